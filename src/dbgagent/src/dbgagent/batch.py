@@ -0,0 +1,259 @@
+"""Analyze several targets in one dbgagent invocation.
+
+``dbgagent --jobs 4 ./bin/*`` runs one ``DebugAgentRunner`` per target on a
+thread pool and aggregates the outcomes into a ``BatchReport``. Each target
+gets its own debugger session, report and log file; a target whose analysis
+fails (debugger missing, LLM error, a bug in the runner) is recorded as an
+error and the rest of the batch carries on.
+
+All workers share the process-wide LLM rate limiter (``--llm-rate-limit``
+buckets are per provider, not per session) and one cancellation
+``Context``: Ctrl-C or a cancelled batch ends every running session, and
+``--timeout`` applies to each target separately.
+"""
+from __future__ import annotations
+
+from concurrent.futures import ThreadPoolExecutor, as_completed
+from dataclasses import dataclass, field, replace
+from pathlib import Path
+from typing import Any, Callable, Dict, List, Optional
+import glob
+import json
+import time
+
+from dbgcopilot.analyze.result import (
+    SCHEMA_VERSION,
+    SEVERITY_NONE,
+    AnalysisResult,
+    render_markdown,
+    render_text,
+    severity_rank,
+)
+from dbgcopilot.debugger.base import DebuggerUnavailable
+from dbgcopilot.debugger.factory import detect_backend
+from dbgcopilot.llm.base import LLMError
+from dbgcopilot.utils import tracing
+from dbgcopilot.utils.context import Cancelled, Context, DeadlineExceeded
+
+from .runner import AgentRequest, DebugAgentRunner
+
+DEFAULT_JOBS = 4
+# Characters of each target's summary shown in the batch overview.
+SUMMARY_WIDTH = 80
+
+STATUS_OK = "ok"
+STATUS_ERROR = "error"
+# LLM, network or debugger infrastructure failed; nothing is known about the target.
+STATUS_INFRA = "infra-error"
+STATUS_TIMEOUT = "timeout"
+STATUS_CANCELLED = "cancelled"
+
+
+def expand_targets(patterns: List[str]) -> List[str]:
+    """Paths named by ``patterns``, globs expanded in sorted order, duplicates dropped.
+
+    Raises ``ValueError`` for a glob that matches nothing, so a typo does not
+    quietly shrink the batch.
+    """
+    targets: List[str] = []
+    for pattern in patterns:
+        if glob.has_magic(pattern):
+            matches = sorted(glob.glob(str(Path(pattern).expanduser())))
+            if not matches:
+                raise ValueError(f"{pattern!r} matches no files")
+        else:
+            matches = [pattern]
+        targets.extend(m for m in matches if m not in targets)
+    return targets
+
+
+@dataclass
+class BatchEntry:
+    target: str
+    status: str = STATUS_OK
+    debugger: str = ""
+    result: Optional[AnalysisResult] = None
+    error: str = ""
+    seconds: float = 0.0
+    report_path: str = ""
+
+    @property
+    def severity(self) -> str:
+        return self.result.severity if self.result is not None else SEVERITY_NONE
+
+    def describe(self) -> str:
+        if self.result is None:
+            return f"{self.status}: {self.error}"
+        issue = self.result.issue_type + (f" ({self.result.issue_detail})" if self.result.issue_detail else "")
+        summary = self.result.summary
+        if len(summary) > SUMMARY_WIDTH:
+            summary = summary[: SUMMARY_WIDTH - 3].rstrip() + "..."
+        return f"{self.severity}: {issue}" + (f" - {summary}" if summary else "")
+
+    def to_dict(self) -> Dict[str, Any]:
+        return {
+            "target": self.target,
+            "status": self.status,
+            "severity": self.severity,
+            "debugger": self.debugger,
+            "error": self.error,
+            "seconds": round(self.seconds, 3),
+            "report_path": self.report_path,
+            "result": self.result.to_dict() if self.result is not None else None,
+        }
+
+
+def _new_entries() -> List[BatchEntry]:
+    return []
+
+
+@dataclass
+class BatchReport:
+    entries: List[BatchEntry] = field(default_factory=_new_entries)
+
+    @property
+    def worst_severity(self) -> str:
+        severities = [entry.severity for entry in self.entries if entry.result is not None]
+        return max(severities, key=severity_rank, default=SEVERITY_NONE)
+
+    def failed(self) -> List[BatchEntry]:
+        """Targets whose analysis did not finish."""
+        return [entry for entry in self.entries if entry.status != STATUS_OK]
+
+    def summary_lines(self) -> List[str]:
+        width = max((len(entry.target) for entry in self.entries), default=0)
+        lines = [f"Analyzed {len(self.entries)} target(s); worst severity: {self.worst_severity}"]
+        lines += [f"  {entry.target.ljust(width)}  {entry.describe()}" for entry in self.entries]
+        return lines
+
+
+def run_batch(
+    requests: List[AgentRequest],
+    *,
+    jobs: int = DEFAULT_JOBS,
+    context: Optional[Context] = None,
+    on_done: Optional[Callable[[BatchEntry, int, int], None]] = None,
+    runner_factory: Callable[[AgentRequest], DebugAgentRunner] = DebugAgentRunner,
+) -> BatchReport:
+    """Analyze every request on ``jobs`` workers; entries keep the order of ``requests``.
+
+    ``on_done(entry, finished, total)`` is called on the calling thread as
+    each target finishes. Ctrl-C cancels ``context`` (and so every session
+    still running), waits for the workers to end them and re-raises.
+    """
+    batch = context or Context.background().child()
+    report = BatchReport(entries=[BatchEntry(target=r.program or r.main_class or "") for r in requests])
+    pool = ThreadPoolExecutor(max_workers=max(jobs, 1), thread_name_prefix="dbgagent-batch")
+    try:
+        with tracing.span(tracing.SPAN_BATCH, targets=len(requests), jobs=jobs) as span:
+            # Each worker runs in a copy of this context, so its analysis is a child of the batch span.
+            futures = {
+                pool.submit(tracing.propagate(_analyze), request, entry, batch, runner_factory): entry
+                for request, entry in zip(requests, report.entries)
+            }
+            for finished, future in enumerate(as_completed(futures), start=1):
+                future.result()
+                if on_done is not None:
+                    on_done(futures[future], finished, len(futures))
+            span.set(failed=len(report.failed()), severity=report.worst_severity)
+    except KeyboardInterrupt:
+        batch.cancel("interrupted")
+        raise
+    finally:
+        pool.shutdown(wait=True, cancel_futures=True)
+        if context is None:
+            batch.close()
+    return report
+
+
+def _analyze(
+    request: AgentRequest,
+    entry: BatchEntry,
+    batch: Context,
+    runner_factory: Callable[[AgentRequest], DebugAgentRunner],
+) -> None:
+    start = time.monotonic()
+    ctx = batch.child(timeout=request.timeout)
+    try:
+        if batch.cancelled:
+            raise batch.error or Cancelled("cancelled")
+        if request.debugger == "auto":
+            request = replace(request, debugger=detect_backend(request.program or ""))
+        entry.debugger = request.debugger
+        runner = runner_factory(request)
+        final_report = runner.run(context=ctx)
+        entry.result = runner.result or runner.analysis_result(final_report)
+        entry.report_path = str(request.report_path)
+    except DeadlineExceeded as exc:
+        entry.status, entry.error = STATUS_TIMEOUT, str(exc)
+    except Cancelled as exc:
+        entry.status, entry.error = STATUS_CANCELLED, str(exc)
+    except (LLMError, DebuggerUnavailable, OSError) as exc:
+        entry.status, entry.error = STATUS_INFRA, str(exc)
+    except Exception as exc:
+        # One broken target must not take the batch down with it.
+        entry.status, entry.error = STATUS_ERROR, f"{type(exc).__name__}: {exc}"
+    finally:
+        ctx.close()
+        entry.seconds = time.monotonic() - start
+
+
+def render_batch_text(report: BatchReport) -> str:
+    parts = ["\n".join(report.summary_lines())]
+    for entry in report.entries:
+        body = render_text(entry.result) if entry.result is not None else f"{entry.status}: {entry.error}"
+        parts.append(f"== {entry.target} ==\n{body}")
+    return "\n\n".join(parts)
+
+
+def render_batch_json(report: BatchReport, *, indent: Optional[int] = 2) -> str:
+    doc = {
+        "schema_version": SCHEMA_VERSION,
+        "worst_severity": report.worst_severity,
+        "targets": [entry.to_dict() for entry in report.entries],
+    }
+    return json.dumps(doc, indent=indent, ensure_ascii=False)
+
+
+def render_batch_markdown(report: BatchReport) -> str:
+    lines = [
+        f"# Batch analysis: {len(report.entries)} target(s)",
+        "",
+        f"Worst severity: **{report.worst_severity}**",
+        "",
+        "| Target | Status | Severity | Result |",
+        "| --- | --- | --- | --- |",
+    ]
+    for entry in report.entries:
+        detail = entry.describe().replace("|", "\\|")
+        lines.append(f"| `{entry.target}` | {entry.status} | {entry.severity} | {detail} |")
+    for entry in report.entries:
+        if entry.result is not None:
+            # Each target's own report, one heading level down.
+            body = render_markdown(entry.result).rstrip("\n").splitlines()
+            lines += [""] + [f"#{line}" if line.startswith("#") else line for line in body]
+    return "\n".join(lines) + "\n"
+
+
+def target_paths(path: Path, index: int, target: str) -> Path:
+    """Per-target file next to ``path``: report.md -> report-03-server.md."""
+    name = "".join(ch if ch.isalnum() or ch in "-_." else "_" for ch in Path(target).name) or "target"
+    return path.with_name(f"{path.stem}-{index:02d}-{name}{path.suffix}")
+
+
+__all__ = [
+    "DEFAULT_JOBS",
+    "STATUS_CANCELLED",
+    "STATUS_ERROR",
+    "STATUS_INFRA",
+    "STATUS_OK",
+    "STATUS_TIMEOUT",
+    "BatchEntry",
+    "BatchReport",
+    "expand_targets",
+    "render_batch_json",
+    "render_batch_markdown",
+    "render_batch_text",
+    "run_batch",
+    "target_paths",
+]
@@ -2,15 +2,49 @@
 from __future__ import annotations
 
 import argparse
+import io
 import os
 import sys
 from pathlib import Path
+from dataclasses import replace
 from datetime import datetime, timezone
+from typing import Callable, TextIO
 import textwrap
 
+from dbgcopilot.analyze.patch import DEFAULT_PATCH_ATTEMPTS
+from dbgcopilot.analyze.pins import Pin
+from dbgcopilot.analyze.result import (
+    SEVERITIES,
+    SEVERITY_NONE,
+    confidence_warning,
+    render_json,
+    render_markdown,
+    render_text,
+    severity_rank,
+)
+from dbgcopilot.debugger.base import DebuggerUnavailable
+from dbgcopilot.debugger.hang import DEFAULT_HANG_TIMEOUT
+from dbgcopilot.debugger.launch import Invocation, parse_env
 from dbgcopilot.llm import providers as provider_registry
+from dbgcopilot.llm.base import LLMError
+from dbgcopilot.prompts.templates import TEMPLATE_DIR_KEY, PromptTemplateError, load_analysis_templates
+from dbgcopilot.utils.context import Cancelled, DeadlineExceeded
+from dbgcopilot.utils.log import DEFAULT_LEVEL, LEVEL_ENV_VAR, LEVELS, configure as configure_logging
+from dbgcopilot.utils.tracing import configure as configure_tracing
+from dbgcopilot.utils.pathmap import PathMap
 from dbgcopilot.utils.tools import warn_missing_debugger_tools
 
+from .batch import (
+    DEFAULT_JOBS,
+    STATUS_INFRA,
+    STATUS_TIMEOUT,
+    expand_targets,
+    render_batch_json,
+    render_batch_markdown,
+    render_batch_text,
+    run_batch,
+    target_paths,
+)
 from .runner import AgentRequest, DebugAgentRunner
 
 
@@ -24,8 +58,20 @@ SUPPORTED_DEBUGGERS = [
     "pdb",
     "delve",
     "radare2",
+    "auto",
 ]
 
+EXIT_OK = 0
+EXIT_ERROR = 1
+# argparse exits with 2 on usage errors.
+EXIT_INFRA = 3
+# --timeout expired before the analysis finished.
+EXIT_TIMEOUT = 4
+# Ctrl-C, as a shell reports death by SIGINT.
+EXIT_INTERRUPTED = 130
+# Analysis ran and found something at or above --fail-on.
+EXIT_SEVERITY = {"info": 10, "warning": 11, "critical": 12}
+
 
 def _default_path(prefix: str, suffix: str) -> Path:
     ts = datetime.now(timezone.utc).strftime("%Y%m%d-%H%M%S")
@@ -34,7 +80,7 @@ def _default_path(prefix: str, suffix: str) -> Path:
 
 def build_parser() -> argparse.ArgumentParser:
     provider_choices = provider_registry.list_providers()
-    default_provider = "openrouter"
+    default_provider = os.getenv(provider_registry.PROVIDER_ENV_VAR) or "openrouter"
     if provider_choices:
         if default_provider not in provider_choices:
             default_provider = provider_choices[0]
@@ -53,7 +99,17 @@ def build_parser() -> argparse.ArgumentParser:
               dbgagent --debugger gdb --program ./a.out --goal crash --llm-provider deepseek \
                        --llm-key $DEEPSEEK_API_KEY --log-session
 
+            Several targets are analyzed in parallel and summarized in one report:
+              dbgagent --debugger auto --jobs 4 './build/failing/*'
+
+            Everything after -- is the program's command line (or its arguments, after --program):
+              dbgagent --debugger delve --goal hang --env CONFIG=prod.yaml -- ./server --port 8080
+
             To continue from a hand-edited report, pass --resume-from path/to/report.md.
+
+            Exit codes: 0 nothing at or above --fail-on, 1 internal error, 2 usage error,
+            3 LLM/network/debugger connection failure, 4 --timeout reached, 130 interrupted (Ctrl-C),
+            10/11/12 an info/warning/critical finding.
             """
         ),
     )
@@ -61,10 +117,68 @@ def build_parser() -> argparse.ArgumentParser:
         "--debugger",
         choices=SUPPORTED_DEBUGGERS,
         default="gdb",
-        help="Debugger backend to use",
+        help="Debugger backend to use (auto picks delve for Go binaries, pdb for Python scripts, lldb otherwise)",
     )
     parser.add_argument("--program", help="Path to the binary under test", default=None)
+    parser.add_argument(
+        "targets",
+        nargs="*",
+        metavar="TARGET",
+        help="Binaries to analyze, globs allowed; more than one (with --program) runs them as a batch",
+    )
+    parser.add_argument(
+        "--jobs",
+        type=int,
+        default=DEFAULT_JOBS,
+        metavar="N",
+        help=f"Targets analyzed at once in a batch (default {DEFAULT_JOBS}); all share the LLM rate limit",
+    )
+    parser.add_argument(
+        "--env",
+        action="append",
+        default=[],
+        metavar="KEY=VAL",
+        help="Set an environment variable for the launched program (repeatable)",
+    )
+    parser.add_argument(
+        "--inherit-env",
+        action=argparse.BooleanOptionalAction,
+        default=True,
+        help="Start the program with dbgagent's environment plus --env (default); --no-inherit-env gives it only --env",
+    )
+    parser.add_argument(
+        "--record",
+        default=None,
+        metavar="FILE",
+        help="Record the session (program invocation, debugger commands, LLM replies) as an NDJSON transcript",
+    )
     parser.add_argument("--core", dest="corefile", help="Path to a core dump", default=None)
+    parser.add_argument(
+        "--remote",
+        default=None,
+        metavar="HOST:PORT",
+        help="Connect to a headless Delve server (dlv debug/exec/attach --headless --listen=HOST:PORT)",
+    )
+    parser.add_argument(
+        "--pid",
+        type=int,
+        default=None,
+        help="Attach Delve to this running Go process; it is detached and resumed when the session ends",
+    )
+    parser.add_argument(
+        "--hang-timeout",
+        type=float,
+        default=None,
+        metavar="SECONDS",
+        help="Run the Go program and pause it for analysis after SECONDS without output, breakpoint hits or CPU use",
+    )
+    parser.add_argument(
+        "--api-version",
+        type=int,
+        choices=[1, 2],
+        default=2,
+        help="Delve JSON-RPC API version to negotiate with --remote (default: 2)",
+    )
     parser.add_argument(
         "--goal", choices=["crash", "hang", "leak", "custom"], default="crash", help="Primary investigation goal"
     )
@@ -81,6 +195,29 @@ def build_parser() -> argparse.ArgumentParser:
         parser.add_argument("--llm-provider", default=default_provider, help=provider_help)
     parser.add_argument("--llm-model", default=None, help="Override model for the selected provider")
     parser.add_argument("--llm-key", default=None, help="API key for the selected provider (optional)")
+    parser.add_argument(
+        "--llm-fallback",
+        default=os.getenv(provider_registry.FALLBACK_ENV_VAR),
+        help="Comma-separated providers to try when the primary is rate limited (HTTP 429) or times out",
+    )
+    parser.add_argument(
+        "--llm-retries",
+        type=int,
+        default=None,
+        help="Attempts per LLM call on 429/5xx/timeouts, 1 to disable (default 3, or $DBGCOPILOT_LLM_RETRIES)",
+    )
+    parser.add_argument(
+        "--llm-retry-max-delay",
+        type=float,
+        default=None,
+        help="Longest wait between attempts in seconds; a longer Retry-After is cut to it (default 30)",
+    )
+    parser.add_argument(
+        "--llm-rate-limit",
+        type=float,
+        default=None,
+        help="Client-side cap on LLM requests per minute (default: none, or $DBGCOPILOT_LLM_RATE_LIMIT)",
+    )
     parser.add_argument(
         "--classpath",
         default=None,
@@ -96,6 +233,12 @@ def build_parser() -> argparse.ArgumentParser:
         default=None,
         help="Main class for jdb (fully qualified, e.g. com.example.Main)",
     )
+    parser.add_argument(
+        "--python",
+        default=None,
+        help="Interpreter for pdb: a path, a name on PATH or a virtualenv directory "
+        "(default: $VIRTUAL_ENV, a .venv next to the script, its shebang, then this Python)",
+    )
     parser.add_argument("--max-steps", type=int, default=16, help="Maximum auto iterations")
     parser.add_argument(
         "--language",
@@ -108,18 +251,277 @@ def build_parser() -> argparse.ArgumentParser:
         help="Enable plaintext session logging (default path in /tmp)",
     )
     parser.add_argument("--log-file", default=None, help="Explicit log file path (implies --log-session)")
+    parser.add_argument(
+        "--log-level",
+        choices=LEVELS,
+        default=None,
+        help=(
+            "Diagnostics written to stderr; debug traces every debugger command and LLM prompt and answer, "
+            f"redacted (default: ${LEVEL_ENV_VAR} or {DEFAULT_LEVEL})"
+        ),
+    )
     parser.add_argument("--report-file", default=None, help="Where to write the final report (defaults to /tmp)")
+    parser.add_argument(
+        "--format",
+        dest="output_format",
+        choices=["text", "json", "md"],
+        default="text",
+        help=(
+            "Print the analysis as text, as one versioned JSON document (for CI) or as a Markdown report "
+            "for incident write-ups, on stdout (or --output); progress and status lines go to stderr"
+        ),
+    )
+    parser.add_argument(
+        "--output",
+        default=None,
+        metavar="FILE",
+        help="Write the result (in --format) to FILE instead of stdout; '-' is stdout",
+    )
+    parser.add_argument(
+        "--quiet",
+        "-q",
+        action="store_true",
+        help=(
+            "Print nothing but the result: no progress or status lines and only error-level diagnostics; "
+            "errors that explain a failing exit code still go to stderr"
+        ),
+    )
+    parser.add_argument(
+        "--stream",
+        action="store_true",
+        help="Show the LLM's answers on stderr as they are generated, with the other progress lines",
+    )
+    parser.add_argument(
+        "--fail-on",
+        choices=SEVERITIES,
+        default=SEVERITY_NONE,
+        help="Exit non-zero when the analysis severity is at least this level (default: none, always exit 0)",
+    )
+    parser.add_argument(
+        "--interactive",
+        action="store_true",
+        help="After the report, keep the debugger session open and answer follow-up questions",
+    )
+    parser.add_argument(
+        "--no-llm",
+        action="store_true",
+        help=(
+            "Never call an LLM: the deterministic analyzers (panic, deadlock, channel, crash and leak) write the "
+            "report from templates, in the same formats (for air-gapped machines)"
+        ),
+    )
+    parser.add_argument(
+        "--suggest-patch",
+        action="store_true",
+        help=(
+            "After the report, ask the LLM for a unified diff implementing the fix, written against the source of "
+            "the frames involved; a diff that does not apply to the current files is rejected and asked for again. "
+            "It is printed with the result and saved next to the report, and never applied without --apply"
+        ),
+    )
+    parser.add_argument(
+        "--apply",
+        action="store_true",
+        help="Write the diff --suggest-patch checked to the source files",
+    )
+    parser.add_argument(
+        "--patch-attempts",
+        type=int,
+        default=DEFAULT_PATCH_ATTEMPTS,
+        metavar="N",
+        help=f"LLM answers to try before giving up on --suggest-patch (default {DEFAULT_PATCH_ATTEMPTS})",
+    )
+    parser.add_argument(
+        "--no-cache",
+        action="store_true",
+        help="Always call the LLM instead of reusing cached answers to identical prompts",
+    )
+    parser.add_argument(
+        "--cache-dir",
+        default=None,
+        help="Directory for cached LLM responses (default: $DBGCOPILOT_CACHE_DIR or ~/.cache/dbgcopilot/llm)",
+    )
+    parser.add_argument(
+        "--pin",
+        action="append",
+        default=[],
+        metavar="ID|TEXT",
+        help=(
+            "Always show this goroutine in full in the prompt, with its locals loaded in depth: a goroutine id, "
+            "or text matched against function names and file:line (repeatable)"
+        ),
+    )
+    parser.add_argument(
+        "--path-map",
+        action="append",
+        default=[],
+        metavar="BUILD=LOCAL",
+        help=(
+            "Map source paths recorded in the binary to local ones (repeatable; longest prefix wins). "
+            "Use BUILD=$GOMODCACHE (or *=$GOMODCACHE) for -trimpath module@version paths"
+        ),
+    )
+    parser.add_argument(
+        "--program-output-lines",
+        type=int,
+        default=None,
+        metavar="N",
+        help="Lines of the program's own stdout/stderr, with arrival times, to show the LLM (default 40; 0 disables)",
+    )
+    parser.add_argument(
+        "--prompt-templates",
+        default=None,
+        metavar="DIR",
+        help=(
+            "Directory of deadlock.txt, panic.txt and generic.txt analysis templates overriding the built-in wording "
+            "(default: $DBGCOPILOT_PROMPT_TEMPLATES)"
+        ),
+    )
+    parser.add_argument(
+        "--timeout",
+        type=float,
+        default=None,
+        metavar="SECONDS",
+        help=(
+            "Give up after this long in total, LLM calls, dlv attach and core loading included; the debugger "
+            "session is ended (an attached process detached, a launched one killed) (default: no limit)"
+        ),
+    )
+    parser.add_argument(
+        "--triage",
+        action="store_true",
+        help=(
+            "Run a launched Go program once under hang detection (--hang-timeout, default "
+            f"{DEFAULT_HANG_TIMEOUT:g}s) and skip the LLM when it exits with status 0, no panic and no hang; "
+            "pdb scripts are always triaged"
+        ),
+    )
+    parser.add_argument(
+        "--force-analyze",
+        action="store_true",
+        help=(
+            "Ask the LLM even when triage finds nothing wrong with the run (status 0, no panic, no hang); "
+            "by default such runs skip the LLM"
+        ),
+    )
     parser.add_argument("--resume-from", default=None, help="Existing report/notes to inject as additional context")
     return parser
 
 
 def main(argv: list[str] | None = None) -> int:
     parser = build_parser()
+    argv, command = _split_command(sys.argv[1:] if argv is None else argv)
     args = parser.parse_args(argv)
+    if command and not args.program and not args.targets:
+        args.program, command = command[0], command[1:]
 
-    warn_missing_debugger_tools("dbgagent")
+    try:
+        configure_logging(args.log_level or ("error" if args.quiet else None))
+    except ValueError as exc:
+        parser.error(f"${LEVEL_ENV_VAR}: {exc}")
+    configure_tracing()
+    if not args.quiet:
+        warn_missing_debugger_tools("dbgagent")
 
     debugger = args.debugger
+    # stdout carries the result alone, so scripts can pipe it; progress goes to stderr, or nowhere with --quiet.
+    status: TextIO = io.StringIO() if args.quiet else sys.stderr
+
+    if args.hang_timeout is not None:
+        if args.hang_timeout <= 0:
+            parser.error("--hang-timeout must be positive")
+        if args.corefile:
+            parser.error("--hang-timeout needs a live process, not a core dump")
+        if args.remote:
+            parser.error("--hang-timeout watches a local Delve session, not a --remote server")
+    if args.triage:
+        for flag, value in (("--core", args.corefile), ("--remote", args.remote), ("--pid", args.pid)):
+            if value:
+                parser.error(f"--triage runs a program dbgagent launches, not with {flag}")
+    if args.interactive and args.output_format in {"json", "md"}:
+        parser.error(f"--interactive cannot be combined with --format {args.output_format}")
+    if args.quiet and args.stream:
+        parser.error("--stream shows progress; drop --quiet")
+    if args.quiet and args.interactive:
+        parser.error("--interactive is a conversation on the terminal; drop --quiet")
+    if args.no_llm and args.stream:
+        parser.error("--stream shows the LLM's answers; drop --no-llm")
+    if args.no_llm and args.interactive:
+        parser.error("--interactive needs an LLM; drop --no-llm")
+    if args.no_llm and args.force_analyze:
+        parser.error("--force-analyze asks the LLM; drop --no-llm")
+    if args.no_llm and args.suggest_patch:
+        parser.error("--suggest-patch asks the LLM for the diff; drop --no-llm")
+    if args.apply and not args.suggest_patch:
+        parser.error("--apply writes the diff --suggest-patch proposes; add --suggest-patch")
+    if args.patch_attempts < 1:
+        parser.error("--patch-attempts must be at least 1")
+    try:
+        invocation = Invocation(args=command, env=parse_env(args.env), inherit_env=args.inherit_env)
+    except ValueError as exc:
+        parser.error(f"--env: {exc}")
+    if args.remote and args.pid:
+        parser.error("--remote and --pid are mutually exclusive")
+    if args.llm_retries is not None and args.llm_retries < 1:
+        parser.error("--llm-retries must be at least 1")
+    for flag, value in (("--llm-retry-max-delay", args.llm_retry_max_delay), ("--llm-rate-limit", args.llm_rate_limit)):
+        if value is not None and value < 0:
+            parser.error(f"{flag} must not be negative")
+    if args.timeout is not None and args.timeout <= 0:
+        parser.error("--timeout must be positive")
+    if args.jobs < 1:
+        parser.error("--jobs must be at least 1")
+    try:
+        programs = ([args.program] if args.program else []) + expand_targets(args.targets)
+    except ValueError as exc:
+        parser.error(str(exc))
+    batch = len(programs) > 1
+    if programs:
+        args.program = programs[0]
+    if batch:
+        single = (("--core", args.corefile), ("--remote", args.remote), ("--pid", args.pid))
+        options = (
+            ("--interactive", args.interactive),
+            ("--stream", args.stream),
+            ("--suggest-patch", args.suggest_patch),
+            ("--record", args.record),
+            ("-- ARGS", command),
+        )
+        for flag, value in single + options:
+            if value:
+                parser.error(f"{flag} applies to a single target; pass one program")
+        if debugger == "jdb":
+            parser.error("jdb takes --main-class, not several targets")
+    if args.program_output_lines is not None and args.program_output_lines < 0:
+        parser.error("--program-output-lines must not be negative")
+    for spec in args.pin:
+        try:
+            Pin.parse(spec)
+        except ValueError as exc:
+            parser.error(f"--pin: {exc}")
+    try:
+        PathMap.parse(args.path_map)
+    except ValueError as exc:
+        parser.error(f"--path-map: {exc}")
+    try:
+        load_analysis_templates({TEMPLATE_DIR_KEY: args.prompt_templates or ""})
+    except (PromptTemplateError, OSError) as exc:
+        parser.error(f"--prompt-templates: {exc}")
+    if args.remote or args.pid:
+        if debugger not in {"auto", "delve"}:
+            parser.error("--remote and --pid are only supported with the delve debugger")
+        debugger = "delve"
+
+    if debugger == "auto" and not batch:
+        if not args.program:
+            parser.error("--debugger auto requires --program")
+        from dbgcopilot.debugger import DebuggerError, detect_backend
+
+        try:
+            debugger = detect_backend(args.program)
+        except DebuggerError as exc:
+            parser.error(str(exc))
+        print(f"[dbgagent] Auto-selected debugger: {debugger}", file=status)
 
     if debugger == "jdb":
         if args.program:
@@ -128,8 +530,16 @@ def main(argv: list[str] | None = None) -> int:
             parser.error("jdb debugger requires --classpath pointing to the compiled classes or jar")
         if not args.main_class:
             parser.error("jdb debugger requires --main-class (fully qualified entry point)")
+    if not invocation.default:
+        if debugger in {"jdb", "radare2"}:
+            parser.error(f"Program arguments and --env are not supported with {debugger}")
+        for flag, value in (("--core", args.corefile), ("--remote", args.remote), ("--pid", args.pid)):
+            if value:
+                parser.error(f"Program arguments and --env apply to a program dbgagent launches, not with {flag}")
+    if args.python and debugger != "pdb":
+        parser.error("--python is only supported with the pdb debugger")
     else:
-        if debugger in {"delve", "radare2", "pdb", "rust-lldb", "lldb-rust"} and not args.program:
+        if debugger in {"delve", "radare2", "pdb", "rust-lldb", "lldb-rust"} and not args.program and not (args.remote or args.pid):
             parser.error(f"{debugger} debugger requires --program")
 
     log_enabled = bool(args.log_session or args.log_file or os.getenv("DBGAGENT_LOG"))
@@ -165,23 +575,177 @@ def main(argv: list[str] | None = None) -> int:
         log_enabled=log_enabled,
         log_path=log_path,
         report_path=report_path,
+        llm_fallback=args.llm_fallback,
+        remote=args.remote,
+        api_version=args.api_version,
+        pid=args.pid,
+        hang_timeout=args.hang_timeout,
+        output_format=args.output_format,
+        use_cache=not args.no_cache,
+        cache_dir=args.cache_dir,
+        interactive=args.interactive,
+        python=args.python,
+        llm_retries=args.llm_retries,
+        llm_retry_max_delay=args.llm_retry_max_delay,
+        llm_rate_limit=args.llm_rate_limit,
+        path_map=args.path_map,
+        program_output_lines=args.program_output_lines,
+        prompt_templates=args.prompt_templates,
+        timeout=args.timeout,
+        triage=args.triage,
+        force_analyze=args.force_analyze,
+        no_llm=args.no_llm,
+        pins=args.pin,
+        token_sink=_stream_to(sys.stderr) if args.stream else None,
+        suggest_patch=args.suggest_patch,
+        apply_patch=args.apply,
+        patch_attempts=args.patch_attempts,
+        invocation=invocation,
+        record_path=Path(args.record) if args.record else None,
     )
 
+    if batch:
+        return _run_batch(request, programs, args, status)
+
     runner = DebugAgentRunner(request)
     try:
         final_report = runner.run()
+    except (KeyboardInterrupt, Cancelled) as exc:
+        return _ended_early(exc, args.timeout)
+    except (LLMError, DebuggerUnavailable, OSError) as exc:
+        # Infrastructure failed; nothing is known about the program under test.
+        print(f"[dbgagent] Infrastructure error: {exc}", file=sys.stderr)
+        return EXIT_INFRA
     except Exception as exc:  # pragma: no cover - best effort CLI safeguard
         print(f"[dbgagent] Error: {exc}", file=sys.stderr)
         print("", file=sys.stderr)
         parser.print_help(sys.stderr)
-        return 1
+        return EXIT_ERROR
 
-    print(f"[dbgagent] Session complete. Report saved to {report_path}")
+    result = runner.result or runner.analysis_result(final_report)
+    if args.output_format == "json":
+        _emit_result(render_json(result) + "\n", args.output, status)
+    elif args.output_format == "md":
+        _emit_result(render_markdown(result), args.output, status)
+    else:
+        _emit_result(render_text(result) + "\n", args.output, status)
+    if result.low_confidence and args.output_format != "text":
+        # The text view leads with this warning; JSON and Markdown readers get it on stderr too.
+        print(f"[dbgagent] {confidence_warning(result)}", file=status)
+    if runner.skipped_analysis:
+        print("[dbgagent] Triage found no anomaly; the LLM was not consulted (--force-analyze to ask)", file=status)
+    if runner.patched_files:
+        print(f"[dbgagent] Applied the suggested patch to {', '.join(runner.patched_files)}", file=status)
+    elif runner.patch_path is not None:
+        print(f"[dbgagent] Suggested patch saved to {runner.patch_path}; review it, then git apply it", file=status)
+    if runner.patch_error:
+        print(f"[dbgagent] {runner.patch_error}", file=status)
+    print(f"[dbgagent] Session complete. Report saved to {report_path}", file=status)
     if log_enabled and log_path is not None:
-        print(f"[dbgagent] Session log stored at {log_path}")
+        print(f"[dbgagent] Session log stored at {log_path}", file=status)
     if final_report.strip().startswith("Final Report"):
-        print("[dbgagent] Investigation ended without a detailed report. Inspect the log for next steps.")
-    return 0
+        print("[dbgagent] Investigation ended without a detailed report. Inspect the log for next steps.", file=status)
+    if args.interactive:
+        try:
+            runner.interact(final_report)
+        except (KeyboardInterrupt, Cancelled) as exc:
+            return _ended_early(exc, args.timeout)
+        except (LLMError, DebuggerUnavailable, OSError) as exc:
+            print(f"[dbgagent] Infrastructure error: {exc}", file=sys.stderr)
+            return EXIT_INFRA
+    return exit_code(result.severity, args.fail_on)
+
+
+def _run_batch(request: AgentRequest, programs: list[str], args: argparse.Namespace, status: TextIO) -> int:
+    """Analyze ``programs`` on ``--jobs`` workers and print one aggregated report."""
+    requests = []
+    for index, program in enumerate(programs, start=1):
+        log_path = target_paths(request.log_path, index, program) if request.log_path is not None else None
+        requests.append(
+            replace(
+                request,
+                program=program,
+                report_path=target_paths(request.report_path, index, program),
+                log_path=log_path,
+            )
+        )
+    print(f"[dbgagent] Analyzing {len(programs)} target(s), {min(args.jobs, len(programs))} at a time", file=status)
+
+    def done(entry, finished: int, total: int) -> None:
+        print(f"[dbgagent] [{finished}/{total}] {entry.target}: {entry.describe()}", file=status)
+
+    try:
+        report = run_batch(requests, jobs=args.jobs, on_done=done)
+    except KeyboardInterrupt as exc:
+        return _ended_early(exc, args.timeout)
+    if args.output_format == "json":
+        _emit_result(render_batch_json(report) + "\n", args.output, status)
+    elif args.output_format == "md":
+        _emit_result(render_batch_markdown(report), args.output, status)
+    else:
+        _emit_result(render_batch_text(report) + "\n", args.output, status)
+    request.report_path.parent.mkdir(parents=True, exist_ok=True)
+    request.report_path.write_text(render_batch_markdown(report), encoding="utf-8")
+    print(f"[dbgagent] Batch complete. Summary saved to {request.report_path}", file=status)
+    code = exit_code(report.worst_severity, args.fail_on)
+    if code != EXIT_OK:
+        return code
+    failed = report.failed()
+    if not failed:
+        return EXIT_OK
+    if all(entry.status == STATUS_TIMEOUT for entry in failed):
+        return EXIT_TIMEOUT
+    if all(entry.status == STATUS_INFRA for entry in failed):
+        return EXIT_INFRA
+    return EXIT_ERROR
+
+
+def _split_command(argv: list[str]) -> tuple[list[str], list[str]]:
+    """dbgagent's own arguments, and the program's command line after the first "--"."""
+    if "--" not in argv:
+        return argv, []
+    idx = argv.index("--")
+    return argv[:idx], argv[idx + 1 :]
+
+
+def _emit_result(text: str, output: str | None, status: TextIO) -> None:
+    """The result document on stdout, or in ``--output FILE``."""
+    if not output or output == "-":
+        sys.stdout.write(text)
+        sys.stdout.flush()
+        return
+    path = Path(output)
+    path.parent.mkdir(parents=True, exist_ok=True)
+    path.write_text(text, encoding="utf-8")
+    print(f"[dbgagent] Result written to {path}", file=status)
+
+
+def _stream_to(stream: TextIO) -> Callable[[str], None]:
+    """Token sink writing the LLM's answer to ``stream`` as it arrives."""
+
+    def write(chunk: str) -> None:
+        stream.write(chunk)
+        stream.flush()
+
+    return write
+
+
+def _ended_early(exc: BaseException, timeout: float | None) -> int:
+    # The runner has already aborted the LLM request and ended the debugger session.
+    if isinstance(exc, DeadlineExceeded):
+        print(f"[dbgagent] Timed out after {timeout or 0:g}s; the debugger session was ended", file=sys.stderr)
+        return EXIT_TIMEOUT
+    print("[dbgagent] Interrupted; the debugger session was ended", file=sys.stderr)
+    return EXIT_INTERRUPTED
+
+
+def exit_code(severity: str, fail_on: str) -> int:
+    """Process exit status for an analysis of ``severity`` under the ``--fail-on`` threshold."""
+    if fail_on == SEVERITY_NONE or severity == SEVERITY_NONE:
+        return EXIT_OK
+    if severity_rank(severity) < severity_rank(fail_on):
+        return EXIT_OK
+    return EXIT_SEVERITY[severity]
 
 
 if __name__ == "__main__":  # pragma: no cover
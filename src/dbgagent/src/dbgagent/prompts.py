@@ -14,7 +14,8 @@ AGENT_PROMPT_CONFIG: dict[str, object] = {
         "Place ONLY the literal debugger command inside a standalone <cmd>THE_SINGLE_COMMAND</cmd> tag; keep commentary outside the tag.",
         "Never batch multiple commands, shell pipelines, or code blocks inside one <cmd>.",
         "Read the most recent debugger output and facts carefully before planning the next step.",
-        "When you conclude, output a Final Report with the headings: Analysis Summary, Findings, Suggested Fixes, Next Steps.",
+        "When you conclude, output a Final Report with the headings: Analysis Summary, Diagnosis, Alternative Hypotheses, Findings, Suggested Fixes, Next Steps.",
+        "Under Diagnosis state the single most likely root cause in one sentence; under Alternative Hypotheses list the other causes you considered as '- cause: why it is less likely' (or '- none').",
         "Quote exact snippets from debugger output when referencing evidence in the Final Report.",
         "If the context is insufficient to continue, explain what data is missing in the Final Report instead of guessing."
     ],
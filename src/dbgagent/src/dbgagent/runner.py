@@ -4,13 +4,57 @@ from __future__ import annotations
 from dataclasses import dataclass, field
 from pathlib import Path
 from typing import Optional, Dict, Any, Callable, cast, Iterable
+from contextlib import contextmanager, nullcontext
+import json
 import logging
+import time
 import uuid
 import re
 
-from dbgcopilot.core.state import Attempt
-from dbgcopilot.utils.io import head_tail_truncate, strip_ansi
+from dbgcopilot.analyze.goroutines import looks_like_goroutine_dump
+from dbgcopilot.analyze.offline import offline_report
+from dbgcopilot.analyze.panic import looks_like_panic
+from dbgcopilot.analyze.patch import (
+    DEFAULT_PATCH_ATTEMPTS,
+    Patch,
+    PatchError,
+    apply_patch,
+    check_patch,
+    extract_diff,
+    patch_prompt,
+    patch_sources,
+)
+from dbgcopilot.analyze.pins import PINS_KEY, Pin, pins_to_config
+from dbgcopilot.analyze.result import SEVERITY_NONE, AnalysisResult, build_result, render_text
+from dbgcopilot.analyze.triage import Triage, triage_run
+from dbgcopilot.core.state import Attempt, resolve_source_context_lines
+from dbgcopilot.debugger.base import StopEvent
+from dbgcopilot.debugger.factory import start_session
+from dbgcopilot.debugger.hang import DEFAULT_HANG_TIMEOUT
+from dbgcopilot.debugger.launch import Invocation
+from dbgcopilot.debugger.output import (
+    DEFAULT_OUTPUT_BYTES,
+    DEFAULT_OUTPUT_LINES,
+    OutputLine,
+    OutputRing,
+    TargetOutputTee,
+    resumes_target,
+    tee_target_output,
+)
+from dbgcopilot.llm.base import LLMError
+from dbgcopilot.llm.cache import CachedClient
+from dbgcopilot.session.interactive import DEFAULT_HISTORY_CHARS, ROLE_ASSISTANT, ROLE_DEBUGGER, Interactive
+from dbgcopilot.session.recorder import Recorder
+from dbgcopilot.utils import tracing
+from dbgcopilot.utils.context import Context, DeadlineExceeded
+from dbgcopilot.utils.io import strip_ansi
+from dbgcopilot.utils.log import set_redactor
+from dbgcopilot.utils.pathmap import PathMap, set_path_map
+from dbgcopilot.utils.redact import Redactor, command_variable
 from dbgcopilot.llm import providers
+from dbgcopilot.prompts.budget import Budget
+from dbgcopilot.prompts.pinned import pinned_section
+from dbgcopilot.prompts.templates import TEMPLATE_DIR_KEY, analysis_sections, issue_for_output, load_analysis_templates
 
 from .prompts import AGENT_PROMPT_CONFIG
 
@@ -34,6 +78,54 @@ class AgentRequest:
     log_enabled: bool
     log_path: Optional[Path]
     report_path: Path
+    llm_fallback: Optional[str] = None
+    # host:port of a headless Delve server to connect to instead of launching dlv.
+    remote: Optional[str] = None
+    api_version: int = 2
+    # Running process to attach Delve to; it is detached (and resumed) when the session ends.
+    pid: Optional[int] = None
+    # Seconds without output, debugger events or CPU use before a running target is treated as hung.
+    hang_timeout: Optional[float] = None
+    # "text", "json" or "md"; selects how the final AnalysisResult is printed.
+    output_format: str = "text"
+    # Serve identical prompts from the on-disk response cache (None keeps the default directory).
+    use_cache: bool = True
+    cache_dir: Optional[str] = None
+    # Keep the debugger session open for follow-up questions after the report (see ``interact``).
+    interactive: bool = False
+    # Interpreter for the pdb debugger (path, PATH name or virtualenv); None lets it pick one.
+    python: Optional[str] = None
+    # LLM attempts per call, longest single backoff wait, and requests per minute; None keeps config/env.
+    llm_retries: Optional[int] = None
+    llm_retry_max_delay: Optional[float] = None
+    llm_rate_limit: Optional[float] = None
+    # "build=local" source path prefixes for binaries built elsewhere (see dbgcopilot.utils.pathmap).
+    path_map: list[str] = field(default_factory=list)
+    # Lines of the target's own stdout/stderr to include in prompts (0 stops capturing; None keeps config).
+    program_output_lines: Optional[int] = None
+    # Directory of per-issue analysis template overrides (see dbgcopilot.prompts.templates).
+    prompt_templates: Optional[str] = None
+    # Seconds the whole session may take, LLM calls and debugger startup included; None for no limit.
+    timeout: Optional[float] = None
+    # Run a launched Go program once under hang detection and skip the LLM when that run is clean.
+    triage: bool = False
+    # Ask the LLM even when triage finds the run clean (exit 0, no panic, no hang).
+    force_analyze: bool = False
+    # Analyze with the deterministic rules only (see dbgcopilot.analyze.offline); no LLM is created or called.
+    no_llm: bool = False
+    # Goroutine ids or stack substrings every prompt shows in full (see dbgcopilot.analyze.pins).
+    pins: list[str] = field(default_factory=list)
+    # Receives the LLM's answers chunk by chunk as they stream in; progress, never the result (None: no streaming).
+    token_sink: Optional[Callable[[str], None]] = None
+    # After the report, ask for a unified diff of the fix and retry until one applies (see dbgcopilot.analyze.patch).
+    suggest_patch: bool = False
+    # Write that diff to the source files; only ever together with suggest_patch.
+    apply_patch: bool = False
+    patch_attempts: int = DEFAULT_PATCH_ATTEMPTS
+    # Arguments and environment for a program the debugger launches (see dbgcopilot.debugger.launch).
+    invocation: Invocation = field(default_factory=Invocation)
+    # NDJSON transcript of the session (see dbgcopilot.session.recorder); None records nothing.
+    record_path: Optional[Path] = None
 
 
 @dataclass
@@ -43,6 +135,15 @@ class AgentState:
     chatlog: list[str] = field(default_factory=list)
     facts: list[str] = field(default_factory=list)
     last_output: str = ""
+    # time.time() when last_output was recorded, to line it up with program output timestamps.
+    last_output_at: float = 0.0
+    # Full output of every executed command, oldest first, for the structured result.
+    outputs: list[str] = field(default_factory=list)
+    hung: bool = False
+    # How the target's first run ended: pdb's first stop, or the stop that ended the hang watch.
+    run_stop: Optional[StopEvent] = None
+    # Verdict on that run (see dbgcopilot.analyze.triage); None when its outcome is unknown.
+    triage: Optional[Triage] = None
 
 
 class DebugAgentRunner:
@@ -53,6 +154,8 @@ class DebugAgentRunner:
         self.state = AgentState(session_id=str(uuid.uuid4())[:8])
         self.prompt_config: Dict[str, Any] = dict(AGENT_PROMPT_CONFIG)
         self.session_config: dict[str, str] = {}
+        # Secret literals already redacted from prompts, hidden wherever they reappear.
+        self._secrets: Dict[str, str] = {}
         self.logger = logging.getLogger(f"dbgagent.session.{self.state.session_id}")
         self.logger.setLevel(logging.INFO)
         self._handler: Optional[logging.Handler] = None
@@ -82,11 +185,46 @@ class DebugAgentRunner:
             self.session_config[f"{provider_key}_model"] = self.request.model
         if self.request.api_key:
             self.session_config[f"{provider_key}_api_key"] = self.request.api_key
+        if self.request.llm_fallback:
+            self.session_config["llm_fallback"] = self.request.llm_fallback
+        if not self.request.use_cache:
+            self.session_config["llm_cache"] = "off"
+        if self.request.cache_dir:
+            self.session_config["llm_cache_dir"] = self.request.cache_dir
+        if self.request.llm_retries is not None:
+            self.session_config["llm_retries"] = str(self.request.llm_retries)
+        if self.request.llm_retry_max_delay is not None:
+            self.session_config["llm_retry_max_delay"] = str(self.request.llm_retry_max_delay)
+        if self.request.llm_rate_limit is not None:
+            self.session_config["llm_rate_limit"] = str(self.request.llm_rate_limit)
+        if self.request.path_map:
+            self.session_config["path_map"] = json.dumps(self.request.path_map)
+        set_path_map(PathMap.from_config(self.session_config))
+        if self.request.pins:
+            self.session_config[PINS_KEY] = pins_to_config([Pin.parse(spec) for spec in self.request.pins])
+        if self.request.program_output_lines is not None:
+            self.session_config["program_output_lines"] = str(self.request.program_output_lines)
+        self.output_lines = _config_int(self.session_config, "program_output_lines", DEFAULT_OUTPUT_LINES)
+        self.program_output = OutputRing(
+            _config_int(self.session_config, "program_output_bytes", DEFAULT_OUTPUT_BYTES)
+        )
+        self._tee: Optional[TargetOutputTee] = None
+        if self.request.prompt_templates:
+            self.session_config[TEMPLATE_DIR_KEY] = self.request.prompt_templates
+        self.analysis_templates = load_analysis_templates(self.session_config)
 
         if self.request.program:
             self.state.facts.append(f"Program path: {self.request.program}")
+            if not self.request.invocation.default:
+                self.state.facts.append(f"Program invocation: {self.invocation_line()}")
+        for mapping in self.request.path_map:
+            self.state.facts.append(f"Source path mapping (build=local): {mapping}")
         if self.request.corefile:
             self.state.facts.append(f"Corefile: {self.request.corefile}")
+        if self.request.remote:
+            self.state.facts.append(f"Remote Delve server: {self.request.remote}")
+        if self.request.pid:
+            self.state.facts.append(f"Attached to running process PID {self.request.pid}")
         if self.request.debugger == "jdb":
             if self.request.classpath:
                 self.state.facts.append(f"JDB classpath: {self.request.classpath}")
@@ -96,35 +234,232 @@ class DebugAgentRunner:
                 self.state.facts.append(f"JDB main class: {self.request.main_class}")
 
         self.backend = None
+        self.result: Optional[AnalysisResult] = None
+        # The checked --suggest-patch diff, or why there is none; the files it was applied to.
+        self.patch: Optional[Patch] = None
+        self.patch_error = ""
+        self.patch_path: Optional[Path] = None
+        self.patched_files: list[str] = []
+        self._last_cache_hit = False
+        self._recorder: Optional[Recorder] = None
+        self.context = Context.background()
+
+    def invocation_line(self) -> str:
+        """How the program is started, as a shell command line."""
+        return self.request.invocation.describe(self.request.program or "")
 
     # ------------------------------------------------------------------
-    def run(self) -> str:
+    def run(self, context: Optional[Context] = None) -> str:
+        """Investigate until the LLM writes a final report; returns it.
+
+        Everything runs under ``context`` (by default one that expires after
+        ``request.timeout``). When it is cancelled, or Ctrl-C interrupts the
+        run, the blocked LLM request or debugger command is aborted, the
+        debugger session is ended (an attached process is detached, a
+        launched one killed) and ``Cancelled`` or ``KeyboardInterrupt``
+        propagates to the caller.
+        """
+        self.context = context or Context.with_timeout(self.request.timeout)
+        # Debug traces hide the same secrets as the prompts.
+        set_redactor(Redactor.from_config(self.session_config, known=self._secrets))
+        completed = False
         try:
-            self._log(f"Starting dbgagent session {self.state.session_id}")
-            self._log(f"Debugger: {self.request.debugger}")
-            self._log(f"Provider: {self.request.provider} | Model: {self.request.model or '(default)'}")
-            self._log(f"Goal: {self.request.goal_type} | Notes: {self.request.goal_text or '(none)'}")
-            self._log(f"Language: {self.request.language}")
-            if self.request.debugger == "jdb":
-                self._log(f"Classpath: {self.request.classpath or '(unset)'}")
-                self._log(f"Main class: {self.request.main_class or '(unset)'}")
-                if self.request.sourcepath:
-                    self._log(f"Sourcepath: {self.request.sourcepath}")
-            else:
-                if self.request.program:
-                    self._log(f"Program: {self.request.program}")
-                if self.request.corefile:
-                    self._log(f"Corefile: {self.request.corefile}")
-            self.backend = self._create_backend()
-            self._prepare_debugger()
-            final_report = self._auto_loop()
-            self._write_report(final_report)
+            with self.context.activate(), tracing.span(
+                tracing.SPAN_ANALYZE,
+                debugger=self.request.debugger,
+                program=self.request.program or self.request.main_class,
+                provider=self.request.provider,
+                model=self.request.model,
+                session=self.state.session_id,
+            ) as span:
+                final_report = self._run()
+                if self.result is not None:
+                    span.set(severity=self.result.severity, confidence=self.result.confidence_level)
+                totals = {k: int(self.usage_totals.get(k, 0)) or None for k in ("prompt_tokens", "completion_tokens")}
+                span.set(**totals)
+            completed = True
             return final_report
+        except KeyboardInterrupt:
+            self._log("Interrupted; ending the debugger session")
+            self.context.cancel("interrupted")
+            raise
         finally:
+            # An interactive session keeps the debugger (and the deadline) until ``interact`` ends.
+            if not (completed and self.request.interactive):
+                self._end_backend(aborted=not completed)
+                self.context.close()
             if self._handler is not None:
                 self.logger.removeHandler(self._handler)
                 self._handler.close()
 
+    def _run(self) -> str:
+        self._log(f"Starting dbgagent session {self.state.session_id}")
+        self._log(f"Debugger: {self.request.debugger}")
+        if self.request.no_llm:
+            self._log("Provider: none (--no-llm)")
+        else:
+            self._log(f"Provider: {self.request.provider} | Model: {self.request.model or '(default)'}")
+        self._log(f"Goal: {self.request.goal_type} | Notes: {self.request.goal_text or '(none)'}")
+        self._log(f"Language: {self.request.language}")
+        if self.request.debugger == "jdb":
+            self._log(f"Classpath: {self.request.classpath or '(unset)'}")
+            self._log(f"Main class: {self.request.main_class or '(unset)'}")
+            if self.request.sourcepath:
+                self._log(f"Sourcepath: {self.request.sourcepath}")
+        else:
+            if self.request.program:
+                self._log(f"Program: {self.request.program}")
+                if not self.request.invocation.default:
+                    self._log(f"Invocation: {self.invocation_line()}")
+            if self.request.corefile:
+                self._log(f"Corefile: {self.request.corefile}")
+            if self.request.remote:
+                self._log(f"Remote: {self.request.remote} (API v{self.request.api_version})")
+        with tracing.span(tracing.SPAN_LAUNCH, debugger=self.request.debugger):
+            self.backend = self._create_backend()
+        if self.request.record_path is not None:
+            self._start_recording(self.request.record_path)
+        if self.output_lines > 0:
+            self._tee = tee_target_output(self.backend, self.program_output)
+        self._prepare_debugger()
+        hang_timeout = self._watch_timeout()
+        watch = self.watch_for_hang(hang_timeout) if hang_timeout else None
+        self.state.triage = self._triage(watch, hang_timeout)
+        if self.state.triage is not None:
+            self._log(f"Triage: {self.state.triage.describe()}")
+            self.state.facts.append(f"Triage: {self.state.triage.describe()}")
+        if self.skipped_analysis and self.state.triage is not None:
+            final_report = self.state.triage.report()
+            self._log("Skipping analysis: triage found no anomaly")
+        elif self.request.no_llm:
+            final_report = self._offline_analysis()
+        else:
+            final_report = self._auto_loop()
+        self.result = self.analysis_result(final_report)
+        try:
+            if self.request.suggest_patch and not self.skipped_analysis:
+                self._suggest_patch(self.result)
+        finally:
+            # The analysis is done; a patch request that fails or is cut short must not lose its report.
+            self._write_report(final_report)
+        return final_report
+
+    @property
+    def skipped_analysis(self) -> bool:
+        """Triage found nothing wrong with the run, so the LLM is not asked (unless ``force_analyze``).
+
+        Only with ``triage``, or under pdb, which always runs the script to
+        its first stop and never sent a clean exit on to the LLM.
+        """
+        triage = self.state.triage
+        if triage is None or triage.anomaly or self.request.force_analyze:
+            return False
+        return self.request.triage or self.request.debugger == "pdb"
+
+    def _watch_timeout(self) -> Optional[float]:
+        """Hang timeout for the first run: ``--hang-timeout``, or the default when ``--triage`` needs the outcome.
+
+        Without either a launched Delve session starts at the program's
+        entry and the LLM drives it as usual.
+        """
+        if self.request.hang_timeout:
+            return self.request.hang_timeout
+        request = self.request
+        launched = request.debugger == "delve" and not (request.corefile or request.remote or request.pid)
+        if launched and request.triage and not request.force_analyze:
+            return DEFAULT_HANG_TIMEOUT
+        return None
+
+    def _triage(self, watch: Any, hang_timeout: Optional[float]) -> Optional[Triage]:
+        """Check how the first run ended; None when nothing ran it to an outcome (core, attach, gdb, ...)."""
+        stop = self.state.run_stop
+        if stop is None and not self.state.hung:
+            return None
+        printed = "\n".join(line.text for line in self.program_output.lines())
+        if watch is not None and watch.output_tail:
+            printed = "\n".join(text for text in (printed, watch.output_tail) if text)
+        return triage_run(
+            self.state.outputs,
+            exited=stop is not None and stop.exited,
+            exit_code=stop.exit_code if stop is not None else None,
+            stop_reason=stop.reason if stop is not None else "paused",
+            program_output=printed,
+            hung=self.state.hung,
+            idle_seconds=watch.idle_seconds if watch is not None else 0.0,
+            hang_timeout=hang_timeout,
+            runtime_deadlock=watch is not None and watch.runtime_deadlock,
+        )
+
+    def _start_recording(self, path: Path) -> None:
+        path.parent.mkdir(parents=True, exist_ok=True)
+        self._recorder = Recorder(str(path))
+        self._recorder.start(
+            self.backend,
+            session_id=self.state.session_id,
+            goal=self.request.goal_type,
+            program=self.request.program or self.request.main_class,
+            invocation=self._recorded_invocation() if self.request.program else None,
+        )
+        self._log(f"Recording the session to {path}")
+
+    def _recorded_invocation(self) -> Dict[str, Any]:
+        """The invocation for the transcript header, its secrets redacted as the prompts' are."""
+        redactor = Redactor.from_config(self.session_config, known=self._secrets)
+        invocation = self.request.invocation.to_dict()
+        invocation["args"] = [redactor.redact(arg) for arg in invocation["args"]]
+        invocation["env"] = {name: redactor.redact(value, variable=name) for name, value in invocation["env"].items()}
+        return invocation
+
+    def _end_backend(self, aborted: bool = False) -> None:
+        """Detach from an attached process, or quit the debugger; ``aborted`` ends it even mid-command."""
+        if self._recorder is not None:
+            self._recorder.close()
+            self._recorder = None
+        backend = self.backend
+        if backend is None:
+            return
+        if aborted and hasattr(backend, "abort"):
+            backend.abort()
+        elif self.request.pid:
+            backend.detach()
+        elif hasattr(backend, "close"):
+            backend.close()
+
+    def interact(
+        self,
+        final_report: str = "",
+        read: Callable[[str], str] = input,
+        write: Callable[[str], None] = print,
+    ) -> None:
+        """Answer follow-up questions against the still-running debugger session."""
+        if self.backend is None:
+            raise RuntimeError("Debugger backend not initialized")
+        provider = self.request.provider
+        budget = providers.prompt_char_budget(provider) or DEFAULT_HISTORY_CHARS
+        chat = Interactive(
+            self.backend,
+            self._get_provider_fn(provider),
+            config=self.session_config,
+            max_history_chars=budget,
+            provider=provider,
+            chat=providers.create_tool_client(provider, self.session_config),
+        )
+        if self.state.facts:
+            chat.history.add(ROLE_DEBUGGER, "Observations so far:\n" + "\n".join(self.state.facts[-10:]))
+        if final_report:
+            chat.history.add(ROLE_ASSISTANT, final_report)
+        completed = False
+        try:
+            with self.context.activate():
+                chat.run(read, write)
+            completed = True
+        except KeyboardInterrupt:
+            self.context.cancel("interrupted")
+            raise
+        finally:
+            self._end_backend(aborted=not completed)
+            self.context.close()
+
     # ------------------------------------------------------------------
     def _create_backend(self):
         debugger = self.request.debugger
@@ -132,7 +467,7 @@ class DebugAgentRunner:
             from dbgcopilot.backends.gdb_subprocess import GdbSubprocessBackend
 
             backend = GdbSubprocessBackend()
-            backend.initialize_session()
+            start_session(backend)
         elif debugger == "lldb":
             backend = self._create_lldb_backend()
         elif debugger in {"rust-lldb", "lldb-rust"}:
@@ -141,28 +476,50 @@ class DebugAgentRunner:
             from dbgcopilot.backends.rust_gdb import RustGdbBackend
 
             backend = RustGdbBackend()
-            backend.initialize_session()
+            start_session(backend)
+        elif debugger == "delve" and self.request.remote:
+            from dbgcopilot.debugger import connect
+
+            backend = connect(self.request.remote, api_version=self.request.api_version, context=self.context)
+        elif debugger == "delve" and self.request.pid:
+            from dbgcopilot.debugger import attach
+
+            backend = attach(self.request.pid, self.request.program, context=self.context)
         elif debugger == "delve":
             if not self.request.program:
                 raise ValueError("Delve debugger requires a program path")
-            from dbgcopilot.backends.delve_subprocess import DelveSubprocessBackend
+            launch = {"args": self.request.invocation.args, "env": self._program_env()}
+            if self.request.corefile or self._watch_timeout():
+                from dbgcopilot.debugger.delve import DelveDebugger
 
-            backend = DelveSubprocessBackend(program=self.request.program)
-            backend.initialize_session()
+                backend = DelveDebugger(
+                    program=self.request.program, core=self.request.corefile, context=self.context, **launch
+                )
+            else:
+                from dbgcopilot.backends.delve_subprocess import DelveSubprocessBackend
+
+                backend = DelveSubprocessBackend(program=self.request.program, context=self.context, **launch)
+            start_session(backend)
         elif debugger == "radare2":
             if not self.request.program:
                 raise ValueError("radare2 debugger requires a program path")
             from dbgcopilot.backends.radare2_subprocess import Radare2SubprocessBackend
 
             backend = Radare2SubprocessBackend(program=self.request.program)
-            backend.initialize_session()
+            start_session(backend)
         elif debugger == "pdb":
             if not self.request.program:
                 raise ValueError("pdb debugger requires a Python script path")
-            from dbgcopilot.backends.python_pdb import PythonPdbBackend
+            from dbgcopilot.debugger.python import PythonDebugger
 
-            backend = PythonPdbBackend(program=self.request.program)
-            backend.initialize_session()
+            backend = PythonDebugger(
+                self.request.program,
+                python=self.request.python,
+                args=self.request.invocation.args,
+                env=self._program_env(),
+            )
+            start_session(backend)
+            self._log(f"Python interpreter: {backend.interpreter.path} ({backend.interpreter.source})")
         elif debugger == "jdb":
             from dbgcopilot.backends.java_jdb import JavaJdbBackend
 
@@ -171,7 +528,7 @@ class DebugAgentRunner:
                 classpath=self.request.classpath,
                 sourcepath=self.request.sourcepath,
             )
-            backend.initialize_session()
+            start_session(backend)
         else:
             raise ValueError(f"Unsupported debugger: {debugger}")
 
@@ -185,6 +542,11 @@ class DebugAgentRunner:
 
         return backend
 
+    def _program_env(self) -> Optional[Dict[str, str]]:
+        """The launched program's whole environment, or None to leave it ours."""
+        invocation = self.request.invocation
+        return invocation.environment() if invocation.env or not invocation.inherit_env else None
+
     def _create_lldb_backend(self):
         api_error: Optional[Exception] = None
         try:
@@ -256,6 +618,8 @@ class DebugAgentRunner:
                 commands.append(f"file {self.request.program}")
             if self.request.corefile:
                 commands.append(f"core-file {self.request.corefile}")
+            else:
+                commands += self.request.invocation.gdb_commands()
         elif debugger in {"lldb", "rust-lldb", "lldb-rust"}:
             if self.request.program and self.request.corefile:
                 commands.append(
@@ -265,9 +629,11 @@ class DebugAgentRunner:
                 commands.append(f"target create --core {self.request.corefile}")
             elif self.request.program:
                 commands.append(f"target create {self.request.program}")
+            if not self.request.corefile:
+                commands += self.request.invocation.lldb_commands()
         elif debugger == "pdb":
-            if self.request.program:
-                commands.append(f"file {self.request.program}")
+            self._run_python()
+            return
         elif debugger == "jdb":
             details: list[str] = []
             if self.request.classpath:
@@ -284,9 +650,86 @@ class DebugAgentRunner:
             return
 
         for cmd in commands:
-            out = self.backend.run_command(cmd)
+            with self._running(cmd):
+                out = self.backend.run_command(cmd)
             self._record_execution(cmd, out)
 
+    @contextmanager
+    def _running(self, cmd: str):
+        """Span of ``cmd``, with the program's output teed while it lets the program run."""
+        name = _command_span(cmd)
+        with tracing.span(name, debugger=self.request.debugger, command=cmd), self._capturing(cmd):
+            yield
+
+    def _capturing(self, cmd: str):
+        """Tee the program's output into the ring while ``cmd`` runs, if it lets the program run."""
+        if self._tee is None or not resumes_target(cmd):
+            return nullcontext()
+        return self._tee.capturing(cmd)
+
+    def _run_python(self) -> None:
+        """Run the script to its first stop; triage decides whether the LLM needs to see it."""
+        if not hasattr(self.backend, "continue_"):
+            return
+        with self._running("continue"):
+            event = self.backend.continue_()
+        self.state.run_stop = event
+        self.state.facts.append(event.describe())
+        self._record_execution("continue", f"{event.describe()}\n{event.raw}".strip())
+        if event.reason == "exception":
+            out = self.backend.run_command("threads")
+            self._record_execution("threads", out)
+
+    def watch_for_hang(self, timeout: float):
+        """Run the target until it hangs (no progress for ``timeout`` seconds), crashes or exits.
+
+        On a hang, or when the runtime reports all goroutines asleep, the
+        goroutine dump and deadlock findings become the last output, so the
+        auto loop starts from the diagnosis.
+        """
+        from dbgcopilot.analyze.grouping import condense_goroutine_output
+        from dbgcopilot.debugger.hang import watch_for_hang
+
+        if not hasattr(self.backend, "resume_async"):
+            raise ValueError("Hang detection requires the delve debugger")
+        self._log(f"Watching for a hang (timeout {timeout:g}s)")
+        with tracing.span(tracing.SPAN_WAIT, debugger=self.request.debugger, hang_timeout=timeout) as span:
+            with self._capturing("continue"):
+                result = watch_for_hang(self.backend, timeout)
+            span.set(hung=result.hung, stop=result.stop.reason if result.stop is not None else None)
+        self.state.hung = result.hung
+        self.state.run_stop = result.stop
+        summary = result.describe()
+        self.state.facts.append(summary.splitlines()[0])
+        if result.hung and result.dump is not None:
+            self._record_execution(
+                f"continue (paused after {result.idle_seconds:.0f}s idle)",
+                summary + "\n" + condense_goroutine_output(result.dump.raw),
+            )
+        elif result.runtime_deadlock and result.dump is not None:
+            self._record_execution(
+                "continue (runtime deadlock)", summary + "\n" + condense_goroutine_output(result.dump.raw)
+            )
+        elif result.stop is not None:
+            self._record_execution("continue", result.stop.raw or summary)
+        return result
+
+    def analysis_result(self, final_report: str) -> AnalysisResult:
+        """Structured result shared by the text and JSON renderers."""
+        result = build_result(
+            self.state.outputs,
+            report=final_report,
+            debugger=getattr(self.backend, "name", None) or self.request.debugger,
+            program=self.request.program or self.request.main_class or "",
+            hang=self.state.hung,
+        )
+        if self.skipped_analysis and self.state.triage is not None:
+            # The triage report is evidence of a clean run, not a finding.
+            result.summary = self.state.triage.describe()
+            result.severity = SEVERITY_NONE
+            result.diagnosis, result.confidence_level, result.confidence_reasons = "", "", []
+        return result
+
     # ------------------------------------------------------------------
     def _auto_loop(self) -> str:
         max_steps_value = self.prompt_config.get("max_steps", self.request.max_steps)
@@ -314,10 +757,14 @@ class DebugAgentRunner:
         language_instruction = self._language_instruction()
 
         for step in range(1, max_steps + 1):
-            prompt = self._build_prompt(system_preamble, rules_text, followup, language_instruction)
+            self.context.check()
+            with tracing.span(tracing.SPAN_PROMPT, step=step) as span:
+                prompt = self._build_prompt(system_preamble, rules_text, followup, language_instruction)
+                span.set(chars=len(prompt))
             answer = self._call_llm(prompt)
             answer_clean = answer.strip()
-            self._log(f"LLM step {step} response:\n{answer_clean}")
+            cached = " (cache hit)" if self._last_cache_hit else ""
+            self._log(f"LLM step {step} response{cached}:\n{answer_clean}")
             self.state.chatlog.append(f"Assistant: {answer_clean}")
 
             cmd = self._extract_cmd(answer_clean)
@@ -325,7 +772,8 @@ class DebugAgentRunner:
                 self._log(f"Executing command: {cmd}")
                 if self.backend is None:
                     raise RuntimeError("Debugger backend not initialized")
-                out = self.backend.run_command(cmd)
+                with self._running(cmd):
+                    out = self.backend.run_command(cmd)
                 self._record_execution(cmd, out)
                 continue
 
@@ -338,37 +786,140 @@ class DebugAgentRunner:
         self._log("Reached maximum iterations without final report")
         return self._fallback_report()
 
+    def _offline_analysis(self) -> str:
+        """Report from the analyzers alone, after dumping every stack unless a panic or dump is already there."""
+        last = self.state.last_output
+        exited = self.state.run_stop is not None and self.state.run_stop.exited
+        if self.backend is not None and not exited and not (looks_like_panic(last) or looks_like_goroutine_dump(last)):
+            cmd = getattr(self.backend, "GOROUTINES_COMMAND", None) or _STACKS_COMMANDS.get(self.request.debugger)
+            if cmd:
+                self._log(f"Executing command: {cmd}")
+                with self._running(cmd):
+                    out = self.backend.run_command(cmd)
+                self._record_execution(cmd, out)
+        self._log("Writing the report from the deterministic analyzers (--no-llm)")
+        return offline_report(self.state.outputs, hang=self.state.hung)
+
+    def _suggest_patch(self, result: AnalysisResult) -> Optional[Patch]:
+        """Ask for a diff of ``result``'s fix until one applies to the current sources; written with ``apply_patch``."""
+        sources = patch_sources(result)
+        if not sources:
+            self.patch_error = "No patch: the source files of the frames involved could not be read"
+            self._log(self.patch_error)
+            return None
+        redactor = Redactor.from_config(self.session_config, known=self._secrets)
+        attempts = max(self.request.patch_attempts, 1)
+        previous = error = ""
+        for attempt in range(1, attempts + 1):
+            try:
+                self.context.check()
+                answer = self._call_llm(redactor.redact(patch_prompt(result, sources, previous=previous, error=error)))
+            except (LLMError, DeadlineExceeded) as exc:
+                # The analysis stands without a patch; the report says why there is none.
+                self.patch_error = f"No patch: the LLM request for attempt {attempt} failed: {exc}"
+                self._log(self.patch_error)
+                return None
+            self._log(f"LLM patch attempt {attempt} response:\n{answer.strip()}")
+            try:
+                patch = check_patch(answer, sources)
+            except PatchError as exc:
+                previous, error = extract_diff(answer) or answer.strip(), str(exc)
+                self._log(f"Patch attempt {attempt} rejected: {exc}")
+                continue
+            break
+        else:
+            self.patch_error = f"No patch: no diff applied to the sources in {attempts} attempt(s) (last: {error})"
+            self._log(self.patch_error)
+            return None
+        self._log(f"Patch checked: {patch.describe()}")
+        self.patch = patch
+        result.patch = patch.diff
+        if self.request.apply_patch:
+            try:
+                self.patched_files = apply_patch(patch)
+            except (PatchError, OSError) as exc:
+                self.patch_error = f"The patch was not applied: {exc}"
+                self._log(self.patch_error)
+            else:
+                self._log("Applied the patch to " + ", ".join(self.patched_files))
+        return patch
+
     # ------------------------------------------------------------------
     def _build_prompt(self, system_preamble: str, rules_text: str, followup: str, language_instruction: str) -> str:
-        context_lines: list[str] = []
-        context_lines.append(f"Goal category: {self.request.goal_type}")
-        if self.request.goal_text:
-            context_lines.append(f"Goal notes: {self.request.goal_text}")
-        if self.request.resume_context:
-            context_lines.append("Loaded prior report:")
-            context_lines.append(self.request.resume_context.strip())
-        if self.state.facts:
-            context_lines.append("Recent observations:")
-            context_lines.extend(self.state.facts[-10:])
-        if self.state.attempts:
-            recent_cmds = [f"- {a.cmd}: {a.output_snippet[:160]}" for a in self.state.attempts[-5:]]
-            context_lines.append("Recent commands:")
-            context_lines.extend(recent_cmds)
-        if self.state.last_output:
-            context_lines.append("Latest debugger output:")
-            context_lines.append(head_tail_truncate(self.state.last_output, 1200))
-
-        context_block = "\n".join(context_lines)
-        prompt_parts = [system_preamble]
-        if rules_text:
-            prompt_parts.append("Rules:\n" + rules_text)
-        if language_instruction:
-            prompt_parts.append(language_instruction)
-        if context_block:
-            prompt_parts.append("Context:\n" + context_block)
-        prompt_parts.append("User: " + followup)
-        prompt_parts.append("Assistant:")
-        return "\n\n".join(prompt_parts)
+        redactor = Redactor.from_config(self.session_config, known=self._secrets)
+        for entry in self.state.chatlog:
+            # Learn values printed by "(executed) print <secret>" so facts and snippets hide them too.
+            redactor.redact_entry(entry)
+        last_cmd = self.state.attempts[-1].cmd if self.state.attempts else ""
+        program_output = self.program_output.render(self.output_lines) if self.output_lines > 0 else ""
+        if program_output:
+            evicted = self.program_output.dropped
+            if evicted:
+                program_output = f"({evicted} older line(s) dropped)\n" + program_output
+            if self.state.last_output_at:
+                stamp = OutputLine(self.state.last_output_at, "").render().rstrip()
+                program_output += f"\n{stamp} (debugger output captured)"
+        last_output = self.state.last_output
+        issue = issue_for_output(last_output, hang=self.state.hung)
+        findings, source_context = analysis_sections(last_output, resolve_source_context_lines(self.session_config))
+        dbg = getattr(self.backend, "name", None) or self.request.debugger
+        pinned = pinned_section(self.session_config, output=last_output, debugger=self.backend)
+
+        def compose(outputs: list[str], facts: list[str], note: str) -> str:
+            stack, findings, source_context, program_output = outputs
+            context_lines: list[str] = []
+            context_lines.append(f"Goal category: {self.request.goal_type}")
+            if self.request.goal_text:
+                context_lines.append(f"Goal notes: {self.request.goal_text}")
+            if self.request.resume_context:
+                context_lines.append("Loaded prior report:")
+                context_lines.append(self.request.resume_context.strip())
+            if facts:
+                context_lines.append("Recent observations:")
+                context_lines.extend(facts)
+            if self.state.attempts:
+                recent_cmds = [f"- {a.cmd}: {a.output_snippet[:160]}" for a in self.state.attempts[-5:]]
+                context_lines.append("Recent commands:")
+                context_lines.extend(recent_cmds)
+            stack = redactor.redact(stack, variable=command_variable(last_cmd))
+            analysis = self.analysis_templates.render(
+                issue,
+                debugger=dbg,
+                command=last_cmd,
+                stack=stack,
+                findings=findings,
+                source_context=source_context,
+                program_output=program_output,
+            )
+            if analysis:
+                context_lines.append(analysis)
+            if pinned:
+                context_lines.append(pinned)
+            if note:
+                context_lines.append(f"({note})")
+
+            context_block = redactor.redact("\n".join(context_lines))
+            prompt_parts = [system_preamble]
+            if rules_text:
+                prompt_parts.append("Rules:\n" + rules_text)
+            if language_instruction:
+                prompt_parts.append(language_instruction)
+            if context_block:
+                prompt_parts.append("Context:\n" + context_block)
+            prompt_parts.append("User: " + followup)
+            prompt_parts.append("Assistant:")
+            return "\n\n".join(prompt_parts)
+
+        # Every section that can grow goes through the budget, which cuts the longest first; pins stay whole.
+        outputs = [last_output or "", findings, source_context, program_output]
+        facts = self.state.facts[-10:]
+        budget = Budget.for_provider(self.request.provider, self.request.model)
+        if budget is None:
+            return compose(outputs, facts, "")
+        prompt = budget.fit(compose, outputs, facts)
+        if budget.trimmed:
+            self._log(budget.note())
+        return prompt
 
     def _language_instruction(self) -> str:
         lang = (self.request.language or "en").lower()
@@ -382,11 +933,55 @@ class DebugAgentRunner:
     def _call_llm(self, prompt: str) -> str:
         provider = self.request.provider
         ask_fn = self._get_provider_fn(provider)
-        answer = ask_fn(prompt)
-        usage = getattr(ask_fn, "last_usage", None)
-        self._record_usage_stats(provider, usage)
+        with tracing.span(tracing.SPAN_LLM, provider=provider, model=self.request.model) as span:
+            try:
+                if self.request.token_sink is not None:
+                    answer, usage = self._stream_llm(provider, prompt, ask_fn, self.request.token_sink)
+                else:
+                    answer = ask_fn(prompt)
+                    usage = getattr(ask_fn, "last_usage", None)
+            except LLMError:
+                # A request the deadline cut short reports the deadline.
+                self.context.check()
+                raise
+            self._last_cache_hit = bool(getattr(ask_fn, "last_cache_hit", False))
+            if self._recorder is not None:
+                self._recorder.record_llm(prompt, answer, provider=provider, cache_hit=self._last_cache_hit)
+            recorded = len(self.usage_entries)
+            self._record_usage_stats(provider, usage)
+            entry = self.usage_entries[-1] if len(self.usage_entries) > recorded else {}
+            span.set(
+                cache_hit=self._last_cache_hit,
+                model=entry.get("model"),
+                prompt_tokens=entry.get("prompt_tokens"),
+                completion_tokens=entry.get("completion_tokens"),
+            )
         return answer
 
+    def _stream_llm(
+        self, provider: str, prompt: str, ask_fn: Callable[[str], str], sink: Callable[[str], None]
+    ) -> tuple[str, Dict[str, Any]]:
+        """``prompt``'s answer and its token usage, shown chunk by chunk on ``sink``; a cached answer is shown whole.
+
+        The stream is retried and rate-limited (``--llm-rate-limit``) like ``ask_fn`` until its first chunk.
+        """
+        cached = ask_fn if isinstance(ask_fn, CachedClient) else None
+        hit = cached.lookup(prompt) if cached is not None else None
+        if hit is not None:
+            sink(hit if hit.endswith("\n") else hit + "\n")
+            return hit, {}
+        stream = providers.create_stream(provider, prompt, self.session_config)
+        try:
+            for chunk in stream:
+                sink(chunk)
+        finally:
+            stream.cancel()
+            if stream.text and not stream.text.endswith("\n"):
+                sink("\n")
+        if cached is not None:
+            cached.store(prompt, stream.text)
+        return stream.text, stream.last_usage
+
     # ------------------------------------------------------------------
     def _extract_cmd(self, text: str) -> Optional[str]:
         match = re.search(r"<cmd>\s*([\s\S]*?)\s*</cmd>", text, re.IGNORECASE)
@@ -398,19 +993,9 @@ class DebugAgentRunner:
         if provider in self._provider_cache:
             return self._provider_cache[provider]
 
-        if provider == "openrouter":
-            from dbgcopilot.llm import openrouter as _or
-
-            ask_fn = _or.create_provider(session_config=self.session_config)
-        elif provider in {"openai-http", "ollama", "deepseek", "qwen", "kimi", "zhipuglm", "llama-cpp", "modelscope"}:
-            from dbgcopilot.llm import openai_compat as _oa
-
-            ask_fn = _oa.create_provider(session_config=self.session_config, name=provider)
-        else:
-            prov = providers.get_provider(provider)
-            if prov is None:
-                raise RuntimeError(f"Unknown provider: {provider}")
-            ask_fn = prov.ask
+        if providers.get_provider(provider) is None:
+            raise RuntimeError(f"Unknown provider: {provider}")
+        ask_fn = providers.create_cached_client(provider, self.session_config)
 
         self._provider_cache[provider] = ask_fn
         return ask_fn
@@ -464,6 +1049,8 @@ class DebugAgentRunner:
         snippet = clean_output[:160]
         self.state.attempts.append(Attempt(cmd=cmd, output_snippet=snippet))
         self.state.last_output = clean_output
+        self.state.last_output_at = time.time()
+        self.state.outputs.append(clean_output)
         if clean_output:
             first_line = clean_output.splitlines()[0]
         else:
@@ -493,6 +1080,16 @@ class DebugAgentRunner:
             "## Final Report",
             final_report.strip(),
         ]
+        if self.result is not None:
+            content_lines += ["", "## Structured Analysis", "```", render_text(self.result), "```"]
+        if self.patch is not None:
+            status = "Applied to " + ", ".join(self.patched_files) if self.patched_files else "Not applied"
+            content_lines += ["", "## Suggested Patch", f"{status}.", "", "```diff", self.patch.diff.rstrip("\n")]
+            content_lines.append("```")
+            self.patch_path = self.request.report_path.with_suffix(".patch")
+            self.patch_path.write_text(self.patch.diff, encoding="utf-8")
+        elif self.patch_error:
+            content_lines += ["", "## Suggested Patch", self.patch_error]
         backend_name = getattr(self.backend, "name", None) or self.request.debugger
         session_section = [
             "",
@@ -503,6 +1100,11 @@ class DebugAgentRunner:
             f"Language: {self.request.language}",
             f"Max steps: {self.request.max_steps}",
         ]
+        if self.request.program and not self.request.invocation.default:
+            redactor = Redactor.from_config(self.session_config, known=self._secrets)
+            session_section.append(f"Program invocation: {redactor.redact(self.invocation_line())}")
+        if self.request.record_path is not None:
+            session_section.append(f"Session transcript: {self.request.record_path}")
         if self.request.log_enabled and self.request.log_path:
             session_section.append(f"Session log: {self.request.log_path}")
         content_lines += session_section
@@ -565,4 +1167,31 @@ class DebugAgentRunner:
             self.logger.info(message)
 
 
+# Every thread's stack, for debuggers without a ``GOROUTINES_COMMAND``.
+_STACKS_COMMANDS = {
+    "gdb": "thread apply all bt",
+    "rust-gdb": "thread apply all bt",
+    "lldb": "thread backtrace all",
+    "rust-lldb": "thread backtrace all",
+    "lldb-rust": "thread backtrace all",
+    "pdb": "where",
+}
+
+
+def _command_span(cmd: str) -> str:
+    """Span name for one debugger command: waiting for the program, dumping goroutines, or anything else."""
+    if resumes_target(cmd):
+        return tracing.SPAN_WAIT
+    if cmd.split()[:1] in (["goroutines"], ["grs"]):
+        return tracing.SPAN_GOROUTINES
+    return tracing.SPAN_COMMAND
+
+
+def _config_int(config: Dict[str, str], key: str, default: int) -> int:
+    try:
+        return max(int(config.get(key, default)), 0)
+    except (TypeError, ValueError):
+        return default
+
+
 __all__ = ["AgentRequest", "AgentState", "DebugAgentRunner"]
@@ -0,0 +1,114 @@
+"""Deterministic analyzers that run on debugger output before the LLM sees it."""
+from __future__ import annotations
+
+from typing import List
+
+from dbgcopilot.utils.source import DEFAULT_CONTEXT_RADIUS
+
+from .channels import (
+    ChannelEdges,
+    ChannelReport,
+    ChannelWait,
+    channel_wait_graph,
+    detect_channel_deadlock,
+    find_channel_waits,
+    format_channel_report,
+    runtime_deadlock_fired,
+)
+from .deadlock import (
+    DeadlockCycle,
+    HeldLock,
+    LockRef,
+    LockWait,
+    LockOrder,
+    LockStep,
+    detect_deadlock,
+    find_lock_waits,
+    format_deadlock_report,
+    format_held_locks,
+    format_lock_orders,
+    held_locks,
+    lock_order_fix,
+)
+from .diff import DumpDiff, diff_dumps, format_dump_diff
+from .frames import extract_stack, format_stacktrace, stack_context_for_output
+from .goroutines import (
+    Frame,
+    Goroutine,
+    GoroutineDump,
+    looks_like_goroutine_dump,
+    parse_goroutine_dump,
+)
+from .grouping import GoroutineGroup, condense_goroutine_output, filter_goroutines, group_goroutines
+from .leak import LeakSuspect, detect_leak, format_leak_report
+from .panic import PanicReport, classify_panic, format_panic_report, looks_like_panic
+
+
+def report_sections(text: str) -> List[str]:
+    """Panic, lock-cycle, held-lock and channel reports that apply to raw debugger output, in that order."""
+    sections: List[str] = []
+    if looks_like_panic(text):
+        sections.append(format_panic_report(classify_panic(text)))
+    if looks_like_goroutine_dump(text):
+        dump = parse_goroutine_dump(text)
+        cycles = detect_deadlock(dump)
+        sections.append(format_deadlock_report(cycles))
+        sections.append(format_held_locks(dump))
+        channels = detect_channel_deadlock(dump, runtime_deadlock=runtime_deadlock_fired(text))
+        sections.append(format_channel_report(channels, lock_cycles=len(cycles)))
+    return [s for s in sections if s]
+
+
+def findings_for_output(text: str, *, source_radius: int = DEFAULT_CONTEXT_RADIUS) -> str:
+    """Return analyzer findings for raw debugger output, or "" when nothing applies."""
+    sections = [stack_context_for_output(text, source_radius)] + report_sections(text)
+    return "\n\n".join(s for s in sections if s)
+
+
+__all__ = [
+    "ChannelEdges",
+    "ChannelReport",
+    "ChannelWait",
+    "DeadlockCycle",
+    "DumpDiff",
+    "Frame",
+    "Goroutine",
+    "GoroutineDump",
+    "GoroutineGroup",
+    "HeldLock",
+    "LeakSuspect",
+    "LockOrder",
+    "LockRef",
+    "LockStep",
+    "LockWait",
+    "PanicReport",
+    "channel_wait_graph",
+    "classify_panic",
+    "condense_goroutine_output",
+    "detect_channel_deadlock",
+    "detect_deadlock",
+    "detect_leak",
+    "diff_dumps",
+    "extract_stack",
+    "filter_goroutines",
+    "find_channel_waits",
+    "find_lock_waits",
+    "findings_for_output",
+    "format_channel_report",
+    "format_deadlock_report",
+    "format_dump_diff",
+    "format_held_locks",
+    "format_leak_report",
+    "format_lock_orders",
+    "format_panic_report",
+    "format_stacktrace",
+    "group_goroutines",
+    "held_locks",
+    "lock_order_fix",
+    "looks_like_goroutine_dump",
+    "looks_like_panic",
+    "parse_goroutine_dump",
+    "report_sections",
+    "runtime_deadlock_fired",
+    "stack_context_for_output",
+]
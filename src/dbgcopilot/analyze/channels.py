@@ -0,0 +1,407 @@
+"""Channel deadlock detection from goroutine dumps.
+
+Goroutines blocked in ``chan send``, ``chan receive`` or ``select`` form a
+channel wait graph: each channel lists the goroutines blocked sending on it
+and those blocked receiving from it. An unbuffered channel with blocked
+senders and no blocked receiver (or the reverse) is starved; if nothing else
+is running, the Go runtime itself gives up with ``fatal error: all
+goroutines are asleep - deadlock!``, and every blocked operation is
+permanent. Receives from a nil channel and ``select {}`` never return at
+all.
+
+Which channel a goroutine waits on comes from the ``runtime.chansend1`` /
+``chanrecv1`` argument when runtime frames are in the dump, else from the
+source expression at the blocked user frame (every ``case`` of a
+``select``), so channels are told apart the same way ``deadlock.py`` tells
+locks apart.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional, Set
+import re
+
+from dbgcopilot.utils.source import SourceCache
+
+from .goroutines import (
+    CHAN_WAIT_KINDS,
+    LOCK_WAIT_KINDS,
+    WAIT_CHAN_RECEIVE,
+    WAIT_CHAN_SEND,
+    WAIT_RUNNING,
+    WAIT_SELECT,
+    WAIT_SELECT_NO_CASES,
+    Frame,
+    Goroutine,
+    GoroutineDump,
+)
+
+SEND = "send"
+RECEIVE = "receive"
+
+RUNTIME_DEADLOCK_MESSAGE = "all goroutines are asleep - deadlock!"
+_RUNTIME_DEADLOCK_RE = re.compile(r"all goroutines are asleep\s*-\s*deadlock")
+
+_EXPR = r"[A-Za-z_]\w*(?:\.\w+|\[[^\]]*\])*"
+_SEND_RE = re.compile(rf"(?P<ch>{_EXPR})\s*<-")
+_RECV_RE = re.compile(rf"<-\s*(?P<ch>{_EXPR})")
+_RANGE_RE = re.compile(rf"\brange\s+(?P<ch>{_EXPR})")
+_CASE_RE = re.compile(r"^\s*case\s+(?P<op>.+?):\s*(?://.*)?$")
+_ADDR_RE = re.compile(r"0x[0-9a-fA-F]+")
+# Runtime entry points whose first argument is the channel (an *hchan).
+_SEND_FRAMES = {"runtime.chansend1", "runtime.chansend"}
+_RECV_FRAMES = {"runtime.chanrecv1", "runtime.chanrecv2", "runtime.chanrecv"}
+# The case clauses of a select are read at most this many lines below it.
+_MAX_SELECT_LINES = 200
+_MAX_OTHER_BLOCKED = 5
+
+
+def runtime_deadlock_fired(text: str) -> bool:
+    """True when the Go runtime's own deadlock detector ended the program."""
+    return bool(_RUNTIME_DEADLOCK_RE.search(text or ""))
+
+
+@dataclass
+class ChannelRef:
+    """Identity of a channel: its address when known, else its source expression."""
+
+    address: str = ""
+    name: str = ""
+
+    @property
+    def key(self) -> str:
+        return self.address or self.name
+
+    @property
+    def label(self) -> str:
+        if self.name and self.address:
+            return f"{self.name} ({self.address})"
+        return self.name or self.address or "(unknown channel)"
+
+
+@dataclass
+class ChannelOp:
+    channel: ChannelRef
+    direction: str
+
+
+def _new_op_list() -> List[ChannelOp]:
+    return []
+
+
+@dataclass
+class ChannelWait:
+    """A goroutine blocked on one channel operation, or on every case of a select."""
+
+    goroutine_id: int
+    kind: str
+    waiting_at: Optional[Frame]
+    ops: List[ChannelOp] = field(default_factory=_new_op_list)
+    # Blocked on a nil channel: the operation can never complete.
+    nil_channel: bool = False
+
+    @property
+    def forever(self) -> bool:
+        return self.nil_channel or self.kind == WAIT_SELECT_NO_CASES
+
+    @property
+    def waiting_location(self) -> str:
+        if self.waiting_at is None:
+            return "(unknown)"
+        return f"{self.waiting_at.function} at {self.waiting_at.location}"
+
+    def describe_forever(self) -> str:
+        if self.kind == WAIT_SELECT_NO_CASES:
+            return f"goroutine {self.goroutine_id} is in select {{}} (no cases) in {self.waiting_location}"
+        op = self.ops[0] if self.ops else None
+        verb = "sends on" if op is not None and op.direction == SEND else "receives from"
+        name = f" {op.channel.label}" if op is not None and op.channel.name else ""
+        return f"goroutine {self.goroutine_id} {verb} a nil channel{name} in {self.waiting_location}"
+
+
+def _new_int_list() -> List[int]:
+    return []
+
+
+@dataclass
+class ChannelEdges:
+    """Goroutines blocked on one channel, by direction."""
+
+    channel: ChannelRef
+    senders: List[int] = field(default_factory=_new_int_list)
+    receivers: List[int] = field(default_factory=_new_int_list)
+
+    @property
+    def starved(self) -> bool:
+        return bool(self.senders) != bool(self.receivers)
+
+
+def _new_wait_list() -> List[ChannelWait]:
+    return []
+
+
+def _new_edges_list() -> List[ChannelEdges]:
+    return []
+
+
+def _new_goroutine_list() -> List[Goroutine]:
+    return []
+
+
+@dataclass
+class ChannelReport:
+    waits: List[ChannelWait] = field(default_factory=_new_wait_list)
+    # Channels with blocked goroutines on one side only.
+    starved: List[ChannelEdges] = field(default_factory=_new_edges_list)
+    # Blocked neither on a channel nor on a mutex (WaitGroup, Cond, ...), often waiting on the above.
+    other_blocked: List[Goroutine] = field(default_factory=_new_goroutine_list)
+    running: int = 0
+    runtime_deadlock: bool = False
+
+    @property
+    def forever(self) -> List[ChannelWait]:
+        return [w for w in self.waits if w.forever]
+
+    @property
+    def found(self) -> bool:
+        # Once the runtime gave up, every channel wait is permanent even when no source names the channel.
+        return bool(self.starved or self.forever or (self.runtime_deadlock and self.waits))
+
+    def wait(self, goroutine_id: int) -> Optional[ChannelWait]:
+        return next((w for w in self.waits if w.goroutine_id == goroutine_id), None)
+
+
+def _strip_comment(text: str) -> str:
+    idx = text.find("//")
+    return text if idx < 0 else text[:idx]
+
+
+def _channel_kind(goroutine: Goroutine) -> str:
+    kind = goroutine.wait_kind
+    if kind in CHAN_WAIT_KINDS:
+        return kind
+    # Delve dumps without a wait reason: infer it from the runtime frames.
+    for frame in goroutine.frames:
+        fn = frame.function
+        if fn in _SEND_FRAMES:
+            return WAIT_CHAN_SEND
+        if fn in _RECV_FRAMES:
+            return WAIT_CHAN_RECEIVE
+        if fn == "runtime.selectgo":
+            return WAIT_SELECT
+        if fn == "runtime.block":
+            return WAIT_SELECT_NO_CASES
+    return ""
+
+
+def _channel_address(goroutine: Goroutine) -> str:
+    for frame in goroutine.frames:
+        if frame.function in _SEND_FRAMES or frame.function in _RECV_FRAMES:
+            m = _ADDR_RE.search(frame.args or "")
+            if m:
+                return m.group(0)
+    return ""
+
+
+def _op_in(text: str, direction: str) -> str:
+    code = _strip_comment(text)
+    if direction == SEND:
+        m = _SEND_RE.search(code)
+    else:
+        m = _RECV_RE.search(code) or _RANGE_RE.search(code)
+    return m.group("ch") if m else ""
+
+
+def _select_ops(frame: Frame, sources: SourceCache) -> List[ChannelOp]:
+    """The channel operations of every ``case`` in the select statement at ``frame``."""
+    lines = sources.lines(frame.file)
+    if lines is None or frame.line < 1:
+        return []
+    ops: List[ChannelOp] = []
+    depth = 0
+    for lineno in range(frame.line, min(len(lines), frame.line + _MAX_SELECT_LINES) + 1):
+        code = _strip_comment(lines[lineno - 1])
+        if depth == 1:
+            m = _CASE_RE.match(code)
+            if m:
+                clause = m.group("op")
+                send = _SEND_RE.match(clause.strip())
+                if send:
+                    ops.append(ChannelOp(ChannelRef(name=send.group("ch")), SEND))
+                else:
+                    name = _op_in(clause, RECEIVE)
+                    if name:
+                        ops.append(ChannelOp(ChannelRef(name=name), RECEIVE))
+        depth += code.count("{") - code.count("}")
+        if depth <= 0 and lineno > frame.line:
+            break
+    return ops
+
+
+def find_channel_waits(dump: GoroutineDump, sources: Optional[SourceCache] = None) -> List[ChannelWait]:
+    """Every goroutine blocked on a channel operation, with the channel(s) involved."""
+    sources = sources or SourceCache()
+    waits: List[ChannelWait] = []
+    for goroutine in dump.goroutines:
+        kind = _channel_kind(goroutine)
+        if not kind:
+            continue
+        user_frames = goroutine.user_frames()
+        waiting_at = user_frames[0] if user_frames else None
+        wait = ChannelWait(
+            goroutine_id=goroutine.id,
+            kind=kind,
+            waiting_at=waiting_at,
+            nil_channel="nil chan" in goroutine.state,
+        )
+        if kind == WAIT_SELECT and waiting_at is not None:
+            wait.ops = _select_ops(waiting_at, sources)
+        elif kind in {WAIT_CHAN_SEND, WAIT_CHAN_RECEIVE}:
+            direction = SEND if kind == WAIT_CHAN_SEND else RECEIVE
+            text = sources.line(waiting_at.file, waiting_at.line) if waiting_at is not None else None
+            name = _op_in(text, direction) if text else ""
+            wait.ops = [ChannelOp(ChannelRef(address=_channel_address(goroutine), name=name), direction)]
+        waits.append(wait)
+    _resolve_addresses(waits)
+    return waits
+
+
+def _resolve_addresses(waits: List[ChannelWait]) -> None:
+    """Give source-only channels the address observed for the same expression elsewhere."""
+    by_name: Dict[str, Set[str]] = {}
+    for w in waits:
+        for op in w.ops:
+            if op.channel.name and op.channel.address:
+                by_name.setdefault(op.channel.name, set()).add(op.channel.address)
+    for w in waits:
+        for op in w.ops:
+            if op.channel.name and not op.channel.address:
+                addrs = by_name.get(op.channel.name, set())
+                if len(addrs) == 1:
+                    op.channel.address = next(iter(addrs))
+
+
+def channel_wait_graph(waits: List[ChannelWait]) -> List[ChannelEdges]:
+    """Blocked senders and receivers per channel, in order of first appearance; nil channels excluded."""
+    edges: Dict[str, ChannelEdges] = {}
+    for w in waits:
+        if w.nil_channel:
+            continue
+        for op in w.ops:
+            if not op.channel.key:
+                continue
+            entry = edges.setdefault(op.channel.key, ChannelEdges(channel=op.channel))
+            side = entry.senders if op.direction == SEND else entry.receivers
+            if w.goroutine_id not in side:
+                side.append(w.goroutine_id)
+    return list(edges.values())
+
+
+def detect_channel_deadlock(
+    dump: GoroutineDump, sources: Optional[SourceCache] = None, *, runtime_deadlock: Optional[bool] = None
+) -> ChannelReport:
+    """Channel starvation and never-returning channel operations in ``dump``.
+
+    ``runtime_deadlock`` defaults to whether the dump carries the runtime's
+    "all goroutines are asleep" banner.
+    """
+    if runtime_deadlock is None:
+        runtime_deadlock = runtime_deadlock_fired(dump.header) or runtime_deadlock_fired(dump.raw)
+    waits = find_channel_waits(dump, sources)
+    report = ChannelReport(waits=waits, runtime_deadlock=runtime_deadlock)
+    report.starved = [e for e in channel_wait_graph(waits) if e.starved]
+    on_channel = {w.goroutine_id for w in waits}
+    for g in dump.goroutines:
+        kind = g.wait_kind
+        if kind == WAIT_RUNNING:
+            report.running += 1
+        elif g.id not in on_channel and kind not in LOCK_WAIT_KINDS:
+            report.other_blocked.append(g)
+    return report
+
+
+def _how(wait: Optional[ChannelWait], channel: ChannelRef, goroutine_id: int) -> str:
+    if wait is None:
+        return f"goroutine {goroutine_id}"
+    text = f"goroutine {goroutine_id} ({wait.waiting_location}"
+    others = [op.channel.label for op in wait.ops if op.channel.key != channel.key]
+    if wait.kind == WAIT_SELECT:
+        text += ", in a select" + (f" also waiting on {', '.join(others)}" if others else "")
+    return text + ")"
+
+
+def _describe_starved(edges: ChannelEdges, report: ChannelReport) -> str:
+    if edges.senders:
+        who = "; ".join(_how(report.wait(gid), edges.channel, gid) for gid in edges.senders)
+        return f"{edges.channel.label}: blocked sending: {who}; no goroutine is receiving from it."
+    who = "; ".join(_how(report.wait(gid), edges.channel, gid) for gid in edges.receivers)
+    return f"{edges.channel.label}: blocked receiving: {who}; no goroutine is sending on it or closing it."
+
+
+def format_channel_report(report: ChannelReport, *, lock_cycles: int = 0) -> str:
+    """Explain channel starvation, kept apart from mutex cycles (``lock_cycles`` found by ``deadlock.py``)."""
+    if not (report.found or report.runtime_deadlock):
+        return ""
+    lines: List[str] = []
+    # The runtime fired but only the mutex cycles explain it: nothing more to add about channels.
+    locks_only = bool(lock_cycles) and not (report.starved or report.forever)
+    if report.runtime_deadlock:
+        lines.append(
+            f'The Go runtime\'s deadlock detector fired ("{RUNTIME_DEADLOCK_MESSAGE}"): no goroutine can '
+            "make progress, so every blocked operation below is permanent."
+        )
+        if locks_only:
+            lines.append("Cause: the mutex lock cycle(s) above, not channel starvation.")
+        elif lock_cycles:
+            lines.append("Cause: the mutex lock cycle(s) above together with the channel starvation below.")
+        elif not report.waits:
+            lines.append("No lock cycle or starved channel was identified; see the blocked goroutines below.")
+    if report.starved:
+        lines.append(
+            f"Channel starvation (not a mutex cycle): {len(report.starved)} channel(s) with goroutines "
+            "blocked on one side only:"
+        )
+        for idx, edges in enumerate(report.starved, start=1):
+            lines.append(f"{idx}. {_describe_starved(edges, report)}")
+    forever = report.forever
+    if forever:
+        lines.append("Blocked forever:")
+        lines.extend(f"- {w.describe_forever()}" for w in forever)
+    # Without source or runtime frames no channel is named, so nothing is starved; the waits still explain it.
+    unnamed = [w for w in report.waits if not w.forever] if report.runtime_deadlock and not report.starved else []
+    if unnamed and not locks_only:
+        lines.append("Blocked on channels:")
+        lines.extend(f"- goroutine {w.goroutine_id} [{w.kind}] in {w.waiting_location}" for w in unnamed)
+    if report.other_blocked and not locks_only:
+        lines.append("Also blocked, not on a channel or mutex:")
+        for g in report.other_blocked[:_MAX_OTHER_BLOCKED]:
+            top = g.top_user_frame()
+            where = f" in {top.function} at {top.location}" if top is not None else ""
+            lines.append(f"- goroutine {g.id} [{g.state or 'unknown'}]{where}")
+        if len(report.other_blocked) > _MAX_OTHER_BLOCKED:
+            lines.append(f"- ... {len(report.other_blocked) - _MAX_OTHER_BLOCKED} more")
+    if report.starved and not report.runtime_deadlock:
+        if report.running:
+            lines.append(
+                f"(A snapshot: {report.running} goroutine(s) still running or runnable could yet unblock these.)"
+            )
+        else:
+            lines.append("(A snapshot: a timer, I/O or a signal handler could still unblock these.)")
+    return "\n".join(lines)
+
+
+__all__ = [
+    "RECEIVE",
+    "RUNTIME_DEADLOCK_MESSAGE",
+    "SEND",
+    "ChannelEdges",
+    "ChannelOp",
+    "ChannelRef",
+    "ChannelReport",
+    "ChannelWait",
+    "channel_wait_graph",
+    "detect_channel_deadlock",
+    "find_channel_waits",
+    "format_channel_report",
+    "runtime_deadlock_fired",
+]
@@ -0,0 +1,434 @@
+"""Mutex deadlock detection from goroutine dumps.
+
+Builds a lock wait graph (goroutine -> goroutine holding the lock it waits
+for) and reports every cycle. Which lock a goroutine waits on comes from the
+blocked frame (``lockSlow`` argument address plus the source expression at
+the user frame); which locks it holds is reconstructed from source by
+walking each user frame's function body up to the call site and tracking
+``Lock``/``Unlock`` pairs, so locks taken several frames up are included.
+
+For a cycle between goroutines, ``lock_orders`` lists the locks each one
+took, in order, ending with the one it is blocked on: the hang example's
+workerOne takes lockA then lockB while workerTwo takes lockB then lockA.
+``format_lock_orders`` puts those orderings side by side with file:line and
+``lock_order_fix`` proposes one global order and names the functions to
+change.
+
+``held_locks`` gives the same reconstruction for any goroutine, blocked on
+a lock or not (``Goroutine.held_locks``), ahead of the locks a lock monitor
+observed it take (``debugger.locks.LockMonitor``), which carry exact
+addresses and so key the wait graph even where source is missing.
+``format_held_locks`` lists them per goroutine for the prompt.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from pathlib import PurePath
+from typing import Dict, List, Optional, Set, Tuple
+import re
+
+from dbgcopilot.utils.source import SourceCache
+
+from .goroutines import (
+    Frame,
+    Goroutine,
+    GoroutineDump,
+    LOCK_WAIT_KINDS,
+    WAIT_RWMUTEX_READ,
+)
+
+
+_LOCK_CALL_RE = re.compile(r"([A-Za-z_][\w.\[\]()*&]*?)\.(Lock|RLock|Unlock|RUnlock)\(\)")
+_FUNC_START_RE = re.compile(r"^func\b")
+_ADDR_RE = re.compile(r"0x[0-9a-fA-F]+")
+
+
+@dataclass
+class LockRef:
+    """Identity of a lock: its address when known, else its source expression."""
+
+    address: str = ""
+    name: str = ""
+
+    @property
+    def key(self) -> str:
+        return self.address or self.name
+
+    @property
+    def label(self) -> str:
+        if self.name and self.address:
+            return f"{self.name} ({self.address})"
+        return self.name or self.address or "(unknown lock)"
+
+
+@dataclass
+class HeldLock:
+    lock: LockRef
+    acquired_at: str
+    function: str = ""
+    read: bool = False
+
+
+def _new_held_list() -> List[HeldLock]:
+    return []
+
+
+@dataclass
+class LockWait:
+    """A goroutine blocked acquiring ``lock`` while holding ``held``."""
+
+    goroutine_id: int
+    lock: LockRef
+    waiting_at: Optional[Frame]
+    read: bool = False
+    held: List[HeldLock] = field(default_factory=_new_held_list)
+
+    @property
+    def waiting_location(self) -> str:
+        return self.waiting_at.location if self.waiting_at else "(unknown)"
+
+    def holds(self, key: str) -> Optional[HeldLock]:
+        for held in self.held:
+            if held.lock.key == key:
+                return held
+        return None
+
+
+@dataclass
+class LockStep:
+    lock: LockRef
+    # "file:line" of the Lock call.
+    location: str
+    # The lock the goroutine is blocked acquiring (always the last step).
+    waiting: bool = False
+
+    @property
+    def short_location(self) -> str:
+        path, sep, line = self.location.rpartition(":")
+        return f"{PurePath(path).name}:{line}" if sep and line.isdigit() else self.location
+
+
+def _new_step_list() -> List[LockStep]:
+    return []
+
+
+@dataclass
+class LockOrder:
+    """The locks one goroutine of a cycle acquired, oldest first, ending with the one it waits for."""
+
+    goroutine_id: int
+    function: str
+    steps: List[LockStep] = field(default_factory=_new_step_list)
+
+
+@dataclass
+class DeadlockCycle:
+    """Goroutines in wait order: ``waits[i]`` wants a lock held by ``waits[i+1]``."""
+
+    waits: List[LockWait]
+
+    @property
+    def goroutine_ids(self) -> List[int]:
+        return [w.goroutine_id for w in self.waits]
+
+    @property
+    def lock_addresses(self) -> List[str]:
+        return [w.lock.address for w in self.waits if w.lock.address]
+
+    def lock_orders(self) -> List[LockOrder]:
+        orders: List[LockOrder] = []
+        for w in self.waits:
+            steps = [LockStep(lock=h.lock, location=h.acquired_at) for h in w.held]
+            steps.append(LockStep(lock=w.lock, location=w.waiting_location, waiting=True))
+            function = w.waiting_at.function if w.waiting_at is not None else ""
+            orders.append(LockOrder(goroutine_id=w.goroutine_id, function=function, steps=steps))
+        return orders
+
+    def global_order(self) -> List[LockRef]:
+        """The cycle's locks in the order the first goroutine takes them; following it breaks the cycle."""
+        # waits[i] holds waits[i-1].lock and wants waits[i].lock.
+        return [self.waits[i - 1].lock for i in range(len(self.waits))]
+
+    def describe(self) -> str:
+        """One-sentence narration suitable for the LLM summary step."""
+        if len(self.waits) == 1:
+            w = self.waits[0]
+            held = w.holds(w.lock.key)
+            where = f" (acquired at {held.acquired_at})" if held else ""
+            return (
+                f"goroutine {w.goroutine_id} already holds {w.lock.label}{where} "
+                f"and tries to lock it again at {w.waiting_location}."
+            )
+        parts: List[str] = []
+        for idx, w in enumerate(self.waits):
+            # The lock this goroutine holds that its predecessor is waiting for.
+            prev = self.waits[idx - 1]
+            held = w.holds(prev.lock.key)
+            holds_txt = prev.lock.label
+            if held is not None:
+                holds_txt += f" (acquired at {held.acquired_at})"
+            parts.append(
+                f"goroutine {w.goroutine_id} holds {holds_txt} and wants "
+                f"{w.lock.label} (waiting at {w.waiting_location})"
+            )
+        return " while ".join(parts) + "."
+
+
+def _lock_address(goroutine: Goroutine) -> str:
+    for frame in goroutine.frames:
+        fn = frame.function
+        if fn.endswith(".lockSlow") or fn.endswith("(*RWMutex).RLock") or fn.endswith("(*RWMutex).Lock"):
+            m = _ADDR_RE.search(frame.args or "")
+            if m:
+                return m.group(0)
+    return ""
+
+
+def _strip_comment(text: str) -> str:
+    idx = text.find("//")
+    return text if idx < 0 else text[:idx]
+
+
+def _function_start(lines: List[str], lineno: int) -> int:
+    for idx in range(min(lineno, len(lines)) - 1, -1, -1):
+        if _FUNC_START_RE.match(lines[idx]):
+            return idx + 1
+    return 1
+
+
+def _held_in_frame(frame: Frame, sources: SourceCache) -> Optional[List[HeldLock]]:
+    """Locks acquired in ``frame``'s function before its current line, or None without source."""
+    lines = sources.lines(frame.file)
+    if lines is None or frame.line < 1:
+        return None
+    start = _function_start(lines, frame.line)
+    held: Dict[str, HeldLock] = {}
+    for lineno in range(start, frame.line):
+        text = _strip_comment(lines[lineno - 1])
+        deferred = text.lstrip().startswith("defer ")
+        for m in _LOCK_CALL_RE.finditer(text):
+            name, op = m.group(1), m.group(2)
+            if op in {"Lock", "RLock"}:
+                held[name] = HeldLock(
+                    lock=LockRef(name=name),
+                    acquired_at=f"{frame.file}:{lineno}",
+                    function=frame.function,
+                    read=op == "RLock",
+                )
+            elif not deferred:
+                held.pop(name, None)
+    return list(held.values())
+
+
+def _locked_name(file: str, line: int, sources: SourceCache) -> str:
+    """The expression the last Lock/RLock call on ``file:line`` locks, or ""."""
+    text = sources.line(file, line)
+    if text is None:
+        return ""
+    names = [m.group(1) for m in _LOCK_CALL_RE.finditer(_strip_comment(text)) if m.group(2) in {"Lock", "RLock"}]
+    return names[-1] if names else ""
+
+
+def _waited_lock_name(frame: Optional[Frame], sources: SourceCache) -> str:
+    if frame is None:
+        return ""
+    return _locked_name(frame.file, frame.line, sources)
+
+
+def held_locks(goroutine: Goroutine, sources: Optional[SourceCache] = None) -> List[HeldLock]:
+    """Locks ``goroutine`` holds, oldest first: observed ones, then those reconstructed from source."""
+    sources = sources or SourceCache()
+    held: List[HeldLock] = []
+    for observed in goroutine.observed_locks:
+        path, _, line = observed.acquired_at.rpartition(":")
+        name = observed.lock.name or (_locked_name(path, int(line), sources) if line.isdigit() else "")
+        lock = LockRef(address=observed.lock.address, name=name)
+        held.append(
+            HeldLock(lock=lock, acquired_at=observed.acquired_at, function=observed.function, read=observed.read)
+        )
+    observed_names = {h.lock.name for h in held if h.lock.name}
+    # Outermost frame first, so ``held`` is in acquisition order.
+    for frame in reversed(goroutine.user_frames()):
+        held.extend(h for h in _held_in_frame(frame, sources) or [] if h.lock.name not in observed_names)
+    return held
+
+
+def find_lock_waits(dump: GoroutineDump, sources: Optional[SourceCache] = None) -> List[LockWait]:
+    """Return every goroutine blocked on a mutex/RWMutex with its held locks."""
+    sources = sources or SourceCache()
+    waits: List[LockWait] = []
+    for goroutine in dump.goroutines:
+        kind = goroutine.wait_kind
+        if kind not in LOCK_WAIT_KINDS:
+            continue
+        user_frames = goroutine.user_frames()
+        waiting_at = user_frames[0] if user_frames else None
+        lock = LockRef(address=_lock_address(goroutine), name=_waited_lock_name(waiting_at, sources))
+        held = held_locks(goroutine, sources)
+        waits.append(
+            LockWait(
+                goroutine_id=goroutine.id,
+                lock=lock,
+                waiting_at=waiting_at,
+                read=kind == WAIT_RWMUTEX_READ,
+                held=held,
+            )
+        )
+    _resolve_addresses(waits)
+    return waits
+
+
+def _addresses_by_name(waits: List[LockWait]) -> Dict[str, Set[str]]:
+    by_name: Dict[str, Set[str]] = {}
+    for w in waits:
+        for lock in [w.lock] + [h.lock for h in w.held]:
+            if lock.name and lock.address:
+                by_name.setdefault(lock.name, set()).add(lock.address)
+    return by_name
+
+
+def _resolve(lock: LockRef, by_name: Dict[str, Set[str]]) -> None:
+    addrs = by_name.get(lock.name, set())
+    if lock.name and not lock.address and len(addrs) == 1:
+        lock.address = next(iter(addrs))
+
+
+def _resolve_addresses(waits: List[LockWait]) -> None:
+    """Give source-only locks the address observed for the same name elsewhere."""
+    by_name = _addresses_by_name(waits)
+    for w in waits:
+        _resolve(w.lock, by_name)
+        for held in w.held:
+            _resolve(held.lock, by_name)
+
+
+def _wait_graph(waits: List[LockWait]) -> Dict[int, List[int]]:
+    graph: Dict[int, List[int]] = {}
+    for waiter in waits:
+        key = waiter.lock.key
+        if not key:
+            continue
+        targets: List[int] = []
+        for holder in waits:
+            held = holder.holds(key)
+            if held is None:
+                continue
+            # Concurrent readers do not block each other.
+            if waiter.read and held.read:
+                continue
+            targets.append(holder.goroutine_id)
+        graph[waiter.goroutine_id] = sorted(set(targets))
+    return graph
+
+
+def _find_cycles(graph: Dict[int, List[int]]) -> List[List[int]]:
+    cycles: List[List[int]] = []
+    seen: Set[tuple[int, ...]] = set()
+    for start in sorted(graph):
+        stack: List[tuple[int, List[int]]] = [(start, [start])]
+        while stack:
+            node, path = stack.pop()
+            for nxt in graph.get(node, []):
+                if nxt == start:
+                    key = tuple(path)
+                    if key not in seen:
+                        seen.add(key)
+                        cycles.append(list(path))
+                elif nxt > start and nxt not in path:
+                    stack.append((nxt, path + [nxt]))
+    return cycles
+
+
+def detect_deadlock(dump: GoroutineDump, sources: Optional[SourceCache] = None) -> List[DeadlockCycle]:
+    """Return lock cycles found in ``dump`` (empty when there is no mutex deadlock)."""
+    waits = find_lock_waits(dump, sources)
+    by_id = {w.goroutine_id: w for w in waits}
+    cycles = _find_cycles(_wait_graph(waits))
+    return [DeadlockCycle(waits=[by_id[gid] for gid in cycle]) for cycle in cycles]
+
+
+def format_lock_orders(cycle: DeadlockCycle) -> str:
+    """The cycle's lock orderings side by side ("" for a goroutine re-locking its own mutex)."""
+    if len(cycle.waits) < 2:
+        return ""
+    orders = cycle.lock_orders()
+    columns: List[List[str]] = []
+    for order in orders:
+        column = [f"goroutine {order.goroutine_id} {order.function}".rstrip()]
+        for idx, step in enumerate(order.steps, start=1):
+            blocked = "  <- blocked" if step.waiting else ""
+            column.append(f"{idx}. {step.lock.name or step.lock.label}  {step.short_location}{blocked}")
+        columns.append(column)
+    widths = [max(len(cell) for cell in column) for column in columns]
+    height = max(len(column) for column in columns)
+    ids = " vs ".join(f"goroutine {o.goroutine_id}" for o in orders)
+    lines = [f"Lock order inversion ({ids}):"]
+    for row in range(height):
+        cells = [(column[row] if row < len(column) else "").ljust(width) for column, width in zip(columns, widths)]
+        lines.append(("  " + "   |   ".join(cells)).rstrip())
+    return "\n".join(lines)
+
+
+def lock_order_fix(cycle: DeadlockCycle) -> Optional[Tuple[str, str]]:
+    """(summary, details) of a consistent global lock order for ``cycle``; None for a self-deadlock."""
+    if len(cycle.waits) < 2:
+        return None
+    order = cycle.global_order()
+    names = [lock.name or lock.label for lock in order]
+    keys = [lock.key for lock in order]
+    following: List[str] = []
+    changes: List[str] = []
+    for lock_order in cycle.lock_orders():
+        taken = [step for step in lock_order.steps if step.lock.key in keys]
+        where = ", ".join(f"{_lock_name(order, step.lock.key)} at {step.short_location}" for step in taken)
+        taken_keys = [step.lock.key for step in taken]
+        fn = lock_order.function or f"goroutine {lock_order.goroutine_id}"
+        if taken_keys == [key for key in keys if key in taken_keys]:
+            following.append(f"{fn} already does ({where})")
+        else:
+            changes.append(f"change {fn} to take them in that order (it takes {where})")
+    summary = "Acquire " + " before ".join(names) + " everywhere"
+    details = "; ".join(changes + following) + "."
+    return summary, details[0].upper() + details[1:]
+
+
+def _lock_name(order: List[LockRef], key: str) -> str:
+    for lock in order:
+        if lock.key == key:
+            return lock.name or lock.label
+    return key
+
+
+def format_held_locks(dump: GoroutineDump, sources: Optional[SourceCache] = None) -> str:
+    """Locks each goroutine of ``dump`` holds, one line per lock ("" when none holds any)."""
+    sources = sources or SourceCache()
+    waits = find_lock_waits(dump, sources)
+    by_id = {w.goroutine_id: w.held for w in waits}
+    by_name = _addresses_by_name(waits)
+    lines: List[str] = []
+    for goroutine in dump.goroutines:
+        for held in by_id.get(goroutine.id) or held_locks(goroutine, sources):
+            _resolve(held.lock, by_name)
+            mode = " (read)" if held.read else ""
+            step = LockStep(lock=held.lock, location=held.acquired_at)
+            lines.append(
+                f"- goroutine {goroutine.id} [{goroutine.wait_kind}]: {held.lock.label}{mode} "
+                f"since {step.short_location} in {held.function or '?'}"
+            )
+    return "\n".join(["Held locks:"] + lines) if lines else ""
+
+
+def format_deadlock_report(cycles: List[DeadlockCycle]) -> str:
+    if not cycles:
+        return ""
+    lines = [f"Detected {len(cycles)} lock cycle(s):"]
+    for idx, cycle in enumerate(cycles, start=1):
+        lines.append(f"{idx}. {cycle.describe()}")
+    for cycle in cycles:
+        table = format_lock_orders(cycle)
+        fix = lock_order_fix(cycle)
+        if table:
+            lines.append(table)
+        if fix is not None:
+            lines.append(f"Suggested fix: {fix[0]}. {fix[1]}")
+    return "\n".join(lines)
@@ -0,0 +1,168 @@
+"""Compare two goroutine dumps of the same process taken some time apart.
+
+A slow leak of blocked goroutines, or a hang that builds up, is easiest to
+see as a difference: which goroutines appeared, which finished, which moved
+on, and which sat in exactly the same wait in both snapshots. The last set
+is what points at a deadlock, so it is reported first.
+
+Goroutines are matched by id when the id refers to the same goroutine in
+both dumps (Go never reuses ids within a process; a different creation
+site means the dumps come from different runs). The rest are paired by
+creation site, preferring an identical stack, which also lines up dumps
+taken from two runs of the same program.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional, Tuple
+
+from .goroutines import WAIT_RUNNING, Goroutine, GoroutineDump
+from .grouping import format_groups, group_goroutines
+
+GoroutinePair = Tuple[Goroutine, Goroutine]
+
+
+def _new_goroutine_list() -> List[Goroutine]:
+    return []
+
+
+def _new_pair_list() -> List[GoroutinePair]:
+    return []
+
+
+@dataclass
+class DumpDiff:
+    """What changed between two dumps; pairs are ``(before, after)``."""
+
+    appeared: List[Goroutine] = field(default_factory=_new_goroutine_list)
+    gone: List[Goroutine] = field(default_factory=_new_goroutine_list)
+    # Matched goroutines whose state or stack changed, or that woke up and blocked again.
+    transitioned: List[GoroutinePair] = field(default_factory=_new_pair_list)
+    # Matched goroutines blocked in the same wait, at the same stack, in both dumps.
+    stuck: List[GoroutinePair] = field(default_factory=_new_pair_list)
+    unchanged: int = 0
+    # Goroutines paired by creation site because their ids did not line up.
+    matched_by_creation: int = 0
+
+    @property
+    def changed(self) -> bool:
+        return bool(self.appeared or self.gone or self.transitioned)
+
+
+def _creation_key(g: Goroutine) -> Tuple[object, ...]:
+    created = g.created_by
+    return (created.function, created.file, created.line) if created else ("",)
+
+
+def _stack_key(g: Goroutine) -> Tuple[object, ...]:
+    return tuple((f.function, f.file, f.line) for f in g.frames)
+
+
+def _same_wait(before: Goroutine, after: Goroutine) -> bool:
+    if before.state != after.state or _stack_key(before) != _stack_key(after):
+        return False
+    if before.wait_kind == WAIT_RUNNING:
+        return False
+    # A shorter wait means it woke up and blocked again in between.
+    if before.wait_minutes is not None and after.wait_minutes is not None:
+        return after.wait_minutes >= before.wait_minutes
+    return True
+
+
+def _match(before: GoroutineDump, after: GoroutineDump) -> Tuple[List[GoroutinePair], List[Goroutine], List[Goroutine]]:
+    pairs: List[GoroutinePair] = []
+    by_id = {g.id: g for g in before.goroutines}
+    unmatched_after: List[Goroutine] = []
+    for g in after.goroutines:
+        old = by_id.get(g.id)
+        if old is not None and _creation_key(old) == _creation_key(g):
+            pairs.append((old, g))
+            del by_id[g.id]
+        else:
+            unmatched_after.append(g)
+    if not unmatched_after:
+        return pairs, [], list(by_id.values())
+
+    # Pair the rest by creation site, identical stacks first.
+    pool: Dict[Tuple[object, ...], List[Goroutine]] = {}
+    for g in by_id.values():
+        pool.setdefault(_creation_key(g), []).append(g)
+    appeared: List[Goroutine] = []
+    leftovers: List[Goroutine] = []
+    for g in unmatched_after:
+        candidates = pool.get(_creation_key(g)) or []
+        same = [c for c in candidates if _stack_key(c) == _stack_key(g) and c.state == g.state]
+        if same:
+            candidates.remove(same[0])
+            pairs.append((same[0], g))
+        else:
+            leftovers.append(g)
+    for g in leftovers:
+        candidates = pool.get(_creation_key(g)) or []
+        if candidates and g.created_by is not None:
+            pairs.append((candidates.pop(0), g))
+        else:
+            appeared.append(g)
+    gone = [g for group in pool.values() for g in group]
+    return pairs, appeared, gone
+
+
+def diff_dumps(before: GoroutineDump, after: GoroutineDump) -> DumpDiff:
+    """Match goroutines across two snapshots and classify each as appeared, gone, transitioned or stuck."""
+    pairs, appeared, gone = _match(before, after)
+    diff = DumpDiff(appeared=appeared, gone=gone)
+    for old, new in pairs:
+        if old.id != new.id:
+            diff.matched_by_creation += 1
+        if _same_wait(old, new):
+            diff.stuck.append((old, new))
+        elif old.state == new.state and _stack_key(old) == _stack_key(new) and old.wait_kind == WAIT_RUNNING:
+            diff.unchanged += 1
+        else:
+            diff.transitioned.append((old, new))
+    return diff
+
+
+def _grouped(goroutines: List[Goroutine], total: int) -> str:
+    dump = GoroutineDump(goroutines=goroutines)
+    return format_groups(group_goroutines(dump), total=total)
+
+
+def format_dump_diff(diff: DumpDiff, *, total: Optional[int] = None) -> str:
+    """Render the diff for the prompt: the stuck set first, then what appeared, moved and went away."""
+    total_after = total if total is not None else (
+        len(diff.appeared) + len(diff.transitioned) + len(diff.stuck) + diff.unchanged
+    )
+    lines = [
+        f"Goroutine dump diff: {len(diff.stuck)} stuck, {len(diff.appeared)} appeared, "
+        f"{len(diff.transitioned)} changed state or stack, {len(diff.gone)} gone, {diff.unchanged} unchanged."
+    ]
+    if diff.matched_by_creation:
+        lines.append(f"({diff.matched_by_creation} goroutine(s) matched by creation site because their ids differ.)")
+    if diff.stuck:
+        lines.append("")
+        lines.append("STUCK in the same wait in both dumps (likely deadlock or leak; focus here):")
+        lines.append(_grouped([new for _, new in diff.stuck], total_after))
+    if diff.appeared:
+        lines.append("")
+        lines.append("Appeared since the first dump:")
+        lines.append(_grouped(diff.appeared, total_after))
+    if diff.transitioned:
+        lines.append("")
+        lines.append("Changed state or stack:")
+        for old, new in diff.transitioned[:20]:
+            where_old = old.top_user_frame() or (old.frames[0] if old.frames else None)
+            where_new = new.top_user_frame() or (new.frames[0] if new.frames else None)
+            lines.append(
+                f"  goroutine {new.id}: [{old.state or 'unknown'}] {where_old.function if where_old else '?'}"
+                f" -> [{new.state or 'unknown'}] {where_new.function if where_new else '?'}"
+            )
+        if len(diff.transitioned) > 20:
+            lines.append(f"  ... {len(diff.transitioned) - 20} more")
+    if diff.gone:
+        lines.append("")
+        lines.append(f"Gone (finished) since the first dump: {len(diff.gone)} goroutine(s)")
+    return "\n".join(lines)
+
+
+__all__ = ["DumpDiff", "diff_dumps", "format_dump_diff"]
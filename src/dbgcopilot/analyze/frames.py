@@ -0,0 +1,113 @@
+"""Render stack traces with the surrounding source of every frame.
+
+Frames that carry only ``file:line`` make the LLM guess at the code. Here
+each frame is followed by a few lines read from disk, with the active line
+marked; frames whose source is not present locally (stdlib, vendored
+dependencies, stripped binaries) are listed with a short note instead.
+"""
+from __future__ import annotations
+
+from typing import List, Optional
+import re
+
+from dbgcopilot.utils.source import DEFAULT_CONTEXT_RADIUS, SourceCache
+
+from .goroutines import Frame, GoroutineDump, looks_like_goroutine_dump, parse_delve_frames, parse_goroutine_dump
+
+
+MAX_CONTEXT_FRAMES = 12
+
+_GDB_FRAME_RE = re.compile(
+    r"^#(\d+)\s+(?:(0x[0-9a-fA-F]+)\s+in\s+)?(\S+)\s*\(.*?\)(?:\s+at\s+(\S+?):(\d+))?(?:\s+from\s+(\S+))?\s*$"
+)
+_LLDB_FRAME_MARK = re.compile(r"^\s*\*?\s*frame #\d+:", re.MULTILINE)
+
+
+def _parse_gdb_frames(text: str) -> List[Frame]:
+    frames: List[Frame] = []
+    for line in text.splitlines():
+        m = _GDB_FRAME_RE.match(line.strip())
+        if m:
+            frames.append(
+                Frame(
+                    function=m.group(3),
+                    file=m.group(4) or "",
+                    line=int(m.group(5)) if m.group(5) else 0,
+                    pc=m.group(2) or "",
+                    module=m.group(6) or "",
+                )
+            )
+    return frames
+
+
+def _focus_frames(dump: GoroutineDump) -> List[Frame]:
+    # The goroutine/thread that stopped is the one the user is looking at.
+    for g in dump.goroutines:
+        if g.current or g.state == "running":
+            return g.frames
+    return dump.goroutines[0].frames if dump.goroutines else []
+
+
+def extract_stack(text: str) -> List[Frame]:
+    """Pull the active stack out of gdb, LLDB or Delve output, or a Go goroutine dump."""
+    if not text:
+        return []
+    if _LLDB_FRAME_MARK.search(text):
+        from dbgcopilot.debugger.lldb import parse_backtrace
+
+        return _focus_frames(parse_backtrace(text))
+    if looks_like_goroutine_dump(text):
+        return _focus_frames(parse_goroutine_dump(text))
+    frames = parse_delve_frames(text)
+    if frames:
+        return frames
+    return _parse_gdb_frames(text)
+
+
+def format_stacktrace(
+    frames: List[Frame],
+    *,
+    radius: int = DEFAULT_CONTEXT_RADIUS,
+    sources: Optional[SourceCache] = None,
+    limit: int = MAX_CONTEXT_FRAMES,
+) -> str:
+    """Render frames as ``#N func at file:line`` followed by their source context."""
+    cache = sources or SourceCache()
+    lines: List[str] = []
+    for idx, frame in enumerate(frames[:limit]):
+        lines.append(f"#{idx} {frame.function} at {frame.location}")
+        if radius <= 0:
+            continue
+        if not frame.file or not frame.line:
+            lines.append("  (no line information)")
+            continue
+        ctx = cache.context(frame.file, frame.line, radius)
+        if ctx.available:
+            lines.extend(ctx.render())
+        else:
+            lines.append("  (source not available locally)")
+    if len(frames) > limit:
+        lines.append(f"... {len(frames) - limit} more frame(s)")
+    return "\n".join(lines)
+
+
+def stack_context_for_output(text: str, radius: int = DEFAULT_CONTEXT_RADIUS) -> str:
+    """Source-annotated stack for debugger output, or "" when there is nothing worth adding."""
+    if radius <= 0:
+        return ""
+    frames = extract_stack(text)
+    if not any(f.file and f.line for f in frames):
+        return ""
+    cache = SourceCache()
+    # Only worth the prompt space when at least one frame's source is on disk.
+    if not any(cache.context(f.file, f.line, 0).available for f in frames[:MAX_CONTEXT_FRAMES]):
+        return ""
+    return "Stack with source context:\n" + format_stacktrace(frames, radius=radius, sources=cache)
+
+
+__all__ = [
+    "MAX_CONTEXT_FRAMES",
+    "extract_stack",
+    "format_stacktrace",
+    "stack_context_for_output",
+]
@@ -0,0 +1,313 @@
+"""Goroutine dump model and parser.
+
+Understands the two dump shapes we see in practice:
+
+- the Go runtime format printed by ``runtime.Stack``, ``SIGQUIT`` and fatal
+  errors (``goroutine 18 [sync.Mutex.Lock]:`` followed by function/file pairs)
+- Delve's ``goroutines -t`` / ``stack`` output (``Goroutine 18 - User: ...``
+  followed by numbered ``0x... in func`` / ``at file:line`` frames)
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import TYPE_CHECKING, Any, List, Optional
+import re
+
+if TYPE_CHECKING:
+    from dbgcopilot.utils.source import SourceCache
+
+    from .deadlock import HeldLock
+
+
+WAIT_RUNNING = "running"
+WAIT_MUTEX = "mutex"
+WAIT_RWMUTEX_READ = "rwmutex-read"
+WAIT_RWMUTEX_WRITE = "rwmutex-write"
+WAIT_CHAN_SEND = "chan send"
+WAIT_CHAN_RECEIVE = "chan receive"
+WAIT_SELECT = "select"
+WAIT_SELECT_NO_CASES = "select (no cases)"
+WAIT_IO = "IO wait"
+WAIT_SLEEP = "sleep"
+WAIT_OTHER = "other"
+
+_SYSTEM_MODULE_RE = re.compile(
+    r"^(?:lib(?:c|m|dl|rt|pthread|stdc\+\+|c\+\+|gcc_s|system_\w+|dyld)\b|ld-linux|ld64|dyld)"
+)
+
+LOCK_WAIT_KINDS = {WAIT_MUTEX, WAIT_RWMUTEX_READ, WAIT_RWMUTEX_WRITE}
+CHAN_WAIT_KINDS = {WAIT_CHAN_SEND, WAIT_CHAN_RECEIVE, WAIT_SELECT, WAIT_SELECT_NO_CASES}
+
+
+@dataclass
+class Frame:
+    """One stack frame; the same shape is used by every debugger backend."""
+
+    function: str
+    file: str = ""
+    line: int = 0
+    args: str = ""
+    pc: str = ""
+    module: str = ""
+
+    @property
+    def location(self) -> str:
+        if not self.file:
+            return self.function
+        return f"{self.file}:{self.line}" if self.line else self.file
+
+    @property
+    def package(self) -> str:
+        name = self.function
+        slash = name.rfind("/")
+        head, tail = name[: slash + 1], name[slash + 1 :]
+        return head + tail.split(".", 1)[0]
+
+    def is_runtime(self) -> bool:
+        """Return True for Go runtime/standard-library frames and native system libraries."""
+        if self.module:
+            return not self.file or bool(_SYSTEM_MODULE_RE.match(self.module))
+        pkg = self.package
+        if not pkg or pkg == "main":
+            return False
+        first = pkg.split("/", 1)[0]
+        return "." not in first
+
+
+def _new_frame_list() -> List[Frame]:
+    return []
+
+
+def _new_observed_list() -> List[Any]:
+    return []
+
+
+@dataclass
+class Goroutine:
+    id: int
+    state: str = ""
+    wait_minutes: Optional[int] = None
+    frames: List[Frame] = field(default_factory=_new_frame_list)
+    created_by: Optional[Frame] = None
+    current: bool = False
+    # Locks a lock monitor saw this goroutine take and not release (``debugger.locks``).
+    observed_locks: List["HeldLock"] = field(default_factory=_new_observed_list)
+
+    @property
+    def wait_kind(self) -> str:
+        """Classify what the goroutine is blocked on."""
+        for frame in self.frames:
+            fn = frame.function
+            if fn.endswith("(*RWMutex).RLock"):
+                return WAIT_RWMUTEX_READ
+            if fn.endswith("(*RWMutex).Lock"):
+                return WAIT_RWMUTEX_WRITE
+            if fn.endswith("(*Mutex).Lock") or fn.endswith("(*Mutex).lockSlow"):
+                return WAIT_MUTEX
+        state = self.state.lower()
+        if state.startswith("sync.rwmutex.rlock"):
+            return WAIT_RWMUTEX_READ
+        if state.startswith("sync.rwmutex.lock"):
+            return WAIT_RWMUTEX_WRITE
+        if state.startswith("sync.mutex.lock"):
+            return WAIT_MUTEX
+        if state.startswith("chan send"):
+            return WAIT_CHAN_SEND
+        if state.startswith("chan receive"):
+            return WAIT_CHAN_RECEIVE
+        if state.startswith("select (no cases)"):
+            return WAIT_SELECT_NO_CASES
+        if state.startswith("select"):
+            return WAIT_SELECT
+        if state.startswith("io wait"):
+            return WAIT_IO
+        if state.startswith("sleep"):
+            return WAIT_SLEEP
+        if state in {"running", "runnable", "syscall", ""}:
+            return WAIT_RUNNING
+        return WAIT_OTHER
+
+    def user_frames(self) -> List[Frame]:
+        return [f for f in self.frames if not f.is_runtime()]
+
+    def top_user_frame(self) -> Optional[Frame]:
+        for frame in self.frames:
+            if not frame.is_runtime():
+                return frame
+        return None
+
+    def held_locks(self, sources: Optional["SourceCache"] = None) -> List["HeldLock"]:
+        """Locks this goroutine holds, oldest first; best effort.
+
+        ``observed_locks`` come first, with their addresses; then the locks
+        each user frame's function took from source before its current line
+        and has not unlocked since.
+        """
+        from .deadlock import held_locks
+
+        return held_locks(self, sources)
+
+
+def _new_goroutine_list() -> List[Goroutine]:
+    return []
+
+
+@dataclass
+class GoroutineDump:
+    goroutines: List[Goroutine] = field(default_factory=_new_goroutine_list)
+    header: str = ""
+    raw: str = ""
+
+    def get(self, goroutine_id: int) -> Optional[Goroutine]:
+        for g in self.goroutines:
+            if g.id == goroutine_id:
+                return g
+        return None
+
+    def __len__(self) -> int:
+        return len(self.goroutines)
+
+
+_RUNTIME_HEADER_RE = re.compile(r"^goroutine\s+(\d+)\s+(?:gp=\S+\s+m=\S+(?:\s+mp=\S+)?\s+)?\[([^\]]*)\]:\s*$")
+_DELVE_HEADER_RE = re.compile(
+    r"^(\*)?\s*Goroutine\s+(\d+)\s+-\s+\S+:\s+(\S+?):(\d+)\s+(\S+)\s+\((0x[0-9a-fA-F]+)\)(?:\s+\[([^\]]*)\])?"
+)
+_DELVE_FRAME_RE = re.compile(r"^\s*(\d+)\s+(0x[0-9a-fA-F]+)\s+in\s+(\S+)\s*$")
+_DELVE_AT_RE = re.compile(r"^\s*at\s+(\S+?):(\d+)\s*$")
+_CALL_RE = re.compile(r"^(?P<func>\S.*?)\((?P<args>[^()]*)\)$")
+_FILE_RE = re.compile(r"^\s+(\S+?):(\d+)(?:\s+(\+0x[0-9a-fA-F]+))?")
+_CREATED_RE = re.compile(r"^created by\s+(\S+)(?:\s+in goroutine\s+\d+)?\s*$")
+_WAIT_MINUTES_RE = re.compile(r",\s*(\d+)\s+minutes?")
+
+
+def _split_state(raw_state: str) -> tuple[str, Optional[int]]:
+    minutes = None
+    m = _WAIT_MINUTES_RE.search(raw_state)
+    if m:
+        minutes = int(m.group(1))
+    state = raw_state.split(",", 1)[0].strip()
+    # Delve appends the wait-since timestamp: "[sync.Mutex.Lock 12345678]"
+    state = re.sub(r"\s+\d+$", "", state)
+    return state, minutes
+
+
+def parse_goroutine_dump(text: str) -> GoroutineDump:
+    """Parse a runtime or Delve goroutine dump into a ``GoroutineDump``."""
+    dump = GoroutineDump(raw=text or "")
+    header_lines: List[str] = []
+    current: Optional[Goroutine] = None
+    pending_call: Optional[Frame] = None
+    pending_created = False
+    lines = (text or "").replace("\r\n", "\n").split("\n")
+
+    for index, raw_line in enumerate(lines):
+        line = raw_line.rstrip()
+        stripped = line.strip()
+
+        m = _RUNTIME_HEADER_RE.match(stripped)
+        if m:
+            state, minutes = _split_state(m.group(2))
+            current = Goroutine(id=int(m.group(1)), state=state, wait_minutes=minutes)
+            dump.goroutines.append(current)
+            pending_call = None
+            pending_created = False
+            continue
+
+        m = _DELVE_HEADER_RE.match(stripped)
+        if m:
+            state, minutes = _split_state(m.group(7) or "")
+            current = Goroutine(id=int(m.group(2)), state=state, wait_minutes=minutes, current=bool(m.group(1)))
+            # Without -t Delve lists only the user location; keep it as the sole frame.
+            current.frames.append(
+                Frame(function=m.group(5), file=m.group(3), line=int(m.group(4)), pc=m.group(6))
+            )
+            dump.goroutines.append(current)
+            pending_call = None
+            pending_created = False
+            continue
+
+        if current is None:
+            if stripped:
+                header_lines.append(stripped)
+            continue
+        if not stripped:
+            pending_call = None
+            continue
+
+        m = _DELVE_FRAME_RE.match(line)
+        if m:
+            if m.group(1) == "0" and len(current.frames) == 1 and not current.frames[0].args:
+                # Replace the header's user-location placeholder with the full stack.
+                current.frames.clear()
+            pending_call = Frame(function=m.group(3), pc=m.group(2))
+            current.frames.append(pending_call)
+            continue
+        m = _DELVE_AT_RE.match(line)
+        if m and pending_call is not None:
+            pending_call.file = m.group(1)
+            pending_call.line = int(m.group(2))
+            pending_call = None
+            continue
+
+        m = _CREATED_RE.match(stripped)
+        if m:
+            current.created_by = Frame(function=m.group(1))
+            pending_created = True
+            continue
+
+        m = _FILE_RE.match(line)
+        if m and line[:1] in {"\t", " "}:
+            target = current.created_by if pending_created else pending_call
+            if target is not None:
+                target.file = m.group(1)
+                target.line = int(m.group(2))
+                target.pc = m.group(3) or target.pc
+            pending_call = None
+            pending_created = False
+            continue
+
+        # A function line is a frame only with its file:line below; "exit status 2" after a dump is not.
+        below = lines[index + 1] if index + 1 < len(lines) else ""
+        if not (below[:1] in {"\t", " "} and _FILE_RE.match(below.rstrip())):
+            pending_call = None
+            continue
+        m = _CALL_RE.match(stripped)
+        if m:
+            pending_call = Frame(function=m.group("func"), args=m.group("args"))
+            current.frames.append(pending_call)
+            pending_created = False
+            continue
+        pending_call = Frame(function=stripped)
+        current.frames.append(pending_call)
+
+    dump.header = "\n".join(header_lines)
+    return dump
+
+
+def looks_like_goroutine_dump(text: str) -> bool:
+    """Quick check used before running analyzers on arbitrary debugger output."""
+    if not text:
+        return False
+    for line in text.splitlines():
+        stripped = line.strip()
+        if _RUNTIME_HEADER_RE.match(stripped) or _DELVE_HEADER_RE.match(stripped):
+            return True
+    return False
+
+
+def parse_delve_frames(text: str) -> List[Frame]:
+    """Parse the numbered frame listing printed by Delve's ``stack`` command."""
+    frames: List[Frame] = []
+    pending: Optional[Frame] = None
+    for line in (text or "").replace("\r\n", "\n").split("\n"):
+        m = _DELVE_FRAME_RE.match(line)
+        if m:
+            pending = Frame(function=m.group(3), pc=m.group(2))
+            frames.append(pending)
+            continue
+        m = _DELVE_AT_RE.match(line)
+        if m and pending is not None:
+            pending.file = m.group(1)
+            pending.line = int(m.group(2))
+            pending = None
+    return frames
@@ -0,0 +1,176 @@
+"""Collapse goroutines with identical stacks, like pprof's goroutine profile.
+
+A hang in a server with thousands of goroutines usually has only a handful
+of distinct stacks. Grouping by state plus the (function, file, line) of
+every frame turns the dump into a short list of "N goroutines doing X",
+which fits in the LLM context; arguments and wait times are ignored since
+they differ between otherwise identical goroutines.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Dict, Iterable, List, Optional, Sequence, Tuple
+
+from .goroutines import Goroutine, GoroutineDump, looks_like_goroutine_dump, parse_goroutine_dump
+
+
+# Dumps with more goroutines than this are sent to the LLM grouped.
+GROUP_THRESHOLD = 20
+MAX_GROUPS_SHOWN = 25
+MAX_IDS_SHOWN = 8
+
+
+def _new_goroutine_list() -> List[Goroutine]:
+    return []
+
+
+@dataclass
+class GoroutineGroup:
+    state: str
+    goroutines: List[Goroutine] = field(default_factory=_new_goroutine_list)
+
+    @property
+    def count(self) -> int:
+        return len(self.goroutines)
+
+    @property
+    def representative(self) -> Goroutine:
+        return self.goroutines[0]
+
+    @property
+    def ids(self) -> List[int]:
+        return [g.id for g in self.goroutines]
+
+    def describe(self, max_frames: int = 12, *, user_only: bool = False) -> str:
+        ids = ", ".join(str(i) for i in self.ids[:MAX_IDS_SHOWN])
+        if self.count > MAX_IDS_SHOWN:
+            ids += ", ..."
+        lines = [f"{self.count} goroutine(s) [{self.state or 'unknown'}]: {ids}"]
+        waits = [g.wait_minutes for g in self.goroutines if g.wait_minutes is not None]
+        if waits:
+            lines[0] += f" (waiting up to {max(waits)} minutes)"
+        rep = self.representative
+        frames = rep.user_frames() if user_only else rep.frames
+        for frame in frames[:max_frames]:
+            lines.append(f"  {frame.function} at {frame.location}")
+        if len(frames) > max_frames:
+            lines.append(f"  ... {len(frames) - max_frames} more frame(s)")
+        if len(frames) < len(rep.frames):
+            lines.append(f"  ({len(rep.frames) - len(frames)} runtime/stdlib frame(s) omitted)")
+        if rep.created_by is not None:
+            lines.append(f"  created by {rep.created_by.function} at {rep.created_by.location}")
+        return "\n".join(lines)
+
+
+def _signature(g: Goroutine) -> Tuple[object, ...]:
+    frames = tuple((f.function, f.file, f.line) for f in g.frames)
+    created = (g.created_by.function, g.created_by.file, g.created_by.line) if g.created_by else None
+    return (g.state, frames, created)
+
+
+def state_matches(g: Goroutine, wanted: str) -> bool:
+    """Match a user-facing state name ("chan receive", "sync.Mutex", "IO wait", ...)."""
+    want = wanted.strip().lower()
+    if not want:
+        return True
+    state = g.state.lower()
+    kind = g.wait_kind.lower()
+    if want in {"mutex", "sync.mutex", "lock"}:
+        return kind in {"mutex", "rwmutex-read", "rwmutex-write"} or state.startswith("sync.")
+    return kind == want or state.startswith(want)
+
+
+def filter_goroutines(
+    goroutines: Iterable[Goroutine],
+    *,
+    states: Optional[Sequence[str]] = None,
+    contains: str = "",
+) -> List[Goroutine]:
+    """Keep goroutines in any of ``states`` whose stack mentions ``contains``."""
+    needle = (contains or "").lower()
+    kept: List[Goroutine] = []
+    for g in goroutines:
+        if states and not any(state_matches(g, s) for s in states):
+            continue
+        if needle:
+            frames = list(g.frames) + ([g.created_by] if g.created_by else [])
+            if not any(needle in f.function.lower() or needle in f.location.lower() for f in frames):
+                continue
+        kept.append(g)
+    return kept
+
+
+def group_goroutines(
+    dump: GoroutineDump,
+    *,
+    states: Optional[Sequence[str]] = None,
+    contains: str = "",
+) -> List[GoroutineGroup]:
+    """Group (optionally filtered) goroutines by identical stack, largest group first."""
+    groups: Dict[Tuple[object, ...], GoroutineGroup] = {}
+    for g in filter_goroutines(dump.goroutines, states=states, contains=contains):
+        key = _signature(g)
+        group = groups.get(key)
+        if group is None:
+            group = groups[key] = GoroutineGroup(state=g.state)
+        group.goroutines.append(g)
+    return sorted(groups.values(), key=lambda grp: (-grp.count, grp.ids[0]))
+
+
+def format_groups(
+    groups: List[GoroutineGroup], *, total: int, limit: int = MAX_GROUPS_SHOWN, user_only: bool = False
+) -> str:
+    shown = sum(grp.count for grp in groups)
+    lines = [f"{shown} of {total} goroutine(s) in {len(groups)} group(s) with identical stacks:"]
+    for grp in groups[:limit]:
+        lines.append(grp.describe(user_only=user_only))
+    if len(groups) > limit:
+        rest = groups[limit:]
+        lines.append(f"... {len(rest)} more group(s) covering {sum(g.count for g in rest)} goroutine(s)")
+    return "\n".join(lines)
+
+
+def condense_goroutine_output(
+    text: str,
+    *,
+    states: Optional[Sequence[str]] = None,
+    contains: str = "",
+    threshold: int = GROUP_THRESHOLD,
+    user_only: bool = False,
+) -> str:
+    """Replace a large (or filtered) goroutine dump with its grouped form; other text is returned as is.
+
+    ``user_only`` lists only the non-runtime frames of each group, for prompts
+    that are still too large once grouped.
+    """
+    if not looks_like_goroutine_dump(text):
+        return text
+    dump = parse_goroutine_dump(text)
+    filtered = bool(states) or bool(contains)
+    if len(dump) <= threshold and not filtered:
+        return text
+    groups = group_goroutines(dump, states=states, contains=contains)
+    parts = []
+    if dump.header:
+        parts.append(dump.header)
+    parts.append(format_groups(groups, total=len(dump), user_only=user_only))
+    if filtered:
+        criteria = []
+        if states:
+            criteria.append("state in " + ", ".join(states))
+        if contains:
+            criteria.append(f"stack contains '{contains}'")
+        parts.append("Filter: " + "; ".join(criteria))
+    parts.append("(Grouped view; the raw dump is available on request.)")
+    return "\n".join(parts)
+
+
+__all__ = [
+    "GROUP_THRESHOLD",
+    "GoroutineGroup",
+    "condense_goroutine_output",
+    "filter_goroutines",
+    "format_groups",
+    "group_goroutines",
+    "state_matches",
+]
@@ -0,0 +1,202 @@
+"""Find goroutine leaks in a series of dumps of one process.
+
+A leak shows as a stack whose goroutine count only goes up: every sample
+has more goroutines parked at the same spot, created by the same function,
+than the one before. Goroutines are keyed by creation site, top user frame
+and wait kind, so the same leak is recognised while the runtime frames
+under it vary.
+
+Worker pools are not leaks even while they grow under load. Their
+goroutines finish and are replaced, so besides monotonic growth across at
+least ``MIN_SAMPLES`` samples a suspect's goroutines must survive from one
+sample to the next: at least ``MIN_RETAINED`` of them, matched by id (Go
+never reuses ids within a process). A fixed-size pool does not grow, and a
+churning one does not retain.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional, Sequence, Set, Tuple
+
+from .goroutines import (
+    WAIT_CHAN_RECEIVE,
+    WAIT_CHAN_SEND,
+    WAIT_IO,
+    WAIT_MUTEX,
+    WAIT_RWMUTEX_READ,
+    WAIT_RWMUTEX_WRITE,
+    WAIT_SELECT,
+    WAIT_SELECT_NO_CASES,
+    WAIT_SLEEP,
+    Frame,
+    Goroutine,
+    GoroutineDump,
+)
+from .grouping import GoroutineGroup
+
+MIN_SAMPLES = 3
+# Share of a stack's goroutines that must still exist in the next sample.
+MIN_RETAINED = 0.8
+MAX_SUSPECTS_SHOWN = 5
+
+_WAITGROUP_WAIT = "(*WaitGroup).Wait"
+
+# What usually keeps a goroutine parked this way forever.
+_MISSING = {
+    WAIT_CHAN_RECEIVE: "nothing sends on or closes the channel it receives from; close it when the producer is done, "
+    "or select on ctx.Done() as well",
+    WAIT_CHAN_SEND: "nobody receives its result any more (the caller returned early); make the channel buffered "
+    "or select on ctx.Done() when sending",
+    WAIT_SELECT: "no select case ever fires; add a ctx.Done() case and cancel the context when the caller returns",
+    WAIT_SELECT_NO_CASES: "it blocks in an empty select by design; give it a way to return",
+    WAIT_IO: "the connection it reads is never closed and has no deadline; close it or set a read deadline",
+    WAIT_SLEEP: "it loops on a timer with no exit condition; stop it through a context or a done channel",
+    WAIT_MUTEX: "the lock it waits for is never released",
+    WAIT_RWMUTEX_READ: "the lock it waits for is never released",
+    WAIT_RWMUTEX_WRITE: "the lock it waits for is never released",
+}
+_MISSING_WAITGROUP = "a WaitGroup.Add has no matching Done, so Wait never returns; defer wg.Done() in every worker"
+_MISSING_DEFAULT = "nothing tells it to stop; pass it a context and cancel the context when its work is abandoned"
+
+LeakKey = Tuple[object, ...]
+
+
+def _new_counts() -> List[int]:
+    return []
+
+
+@dataclass
+class LeakSuspect:
+    """One stack whose goroutine count grew in every sample."""
+
+    # Latest sample's goroutines at this stack.
+    group: GoroutineGroup
+    counts: List[int] = field(default_factory=_new_counts)
+    # Share of goroutines still alive from one sample to the next, over the whole series.
+    retained: float = 1.0
+
+    @property
+    def creator(self) -> Optional[Frame]:
+        return self.group.representative.created_by
+
+    @property
+    def spawner(self) -> str:
+        """Function whose ``go`` statement started the leaked goroutines."""
+        return self.creator.function if self.creator is not None else "(unknown)"
+
+    @property
+    def blocked_in(self) -> Optional[Frame]:
+        rep = self.group.representative
+        return rep.top_user_frame() or (rep.frames[0] if rep.frames else None)
+
+    @property
+    def growth(self) -> int:
+        return self.counts[-1] - self.counts[0]
+
+    @property
+    def rate(self) -> float:
+        """Goroutines added per sample interval."""
+        return self.growth / (len(self.counts) - 1)
+
+    @property
+    def wait_kind(self) -> str:
+        return self.group.representative.wait_kind
+
+    def likely_missing(self) -> str:
+        rep = self.group.representative
+        if rep.state.startswith("sync.WaitGroup.Wait") or any(f.function.endswith(_WAITGROUP_WAIT) for f in rep.frames):
+            return _MISSING_WAITGROUP
+        return _MISSING.get(rep.wait_kind, _MISSING_DEFAULT)
+
+    def describe(self) -> str:
+        where = self.blocked_in
+        blocked = f"{where.function} ({where.location})" if where is not None else "an unknown frame"
+        counts = " -> ".join(str(c) for c in self.counts)
+        lines = [
+            f"Goroutines started by {self.spawner} pile up [{self.group.state or 'unknown'}] in {blocked}: "
+            f"{counts} (+{self.rate:.1f} per sample, {self.retained:.0%} still alive from sample to sample)"
+        ]
+        if self.creator is not None:
+            lines.append(f"  created by {self.creator.function} at {self.creator.location}")
+        lines.append(f"  Likely missing: {self.likely_missing()}")
+        return "\n".join(lines)
+
+
+def _leak_key(g: Goroutine) -> LeakKey:
+    created = g.created_by
+    top = g.top_user_frame() or (g.frames[0] if g.frames else None)
+    return (
+        (created.function, created.file, created.line) if created else None,
+        (top.function, top.file, top.line) if top else None,
+        g.wait_kind,
+    )
+
+
+def _by_key(dump: GoroutineDump) -> Dict[LeakKey, GoroutineGroup]:
+    groups: Dict[LeakKey, GoroutineGroup] = {}
+    for g in dump.goroutines:
+        key = _leak_key(g)
+        group = groups.get(key)
+        if group is None:
+            group = groups[key] = GoroutineGroup(state=g.state)
+        group.goroutines.append(g)
+    return groups
+
+
+def detect_leak(
+    samples: Sequence[GoroutineDump],
+    *,
+    min_samples: int = MIN_SAMPLES,
+    min_retained: float = MIN_RETAINED,
+) -> List[LeakSuspect]:
+    """Stacks whose goroutine count grew in every sample, fastest-growing first.
+
+    ``samples`` are dumps of one process, oldest first; fewer than
+    ``min_samples`` of them cannot show monotonic growth and find nothing.
+    """
+    if len(samples) < max(min_samples, 2):
+        return []
+    series = [_by_key(dump) for dump in samples]
+    suspects: List[LeakSuspect] = []
+    for key, latest in series[-1].items():
+        groups = [sample.get(key) for sample in series]
+        counts = [group.count if group is not None else 0 for group in groups]
+        if any(later <= earlier for earlier, later in zip(counts, counts[1:])):
+            continue
+        kept = total = 0
+        for earlier, later in zip(groups, groups[1:]):
+            if earlier is None or later is None:
+                continue
+            alive: Set[int] = set(later.ids)
+            kept += sum(1 for gid in earlier.ids if gid in alive)
+            total += earlier.count
+        retained = kept / total if total else 0.0
+        if retained < min_retained:
+            continue
+        suspects.append(LeakSuspect(group=latest, counts=counts, retained=retained))
+    suspects.sort(key=lambda s: (-s.rate, -s.counts[-1], s.group.ids[0]))
+    return suspects
+
+
+def format_leak_report(suspects: List[LeakSuspect], *, samples: int, limit: int = MAX_SUSPECTS_SHOWN) -> str:
+    """Render ``detect_leak`` results for the prompt; names each spawning function and what likely stops it."""
+    if samples < MIN_SAMPLES:
+        return f"Goroutine leak check needs at least {MIN_SAMPLES} samples; have {samples}."
+    if not suspects:
+        return (
+            f"No goroutine leak: no stack grew in every one of {samples} samples while keeping its goroutines alive."
+        )
+    lines = [f"Goroutine leak suspected: {len(suspects)} stack(s) grew in every one of {samples} samples."]
+    for index, suspect in enumerate(suspects[:limit], start=1):
+        lines.append(f"{index}. {suspect.describe()}")
+    if len(suspects) > limit:
+        lines.append(f"... {len(suspects) - limit} more growing stack(s)")
+    top = suspects[0]
+    lines.append(
+        f"Name {top.spawner} as the function spawning the leaked goroutines and explain which cancellation, "
+        "channel close or WaitGroup.Done is missing."
+    )
+    return "\n".join(lines)
+
+
+__all__ = ["MIN_RETAINED", "MIN_SAMPLES", "LeakSuspect", "detect_leak", "format_leak_report"]
@@ -0,0 +1,179 @@
+"""Rule-based final reports for runs analyzed without an LLM (``dbgagent --no-llm``).
+
+Where no model can be reached, the deterministic analyzers still know what
+went wrong: the panic class and the frame that failed, the lock cycle, the
+starved channels, the crash signal, a goroutine stack that keeps growing.
+``offline_report`` turns those findings into sentences from fixed templates
+and writes them in the section format the agent prompt asks the LLM for
+(Analysis Summary, Diagnosis, Findings, Suggested Fixes, Next Steps), so
+``build_result`` reads it like any final report and every renderer's output
+keeps its shape whether or not an LLM was involved.
+"""
+from __future__ import annotations
+
+from typing import List, Optional, Sequence
+
+from .goroutines import GoroutineDump, looks_like_goroutine_dump, parse_goroutine_dump
+from .leak import LeakSuspect, detect_leak
+from .panic import (
+    PANIC_CONCURRENT_MAP,
+    PANIC_FATAL,
+    PANIC_INDEX,
+    PANIC_NIL_DEREF,
+    PANIC_RUNTIME_ERROR,
+    PANIC_UNRECOVERED,
+)
+from .result import (
+    ISSUE_CRASH,
+    ISSUE_DEADLOCK,
+    ISSUE_FATAL,
+    ISSUE_HANG,
+    ISSUE_PANIC,
+    AnalysisResult,
+    ResultFrame,
+    build_result,
+)
+
+OFFLINE_NOTE = "Generated offline from the deterministic analyzers; no LLM was consulted."
+
+# Diagnosis and fix per panic kind; {where} is "function (file:line)", {function} and {location} its parts.
+_PANIC_TEMPLATES = {
+    PANIC_NIL_DEREF: (
+        "The program panics on a nil pointer dereference in {where}.",
+        "Check for nil before use: make sure the pointer is set on every path that reaches {function}, "
+        "or return an error when it is not.",
+    ),
+    PANIC_INDEX: (
+        "The program panics on an index out of range in {where}.",
+        "Check the index against len() before indexing in {function}, and fix the bound or offset that produced it.",
+    ),
+    PANIC_CONCURRENT_MAP: (
+        "The program dies of concurrent map access: goroutines {ids} use the same map at once without "
+        "synchronization.",
+        "Guard the map with a sync.Mutex (or sync.RWMutex) held by every reader and writer, or use sync.Map; "
+        "confirm with go test -race.",
+    ),
+    PANIC_RUNTIME_ERROR: (
+        "The program panics on a runtime error in {where}.",
+        "Fix the operation at {location} that the runtime rejected; the panic message names it.",
+    ),
+    PANIC_UNRECOVERED: (
+        "{function} panics and nothing recovers it, so the program exits.",
+        "Return an error instead of panicking at {location}, or recover at the goroutine's entry point "
+        "if the panic is expected.",
+    ),
+    PANIC_FATAL: (
+        "The runtime aborted the program with a fatal error in {where}.",
+        "Fatal errors cannot be recovered; remove the condition the message describes at {location}.",
+    ),
+}
+_PANIC_DEFAULT = _PANIC_TEMPLATES[PANIC_RUNTIME_ERROR]
+_LOCK_CYCLE = (
+    "Deadlock from a lock-order inversion: goroutines {ids} each hold a lock the next one waits for, "
+    "so none of them can proceed."
+)
+_LOCK_CYCLE_FIX = "Acquire the locks in one global order in every goroutine, or release one before taking the next."
+_CHANNELS = "Deadlock on channels: goroutines {ids} block forever on channel operations no other goroutine completes."
+_CHANNELS_FIX = (
+    "Make sure every channel a goroutine waits on is eventually sent to or closed, "
+    "and give blocking selects a ctx.Done() case."
+)
+_CRASH = "The program crashed with {detail} in {where}."
+_CRASH_FIX = "Inspect the memory access at {location}: a nil, freed or out-of-bounds pointer is the usual cause."
+_HANG = (
+    "The program stopped making progress, but its goroutines show no lock cycle or starved channel; "
+    "it is most likely waiting on I/O, a timer or an external service."
+)
+_NOTHING = "The deterministic analyzers found no panic, deadlock, crash or goroutine leak in the debugger output."
+
+
+def _frame(result: AnalysisResult) -> Optional[ResultFrame]:
+    for p in result.participants:
+        if p.frame is not None:
+            return p.frame
+    return result.frames[0] if result.frames else None
+
+
+def _ids(result: AnalysisResult) -> str:
+    ids: List[int] = []
+    for p in result.participants:
+        if p.id not in ids:
+            ids.append(p.id)
+    return ", ".join(str(i) for i in ids) or "(unknown)"
+
+
+def _dumps(outputs: Sequence[str]) -> List[GoroutineDump]:
+    return [parse_goroutine_dump(text) for text in outputs if text and looks_like_goroutine_dump(text)]
+
+
+def offline_report(outputs: Sequence[str], *, hang: bool = False) -> str:
+    """Final report, in the agent's section format, written from the analyzers alone.
+
+    ``outputs`` are the session's debugger outputs, oldest first. The
+    goroutine dumps among them are also checked for a leak, which needs
+    ``leak.MIN_SAMPLES`` dumps of the same process.
+    """
+    result = build_result(outputs, hang=hang, source_radius=0)
+    leaks = detect_leak(_dumps(outputs))
+    frame = _frame(result)
+    fields = {
+        "where": f"{frame.function} ({frame.location})" if frame is not None else "an unknown frame",
+        "function": frame.function if frame is not None else "the failing function",
+        "location": frame.location if frame is not None else "the failing line",
+        "ids": _ids(result),
+        "detail": result.issue_detail,
+    }
+    templates: List[str] = []
+    if result.issue_type in (ISSUE_PANIC, ISSUE_FATAL):
+        diagnosis, fix = _PANIC_TEMPLATES.get(result.issue_detail, _PANIC_DEFAULT)
+        templates.append(fix)
+    elif result.issue_type == ISSUE_DEADLOCK and result.issue_detail == "lock-cycle":
+        diagnosis = _LOCK_CYCLE
+        # build_result already suggests the lock order the analyzers derived.
+        if not result.suggested_fixes:
+            templates.append(_LOCK_CYCLE_FIX)
+    elif result.issue_type == ISSUE_DEADLOCK:
+        diagnosis = _CHANNELS
+        templates.append(_CHANNELS_FIX)
+    elif result.issue_type == ISSUE_CRASH:
+        diagnosis = _CRASH
+        templates.append(_CRASH_FIX)
+    elif result.issue_type == ISSUE_HANG and not leaks:
+        diagnosis = _HANG
+    else:
+        diagnosis = "" if leaks else _NOTHING
+    text = diagnosis.format(**fields) if diagnosis else _leak_diagnosis(leaks[0])
+    fixes = [template.format(**fields) for template in templates]
+    fixes += [f"Stop the goroutines started by {s.spawner}: {s.likely_missing()}" for s in leaks]
+
+    lines = ["Analysis Summary:", f"- {result.summary or text}", f"- {OFFLINE_NOTE}", "", "Diagnosis:", f"- {text}"]
+    if leaks:
+        lines += ["", "Findings:"]
+        lines += ["- " + "; ".join(line.strip() for line in s.describe().splitlines()) for s in leaks]
+    if fixes:
+        lines += ["", "Suggested Fixes:", *(f"- {fix}" for fix in fixes)]
+    lines += ["", "Next Steps:", *(f"- {step}" for step in _next_steps(result, frame))]
+    return "\n".join(lines)
+
+
+def _leak_diagnosis(suspect: LeakSuspect) -> str:
+    where = suspect.blocked_in
+    blocked = f"{where.function} ({where.location})" if where is not None else "the same frame"
+    counts = " -> ".join(str(c) for c in suspect.counts)
+    return (
+        f"Goroutine leak: goroutines started by {suspect.spawner} pile up in {blocked} "
+        f"({counts} across the samples) and never exit."
+    )
+
+
+def _next_steps(result: AnalysisResult, frame: Optional[ResultFrame]) -> List[str]:
+    steps: List[str] = []
+    if frame is not None:
+        steps.append(f"Read {frame.location} and the values of its locals there.")
+    if len({p.id for p in result.participants}) > 1:
+        steps.append(f"Compare the stacks of goroutines {_ids(result)}.")
+    steps.append("Rerun without --no-llm where an LLM is reachable for a fuller explanation.")
+    return steps
+
+
+__all__ = ["OFFLINE_NOTE", "offline_report"]
@@ -0,0 +1,187 @@
+"""Classify Go panics and fatal runtime errors.
+
+Works on the trace the runtime prints when a program dies (``panic: ...`` or
+``fatal error: ...`` followed by a goroutine dump). The report names the
+panic class, the goroutine that failed, and the first frame outside the
+runtime and standard library, which is usually where the bug is. ``fatal
+error: concurrent map writes`` is reported together with every goroutine
+caught inside a map operation, because the racing writer matters as much as
+the one that tripped the check.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import List, Optional
+import re
+
+from dbgcopilot.utils.source import DEFAULT_CONTEXT_RADIUS, SourceCache, SourceContext
+
+from .goroutines import Frame, Goroutine, GoroutineDump, parse_goroutine_dump
+
+
+PANIC_NIL_DEREF = "nil-deref"
+PANIC_INDEX = "index-out-of-range"
+PANIC_CONCURRENT_MAP = "concurrent-map-access"
+PANIC_RUNTIME_ERROR = "runtime-error"
+PANIC_UNRECOVERED = "unrecovered-panic"
+PANIC_FATAL = "fatal-error"
+PANIC_UNKNOWN = "unknown"
+
+_KIND_LABELS = {
+    PANIC_NIL_DEREF: "nil pointer dereference",
+    PANIC_INDEX: "index out of range",
+    PANIC_CONCURRENT_MAP: "concurrent map access",
+    PANIC_RUNTIME_ERROR: "runtime error",
+    PANIC_UNRECOVERED: "unrecovered panic",
+    PANIC_FATAL: "fatal runtime error",
+    PANIC_UNKNOWN: "crash",
+}
+
+_PANIC_RE = re.compile(r"^panic:\s*(.*)$", re.MULTILINE)
+_FATAL_RE = re.compile(r"^fatal error:\s*(.*)$", re.MULTILINE)
+_SIGNAL_RE = re.compile(r"^\[signal\s+([^\]]+)\]", re.MULTILINE)
+_CONCURRENT_MAP_RE = re.compile(r"concurrent map (?:writes|read and map write|iteration and map write)")
+# Runtime helpers that show a goroutine was inside a map operation.
+_MAP_FRAME_RE = re.compile(r"^(?:runtime\.(?:map\w+|evacuate\w*|growWork\w*)|internal/runtime/maps\.)")
+
+
+def _new_participant_list() -> List[Goroutine]:
+    return []
+
+
+@dataclass
+class PanicReport:
+    kind: str
+    message: str = ""
+    signal: str = ""
+    goroutine: Optional[Goroutine] = None
+    culprit: Optional[Frame] = None
+    source: Optional[SourceContext] = None
+    participants: List[Goroutine] = field(default_factory=_new_participant_list)
+    dump: Optional[GoroutineDump] = None
+
+    @property
+    def label(self) -> str:
+        return _KIND_LABELS.get(self.kind, self.kind)
+
+    @property
+    def goroutine_id(self) -> Optional[int]:
+        return self.goroutine.id if self.goroutine is not None else None
+
+    @property
+    def multi_goroutine(self) -> bool:
+        """True when the failure involves more than the goroutine that died."""
+        return self.kind == PANIC_CONCURRENT_MAP
+
+    def describe(self) -> str:
+        where = f" in goroutine {self.goroutine_id}" if self.goroutine is not None else ""
+        lines = [f"Panic analysis: {self.label}{where}"]
+        if self.message:
+            lines.append(f"  message: {self.message}")
+        if self.signal:
+            lines.append(f"  signal: {self.signal}")
+        if self.goroutine is not None and self.goroutine.created_by is not None and self.goroutine.id != 1:
+            lines.append(f"  goroutine {self.goroutine.id} was started by {self.goroutine.created_by.function}")
+        if self.culprit is not None:
+            lines.append(f"  likely culprit: {self.culprit.function} at {self.culprit.location}")
+        elif self.goroutine is not None:
+            lines.append("  no frame outside the runtime/standard library was found")
+        if self.source is not None:
+            lines.extend(self.source.render())
+        if self.multi_goroutine:
+            lines.append(f"  goroutines inside map operations: {len(self.participants)}")
+            for g in self.participants:
+                top = g.top_user_frame()
+                at = f"{top.function} at {top.location}" if top is not None else "(runtime only)"
+                lines.append(f"  - goroutine {g.id} [{g.state}]: {at}")
+            if len(self.participants) < 2:
+                lines.append("  the other accessor already left the map; set GOTRACEBACK=all to capture it")
+        return "\n".join(lines)
+
+
+def looks_like_panic(text: str) -> bool:
+    """Quick check for a runtime panic or fatal error banner."""
+    if not text:
+        return False
+    return bool(_PANIC_RE.search(text) or _FATAL_RE.search(text))
+
+
+def _classify(message: str, fatal: bool) -> str:
+    if fatal:
+        return PANIC_CONCURRENT_MAP if _CONCURRENT_MAP_RE.search(message) else PANIC_FATAL
+    if message.startswith("runtime error:"):
+        if "nil pointer dereference" in message or "invalid memory address" in message:
+            return PANIC_NIL_DEREF
+        if "index out of range" in message or "slice bounds out of range" in message:
+            return PANIC_INDEX
+        return PANIC_RUNTIME_ERROR
+    return PANIC_UNRECOVERED if message else PANIC_UNKNOWN
+
+
+def _failing_goroutine(dump: GoroutineDump) -> Optional[Goroutine]:
+    # The runtime prints the goroutine that panicked first, marked [running].
+    for g in dump.goroutines:
+        if g.state == "running" or g.current:
+            return g
+    return None
+
+
+def classify_panic(
+    trace: str,
+    *,
+    sources: Optional[SourceCache] = None,
+    radius: int = DEFAULT_CONTEXT_RADIUS,
+) -> PanicReport:
+    """Build a ``PanicReport`` from a runtime panic or fatal error trace."""
+    text = (trace or "").replace("\r\n", "\n")
+    message = ""
+    fatal = False
+    m = _FATAL_RE.search(text)
+    p = _PANIC_RE.search(text)
+    # A fatal error can follow a panic (and vice versa); the first banner wins.
+    if m and (p is None or m.start() < p.start()):
+        message, fatal = m.group(1).strip(), True
+    elif p:
+        message = p.group(1).strip()
+    kind = _classify(message, fatal)
+
+    dump = parse_goroutine_dump(text)
+    report = PanicReport(kind=kind, message=message, dump=dump)
+    s = _SIGNAL_RE.search(text)
+    if s:
+        report.signal = s.group(1).strip()
+    report.goroutine = _failing_goroutine(dump)
+    if report.goroutine is not None:
+        report.culprit = report.goroutine.top_user_frame()
+
+    if kind == PANIC_CONCURRENT_MAP:
+        report.participants = [
+            g for g in dump.goroutines if any(_MAP_FRAME_RE.match(f.function) for f in g.frames)
+        ]
+        if report.goroutine is not None and report.goroutine not in report.participants:
+            report.participants.insert(0, report.goroutine)
+
+    if report.culprit is not None and report.culprit.line:
+        report.source = (sources or SourceCache()).context(report.culprit.file, report.culprit.line, radius)
+    return report
+
+
+def format_panic_report(report: Optional[PanicReport]) -> str:
+    if report is None or report.kind == PANIC_UNKNOWN:
+        return ""
+    return report.describe()
+
+
+__all__ = [
+    "PANIC_CONCURRENT_MAP",
+    "PANIC_FATAL",
+    "PANIC_INDEX",
+    "PANIC_NIL_DEREF",
+    "PANIC_RUNTIME_ERROR",
+    "PANIC_UNKNOWN",
+    "PANIC_UNRECOVERED",
+    "PanicReport",
+    "classify_panic",
+    "format_panic_report",
+    "looks_like_panic",
+]
@@ -0,0 +1,357 @@
+"""A fix as a unified diff: proposed by the LLM, checked against the sources on disk.
+
+``dbgagent --suggest-patch`` takes the diagnosis one step further. The
+source around every frame the result names (the goroutines of a lock
+cycle, the panicking frame) is shown to the model with line numbers, and
+the model answers with a unified diff against exactly those files.
+``check_patch`` applies the diff in memory: every hunk's context and
+removed lines must match the file as it is now, at the line the header
+gives or shifted by a few lines the way ``patch`` and ``git apply``
+allow. A hunk that does not match raises ``PatchError`` saying which line
+differs, which is what the retry prompt tells the model.
+
+Models miscount hunk lengths far more often than they misquote code, so
+the counts in ``@@`` headers are not trusted: hunks end at the next
+header, and the diff that is printed or applied is regenerated from the
+patched text, so it always applies to the files it was checked against.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from pathlib import Path, PurePosixPath
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+import difflib
+import os
+import re
+
+from dbgcopilot.utils.source import SourceCache
+
+from .result import AnalysisResult, ResultFrame
+
+# Lines of source shown before and after each frame's line.
+PATCH_CONTEXT_RADIUS = 15
+DEFAULT_PATCH_ATTEMPTS = 3
+# How far a hunk may have drifted from the line its header names.
+MAX_HUNK_OFFSET = 50
+
+_FENCE_RE = re.compile(r"^(`{3,}|~{3,})[ \t]*(?:diff|patch|udiff)?[ \t]*\n(.*?)^\1[ \t]*$", re.MULTILINE | re.DOTALL)
+_HUNK_RE = re.compile(r"^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@")
+_TIMESTAMP_RE = re.compile(r"\t.*$")
+
+
+class PatchError(ValueError):
+    """The model's diff is malformed or does not apply to the current sources."""
+
+
+def _new_list() -> List[Any]:
+    return []
+
+
+def _new_dict() -> Dict[str, Any]:
+    return {}
+
+
+@dataclass
+class SourceFile:
+    """One file shown to the model: how the diff names it, where it is on disk, and its lines."""
+
+    path: str
+    local: str
+    lines: List[str] = field(default_factory=_new_list)
+    # 1-based (first, last) line ranges shown in the prompt.
+    ranges: List[Tuple[int, int]] = field(default_factory=_new_list)
+
+    def render(self) -> List[str]:
+        width = len(str(max(last for _, last in self.ranges)))
+        out = [f"File: {self.path}"]
+        for idx, (first, last) in enumerate(self.ranges):
+            if idx:
+                out.append(f"{'':>{width}} | ...")
+            out += [f"{n:>{width}} | {self.lines[n - 1]}" for n in range(first, last + 1)]
+        return out
+
+
+@dataclass
+class Hunk:
+    old_start: int
+    # (" ", "-" or "+", text)
+    lines: List[Tuple[str, str]] = field(default_factory=_new_list)
+
+    @property
+    def old(self) -> List[str]:
+        return [text for op, text in self.lines if op != "+"]
+
+    @property
+    def new(self) -> List[str]:
+        return [text for op, text in self.lines if op != "-"]
+
+
+@dataclass
+class FilePatch:
+    path: str
+    hunks: List[Hunk] = field(default_factory=_new_list)
+
+
+@dataclass
+class Patch:
+    """A diff that applies: the regenerated ``diff`` and each changed file's new lines."""
+
+    diff: str
+    files: List[SourceFile] = field(default_factory=_new_list)
+    patched: Dict[str, List[str]] = field(default_factory=_new_dict)
+
+    def describe(self) -> str:
+        added = removed = 0
+        for line in self.diff.splitlines():
+            if line.startswith("+") and not line.startswith("+++"):
+                added += 1
+            elif line.startswith("-") and not line.startswith("---"):
+                removed += 1
+        names = ", ".join(f.path for f in self.files)
+        return f"{len(self.files)} file(s) changed ({names}): +{added} -{removed}"
+
+
+def _display_path(local: str) -> str:
+    """``local`` relative to the working directory when it is under it, the way diffs usually name files."""
+    try:
+        rel = os.path.relpath(local)
+    except ValueError:
+        return local
+    return local if rel.startswith("..") else PurePosixPath(Path(rel)).as_posix()
+
+
+def patch_sources(
+    result: AnalysisResult, cache: Optional[SourceCache] = None, radius: int = PATCH_CONTEXT_RADIUS
+) -> List[SourceFile]:
+    """The readable files of ``result``'s frames, with ``radius`` lines around each frame's line."""
+    cache = cache or SourceCache()
+    frames: List[ResultFrame] = [p.frame for p in result.participants if p.frame is not None] + result.frames
+    files: Dict[str, SourceFile] = {}
+    for frame in frames:
+        lines = cache.lines(frame.file) if frame.file and frame.line else None
+        if not lines or frame.line > len(lines):
+            continue
+        local = cache.path_map.to_local(frame.file)
+        source = files.setdefault(local, SourceFile(path=_display_path(local), local=local, lines=lines))
+        source.ranges.append((max(1, frame.line - radius), min(len(lines), frame.line + radius)))
+    for source in files.values():
+        merged: List[Tuple[int, int]] = []
+        for first, last in sorted(source.ranges):
+            if merged and first <= merged[-1][1] + 1:
+                merged[-1] = (merged[-1][0], max(merged[-1][1], last))
+            else:
+                merged.append((first, last))
+        source.ranges = merged
+    return list(files.values())
+
+
+def patch_prompt(result: AnalysisResult, sources: Sequence[SourceFile], *, previous: str = "", error: str = "") -> str:
+    """Ask for a unified diff fixing ``result``'s diagnosis; ``previous`` and ``error`` describe a rejected one."""
+    lines = [
+        "You are fixing a bug a debugging session diagnosed. Propose the smallest code change that fixes it.",
+        "",
+        f"Issue: {result.issue_type}" + (f" ({result.issue_detail})" if result.issue_detail else ""),
+    ]
+    if result.summary:
+        lines.append(f"Summary: {result.summary}")
+    if result.diagnosis:
+        lines.append(f"Diagnosis: {result.diagnosis}")
+    if result.suggested_fixes:
+        lines.append("Suggested fixes:")
+        lines += [f"- {fix.summary}" + (f": {fix.details}" if fix.details else "") for fix in result.suggested_fixes]
+    lines += ["", "Source (the numbers and '|' are not part of the file):"]
+    for source in sources:
+        lines += source.render() + [""]
+    lines.append(
+        "Reply with one unified diff inside a ```diff block and nothing else. Name each file with "
+        "'--- a/PATH' and '+++ b/PATH', PATH exactly as shown above, and give every hunk an '@@ -N,M +N,M @@' "
+        "header with the real line numbers. Only change the files shown; copy context and removed lines "
+        "exactly, tabs included."
+    )
+    if error:
+        lines += ["", "Your previous diff was rejected:", error, "", "Previous diff:", *_fenced(previous)]
+        lines.append("Reply with a corrected diff against the source above.")
+    return "\n".join(lines)
+
+
+def _fenced(text: str) -> List[str]:
+    return ["```diff", *text.rstrip("\n").splitlines(), "```"]
+
+
+def extract_diff(text: str) -> str:
+    """The diff in a model's answer: the first fenced block that looks like one, or the text from '--- ' on."""
+    for m in _FENCE_RE.finditer(text or ""):
+        body = m.group(2)
+        if re.search(r"^(?:---|\+\+\+|@@) ", body, re.MULTILINE):
+            return body
+    m = re.search(r"^(?:diff --git |--- )", text or "", re.MULTILINE)
+    return text[m.start() :] if m else ""
+
+
+def _header_path(line: str) -> str:
+    return _TIMESTAMP_RE.sub("", line[4:]).strip()
+
+
+def parse_patch(diff: str) -> List[FilePatch]:
+    """Files and hunks of a unified diff; PatchError when there are none or a hunk is outside a file."""
+    files: List[FilePatch] = []
+    hunk: Optional[Hunk] = None
+    lines = diff.splitlines()
+    idx = 0
+    while idx < len(lines):
+        line = lines[idx]
+        if line.startswith("--- ") and idx + 1 < len(lines) and lines[idx + 1].startswith("+++ "):
+            old, new = _header_path(line), _header_path(lines[idx + 1])
+            if new == "/dev/null" or old == "/dev/null":
+                created = new if old == "/dev/null" else old
+                raise PatchError(f"the diff creates or deletes {created}; edit the files shown")
+            files.append(FilePatch(path=new))
+            hunk = None
+            idx += 2
+            continue
+        m = _HUNK_RE.match(line)
+        if m:
+            if not files:
+                raise PatchError(f"hunk {line!r} comes before any '--- a/PATH' / '+++ b/PATH' file header")
+            hunk = Hunk(old_start=int(m.group(1)))
+            files[-1].hunks.append(hunk)
+        elif hunk is not None and line[:1] in (" ", "-", "+"):
+            hunk.lines.append((line[0], line[1:]))
+        elif hunk is not None and line == "":
+            # Blank context lines lose their leading space in many editors and models.
+            hunk.lines.append((" ", ""))
+        idx += 1
+    for file in files:
+        for h in file.hunks:
+            while h.lines and h.lines[-1] == (" ", ""):
+                h.lines.pop()
+    files = [f for f in files if any(h.lines for h in f.hunks)]
+    if not files:
+        raise PatchError("no unified diff with '@@' hunks was found in the answer")
+    return files
+
+
+def _resolve(path: str, sources: Sequence[SourceFile]) -> SourceFile:
+    candidates = [path]
+    if path[:2] in ("a/", "b/"):
+        candidates.append(path[2:])
+    for candidate in candidates:
+        candidate = candidate[2:] if candidate.startswith("./") else candidate
+        for source in sources:
+            if candidate in (source.path, source.local):
+                return source
+        suffix = [s for s in sources if s.local.endswith("/" + candidate)]
+        if len(suffix) == 1:
+            return suffix[0]
+    shown = ", ".join(s.path for s in sources)
+    raise PatchError(f"{path} is not one of the files shown ({shown})")
+
+
+def _matches(lines: List[str], old: List[str], at: int) -> bool:
+    return 0 <= at <= len(lines) - len(old) and lines[at : at + len(old)] == old
+
+
+def _locate(lines: List[str], hunk: Hunk, start: int, offset: int) -> Optional[int]:
+    old = hunk.old
+    # A pure insertion's header names the line it goes after.
+    want = hunk.old_start + offset - (1 if old else 0)
+    for delta in range(MAX_HUNK_OFFSET + 1):
+        for at in (want - delta, want + delta) if delta else (want,):
+            if at >= start and _matches(lines, old, at):
+                return at
+    return None
+
+
+def _mismatch(lines: List[str], hunk: Hunk, want: int, start: int) -> str:
+    """Where the hunk comes closest to matching near ``want``, the first line that differs."""
+    old = hunk.old
+    best, best_same = max(want, start), -1
+    for delta in range(MAX_HUNK_OFFSET + 1):
+        for at in (want - delta, want + delta) if delta else (want,):
+            if start <= at <= len(lines) - len(old):
+                same = sum(1 for n, text in enumerate(old) if lines[at + n] == text)
+                if same > best_same:
+                    best, best_same = at, same
+    for n, expected in enumerate(old):
+        actual = lines[best + n] if best + n < len(lines) else None
+        if actual != expected:
+            found = "the end of the file" if actual is None else repr(actual)
+            return f"line {best + n + 1} is {found}, the hunk expects {expected!r}"
+    return "it overlaps an earlier hunk"
+
+
+def _apply(source: SourceFile, hunks: List[Hunk]) -> List[str]:
+    lines, out = source.lines, []
+    pos = offset = 0
+    for number, hunk in enumerate(hunks, start=1):
+        at = _locate(lines, hunk, pos, offset)
+        if at is None:
+            why = _mismatch(lines, hunk, max(hunk.old_start + offset - 1, 0), pos)
+            raise PatchError(f"{source.path}: hunk {number} (@@ -{hunk.old_start} @@) does not apply: {why}")
+        out += lines[pos:at] + hunk.new
+        pos = at + len(hunk.old)
+        offset = at - hunk.old_start + (1 if hunk.old else 0)
+    return out + lines[pos:]
+
+
+def check_patch(answer: str, sources: Sequence[SourceFile]) -> Patch:
+    """Apply the diff in ``answer`` to ``sources`` in memory; PatchError says why it does not apply."""
+    diff = extract_diff(answer)
+    if not diff:
+        raise PatchError("the answer has no unified diff (no ```diff block or '--- a/PATH' header)")
+    changed: Dict[str, Tuple[SourceFile, List[Hunk]]] = {}
+    for file in parse_patch(diff):
+        source = _resolve(file.path, sources)
+        # The same file may come in several sections.
+        changed.setdefault(source.local, (source, []))[1].extend(h for h in file.hunks if h.lines)
+    patch = Patch(diff="")
+    chunks: List[str] = []
+    for source, hunks in changed.values():
+        hunks.sort(key=lambda h: h.old_start)
+        new = _apply(source, hunks)
+        if new == source.lines:
+            continue
+        old_name, new_name = (source.path, source.path) if os.path.isabs(source.path) else (
+            f"a/{source.path}",
+            f"b/{source.path}",
+        )
+        chunks += difflib.unified_diff(source.lines, new, old_name, new_name, lineterm="")
+        patch.files.append(source)
+        patch.patched[source.local] = new
+    if not patch.files:
+        raise PatchError("the diff changes nothing")
+    patch.diff = "\n".join(chunks) + "\n"
+    return patch
+
+
+def apply_patch(patch: Patch) -> List[str]:
+    """Write ``patch``'s files back to disk, keeping each file's line ending style; returns the paths written."""
+    written: List[str] = []
+    for source in patch.files:
+        path = Path(source.local)
+        text = path.read_text(encoding="utf-8")
+        if text.splitlines() != source.lines:
+            raise PatchError(f"{source.path} changed on disk since the patch was checked")
+        newline = "\r\n" if "\r\n" in text else "\n"
+        trailing = newline if text.endswith(("\n", "\r")) else ""
+        path.write_text(newline.join(patch.patched[source.local]) + trailing, encoding="utf-8", newline="")
+        written.append(source.path)
+    return written
+
+
+__all__ = [
+    "DEFAULT_PATCH_ATTEMPTS",
+    "MAX_HUNK_OFFSET",
+    "PATCH_CONTEXT_RADIUS",
+    "FilePatch",
+    "Hunk",
+    "Patch",
+    "PatchError",
+    "SourceFile",
+    "apply_patch",
+    "check_patch",
+    "extract_diff",
+    "parse_patch",
+    "patch_prompt",
+    "patch_sources",
+]
@@ -0,0 +1,159 @@
+"""Goroutines (or threads) the user pinned, which prompts always show in full.
+
+The grouping and the prompt budget decide what of a big dump the LLM sees,
+and sometimes they drop exactly the goroutine that matters. A pin names it
+instead: ``19`` pins goroutine 19, any other text pins every goroutine with
+a frame (or creation site) whose function or file:line contains it, such as
+``handleConn`` or ``server.go:88``. Pins live in the session config under
+``goroutine_pins`` as a comma-separated list (``/pin`` in the REPL,
+``dbgagent --pin``).
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Dict, List, Mapping, Optional, Sequence
+
+from .goroutines import Frame, Goroutine, GoroutineDump
+
+PINS_KEY = "goroutine_pins"
+# Pinned goroutines shown in full, and deep-loaded, per prompt.
+MAX_PINNED = 8
+
+
+@dataclass(frozen=True)
+class Pin:
+    # Goroutine id, or None for a stack substring pin.
+    goroutine_id: Optional[int] = None
+    text: str = ""
+
+    @classmethod
+    def parse(cls, spec: str) -> "Pin":
+        text = spec.strip()
+        if not text:
+            raise ValueError("A pin needs a goroutine id or a stack substring")
+        if "," in text:
+            raise ValueError(f"A pin cannot contain a comma: {text!r}")
+        if text.isdigit():
+            return cls(goroutine_id=int(text))
+        return cls(text=text)
+
+    def describe(self) -> str:
+        return f"goroutine {self.goroutine_id}" if self.goroutine_id is not None else f"stack contains {self.text!r}"
+
+    def __str__(self) -> str:
+        return str(self.goroutine_id) if self.goroutine_id is not None else self.text
+
+    def frame_index(self, g: Goroutine) -> Optional[int]:
+        """Index of the frame this pin selects in ``g``, or None when it does not match ``g``.
+
+        An id pin selects the top user frame (0 without one); a substring pin the first frame it matches,
+        or the top user frame when only the creation site matched.
+        """
+        if self.goroutine_id is not None:
+            return _top_user_index(g) if g.id == self.goroutine_id else None
+        needle = self.text.lower()
+        for index, frame in enumerate(g.frames):
+            if _matches(frame, needle):
+                return index
+        if g.created_by is not None and _matches(g.created_by, needle):
+            return _top_user_index(g)
+        return None
+
+
+def _matches(frame: Frame, needle: str) -> bool:
+    return needle in frame.function.lower() or needle in frame.location.lower()
+
+
+def _top_user_index(g: Goroutine) -> int:
+    for index, frame in enumerate(g.frames):
+        if not frame.is_runtime():
+            return index
+    return 0
+
+
+def pins_from_config(config: Optional[Mapping[str, str]]) -> List[Pin]:
+    raw = (config or {}).get(PINS_KEY) or ""
+    return [Pin.parse(part) for part in raw.split(",") if part.strip()]
+
+
+def pins_to_config(pins: Sequence[Pin]) -> str:
+    return ",".join(str(pin) for pin in pins)
+
+
+@dataclass
+class PinnedGoroutine:
+    goroutine: Goroutine
+    pin: Pin
+    # Frame whose locals are loaded in depth.
+    frame: int = 0
+
+
+def pinned_goroutines(dump: GoroutineDump, pins: Sequence[Pin], *, limit: int = MAX_PINNED) -> List[PinnedGoroutine]:
+    """Goroutines of ``dump`` selected by any of ``pins``, in pin order, each once."""
+    found: List[PinnedGoroutine] = []
+    seen = set()
+    for pin in pins:
+        for g in dump.goroutines:
+            if g.id in seen:
+                continue
+            index = pin.frame_index(g)
+            if index is not None:
+                found.append(PinnedGoroutine(goroutine=g, pin=pin, frame=index))
+                seen.add(g.id)
+    return found[:limit]
+
+
+def format_goroutine(g: Goroutine) -> str:
+    """Every frame of ``g`` with its arguments and location, as in the runtime's traceback."""
+    state = g.state or "unknown"
+    if g.wait_minutes is not None:
+        state += f", {g.wait_minutes} minutes"
+    lines = [f"goroutine {g.id} [{state}]:"]
+    for frame in g.frames:
+        lines.append(f"  {frame.function}({frame.args})")
+        if frame.file:
+            lines.append(f"      {frame.location}")
+    if g.created_by is not None:
+        lines.append(f"  created by {g.created_by.function} at {g.created_by.location}")
+    return "\n".join(lines)
+
+
+def format_pinned(
+    dump: GoroutineDump,
+    pinned: Sequence[PinnedGoroutine],
+    pins: Sequence[Pin],
+    *,
+    locals_text: Optional[Dict[int, str]] = None,
+) -> str:
+    """The prompt section for pinned goroutines; ``locals_text`` maps goroutine id to its deep-loaded locals."""
+    if not pins:
+        return ""
+    lines = ["Pinned goroutines (full detail; never trimmed):"]
+    for item in pinned:
+        g = item.goroutine
+        head, _, frames = format_goroutine(g).partition("\n")
+        lines.append(f"{head}  (pinned: {item.pin.describe()})")
+        if frames:
+            lines.append(frames)
+        loaded = (locals_text or {}).get(g.id)
+        if loaded is not None:
+            where = g.frames[item.frame].function if item.frame < len(g.frames) else f"frame {item.frame}"
+            lines.append(f"  Locals of frame {item.frame} ({where}), loaded in depth:")
+            lines.extend(f"    {line}" for line in (loaded.splitlines() or ["(none)"]))
+    for pin in pins:
+        if not any(pin.frame_index(g) is not None for g in dump.goroutines):
+            lines.append(f"Pin {pin.describe()} matched no goroutine in the latest dump.")
+    return "\n".join(lines)
+
+
+__all__ = [
+    "MAX_PINNED",
+    "PINS_KEY",
+    "Pin",
+    "PinnedGoroutine",
+    "format_goroutine",
+    "format_pinned",
+    "pinned_goroutines",
+    "pins_from_config",
+    "pins_to_config",
+]
@@ -0,0 +1,657 @@
+"""One structured result for a whole analysis, rendered as text, JSON or Markdown.
+
+``build_result`` runs the deterministic analyzers over the debugger output
+collected in a session and combines them with the LLM's final report.
+``render_text``, ``render_json`` and ``render_markdown`` all read the same
+``AnalysisResult``, so the terminal view, the machine-readable document and
+the incident write-up cannot drift.
+
+The LLM's primary ``diagnosis`` and its ``alternatives`` come with a
+``confidence`` score: how well the deterministic analyzers corroborate the
+diagnosis (the same issue class, the same panic kind, the goroutines and
+locks they found). A diagnosis nothing deterministic backs up scores
+``low`` and every renderer flags it.
+Bump ``SCHEMA_VERSION`` whenever a field is renamed or removed.
+"""
+from __future__ import annotations
+
+from dataclasses import asdict, dataclass, field
+from datetime import datetime, timezone
+from pathlib import PurePath
+from typing import Any, Dict, List, Optional, Sequence
+import json
+import re
+
+from dbgcopilot.utils.source import DEFAULT_CONTEXT_RADIUS, SourceCache
+
+from .channels import ChannelReport, detect_channel_deadlock, format_channel_report, runtime_deadlock_fired
+from .deadlock import DeadlockCycle, detect_deadlock, format_lock_orders, lock_order_fix
+from .frames import MAX_CONTEXT_FRAMES, extract_stack
+from .goroutines import Frame, Goroutine, looks_like_goroutine_dump, parse_goroutine_dump
+from .panic import PANIC_FATAL, PanicReport, classify_panic, looks_like_panic
+
+
+SCHEMA_VERSION = "1.0"
+
+ISSUE_PANIC = "panic"
+ISSUE_FATAL = "fatal-error"
+ISSUE_DEADLOCK = "deadlock"
+ISSUE_HANG = "hang"
+ISSUE_CRASH = "crash"
+ISSUE_UNKNOWN = "unknown"
+
+SEVERITY_NONE = "none"
+SEVERITY_INFO = "info"
+SEVERITY_WARNING = "warning"
+SEVERITY_CRITICAL = "critical"
+# Lowest first; compare with ``severity_rank``.
+SEVERITIES = (SEVERITY_NONE, SEVERITY_INFO, SEVERITY_WARNING, SEVERITY_CRITICAL)
+_CRITICAL_ISSUES = {ISSUE_PANIC, ISSUE_FATAL, ISSUE_DEADLOCK, ISSUE_CRASH}
+
+# Headings of the final report the agent prompt asks for.
+_SECTION_RE = re.compile(
+    r"^\s*(?:#+\s*)?(?:\*\*)?(Analysis Summary|Diagnosis|Alternative Hypotheses|Findings|Suggested Fixes|Next Steps)"
+    r"(?:\*\*)?\s*:?\s*(?:\*\*)?\s*$",
+    re.IGNORECASE | re.MULTILINE,
+)
+_BULLET_RE = re.compile(r"^\s*(?:[-*+]|\d+[.)])\s+")
+_SIGNAL_RE = re.compile(r"\b(SIGSEGV|SIGBUS|SIGABRT|SIGFPE|SIGILL|EXC_BAD_ACCESS)\b")
+# Fenced code block language and line comment per source suffix, for the Markdown report.
+_FENCE_LANGUAGES = {
+    ".go": ("go", "//"), ".c": ("c", "//"), ".h": ("c", "//"), ".cc": ("cpp", "//"), ".cpp": ("cpp", "//"),
+    ".hpp": ("cpp", "//"), ".rs": ("rust", "//"), ".java": ("java", "//"), ".py": ("python", "#"),
+}
+_ACTIVE_MARK = "<-- active line"
+
+CONFIDENCE_HIGH = "high"
+CONFIDENCE_MEDIUM = "medium"
+CONFIDENCE_LOW = "low"
+# Lowest score of each level, highest first.
+_CONFIDENCE_LEVELS = ((0.75, CONFIDENCE_HIGH), (0.45, CONFIDENCE_MEDIUM), (0.0, CONFIDENCE_LOW))
+# How a diagnosis names each issue class and analyzer refinement.
+_ISSUE_TERMS = {
+    ISSUE_DEADLOCK: r"deadlock|lock.order|lock cycle|circular wait|starv|all goroutines are asleep",
+    ISSUE_PANIC: r"panic",
+    ISSUE_FATAL: r"fatal error|concurrent map|data race",
+    ISSUE_CRASH: r"crash|segfault|segmentation|SIGSEGV|SIGBUS|SIGABRT|SIGFPE|SIGILL|access violation",
+    ISSUE_HANG: r"hang|hung|stuck|block|deadlock|livelock|spin|infinite loop|never return",
+}
+_DETAIL_TERMS = {
+    "nil-deref": r"\bnil\b|null pointer",
+    "index-out-of-range": r"index|out of range|bounds",
+    "concurrent-map-access": r"concurrent map|map (?:read|write)|data race|\brace\b",
+    "lock-cycle": r"lock|mutex",
+    "channel-starvation": r"chan",
+}
+# "hypothesis: rationale", also with a dash.
+_RATIONALE_RE = re.compile(r":\s+|\s+[—–-]\s+")
+# Alternatives the LLM writes when it has none.
+_NO_ALTERNATIVE_RE = re.compile(r"^(?:none|n/?a|no other (?:plausible )?(?:causes?|hypothes[ie]s))\.?$", re.IGNORECASE)
+
+
+def _new_list() -> List[Any]:
+    return []
+
+
+@dataclass
+class SourceLine:
+    line: int
+    text: str
+    active: bool = False
+
+
+@dataclass
+class ResultFrame:
+    function: str
+    file: str = ""
+    line: int = 0
+    source: List[SourceLine] = field(default_factory=_new_list)
+
+    @property
+    def location(self) -> str:
+        return f"{self.file}:{self.line}" if self.file and self.line else (self.file or self.function)
+
+
+@dataclass
+class Participant:
+    """A goroutine or thread involved in the issue."""
+
+    id: int
+    kind: str = "goroutine"
+    state: str = ""
+    role: str = ""
+    frame: Optional[ResultFrame] = None
+
+
+@dataclass
+class SuggestedFix:
+    summary: str
+    details: str = ""
+
+
+@dataclass
+class Hypothesis:
+    """A cause the LLM considered besides its diagnosis, and why it ranks lower."""
+
+    summary: str
+    rationale: str = ""
+
+
+@dataclass
+class AnalysisResult:
+    issue_type: str = ISSUE_UNKNOWN
+    # Analyzer-specific refinement, e.g. the panic kind ("nil-deref") or signal name.
+    issue_detail: str = ""
+    severity: str = SEVERITY_NONE
+    summary: str = ""
+    participants: List[Participant] = field(default_factory=_new_list)
+    frames: List[ResultFrame] = field(default_factory=_new_list)
+    findings: List[str] = field(default_factory=_new_list)
+    explanation: str = ""
+    suggested_fixes: List[SuggestedFix] = field(default_factory=_new_list)
+    next_steps: List[str] = field(default_factory=_new_list)
+    debugger: str = ""
+    program: str = ""
+    # The LLM's primary diagnosis and the other causes it considered.
+    diagnosis: str = ""
+    alternatives: List[Hypothesis] = field(default_factory=_new_list)
+    # 0..1, how well the analyzers corroborate the diagnosis; level "" when there is nothing to score.
+    confidence: float = 0.0
+    confidence_level: str = ""
+    confidence_reasons: List[str] = field(default_factory=_new_list)
+    # Unified diff implementing the fix (dbgagent --suggest-patch), checked to apply to the current sources.
+    patch: str = ""
+
+    @property
+    def low_confidence(self) -> bool:
+        return self.confidence_level == CONFIDENCE_LOW
+
+    def to_dict(self) -> Dict[str, Any]:
+        data = asdict(self)
+        return {"schema_version": SCHEMA_VERSION, **data}
+
+
+def severity_rank(severity: str) -> int:
+    try:
+        return SEVERITIES.index(severity)
+    except ValueError:
+        raise ValueError(f"Unknown severity {severity!r} (expected one of: {', '.join(SEVERITIES)})") from None
+
+
+def classify_severity(result: AnalysisResult) -> str:
+    """Critical for panics, crashes and deadlocks; warning for hangs and other analyzer findings.
+
+    Info when the program stopped somewhere the analyzers located but could not classify. Only
+    the analyzers count: every LLM and offline report has Suggested Fixes, clean runs included.
+    """
+    if result.issue_type in _CRITICAL_ISSUES:
+        return SEVERITY_CRITICAL
+    if result.issue_type == ISSUE_HANG or result.findings:
+        return SEVERITY_WARNING
+    if result.frames:
+        return SEVERITY_INFO
+    return SEVERITY_NONE
+
+
+def confidence_level(score: float) -> str:
+    for floor, level in _CONFIDENCE_LEVELS:
+        if score >= floor:
+            return level
+    return CONFIDENCE_LOW
+
+
+def score_confidence(result: AnalysisResult) -> None:
+    """Fill ``confidence*`` from how the analyzer outcome corroborates the diagnosis and explanation."""
+    text = "\n".join(part for part in (result.diagnosis, result.explanation) if part)
+    if not text.strip():
+        result.confidence, result.confidence_level, result.confidence_reasons = 0.0, "", []
+        return
+    reasons: List[str] = []
+    terms = _ISSUE_TERMS.get(result.issue_type)
+    detail_terms = _DETAIL_TERMS.get(result.issue_detail)
+    names_issue = terms is not None and bool(re.search(terms, text, re.IGNORECASE))
+    names_detail = detail_terms is not None and bool(re.search(detail_terms, text, re.IGNORECASE))
+    if terms is None:
+        score = 0.3
+        reasons.append("no deterministic analyzer corroborates the diagnosis")
+    elif names_issue or names_detail:
+        score = 0.45
+        if names_issue:
+            score += 0.15
+            reasons.append(f"the analyzers also found a {result.issue_type}")
+        if names_detail:
+            score += 0.2
+            reasons.append(f"the diagnosis names the {result.issue_detail} the analyzers classified")
+        named = _named_specifics(result, text)
+        if named:
+            score += 0.15
+            reasons.append("it names what the analyzers pinpointed: " + ", ".join(named))
+    else:
+        score = 0.15
+        reasons.append(f"the analyzers found a {result.issue_type}, which the diagnosis does not mention")
+    if result.alternatives:
+        score -= min(0.05 * len(result.alternatives), 0.15)
+        reasons.append(f"{len(result.alternatives)} alternative hypothesis(es) remain open")
+    result.confidence = round(min(max(score, 0.0), 1.0), 2)
+    result.confidence_level = confidence_level(result.confidence)
+    result.confidence_reasons = reasons
+
+
+def _named_specifics(result: AnalysisResult, text: str) -> List[str]:
+    """Goroutines and functions the analyzers reported that the LLM's text mentions as well."""
+    named: List[str] = []
+    for p in result.participants:
+        if re.search(rf"\b(?:goroutine|thread|G)\s*#?{p.id}\b", text, re.IGNORECASE):
+            named.append(f"{p.kind} {p.id}")
+    for frame in result.frames[:1] + [p.frame for p in result.participants if p.frame is not None]:
+        short = frame.function.rsplit(".", 1)[-1]
+        label = frame.function
+        if short and label not in named and re.search(rf"\b{re.escape(short)}\b", text):
+            named.append(label)
+    return named
+
+
+def _result_frame(frame: Frame, cache: SourceCache, radius: int) -> ResultFrame:
+    out = ResultFrame(function=frame.function, file=frame.file, line=frame.line)
+    if radius > 0 and frame.file and frame.line:
+        ctx = cache.context(frame.file, frame.line, radius)
+        if ctx.available:
+            out.source = [
+                SourceLine(line=ctx.start + idx, text=text, active=idx == ctx.active_index)
+                for idx, text in enumerate(ctx.lines)
+            ]
+    return out
+
+
+def _participant(g: Goroutine, role: str, cache: SourceCache, radius: int) -> Participant:
+    top = g.top_user_frame() or (g.frames[0] if g.frames else None)
+    return Participant(
+        id=g.id,
+        state=g.state,
+        role=role,
+        frame=_result_frame(top, cache, radius) if top is not None else None,
+    )
+
+
+def parse_report_sections(report: str) -> Dict[str, str]:
+    """Split an LLM final report into its mandated sections (lower-cased heading -> body)."""
+    sections: Dict[str, str] = {}
+    matches = list(_SECTION_RE.finditer(report or ""))
+    for idx, m in enumerate(matches):
+        end = matches[idx + 1].start() if idx + 1 < len(matches) else len(report)
+        sections[m.group(1).lower()] = report[m.end() : end].strip()
+    return sections
+
+
+def _bullets(text: str) -> List[str]:
+    items: List[str] = []
+    for line in (text or "").splitlines():
+        if not line.strip():
+            continue
+        if _BULLET_RE.match(line) or not items:
+            items.append(_BULLET_RE.sub("", line).strip())
+        else:
+            # Continuation of the previous bullet.
+            items[-1] += " " + line.strip()
+    return items
+
+
+def _hypotheses(text: str) -> List[Hypothesis]:
+    hypotheses: List[Hypothesis] = []
+    for item in _bullets(text):
+        if _NO_ALTERNATIVE_RE.match(item.strip("* ")):
+            continue
+        parts = _RATIONALE_RE.split(item, maxsplit=1)
+        head, rest = (parts[0], parts[1]) if len(parts) == 2 and len(parts[0]) <= 120 else (item, "")
+        hypotheses.append(Hypothesis(summary=head.strip("* "), rationale=rest.strip()))
+    return hypotheses
+
+
+def _fixes(text: str) -> List[SuggestedFix]:
+    fixes: List[SuggestedFix] = []
+    for item in _bullets(text):
+        head, sep, rest = item.partition(": ")
+        if sep and len(head) <= 80:
+            fixes.append(SuggestedFix(summary=head.strip("* "), details=rest.strip()))
+        else:
+            fixes.append(SuggestedFix(summary=item))
+    return fixes
+
+
+def _apply_panic(result: AnalysisResult, report: PanicReport, cache: SourceCache, radius: int) -> None:
+    result.issue_type = ISSUE_FATAL if report.kind == PANIC_FATAL else ISSUE_PANIC
+    result.issue_detail = report.kind
+    result.summary = report.label + (f": {report.message}" if report.message else "")
+    result.findings.append(report.describe())
+    if report.goroutine is not None:
+        result.participants.append(_participant(report.goroutine, "panicking", cache, radius))
+    for g in report.participants:
+        if report.goroutine is None or g.id != report.goroutine.id:
+            result.participants.append(_participant(g, "map accessor", cache, radius))
+    if report.goroutine is not None:
+        result.frames = [_result_frame(f, cache, radius) for f in report.goroutine.frames[:MAX_CONTEXT_FRAMES]]
+
+
+def _apply_deadlock(
+    result: AnalysisResult,
+    cycles: List[DeadlockCycle],
+    goroutines: Dict[int, Goroutine],
+    cache: SourceCache,
+    radius: int,
+) -> None:
+    result.issue_type = ISSUE_DEADLOCK
+    result.issue_detail = "lock-cycle"
+    result.summary = f"{len(cycles)} lock cycle(s) between goroutines " + ", ".join(
+        str(gid) for gid in cycles[0].goroutine_ids
+    )
+    for cycle in cycles:
+        result.findings.append(cycle.describe())
+        table = format_lock_orders(cycle)
+        if table:
+            result.findings.append(table)
+        fix = lock_order_fix(cycle)
+        if fix is not None:
+            result.suggested_fixes.append(SuggestedFix(summary=fix[0], details=fix[1]))
+        for wait in cycle.waits:
+            g = goroutines.get(wait.goroutine_id)
+            frame = _result_frame(wait.waiting_at, cache, radius) if wait.waiting_at is not None else None
+            result.participants.append(
+                Participant(
+                    id=wait.goroutine_id,
+                    state=g.state if g is not None else "",
+                    role=f"waits for {wait.lock.label}",
+                    frame=frame,
+                )
+            )
+
+
+def _apply_channel_deadlock(
+    result: AnalysisResult,
+    report: ChannelReport,
+    goroutines: Dict[int, Goroutine],
+    cache: SourceCache,
+    radius: int,
+) -> None:
+    result.issue_type = ISSUE_DEADLOCK
+    result.issue_detail = "channel-starvation"
+    names = [e.channel.label for e in report.starved]
+    if names:
+        result.summary = "Goroutines starved on channel(s) " + ", ".join(names)
+    elif not report.forever:
+        result.summary = f"{len(report.waits)} goroutine(s) blocked on channel operations nothing can complete"
+    else:
+        result.summary = f"{len(report.forever)} goroutine(s) blocked forever on nil channels or select {{}}"
+    if report.runtime_deadlock:
+        result.summary += " (the runtime reported all goroutines asleep)"
+    result.findings.append(format_channel_report(report))
+    for wait in report.waits:
+        g = goroutines.get(wait.goroutine_id)
+        channels = ", ".join(op.channel.label for op in wait.ops) or "a channel"
+        frame = _result_frame(wait.waiting_at, cache, radius) if wait.waiting_at is not None else None
+        result.participants.append(
+            Participant(
+                id=wait.goroutine_id,
+                state=g.state if g is not None else "",
+                role=f"blocked in {wait.kind} on {channels}",
+                frame=frame,
+            )
+        )
+
+
+def build_result(
+    outputs: Sequence[str],
+    *,
+    report: str = "",
+    source_radius: int = DEFAULT_CONTEXT_RADIUS,
+    debugger: str = "",
+    program: str = "",
+    hang: bool = False,
+) -> AnalysisResult:
+    """Analyze the session's debugger ``outputs`` (oldest first) and attach the LLM ``report``.
+
+    The most recent output that shows a panic, a deadlock or a stack wins;
+    ``hang`` marks a run that was paused by hang detection. Channel
+    starvation counts as a deadlock only when the runtime's own detector
+    fired or the run hung, since a blocked channel is otherwise normal.
+    """
+    cache = SourceCache()
+    result = AnalysisResult(debugger=debugger, program=program)
+    for text in reversed([o for o in outputs if o]):
+        # "all goroutines are asleep" is a fatal error too, but the dump after it says why.
+        panicked = looks_like_panic(text)
+        if panicked and not runtime_deadlock_fired(text):
+            _apply_panic(result, classify_panic(text, sources=cache, radius=source_radius), cache, source_radius)
+            break
+        if looks_like_goroutine_dump(text):
+            dump = parse_goroutine_dump(text)
+            goroutines = {g.id: g for g in dump.goroutines}
+            cycles = detect_deadlock(dump, cache)
+            if cycles:
+                _apply_deadlock(result, cycles, goroutines, cache, source_radius)
+                break
+            channels = detect_channel_deadlock(dump, cache)
+            if channels.found and (channels.runtime_deadlock or hang):
+                _apply_channel_deadlock(result, channels, goroutines, cache, source_radius)
+                break
+        if panicked:
+            _apply_panic(result, classify_panic(text, sources=cache, radius=source_radius), cache, source_radius)
+            break
+        frames = extract_stack(text)
+        if frames and not result.frames:
+            result.frames = [_result_frame(f, cache, source_radius) for f in frames[:MAX_CONTEXT_FRAMES]]
+            signal = _SIGNAL_RE.search(text)
+            if signal:
+                result.issue_type = ISSUE_CRASH
+                result.issue_detail = signal.group(1)
+                result.summary = f"Crashed with {signal.group(1)} in {frames[0].function}"
+    if hang and result.issue_type == ISSUE_UNKNOWN:
+        result.issue_type = ISSUE_HANG
+        result.summary = result.summary or "The program stopped making progress"
+    sections = parse_report_sections(report)
+    if sections:
+        result.explanation = "\n\n".join(
+            s for s in (sections.get("analysis summary", ""), sections.get("findings", "")) if s
+        )
+        # After any fix the analyzers derived (the lock order for a cycle).
+        result.suggested_fixes += _fixes(sections.get("suggested fixes", ""))
+        result.next_steps = _bullets(sections.get("next steps", ""))
+        # Without a Diagnosis section, the summary's first point is the diagnosis.
+        result.diagnosis = " ".join(_bullets(sections.get("diagnosis", ""))) or _first_point(
+            sections.get("analysis summary", "")
+        )
+        result.alternatives = _hypotheses(sections.get("alternative hypotheses", ""))
+    else:
+        result.explanation = (report or "").strip()
+        result.diagnosis = _first_point(result.explanation)
+    result.severity = classify_severity(result)
+    score_confidence(result)
+    return result
+
+
+def _first_point(text: str) -> str:
+    points = _bullets(text)
+    return points[0] if points else ""
+
+
+def render_json(result: AnalysisResult, *, indent: Optional[int] = 2) -> str:
+    return json.dumps(result.to_dict(), indent=indent, ensure_ascii=False)
+
+
+def confidence_warning(result: AnalysisResult) -> str:
+    """One line flagging a low-confidence diagnosis ("" otherwise), shown before anything else."""
+    if not result.low_confidence:
+        return ""
+    why = "; ".join(result.confidence_reasons)
+    return f"WARNING: low confidence ({result.confidence:.2f}): {why}. Verify the diagnosis before acting on it."
+
+
+def _confidence_line(result: AnalysisResult) -> str:
+    return f"{result.confidence_level} ({result.confidence:.2f}): " + "; ".join(result.confidence_reasons)
+
+
+def render_text(result: AnalysisResult) -> str:
+    lines = [confidence_warning(result)] if result.low_confidence else []
+    lines.append(f"Issue: {result.issue_type}" + (f" ({result.issue_detail})" if result.issue_detail else ""))
+    lines.append(f"Severity: {result.severity}")
+    if result.summary:
+        lines.append(f"Summary: {result.summary}")
+    if result.diagnosis:
+        lines.append(f"Diagnosis: {result.diagnosis}")
+    if result.confidence_level:
+        lines.append(f"Confidence: {_confidence_line(result)}")
+    if result.participants:
+        lines.append("Participants:")
+        for p in result.participants:
+            where = f" at {p.frame.function} {p.frame.location}" if p.frame is not None else ""
+            state = f" [{p.state}]" if p.state else ""
+            role = f" {p.role}" if p.role else ""
+            lines.append(f"  {p.kind} {p.id}{state}{role}{where}")
+    if result.frames:
+        lines.append("Frames:")
+        for idx, frame in enumerate(result.frames):
+            lines.append(f"  #{idx} {frame.function} at {frame.location}")
+            width = len(str(frame.source[-1].line)) if frame.source else 0
+            for src in frame.source:
+                lines.append(f"    {'>' if src.active else ' '} {src.line:>{width}} | {src.text}")
+    if result.findings:
+        lines.append("Findings:")
+        lines.extend(f"  - {f}" for f in result.findings)
+    if result.explanation:
+        lines.append("Explanation:")
+        lines.extend(f"  {line}" if line else "" for line in result.explanation.splitlines())
+    if result.alternatives:
+        lines.append("Alternative hypotheses:")
+        for alt in result.alternatives:
+            lines.append(f"  - {alt.summary}" + (f": {alt.rationale}" if alt.rationale else ""))
+    if result.suggested_fixes:
+        lines.append("Suggested fixes:")
+        for fix in result.suggested_fixes:
+            lines.append(f"  - {fix.summary}" + (f": {fix.details}" if fix.details else ""))
+    if result.patch:
+        lines.append("Suggested patch:")
+        lines.extend(f"  {line}" for line in result.patch.splitlines())
+    if result.next_steps:
+        lines.append("Next steps:")
+        lines.extend(f"  - {step}" for step in result.next_steps)
+    return "\n".join(lines)
+
+
+def _md_cell(text: str) -> str:
+    # GFM splits table cells on "|" even inside code spans.
+    return (text or "").replace("|", "\\|").replace("\n", " ") or " "
+
+
+def _md_code(text: str) -> str:
+    """Inline code that survives backticks inside ``text``."""
+    ticks = "`" * (max((len(run) for run in re.findall(r"`+", text)), default=0) + 1)
+    pad = " " if text.startswith("`") or text.endswith("`") else ""
+    return f"{ticks}{pad}{text}{pad}{ticks}"
+
+
+def _md_fenced(body: List[str], language: str = "") -> List[str]:
+    """A fenced code block longer than any backtick run in ``body``."""
+    fence = "`" * max(3, max((len(run) + 1 for line in body for run in re.findall(r"`+", line)), default=0))
+    return [f"{fence}{language}", *body, fence]
+
+
+def _md_snippet(frame: ResultFrame) -> List[str]:
+    language, comment = _FENCE_LANGUAGES.get(PurePath(frame.file).suffix.lower(), ("", "//"))
+    body = [f"{src.text}  {comment} {_ACTIVE_MARK}" if src.active else src.text for src in frame.source]
+    heading = f"**{_md_code(frame.function)}** at {_md_code(frame.location)} (from line {frame.source[0].line}):"
+    return [heading, "", *_md_fenced(body, language)]
+
+
+def render_markdown(result: AnalysisResult, *, generated: Optional[datetime] = None) -> str:
+    """GitHub-flavored Markdown write-up of ``result`` for sharing in incident reports."""
+    when = (generated or datetime.now(timezone.utc)).astimezone(timezone.utc)
+    binary = PurePath(result.program).name if result.program else "(unknown program)"
+    issue = result.issue_type + (f" ({result.issue_detail})" if result.issue_detail else "")
+    lines = [
+        f"# Analysis report: {binary}",
+        "",
+        "| Field | Value |",
+        "| --- | --- |",
+        f"| Binary | {_md_cell(_md_code(result.program or binary))} |",
+        f"| Generated | {when.strftime('%Y-%m-%d %H:%M:%S UTC')} |",
+        f"| Issue | {_md_cell(issue)} |",
+        f"| Severity | {_md_cell(result.severity)} |",
+    ]
+    if result.confidence_level:
+        lines.append(f"| Confidence | {_md_cell(f'{result.confidence_level} ({result.confidence:.2f})')} |")
+    if result.debugger:
+        lines.append(f"| Debugger | {_md_cell(result.debugger)} |")
+    if result.low_confidence:
+        lines += ["", f"> **{confidence_warning(result)}**"]
+    lines += ["", "## Summary", "", result.summary or "No issue was identified by the analyzers."]
+    if result.diagnosis:
+        lines += ["", "## Diagnosis", "", result.diagnosis, "", f"Confidence: {_confidence_line(result)}"]
+    if result.alternatives:
+        lines += ["", "## Alternative hypotheses", ""]
+        for alt in result.alternatives:
+            lines.append(f"- **{alt.summary}**" + (f": {alt.rationale}" if alt.rationale else ""))
+    if result.explanation:
+        lines += ["", "## Analysis", "", result.explanation.strip()]
+    if result.participants:
+        lines += ["", "## Goroutines involved", ""]
+        kinds = {p.kind for p in result.participants}
+        lines.append(f"| {'Goroutine' if kinds == {'goroutine'} else 'ID'} | State | Role | Location |")
+        lines.append("| ---: | --- | --- | --- |")
+        for p in result.participants:
+            where = f"{_md_code(p.frame.function)} at {_md_code(p.frame.location)}" if p.frame is not None else ""
+            ident = str(p.id) if kinds == {"goroutine"} else f"{p.kind} {p.id}"
+            lines.append(f"| {ident} | {_md_cell(p.state)} | {_md_cell(p.role)} | {_md_cell(where)} |")
+    if result.findings:
+        # Analyzer reports are preformatted (indented source, aligned columns), so keep them verbatim.
+        lines += ["", "## Findings"]
+        for finding in result.findings:
+            lines += ["", *_md_fenced(finding.splitlines(), "text")]
+    snippets: List[ResultFrame] = []
+    seen = set()
+    for frame in result.frames + [p.frame for p in result.participants if p.frame is not None]:
+        key = (frame.file, frame.line)
+        if frame.source and key not in seen:
+            seen.add(key)
+            snippets.append(frame)
+    if snippets:
+        lines += ["", "## Source", ""]
+        for idx, frame in enumerate(snippets):
+            if idx:
+                lines.append("")
+            lines += _md_snippet(frame)
+    lines += ["", "## Suggested fix", ""]
+    if result.suggested_fixes:
+        for fix in result.suggested_fixes:
+            lines.append(f"- **{fix.summary}**" + (f": {fix.details}" if fix.details else ""))
+    else:
+        lines.append("No fix was suggested.")
+    if result.patch:
+        lines += ["", *_md_fenced(result.patch.splitlines(), "diff")]
+    if result.next_steps:
+        lines += ["", "## Next steps", ""]
+        lines.extend(f"{n}. {step}" for n, step in enumerate(result.next_steps, 1))
+    return "\n".join(lines) + "\n"
+
+
+__all__ = [
+    "AnalysisResult",
+    "CONFIDENCE_HIGH",
+    "CONFIDENCE_LOW",
+    "CONFIDENCE_MEDIUM",
+    "Hypothesis",
+    "Participant",
+    "ResultFrame",
+    "SCHEMA_VERSION",
+    "SEVERITIES",
+    "SourceLine",
+    "SuggestedFix",
+    "build_result",
+    "classify_severity",
+    "confidence_level",
+    "confidence_warning",
+    "parse_report_sections",
+    "render_json",
+    "render_markdown",
+    "render_text",
+    "score_confidence",
+    "severity_rank",
+]
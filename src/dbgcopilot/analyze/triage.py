@@ -0,0 +1,123 @@
+"""Local triage of a finished run: is there anything for the LLM to analyze?
+
+Most CI runs are green. Before the first LLM call, dbgagent looks at how the
+program's run ended and only asks the LLM about a run that shows an anomaly:
+
+- exit status: the program exited on its own with status 0 (a stop at a
+  signal, exception or breakpoint, or a non-zero status, is an anomaly);
+- panic: no Go panic or fatal error banner, and no crash signal, in the
+  debugger output or in what the program printed;
+- hang: hang detection did not pause it and the runtime did not report all
+  goroutines asleep.
+
+``triage_run`` checks all three and keeps the evidence for each, so a run
+that is skipped can say why. A run without a known outcome (a core dump, an
+attached process, a debugger that does not report one) is not triaged.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import List, Optional, Sequence
+import re
+
+from .channels import runtime_deadlock_fired
+from .panic import looks_like_panic
+
+CHECK_EXIT = "exit status"
+CHECK_PANIC = "panic"
+CHECK_HANG = "hang"
+
+_SIGNAL_RE = re.compile(r"\b(SIGSEGV|SIGBUS|SIGABRT|SIGFPE|SIGILL|EXC_BAD_ACCESS)\b")
+
+
+@dataclass
+class TriageCheck:
+    name: str
+    passed: bool
+    # What was looked at, e.g. "exited with status 0".
+    evidence: str
+
+
+def _new_checks() -> List[TriageCheck]:
+    return []
+
+
+@dataclass
+class Triage:
+    checks: List[TriageCheck] = field(default_factory=_new_checks)
+
+    @property
+    def anomaly(self) -> bool:
+        return not all(check.passed for check in self.checks)
+
+    def describe(self) -> str:
+        head = "Anomaly detected" if self.anomaly else "No issues detected"
+        return head + ": " + "; ".join(check.evidence for check in self.checks)
+
+    def report(self) -> str:
+        """Final report, in the agent's section format, for a run the LLM was not asked about."""
+        lines = ["Analysis Summary:", "- No issues detected by local triage; the LLM was not consulted."]
+        lines += ["", "Findings:"]
+        lines += [f"- {check.name}: {check.evidence}" for check in self.checks]
+        lines += ["", "Next Steps:", "- Rerun with --force-analyze to ask the LLM anyway."]
+        return "\n".join(lines)
+
+
+def triage_run(
+    outputs: Sequence[str],
+    *,
+    exited: bool,
+    exit_code: Optional[int] = None,
+    stop_reason: str = "",
+    program_output: str = "",
+    hung: bool = False,
+    idle_seconds: float = 0.0,
+    hang_timeout: Optional[float] = None,
+    runtime_deadlock: bool = False,
+) -> Triage:
+    """Check how a run ended; ``outputs`` is the debugger output, ``program_output`` the target's own.
+
+    ``hang_timeout`` is the idle time hang detection allowed, when it watched the run.
+    """
+    triage = Triage()
+    if exited and exit_code == 0:
+        triage.checks.append(TriageCheck(CHECK_EXIT, True, "exited with status 0"))
+    elif exited:
+        triage.checks.append(TriageCheck(CHECK_EXIT, False, f"exited with status {exit_code}"))
+    else:
+        triage.checks.append(TriageCheck(CHECK_EXIT, False, f"did not exit (stopped: {stop_reason or 'unknown'})"))
+
+    texts = [text for text in (*outputs, program_output) if text]
+    searched = sum(len(text.encode("utf-8", "replace")) for text in texts)
+    banner = _first_banner(texts)
+    if banner:
+        triage.checks.append(TriageCheck(CHECK_PANIC, False, f"found {banner!r}"))
+    else:
+        triage.checks.append(
+            TriageCheck(CHECK_PANIC, True, f"no panic, fatal error or crash signal in {searched} byte(s) of output")
+        )
+
+    if hung:
+        triage.checks.append(TriageCheck(CHECK_HANG, False, f"no progress for {idle_seconds:.1f}s"))
+    elif runtime_deadlock or any(runtime_deadlock_fired(text) for text in texts):
+        triage.checks.append(TriageCheck(CHECK_HANG, False, "the runtime reported all goroutines asleep"))
+    else:
+        watched = f"no stall longer than {hang_timeout:g}s" if hang_timeout else "stopped on its own"
+        triage.checks.append(TriageCheck(CHECK_HANG, True, watched))
+    return triage
+
+
+def _first_banner(texts: Sequence[str]) -> str:
+    for text in texts:
+        if looks_like_panic(text):
+            for line in text.splitlines():
+                if line.lstrip().startswith(("panic:", "fatal error:")):
+                    return line.strip()
+            return "panic"
+        signal = _SIGNAL_RE.search(text)
+        if signal:
+            return signal.group(1)
+    return ""
+
+
+__all__ = ["CHECK_EXIT", "CHECK_HANG", "CHECK_PANIC", "Triage", "TriageCheck", "triage_run"]
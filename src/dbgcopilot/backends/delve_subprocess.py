@@ -3,18 +3,32 @@
 Launches a `dlv exec <binary>` session in interactive mode and proxies
 commands via a pseudo-tty. Requires the target binary up-front so Delve
 can attach immediately.
+
+Every wait for the prompt is bounded by the session's ``Context``: when it
+is cancelled (Ctrl-C, ``--timeout``) Delve is interrupted from whichever
+thread cancelled, the blocked command returns, and ``abort()`` then ends the
+session without leaving dlv or the launched program behind.
 """
 from __future__ import annotations
 
-from typing import Optional, List, Any
+from typing import Optional, List, Any, Dict, Mapping, Sequence
 import os
 import re
+import shutil
+import signal
+import time
+
+from dbgcopilot.utils.context import Context, current
+from dbgcopilot.utils.log import trace_debugger
 
 try:
     import pexpect  # type: ignore
 except Exception:  # pragma: no cover - import error surfaced at runtime
     pexpect = None  # type: ignore
 
+# Variables dlv needs from our environment when the program is given one of its own.
+DELVE_ENV = ("HOME", "PATH", "TERM", "TMPDIR", "XDG_CONFIG_HOME")
+
 
 class DelveSubprocessBackend:
     """Minimal Delve CLI wrapper driven through a pexpect session."""
@@ -29,17 +43,28 @@ class DelveSubprocessBackend:
         delve_path: str = "dlv",
         timeout: float = 15.0,
         working_dir: Optional[str] = None,
+        context: Optional[Context] = None,
+        args: Sequence[str] = (),
+        env: Optional[Mapping[str, str]] = None,
     ) -> None:
         if not program:
             raise ValueError("Delve backend requires a program path")
         self.delve_path = delve_path
         self.program = program
+        # Passed to the program after "--"; ``env`` is the program's whole environment (None: ours).
+        self.args = list(args)
+        self.env = dict(env) if env is not None else None
         self.timeout = timeout
         self.working_dir = working_dir or os.getcwd()
         self.child: Optional[Any] = None
         self.prompt = "(dlv) "
         self._prompt_re = re.compile(r"\(dlv\)\s")
         self._startup_output: str = ""
+        # Bounds every prompt wait; defaults to the context active where the backend is created.
+        self.context = context or current()
+        self._unregister_cancel: Optional[Any] = None
+        # True while a command (or the startup) has not yet returned to the prompt.
+        self._waiting = False
 
     @property
     def startup_output(self) -> str:
@@ -47,21 +72,34 @@ class DelveSubprocessBackend:
         return self._startup_output
 
     def initialize_session(self) -> None:
+        # Imported here: dbgcopilot.debugger imports this module.
+        from dbgcopilot.debugger.base import DebuggerUnavailable
+
         if pexpect is None:
-            raise RuntimeError("pexpect is required to use the Delve backend")
-        args = ["exec", self.program]
-        self.child = pexpect.spawn(
-            self.delve_path,
-            args,
-            cwd=self.working_dir,
-            encoding="utf-8",
-            timeout=self.timeout,
-        )
+            raise DebuggerUnavailable("pexpect is required to use the Delve backend")
         try:
-            banner = self._expect_prompt()
+            self.child = pexpect.spawn(
+                # Found on our PATH, which an environment of the program's own may not have.
+                shutil.which(self.delve_path) or self.delve_path,
+                self._launch_args(),
+                cwd=self.working_dir,
+                env=self._delve_env(),  # type: ignore[arg-type]
+                encoding="utf-8",
+                timeout=self.timeout,
+            )
+        except pexpect.ExceptionPexpect as exc:  # type: ignore[union-attr]
+            raise DebuggerUnavailable(f"Delve failed to start: {exc}") from exc
+        if self._unregister_cancel is None:
+            self._unregister_cancel = self.context.on_cancel(self._interrupt_for_cancel)
+        self._waiting = True
+        try:
+            # dlv attach and dlv core can take long on big processes; the context bounds them too.
+            banner = self._expect_prompt(self.context.timeout(self.timeout))
         except (pexpect.EOF, pexpect.TIMEOUT) as exc:  # type: ignore[arg-type]
             message = self._format_startup_error(exc)
-            raise RuntimeError(message)
+            self.context.check()
+            raise DebuggerUnavailable(message) from exc
+        self._waiting = False
         self._startup_output = banner.strip()
 
     def run_command(self, cmd: str, timeout: float | None = None) -> str:
@@ -79,35 +117,58 @@ class DelveSubprocessBackend:
                 break
             try:
                 out = self._send_and_capture(part, timeout=timeout)
+                # The command may have returned only because the cancellation interrupted it.
+                self.context.check()
             except Exception as exc:
+                # A wait cut short by cancellation is not a command failure.
+                self.context.check()
                 outputs.append(f"[delve error] {part}: {exc}")
                 continue
             outputs.append(out)
         return "\n".join(chunk for chunk in outputs if chunk)
 
     # Internal helpers -------------------------------------------------
+    def _delve_env(self) -> Optional[Dict[str, str]]:
+        """dlv's environment: the program's, plus what dlv itself needs to start that the program's lacks.
+
+        Delve launches the program with its own environment and has no option to give it another, so
+        with ``--no-inherit-env`` these few variables (home, path, terminal, temp dir) reach it too.
+        """
+        if self.env is None:
+            return None
+        env = {name: os.environ[name] for name in DELVE_ENV if name in os.environ}
+        env.update(self.env)
+        return env
+
+    def _launch_args(self) -> List[str]:
+        return ["exec", self.program] + (["--", *self.args] if self.args else [])
+
     def _split_commands(self, text: str) -> List[str]:
         pieces: List[str] = []
         for segment in text.replace("\r", "\n").split("\n"):
             pieces.extend([p.strip() for p in segment.split(";") if p.strip()])
         return pieces or [text]
 
-    def _expect_prompt(self) -> str:
+    def _expect_prompt(self, timeout: Optional[float] = None) -> str:
         if self.child is None:
             raise RuntimeError("Delve subprocess is not running")
-        self.child.expect(self._prompt_re)
+        self.child.expect(self._prompt_re, timeout=timeout)
         return self.child.before or ""
 
     def _send_and_capture(self, cmd: str, timeout: Optional[float] = None) -> str:
         if self.child is None:
             raise RuntimeError("Delve subprocess is not running")
         child: Any = self.child
+        self.context.check()
+        trace_debugger(self.name, "request", cmd=cmd)
+        start = time.monotonic()
         child.sendline(cmd)
         old_timeout = child.timeout
-        if timeout is not None:
-            child.timeout = timeout
+        child.timeout = self.context.timeout(timeout if timeout is not None else old_timeout)
+        self._waiting = True
         try:
             child.expect(self._prompt_re)
+            self._waiting = False
             out = child.before or ""
         finally:
             child.timeout = old_timeout
@@ -115,7 +176,9 @@ class DelveSubprocessBackend:
         lines = cleaned.splitlines()
         if lines and lines[0].strip() == cmd.strip():
             lines = lines[1:]
-        return "\n".join(lines)
+        output = "\n".join(lines)
+        trace_debugger(self.name, "response", cmd=cmd, seconds=round(time.monotonic() - start, 3), output=output)
+        return output
 
     def _format_startup_error(self, exc: Exception) -> str:
         output = ""
@@ -158,6 +221,63 @@ class DelveSubprocessBackend:
             return f"[delve closed] {cmd}: {exc}"
         return "[delve] session restarted; ready for commands"
 
+    def _interrupt_for_cancel(self) -> None:
+        """on_cancel callback, run on the cancelling thread: unblock a pending wait for the prompt."""
+        child = self.child
+        if child is not None and self._waiting:
+            # Halts the running target, as Ctrl-C in the Delve CLI does; Delve then prompts again.
+            child.sendintr()
+
+    def target_pid(self) -> Optional[int]:
+        """PID of the program dlv launched, if it is still running."""
+        return _first_child(getattr(self.child, "pid", None))
+
+    def abort(self) -> None:
+        """End the session after a cancellation, from the thread that owns it.
+
+        A running target is halted, the launched program is killed, then dlv
+        is told to exit and killed if it does not. Safe in any state and
+        when called repeatedly.
+        """
+        self._release_context()
+        child = self.child
+        self.child = None
+        if child is None:
+            return
+        try:
+            if self._halt(child):
+                target = _first_child(getattr(child, "pid", None))
+                if target:
+                    _kill(target)
+                child.sendline("exit")
+                child.expect(pexpect.EOF, timeout=2)  # type: ignore[arg-type]
+        except Exception:
+            pass
+        finally:
+            try:
+                child.close(force=True)
+            except Exception:
+                pass
+
+    def close(self) -> None:
+        """Quit dlv; the program it launched ends with it."""
+        self.abort()
+
+    def _halt(self, child: Any) -> bool:
+        """Bring Delve back to its prompt if a command is still running; False when it is gone."""
+        if not child.isalive():
+            return False
+        if self._waiting:
+            child.sendintr()
+            child.expect(self._prompt_re, timeout=2)
+            self._waiting = False
+        return True
+
+    def _release_context(self) -> None:
+        if self._unregister_cancel is not None:
+            self._unregister_cancel()
+            self._unregister_cancel = None
+
     def __del__(self) -> None:  # pragma: no cover - best-effort cleanup
         try:
             if self.child and self.child.isalive():
@@ -167,4 +287,26 @@ class DelveSubprocessBackend:
                 except Exception:
                     self.child.close(force=True)
         except Exception:
-            pass
\ No newline at end of file
+            pass
+
+
+def _first_child(pid: Optional[int]) -> Optional[int]:
+    if not pid:
+        return None
+    try:
+        for task in os.scandir(f"/proc/{pid}/task"):
+            with open(os.path.join(task.path, "children"), encoding="ascii") as fh:
+                children = fh.read().split()
+            if children:
+                return int(children[0])
+    except (OSError, ValueError):
+        return None
+    return None
+
+
+def _kill(pid: int) -> None:
+    try:
+        os.kill(pid, signal.SIGKILL)
+    except OSError:
+        # Already gone with dlv.
+        pass
@@ -5,6 +5,11 @@ when the gdb Python module isn't available (e.g., unit tests).
 """
 from __future__ import annotations
 
+import time
+
+from dbgcopilot.utils.log import trace_debugger
+
+
 class GdbInProcessBackend:
     name = "gdb"
 
@@ -48,6 +53,8 @@ class GdbInProcessBackend:
             parts.extend([p.strip() for p in chunk.split(";") if p.strip()])
 
         for part in parts or [cmd.strip()]:
+            trace_debugger(self.name, "request", cmd=part)
+            start = time.monotonic()
             try:
                 # Run as-if typed by a human (from_tty=True) but capture output (to_string=True)
                 out = gdb.execute(part, from_tty=True, to_string=True)
@@ -76,6 +83,7 @@ class GdbInProcessBackend:
                     text += bt
             # For 'file' and similar loader commands, GDB usually prints 'Reading symbols...' when from_tty
             # We've already run with from_tty=True above so such messages should be included in 'text'.
+            trace_debugger(self.name, "response", cmd=part, seconds=round(time.monotonic() - start, 3), output=text)
             outputs.append(text)
 
         return "\n".join(o for o in outputs if o)
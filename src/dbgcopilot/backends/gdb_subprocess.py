@@ -7,12 +7,15 @@ from __future__ import annotations
 
 from typing import Optional, List, Any
 import re
+import time
 
 try:
     import pexpect
 except Exception as _e:  # pragma: no cover - import error surfaced at runtime
     pexpect = None  # type: ignore
 
+from dbgcopilot.utils.log import trace_debugger
+
 
 class GdbSubprocessBackend:
     name = "gdb"
@@ -63,6 +66,8 @@ class GdbSubprocessBackend:
         if self.child is None:
             raise RuntimeError("GDB subprocess is not running")
         child: Any = self.child
+        trace_debugger(self.name, "request", cmd=cmd)
+        start = time.monotonic()
         # Send command and wait for next prompt; capture output in-between
         child.sendline(cmd)
         # Use per-call timeout if provided
@@ -80,7 +85,9 @@ class GdbSubprocessBackend:
         lines = text.splitlines()
         if lines and lines[0].strip() == cmd.strip():
             lines = lines[1:]
-        return "\n".join(lines)
+        output = "\n".join(lines)
+        trace_debugger(self.name, "response", cmd=cmd, seconds=round(time.monotonic() - start, 3), output=output)
+        return output
 
     def run_command(self, cmd: str, timeout: float | None = None) -> str:
         if self.child is None:
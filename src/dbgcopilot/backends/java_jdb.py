@@ -18,6 +18,8 @@ try:  # pragma: no cover - optional dependency guard
 except Exception:  # pragma: no cover - runtime dependency check
     pexpect = None  # type: ignore
 
+from dbgcopilot.utils.log import trace_debugger
+
 
 class JavaJdbBackend:
     name = "jdb"
@@ -272,6 +274,13 @@ class JavaJdbBackend:
         ensure: bool = True,
         post_drain: bool = False,
     ) -> str:
+        trace_debugger(self.name, "request", cmd=command)
+        start = time.monotonic()
+        output = self._capture(command, timeout, ensure=ensure, post_drain=post_drain)
+        trace_debugger(self.name, "response", cmd=command, seconds=round(time.monotonic() - start, 3), output=output)
+        return output
+
+    def _capture(self, command: str, timeout: float | None, *, ensure: bool, post_drain: bool) -> str:
         startup = ""
         if ensure:
             startup = self._ensure_session_started(timeout)
@@ -11,6 +11,9 @@ import sys
 import os
 import subprocess
 import re
+import time
+
+from dbgcopilot.utils.log import trace_debugger
 
 
 class LldbApiBackend:
@@ -209,10 +212,13 @@ class LldbApiBackend:
         outputs: List[str] = []
         for part in parts:
             # SBCommandInterpreter is synchronous; timeout is not supported here
+            trace_debugger(self.name, "request", cmd=part)
+            start = time.monotonic()
             try:
                 out = self._handle_command(part)
             except Exception as e:
                 out = f"[lldb error] {part}: {e}"
+            trace_debugger(self.name, "response", cmd=part, seconds=round(time.monotonic() - start, 3), output=out)
             if out:
                 outputs.append(out)
         return "\n".join(outputs)
@@ -5,6 +5,10 @@ to placeholders when the lldb Python module isn't available (e.g., unit tests).
 """
 from __future__ import annotations
 
+import time
+
+from dbgcopilot.utils.log import trace_debugger
+
 
 class LldbInProcessBackend:
     name = "lldb"
@@ -42,6 +46,8 @@ class LldbInProcessBackend:
             parts.extend([p.strip() for p in chunk.split(";") if p.strip()])
 
         for part in parts or [cmd.strip()]:
+            trace_debugger(self.name, "request", cmd=part)
+            start = time.monotonic()
             res = lldb.SBCommandReturnObject()
             interp.HandleCommand(part, res)
             text = ""
@@ -50,6 +56,7 @@ class LldbInProcessBackend:
             else:
                 err = res.GetError() or ""
                 text = (text + err) if err else text
+            trace_debugger(self.name, "response", cmd=part, seconds=round(time.monotonic() - start, 3), output=text)
             outputs.append(text)
 
         return "\n".join(o for o in outputs if o)
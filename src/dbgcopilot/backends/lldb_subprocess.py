@@ -7,12 +7,15 @@ from __future__ import annotations
 
 from typing import Optional, List
 import re
+import time
 
 try:
     import pexpect
 except Exception:
     pexpect = None  # type: ignore
 
+from dbgcopilot.utils.log import trace_debugger
+
 
 _DWARF_INDEXING_RE = re.compile(r"^\s*\[\d+/\d+\]\s+Manually indexing DWARF:.*$")
 
@@ -132,6 +135,13 @@ class LldbSubprocessBackend:
         return out or last_chunk
 
     def _send_and_capture(self, cmd: str, timeout: Optional[float] = None) -> str:
+        trace_debugger(self.name, "request", cmd=cmd)
+        start = time.monotonic()
+        output = self._capture(cmd, timeout)
+        trace_debugger(self.name, "response", cmd=cmd, seconds=round(time.monotonic() - start, 3), output=output)
+        return output
+
+    def _capture(self, cmd: str, timeout: Optional[float] = None) -> str:
         # Wrap raw capture and remove any echoed command line
         try:
             out = self._send_and_capture_raw(cmd, timeout=timeout)
@@ -10,6 +10,7 @@ from __future__ import annotations
 import os
 import re
 import sys
+import time
 from pathlib import Path
 from typing import Any, Optional
 
@@ -18,6 +19,8 @@ try:  # pragma: no cover - import guard for optional dependency
 except Exception:  # pragma: no cover - runtime dependency check
     pexpect = None  # type: ignore
 
+from dbgcopilot.utils.log import trace_debugger
+
 
 class PythonPdbBackend:
     name = "pdb"
@@ -175,6 +178,13 @@ class PythonPdbBackend:
         return startup
 
     def _send_and_capture(self, command: str, timeout: float | None = None) -> str:
+        trace_debugger(self.name, "request", cmd=command)
+        start = time.monotonic()
+        output = self._capture(command, timeout)
+        trace_debugger(self.name, "response", cmd=command, seconds=round(time.monotonic() - start, 3), output=output)
+        return output
+
+    def _capture(self, command: str, timeout: float | None = None) -> str:
         if not self.child or not self.child.isalive():
             return f"{self._prefix()} session ended"
         assert pexpect is not None
@@ -11,6 +11,7 @@ import re
 import subprocess
 import tempfile
 import threading
+import time
 from collections import deque
 
 try:
@@ -18,6 +19,8 @@ try:
 except Exception:  # pragma: no cover - import error surfaced at runtime
     r2pipe = None  # type: ignore
 
+from dbgcopilot.utils.log import trace_debugger
+
 
 _r2pipe_stderr_patched = False
 _CSI_PRIVATE_MODE_RE = re.compile(r"\x1b\[\?[0-9;]*[hl]")
@@ -249,8 +252,11 @@ class Radare2SubprocessBackend:
         if self._r2 is None:
             raise RuntimeError("radare2 session is not running")
 
-        output = self._r2.cmd(command)
-        return self._sanitize_output(output)
+        trace_debugger(self.name, "request", cmd=command)
+        start = time.monotonic()
+        output = self._sanitize_output(self._r2.cmd(command))
+        trace_debugger(self.name, "response", cmd=command, seconds=round(time.monotonic() - start, 3), output=output)
+        return output
 
     def _sanitize_output(self, text: str) -> str:
         if not text:
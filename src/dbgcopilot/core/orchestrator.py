@@ -8,13 +8,26 @@ from __future__ import annotations
 
 from typing import Optional, List, Any, Dict
 import re
-from dbgcopilot.core.state import Attempt, SessionState, resolve_auto_round_limit
+from dbgcopilot.analyze import condense_goroutine_output
+from dbgcopilot.core.state import (
+    Attempt,
+    SessionState,
+    resolve_auto_round_limit,
+    resolve_goroutine_filter,
+    resolve_source_context_lines,
+)
 from dbgcopilot.llm import providers
+from dbgcopilot.llm.cache import CachedClient
+from dbgcopilot.utils.context import Cancelled, current
 from dbgcopilot.utils.io import head_tail_truncate, color_text, strip_ansi
+from dbgcopilot.utils.redact import Redactor, command_variable, format_preview
 from pathlib import Path
 import os
 import json
+from dbgcopilot.prompts.budget import Budget
+from dbgcopilot.prompts.pinned import pinned_section
 from dbgcopilot.prompts.defaults import DEFAULT_PROMPT_CONFIG
+from dbgcopilot.prompts.templates import analysis_sections, issue_for_output, load_analysis_templates
 
 DEFAULT_MAX_CONTEXT_CHARS = int(DEFAULT_PROMPT_CONFIG.get("max_context_chars", 16000))
 
@@ -34,6 +47,10 @@ class CopilotOrchestrator:
         # Load prompt config
         self.prompt_source = "defaults"
         self.prompt_config = self._load_prompt_config()
+        # Per-issue analysis templates; a broken override raises PromptTemplateError here.
+        self.analysis_templates = load_analysis_templates(state.config)
+        # Secret literals redacted so far; hidden wherever they reappear in later prompts.
+        self._secrets: Dict[str, str] = {}
 
     # ------------------------------------------------------------------
     # Auto-approve helpers
@@ -170,12 +187,19 @@ class CopilotOrchestrator:
         return cfg
 
     def reload_prompts(self) -> str:
+        # Validate the templates first so a broken override leaves the current prompts in place.
+        templates = load_analysis_templates(self.state.config)
         self.prompt_config = self._load_prompt_config()
-        return f"Prompts reloaded from {self.prompt_source}."
+        self.analysis_templates = templates
+        message = f"Prompts reloaded from {self.prompt_source}."
+        if templates.directory:
+            message += f" Analysis templates from {templates.directory}."
+        return message
 
     def get_prompt_config(self) -> dict[str, Any]:
         d = dict(self.prompt_config)
         d["_source"] = self.prompt_source
+        d["_analysis_templates"] = self.analysis_templates.sources()
         return d
 
     def ask(self, question: str) -> str:
@@ -184,6 +208,38 @@ class CopilotOrchestrator:
             return self._handle_command_confirmation(text)
         return self._llm_turn(text)
 
+    def run_and_analyze(self, command: str) -> str:
+        """Run ``command`` without confirmation and ask the LLM to interpret its output."""
+        return self._execute_with_followup(command)
+
+    def record_output(self, command: str, output: str) -> None:
+        """Add output collected outside the normal command flow to the context of the next prompt."""
+        self.state.last_output = output
+        self.state.chatlog.append(f"Assistant: (collected) {command}\n" + (output or ""))
+
+    def analyze_output(self, command: str, output: str) -> str:
+        """Ask the LLM to interpret output collected outside the normal command flow (e.g. an attach snapshot)."""
+        self.record_output(command, output)
+        return self._llm_turn(self._build_followup_prompt(command, output))
+
+    def narrate_watch_hit(self, event: str, *, variable: str = "") -> str:
+        """Ask the LLM to explain a fired watchpoint (old/new value and responsible frame)."""
+        dbg = getattr(self.backend, "name", "debugger") or "debugger"
+        template = self.prompt_config.get("watchpoint_narration") or DEFAULT_PROMPT_CONFIG["watchpoint_narration"]
+        self.state.last_output = event
+        # Values of a watched secret carry no name of their own, so redact them with the expression as context.
+        redacted = self._redactor().redact(event, variable=variable)
+        return self._llm_turn(template.format(debugger=dbg, event=redacted))
+
+    def redaction_dry_run(self) -> str:
+        """Report what redaction would remove from the next prompt's debugger output and transcript."""
+        redactor = self._redactor()
+        sections = [(self.state.last_output or "", command_variable(self._last_command()))]
+        sections += [(entry, "") for entry in self.state.chatlog]
+        if not any(text for text, _ in sections):
+            return "Nothing to check yet: no debugger output or conversation."
+        return format_preview(redactor.preview(sections), enabled=redactor.enabled)
+
     def _handle_command_confirmation(self, reply: str) -> str:
         cmd = self.state.pending_command
         self.state.pending_command = None
@@ -244,19 +300,48 @@ class CopilotOrchestrator:
 
     def _build_followup_prompt(self, command: str, exec_output: str) -> str:
         plain = strip_ansi(exec_output or "")
+        redactor = self._redactor()
+        findings, source_context = analysis_sections(plain, resolve_source_context_lines(self.state.config))
+        analysis = self.analysis_templates.render(
+            issue_for_output(plain),
+            debugger=getattr(self.backend, "name", "debugger") or "debugger",
+            command=redactor.redact(command),
+            stack=redactor.redact(self._condense(plain), variable=command_variable(command)) or "(no output)",
+            findings=redactor.redact(findings),
+            source_context=redactor.redact(source_context),
+        )
         parts = [
-            f"The debugger command `{command}` was executed.",
-            "Debugger output:",
-            plain or "(no output)",
+            f"The debugger command `{redactor.redact(command)}` was executed.",
+            analysis,
             "What should we do next? Remember to wrap any future debugger commands inside <cmd>...</cmd>.",
         ]
         return "\n".join(parts)
 
-    def _format_confirmation_prompt(self, raw_answer: str, command: str) -> str:
+    def _redactor(self) -> Redactor:
+        """Redaction rules from the session config (``redact``, ``redact_names``, ``redact_patterns``)."""
+        return Redactor.from_config(self.state.config, known=self._secrets)
+
+    def _last_command(self) -> str:
+        return self.state.attempts[-1].cmd if self.state.attempts else ""
+
+    def _with_trim_notice(self, text: str) -> str:
+        """Prefix ``text`` with a note about what was trimmed from the last prompt, if anything."""
+        note = getattr(self.state, "last_prompt_trim", "")
+        if not note:
+            return text
+        colors = getattr(self.state, "colors_enabled", True)
+        return (color_text(f"[{note}]", "gray", enable=colors) if colors else f"[{note}]") + "\n" + text
+
+    def _condense(self, text: str) -> str:
+        """Group large goroutine dumps (and apply the session's goroutine filter) for the prompt."""
+        states, contains = resolve_goroutine_filter(self.state.config)
+        return condense_goroutine_output(text, states=states, contains=contains)
+
+    def _format_confirmation_prompt(self, raw_answer: str, command: str, *, show_explanation: bool = True) -> str:
         colors = getattr(self.state, "colors_enabled", True)
         explanation = self._extract_explanation(raw_answer)
         parts = []
-        if explanation:
+        if explanation and show_explanation:
             parts.append(color_text(explanation, "green", enable=colors))
         parts.append("Proposed debugger command:")
         label = (getattr(self.backend, "name", "debugger") or "debugger")
@@ -277,6 +362,62 @@ class CopilotOrchestrator:
         self._emit_chat_event(proposal)
         return "\n".join(parts)
 
+    def _stream_answer(self, pname: str, prompt: str, sink: Any) -> tuple[str, bool]:
+        """Feed completion chunks to ``sink`` as they arrive.
+
+        Returns the assembled text and whether the user cancelled with Ctrl-C
+        (or ``cancel_stream`` was called from another thread). The stream runs
+        under a child of the active context, so ``cancel_stream`` closes the
+        HTTP response even while a read is blocked waiting for tokens.
+        """
+        self._last_cache_hit = False
+        cached = providers.create_cached_client(pname, self.state.config)
+        if isinstance(cached, CachedClient):
+            hit = cached.lookup(prompt)
+            if hit is not None:
+                self._last_cache_hit = True
+                sink(hit if hit.endswith("\n") else hit + "\n")
+                return hit, False
+        stream = providers.create_stream(pname, prompt, self.state.config)
+        ctx = current().child()
+        self._active_stream = stream
+        self._stream_context = ctx
+        cancelled = False
+        try:
+            with ctx.activate():
+                for chunk in stream:
+                    sink(chunk)
+        except KeyboardInterrupt:
+            stream.cancel()
+        except Cancelled:
+            # Only our own cancel_stream is a user cancellation; a session deadline propagates.
+            if not stream.cancelled:
+                raise
+        finally:
+            ctx.close()
+            self._active_stream = None
+            self._stream_context = None
+            cancelled = stream.cancelled
+            if stream.text and not stream.text.endswith("\n"):
+                try:
+                    sink("\n")
+                except Exception:
+                    pass
+        if isinstance(cached, CachedClient) and not cancelled:
+            cached.store(prompt, stream.text)
+        return stream.text, cancelled
+
+    def cancel_stream(self) -> bool:
+        """Stop an in-flight streamed answer; returns False when nothing is streaming."""
+        stream = getattr(self, "_active_stream", None)
+        if stream is None:
+            return False
+        stream.cancel()
+        ctx = getattr(self, "_stream_context", None)
+        if ctx is not None:
+            ctx.cancel("answer cancelled")
+        return True
+
     def _extract_explanation(self, raw_answer: str) -> str:
         return re.sub(r"<cmd>[\s\S]*?</cmd>", "", raw_answer, flags=re.IGNORECASE).strip()
 
@@ -315,6 +456,11 @@ class CopilotOrchestrator:
         prev_lines = list(self.state.chatlog)
         prev_lines.append(f"User: {text}")
 
+        replay_llm = getattr(self.state, "replay_llm", None)
+        pname = providers.resolve_provider_name(
+            self.state.config, getattr(self.state, "selected_provider", None)
+        )
+        # The provider's context window is handled by trimming the prompt below; this cap is the user's own limit.
         MAX_CONTEXT_CHARS = int(self.prompt_config.get("max_context_chars", DEFAULT_MAX_CONTEXT_CHARS))
         transcript_for_llm = "\n".join(prev_lines)
         if len(transcript_for_llm) > MAX_CONTEXT_CHARS:
@@ -360,54 +506,105 @@ class CopilotOrchestrator:
         attempts_txt = "\n".join(
             f"- {a.cmd}: {a.output_snippet}" for a in attempts if getattr(a, "output_snippet", "")
         )
-        last_out = head_tail_truncate(self.state.last_output or "", 2000)
 
         wants_zh = _wants_chinese(question)
 
         all_rules = list(self.prompt_config.get("rules", []))
         rules_lines = "\n".join(f"- {r}" for r in all_rules)
+        post_mortem_note = ""
+        if getattr(self.backend, "post_mortem", False):
+            post_mortem_note = self.prompt_config.get("post_mortem_note", "").format(
+                debugger=dbg, core=getattr(self.backend, "core", "") or "core file"
+            )
         system_preamble = (
             self.prompt_config.get("system_preamble", "").format(debugger=dbg)
+            + post_mortem_note
             + self.prompt_config.get("assistant_cmd_tag_instructions", "")
             + ("Rules:\n" + rules_lines + "\n" if rules_lines else "")
         )
 
-        context_block = (
-            (f"Goal: {goal}\n" if goal else "")
-            + (f"Recent commands and snippets:\n{attempts_txt}\n" if attempts_txt else "")
-            + (f"Last output:\n{last_out}\n" if last_out else "")
-            + ("\nFull conversation so far:\n" + "\n".join(self.state.chatlog) + "\n" if self.state.chatlog else "")
-        )
-
         lang_hint = (self.prompt_config.get("language_hint_zh", "") if wants_zh else "")
 
-        primed_question = (
-            system_preamble
-            + ("\n" + context_block if context_block else "")
-            + ("\n" + lang_hint if lang_hint else "")
-            + "\nUser: "
-            + question.strip()
-            + "\nAssistant:"
-        )
+        redactor = self._redactor()
+        # Learn "(executed) print token" values from the whole transcript, even entries trimmed below.
+        for entry in self.state.chatlog:
+            redactor.redact_entry(entry)
+
+        # Pinned goroutines are composed outside the outputs the budget trims.
+        pinned = pinned_section(self.state.config, output=self.state.last_output or "", debugger=self.backend)
+
+        def _compose(outputs: List[str], history: List[str], note: str) -> str:
+            chat_txt = "\n".join(redactor.redact_entry(entry) for entry in history)
+            last_out = self._condense(outputs[0]) if outputs else ""
+            last_out = redactor.redact(last_out, variable=command_variable(self._last_command()))
+            context_block = redactor.redact(
+                (f"Goal: {goal}\n" if goal else "")
+                + (f"Recent commands and snippets:\n{attempts_txt}\n" if attempts_txt else "")
+                + (f"Last output:\n{last_out}\n" if last_out else "")
+                + (f"{pinned}\n" if pinned else "")
+                + ("\nFull conversation so far:\n" + chat_txt + "\n" if history else "")
+                + (f"\n({note})\n" if note else "")
+            )
+            return (
+                system_preamble
+                + ("\n" + context_block if context_block else "")
+                + ("\n" + lang_hint if lang_hint else "")
+                + "\nUser: "
+                + redactor.redact(question.strip())
+                + "\nAssistant:"
+            )
 
-        pname = getattr(self.state, "selected_provider", None) or self.state.config.get("llm_provider")
-        if pname:
-            prov = providers.get_provider(pname)
-            if prov:
+        # Fit the provider's context window: group duplicate stacks, drop runtime frames, then old history.
+        states, contains = resolve_goroutine_filter(self.state.config)
+        model = providers.session_model(pname, self.state.config)
+        budget = Budget.for_provider(pname, model, states=states, contains=contains)
+        outputs = [self.state.last_output] if self.state.last_output else []
+        if budget is None:
+            primed_question = _compose(outputs, list(self.state.chatlog), "")
+        else:
+            primed_question = budget.fit(_compose, outputs, self.state.chatlog)
+        self.state.last_prompt_trim = budget.note() if budget is not None else ""
+
+        if pname or replay_llm:
+            prov = providers.get_provider(pname) if pname else None
+            if prov or replay_llm:
                 try:
-                    try:
-                        client = prov.create_client(self.state.config)
-                    except Exception:
-                        client = prov.ask
-                    answer = client(primed_question)
+                    token_sink = getattr(self.state, "token_sink", None)
+                    tokens_streamed = False
+                    cancelled = False
+                    cache_hit = False
+                    if replay_llm is not None:
+                        answer = replay_llm(primed_question)
+                    elif token_sink is not None:
+                        answer, cancelled = self._stream_answer(pname, primed_question, token_sink)
+                        tokens_streamed = True
+                        cache_hit = self._last_cache_hit
+                    else:
+                        try:
+                            client = providers.create_cached_client(pname, self.state.config)
+                        except Exception:
+                            client = prov.ask
+                        answer = client(primed_question)
+                        cache_hit = bool(getattr(client, "last_cache_hit", False))
+                    recorder = getattr(self.state, "recorder", None)
+                    if recorder is not None:
+                        recorder.record_llm(
+                            primed_question, answer, provider=pname, cancelled=cancelled, cache_hit=cache_hit
+                        )
 
                     user_line = f"User: {question.strip()}"
-                    assistant_line = f"Assistant: {answer.strip()}"
+                    assistant_line = f"Assistant: {answer.strip()}" + (" [cancelled]" if cancelled else "")
                     self.state.chatlog.append(user_line)
                     self.state.chatlog.append(assistant_line)
                     self.state.facts.append(f"Q: {question.strip()}")
                     self.state.facts.append(f"A: {(answer.splitlines()[0] if answer else '').strip()}")
 
+                    if cancelled:
+                        # Never act on a command from a half-finished answer.
+                        msg = "Response cancelled."
+                        colors = getattr(self.state, "colors_enabled", True)
+                        return color_text(msg, "yellow", enable=colors) if colors else msg
+
                     explanation = self._extract_explanation(answer)
                     display_text = (explanation or answer).strip()
                     auto_mode = getattr(self.state, "auto_accept_commands", False)
@@ -420,12 +617,14 @@ class CopilotOrchestrator:
                             allowed, notice = self._reserve_auto_round()
                             if not allowed:
                                 self.state.pending_command = exec_cmd
-                                confirm = self._format_confirmation_prompt(answer, exec_cmd)
+                                confirm = self._format_confirmation_prompt(
+                                    answer, exec_cmd, show_explanation=not tokens_streamed
+                                )
                                 segments = [notice, confirm] if notice else [confirm]
                                 return "\n".join(seg for seg in segments if seg)
                             colors = getattr(self.state, "colors_enabled", True)
                             payload_lines: list[str] = []
-                            if display_text:
+                            if display_text and not tokens_streamed:
                                 payload_lines.append(
                                     color_text(display_text, "green", enable=colors) if colors else display_text
                                 )
@@ -444,12 +643,17 @@ class CopilotOrchestrator:
                                 return "\n".join(seg for seg in segments if seg)
                             return result
                         self.state.pending_command = exec_cmd
-                        return self._format_confirmation_prompt(answer, exec_cmd)
+                        return self._with_trim_notice(
+                            self._format_confirmation_prompt(answer, exec_cmd, show_explanation=not tokens_streamed)
+                        )
 
+                    if tokens_streamed:
+                        self.state.last_answer_streamed = True
+                        return ""
                     if auto_mode and display_text and not streamed:
                         streamed = self._emit_chat(display_text)
                     colors = getattr(self.state, "colors_enabled", True)
-                    result = color_text(answer, "green", enable=colors) if colors else answer
+                    result = self._with_trim_notice(color_text(answer, "green", enable=colors) if colors else answer)
                     if auto_mode and streamed and getattr(self.state, "last_answer_streamed", False):
                         return ""
                     return result
@@ -677,16 +881,9 @@ def _prefix_dbg_echo(cmd: str, backend: Any, colors: bool = True) -> str:
 
 
 def _call_llm(provider_name: str, question: str, state: SessionState) -> str:
-    prov = providers.get_provider(provider_name)
-    if provider_name == "openrouter":
-        from dbgcopilot.llm import openrouter as _or
-        ask_fn = _or.create_provider(session_config=state.config)
-        return ask_fn(question)
-    if provider_name in {"openai-http", "ollama", "deepseek", "qwen", "kimi", "zhipuglm", "llama-cpp", "modelscope"}:
-        from dbgcopilot.llm import openai_compat as _oa
-        ask_fn = _oa.create_provider(session_config=state.config, name=provider_name)
-        return ask_fn(question)
-    return prov.ask(question) if prov else ""
+    if providers.get_provider(provider_name) is None:
+        return ""
+    return providers.create_cached_client(provider_name, state.config)(question)
 
 
 def _execute_and_format(backend: Any, cmd: str, colors: bool) -> str:
@@ -709,10 +906,14 @@ def _llm_summarize_session(self: "CopilotOrchestrator") -> str:
     attempts_txt = "\n".join(
         f"- {a.cmd}: {a.output_snippet}" for a in attempts if getattr(a, "output_snippet", "")
     )
-    last_out = head_tail_truncate(self.state.last_output or "", 1200)
+    redactor = self._redactor()
     # Use only the last ~40 chat lines to avoid bloat
     chat_tail = self.state.chatlog[-40:]
-    chat_txt = "\n".join(chat_tail)
+    chat_txt = "\n".join(redactor.redact_entry(entry) for entry in chat_tail)
+    last_out = redactor.redact(
+        head_tail_truncate(self.state.last_output or "", 1200), variable=command_variable(self._last_command())
+    )
+    attempts_txt = redactor.redact(attempts_txt)
     # Build a compact prompt for summarization
     prompt = (
         "You are a helpful debugging assistant. Produce a concise summary of the session below.\n"
@@ -724,7 +925,7 @@ def _llm_summarize_session(self: "CopilotOrchestrator") -> str:
         + (f"Recent chat (tail):\n{chat_txt}\n" if chat_txt else "")
         + "\nSummary:"
     )
-    pname = getattr(self.state, "selected_provider", None) or self.state.config.get("llm_provider")
+    pname = providers.resolve_provider_name(self.state.config, getattr(self.state, "selected_provider", None))
     if pname:
         try:
             return _call_llm(pname, prompt, self.state)
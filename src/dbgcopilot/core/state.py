@@ -6,6 +6,7 @@ from typing import Optional, Callable, List, Any, Dict, Mapping
 
 
 DEFAULT_AUTO_ROUND_LIMIT = 64
+DEFAULT_SOURCE_CONTEXT_LINES = 3
 
 
 def resolve_auto_round_limit(config: Mapping[str, str] | None) -> int:
@@ -23,6 +24,26 @@ def resolve_auto_round_limit(config: Mapping[str, str] | None) -> int:
                 return limit
     return DEFAULT_AUTO_ROUND_LIMIT
 
+
+def resolve_source_context_lines(config: Mapping[str, str] | None) -> int:
+    """Return how many source lines to show around each stack frame (0 disables)."""
+    raw = (config or {}).get("source_context_lines")
+    if raw is not None:
+        try:
+            lines = int(raw)
+        except (TypeError, ValueError):
+            lines = -1
+        if lines >= 0:
+            return lines
+    return DEFAULT_SOURCE_CONTEXT_LINES
+
+
+def resolve_goroutine_filter(config: Mapping[str, str] | None) -> tuple[List[str], str]:
+    """Return (states, stack substring) used to filter goroutine dumps sent to the LLM."""
+    cfg = config or {}
+    states = [part.strip() for part in (cfg.get("goroutine_states") or "").split(",") if part.strip()]
+    return states, (cfg.get("goroutine_grep") or "").strip()
+
 @dataclass
 class Attempt:
     cmd: str
@@ -65,8 +86,20 @@ class SessionState:
     debugger_output_sink: Optional[Callable[[str], None]] = None
     pending_chat: List[str] = field(default_factory=_new_str_list)
     chat_output_sink: Optional[Callable[[str], None]] = None
+    # Receives LLM answer chunks as they stream; None keeps blocking completions
+    token_sink: Optional[Callable[[str], None]] = None
     last_answer_streamed: bool = False
+    # Goroutine dump saved by /goroutines snapshot for a later /goroutines diff
+    goroutine_baseline: str = ""
+    # Dumps since that snapshot, oldest first, for /goroutines leak
+    goroutine_samples: List[str] = field(default_factory=_new_str_list)
+    # What Budget.fit removed from the last prompt to fit the context window ("" when nothing)
+    last_prompt_trim: str = ""
     pending_chat_events: List[Dict[str, Any]] = field(default_factory=_new_chat_event_list)
     auto_rounds_remaining: Optional[int] = None
     auto_loop_depth: int = 0
     chat_event_sink: Optional[Callable[[Dict[str, Any]], None]] = None
+    # Session transcript writer (dbgcopilot.session.Recorder) when /record is active
+    recorder: Optional[Any] = None
+    # Recorded LLM responses used instead of the provider while replaying
+    replay_llm: Optional[Callable[[str], str]] = None
@@ -0,0 +1,21 @@
+"""Debug Adapter Protocol server so editors (VS Code and other DAP clients) can drive the copilot."""
+from __future__ import annotations
+
+from .protocol import DapError, MessageWriter, ProtocolError, encode_message, read_message
+from .references import FrameRef, ReferenceManager, ScopeRef, StaleHandleError, VariableRef
+from .server import ANALYSIS_EVENT, DapServer
+
+__all__ = [
+    "ANALYSIS_EVENT",
+    "DapError",
+    "DapServer",
+    "FrameRef",
+    "MessageWriter",
+    "ProtocolError",
+    "ReferenceManager",
+    "ScopeRef",
+    "StaleHandleError",
+    "VariableRef",
+    "encode_message",
+    "read_message",
+]
@@ -0,0 +1,105 @@
+"""Debug Adapter Protocol wire format.
+
+Every message is a JSON object preceded by a ``Content-Length: N`` header
+and a blank line (``\\r\\n\\r\\n``). ``MessageWriter`` numbers outgoing
+messages with the ``seq`` field the protocol requires and builds the
+response and event envelopes.
+"""
+from __future__ import annotations
+
+from typing import Any, BinaryIO, Dict, Optional
+import json
+import threading
+
+
+class DapError(RuntimeError):
+    """A request could not be served; the message is shown to the DAP client."""
+
+
+class ProtocolError(DapError):
+    """The client sent bytes that do not frame a DAP message."""
+
+
+def read_message(stream: BinaryIO) -> Optional[Dict[str, Any]]:
+    """Next message from ``stream``, or None at end of stream."""
+    length = -1
+    while True:
+        line = stream.readline()
+        if not line:
+            return None
+        text = line.decode("ascii", errors="replace").strip()
+        if not text:
+            if length >= 0:
+                break
+            # Tolerate stray blank lines between messages.
+            continue
+        name, _, value = text.partition(":")
+        if name.strip().lower() == "content-length":
+            try:
+                length = int(value.strip())
+            except ValueError:
+                raise ProtocolError(f"bad Content-Length header: {text!r}") from None
+    body = stream.read(length)
+    if len(body) < length:
+        return None
+    try:
+        message = json.loads(body.decode("utf-8"))
+    except ValueError as e:
+        raise ProtocolError(f"message body is not JSON: {e}") from None
+    if not isinstance(message, dict):
+        raise ProtocolError("message body must be a JSON object")
+    return message
+
+
+def encode_message(message: Dict[str, Any]) -> bytes:
+    body = json.dumps(message, ensure_ascii=False).encode("utf-8")
+    return f"Content-Length: {len(body)}\r\n\r\n".encode("ascii") + body
+
+
+class MessageWriter:
+    """Writes sequenced responses and events; safe to share between threads."""
+
+    def __init__(self, stream: BinaryIO) -> None:
+        self._stream = stream
+        self._seq = 0
+        self._lock = threading.Lock()
+
+    def send(self, message: Dict[str, Any]) -> Dict[str, Any]:
+        with self._lock:
+            self._seq += 1
+            message = {"seq": self._seq, **message}
+            self._stream.write(encode_message(message))
+            self._stream.flush()
+        return message
+
+    def response(self, request: Dict[str, Any], body: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        reply: Dict[str, Any] = {
+            "type": "response",
+            "request_seq": request.get("seq", 0),
+            "success": True,
+            "command": request.get("command", ""),
+        }
+        if body is not None:
+            reply["body"] = body
+        return self.send(reply)
+
+    def error(self, request: Dict[str, Any], message: str) -> Dict[str, Any]:
+        return self.send(
+            {
+                "type": "response",
+                "request_seq": request.get("seq", 0),
+                "success": False,
+                "command": request.get("command", ""),
+                "message": message,
+                "body": {"error": {"id": 1, "format": message, "showUser": True}},
+            }
+        )
+
+    def event(self, name: str, body: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        message: Dict[str, Any] = {"type": "event", "event": name}
+        if body is not None:
+            message["body"] = body
+        return self.send(message)
+
+
+__all__ = ["DapError", "MessageWriter", "ProtocolError", "encode_message", "read_message"]
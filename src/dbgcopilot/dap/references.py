@@ -0,0 +1,132 @@
+"""Integer handles for DAP frame, scope and variable references.
+
+DAP names frames by ``frameId`` and expandable values by
+``variablesReference``: positive integers the adapter hands out and the
+client sends back in later requests. ``ReferenceManager`` maps them to the
+debugger's ``Frame`` and ``Variable`` objects with three rules:
+
+- the same object gets the same handle for as long as the target stays
+  stopped, so repeated ``stackTrace`` or ``variables`` requests (editors
+  send many) return identical ids;
+- ``invalidate()`` is called whenever the target resumes, and handles from
+  an earlier stop are rejected with ``StaleHandleError`` instead of
+  silently resolving to whatever now sits at that frame index;
+- handles are never reused, even across stops, and 0 is never issued
+  because DAP reserves ``variablesReference: 0`` for "no children".
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Dict, Hashable, Optional, Type, TypeVar, Union
+
+from dbgcopilot.analyze.goroutines import Frame
+from dbgcopilot.debugger.base import Variable
+
+from .protocol import DapError
+
+SCOPE_LOCALS = "Locals"
+
+
+class StaleHandleError(DapError):
+    """A frameId or variablesReference that is unknown or from an earlier stop."""
+
+
+@dataclass
+class FrameRef:
+    thread_id: int
+    # 0 is the innermost frame.
+    index: int
+    frame: Frame
+
+
+@dataclass
+class ScopeRef:
+    frame_id: int
+    name: str
+
+
+@dataclass
+class VariableRef:
+    """An expandable value; ``frame_id`` is the frame it was evaluated in, if known."""
+
+    variable: Variable
+    frame_id: Optional[int] = None
+
+
+Ref = Union[FrameRef, ScopeRef, VariableRef]
+R = TypeVar("R", FrameRef, ScopeRef, VariableRef)
+
+_KIND_NAMES = {FrameRef: "frame", ScopeRef: "scope", VariableRef: "variable"}
+
+
+class ReferenceManager:
+    """Allocates and resolves handles; valid until the next ``invalidate()``."""
+
+    def __init__(self) -> None:
+        self._next = 1
+        self._refs: Dict[int, Ref] = {}
+        self._keys: Dict[Hashable, int] = {}
+        # Handles below this were issued before the last invalidate().
+        self._floor = 1
+
+    def _allocate(self, key: Hashable, ref: Ref) -> int:
+        handle = self._keys.get(key)
+        if handle is not None:
+            return handle
+        handle = self._next
+        self._next += 1
+        self._keys[key] = handle
+        self._refs[handle] = ref
+        return handle
+
+    def frame(self, thread_id: int, index: int, frame: Frame) -> int:
+        key = ("frame", thread_id, index, frame.function, frame.file, frame.line)
+        return self._allocate(key, FrameRef(thread_id, index, frame))
+
+    def scope(self, frame_id: int, name: str = SCOPE_LOCALS) -> int:
+        self.get(frame_id, FrameRef)
+        return self._allocate(("scope", frame_id, name), ScopeRef(frame_id, name))
+
+    def variable(self, parent: Union[int, str], variable: Variable, frame_id: Optional[int] = None) -> int:
+        """Handle for ``variable``'s children, or 0 when it has none to expand.
+
+        ``parent`` is the containing handle (scope or variable) or, for
+        top-level evaluations, the expression text; together with the
+        variable's name it identifies the value within one stop.
+        """
+        if not variable.children:
+            return 0
+        return self._allocate(("var", frame_id, parent, variable.name), VariableRef(variable, frame_id))
+
+    def resolve(self, handle: int, what: str = "variables") -> Ref:
+        ref = self._refs.get(handle)
+        if ref is not None:
+            return ref
+        if self._floor > handle > 0:
+            raise StaleHandleError(f"{what} reference {handle} is from an earlier stop; request the stack trace again")
+        raise StaleHandleError(f"unknown {what} reference {handle}")
+
+    def get(self, handle: int, kind: Type[R]) -> R:
+        ref = self.resolve(handle, _KIND_NAMES[kind])
+        if not isinstance(ref, kind):
+            raise StaleHandleError(f"reference {handle} is a {_KIND_NAMES[type(ref)]}, not a {_KIND_NAMES[kind]}")
+        return ref
+
+    def invalidate(self) -> None:
+        """Forget every handle; call when the target resumes."""
+        self._refs.clear()
+        self._keys.clear()
+        self._floor = self._next
+
+    def __len__(self) -> int:
+        return len(self._refs)
+
+
+__all__ = [
+    "FrameRef",
+    "ReferenceManager",
+    "SCOPE_LOCALS",
+    "ScopeRef",
+    "StaleHandleError",
+    "VariableRef",
+]
@@ -0,0 +1,568 @@
+"""DAP server: let an editor drive a structured debugger through the copilot.
+
+``DapServer`` translates Debug Adapter Protocol requests (initialize,
+launch/attach, setBreakpoints, configurationDone, threads, stackTrace,
+scopes, variables, evaluate, continue, next/stepIn/stepOut, disconnect) into calls on the
+``Debugger`` interface. Goroutines and Python/native threads are DAP
+threads; frame and variable handles come from ``ReferenceManager`` and are
+invalidated on every resume. Locals come from the debugger's
+``frame_locals`` when it has one, so composite values keep their children
+to expand; otherwise from the backend's ``locals`` listing. ``pathMap`` in the launch or attach arguments
+(``build=local`` prefixes) maps the source paths of a binary built elsewhere
+to the editor's files, for stack frames and breakpoints alike.
+
+When the target stops on a panic, fatal error, uncaught exception or
+signal, or is found hung (``hangTimeout`` in the launch arguments, or a
+Python script pdb could not break into), the analyzer findings and the
+LLM's explanation are sent as an ``output`` event and as a custom
+``dbgcopilot/analysis`` event for clients that render it themselves.
+
+Requests are served one at a time: ``continue`` blocks until the next stop,
+so ``pause`` is not supported.
+"""
+from __future__ import annotations
+
+from pathlib import Path
+from typing import Any, BinaryIO, Callable, Dict, List, Optional
+import argparse
+import re
+import socket
+import sys
+
+from dbgcopilot.analyze import condense_goroutine_output, findings_for_output
+from dbgcopilot.debugger.base import (
+    STOP_BREAKPOINT,
+    STOP_EXCEPTION,
+    STOP_FATAL,
+    STOP_PANIC,
+    STOP_SIGNAL,
+    STOP_STEP,
+    STOP_STOPPED,
+    STOP_WATCH_SCOPE,
+    STOP_WATCHPOINT,
+    BreakpointSpec,
+    Debugger,
+    DebuggerError,
+    StopEvent,
+    Variable,
+    stop_hits,
+)
+from dbgcopilot.prompts.defaults import DEFAULT_PROMPT_CONFIG
+from dbgcopilot.utils.pathmap import PathMap, local_path, set_path_map
+from dbgcopilot.utils.redact import Redactor
+
+from .protocol import DapError, MessageWriter, read_message
+from .references import SCOPE_LOCALS, FrameRef, ReferenceManager, ScopeRef, VariableRef
+
+ANALYSIS_EVENT = "dbgcopilot/analysis"
+# Stops that mean something went wrong and are worth an LLM explanation.
+ANALYZE_REASONS = {STOP_PANIC, STOP_FATAL, STOP_EXCEPTION, STOP_SIGNAL}
+REASON_HANG = "hang"
+
+_DAP_STOP_REASONS = {
+    STOP_BREAKPOINT: "breakpoint",
+    STOP_WATCHPOINT: "data breakpoint",
+    STOP_WATCH_SCOPE: "data breakpoint",
+    STOP_PANIC: "exception",
+    STOP_FATAL: "exception",
+    STOP_EXCEPTION: "exception",
+    STOP_SIGNAL: "exception",
+    STOP_STEP: "step",
+}
+
+# Deepest stack a stackTrace request reads, so ``totalFrames`` is the real depth below it.
+_STACK_LIMIT = 512
+# Fallback for debuggers without ``frame_locals``: backend name -> (command
+# listing locals, whether it can address any frame).
+_LOCALS_COMMANDS = {
+    "delve": ("goroutine {thread} frame {index} locals", True),
+    "lldb": ("frame variable", False),
+}
+# ``name = value`` (Delve) or ``(type) name = value`` (LLDB).
+_LOCAL_RE = re.compile(r"^(?:\((?P<type>[^)]*)\)\s+)?(?P<name>[A-Za-z_$][\w$.]*)\s+=\s?(?P<value>.*)$")
+_HIT_CONDITION_RE = re.compile(r"^\s*(?:>=|==|=)?\s*(\d+)\s*$")
+
+CAPABILITIES = {
+    "supportsConfigurationDoneRequest": True,
+    "supportsConditionalBreakpoints": True,
+    "supportsHitConditionalBreakpoints": True,
+    "supportsEvaluateForHovers": True,
+}
+
+Opener = Callable[..., Debugger]
+
+
+def parse_locals(text: str) -> List[Variable]:
+    """Locals from a ``locals`` / ``frame variable`` listing; wrapped lines join the value above."""
+    found: List[Variable] = []
+    for line in (text or "").splitlines():
+        m = _LOCAL_RE.match(line.strip())
+        if m and not line[:1].isspace():
+            found.append(Variable(name=m.group("name"), value=m.group("value").strip(), type=m.group("type") or ""))
+        elif found and line.strip():
+            found[-1].value += "\n" + line.rstrip()
+    return found
+
+
+def parse_hit_condition(text: str) -> int:
+    """Hit count from a DAP ``hitCondition`` ("3", ">= 3"); 0 when empty."""
+    if not (text or "").strip():
+        return 0
+    m = _HIT_CONDITION_RE.match(text)
+    if not m:
+        raise DebuggerError(f"Unsupported hit condition {text!r}; use a number N to stop from the Nth hit")
+    return int(m.group(1))
+
+
+def _open(program: str, backend: Optional[str] = None, **kwargs: Any) -> Debugger:
+    from dbgcopilot.debugger import open_debugger
+
+    return open_debugger(program, backend, **kwargs)
+
+
+class DapServer:
+    """Serve one DAP client over a pair of binary streams."""
+
+    def __init__(
+        self,
+        reader: BinaryIO,
+        writer: BinaryIO,
+        *,
+        llm: Optional[Callable[[str], str]] = None,
+        opener: Opener = _open,
+        config: Optional[Dict[str, str]] = None,
+    ) -> None:
+        self.reader = reader
+        self.out = MessageWriter(writer)
+        self.refs = ReferenceManager()
+        self.llm = llm
+        self.config: Dict[str, str] = dict(config or {})
+        self.debugger: Optional[Debugger] = None
+        self.hang_timeout: Optional[float] = None
+        self.done = False
+        self._opener = opener
+        self._attached = False
+        # Thread the target last stopped in; its innermost frame is where expressions evaluate.
+        self._thread: Optional[int] = None
+        # Source path -> debugger breakpoint ids, replaced wholesale by each setBreakpoints.
+        self._source_breakpoints: Dict[str, List[int]] = {}
+        self._then: Optional[Callable[[], None]] = None
+        self._handlers: Dict[str, Callable[[Dict[str, Any]], Optional[Dict[str, Any]]]] = {
+            "initialize": self._initialize,
+            "launch": self._launch,
+            "attach": self._attach,
+            "setBreakpoints": self._set_breakpoints,
+            "setExceptionBreakpoints": lambda args: {"breakpoints": []},
+            "configurationDone": self._configuration_done,
+            "threads": self._threads,
+            "stackTrace": self._stack_trace,
+            "scopes": self._scopes,
+            "variables": self._variables,
+            "evaluate": self._evaluate,
+            "continue": self._continue,
+            "next": lambda args: self._step("next"),
+            "stepIn": lambda args: self._step("step"),
+            "stepOut": lambda args: self._step("step_out"),
+            "disconnect": self._disconnect,
+        }
+
+    # ------------------------------------------------------------------
+    def serve(self) -> None:
+        try:
+            while not self.done:
+                message = read_message(self.reader)
+                if message is None:
+                    break
+                self.handle(message)
+        finally:
+            if self.debugger is not None:
+                self._end(terminate=not self._attached)
+
+    def handle(self, request: Dict[str, Any]) -> None:
+        if request.get("type") != "request":
+            return
+        command = str(request.get("command") or "")
+        handler = self._handlers.get(command)
+        if handler is None:
+            self.out.error(request, f"Unsupported request '{command}'")
+            return
+        self._then = None
+        try:
+            body = handler(request.get("arguments") or {})
+        except (DapError, DebuggerError) as e:
+            self.out.error(request, str(e))
+            return
+        except Exception as e:
+            self.out.error(request, f"{command} failed: {e}")
+            return
+        self.out.response(request, body)
+        # Work that must follow the response, e.g. running the target after configurationDone.
+        then, self._then = self._then, None
+        if then is not None:
+            then()
+
+    def _need_debugger(self) -> Debugger:
+        if self.debugger is None:
+            raise DapError("No debug session: send launch or attach first")
+        return self.debugger
+
+    # ------------------------------------------------------------------
+    # Session lifecycle.
+
+    def _initialize(self, args: Dict[str, Any]) -> Dict[str, Any]:
+        return dict(CAPABILITIES)
+
+    def _configure(self, args: Dict[str, Any]) -> None:
+        if args.get("pathMap"):
+            mappings = args["pathMap"]
+            try:
+                set_path_map(PathMap.parse([mappings] if isinstance(mappings, str) else mappings))
+            except ValueError as e:
+                raise DapError(str(e)) from None
+        if args.get("hangTimeout"):
+            self.hang_timeout = float(args["hangTimeout"])
+        provider = args.get("llmProvider")
+        if provider and self.llm is None:
+            from dbgcopilot.llm import providers
+
+            self.llm = providers.create_cached_client(str(provider), self.config)
+
+    def _launch(self, args: Dict[str, Any]) -> None:
+        program = str(args.get("program") or "")
+        if not program:
+            raise DapError("launch requires 'program'")
+        self._configure(args)
+        options: Dict[str, Any] = {}
+        if args.get("core"):
+            options["core"] = str(args["core"])
+        if args.get("python"):
+            options["python"] = str(args["python"])
+        self.debugger = self._opener(program, args.get("debugger") or None, **options)
+        self._then = lambda: self._started(program, "launch")
+
+    def _attach(self, args: Dict[str, Any]) -> None:
+        from dbgcopilot.debugger import attach, connect
+
+        self._configure(args)
+        if args.get("remote"):
+            self.debugger = connect(str(args["remote"]), api_version=int(args.get("apiVersion") or 2))
+        elif args.get("pid"):
+            self.debugger = attach(int(args["pid"]), args.get("program") or None)
+        else:
+            raise DapError("attach requires 'pid' or 'remote'")
+        self._attached = True
+        self._then = lambda: self._started(str(args.get("program") or args.get("pid") or args["remote"]), "attach")
+
+    def _started(self, name: str, method: str) -> None:
+        startup = getattr(self.debugger, "startup_output", "")
+        if startup:
+            text = startup.replace("\r\n", "\n").rstrip()
+            self.out.event("output", {"category": "console", "output": text + "\n"})
+        self.out.event("process", {"name": name, "startMethod": method, "isLocalProcess": method == "launch"})
+        self.out.event("initialized")
+
+    def _configuration_done(self, args: Dict[str, Any]) -> None:
+        debugger = self._need_debugger()
+        if getattr(debugger, "post_mortem", False):
+            # A core dump has nothing to run: present the crash as the first stop.
+            self._then = lambda: self._report(StopEvent(reason=STOP_SIGNAL, detail="core dump"))
+        else:
+            self._then = self._resume
+
+    def _disconnect(self, args: Dict[str, Any]) -> None:
+        terminate = bool(args.get("terminateDebuggee", not self._attached))
+        if self.debugger is not None:
+            self._end(terminate=terminate)
+        self.done = True
+
+    def _end(self, *, terminate: bool) -> None:
+        debugger, self.debugger = self.debugger, None
+        if debugger is None:
+            return
+        try:
+            if terminate:
+                debugger.close()
+            else:
+                debugger.detach()
+        except DebuggerError:
+            pass
+
+    # ------------------------------------------------------------------
+    # Breakpoints and execution.
+
+    def _set_breakpoints(self, args: Dict[str, Any]) -> Dict[str, Any]:
+        debugger = self._need_debugger()
+        source = args.get("source") or {}
+        path = str(source.get("path") or source.get("name") or "")
+        if not path:
+            raise DapError("setBreakpoints requires source.path")
+        for old in self._source_breakpoints.pop(path, []):
+            try:
+                debugger.clear_breakpoint(old)
+            except DebuggerError:
+                pass
+        ids: List[int] = []
+        result: List[Dict[str, Any]] = []
+        for wanted in args.get("breakpoints") or []:
+            line = int(wanted.get("line") or 0)
+            try:
+                spec = BreakpointSpec(
+                    location=f"{path}:{line}",
+                    condition=str(wanted.get("condition") or ""),
+                    hit_count=parse_hit_condition(str(wanted.get("hitCondition") or "")),
+                )
+                bp = debugger.set_breakpoint(spec)
+            except DebuggerError as e:
+                result.append({"verified": False, "line": line, "message": str(e)})
+                continue
+            ids.append(bp.id)
+            result.append({"id": bp.id, "verified": True, "line": bp.line or line, "source": source})
+        self._source_breakpoints[path] = ids
+        return {"breakpoints": result}
+
+    def _continue(self, args: Dict[str, Any]) -> Dict[str, Any]:
+        self._need_debugger()
+        self._then = self._resume
+        return {"allThreadsContinued": True}
+
+    def _resume(self) -> None:
+        debugger = self._need_debugger()
+        self.refs.invalidate()
+        self._thread = None
+        try:
+            if self.hang_timeout and hasattr(debugger, "resume_async"):
+                event = self._watch(debugger, self.hang_timeout)
+            else:
+                event = debugger.continue_()
+        except DebuggerError as e:
+            self.out.event("output", {"category": "stderr", "output": f"[dbgcopilot] {e}\n"})
+            self.out.event("terminated")
+            return
+        self._report(event)
+
+    def _step(self, method: str) -> None:
+        debugger = self._need_debugger()
+        if not callable(getattr(debugger, method, None)):
+            raise DapError(f"{getattr(debugger, 'name', 'This debugger')} cannot step")
+
+        def run() -> None:
+            self.refs.invalidate()
+            self._thread = None
+            try:
+                event = getattr(debugger, method)()
+            except DebuggerError as e:
+                # The target is still stopped where it was (or already gone): report and keep the session.
+                self.out.event("output", {"category": "stderr", "output": f"[dbgcopilot] {e}\n"})
+                return
+            self._report(event)
+
+        self._then = run
+
+    def _watch(self, debugger: Any, timeout: float) -> StopEvent:
+        from dbgcopilot.debugger.hang import watch_for_hang
+
+        result = watch_for_hang(debugger, timeout)
+        if result.runtime_deadlock and result.stop is not None and result.dump is not None:
+            result.stop.raw = f"{result.describe()}\n{condense_goroutine_output(result.dump.raw)}"
+            return result.stop
+        if not result.hung and result.stop is not None:
+            return result.stop
+        raw = condense_goroutine_output(result.dump.raw) if result.dump is not None else ""
+        return StopEvent(reason=STOP_STOPPED, detail=REASON_HANG, raw=f"{result.describe()}\n{raw}".strip())
+
+    def _report(self, event: StopEvent) -> None:
+        if event.exited:
+            self.out.event("output", {"category": "console", "output": event.describe() + "\n"})
+            self.out.event("exited", {"exitCode": event.exit_code or 0})
+            self.out.event("terminated")
+            return
+        self._thread = event.goroutine_id if event.goroutine_id is not None else self._current_thread()
+        body: Dict[str, Any] = {
+            "reason": _DAP_STOP_REASONS.get(event.reason, "pause"),
+            "description": event.describe(),
+            "threadId": self._thread,
+            "allThreadsStopped": True,
+        }
+        if event.detail:
+            body["text"] = event.detail
+        # Goroutines that reached breakpoints together are reported by the same stop.
+        hit_ids = [hit.breakpoint_id for hit in stop_hits(event) if hit.breakpoint_id is not None]
+        if hit_ids:
+            body["hitBreakpointIds"] = hit_ids
+        self.out.event("stopped", body)
+        hung = event.detail == REASON_HANG or getattr(self.debugger, "hung", None) is not None
+        if event.reason in ANALYZE_REASONS or (event.reason == STOP_STOPPED and hung):
+            self._analyze(event, REASON_HANG if event.reason == STOP_STOPPED else event.reason)
+
+    def _current_thread(self) -> int:
+        try:
+            dump = self._need_debugger().goroutines()
+        except DebuggerError:
+            return 1
+        for g in dump.goroutines:
+            if g.current:
+                return g.id
+        return dump.goroutines[0].id if dump.goroutines else 1
+
+    # ------------------------------------------------------------------
+    # Analysis.
+
+    def _analyze(self, event: StopEvent, reason: str) -> Dict[str, Any]:
+        debugger = self._need_debugger()
+        name = getattr(debugger, "name", "") or "debugger"
+        sections = [event.describe(), event.raw]
+        if reason != REASON_HANG:
+            try:
+                sections.append(condense_goroutine_output(debugger.goroutines().raw))
+            except DebuggerError:
+                pass
+        text = "\n".join(s for s in sections if s)
+        findings = findings_for_output(text)
+        analysis = ""
+        if self.llm is not None:
+            template = DEFAULT_PROMPT_CONFIG["dap_stop_analysis"]
+            redacted = Redactor.from_config(self.config).redact(text)
+            try:
+                analysis = self.llm(template.format(debugger=name, event=redacted, findings=findings or "(none)"))
+            except Exception as e:
+                analysis = f"(LLM analysis failed: {e})"
+        body = {
+            "reason": reason,
+            "description": event.describe(),
+            "threadId": self._thread,
+            "findings": findings,
+            "analysis": analysis,
+        }
+        output = "\n".join(s for s in (f"[dbgcopilot] {reason}: {event.describe()}", findings, analysis) if s)
+        self.out.event("output", {"category": "console", "output": output + "\n"})
+        self.out.event(ANALYSIS_EVENT, body)
+        return body
+
+    # ------------------------------------------------------------------
+    # Inspection.
+
+    def _threads(self, args: Dict[str, Any]) -> Dict[str, Any]:
+        dump = self._need_debugger().goroutines()
+        threads = []
+        for g in dump.goroutines:
+            top = g.top_user_frame() or (g.frames[0] if g.frames else None)
+            label = f"{g.id}{f' [{g.state}]' if g.state else ''}{f' {top.function}' if top else ''}"
+            threads.append({"id": g.id, "name": label})
+        if not threads:
+            threads.append({"id": self._thread or 1, "name": "main"})
+        return {"threads": threads}
+
+    def _stack_trace(self, args: Dict[str, Any]) -> Dict[str, Any]:
+        thread_id = int(args.get("threadId") or self._thread or 0) or None
+        start = int(args.get("startFrame") or 0)
+        levels = int(args.get("levels") or 0)
+        frames = self._need_debugger().stacktrace(thread_id, depth=max(_STACK_LIMIT, start + levels + 1))
+        tid = thread_id or self._thread or 1
+        stack = []
+        for index, frame in enumerate(frames[start : start + levels if levels else None], start):
+            entry: Dict[str, Any] = {
+                "id": self.refs.frame(tid, index, frame),
+                "name": frame.function,
+                "line": frame.line,
+                "column": 1,
+            }
+            if frame.file:
+                path = local_path(frame.file)
+                entry["source"] = {"name": Path(path).name, "path": path}
+            if frame.is_runtime():
+                entry["presentationHint"] = "subtle"
+            stack.append(entry)
+        return {"stackFrames": stack, "totalFrames": len(frames)}
+
+    def _innermost(self, ref: FrameRef) -> bool:
+        return ref.index == 0 and (self._thread is None or ref.thread_id == self._thread)
+
+    def _frame_locals(self) -> Optional[Callable[..., List[Variable]]]:
+        frame_locals = getattr(self.debugger, "frame_locals", None)
+        return frame_locals if callable(frame_locals) else None
+
+    def _locals_command(self, ref: FrameRef) -> str:
+        name = getattr(self.debugger, "name", "")
+        command, any_frame = _LOCALS_COMMANDS.get(name, ("", False))
+        if not command or not (any_frame or self._innermost(ref)):
+            return ""
+        return command.format(thread=ref.thread_id, index=ref.index)
+
+    def _scopes(self, args: Dict[str, Any]) -> Dict[str, Any]:
+        frame_id = int(args.get("frameId") or 0)
+        ref = self.refs.get(frame_id, FrameRef)
+        if self._frame_locals() is None and not self._locals_command(ref):
+            return {"scopes": []}
+        scope = {"name": SCOPE_LOCALS, "presentationHint": "locals", "expensive": False}
+        return {"scopes": [{**scope, "variablesReference": self.refs.scope(frame_id)}]}
+
+    def _variables(self, args: Dict[str, Any]) -> Dict[str, Any]:
+        handle = int(args.get("variablesReference") or 0)
+        ref = self.refs.resolve(handle)
+        if isinstance(ref, ScopeRef):
+            frame = self.refs.get(ref.frame_id, FrameRef)
+            frame_locals = self._frame_locals()
+            if frame_locals is not None:
+                # Structured values carry their children, so composites expand in the editor.
+                values = frame_locals(frame.thread_id, frame.index)
+            else:
+                values = parse_locals(self._need_debugger().run_command(self._locals_command(frame)))
+            frame_id: Optional[int] = ref.frame_id
+        elif isinstance(ref, VariableRef):
+            values, frame_id = ref.variable.children, ref.frame_id
+        else:
+            raise DapError(f"reference {handle} is a frame, not a variables reference")
+        return {"variables": [self._variable(handle, v, frame_id) for v in values]}
+
+    def _variable(self, parent: Any, var: Variable, frame_id: Optional[int]) -> Dict[str, Any]:
+        entry: Dict[str, Any] = {
+            "name": var.name,
+            "value": var.unreadable or var.value,
+            "variablesReference": self.refs.variable(parent, var, frame_id),
+        }
+        if var.type:
+            entry["type"] = var.type
+        return entry
+
+    def _evaluate(self, args: Dict[str, Any]) -> Dict[str, Any]:
+        debugger = self._need_debugger()
+        expr = str(args.get("expression") or "").strip()
+        if not expr:
+            raise DapError("evaluate requires an expression")
+        frame_id = int(args["frameId"]) if args.get("frameId") else None
+        ref = self.refs.get(frame_id, FrameRef) if frame_id is not None else None
+        if ref is None or self._innermost(ref):
+            var = debugger.read_variable(expr)
+        elif callable(getattr(debugger, "eval_in_frame", None)):
+            var = debugger.eval_in_frame(ref.thread_id, ref.index, expr)
+        else:
+            raise DapError("Expressions can only be evaluated in the innermost frame of the stopped thread")
+        entry = self._variable(expr, var, frame_id)
+        return {"result": entry["value"], "type": var.type, "variablesReference": entry["variablesReference"]}
+
+
+def main(argv: Optional[List[str]] = None) -> int:
+    parser = argparse.ArgumentParser(
+        prog="dbgcopilot-dap", description="Serve the Debug Adapter Protocol over stdio or a TCP port"
+    )
+    parser.add_argument("--port", type=int, default=None, help="Listen on 127.0.0.1:PORT instead of stdio")
+    parser.add_argument("--llm-provider", default=None, help="Provider that explains crashes and hangs")
+    args = parser.parse_args(argv)
+
+    llm = None
+    if args.llm_provider:
+        from dbgcopilot.llm import providers
+
+        llm = providers.create_cached_client(args.llm_provider, {})
+    if args.port is None:
+        DapServer(sys.stdin.buffer, sys.stdout.buffer, llm=llm).serve()
+        return 0
+    with socket.create_server(("127.0.0.1", args.port)) as listener:
+        print(f"[dbgcopilot-dap] listening on 127.0.0.1:{listener.getsockname()[1]}", file=sys.stderr)
+        conn, _ = listener.accept()
+        with conn, conn.makefile("rb") as reader, conn.makefile("wb") as writer:
+            DapServer(reader, writer, llm=llm).serve()
+    return 0
+
+
+__all__ = ["ANALYSIS_EVENT", "CAPABILITIES", "DapServer", "main", "parse_hit_condition", "parse_locals"]
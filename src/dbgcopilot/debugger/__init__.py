@@ -0,0 +1,71 @@
+"""Structured debugger drivers (Delve, LLDB, pdb) behind a common interface: live, attached, post-mortem or remote."""
+from __future__ import annotations
+
+from .base import (
+    Breakpoint,
+    BreakpointHit,
+    BreakpointSpec,
+    Debugger,
+    DebuggerError,
+    DebuggerUnavailable,
+    LoadConfig,
+    OptimizedAwayError,
+    PostMortemError,
+    ProcessExitedError,
+    StopEvent,
+    Variable,
+    WatchHit,
+    Watchpoint,
+    WatchpointLimitError,
+    describe_placed,
+    place_breakpoints,
+)
+from .events import POLICY_RESUME, POLICY_STOP, BreakpointEvents
+from .factory import (
+    attach,
+    attached,
+    connect,
+    detect_backend,
+    open_core,
+    open_debugger,
+    resolve_backend,
+    start_session,
+)
+from .loops import DEFAULT_AGGREGATE_THRESHOLD, HitAggregator
+from .pretty import pretty_print
+from .ptrace import PtracePermissionError
+
+__all__ = [
+    "DEFAULT_AGGREGATE_THRESHOLD",
+    "POLICY_RESUME",
+    "POLICY_STOP",
+    "Breakpoint",
+    "BreakpointEvents",
+    "BreakpointHit",
+    "BreakpointSpec",
+    "Debugger",
+    "DebuggerError",
+    "DebuggerUnavailable",
+    "HitAggregator",
+    "LoadConfig",
+    "OptimizedAwayError",
+    "PostMortemError",
+    "ProcessExitedError",
+    "PtracePermissionError",
+    "StopEvent",
+    "Variable",
+    "WatchHit",
+    "Watchpoint",
+    "WatchpointLimitError",
+    "attach",
+    "attached",
+    "connect",
+    "describe_placed",
+    "detect_backend",
+    "open_core",
+    "open_debugger",
+    "place_breakpoints",
+    "pretty_print",
+    "resolve_backend",
+    "start_session",
+]
@@ -0,0 +1,541 @@
+"""Structured debugger interface shared by the Delve and LLDB drivers.
+
+The text backends in ``dbgcopilot.backends`` only proxy raw commands. The
+drivers in this package build on them and parse the output into common
+shapes (``Frame``, ``GoroutineDump``) so analyzers and prompt builders never
+need per-backend branches. Native debuggers report OS threads through the
+same ``goroutines()`` call.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Mapping, Optional, Protocol, Union
+import re
+
+from dbgcopilot.analyze.goroutines import Frame, GoroutineDump
+
+
+STOP_BREAKPOINT = "breakpoint"
+STOP_WATCHPOINT = "watchpoint"
+# The watched variable left scope; the watchpoint has been removed.
+STOP_WATCH_SCOPE = "watch-out-of-scope"
+STOP_PANIC = "panic"
+STOP_FATAL = "fatal"
+# An uncaught exception ended the script (Python); the session is post-mortem from then on.
+STOP_EXCEPTION = "exception"
+STOP_SIGNAL = "signal"
+STOP_EXITED = "exited"
+STOP_STOPPED = "stopped"
+# A step, next or step-out finished where it was meant to, in the goroutine (or thread) it started in.
+STOP_STEP = "step"
+
+STEP_INTO = "step"
+STEP_OVER = "next"
+STEP_OUT = "stepout"
+# StopEvent.detail prefix of a step that a breakpoint ended early.
+STEP_INTERRUPTED = "step interrupted"
+
+WATCH_READ = "read"
+WATCH_WRITE = "write"
+WATCH_READWRITE = "readwrite"
+WATCH_KINDS = (WATCH_READ, WATCH_WRITE, WATCH_READWRITE)
+# x86-64 and arm64 both commonly expose four hardware debug registers.
+MAX_HARDWARE_WATCHPOINTS = 4
+
+
+class DebuggerError(RuntimeError):
+    """A debugger command failed; the message carries the debugger's own error text."""
+
+
+class DebuggerUnavailable(DebuggerError):
+    """The requested debugger executable or library is not installed."""
+
+
+class WatchpointLimitError(DebuggerError):
+    """No hardware debug register is left for another watchpoint."""
+
+
+class PostMortemError(DebuggerError):
+    """Execution control was requested on a core dump, which has no live process."""
+
+
+class ProcessExitedError(DebuggerError):
+    """Execution control was requested after the target process exited."""
+
+    def __init__(self, command: str, exit_code: Optional[int] = None) -> None:
+        self.command = command
+        self.exit_code = exit_code
+        status = f" with status {exit_code}" if exit_code is not None else ""
+        super().__init__(
+            f"Cannot {command}: the process has exited{status}. Restart it before stepping again, "
+            "or inspect the output it left behind."
+        )
+
+
+class OptimizedAwayError(DebuggerError):
+    """The expression uses a variable the compiler optimized away at that point of the frame."""
+
+    def __init__(self, expr: str, reason: str, *, goroutine_id: Optional[int] = None, frame: int = 0) -> None:
+        self.expr = expr
+        self.reason = reason
+        self.goroutine_id = goroutine_id
+        self.frame = frame
+        where = f"frame {frame}" + (f" of goroutine {goroutine_id}" if goroutine_id is not None else "")
+        super().__init__(
+            f"Cannot evaluate '{expr}' in {where}: a variable it uses has been optimized away ({reason}). "
+            "Try a caller frame, or rebuild with -gcflags=all='-N -l' to keep locals."
+        )
+
+
+# How Delve, LLDB and gdb report a value the optimizer did not keep (no location at this PC).
+_OPTIMIZED_AWAY_RE = re.compile(
+    r"optimi[sz]ed (?:away|out)|could not find loclist entry|variable not available|empty OP stack", re.IGNORECASE
+)
+
+
+def optimized_away(text: str) -> bool:
+    return bool(_OPTIMIZED_AWAY_RE.search(text or ""))
+
+
+def post_mortem_message(command: str, core: str) -> str:
+    return (
+        f"'{command}' is unavailable in post-mortem mode: {core} is a core dump, so there is "
+        "no live process to resume or step. Inspect the captured state instead "
+        "(goroutines/threads, stack traces, frame selection, printing variables)."
+    )
+
+
+_SPEC_HITCOUNT_RE = re.compile(r"\s+hitcount\s+(\d+)\s*$")
+_SPEC_IF_RE = re.compile(r"\s+if\s+")
+# "-r 'worker.*'", "-r worker.*" or Delve's own "/worker.*/".
+_SPEC_REGEX_RE = re.compile(r"^(?:-r\s+(?:'([^']*)'|\"([^\"]*)\"|(\S+))|/(.+)/)$")
+
+
+@dataclass
+class BreakpointSpec:
+    """Where to stop, plus an optional condition and hit count.
+
+    ``location`` is a file:line, a function name (``main.workerOne``) or an
+    address; with ``regex`` it is a pattern and every function it matches
+    gets a breakpoint (``Debugger.set_breakpoints``). ``hit_count`` N means
+    the first N-1 hits are skipped and execution stops from the Nth hit on;
+    ``condition`` is evaluated in the scope of the breakpoint location.
+    """
+
+    location: str
+    condition: str = ""
+    hit_count: int = 0
+    regex: bool = False
+
+    @classmethod
+    def parse(cls, text: str) -> "BreakpointSpec":
+        """Parse ``<location>|-r <regex> [if <condition>] [hitcount <n>]``."""
+        rest = (text or "").strip()
+        hit_count = 0
+        m = _SPEC_HITCOUNT_RE.search(rest)
+        if m:
+            hit_count = int(m.group(1))
+            rest = rest[: m.start()]
+        location, condition = rest, ""
+        m = _SPEC_IF_RE.search(rest)
+        if m:
+            location, condition = rest[: m.start()], rest[m.end() :]
+        if not location.strip():
+            raise DebuggerError("Breakpoint location is required")
+        m = _SPEC_REGEX_RE.match(location.strip())
+        if m:
+            pattern = next(group for group in m.groups() if group is not None)
+            try:
+                re.compile(pattern)
+            except re.error as e:
+                raise DebuggerError(f"Invalid breakpoint regex {pattern!r}: {e}") from None
+            return cls(location=pattern, condition=condition.strip(), hit_count=hit_count, regex=True)
+        return cls(location=location.strip(), condition=condition.strip(), hit_count=hit_count)
+
+    def describe(self) -> str:
+        return f"regex {self.location!r}" if self.regex else self.location
+
+
+def as_spec(spec: Union[str, BreakpointSpec]) -> BreakpointSpec:
+    return spec if isinstance(spec, BreakpointSpec) else BreakpointSpec(location=spec)
+
+
+def place_breakpoints(debugger: Any, spec: Union[str, BreakpointSpec]) -> List["Breakpoint"]:
+    """Every breakpoint ``spec`` sets on ``debugger``: one, or one per function a regex matched (maybe none)."""
+    spec = as_spec(spec)
+    if not spec.regex:
+        return [debugger.set_breakpoint(spec)]
+    if not callable(getattr(debugger, "set_breakpoints", None)):
+        name = getattr(debugger, "name", "") or "this debugger"
+        raise DebuggerError(f"Regex breakpoints need Delve; {name} takes a file:line or a function name")
+    return debugger.set_breakpoints(spec)
+
+
+def describe_placed(spec: Union[str, BreakpointSpec], placed: List["Breakpoint"]) -> str:
+    """What ``place_breakpoints`` did, with a warning when a regex matched nothing."""
+    spec = as_spec(spec)
+    if not spec.regex:
+        return "\n".join(f"Breakpoint {bp.describe()}" for bp in placed)
+    if not placed:
+        return f"Warning: {spec.describe()} matched no function; no breakpoint was set."
+    lines = [f"Regex {spec.location!r} matched {len(placed)} function(s); breakpoints:"]
+    lines.extend(f"  {bp.describe()}" for bp in placed)
+    return "\n".join(lines)
+
+
+@dataclass
+class Breakpoint:
+    id: int
+    location: str
+    function: str = ""
+    file: str = ""
+    line: int = 0
+    address: str = ""
+    condition: str = ""
+    hit_count: int = 0
+    # Times the breakpoint has been reached so far, as reported by the debugger.
+    hits: int = 0
+    enabled: bool = True
+
+    def describe(self) -> str:
+        where = self.location
+        if self.function and self.file:
+            where = f"{self.function} at {self.file}:{self.line}"
+        parts = [f"{self.id}: {where}"]
+        if self.condition:
+            parts.append(f"if {self.condition}")
+        if self.hit_count:
+            parts.append(f"from hit {self.hit_count}")
+        parts.append(f"(hits: {self.hits}{'' if self.enabled else ', disabled'})")
+        return " ".join(parts)
+
+
+def watch_kind(kind: str) -> str:
+    choice = (kind or WATCH_WRITE).strip().lower().replace("-", "").replace("_", "")
+    aliases = {"r": WATCH_READ, "w": WATCH_WRITE, "rw": WATCH_READWRITE, "readwrite": WATCH_READWRITE}
+    choice = aliases.get(choice, choice)
+    if choice not in WATCH_KINDS:
+        raise DebuggerError(f"Unknown watchpoint kind '{kind}'; choose one of: {', '.join(WATCH_KINDS)}")
+    return choice
+
+
+def watch_limit_message(active: List["Watchpoint"], limit: int = MAX_HARDWARE_WATCHPOINTS) -> str:
+    names = ", ".join(w.expr for w in active) or "none tracked"
+    return (
+        f"Hardware watchpoint limit reached: {len(active)} of {limit} debug registers in use ({names}). "
+        "Clear one before watching another expression."
+    )
+
+
+@dataclass
+class Watchpoint:
+    id: int
+    expr: str
+    kind: str = WATCH_WRITE
+    address: str = ""
+    # Last value seen, used as the "old" value of the next hit.
+    value: str = ""
+    # Function owning a local variable, for debuggers that do not clear stack watchpoints themselves.
+    scope: str = ""
+
+
+@dataclass
+class WatchHit:
+    """What a fired watchpoint changed and where."""
+
+    watchpoint: Watchpoint
+    old_value: str = ""
+    new_value: str = ""
+    frame: Optional[Frame] = None
+    goroutine_id: Optional[int] = None
+
+    def describe(self) -> str:
+        wp = self.watchpoint
+        lines = [f"Watchpoint {wp.id} ({wp.kind}) on {wp.expr} fired"]
+        if self.old_value != self.new_value:
+            lines.append(f"  old value: {self.old_value or '(unknown)'}")
+            lines.append(f"  new value: {self.new_value or '(unknown)'}")
+        else:
+            lines.append(f"  value: {self.new_value or '(unknown)'} (accessed, unchanged)")
+        if self.frame is not None:
+            who = f" in goroutine {self.goroutine_id}" if self.goroutine_id is not None else ""
+            lines.append(f"  by {self.frame.function} at {self.frame.location}{who}")
+        return "\n".join(lines)
+
+
+def _new_frame_list() -> List[Frame]:
+    return []
+
+
+@dataclass
+class BreakpointHit:
+    """One goroutine (or thread) sitting on a breakpoint when the program stopped.
+
+    Goroutines that reach breakpoints at nearly the same moment are reported
+    by one stop, so a ``StopEvent`` carries all of them in ``hits``.
+    """
+
+    breakpoint_id: Optional[int] = None
+    goroutine_id: Optional[int] = None
+    frame: Optional[Frame] = None
+    # Named breakpoints ("[myname]") are identified by name instead of id.
+    name: str = ""
+    # The hit in the goroutine the debugger selected, where StopEvent.frame points.
+    selected: bool = False
+    unit: str = "goroutine"
+    # Set by BreakpointEvents: delivery order and the stop the hit came from, both counted from 1.
+    seq: int = 0
+    stop_seq: int = 0
+    # The goroutine's stack captured before anything resumed (BreakpointEvents fills it).
+    stack: List[Frame] = field(default_factory=_new_frame_list)
+
+    def describe(self) -> str:
+        who = f"{self.unit} {self.goroutine_id}" if self.goroutine_id is not None else "the program"
+        which = self.name or (str(self.breakpoint_id) if self.breakpoint_id is not None else "?")
+        where = f" at {self.frame.function} {self.frame.location}" if self.frame is not None else ""
+        return f"{who} hit breakpoint {which}{where}"
+
+
+def _new_hit_list() -> List[BreakpointHit]:
+    return []
+
+
+@dataclass
+class StopEvent:
+    reason: str
+    frame: Optional[Frame] = None
+    goroutine_id: Optional[int] = None
+    breakpoint_id: Optional[int] = None
+    exit_code: Optional[int] = None
+    detail: str = ""
+    raw: str = ""
+    watch: Optional[WatchHit] = None
+    # Filled by step/next/step_out: the stack of the goroutine (or thread) the stop landed in.
+    stack: List[Frame] = field(default_factory=_new_frame_list)
+    # Every breakpoint hit this stop reported, the selected one first (see ``stop_hits``).
+    hits: List[BreakpointHit] = field(default_factory=_new_hit_list)
+
+    @property
+    def exited(self) -> bool:
+        return self.reason == STOP_EXITED
+
+    @property
+    def clean_exit(self) -> bool:
+        """The program ran to completion with status 0: there is nothing to diagnose."""
+        return self.exited and self.exit_code == 0
+
+    def describe(self) -> str:
+        if self.exited:
+            return f"Process exited with status {self.exit_code}"
+        if self.watch is not None:
+            return self.watch.describe()
+        head = f"Stopped ({self.reason})"
+        if self.reason == STOP_BREAKPOINT and self.detail.startswith(STEP_INTERRUPTED):
+            head = f"Stopped ({self.detail})"
+        if self.detail and self.reason != STOP_STOPPED:
+            head = f"Stopped ({self.reason}: {self.detail})"
+        if self.frame is not None:
+            head += f" at {self.frame.function} {self.frame.location}"
+        others = [hit.describe() for hit in self.hits if not hit.selected]
+        if others:
+            head += "; also " + "; ".join(others)
+        return head
+
+
+def stop_hits(event: StopEvent) -> List[BreakpointHit]:
+    """Breakpoint hits of ``event``; a backend that reports none gets one built from the stop itself."""
+    if event.hits:
+        return event.hits
+    if event.reason != STOP_BREAKPOINT:
+        return []
+    return [
+        BreakpointHit(
+            breakpoint_id=event.breakpoint_id,
+            goroutine_id=event.goroutine_id,
+            frame=event.frame,
+            name="" if event.breakpoint_id is not None else event.detail,
+            selected=True,
+        )
+    ]
+
+
+def finish_step(
+    event: StopEvent, command: str, before: Optional[int], *, pending: bool = False, unit: str = "goroutine"
+) -> StopEvent:
+    """Label the stop that ended ``command`` (``STEP_INTO``/``STEP_OVER``/``STEP_OUT``).
+
+    A step that ran to completion becomes ``STOP_STEP``. Stepping only
+    follows the goroutine (``unit``: or thread) it started in, ``before``,
+    while the rest of the program runs, so a breakpoint hit elsewhere can
+    end it early in another goroutine; such a stop keeps its breakpoint
+    reason and ``detail`` says which goroutine was stepping and whether the
+    step is still ``pending`` (Delve finishes it on the next continue).
+    """
+    if event.frame is None and event.stack:
+        event.frame = event.stack[0]
+    switched = before is not None and event.goroutine_id not in (None, before)
+    if event.reason == STOP_BREAKPOINT and (switched or pending):
+        where = f"{unit} {event.goroutine_id}" if event.goroutine_id is not None else f"another {unit}"
+        during = f"while {unit} {before} was running '{command}'" if before is not None else f"during '{command}'"
+        event.detail = f"{STEP_INTERRUPTED}: {where} hit breakpoint {event.breakpoint_id or event.detail} {during}"
+        if pending:
+            event.detail += "; the step is still pending and 'continue' finishes it"
+    elif event.reason == STOP_STOPPED:
+        event.reason = STOP_STEP
+        event.detail = command
+        if switched:
+            event.detail += f" (started in {unit} {before}, now in {unit} {event.goroutine_id})"
+    return event
+
+
+# LoadConfig attribute -> session config key.
+_LOAD_CONFIG_KEYS = (
+    ("max_depth", "load_depth"),
+    ("max_array_values", "load_array_values"),
+    ("max_string_len", "load_string_len"),
+)
+
+
+def _new_variable_list() -> List["Variable"]:
+    return []
+
+
+@dataclass
+class Variable:
+    """A value read from the target.
+
+    Drivers that only see the debugger's text output fill ``value``; drivers
+    with structured replies (Delve JSON-RPC) also fill ``kind`` and
+    ``children`` so ``pretty.pretty_print`` can lay the value out. ``length``
+    and ``cap`` are -1 when they do not apply; a ``length`` larger than the
+    loaded children (or string) means the debugger truncated it.
+    """
+
+    name: str
+    value: str
+    type: str = ""
+    kind: str = ""
+    address: int = 0
+    length: int = -1
+    cap: int = -1
+    children: List["Variable"] = field(default_factory=_new_variable_list)
+    unreadable: str = ""
+
+
+@dataclass
+class LoadConfig:
+    """How much of a value the debugger loads; mirrors Delve's ``api.LoadConfig``.
+
+    ``max_depth`` is how many levels of nested structs, pointers, slices and
+    maps are followed; ``max_struct_fields`` of -1 loads every field.
+    """
+
+    max_depth: int = 1
+    max_array_values: int = 64
+    max_string_len: int = 64
+    max_struct_fields: int = -1
+    follow_pointers: bool = True
+
+    @classmethod
+    def from_config(cls, config: Optional[Mapping[str, Any]], **overrides: int) -> "LoadConfig":
+        """Session keys ``load_depth``, ``load_array_values`` and ``load_string_len``, then ``overrides``."""
+        cfg = config or {}
+        values: Dict[str, int] = {}
+        for attr, key in _LOAD_CONFIG_KEYS:
+            raw = overrides.get(attr, cfg.get(key))
+            if raw in (None, ""):
+                continue
+            try:
+                values[attr] = int(raw)
+            except (TypeError, ValueError):
+                raise DebuggerError(f"{key} must be an integer, got {raw!r}") from None
+            if values[attr] < 0:
+                raise DebuggerError(f"{key} must not be negative")
+        return cls(**values)
+
+    def to_rpc(self) -> Dict[str, Any]:
+        return {
+            "FollowPointers": self.follow_pointers,
+            "MaxVariableRecurse": self.max_depth,
+            "MaxStringLen": self.max_string_len,
+            "MaxArrayValues": self.max_array_values,
+            "MaxStructFields": self.max_struct_fields,
+        }
+
+
+# Locals of pinned goroutines (dbgcopilot.analyze.pins) are loaded this far, whatever the session's limits.
+DEEP_LOAD = LoadConfig(max_depth=4, max_array_values=256, max_string_len=512)
+
+
+class Debugger(Protocol):
+    name: str
+    # True when inspecting a core file; continue_() raises PostMortemError.
+    post_mortem: bool
+
+    def run_command(self, cmd: str, timeout: float | None = None) -> str:  # pragma: no cover
+        ...
+
+    def initialize_session(self) -> None:  # pragma: no cover
+        ...
+
+    def set_breakpoint(self, spec: Union[str, BreakpointSpec]) -> Breakpoint:  # pragma: no cover
+        ...
+
+    # Optional: one breakpoint per function a regex spec matches (Delve only; see ``place_breakpoints``).
+    def set_breakpoints(self, spec: BreakpointSpec) -> List[Breakpoint]:  # pragma: no cover
+        ...
+
+    def breakpoints(self) -> List[Breakpoint]:  # pragma: no cover
+        ...
+
+    def clear_breakpoint(self, breakpoint_id: int) -> None:  # pragma: no cover
+        ...
+
+    def set_watchpoint(self, expr: str, kind: str = WATCH_WRITE) -> Watchpoint:  # pragma: no cover
+        ...
+
+    def watchpoints(self) -> List[Watchpoint]:  # pragma: no cover
+        ...
+
+    def clear_watchpoint(self, watchpoint_id: int) -> None:  # pragma: no cover
+        ...
+
+    def continue_(self) -> StopEvent:  # pragma: no cover
+        ...
+
+    # Step into calls, over them, or out of the current function. Each returns the
+    # stop with its ``stack``; ProcessExitedError once the process is gone.
+    def step(self) -> StopEvent:  # pragma: no cover
+        ...
+
+    def next(self) -> StopEvent:  # pragma: no cover
+        ...
+
+    def step_out(self) -> StopEvent:  # pragma: no cover
+        ...
+
+    def stacktrace(self, goroutine_id: Optional[int] = None, depth: int = 50) -> List[Frame]:  # pragma: no cover
+        ...
+
+    def goroutines(self) -> GoroutineDump:  # pragma: no cover
+        ...
+
+    def read_variable(self, expr: str, cfg: Optional[LoadConfig] = None) -> Variable:  # pragma: no cover
+        ...
+
+    # Frame 0 is the innermost; goroutine_id None means the current goroutine (or thread).
+    def eval_in_frame(
+        self, goroutine_id: Optional[int], frame: int, expr: str, cfg: Optional[LoadConfig] = None
+    ) -> Variable:  # pragma: no cover
+        ...
+
+    def detach(self) -> None:  # pragma: no cover
+        ...
+
+    def close(self) -> None:  # pragma: no cover
+        ...
+
+    # End the session after its context was cancelled, even mid-command: a launched
+    # process is killed, an attached one detached. Delve's waits honor the context.
+    def abort(self) -> None:  # pragma: no cover
+        ...
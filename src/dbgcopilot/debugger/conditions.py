@@ -0,0 +1,100 @@
+"""Check that a breakpoint condition only names variables in scope at its location.
+
+Neither Delve nor LLDB evaluates a condition until the breakpoint is hit, so
+a typo or an out-of-scope name would otherwise show up much later as an
+evaluation error (or a breakpoint that never fires). The check reads the
+source: names declared in the enclosing function before the breakpoint line,
+package-level declarations and imported package names are in scope. When
+the source file is not available nothing is reported.
+"""
+from __future__ import annotations
+
+from typing import List, Optional, Set
+import re
+
+from dbgcopilot.utils.source import SourceCache
+
+
+_IDENT_RE = re.compile(r"(?<![\w.])([A-Za-z_]\w*)")
+_STRING_RE = re.compile(r'"(?:\\.|[^"\\])*"|`[^`]*`|\'(?:\\.|[^\'\\])*\'')
+_FUNC_START_RE = re.compile(r"^func\s+(?:\([^)]*\)\s*)?(\w+)?")
+_IMPORT_PATH_RE = re.compile(r'(?:^|\s)(?:([A-Za-z_]\w*)\s+)?"([^"]+)"')
+
+# Names every Go expression (and Delve's evaluator) understands without a declaration.
+_UNIVERSE = {
+    "true", "false", "nil", "iota", "len", "cap", "append", "make", "new", "complex", "real", "imag",
+    "min", "max", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64",
+    "uintptr", "float32", "float64", "string", "byte", "rune", "bool", "error", "any",
+    # Delve exposes the runtime package for expressions like runtime.curg.goid.
+    "runtime",
+}
+
+
+def condition_names(expr: str) -> List[str]:
+    """Root identifiers referenced by ``expr`` (``s.count > 3`` -> ``["s"]``)."""
+    names: List[str] = []
+    for m in _IDENT_RE.finditer(_STRING_RE.sub('""', expr or "")):
+        name = m.group(1)
+        if name not in _UNIVERSE and name not in names:
+            names.append(name)
+    return names
+
+
+def _idents(text: str) -> Set[str]:
+    return set(_IDENT_RE.findall(_STRING_RE.sub('""', text)))
+
+
+def scope_names(lines: List[str], line: int) -> Set[str]:
+    """Names visible just before 1-based ``line``: enclosing function so far plus package scope."""
+    names: Set[str] = set()
+    func_start: Optional[int] = None
+    in_func = False
+    in_import = False
+    for idx, text in enumerate(lines, start=1):
+        m = _FUNC_START_RE.match(text)
+        if m:
+            in_func = True
+            if m.group(1):
+                names.add(m.group(1))
+            if idx <= line:
+                func_start = idx
+        if in_func:
+            if text.startswith("}"):
+                in_func = False
+            continue
+        stripped = text.strip()
+        if stripped.startswith("import"):
+            in_import = stripped.endswith("(")
+            _add_imports(stripped, names)
+            continue
+        if in_import:
+            in_import = not stripped.startswith(")")
+            _add_imports(stripped, names)
+            continue
+        names |= _idents(text)
+    if func_start is not None:
+        # The signature line always counts (parameters are visible on the first line).
+        end = max(func_start, line - 1)
+        for text in lines[func_start - 1 : end]:
+            names |= _idents(text)
+    return names
+
+
+def _add_imports(text: str, names: Set[str]) -> None:
+    for m in _IMPORT_PATH_RE.finditer(text):
+        alias, path = m.group(1), m.group(2)
+        names.add(alias or path.rsplit("/", 1)[-1])
+
+
+def unknown_names(
+    expr: str, file: str, line: int, sources: Optional[SourceCache] = None
+) -> List[str]:
+    """Names in ``expr`` that are not in scope at ``file:line``; ``[]`` when unknown."""
+    content = (sources or SourceCache()).lines(file)
+    if content is None or not (1 <= line <= len(content)):
+        return []
+    visible = scope_names(content, line)
+    return [name for name in condition_names(expr) if name not in visible]
+
+
+__all__ = ["condition_names", "scope_names", "unknown_names"]
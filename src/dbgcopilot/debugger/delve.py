@@ -0,0 +1,699 @@
+"""Structured Delve driver built on the ``dlv exec`` subprocess backend.
+
+Passing ``core`` starts ``dlv core <binary> <core>`` instead for post-mortem
+inspection: stacks, goroutines and variables work as for a live process, but
+commands that resume or step execution are refused. Passing ``pid`` runs
+``dlv attach <pid>`` against a live process; ``detach()`` (also done by
+``close()``) leaves it running. After the session's ``context`` is
+cancelled, ``abort()`` kills a launched program but only detaches from an
+attached one.
+"""
+from __future__ import annotations
+
+from typing import Any, Dict, List, Optional, Tuple, Union
+import re
+
+try:
+    import pexpect  # type: ignore
+except Exception:  # pragma: no cover - import error surfaced at runtime
+    pexpect = None  # type: ignore
+
+from dbgcopilot.analyze.goroutines import Frame, GoroutineDump, parse_delve_frames, parse_goroutine_dump
+from dbgcopilot.backends.delve_subprocess import DelveSubprocessBackend
+from dbgcopilot.utils.pathmap import active_path_map, local_path
+
+from .base import (
+    STOP_BREAKPOINT,
+    STOP_EXITED,
+    STOP_FATAL,
+    STOP_PANIC,
+    STOP_STOPPED,
+    STOP_WATCH_SCOPE,
+    STOP_WATCHPOINT,
+    STEP_INTO,
+    STEP_OUT,
+    STEP_OVER,
+    MAX_HARDWARE_WATCHPOINTS,
+    WATCH_READ,
+    WATCH_READWRITE,
+    WATCH_WRITE,
+    Breakpoint,
+    BreakpointHit,
+    BreakpointSpec,
+    DebuggerError,
+    LoadConfig,
+    OptimizedAwayError,
+    PostMortemError,
+    ProcessExitedError,
+    StopEvent,
+    Variable,
+    WatchHit,
+    Watchpoint,
+    WatchpointLimitError,
+    as_spec,
+    finish_step,
+    optimized_away,
+    post_mortem_message,
+    watch_kind,
+    watch_limit_message,
+)
+from .conditions import unknown_names
+from .ptrace import PtracePermissionError, check_attach, is_permission_error, permission_message, ptrace_scope
+
+
+_BREAKPOINT_RE = re.compile(
+    r"Breakpoint\s+(\d+)(?:\s+\(enabled\))?\s+set at\s+(0x[0-9a-fA-F]+)\s+for\s+(\S+?)\(\)\s+(\S+):(\d+)"
+)
+# "> goroutine(7): main.f() ..." when the thread stopped on is running another goroutine than the selected one.
+_STOP_RE = re.compile(
+    r"^>\s+(?:\[([^\]]+)\]\s+)?(?:goroutine\((\d+)\):\s+)?(\S+?)\(\)\s+(\S+):(\d+)"
+    r"(?:\s+\(hits goroutine\((\d+)\):\d+ total:\d+\))?",
+    re.MULTILINE,
+)
+_EXIT_RE = re.compile(r"Process\s+\d+\s+has exited with status\s+(-?\d+)")
+_CURRENT_GOROUTINE_RE = re.compile(r"^\s*Goroutine\s+(\d+):", re.MULTILINE)
+# Printed when another breakpoint interrupts next/step/stepout; the step stays in progress.
+_STEP_PENDING_RE = re.compile(r"breakpoint hit during (?:next|step|stepout)")
+# One entry of the ``breakpoints`` listing; internal breakpoints have names instead of ids.
+_LIST_RE = re.compile(
+    r"^Breakpoint\s+(\S+)\s+\((enabled|disabled)\)\s+at\s+(0x[0-9a-fA-F]+)"
+    r"(?:\s+for\s+(\S+?)\(\)\s+(\S+):(\d+))?(?:\s+\((\d+)\))?"
+)
+_LIST_COND_RE = re.compile(r"^\s+cond\s+(-hitcount\s+)?(.*)$")
+_HITCOND_RE = re.compile(r"^>=\s*(\d+)$")
+# "Watchpoint 1 on [n] set at 0xc000012f48" (older releases print the expression instead of an id).
+_WATCH_SET_RE = re.compile(r"[Ww]atchpoint\s+(\S+)(?:\s+on\s+\[([^\]]*)\])?\s+set at\s+(0x[0-9a-fA-F]+)")
+_WATCH_STOP_RE = re.compile(
+    r"^>\s+\[[Ww]atchpoint\s+([^\s\]]+)(?:\s+on\s+\[[^\]]*\])?\]\s+(\S+?)\(\)\s+(\S+):(\d+)"
+    r"(?:\s+\(hits goroutine\((\d+)\):\d+ total:\d+\))?",
+    re.MULTILINE,
+)
+_WATCH_SCOPE_RE = re.compile(r"[Ww]atchpoint\s+([^\s\]]+)(?:\s+on\s+\[[^\]]*\])?\s+went out of scope")
+_WATCH_LIMIT_RE = re.compile(
+    r"(?i)(?:hardware|debug register)[^\n]*(?:limit|exhausted|no more|not available)|too many[^\n]*watchpoints"
+)
+# "  Goroutine 7 - User: ./main.go:29 main.workerTwo (0x49a4e5) (thread 4243) [chan receive]"
+_GOROUTINE_AT_RE = re.compile(
+    r"^\s*\*?\s*Goroutine\s+(\d+)\s+-\s+[^:]+:\s+(\S+):(\d+)\s+(\S+)\s+\((0x[0-9a-fA-F]+)\)(.*)$", re.MULTILINE
+)
+# "* Thread 4242 at 0x46a1e3 /usr/lib/go/src/runtime/sys_linux_amd64.s:558 runtime.futex"
+_THREAD_RE = re.compile(r"^\s*\*?\s*Thread\s+(\d+)\s+at\s+", re.MULTILINE)
+_ADDRESS_RE = re.compile(r"^0x[0-9a-fA-F]+$")
+_WATCH_FLAGS = {WATCH_READ: "-r", WATCH_WRITE: "-w", WATCH_READWRITE: "-rw"}
+_FAILED_PREFIX = "Command failed:"
+# A top-level value Delve could not read: "(unreadable could not find loclist entry at 0x4a3c5f ...)".
+_UNREADABLE_RE = re.compile(r"^\(unreadable (.*)\)$", re.DOTALL)
+# One variable of Delve's "locals" output: "name = value".
+_LOCAL_RE = re.compile(r"^(\w+) = (.*)$")
+# Runtime functions whose ``s`` argument is the fatal error message.
+_FATAL_FUNCTIONS = {"runtime.throw", "runtime.fatal"}
+# Delve commands (and aliases) that need a running process.
+_RESUME_COMMANDS = {
+    "continue", "c", "next", "n", "step", "s", "stepout", "so", "step-instruction", "si",
+    "restart", "r", "rebuild", "call", "rev", "rewind",
+}
+
+
+def parse_stop(output: str) -> StopEvent:
+    """Translate Delve's continue/step output into a ``StopEvent``."""
+    text = output or ""
+    m = _EXIT_RE.search(text)
+    if m:
+        return StopEvent(reason=STOP_EXITED, exit_code=int(m.group(1)), raw=text)
+    m = _WATCH_STOP_RE.search(text)
+    if m:
+        return StopEvent(
+            reason=STOP_WATCHPOINT,
+            frame=Frame(function=m.group(2), file=m.group(3), line=int(m.group(4))),
+            goroutine_id=int(m.group(5)) if m.group(5) else None,
+            breakpoint_id=int(m.group(1)) if m.group(1).isdigit() else None,
+            detail=m.group(1),
+            raw=text,
+        )
+    matches = list(_STOP_RE.finditer(text))
+    if not matches:
+        return StopEvent(reason=STOP_STOPPED, detail=text.strip(), raw=text)
+    # The stop is the last "> " line; lines before it are tracepoints passed on the way.
+    m = matches[-1]
+    tag = m.group(1) or ""
+    frame = Frame(function=m.group(3), file=m.group(4), line=int(m.group(5)))
+    gid = m.group(6) or m.group(2)
+    goroutine_id = int(gid) if gid else None
+    reason = STOP_STOPPED
+    breakpoint_id: Optional[int] = None
+    bp = re.match(r"Breakpoint\s+(\d+)", tag)
+    if bp:
+        reason = STOP_BREAKPOINT
+        breakpoint_id = int(bp.group(1))
+    elif tag == "unrecovered-panic":
+        reason = STOP_PANIC
+    elif tag in {"runtime-fatal-throw", "fatal-throw"}:
+        reason = STOP_FATAL
+    elif tag:
+        # Named breakpoints ("[myname]") are reported by name instead of number.
+        reason = STOP_BREAKPOINT
+    hit = _stop_hit(m, True)
+    hits = [hit] if hit is not None else []
+    return StopEvent(
+        reason=reason,
+        frame=frame,
+        goroutine_id=goroutine_id,
+        breakpoint_id=breakpoint_id,
+        detail=tag,
+        raw=text,
+        hits=hits,
+    )
+
+
+def _stop_hit(m: "re.Match[str]", selected: bool) -> Optional[BreakpointHit]:
+    """The breakpoint hit one "> [Breakpoint N] ..." line reports, None for panics and plain stops."""
+    tag = m.group(1) or ""
+    if not tag or tag in {"unrecovered-panic", "runtime-fatal-throw", "fatal-throw"}:
+        return None
+    gid = m.group(6) or m.group(2)
+    bp = re.match(r"Breakpoint\s+(\d+)$", tag)
+    return BreakpointHit(
+        breakpoint_id=int(bp.group(1)) if bp else None,
+        goroutine_id=int(gid) if gid else None,
+        frame=Frame(function=m.group(3), file=m.group(4), line=int(m.group(5))),
+        name="" if bp else tag,
+        selected=selected,
+    )
+
+
+class DelveDebugger(DelveSubprocessBackend):
+    """Delve session exposing the structured ``Debugger`` interface."""
+
+    GOROUTINES_COMMAND = "goroutines -t"
+
+    def __init__(
+        self, program: str = "", *, core: Optional[str] = None, pid: Optional[int] = None, **kwargs: Any
+    ) -> None:
+        # dlv attach finds the executable through /proc, so the program is optional there.
+        super().__init__(program or (f"pid {pid}" if pid else ""), **kwargs)
+        self.core = core
+        self.pid = pid
+        self._executable = program
+        self._detached = False
+        self._resume_buffer = ""
+        self._watchpoints: Dict[int, Watchpoint] = {}
+        # LoadConfig last sent with ``config max-*``; None means Delve's defaults.
+        self._load_config: Optional[LoadConfig] = None
+
+    @property
+    def post_mortem(self) -> bool:
+        return bool(self.core)
+
+    def _launch_args(self) -> List[str]:
+        if self.core:
+            return ["core", self.program, self.core]
+        if self.pid:
+            return ["attach", str(self.pid)] + ([self._executable] if self._executable else [])
+        return super()._launch_args()
+
+    def initialize_session(self) -> None:
+        if not self.pid:
+            super().initialize_session()
+            return
+        check_attach(self.pid)
+        try:
+            super().initialize_session()
+        except RuntimeError as e:
+            if is_permission_error(str(e)):
+                raise PtracePermissionError(permission_message(self.pid, str(e), ptrace_scope())) from e
+            if isinstance(e, DebuggerError):
+                raise
+            raise DebuggerError(str(e)) from e
+        self._detached = False
+
+    def run_command(self, cmd: str, timeout: float | None = None) -> str:
+        if self._detached:
+            return f"[detached] Process {self.pid} was resumed; attach again for a new snapshot."
+        if self.pid:
+            parts = self._split_commands((cmd or "").strip())
+            for idx, part in enumerate(parts):
+                if part.lower() in self._EXIT_COMMANDS:
+                    # Plain "exit" would ask whether to kill the attached process, also inside "bt; exit".
+                    before = super().run_command("\n".join(parts[:idx]), timeout=timeout) if idx else ""
+                    self.detach()
+                    return "\n".join(o for o in (before, f"[detached] Process {self.pid} resumed.") if o)
+        if self.core:
+            for part in self._split_commands((cmd or "").strip()):
+                verb = part.split(maxsplit=1)[0].lower() if part else ""
+                if verb in _RESUME_COMMANDS:
+                    return f"[post-mortem] {post_mortem_message(part, self.core)}"
+        return super().run_command(cmd, timeout=timeout)
+
+    def _checked(self, cmd: str, timeout: Optional[float] = None) -> str:
+        out = self.run_command(cmd, timeout=timeout)
+        if _FAILED_PREFIX in out:
+            raise DebuggerError(out.split(_FAILED_PREFIX, 1)[1].strip())
+        if out.startswith("[delve error]"):
+            raise DebuggerError(out)
+        return out
+
+    def set_breakpoint(self, spec: Union[str, BreakpointSpec]) -> Breakpoint:
+        spec = as_spec(spec)
+        if spec.regex:
+            raise DebuggerError(f"{spec.describe()} can match several functions; use set_breakpoints")
+        # Locations use local paths; the binary knows the paths it was built with.
+        out = self._checked(f"break {active_path_map().location_to_target(spec.location)}")
+        m = _BREAKPOINT_RE.search(out)
+        if not m:
+            raise DebuggerError(f"Unexpected Delve breakpoint output: {out.strip()}")
+        return self._constrained(self._new_breakpoint(m, spec), spec)
+
+    def set_breakpoints(self, spec: BreakpointSpec) -> List[Breakpoint]:
+        """A breakpoint on every function ``spec.location`` matches as a regex; [] when none does."""
+        if not spec.regex:
+            return [self.set_breakpoint(spec)]
+        out = self.run_command(f"break /{spec.location}/")
+        if _FAILED_PREFIX in out:
+            message = out.split(_FAILED_PREFIX, 1)[1].strip()
+            if "not found" in message or "no matches" in message:
+                return []
+            raise DebuggerError(message)
+        placed: List[Breakpoint] = []
+        try:
+            for m in _BREAKPOINT_RE.finditer(out):
+                placed.append(self._constrained(self._new_breakpoint(m, spec), spec))
+        except DebuggerError:
+            for bp in placed:
+                self.clear_breakpoint(bp.id)
+            raise
+        return placed
+
+    def _new_breakpoint(self, m: "re.Match[str]", spec: BreakpointSpec) -> Breakpoint:
+        return Breakpoint(
+            id=int(m.group(1)),
+            location=m.group(3) if spec.regex else spec.location,
+            address=m.group(2),
+            function=m.group(3),
+            file=local_path(m.group(4)),
+            line=int(m.group(5)),
+        )
+
+    def _constrained(self, bp: Breakpoint, spec: BreakpointSpec) -> Breakpoint:
+        """Apply ``spec``'s condition and hit count to the new ``bp``; clears it when one is rejected."""
+        try:
+            if spec.condition:
+                missing = unknown_names(spec.condition, bp.file, bp.line)
+                if missing:
+                    raise DebuggerError(
+                        f"Condition '{spec.condition}' references {', '.join(missing)}, "
+                        f"not in scope at {bp.file}:{bp.line} ({bp.function})"
+                    )
+                self._checked(f"condition {bp.id} {spec.condition}")
+                bp.condition = spec.condition
+            if spec.hit_count:
+                self._checked(f"condition -hitcount {bp.id} >= {spec.hit_count}")
+                bp.hit_count = spec.hit_count
+        except DebuggerError:
+            # Never leave an unconditional breakpoint behind when the condition was rejected.
+            self.clear_breakpoint(bp.id)
+            raise
+        return bp
+
+    def breakpoints(self) -> List[Breakpoint]:
+        result: List[Breakpoint] = []
+        current: Optional[Breakpoint] = None
+        for line in self._checked("breakpoints").splitlines():
+            m = _LIST_RE.match(line.strip())
+            if m:
+                current = None
+                if not m.group(1).isdigit():
+                    continue
+                current = Breakpoint(
+                    id=int(m.group(1)),
+                    location=f"{local_path(m.group(5))}:{m.group(6)}" if m.group(5) else m.group(3),
+                    address=m.group(3),
+                    function=m.group(4) or "",
+                    file=local_path(m.group(5) or ""),
+                    line=int(m.group(6)) if m.group(6) else 0,
+                    hits=int(m.group(7)) if m.group(7) else 0,
+                    enabled=m.group(2) == "enabled",
+                )
+                result.append(current)
+                continue
+            c = _LIST_COND_RE.match(line)
+            if c and current is not None:
+                if c.group(1):
+                    n = _HITCOND_RE.match(c.group(2).strip())
+                    current.hit_count = int(n.group(1)) if n else current.hit_count
+                else:
+                    current.condition = c.group(2).strip()
+        return result
+
+    def clear_breakpoint(self, breakpoint_id: int) -> None:
+        self._checked(f"clear {breakpoint_id}")
+
+    def set_watchpoint(self, expr: str, kind: str = WATCH_WRITE) -> Watchpoint:
+        """Watch ``expr`` (or a raw ``0x...`` address, watched as one word) with a hardware watchpoint."""
+        kind = watch_kind(kind)
+        active = list(self._watchpoints.values())
+        if len(active) >= MAX_HARDWARE_WATCHPOINTS:
+            raise WatchpointLimitError(watch_limit_message(active))
+        target = f"*(*uintptr)({expr})" if _ADDRESS_RE.match(expr.strip()) else expr.strip()
+        out = self.run_command(f"watch {_WATCH_FLAGS[kind]} {target}")
+        if _WATCH_LIMIT_RE.search(out):
+            raise WatchpointLimitError(f"{watch_limit_message(active)} Delve: {out.strip()}")
+        if _FAILED_PREFIX in out:
+            raise DebuggerError(out.split(_FAILED_PREFIX, 1)[1].strip())
+        m = _WATCH_SET_RE.search(out)
+        if not m:
+            raise DebuggerError(f"Unexpected Delve watchpoint output: {out.strip()}")
+        wp_id = int(m.group(1)) if m.group(1).isdigit() else self._lookup_id(m.group(3))
+        wp = Watchpoint(id=wp_id, expr=target, kind=kind, address=m.group(3))
+        try:
+            wp.value = self.read_variable(target).value
+        except DebuggerError:
+            wp.value = ""
+        self._watchpoints[wp.id] = wp
+        return wp
+
+    def _lookup_id(self, address: str) -> int:
+        for bp in self.breakpoints():
+            if bp.address == address:
+                return bp.id
+        return max(self._watchpoints, default=0) + 1
+
+    def watchpoints(self) -> List[Watchpoint]:
+        return list(self._watchpoints.values())
+
+    def clear_watchpoint(self, watchpoint_id: int) -> None:
+        self._watchpoints.pop(watchpoint_id, None)
+        self._checked(f"clear {watchpoint_id}")
+
+    def _find_watchpoint(self, ref: str) -> Optional[Watchpoint]:
+        if ref.isdigit():
+            return self._watchpoints.get(int(ref))
+        return next((w for w in self._watchpoints.values() if w.expr == ref), None)
+
+    def continue_(self) -> StopEvent:
+        if self.core:
+            raise PostMortemError(post_mortem_message("continue", self.core))
+        out = self._checked("continue")
+        event = parse_stop(out)
+        scope = _WATCH_SCOPE_RE.search(out)
+        if scope:
+            # Delve clears a stack watchpoint itself when its frame returns; forget it too.
+            gone = self._find_watchpoint(scope.group(1))
+            if gone is not None:
+                self._watchpoints.pop(gone.id, None)
+            event.reason = STOP_WATCH_SCOPE
+            event.detail = scope.group(0)
+            return event
+        if event.reason == STOP_FATAL:
+            message = self.fatal_message(event.goroutine_id)
+            if message:
+                event.detail = message
+            return event
+        if event.reason == STOP_WATCHPOINT:
+            wp = self._find_watchpoint(event.detail)
+            if wp is not None:
+                try:
+                    new_value = self.read_variable(wp.expr).value
+                except DebuggerError:
+                    new_value = ""
+                event.watch = WatchHit(
+                    watchpoint=wp,
+                    old_value=wp.value,
+                    new_value=new_value,
+                    frame=event.frame,
+                    goroutine_id=event.goroutine_id,
+                )
+                wp.value = new_value
+        if event.reason == STOP_BREAKPOINT:
+            try:
+                event.hits += self._other_hits(event)
+            except DebuggerError:
+                # The selected goroutine's hit is still reported.
+                pass
+        return event
+
+    def _other_hits(self, event: StopEvent) -> List[BreakpointHit]:
+        """Goroutines besides those in ``event.hits`` that sit on a breakpoint in the same stop.
+
+        The CLI prints only the selected thread's stop (other threads only for
+        tracepoints), so the rest come from ``goroutines``: a goroutine running
+        on a thread whose PC is a breakpoint's address reached it too.
+        """
+        by_address = {int(bp.address, 16): bp for bp in self.breakpoints() if bp.address}
+        if not by_address:
+            return []
+        seen = {hit.goroutine_id for hit in event.hits}
+        hits: List[BreakpointHit] = []
+        for m in _GOROUTINE_AT_RE.finditer(self._checked("goroutines")):
+            gid = int(m.group(1))
+            bp = by_address.get(int(m.group(5), 16))
+            if bp is None or gid in seen or "(thread " not in m.group(6):
+                continue
+            frame = Frame(function=m.group(4), file=m.group(2), line=int(m.group(3)))
+            hits.append(BreakpointHit(breakpoint_id=bp.id, goroutine_id=gid, frame=frame))
+        return hits
+
+    def fatal_message(self, goroutine_id: Optional[int]) -> str:
+        """The string passed to ``runtime.throw``/``runtime.fatal`` ("all goroutines are asleep - deadlock!")."""
+        try:
+            frames = self.stacktrace(goroutine_id, depth=10)
+        except DebuggerError:
+            return ""
+        for idx, frame in enumerate(frames):
+            if frame.function in _FATAL_FUNCTIONS:
+                try:
+                    value = self.eval_in_frame(goroutine_id, idx, "s").value
+                except DebuggerError:
+                    return ""
+                return value[1:-1] if len(value) >= 2 and value[0] == value[-1] == '"' else value
+        return ""
+
+    def step(self) -> StopEvent:
+        """Execute one source line, entering calls."""
+        return self._step(STEP_INTO)
+
+    def next(self) -> StopEvent:
+        """Execute one source line, stepping over calls."""
+        return self._step(STEP_OVER)
+
+    def step_out(self) -> StopEvent:
+        """Run until the current function returns to its caller."""
+        return self._step(STEP_OUT)
+
+    def current_goroutine(self) -> Optional[int]:
+        m = _CURRENT_GOROUTINE_RE.search(self._checked("goroutine"))
+        return int(m.group(1)) if m else None
+
+    def _step(self, command: str) -> StopEvent:
+        """Run ``command`` and describe where it stopped, with the stopped goroutine's stack.
+
+        Delve steps the selected goroutine only; other goroutines keep running
+        and their breakpoints still fire. Such a hit ends the step early in
+        that goroutine (``finish_step`` labels it) and Delve keeps the step
+        pending until the next continue. A step that parks the goroutine, on
+        a channel or lock, returns only once it is woken.
+        """
+        if self.core:
+            raise PostMortemError(post_mortem_message(command, self.core))
+        try:
+            before = self.current_goroutine()
+            out = self._checked(command)
+        except DebuggerError as e:
+            exited = _EXIT_RE.search(str(e))
+            if exited:
+                raise ProcessExitedError(command, int(exited.group(1))) from None
+            raise
+        event = parse_stop(out)
+        if event.exited:
+            return event
+        if event.goroutine_id is None:
+            event.goroutine_id = self.current_goroutine()
+        event.stack = self.stacktrace()
+        return finish_step(event, command, before, pending=bool(_STEP_PENDING_RE.search(out)))
+
+    # Low-level resume used by ``hang.watch_for_hang`` --------------------
+    def resume_async(self) -> None:
+        """Send ``continue`` without waiting for the target to stop."""
+        if self.core:
+            raise PostMortemError(post_mortem_message("continue", self.core))
+        if self.child is None:
+            raise DebuggerError("Delve is not running")
+        self._resume_buffer = ""
+        self.child.sendline("continue")
+        self._waiting = True
+
+    def read_progress(self, wait: float) -> Tuple[str, Optional[StopEvent]]:
+        """Program output received within ``wait`` seconds, plus the stop event once Delve prompts again."""
+        if self.child is None or pexpect is None:
+            raise DebuggerError("Delve is not running")
+        self.context.check()
+        try:
+            chunk = self.child.read_nonblocking(65536, timeout=self.context.timeout(wait))
+        except pexpect.TIMEOUT:
+            return "", None
+        except pexpect.EOF:
+            self.child = None
+            self._waiting = False
+            return "", StopEvent(reason=STOP_EXITED, detail="Delve exited", raw=self._resume_buffer)
+        self._resume_buffer += chunk
+        m = self._prompt_re.search(self._resume_buffer)
+        if not m:
+            return chunk, None
+        out = self._resume_buffer[: m.start()]
+        self._resume_buffer = ""
+        self._waiting = False
+        return chunk, parse_stop(out)
+
+    def interrupt(self) -> str:
+        """Pause a running target (as Ctrl-C in the Delve CLI would) and return what Delve printed."""
+        if self.child is None:
+            raise DebuggerError("Delve is not running")
+        self.child.sendintr()
+        self.child.expect(self._prompt_re, timeout=self.timeout)
+        self._waiting = False
+        out = self._resume_buffer + (self.child.before or "")
+        self._resume_buffer = ""
+        return out
+
+    def target_pid(self) -> Optional[int]:
+        """PID of the debugged process: the attach target, or the child dlv spawned."""
+        return self.pid or super().target_pid()
+
+    def stacktrace(self, goroutine_id: Optional[int] = None, depth: int = 50) -> List[Frame]:
+        cmd = f"stack {depth}"
+        if goroutine_id is not None:
+            cmd = f"goroutine {goroutine_id} {cmd}"
+        return parse_delve_frames(self._checked(cmd))
+
+    def goroutines(self) -> GoroutineDump:
+        return parse_goroutine_dump(self._checked(self.GOROUTINES_COMMAND))
+
+    def _apply_load_config(self, cfg: Optional[LoadConfig]) -> None:
+        if cfg is not None and cfg != self._load_config:
+            # The CLI only exposes these three LoadConfig limits; they stay in effect for later prints.
+            self._checked(f"config max-variable-recurse {cfg.max_depth}")
+            self._checked(f"config max-array-values {cfg.max_array_values}")
+            self._checked(f"config max-string-len {cfg.max_string_len}")
+            self._load_config = cfg
+
+    def read_variable(self, expr: str, cfg: Optional[LoadConfig] = None) -> Variable:
+        self._apply_load_config(cfg)
+        value = self._checked(f"print {expr}").strip()
+        try:
+            vtype = self._checked(f"whatis {expr}").strip()
+        except DebuggerError:
+            vtype = ""
+        return Variable(name=expr, value=value, type=vtype)
+
+    def eval_in_frame(
+        self, goroutine_id: Optional[int], frame: int, expr: str, cfg: Optional[LoadConfig] = None
+    ) -> Variable:
+        """Evaluate ``expr`` (``len(m)``, ``s.field.ptr``, arithmetic) in one frame of a goroutine.
+
+        Uses Delve's ``goroutine <id> frame <n>`` scope prefix, so the selected
+        goroutine and frame are unchanged afterwards. A variable with no
+        location at that PC raises ``OptimizedAwayError``.
+        """
+        if frame < 0:
+            raise DebuggerError(f"Frame index must not be negative, got {frame}")
+        scope = (f"goroutine {goroutine_id} " if goroutine_id is not None else "") + f"frame {frame} "
+        self._apply_load_config(cfg)
+        try:
+            value = self._checked(f"{scope}print {expr}").strip()
+        except DebuggerError as e:
+            if optimized_away(str(e)):
+                raise OptimizedAwayError(expr, str(e), goroutine_id=goroutine_id, frame=frame) from None
+            raise
+        m = _UNREADABLE_RE.match(value)
+        if m and optimized_away(m.group(1)):
+            raise OptimizedAwayError(expr, m.group(1), goroutine_id=goroutine_id, frame=frame)
+        try:
+            vtype = self._checked(f"{scope}whatis {expr}").strip()
+        except DebuggerError:
+            vtype = ""
+        return Variable(name=expr, value=value, type=vtype, unreadable=m.group(1) if m else "")
+
+    def frame_locals(self, goroutine_id: Optional[int], frame: int, cfg: Optional[LoadConfig] = None) -> List[Variable]:
+        """Local variables of one frame, loaded per ``cfg``; the session's load limits are restored afterwards."""
+        scope = (f"goroutine {goroutine_id} " if goroutine_id is not None else "") + f"frame {frame} "
+        previous = self._load_config
+        self._apply_load_config(cfg)
+        try:
+            out = self._checked(f"{scope}locals")
+        finally:
+            self._apply_load_config(previous or LoadConfig())
+        variables: List[Variable] = []
+        for line in out.splitlines():
+            m = _LOCAL_RE.match(line)
+            if m:
+                variables.append(Variable(name=m.group(1), value=m.group(2).strip()))
+            elif variables and line.strip():
+                # -v style values continue on the following lines.
+                variables[-1].value += "\n" + line.rstrip()
+        return variables
+
+    def thread_stacks(self, depth: int = 50) -> Dict[int, List[Frame]]:
+        """Stack of every OS thread, keyed by thread id."""
+        stacks: Dict[int, List[Frame]] = {}
+        for tid in (int(t) for t in _THREAD_RE.findall(self._checked("threads"))):
+            try:
+                self._checked(f"thread {tid}")
+                stacks[tid] = parse_delve_frames(self._checked(f"stack {depth}"))
+            except DebuggerError:
+                # Threads can exit between the listing and the switch.
+                continue
+        return stacks
+
+    def snapshot(self) -> str:
+        """Goroutine dump plus per-thread stacks, as one text blob for analysis."""
+        parts = [self._checked(self.GOROUTINES_COMMAND)]
+        for tid, frames in self.thread_stacks().items():
+            parts.append(f"Thread {tid}:")
+            parts.extend(f"  {f.function} at {f.location}" for f in frames)
+        return "\n".join(parts)
+
+    def detach(self) -> None:
+        """Detach from an attached process and let it continue running."""
+        if not self.pid:
+            self.close()
+            return
+        self._detached = True
+        self._quit("quit -c")
+
+    def close(self) -> None:
+        if self.pid and not self._detached:
+            self.detach()
+            return
+        self._quit("exit")
+
+    def abort(self) -> None:
+        """As for a launched program, except that an attached process is detached and left running."""
+        if not self.pid or self._detached:
+            super().abort()
+            return
+        try:
+            if self.child is not None:
+                self._halt(self.child)
+        except Exception:
+            pass
+        self.detach()
+
+    def _quit(self, command: str) -> None:
+        self._release_context()
+        child = self.child
+        self.child = None
+        if child is None:
+            return
+        try:
+            child.sendline(command)
+            if pexpect is not None:
+                child.expect(pexpect.EOF, timeout=2)
+        except Exception:
+            pass
+        finally:
+            try:
+                child.close(force=True)
+            except Exception:
+                pass
+
+
+__all__ = ["DelveDebugger", "parse_stop"]
@@ -0,0 +1,181 @@
+"""Breakpoint hits delivered on a queue while a background thread drives ``continue``.
+
+Breakpoints in ``workerOne`` and ``workerTwo`` can fire at nearly the same
+moment on different goroutines. The debugger reports every goroutine that
+reached a breakpoint in the stop it returns (``StopEvent.hits``), and
+``BreakpointEvents`` turns each of them into a ``BreakpointHit`` on
+``hits``, a ``queue.Queue`` that plays the part of a Go channel: the loop
+thread sends, the copilot receives with ``get`` or by iterating, and the
+queue is closed (``None`` is sent) when the program stops for any other
+reason (exit, panic, watchpoint) or ``close`` is called.
+
+This is a library API for programs that embed the debugger: neither the
+REPL nor ``dbgagent --interactive`` drives it, since both run one command
+at a time on the caller's thread.
+
+After each stop the ``policy`` decides what happens to the rest of the
+program: ``POLICY_STOP`` keeps the world stopped until ``resume`` is called,
+so the consumer may inspect it with the debugger; ``POLICY_RESUME``
+continues at once. A callable policy receives the stop's hits and returns
+one of the two. The debugger is never driven from two threads: while the
+loop runs the target only the loop touches it, and each hit carries the
+stack of its goroutine, captured before anything resumed.
+
+Ordering guarantees:
+
+- Hits are delivered in ``seq`` order and never dropped; the queue is
+  unbounded, so a slow consumer under ``POLICY_RESUME`` only delays them.
+- Every hit of one stop (same ``stop_seq``) is delivered before any hit of
+  a later stop, and under ``POLICY_STOP`` no later stop exists until
+  ``resume``.
+- Within one stop the goroutine the debugger selected comes first
+  (``selected``), then the others in the order the debugger listed them.
+  Hits in one stop happened "together": nothing orders them in time.
+- A goroutine that hits the same breakpoint again shows up again, in a
+  later stop.
+"""
+from __future__ import annotations
+
+from typing import Any, Callable, Iterator, List, Optional, Union
+import contextvars
+import queue
+import threading
+import time
+
+from .base import BreakpointHit, DebuggerError, StopEvent, stop_hits
+
+POLICY_STOP = "stop"
+POLICY_RESUME = "resume"
+# How often ``wait_paused`` checks whether the loop has ended.
+_POLL = 0.05
+
+Policy = Union[str, Callable[[List[BreakpointHit]], str]]
+
+
+class BreakpointEvents:
+    """Run ``debugger`` under ``continue`` and publish its breakpoint hits on ``hits``."""
+
+    def __init__(self, debugger: Any, *, policy: Policy = POLICY_STOP, capture_stacks: bool = True) -> None:
+        if not callable(policy) and policy not in {POLICY_STOP, POLICY_RESUME}:
+            raise ValueError(f"Unknown policy {policy!r}; use {POLICY_STOP!r}, {POLICY_RESUME!r} or a callable")
+        self.debugger = debugger
+        self.policy = policy
+        self.capture_stacks = capture_stacks
+        self.hits: "queue.Queue[Optional[BreakpointHit]]" = queue.Queue()
+        # The stop that ended the loop (exit, panic, ...), or the error that did.
+        self.final: Optional[StopEvent] = None
+        self.error: Optional[Exception] = None
+        self.stops = 0
+        self._seq = 0
+        self._resume = threading.Event()
+        self._paused = threading.Event()
+        self._closing = False
+        self._done = threading.Event()
+        self._thread: Optional[threading.Thread] = None
+
+    def start(self) -> "BreakpointEvents":
+        if self._thread is not None:
+            raise DebuggerError("Breakpoint events are already running")
+        # The loop thread sees the caller's context, its trace redactor included.
+        ctx = contextvars.copy_context()
+        self._thread = threading.Thread(
+            target=ctx.run, args=(self._loop,), name="dbgcopilot-breakpoint-events", daemon=True
+        )
+        self._thread.start()
+        return self
+
+    def _loop(self) -> None:
+        try:
+            while not self._closing:
+                event = self.debugger.continue_()
+                hits = stop_hits(event)
+                if not hits:
+                    self.final = event
+                    return
+                self.stops += 1
+                for hit in hits:
+                    self._seq += 1
+                    hit.seq, hit.stop_seq = self._seq, self.stops
+                    if self.capture_stacks and not hit.stack:
+                        hit.stack = self._stack(hit)
+                decision = self.policy(hits) if callable(self.policy) else self.policy
+                if decision == POLICY_STOP:
+                    self._paused.set()
+                for hit in hits:
+                    self.hits.put(hit)
+                if decision == POLICY_STOP:
+                    self._resume.wait()
+                    self._resume.clear()
+                    self._paused.clear()
+        except Exception as exc:
+            # Handed to the consumer, which sees the queue close.
+            self.error = exc
+        finally:
+            self._paused.clear()
+            self._done.set()
+            self.hits.put(None)
+
+    def _stack(self, hit: BreakpointHit) -> List[Any]:
+        try:
+            if hit.unit == "goroutine" and hit.goroutine_id is not None:
+                return self.debugger.stacktrace(hit.goroutine_id)
+            return self.debugger.stacktrace() if hit.selected else []
+        except DebuggerError:
+            return []
+
+    def get(self, timeout: Optional[float] = None) -> Optional[BreakpointHit]:
+        """Next hit; None once the loop has ended. Raises ``queue.Empty`` after ``timeout`` seconds."""
+        hit = self.hits.get(timeout=timeout)
+        if hit is None:
+            # Keep the queue closed for later receivers too.
+            self.hits.put(None)
+        return hit
+
+    def __iter__(self) -> Iterator[BreakpointHit]:
+        while True:
+            hit = self.get()
+            if hit is None:
+                return
+            yield hit
+
+    @property
+    def paused(self) -> bool:
+        """True while the world is stopped waiting for ``resume``; the debugger is free to use."""
+        return self._paused.is_set()
+
+    @property
+    def done(self) -> bool:
+        return self._done.is_set()
+
+    def wait_paused(self, timeout: Optional[float] = None) -> bool:
+        """Block until a stop is waiting for ``resume`` (False on timeout or when the loop ended)."""
+        end = None if timeout is None else time.monotonic() + timeout
+        while not self._paused.is_set():
+            if self._done.is_set():
+                return False
+            wait = _POLL if end is None else min(_POLL, end - time.monotonic())
+            if wait <= 0:
+                return False
+            self._paused.wait(wait)
+        return True
+
+    def resume(self) -> None:
+        """Let the stopped world run again (POLICY_STOP)."""
+        if not self._paused.is_set():
+            raise DebuggerError("The program is not paused at a breakpoint stop")
+        self._paused.clear()
+        self._resume.set()
+
+    def close(self, timeout: Optional[float] = None) -> None:
+        """End the loop at the current or next stop and wait up to ``timeout`` seconds for it.
+
+        A ``continue`` that is running is not interrupted: the loop ends when
+        it returns, leaving the program stopped there.
+        """
+        self._closing = True
+        self._resume.set()
+        if self._thread is not None:
+            self._thread.join(timeout)
+
+
+__all__ = ["POLICY_RESUME", "POLICY_STOP", "BreakpointEvents", "Policy"]
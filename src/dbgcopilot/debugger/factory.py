@@ -0,0 +1,188 @@
+"""Pick a structured debugger for a binary or script based on its format."""
+from __future__ import annotations
+
+from contextlib import contextmanager
+from pathlib import Path
+from typing import Iterator, Optional, TypeVar
+
+from dbgcopilot.utils.context import Context
+
+from .base import Debugger, DebuggerError
+
+
+AUTO = "auto"
+SUPPORTED = ("delve", "lldb", "pdb")
+
+_ELF_MAGIC = b"\x7fELF"
+_MACHO_MAGICS = {
+    b"\xfe\xed\xfa\xce",
+    b"\xfe\xed\xfa\xcf",
+    b"\xce\xfa\xed\xfe",
+    b"\xcf\xfa\xed\xfe",
+    b"\xca\xfe\xba\xbe",
+}
+# Go linkers embed this marker in the .go.buildinfo section (Go 1.13+).
+_GO_BUILDINFO_MAGIC = b"\xff Go buildinf:"
+_CHUNK = 1 << 20
+_PYTHON_SUFFIXES = {".py", ".pyw"}
+
+D = TypeVar("D")
+
+
+def binary_format(path: str) -> str:
+    """Return "elf", "macho" or "" for an unrecognised file."""
+    with open(path, "rb") as fh:
+        magic = fh.read(4)
+    if magic == _ELF_MAGIC:
+        return "elf"
+    if magic in _MACHO_MAGICS:
+        return "macho"
+    return ""
+
+
+def is_go_binary(path: str) -> bool:
+    overlap = len(_GO_BUILDINFO_MAGIC)
+    tail = b""
+    with open(path, "rb") as fh:
+        while True:
+            chunk = fh.read(_CHUNK)
+            if not chunk:
+                return False
+            if _GO_BUILDINFO_MAGIC in tail + chunk:
+                return True
+            tail = chunk[-overlap:]
+
+
+def is_python_script(path: str) -> bool:
+    """True for ``.py`` files and scripts whose shebang names a Python interpreter."""
+    if Path(path).suffix.lower() in _PYTHON_SUFFIXES:
+        return True
+    with open(path, "rb") as fh:
+        first = fh.readline(256)
+    return first.startswith(b"#!") and b"python" in first
+
+
+def detect_backend(program: str) -> str:
+    """Choose "delve" for Go binaries, "pdb" for Python scripts and "lldb" for other native executables."""
+    path = Path(program).expanduser()
+    if not path.is_file():
+        raise DebuggerError(f"Program '{program}' not found")
+    fmt = binary_format(str(path))
+    if not fmt and is_python_script(str(path)):
+        return "pdb"
+    if not fmt:
+        raise DebuggerError(
+            f"Cannot detect a debugger for '{program}': not an ELF or Mach-O executable or a Python script. "
+            "Pass an explicit debugger instead of auto."
+        )
+    return "delve" if is_go_binary(str(path)) else "lldb"
+
+
+def resolve_backend(program: str, override: Optional[str] = None) -> str:
+    choice = (override or AUTO).strip().lower()
+    if choice == AUTO:
+        return detect_backend(program)
+    if choice not in SUPPORTED:
+        raise DebuggerError(f"Unsupported debugger '{override}'; choose one of: auto, {', '.join(SUPPORTED)}")
+    return choice
+
+
+def start_session(debugger: D) -> D:
+    """``initialize_session``, ending the half-started debugger when startup fails or is interrupted.
+
+    A ``dlv attach`` or ``dlv core`` that is cancelled while loading must not
+    leave dlv running, so the caller never sees a debugger it cannot close.
+    """
+    try:
+        debugger.initialize_session()  # type: ignore[attr-defined]
+    except BaseException:
+        abort = getattr(debugger, "abort", None) or getattr(debugger, "close", None)
+        if abort is not None:
+            try:
+                abort()
+            except Exception:
+                pass
+        raise
+    return debugger
+
+
+def open_debugger(
+    program: str,
+    backend: Optional[str] = None,
+    *,
+    core: Optional[str] = None,
+    python: Optional[str] = None,
+    context: Optional[Context] = None,
+) -> Debugger:
+    """Start and return an initialized structured debugger for ``program``.
+
+    ``python`` picks the interpreter for the pdb backend (a path, a name on
+    PATH or a virtualenv directory); see ``resolve_interpreter`` for the
+    default. ``context`` bounds Delve's startup (a large core can take a
+    while to load) and every later wait; it defaults to the active one.
+    """
+    choice = resolve_backend(program, backend)
+    debugger: Debugger
+    if choice == "pdb":
+        if core:
+            raise DebuggerError("pdb cannot open core dumps; run the script instead")
+        from .python import PythonDebugger
+
+        debugger = PythonDebugger(program, python=python)
+    elif choice == "delve":
+        from .delve import DelveDebugger
+
+        debugger = DelveDebugger(program=program, core=core, context=context)
+    else:
+        from .lldb import LldbDebugger
+
+        debugger = LldbDebugger(program=program, core=core)
+    return start_session(debugger)
+
+
+def open_core(
+    program: str, core: str, backend: Optional[str] = None, *, context: Optional[Context] = None
+) -> Debugger:
+    """Open ``core`` against the binary that produced it for post-mortem inspection.
+
+    Go binaries use ``dlv core``; other executables load the core in LLDB.
+    The returned debugger reports ``post_mortem`` and refuses to resume.
+    """
+    core_path = Path(core).expanduser()
+    if not core_path.is_file():
+        raise DebuggerError(f"Core file '{core}' not found")
+    return open_debugger(program, backend, core=str(core_path), context=context)
+
+
+def connect(addr: str, *, api_version: int = 2, context: Optional[Context] = None) -> Debugger:
+    """Attach to a headless Delve server (``dlv debug/exec/attach --headless``) at ``host:port``.
+
+    ``api_version`` is negotiated with the server so one started with
+    ``--api-version=1`` works too; it is not the server's own flag value.
+    """
+    from .remote import DelveRemote
+
+    debugger = DelveRemote(addr, api_version=api_version, context=context)
+    return start_session(debugger)
+
+
+def attach(pid: int, program: Optional[str] = None, *, context: Optional[Context] = None) -> Debugger:
+    """Attach Delve to the running Go process ``pid``; call ``detach()`` to let it run on.
+
+    Raises ``PtracePermissionError`` with the ptrace_scope setting when the
+    kernel does not allow tracing the process.
+    """
+    from .delve import DelveDebugger
+
+    debugger = DelveDebugger(program=program or "", pid=pid, context=context)
+    return start_session(debugger)
+
+
+@contextmanager
+def attached(pid: int, program: Optional[str] = None) -> Iterator[Debugger]:
+    """``attach`` for the duration of a block; the process is resumed even if the block raises."""
+    debugger = attach(pid, program)
+    try:
+        yield debugger
+    finally:
+        debugger.detach()
@@ -0,0 +1,220 @@
+"""Run the target and interrupt it automatically when it stops making progress.
+
+Progress is any of: bytes the program writes to stdout/stderr, a
+breakpoint or watchpoint hit (the watch resumes after each one, so they act
+as progress markers), or CPU time — a CPU-bound loop that prints nothing is
+busy, not hung. Only when none of these happen for ``timeout`` seconds is the
+process paused, its goroutines dumped and the deadlock analyzers (mutex
+cycles and channel starvation) run. Panics, fatal errors and exit end the
+watch like a normal ``continue``; when the fatal error is the runtime's own
+"all goroutines are asleep" deadlock detection, the goroutines are dumped
+and analyzed as for a hang.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Callable, List, Optional, Protocol, Tuple
+import os
+import time
+
+from dbgcopilot.analyze.channels import (
+    ChannelReport,
+    detect_channel_deadlock,
+    format_channel_report,
+    runtime_deadlock_fired,
+)
+from dbgcopilot.analyze.deadlock import DeadlockCycle, detect_deadlock, format_deadlock_report
+from dbgcopilot.analyze.goroutines import GoroutineDump
+
+from .base import STOP_BREAKPOINT, STOP_FATAL, STOP_WATCHPOINT, DebuggerError, StopEvent
+
+
+DEFAULT_HANG_TIMEOUT = 10.0
+POLL_INTERVAL = 0.5
+# CPU use above this fraction of wall time over a poll interval counts as progress.
+BUSY_CPU_FRACTION = 0.05
+OUTPUT_TAIL = 2048
+
+
+class Resumable(Protocol):
+    """What a debugger needs to support ``watch_for_hang``."""
+
+    def resume_async(self) -> None:  # pragma: no cover
+        ...
+
+    def read_progress(self, wait: float) -> Tuple[str, Optional[StopEvent]]:  # pragma: no cover
+        ...
+
+    def interrupt(self) -> str:  # pragma: no cover
+        ...
+
+    def target_pid(self) -> Optional[int]:  # pragma: no cover
+        ...
+
+    def goroutines(self) -> GoroutineDump:  # pragma: no cover
+        ...
+
+
+def cpu_seconds(pid: Optional[int]) -> Optional[float]:
+    """User + system CPU time of ``pid`` from /proc, or None when unavailable."""
+    if not pid:
+        return None
+    try:
+        fields = Path(f"/proc/{pid}/stat").read_text().rsplit(")", 1)[1].split()
+    except (OSError, IndexError):
+        return None
+    # utime and stime are fields 14 and 15 of stat(5); index 0 here is field 3.
+    return (int(fields[11]) + int(fields[12])) / os.sysconf("SC_CLK_TCK")
+
+
+def _new_cycle_list() -> List[DeadlockCycle]:
+    return []
+
+
+@dataclass
+class ProgressMonitor:
+    """Tracks when the target last showed a sign of life."""
+
+    last_progress: float
+    output_bytes: int = 0
+    events: int = 0
+    cpu: Optional[float] = None
+    _cpu_at: float = 0.0
+
+    def note_output(self, nbytes: int, now: float) -> None:
+        if nbytes:
+            self.output_bytes += nbytes
+            self.last_progress = now
+
+    def note_event(self, now: float) -> None:
+        self.events += 1
+        self.last_progress = now
+
+    def note_cpu(self, seconds: Optional[float], now: float) -> None:
+        if seconds is None:
+            return
+        if self.cpu is not None and now > self._cpu_at:
+            if (seconds - self.cpu) / (now - self._cpu_at) >= BUSY_CPU_FRACTION:
+                self.last_progress = now
+        self.cpu, self._cpu_at = seconds, now
+
+    def idle(self, now: float) -> float:
+        return now - self.last_progress
+
+
+@dataclass
+class HangWatch:
+    """Outcome of ``watch_for_hang``: either a hang (with analysis) or the stop that ended the run."""
+
+    hung: bool
+    idle_seconds: float = 0.0
+    output_bytes: int = 0
+    events: int = 0
+    stop: Optional[StopEvent] = None
+    dump: Optional[GoroutineDump] = None
+    cycles: List[DeadlockCycle] = field(default_factory=_new_cycle_list)
+    channels: Optional[ChannelReport] = None
+    output_tail: str = ""
+
+    @property
+    def runtime_deadlock(self) -> bool:
+        return self.channels is not None and self.channels.runtime_deadlock
+
+    def describe(self) -> str:
+        if not self.hung and not self.runtime_deadlock:
+            head = self.stop.describe() if self.stop is not None else "Target stopped"
+            return f"{head} (no hang: {self.output_bytes} output byte(s), {self.events} debugger event(s))"
+        if self.hung:
+            lines = [
+                f"No progress for {self.idle_seconds:.1f}s "
+                f"(after {self.output_bytes} output byte(s), {self.events} debugger event(s)); process paused."
+            ]
+        else:
+            lines = [self.stop.describe() if self.stop is not None else "Runtime deadlock detected"]
+        if self.dump is not None:
+            lines.append(f"{len(self.dump)} goroutine(s) captured.")
+        if self.cycles:
+            lines.append(format_deadlock_report(self.cycles))
+        channels = format_channel_report(self.channels, lock_cycles=len(self.cycles)) if self.channels else ""
+        if channels:
+            lines.append(channels)
+        elif not self.cycles:
+            lines.append("No lock cycle or starved channel found in the goroutine dump.")
+        return "\n".join(lines)
+
+
+def watch_for_hang(
+    debugger: Resumable,
+    timeout: float = DEFAULT_HANG_TIMEOUT,
+    *,
+    poll_interval: float = POLL_INTERVAL,
+    clock: Callable[[], float] = time.monotonic,
+    cpu_reader: Callable[[Optional[int]], Optional[float]] = cpu_seconds,
+) -> HangWatch:
+    """Continue the target until it exits, crashes, or shows no progress for ``timeout`` seconds."""
+    if timeout <= 0:
+        raise DebuggerError("Hang timeout must be positive")
+    monitor = ProgressMonitor(last_progress=clock())
+    tail = ""
+    debugger.resume_async()
+    pid = debugger.target_pid()
+    while True:
+        chunk, stop = debugger.read_progress(poll_interval)
+        now = clock()
+        if chunk:
+            monitor.note_output(len(chunk.encode("utf-8", "replace")), now)
+            tail = (tail + chunk)[-OUTPUT_TAIL:]
+        if stop is not None:
+            if stop.reason in {STOP_BREAKPOINT, STOP_WATCHPOINT}:
+                monitor.note_event(now)
+                debugger.resume_async()
+                continue
+            watch = HangWatch(
+                hung=False,
+                output_bytes=monitor.output_bytes,
+                events=monitor.events,
+                stop=stop,
+                output_tail=tail,
+            )
+            if stop.reason == STOP_FATAL and _runtime_deadlock(debugger, stop):
+                watch.dump = debugger.goroutines()
+                watch.cycles = detect_deadlock(watch.dump)
+                watch.channels = detect_channel_deadlock(watch.dump, runtime_deadlock=True)
+            return watch
+        monitor.note_cpu(cpu_reader(pid), now)
+        if monitor.idle(now) >= timeout:
+            break
+    idle = monitor.idle(clock())
+    debugger.interrupt()
+    dump = debugger.goroutines()
+    return HangWatch(
+        hung=True,
+        idle_seconds=idle,
+        output_bytes=monitor.output_bytes,
+        events=monitor.events,
+        dump=dump,
+        cycles=detect_deadlock(dump),
+        channels=detect_channel_deadlock(dump),
+        output_tail=tail,
+    )
+
+
+def _runtime_deadlock(debugger: Resumable, stop: StopEvent) -> bool:
+    """Whether a fatal stop is the runtime's deadlock detector; reads the throw message when the backend can."""
+    if not runtime_deadlock_fired(stop.detail) and not runtime_deadlock_fired(stop.raw):
+        reader = getattr(debugger, "fatal_message", None)
+        message = reader(stop.goroutine_id) if reader is not None else ""
+        if message:
+            stop.detail = message
+    return runtime_deadlock_fired(stop.detail) or runtime_deadlock_fired(stop.raw)
+
+
+__all__ = [
+    "BUSY_CPU_FRACTION",
+    "DEFAULT_HANG_TIMEOUT",
+    "HangWatch",
+    "ProgressMonitor",
+    "cpu_seconds",
+    "watch_for_hang",
+]
@@ -0,0 +1,110 @@
+"""How a launched target is invoked: its command-line arguments and environment.
+
+Most hangs only reproduce with the program's real flags and configuration.
+``dbgagent -- ./server --config prod.yaml`` passes everything after ``--``
+to the program, ``--env KEY=VAL`` sets variables on top of dbgagent's own
+environment, and ``--no-inherit-env`` starts from an empty one instead.
+
+Each launcher applies an ``Invocation`` its own way. Delve and pdb start
+the program themselves, so the arguments go on their command line and the
+environment is the one the debugger process is spawned with (dlv also
+keeps the few variables it needs to start); gdb and lldb are told with
+``set args`` / ``settings set target.run-args`` and their environment
+settings before the program is run. Recorded transcripts keep ``to_dict()``,
+redacted, in the session header, so a replay shows how the program was
+started.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Mapping, Optional, Sequence
+import os
+import re
+import shlex
+
+_NAME_RE = re.compile(r"^[A-Za-z_][A-Za-z0-9_]*$")
+
+
+def _new_list() -> List[str]:
+    return []
+
+
+def _new_dict() -> Dict[str, str]:
+    return {}
+
+
+def parse_env(specs: Sequence[str]) -> Dict[str, str]:
+    """``KEY=VAL`` assignments as a dict, later ones winning; ValueError names a malformed one."""
+    env: Dict[str, str] = {}
+    for spec in specs:
+        name, sep, value = spec.partition("=")
+        if not sep or not _NAME_RE.match(name):
+            raise ValueError(f"expected KEY=VAL with KEY a variable name, got {spec!r}")
+        env[name] = value
+    return env
+
+
+def _lldb_quote(word: str) -> str:
+    return '"' + word.replace("\\", "\\\\").replace('"', '\\"') + '"'
+
+
+@dataclass
+class Invocation:
+    """Arguments after the program and environment variables set on top of (or instead of) ours."""
+
+    args: List[str] = field(default_factory=_new_list)
+    env: Dict[str, str] = field(default_factory=_new_dict)
+    inherit_env: bool = True
+
+    @property
+    def default(self) -> bool:
+        """Nothing to change: no arguments, no variables, the parent environment."""
+        return not self.args and not self.env and self.inherit_env
+
+    def environment(self, base: Optional[Mapping[str, str]] = None) -> Dict[str, str]:
+        """The full environment to start the program with; ``base`` defaults to ours."""
+        env = dict(os.environ if base is None else base) if self.inherit_env else {}
+        env.update(self.env)
+        return env
+
+    def command_line(self, program: str) -> str:
+        """``program`` and its arguments, quoted for a POSIX shell."""
+        return shlex.join([program, *self.args])
+
+    def describe(self, program: str) -> str:
+        """One line: the environment assignments, then the command line."""
+        assignments = [f"{name}={shlex.quote(value)}" for name, value in self.env.items()]
+        prefix = ["env", "-i"] if not self.inherit_env else []
+        return " ".join(prefix + assignments + [self.command_line(program)])
+
+    def gdb_commands(self) -> List[str]:
+        commands = ["unset environment"] if not self.inherit_env else []
+        commands += [f"set environment {name}={value}" for name, value in self.env.items()]
+        if self.args:
+            # gdb starts the program through the shell, which splits and unquotes the arguments.
+            commands.append("set args " + " ".join(shlex.quote(arg) for arg in self.args))
+        return commands
+
+    def lldb_commands(self) -> List[str]:
+        commands = ["settings set target.inherit-env false"] if not self.inherit_env else []
+        if self.env:
+            assignments = " ".join(_lldb_quote(f"{name}={value}") for name, value in self.env.items())
+            commands.append(f"settings set target.env-vars {assignments}")
+        if self.args:
+            commands.append("settings set target.run-args " + " ".join(_lldb_quote(arg) for arg in self.args))
+        return commands
+
+    def to_dict(self) -> Dict[str, Any]:
+        return {"args": list(self.args), "env": dict(self.env), "inherit_env": self.inherit_env}
+
+    @classmethod
+    def from_dict(cls, data: Optional[Mapping[str, Any]]) -> "Invocation":
+        data = data or {}
+        return cls(
+            args=[str(arg) for arg in data.get("args") or []],
+            env={str(k): str(v) for k, v in (data.get("env") or {}).items()},
+            inherit_env=bool(data.get("inherit_env", True)),
+        )
+
+
+__all__ = ["Invocation", "parse_env"]
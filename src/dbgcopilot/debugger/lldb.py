@@ -0,0 +1,441 @@
+"""Structured LLDB driver for native (C/C++/Rust) binaries.
+
+Wraps the pexpect-based ``LldbSubprocessBackend`` and parses LLDB's
+``thread backtrace`` / ``process`` output into the shared frame model. OS
+threads are reported through ``goroutines()`` so callers can treat Go and
+native targets the same way.
+"""
+from __future__ import annotations
+
+from typing import Dict, List, Optional, Union
+import re
+import shlex
+import shutil
+
+from dbgcopilot.analyze.goroutines import Frame, Goroutine, GoroutineDump
+from dbgcopilot.backends.lldb_subprocess import LldbSubprocessBackend, pexpect
+from dbgcopilot.utils.pathmap import active_path_map, local_path
+
+from .base import (
+    STOP_BREAKPOINT,
+    STOP_EXITED,
+    STOP_SIGNAL,
+    STOP_STOPPED,
+    STOP_WATCH_SCOPE,
+    STOP_WATCHPOINT,
+    STEP_INTO,
+    STEP_OUT,
+    STEP_OVER,
+    MAX_HARDWARE_WATCHPOINTS,
+    WATCH_WRITE,
+    Breakpoint,
+    BreakpointHit,
+    BreakpointSpec,
+    DebuggerError,
+    DebuggerUnavailable,
+    LoadConfig,
+    OptimizedAwayError,
+    PostMortemError,
+    ProcessExitedError,
+    StopEvent,
+    Variable,
+    WatchHit,
+    Watchpoint,
+    WatchpointLimitError,
+    as_spec,
+    finish_step,
+    optimized_away,
+    post_mortem_message,
+    watch_kind,
+    watch_limit_message,
+)
+
+
+_THREAD_RE = re.compile(r"^\s*(\*)?\s*thread #(\d+)(.*)$")
+_FRAME_RE = re.compile(r"^\s*\*?\s*frame #(\d+):\s+(0x[0-9a-fA-F]+)\s+(.*)$")
+_FRAME_DETAIL_RE = re.compile(
+    r"^(?:(?P<module>[^`\s]+)`)?(?P<func>.+?)(?:\s+\+\s+\d+)?(?:\s+at\s+(?P<file>\S+?):(?P<line>\d+)(?::\d+)?)?$"
+)
+_STOP_REASON_RE = re.compile(r"stop reason = (.*)$")
+_BREAKPOINT_RE = re.compile(r"^Breakpoint\s+(\d+):\s+(.*)$", re.MULTILINE)
+_BP_WHERE_RE = re.compile(r"where = (?:[^`\s]+`)?(\S+?)(?:\s+\+\s+\d+)?(?:\s+at\s+(\S+?):(\d+))?, address = (0x[0-9a-fA-F]+)")
+_EXIT_RE = re.compile(r"Process\s+\d+\s+exited with status\s*=\s*(-?\d+)")
+_SELECTED_THREAD_RE = re.compile(r"^\s*\*?\s*thread #(\d+)", re.MULTILINE)
+_STEP_COMMANDS = {STEP_INTO: "thread step-in", STEP_OVER: "thread step-over", STEP_OUT: "thread step-out"}
+_FILE_LINE_RE = re.compile(r"^(.+?):(\d+)$")
+_LIST_RE = re.compile(r"^(\d+):\s+(?:file = '([^']*)', line = (\d+)|name = '([^']*)').*?hit count = (\d+)")
+_LIST_CONDITION_RE = re.compile(r"^\s*Condition:\s*(.*)$")
+_LIST_IGNORE_RE = re.compile(r"ignore:\s*(\d+)")
+_WATCH_CREATED_RE = re.compile(r"Watchpoint created:\s+Watchpoint\s+(\d+):\s+addr\s*=\s*(0x[0-9a-fA-F]+)")
+_WATCH_HIT_RE = re.compile(r"Watchpoint\s+(\d+)\s+hit:\s*\n\s*old value:\s*(.*)\n\s*new value:\s*(.*)")
+_WATCH_LIMIT_RE = re.compile(r"(?i)hardware[^\n]*(?:slots|limit|resources)|watchpoint creation failed")
+_ADDRESS_RE = re.compile(r"^0x[0-9a-fA-F]+$")
+_WATCH_TYPES = {"read": "read", "write": "write", "readwrite": "read_write"}
+# Leading words of LLDB commands (and common aliases) that need a live process.
+_RESUME_PREFIXES = (
+    ("process", "continue"), ("process", "launch"), ("thread", "step-in"), ("thread", "step-over"),
+    ("thread", "step-out"), ("thread", "step-inst"), ("thread", "until"), ("continue",), ("c",),
+    ("step",), ("s",), ("next",), ("n",), ("finish",), ("run",), ("r",), ("si",), ("ni",),
+)
+_VALUE_RE = re.compile(r"^\((?P<type>.+?)\)\s+(?P<name>\S+)\s+=\s+(?P<value>[\s\S]*)$")
+
+
+def _parse_frame(detail: str, pc: str) -> Frame:
+    m = _FRAME_DETAIL_RE.match(detail.strip())
+    if not m:
+        return Frame(function=detail.strip(), pc=pc)
+    return Frame(
+        function=m.group("func").strip(),
+        file=m.group("file") or "",
+        line=int(m.group("line")) if m.group("line") else 0,
+        pc=pc,
+        module=m.group("module") or "",
+    )
+
+
+def parse_backtrace(text: str) -> GoroutineDump:
+    """Parse ``thread backtrace [all]`` output; each thread becomes a ``Goroutine``."""
+    dump = GoroutineDump(raw=text or "")
+    current: Optional[Goroutine] = None
+    for line in (text or "").replace("\r\n", "\n").split("\n"):
+        m = _FRAME_RE.match(line)
+        if m:
+            if current is None:
+                current = Goroutine(id=1, state=STOP_STOPPED)
+                dump.goroutines.append(current)
+            current.frames.append(_parse_frame(m.group(3), m.group(2)))
+            continue
+        m = _THREAD_RE.match(line)
+        if m:
+            reason = _STOP_REASON_RE.search(m.group(3))
+            current = Goroutine(
+                id=int(m.group(2)),
+                state=reason.group(1).strip() if reason else "stopped",
+                current=bool(m.group(1)),
+            )
+            dump.goroutines.append(current)
+    return dump
+
+
+def parse_stop(output: str) -> StopEvent:
+    text = output or ""
+    m = _EXIT_RE.search(text)
+    if m:
+        return StopEvent(reason=STOP_EXITED, exit_code=int(m.group(1)), raw=text)
+    dump = parse_backtrace(text)
+    thread = next((g for g in dump.goroutines if g.current), dump.goroutines[0] if dump.goroutines else None)
+    if thread is None:
+        return StopEvent(reason=STOP_STOPPED, detail=text.strip(), raw=text)
+    state = thread.state
+    reason = STOP_STOPPED
+    breakpoint_id: Optional[int] = None
+    bp = re.match(r"breakpoint\s+(\d+)", state)
+    if bp:
+        reason = STOP_BREAKPOINT
+        breakpoint_id = int(bp.group(1))
+    elif re.match(r"watchpoint\s+\d+", state):
+        reason = STOP_WATCHPOINT
+        breakpoint_id = int(state.split()[1])
+    elif state.startswith("signal") or state.startswith("EXC_"):
+        reason = STOP_SIGNAL
+    # LLDB prints every thread with a stop reason, so threads that hit breakpoints together are all here.
+    hits: List[BreakpointHit] = []
+    for g in sorted(dump.goroutines, key=lambda g: g is not thread):
+        hit = re.match(r"breakpoint\s+(\d+)", g.state)
+        if hit:
+            frame = g.frames[0] if g.frames else None
+            hits.append(BreakpointHit(int(hit.group(1)), g.id, frame, selected=g is thread, unit="thread"))
+    return StopEvent(
+        reason=reason,
+        frame=thread.frames[0] if thread.frames else None,
+        goroutine_id=thread.id,
+        breakpoint_id=breakpoint_id,
+        detail=state,
+        raw=text,
+        hits=hits,
+    )
+
+
+def _is_resume_command(cmd: str) -> bool:
+    words = tuple(cmd.lower().split())
+    return any(words[: len(prefix)] == prefix for prefix in _RESUME_PREFIXES)
+
+
+class LldbDebugger(LldbSubprocessBackend):
+    """LLDB session exposing the structured ``Debugger`` interface."""
+
+    GOROUTINES_COMMAND = "thread backtrace all"
+
+    def __init__(
+        self,
+        program: str,
+        *,
+        core: Optional[str] = None,
+        lldb_path: str = "lldb",
+        timeout: float = 10.0,
+    ) -> None:
+        if not program:
+            raise ValueError("LLDB debugger requires a program path")
+        super().__init__(lldb_path=lldb_path, timeout=timeout)
+        self.program = program
+        self.core = core
+        self._launched = False
+        self._exit_code: Optional[int] = None
+        self._watchpoints: Dict[int, Watchpoint] = {}
+
+    @property
+    def post_mortem(self) -> bool:
+        return bool(self.core)
+
+    def initialize_session(self) -> None:
+        if shutil.which(self.lldb_path) is None:
+            raise DebuggerUnavailable(
+                f"LLDB executable '{self.lldb_path}' not found on PATH. "
+                "Install LLDB (e.g. `sudo apt install lldb`) or choose another debugger."
+            )
+        if pexpect is None:
+            raise DebuggerUnavailable("pexpect is required to drive LLDB")
+        super().initialize_session()
+        if self.core:
+            self._checked(f"target create {self.program} --core {self.core}")
+        else:
+            self._checked(f"target create {self.program}")
+
+    def run_command(self, cmd: str, timeout: float | None = None) -> str:
+        if self.core and _is_resume_command(cmd or ""):
+            return f"[post-mortem] {post_mortem_message(cmd.strip(), self.core)}"
+        return super().run_command(cmd, timeout=timeout)
+
+    def _checked(self, cmd: str) -> str:
+        out = self.run_command(cmd)
+        for line in out.splitlines():
+            if line.strip().startswith("error:"):
+                raise DebuggerError(line.strip()[len("error:"):].strip())
+        return out
+
+    def set_breakpoint(self, spec: Union[str, BreakpointSpec]) -> Breakpoint:
+        """Set a breakpoint; conditions are native (C/C++/Rust) expressions.
+
+        LLDB only evaluates the condition when the breakpoint is hit, so
+        unlike Delve no scope check happens here.
+        """
+        spec = as_spec(spec)
+        location = spec.location
+        m = _FILE_LINE_RE.match(location)
+        if m:
+            cmd = f"breakpoint set --file {active_path_map().to_target(m.group(1))} --line {m.group(2)}"
+        else:
+            cmd = f"breakpoint set --name {location}"
+        if spec.condition:
+            cmd += f" --condition {shlex.quote(spec.condition)}"
+        if spec.hit_count > 1:
+            cmd += f" --ignore-count {spec.hit_count - 1}"
+        out = self._checked(cmd)
+        m = _BREAKPOINT_RE.search(out)
+        if not m:
+            raise DebuggerError(f"Unexpected LLDB breakpoint output: {out.strip()}")
+        bp = Breakpoint(id=int(m.group(1)), location=location, condition=spec.condition, hit_count=spec.hit_count)
+        where = _BP_WHERE_RE.search(m.group(2))
+        if where:
+            bp.function = where.group(1)
+            bp.file = local_path(where.group(2) or "")
+            bp.line = int(where.group(3)) if where.group(3) else 0
+            bp.address = where.group(4)
+        elif "no locations" in m.group(2):
+            raise DebuggerError(f"Breakpoint {bp.id} for {location} has no locations")
+        return bp
+
+    def breakpoints(self) -> List[Breakpoint]:
+        result: List[Breakpoint] = []
+        current: Optional[Breakpoint] = None
+        for line in self._checked("breakpoint list").splitlines():
+            m = _LIST_RE.match(line.strip())
+            if m:
+                file, name = local_path(m.group(2) or ""), m.group(4) or ""
+                lineno = int(m.group(3)) if m.group(3) else 0
+                current = Breakpoint(
+                    id=int(m.group(1)),
+                    location=f"{file}:{lineno}" if file else name,
+                    function=name,
+                    file=file,
+                    line=lineno,
+                    hits=int(m.group(5)),
+                )
+                result.append(current)
+                continue
+            if current is None:
+                continue
+            c = _LIST_CONDITION_RE.match(line)
+            if c:
+                current.condition = c.group(1).strip()
+                continue
+            if line.strip().startswith("Options:"):
+                i = _LIST_IGNORE_RE.search(line)
+                if i:
+                    current.hit_count = int(i.group(1)) + 1
+                current.enabled = "disabled" not in line
+        return result
+
+    def clear_breakpoint(self, breakpoint_id: int) -> None:
+        self._checked(f"breakpoint delete {breakpoint_id}")
+
+    def set_watchpoint(self, expr: str, kind: str = WATCH_WRITE) -> Watchpoint:
+        """Watch a variable, or a raw ``0x...`` address, with a hardware watchpoint."""
+        kind = watch_kind(kind)
+        active = list(self._watchpoints.values())
+        if len(active) >= MAX_HARDWARE_WATCHPOINTS:
+            raise WatchpointLimitError(watch_limit_message(active))
+        expr = expr.strip()
+        if _ADDRESS_RE.match(expr):
+            cmd = f"watchpoint set expression -w {_WATCH_TYPES[kind]} -- {expr}"
+        else:
+            cmd = f"watchpoint set variable -w {_WATCH_TYPES[kind]} {expr}"
+        out = self.run_command(cmd)
+        if _WATCH_LIMIT_RE.search(out):
+            raise WatchpointLimitError(f"{watch_limit_message(active)} LLDB: {out.strip()}")
+        m = _WATCH_CREATED_RE.search(out)
+        if not m:
+            raise DebuggerError(f"Unexpected LLDB watchpoint output: {out.strip()}")
+        wp = Watchpoint(id=int(m.group(1)), expr=expr, kind=kind, address=m.group(2))
+        if not _ADDRESS_RE.match(expr) and "error:" in self.run_command(f"target variable {expr}"):
+            # A local: remember its function so the watchpoint can be dropped once that frame returns.
+            frames = self.stacktrace(depth=1)
+            wp.scope = frames[0].function if frames else ""
+        try:
+            wp.value = self.read_variable(expr).value
+        except DebuggerError:
+            wp.value = ""
+        self._watchpoints[wp.id] = wp
+        return wp
+
+    def watchpoints(self) -> List[Watchpoint]:
+        return list(self._watchpoints.values())
+
+    def clear_watchpoint(self, watchpoint_id: int) -> None:
+        self._watchpoints.pop(watchpoint_id, None)
+        self._checked(f"watchpoint delete {watchpoint_id}")
+
+    def continue_(self) -> StopEvent:
+        if self.core:
+            raise PostMortemError(post_mortem_message("process continue", self.core))
+        cmd = "process continue" if self._launched else "process launch"
+        out = self._checked(cmd)
+        event = parse_stop(out)
+        self._launched = not event.exited
+        self._exit_code = event.exit_code if event.exited else None
+        if event.reason != STOP_WATCHPOINT or event.breakpoint_id not in self._watchpoints:
+            return event
+        wp = self._watchpoints[event.breakpoint_id]
+        if wp.scope and wp.scope not in {f.function for f in self.stacktrace()}:
+            # The variable's frame has returned; its stack slot now belongs to someone else.
+            self.clear_watchpoint(wp.id)
+            event.reason = STOP_WATCH_SCOPE
+            event.detail = f"Watchpoint {wp.id} on {wp.expr} went out of scope ({wp.scope} returned) and was cleared"
+            return event
+        hit = _WATCH_HIT_RE.search(out)
+        new_value = hit.group(3).strip() if hit else wp.value
+        event.watch = WatchHit(
+            watchpoint=wp,
+            old_value=hit.group(2).strip() if hit else wp.value,
+            new_value=new_value,
+            frame=event.frame,
+            goroutine_id=event.goroutine_id,
+        )
+        wp.value = new_value
+        return event
+
+    def step(self) -> StopEvent:
+        return self._step(STEP_INTO)
+
+    def next(self) -> StopEvent:
+        return self._step(STEP_OVER)
+
+    def step_out(self) -> StopEvent:
+        return self._step(STEP_OUT)
+
+    def _step(self, command: str) -> StopEvent:
+        """Step the selected thread; a breakpoint in another thread stops the process there instead."""
+        if self.core:
+            raise PostMortemError(post_mortem_message(_STEP_COMMANDS[command], self.core))
+        if not self._launched:
+            if self._exit_code is not None:
+                raise ProcessExitedError(command, self._exit_code)
+            raise DebuggerError(f"Cannot {command}: the process is not running yet; continue to launch it first")
+        m = _SELECTED_THREAD_RE.search(self._checked("thread info"))
+        before = int(m.group(1)) if m else None
+        event = parse_stop(self._checked(_STEP_COMMANDS[command]))
+        if event.exited:
+            self._launched, self._exit_code = False, event.exit_code
+            return event
+        event.stack = self.stacktrace()
+        return finish_step(event, command, before, unit="thread")
+
+    def stacktrace(self, goroutine_id: Optional[int] = None, depth: int = 50) -> List[Frame]:
+        if goroutine_id is not None:
+            self._checked(f"thread select {goroutine_id}")
+        dump = parse_backtrace(self._checked(f"thread backtrace -c {depth}"))
+        return dump.goroutines[0].frames if dump.goroutines else []
+
+    def goroutines(self) -> GoroutineDump:
+        return parse_backtrace(self._checked(self.GOROUTINES_COMMAND))
+
+    def read_variable(self, expr: str, cfg: Optional[LoadConfig] = None) -> Variable:
+        options = ""
+        if cfg is not None:
+            self._checked(f"settings set target.max-children-count {cfg.max_array_values}")
+            self._checked(f"settings set target.max-string-summary-length {cfg.max_string_len}")
+            options = f"--depth {cfg.max_depth} --ptr-depth {cfg.max_depth if cfg.follow_pointers else 0} "
+        out = self._checked(f"expression {options}-- {expr}").strip()
+        m = _VALUE_RE.match(out)
+        if not m:
+            return Variable(name=expr, value=out)
+        return Variable(name=expr, value=m.group("value").strip(), type=m.group("type"))
+
+    def eval_in_frame(
+        self, goroutine_id: Optional[int], frame: int, expr: str, cfg: Optional[LoadConfig] = None
+    ) -> Variable:
+        """Select thread ``goroutine_id`` (LLDB's index, as in ``goroutines()``) and ``frame``, then evaluate.
+
+        The thread and frame selected before are selected again afterwards,
+        so a later ``bt`` or ``print`` sees where the user was.
+        """
+        if frame < 0:
+            raise DebuggerError(f"Frame index must not be negative, got {frame}")
+        thread_sel = _SELECTED_THREAD_RE.search(self._checked("thread info"))
+        frame_sel = _FRAME_RE.match((self._checked("frame info").strip().splitlines() or [""])[0])
+        try:
+            if goroutine_id is not None:
+                self._checked(f"thread select {goroutine_id}")
+            self._checked(f"frame select {frame}")
+            var = self.read_variable(expr, cfg)
+        except DebuggerError as e:
+            if optimized_away(str(e)):
+                raise OptimizedAwayError(expr, str(e), goroutine_id=goroutine_id, frame=frame) from None
+            raise
+        finally:
+            if thread_sel:
+                self.run_command(f"thread select {thread_sel.group(1)}")
+            if frame_sel:
+                self.run_command(f"frame select {frame_sel.group(1)}")
+        if optimized_away(var.value):
+            raise OptimizedAwayError(expr, var.value, goroutine_id=goroutine_id, frame=frame)
+        return var
+
+    def detach(self) -> None:
+        if self._launched and self.child is not None:
+            try:
+                self.run_command("process detach")
+            except Exception:
+                pass
+        self.close()
+
+    def close(self) -> None:
+        self._shutdown_child()
+        self._launched = False
+
+    def abort(self) -> None:
+        # The debugger takes its process down with it.
+        self.close()
+
+
+__all__ = ["LldbDebugger", "parse_backtrace", "parse_stop"]
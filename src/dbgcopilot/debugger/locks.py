@@ -0,0 +1,202 @@
+"""Lock ownership observed in a monitored run: breakpoints on sync's Lock and Unlock.
+
+Reconstructing held locks from source (``analyze.deadlock.held_locks``)
+misses locks taken through helpers, interfaces or code without source at
+hand, and only knows a lock by its expression. ``LockMonitor`` watches the
+run instead: it breaks on the ``sync.Mutex`` and ``sync.RWMutex`` lock and
+unlock methods and, at each hit, reads the receiver's address and the
+first user frame that called it. A Lock hit is an attempt until the
+goroutine shows up at another lock operation (so the lock was granted) or
+the final dump shows it is not still blocked on that address. ``apply``
+then fills ``Goroutine.observed_locks`` on a dump, and ``held_locks`` and
+the wait graph key those locks by their exact addresses, and ``describe``
+renders the lock cycles and held locks the REPL's ``/continue locks``
+hands to the LLM.
+
+``sync.(*RWMutex).Lock`` takes its embedded writer Mutex, at the same
+address, so a Mutex hit called from an RWMutex method is part of that
+operation and not recorded again.
+
+Every lock operation stops the world, so this is for reproducing a hang
+under the debugger, not for live traffic, and only Delve reads receivers
+this way. The binary must keep the methods out of line
+(``-gcflags=all='-N -l'``). A run of goroutines that block forever with
+others still running never returns from ``continue``; bound it with the
+debugger's ``context=`` deadline.
+"""
+from __future__ import annotations
+
+from typing import Any, Dict, List, Optional, Tuple
+import re
+
+from dbgcopilot.analyze.deadlock import (
+    HeldLock,
+    LockRef,
+    detect_deadlock,
+    format_deadlock_report,
+    format_held_locks,
+)
+from dbgcopilot.analyze.goroutines import LOCK_WAIT_KINDS, Frame, GoroutineDump
+
+from .base import Breakpoint, BreakpointHit, BreakpointSpec, DebuggerError, StopEvent, Variable, stop_hits
+
+OP_LOCK = "Lock"
+OP_UNLOCK = "Unlock"
+OP_RLOCK = "RLock"
+OP_RUNLOCK = "RUnlock"
+
+# Method -> (receiver name, operation).
+LOCK_METHODS: Dict[str, Tuple[str, str]] = {
+    "sync.(*Mutex).Lock": ("m", OP_LOCK),
+    "sync.(*Mutex).Unlock": ("m", OP_UNLOCK),
+    "sync.(*RWMutex).Lock": ("rw", OP_LOCK),
+    "sync.(*RWMutex).Unlock": ("rw", OP_UNLOCK),
+    "sync.(*RWMutex).RLock": ("rw", OP_RLOCK),
+    "sync.(*RWMutex).RUnlock": ("rw", OP_RUNLOCK),
+}
+DEFAULT_MAX_STOPS = 10000
+
+_ADDR_RE = re.compile(r"0x[0-9a-fA-F]+")
+_LOCKSLOW_RE = re.compile(r"\.lockSlow$|\(\*RWMutex\)\.R?Lock$")
+_RWMUTEX_PREFIX = "sync.(*RWMutex)."
+
+
+def _pointee_address(var: Variable) -> str:
+    # Structured replies give the pointee as the only child; CLI text may print (*sync.Mutex)(0x...).
+    if var.children and var.children[0].address:
+        return f"0x{var.children[0].address:x}"
+    m = _ADDR_RE.search(var.value or "")
+    return m.group(0) if m else ""
+
+
+def _caller(stack: List[Frame]) -> Optional[Frame]:
+    for frame in stack:
+        if not frame.is_runtime():
+            return frame
+    return None
+
+
+def _blocked_on(frames: List[Frame]) -> str:
+    for frame in frames:
+        if _LOCKSLOW_RE.search(frame.function):
+            m = _ADDR_RE.search(frame.args or "")
+            if m:
+                return m.group(0)
+    return ""
+
+
+class LockMonitor:
+    """Track, per goroutine, the locks it took and has not released while ``debugger`` runs."""
+
+    def __init__(self, debugger: Any) -> None:
+        self.debugger = debugger
+        # Breakpoint id -> (receiver name, operation).
+        self.operations: Dict[int, Tuple[str, str]] = {}
+        self._held: Dict[int, List[HeldLock]] = {}
+        # The Lock/RLock each goroutine last entered and may still be waiting in.
+        self._pending: Dict[int, HeldLock] = {}
+        self.stops = 0
+
+    def install(self) -> List[Breakpoint]:
+        """Break on every lock method the binary has; DebuggerError when it has none."""
+        placed: List[Breakpoint] = []
+        for function, operation in LOCK_METHODS.items():
+            try:
+                bp = self.debugger.set_breakpoint(BreakpointSpec(location=function))
+            except DebuggerError:
+                # Unused types (no RWMutex) or inlined methods leave nothing to break on.
+                continue
+            self.operations[bp.id] = operation
+            placed.append(bp)
+        if not placed:
+            raise DebuggerError("No sync Lock/Unlock method to break on; build with -gcflags=all='-N -l'")
+        return placed
+
+    def remove(self) -> None:
+        for breakpoint_id in list(self.operations):
+            self.debugger.clear_breakpoint(breakpoint_id)
+        self.operations.clear()
+
+    def record(self, goroutine_id: int, operation: str, address: str, location: str = "", function: str = "") -> None:
+        """One lock operation of ``goroutine_id`` on the lock at ``address``, called from ``location``."""
+        self._grant(goroutine_id)
+        read = operation in (OP_RLOCK, OP_RUNLOCK)
+        if operation in (OP_LOCK, OP_RLOCK):
+            lock = LockRef(address=address)
+            self._pending[goroutine_id] = HeldLock(lock=lock, acquired_at=location, function=function, read=read)
+            return
+        held = self._held.get(goroutine_id, [])
+        # The most recent acquisition of that lock in the same mode is the one released.
+        for idx in range(len(held) - 1, -1, -1):
+            if held[idx].lock.address == address and held[idx].read == read:
+                del held[idx]
+                break
+
+    def _grant(self, goroutine_id: int) -> None:
+        pending = self._pending.pop(goroutine_id, None)
+        if pending is not None:
+            self._held.setdefault(goroutine_id, []).append(pending)
+
+    def observe(self, event: StopEvent) -> bool:
+        """Record the lock operations of ``event``; False when it stopped for anything else."""
+        hits = [hit for hit in stop_hits(event) if hit.breakpoint_id in self.operations]
+        for hit in hits:
+            self._observe_hit(hit)
+        return bool(hits) and len(hits) == len(stop_hits(event))
+
+    def _observe_hit(self, hit: BreakpointHit) -> None:
+        receiver, operation = self.operations[hit.breakpoint_id or 0]
+        if hit.goroutine_id is None:
+            return
+        stack = hit.stack or self.debugger.stacktrace(hit.goroutine_id, depth=8)
+        if receiver == "m" and any(frame.function.startswith(_RWMUTEX_PREFIX) for frame in stack[1:]):
+            # RWMutex.Lock/Unlock on its writer Mutex: the RWMutex hit already recorded it.
+            return
+        address = _pointee_address(self.debugger.eval_in_frame(hit.goroutine_id, 0, receiver))
+        if not address:
+            return
+        caller = _caller(stack)
+        location = caller.location if caller is not None else ""
+        self.record(hit.goroutine_id, operation, address, location, caller.function if caller is not None else "")
+
+    def run(self, max_stops: int = DEFAULT_MAX_STOPS) -> StopEvent:
+        """Continue until the program stops for something other than a lock operation; returns that stop."""
+        while True:
+            event = self.debugger.continue_()
+            self.stops += 1
+            if not self.observe(event) or self.stops >= max_stops:
+                return event
+
+    def held(self, goroutine_id: int) -> List[HeldLock]:
+        return list(self._held.get(goroutine_id, []))
+
+    def apply(self, dump: GoroutineDump) -> GoroutineDump:
+        """Set ``observed_locks`` on ``dump``'s goroutines from what the run recorded."""
+        for goroutine in dump.goroutines:
+            held = self.held(goroutine.id)
+            pending = self._pending.get(goroutine.id)
+            if pending is not None:
+                # Still in a lock wait, it waits for this lock unless the dump names another one.
+                blocked = _blocked_on(goroutine.frames)
+                if goroutine.wait_kind not in LOCK_WAIT_KINDS or (blocked and blocked != pending.lock.address):
+                    held.append(pending)
+            goroutine.observed_locks = held
+        return dump
+
+    def describe(self, dump: GoroutineDump) -> str:
+        """The lock cycles and held locks of ``dump`` once the observed locks are applied to it."""
+        self.apply(dump)
+        sections = [format_deadlock_report(detect_deadlock(dump)), format_held_locks(dump)]
+        body = "\n\n".join(section for section in sections if section) or "No goroutine holds a lock."
+        return f"Lock monitor: {self.stops} stop(s) observed.\n{body}"
+
+
+__all__ = [
+    "DEFAULT_MAX_STOPS",
+    "LOCK_METHODS",
+    "OP_LOCK",
+    "OP_RLOCK",
+    "OP_RUNLOCK",
+    "OP_UNLOCK",
+    "LockMonitor",
+]
@@ -0,0 +1,277 @@
+"""Repeated breakpoint hits in loops, summarized per location instead of reported one by one.
+
+A breakpoint inside a loop that runs thousands of times makes ``continue``
+useless hit by hit, and handing every hit to the LLM drowns the prompt.
+``HitAggregator`` continues the program itself and, at each hit, snapshots
+the locals of the frame that hit it (or the given expressions). Hits at the
+same location form a burst while each follows the previous one within
+``window`` seconds; a longer pause starts a new burst. A burst of at most
+``threshold`` hits is listed hit by hit, as ``continue`` would have
+reported it. A longer one keeps only its count, the goroutines that hit
+it, the first and last snapshots and, per variable, the range of numeric
+values or the distinct values seen, and ``describe()`` is all that is sent
+for analysis.
+
+The run ends at the first stop that is not a breakpoint hit (an exit, a
+panic, a watchpoint) or after ``max_hits``. Like ``locks.LockMonitor``,
+every hit stops the world, so bound a loop that never ends with
+``max_hits`` or the debugger's ``context=`` deadline.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, List, Optional, Sequence
+import re
+import time
+
+from .base import (
+    STOP_EXITED,
+    BreakpointHit,
+    DebuggerError,
+    LoadConfig,
+    ProcessExitedError,
+    StopEvent,
+    stop_hits,
+)
+from .pretty import pretty_print
+
+DEFAULT_AGGREGATE_THRESHOLD = 3
+# Seconds between two hits at a location for them to count as one burst.
+DEFAULT_AGGREGATE_WINDOW = 10.0
+DEFAULT_MAX_HITS = 5000
+MAX_DISTINCT_VALUES = 5
+MAX_VALUE_LEN = 80
+
+# Snapshots are taken at every hit, so they load far less than the session's limits.
+SNAPSHOT_LOAD = LoadConfig(max_depth=1, max_array_values=8, max_string_len=64)
+
+_NUMBER_RE = re.compile(r"^-?\d+(\.\d+)?([eE][-+]?\d+)?$")
+
+
+def _new_str_list() -> List[str]:
+    return []
+
+
+def _new_int_list() -> List[int]:
+    return []
+
+
+def _new_snapshot() -> Dict[str, str]:
+    return {}
+
+
+def _new_ranges() -> Dict[str, "ValueRange"]:
+    return {}
+
+
+def _compact(text: str) -> str:
+    flat = " ".join(text.split())
+    return flat if len(flat) <= MAX_VALUE_LEN else flat[: MAX_VALUE_LEN - 3] + "..."
+
+
+def _number(value: float) -> str:
+    return str(int(value)) if value.is_integer() and abs(value) < 1e15 else repr(value)
+
+
+def format_snapshot(snapshot: Dict[str, str]) -> str:
+    return ", ".join(f"{name}={value}" for name, value in snapshot.items()) or "(no variables)"
+
+
+@dataclass
+class ValueRange:
+    """The values one variable took over a burst: a numeric range, or the first few distinct values."""
+
+    name: str
+    first: str
+    last: str = ""
+    seen: int = 0
+    low: Optional[float] = None
+    high: Optional[float] = None
+    numeric: bool = True
+    distinct: List[str] = field(default_factory=_new_str_list)
+    more: bool = False
+
+    def add(self, value: str) -> None:
+        self.seen += 1
+        self.last = value
+        if self.numeric and _NUMBER_RE.match(value):
+            number = float(value)
+            self.low = number if self.low is None else min(self.low, number)
+            self.high = number if self.high is None else max(self.high, number)
+        else:
+            self.numeric = False
+        if value not in self.distinct:
+            if len(self.distinct) < MAX_DISTINCT_VALUES:
+                self.distinct.append(value)
+            else:
+                self.more = True
+
+    def describe(self) -> str:
+        if len(self.distinct) == 1:
+            return f"{self.name} = {self.first} (unchanged)"
+        if self.numeric and self.low is not None and self.high is not None:
+            return f"{self.name} in [{_number(self.low)}, {_number(self.high)}], first {self.first}, last {self.last}"
+        values = ", ".join(self.distinct) + (", ..." if self.more else "")
+        return f"{self.name} took {values}; last {self.last}"
+
+
+@dataclass
+class HitBurst:
+    """Consecutive hits at one location, each within the window of the one before."""
+
+    location: str
+    breakpoint: str
+    function: str = ""
+    count: int = 0
+    goroutines: List[int] = field(default_factory=_new_int_list)
+    started: float = 0.0
+    ended: float = 0.0
+    first: Dict[str, str] = field(default_factory=_new_snapshot)
+    last: Dict[str, str] = field(default_factory=_new_snapshot)
+    ranges: Dict[str, ValueRange] = field(default_factory=_new_ranges)
+    # Each hit as continue would report it, kept only while the burst is within the threshold.
+    hits: List[str] = field(default_factory=_new_str_list)
+
+    def add(self, hit: BreakpointHit, snapshot: Dict[str, str], now: float, threshold: int) -> None:
+        if not self.count:
+            self.started, self.first = now, snapshot
+        self.count += 1
+        self.ended, self.last = now, snapshot
+        if hit.goroutine_id is not None and hit.goroutine_id not in self.goroutines:
+            self.goroutines.append(hit.goroutine_id)
+        for name, value in snapshot.items():
+            self.ranges.setdefault(name, ValueRange(name=name, first=value)).add(value)
+        if self.count <= threshold:
+            self.hits.append(hit.describe() + (f"; {format_snapshot(snapshot)}" if snapshot else ""))
+        else:
+            self.hits = []
+
+    @property
+    def summarized(self) -> bool:
+        return not self.hits
+
+    def describe(self) -> str:
+        if not self.summarized:
+            return "\n".join(f"- {line}" for line in self.hits)
+        where = f"{self.function} {self.location}" if self.function else self.location
+        who = ", ".join(str(g) for g in self.goroutines) or "the program"
+        lines = [
+            f"- {where} (breakpoint {self.breakpoint}): {self.count} hits by goroutine(s) {who} "
+            f"over {self.ended - self.started:.1f}s",
+            f"    first: {format_snapshot(self.first)}",
+            f"    last: {format_snapshot(self.last)}",
+        ]
+        for value_range in self.ranges.values():
+            if value_range.seen < self.count:
+                lines.append(f"    {value_range.describe()} (in {value_range.seen} of {self.count} hits)")
+            else:
+                lines.append(f"    {value_range.describe()}")
+        return "\n".join(lines)
+
+
+class HitAggregator:
+    """Continue ``debugger`` through repeated breakpoint hits, summarizing bursts longer than ``threshold``."""
+
+    def __init__(
+        self,
+        debugger: Any,
+        *,
+        threshold: int = DEFAULT_AGGREGATE_THRESHOLD,
+        window: float = DEFAULT_AGGREGATE_WINDOW,
+        expressions: Sequence[str] = (),
+        clock: Callable[[], float] = time.monotonic,
+    ) -> None:
+        if threshold < 1:
+            raise ValueError("the aggregation threshold must be at least 1")
+        self.debugger = debugger
+        self.threshold = threshold
+        self.window = window
+        self.expressions = list(expressions)
+        self.clock = clock
+        self.bursts: List[HitBurst] = []
+        # Location -> its current burst.
+        self._open: Dict[str, HitBurst] = {}
+        self.hits = 0
+        self.final: Optional[StopEvent] = None
+
+    def snapshot(self, hit: BreakpointHit) -> Dict[str, str]:
+        """The watched expressions, or every local of the frame that hit, as compact one-line values."""
+        values: Dict[str, str] = {}
+        if self.expressions:
+            for expr in self.expressions:
+                try:
+                    values[expr] = _compact(pretty_print(self.debugger.eval_in_frame(hit.goroutine_id, 0, expr)))
+                except DebuggerError as e:
+                    values[expr] = f"<{e}>"
+            return values
+        frame_locals = getattr(self.debugger, "frame_locals", None)
+        if not callable(frame_locals):
+            return values
+        try:
+            variables = frame_locals(hit.goroutine_id, 0, SNAPSHOT_LOAD)
+        except DebuggerError:
+            return values
+        for var in variables:
+            values[var.name] = _compact(pretty_print(var))
+        return values
+
+    def add(self, hit: BreakpointHit, snapshot: Dict[str, str]) -> HitBurst:
+        """Count ``hit`` in its location's burst, starting a new one after a pause longer than the window."""
+        which = hit.name or (str(hit.breakpoint_id) if hit.breakpoint_id is not None else "?")
+        location = hit.frame.location if hit.frame is not None else f"breakpoint {which}"
+        now = self.clock()
+        burst = self._open.get(location)
+        if burst is None or now - burst.ended > self.window:
+            function = hit.frame.function if hit.frame is not None else ""
+            burst = HitBurst(location=location, breakpoint=which, function=function)
+            self._open[location] = burst
+            self.bursts.append(burst)
+        burst.add(hit, snapshot, now, self.threshold)
+        self.hits += 1
+        return burst
+
+    def observe(self, event: StopEvent) -> bool:
+        """Add the hits of ``event``; False when it stopped for anything else."""
+        hits = stop_hits(event)
+        for hit in hits:
+            self.add(hit, self.snapshot(hit))
+        return bool(hits) and event.watch is None
+
+    def run(self, max_hits: int = DEFAULT_MAX_HITS) -> StopEvent:
+        """Continue until a stop that is not a breakpoint hit, or ``max_hits`` hits; returns that stop."""
+        while True:
+            try:
+                event = self.debugger.continue_()
+            except ProcessExitedError as e:
+                event = StopEvent(reason=STOP_EXITED, exit_code=e.exit_code)
+            if not self.observe(event) or self.hits >= max_hits:
+                self.final = event
+                return event
+
+    def describe(self) -> str:
+        """The summary sent for analysis: each burst, then where the run ended."""
+        locations = len({burst.location for burst in self.bursts})
+        lines = [
+            f"Breakpoint hits: {self.hits} at {locations} location(s) in {len(self.bursts)} burst(s); "
+            f"bursts of more than {self.threshold} hits are summarized."
+        ]
+        lines += [burst.describe() for burst in self.bursts]
+        if self.final is not None:
+            hits = stop_hits(self.final)
+            if hits and self.final.watch is None:
+                lines.append(f"Stopped after {self.hits} hits (the limit); the program is still at the last one.")
+            else:
+                lines.append(f"Then: {self.final.describe()}")
+        return "\n".join(lines)
+
+
+__all__ = [
+    "DEFAULT_AGGREGATE_THRESHOLD",
+    "DEFAULT_AGGREGATE_WINDOW",
+    "DEFAULT_MAX_HITS",
+    "MAX_DISTINCT_VALUES",
+    "HitAggregator",
+    "HitBurst",
+    "ValueRange",
+    "format_snapshot",
+]
@@ -0,0 +1,354 @@
+"""A scripted ``Debugger`` for testing the analysis layer without dlv or a binary.
+
+``MockDebugger.load(path)`` reads a JSON script: the debugger it stands in
+for, and the stops the program makes, in order. Each step carries what the
+debugger would show while stopped there:
+
+```json
+{
+  "debugger": "delve",
+  "steps": [
+    {
+      "stop": {"reason": "breakpoint", "goroutine_id": 6, "breakpoint_id": 1,
+               "frame": {"function": "main.workerOne", "file": "hang.go", "line": 18}},
+      "goroutines": [{"id": 6, "state": "sleep", "frames": [{"function": "main.workerOne", ...}]}],
+      "variables": {"lockA": {"type": "sync.Mutex", "kind": "struct", "children": [...]}},
+      "scopes": [{"goroutine": 6, "frame": 0, "variables": {"wg": {"value": "..."}}}],
+      "commands": {"print lockA.state": "1"}
+    }
+  ]
+}
+```
+
+``goroutines`` is a list of goroutines (``id``, ``state``, ``frames``,
+``created_by``) or the text of a dump, parsed with ``parse_goroutine_dump``;
+``stacks`` maps goroutine ids to frames when they differ from the dump.
+Variables use Delve's ``api.Variable`` JSON (``pretty.variable_from_rpc``),
+and one with an ``"error"`` raises it as a ``DebuggerError``. Relative
+``file`` paths resolve against the script's directory, so fixtures can
+point at real source (the deadlock analyzer reads it to name the locks).
+
+The debugger starts before the first step; ``continue_``, ``step``,
+``next`` and ``step_out`` move to the next stop, and past the last one
+raise ``ProcessExitedError``. A top-level ``"initial"`` object is the state
+before the first stop. Keys missing from a step fall back to the top level
+of the script. Every call is recorded in ``calls`` for assertions.
+"""
+from __future__ import annotations
+
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Tuple, Union
+import json
+import os
+import re
+
+from dbgcopilot.analyze.goroutines import Frame, Goroutine, GoroutineDump, parse_goroutine_dump
+
+from .base import (
+    STOP_EXITED,
+    STOP_STEP,
+    WATCH_WRITE,
+    Breakpoint,
+    BreakpointHit,
+    BreakpointSpec,
+    DebuggerError,
+    LoadConfig,
+    PostMortemError,
+    ProcessExitedError,
+    StopEvent,
+    Variable,
+    Watchpoint,
+    as_spec,
+)
+from .pretty import variable_from_rpc
+
+# What each stand-in lists goroutines (or threads) with, as the real drivers do.
+_GOROUTINES_COMMANDS = {"delve": "goroutines -t", "lldb": "thread backtrace all", "pdb": "threads"}
+
+
+class MockDebugger:
+    """Replay a scripted debugging session; see the module docstring for the script format."""
+
+    def __init__(self, script: Dict[str, Any], *, base_dir: Union[str, Path, None] = None) -> None:
+        self.script = script
+        self.base_dir = Path(base_dir) if base_dir is not None else Path.cwd()
+        self.name = str(script.get("debugger") or "delve")
+        self.GOROUTINES_COMMAND = _GOROUTINES_COMMANDS.get(self.name, "goroutines -t")
+        self.post_mortem = bool(script.get("post_mortem"))
+        self.steps: List[Dict[str, Any]] = list(script.get("steps") or [])
+        # -1 until the first stop.
+        self.position = -1
+        self.calls: List[Tuple[Any, ...]] = []
+        self.closed = False
+        self._breakpoints: Dict[int, Breakpoint] = {}
+        self._watchpoints: Dict[int, Watchpoint] = {}
+        self._next_id = 1
+
+    @classmethod
+    def load(cls, path: Union[str, Path]) -> "MockDebugger":
+        path = Path(path)
+        return cls(json.loads(path.read_text(encoding="utf-8")), base_dir=path.parent)
+
+    # ------------------------------------------------------------------
+    def _state(self) -> Dict[str, Any]:
+        if self.position < 0:
+            return dict(self.script.get("initial") or {})
+        return self.steps[self.position]
+
+    def _lookup(self, key: str) -> Any:
+        state = self._state()
+        return state[key] if key in state else self.script.get(key)
+
+    def _path(self, file: str) -> str:
+        if not file or os.path.isabs(file):
+            return file
+        return os.path.normpath(self.base_dir / file)
+
+    def _frame(self, raw: Optional[Dict[str, Any]]) -> Optional[Frame]:
+        if not raw:
+            return None
+        return Frame(
+            function=str(raw.get("function") or "?"),
+            file=self._path(str(raw.get("file") or "")),
+            line=int(raw.get("line") or 0),
+            args=str(raw.get("args") or ""),
+            pc=str(raw.get("pc") or ""),
+        )
+
+    def _frames(self, raw: List[Dict[str, Any]]) -> List[Frame]:
+        return [f for f in (self._frame(r) for r in raw) if f is not None]
+
+    # ------------------------------------------------------------------
+    def initialize_session(self) -> None:
+        self.calls.append(("initialize_session",))
+
+    def run_command(self, cmd: str, timeout: float | None = None) -> str:
+        self.calls.append(("run_command", cmd))
+        commands = self._lookup("commands") or {}
+        if cmd in commands:
+            return str(commands[cmd])
+        if cmd == self.GOROUTINES_COMMAND:
+            return self.goroutines().raw
+        return ""
+
+    def set_breakpoint(self, spec: Union[str, BreakpointSpec]) -> Breakpoint:
+        spec = as_spec(spec)
+        self.calls.append(("set_breakpoint", spec.location))
+        if spec.regex:
+            raise DebuggerError(f"{spec.describe()} can match several functions; use set_breakpoints")
+        return self._add_breakpoint(spec, spec.location, self._locate(spec.location))
+
+    def set_breakpoints(self, spec: BreakpointSpec) -> List[Breakpoint]:
+        """One breakpoint per function of the script's stacks that ``spec.location`` matches."""
+        self.calls.append(("set_breakpoints", spec.location))
+        if not spec.regex:
+            return [self.set_breakpoint(spec)]
+        pattern = re.compile(spec.location)
+        return [
+            self._add_breakpoint(spec, frame.function, frame)
+            for frame in self._known_functions()
+            if pattern.search(frame.function)
+        ]
+
+    def _add_breakpoint(self, spec: BreakpointSpec, location: str, frame: Optional[Frame]) -> Breakpoint:
+        bp = Breakpoint(
+            id=self._next_id,
+            location=location,
+            function=frame.function if frame is not None else "",
+            file=frame.file if frame is not None else "",
+            line=frame.line if frame is not None else 0,
+            condition=spec.condition,
+            hit_count=spec.hit_count,
+        )
+        self._next_id += 1
+        self._breakpoints[bp.id] = bp
+        return bp
+
+    def _known_functions(self) -> List[Frame]:
+        """The first frame of each function anywhere in the script, in script order."""
+        seen: Dict[str, Frame] = {}
+        states = [self.script.get("initial") or {}] + self.steps
+        for state in states:
+            for goroutine in self._dump_of(state).goroutines:
+                for frame in goroutine.frames:
+                    if not frame.is_runtime():
+                        seen.setdefault(frame.function, frame)
+        return list(seen.values())
+
+    def _locate(self, location: str) -> Optional[Frame]:
+        for frame in self._known_functions():
+            if location in (frame.function, f"{os.path.basename(frame.file)}:{frame.line}", frame.location):
+                return frame
+        return None
+
+    def breakpoints(self) -> List[Breakpoint]:
+        return list(self._breakpoints.values())
+
+    def clear_breakpoint(self, breakpoint_id: int) -> None:
+        self.calls.append(("clear_breakpoint", breakpoint_id))
+        if self._breakpoints.pop(breakpoint_id, None) is None:
+            raise DebuggerError(f"Breakpoint {breakpoint_id} does not exist")
+
+    def set_watchpoint(self, expr: str, kind: str = WATCH_WRITE) -> Watchpoint:
+        self.calls.append(("set_watchpoint", expr, kind))
+        wp = Watchpoint(id=self._next_id, expr=expr, kind=kind)
+        self._next_id += 1
+        self._watchpoints[wp.id] = wp
+        return wp
+
+    def watchpoints(self) -> List[Watchpoint]:
+        return list(self._watchpoints.values())
+
+    def clear_watchpoint(self, watchpoint_id: int) -> None:
+        self.calls.append(("clear_watchpoint", watchpoint_id))
+        self._watchpoints.pop(watchpoint_id, None)
+
+    # ------------------------------------------------------------------
+    def continue_(self) -> StopEvent:
+        return self._advance("continue")
+
+    def step(self) -> StopEvent:
+        return self._advance("step")
+
+    def next(self) -> StopEvent:
+        return self._advance("next")
+
+    def step_out(self) -> StopEvent:
+        return self._advance("stepout")
+
+    def _advance(self, command: str) -> StopEvent:
+        self.calls.append((command,))
+        if self.post_mortem:
+            raise PostMortemError(f"Cannot {command}: the script is a core dump")
+        last = (self.steps[self.position].get("stop") or {}) if self.position >= 0 else {}
+        if last.get("reason") == STOP_EXITED or self.position + 1 >= len(self.steps):
+            raise ProcessExitedError(command, last.get("exit_code"))
+        self.position += 1
+        return self.stop()
+
+    def stop(self) -> StopEvent:
+        """The stop the script is at (``STOP_STOPPED`` before the first one)."""
+        raw = dict(self._state().get("stop") or {"reason": "stopped"})
+        frame = self._frame(raw.get("frame"))
+        goroutine_id = raw.get("goroutine_id")
+        hits = [
+            BreakpointHit(
+                breakpoint_id=hit.get("breakpoint_id"),
+                goroutine_id=hit.get("goroutine_id"),
+                frame=self._frame(hit.get("frame")),
+                selected=index == 0,
+            )
+            for index, hit in enumerate(raw.get("hits") or [])
+        ]
+        return StopEvent(
+            reason=str(raw.get("reason") or "stopped"),
+            frame=frame,
+            goroutine_id=goroutine_id,
+            breakpoint_id=raw.get("breakpoint_id"),
+            exit_code=raw.get("exit_code"),
+            detail=str(raw.get("detail") or ""),
+            raw=str(raw.get("raw") or ""),
+            stack=self.stacktrace(goroutine_id) if raw.get("reason") == STOP_STEP else [],
+            hits=hits,
+        )
+
+    # ------------------------------------------------------------------
+    def _dump_of(self, state: Dict[str, Any]) -> GoroutineDump:
+        raw = state.get("goroutines", self.script.get("goroutines"))
+        if raw is None:
+            return GoroutineDump()
+        if isinstance(raw, str):
+            return parse_goroutine_dump(raw)
+        goroutines = [
+            Goroutine(
+                id=int(g["id"]),
+                state=str(g.get("state") or ""),
+                wait_minutes=g.get("wait_minutes"),
+                frames=self._frames(g.get("frames") or []),
+                created_by=self._frame(g.get("created_by")),
+                current=bool(g.get("current")),
+            )
+            for g in raw
+        ]
+        return GoroutineDump(goroutines=goroutines, header=str(state.get("header") or ""), raw=_render_dump(goroutines))
+
+    def goroutines(self) -> GoroutineDump:
+        self.calls.append(("goroutines",))
+        return self._dump_of(self._state())
+
+    def stacktrace(self, goroutine_id: Optional[int] = None, depth: int = 50) -> List[Frame]:
+        self.calls.append(("stacktrace", goroutine_id))
+        if goroutine_id is None:
+            goroutine_id = (self._state().get("stop") or {}).get("goroutine_id")
+        stacks = self._lookup("stacks") or {}
+        if str(goroutine_id) in stacks:
+            return self._frames(stacks[str(goroutine_id)])[:depth]
+        dump = self._dump_of(self._state())
+        goroutine = dump.get(goroutine_id) if goroutine_id is not None else None
+        if goroutine is None:
+            raise DebuggerError(f"No goroutine {goroutine_id} at this stop")
+        return goroutine.frames[:depth]
+
+    def read_variable(self, expr: str, cfg: Optional[LoadConfig] = None) -> Variable:
+        self.calls.append(("read_variable", expr))
+        return self._variable(self._lookup("variables") or {}, expr)
+
+    def eval_in_frame(
+        self, goroutine_id: Optional[int], frame: int, expr: str, cfg: Optional[LoadConfig] = None
+    ) -> Variable:
+        self.calls.append(("eval_in_frame", goroutine_id, frame, expr))
+        if goroutine_id is None and frame == 0:
+            return self.read_variable(expr, cfg)
+        return self._variable(self._scope(goroutine_id, frame), expr)
+
+    def frame_locals(self, goroutine_id: Optional[int], frame: int, cfg: Optional[LoadConfig] = None) -> List[Variable]:
+        self.calls.append(("frame_locals", goroutine_id, frame))
+        variables = self._scope(goroutine_id, frame)
+        return [self._variable(variables, name) for name in variables]
+
+    def _scope(self, goroutine_id: Optional[int], frame: int) -> Dict[str, Any]:
+        for scope in self._lookup("scopes") or []:
+            if scope.get("goroutine") == goroutine_id and int(scope.get("frame") or 0) == frame:
+                return dict(scope.get("variables") or {})
+        return {}
+
+    def _variable(self, variables: Dict[str, Any], expr: str) -> Variable:
+        raw = variables.get(expr)
+        if raw is None:
+            raise DebuggerError(f"could not find symbol value for {expr}")
+        if isinstance(raw, str):
+            return Variable(name=expr, value=raw)
+        if raw.get("error"):
+            raise DebuggerError(str(raw["error"]))
+        var = variable_from_rpc(raw)
+        var.name = var.name or expr
+        return var
+
+    # ------------------------------------------------------------------
+    def detach(self) -> None:
+        self.calls.append(("detach",))
+        self.closed = True
+
+    def close(self) -> None:
+        self.calls.append(("close",))
+        self.closed = True
+
+    def abort(self) -> None:
+        self.calls.append(("abort",))
+        self.closed = True
+
+
+def _render_dump(goroutines: List[Goroutine]) -> str:
+    """The goroutines in the Go runtime's dump format, for callers that read raw output."""
+    blocks: List[str] = []
+    for g in goroutines:
+        lines = [f"goroutine {g.id} [{g.state or 'running'}]:"]
+        for frame in g.frames:
+            lines += [f"{frame.function}({frame.args})", f"\t{frame.location}"]
+        if g.created_by is not None:
+            lines += [f"created by {g.created_by.function}", f"\t{g.created_by.location}"]
+        blocks.append("\n".join(lines))
+    return "\n\n".join(blocks) + ("\n" if blocks else "")
+
+
+__all__ = ["MockDebugger"]
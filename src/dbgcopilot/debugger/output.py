@@ -0,0 +1,219 @@
+"""The target's own stdout/stderr, kept in a bounded ring buffer with arrival times.
+
+What a program printed before it stopped ("workerOne locking A", then
+"workerTwo locking B") is often the best clue to a hang, so the agent puts
+the last lines in the prompt next to the stack dump. ``OutputRing`` stores
+complete lines stamped with the time their first byte arrived and evicts
+the oldest once ``max_bytes`` is exceeded, so a chatty program cannot grow
+memory without bound.
+
+The subprocess backends run the target on the debugger's pty, so its
+stdout and stderr arrive (merged) in the same stream as the debugger's
+replies. ``TargetOutputTee`` is installed as the pexpect child's
+``logfile_read``; it records only while a resuming command runs (see
+``capturing``), stops at the debugger's next prompt (so a goroutine dump
+taken after an interrupt is not mistaken for program output) and drops the
+command echo and the debugger's own stop banners.
+"""
+from __future__ import annotations
+
+from collections import deque
+from contextlib import contextmanager
+from dataclasses import dataclass
+from typing import Any, Callable, Deque, Iterator, List, Optional
+import re
+import threading
+import time
+
+DEFAULT_OUTPUT_LINES = 40
+DEFAULT_OUTPUT_BYTES = 64 * 1024
+# A "line" that never ends (progress bars, binary junk) is cut at this many characters.
+MAX_LINE_CHARS = 2000
+
+# First words of commands, across debuggers, that let the target run.
+_RESUME_WORDS = {
+    "run", "r", "start", "continue", "c", "cont", "next", "n", "step", "s", "stepout", "so", "finish",
+    "until", "unt", "advance", "jump", "j", "restart", "return", "si", "ni", "stepi", "nexti", "call",
+    "dc", "ds", "dso",
+}
+_RESUME_PAIRS = {("process", "continue"), ("process", "launch"), ("thread", "step-in"), ("thread", "step-over"),
+                 ("thread", "step-out"), ("thread", "until")}
+
+# Prompts and stop banners printed by gdb, lldb, Delve, pdb and jdb rather than by the program.
+_CHATTER_RE = re.compile(
+    r"^(?:"
+    r"\((?:gdb|lldb|dlv|Pdb)\)"
+    r"|> \S+\(.*\)"                                                 # Delve / pdb current location
+    r"|=?>?\s+\d+:\t"                                               # Delve source listing
+    r"|-> |-->\s"                                                   # pdb / lldb current line
+    r"|\[(?:Breakpoint|Watchpoint|New Thread|Thread |Inferior|Switching|Detaching)"
+    r"|Process \d+ (?:has exited|launched|stopped|resuming|exited)"
+    r"|Starting program: |Continuing\.|Run till exit"
+    r"|Breakpoint \d+[,:] |Thread \d+ .* hit |Watchpoint \d+ hit"
+    r"|(?:Program|Thread \d+ \S+) received signal"
+    r"|received SIGINT|Command failed: "
+    r"|\* thread #|\s+frame #\d+"
+    r"|--(?:Return|Call)--"
+    r"|\d+\t"                                                       # gdb source line
+    r"|\S+\[\d+\] "                                                 # jdb "main[1] "
+    r")"
+)
+# The debugger waiting for input again: the program has stopped.
+_PROMPT_RE = re.compile(r"(?:^|\n)(?:\((?:gdb|lldb|dlv|Pdb)\) ?|\S+\[\d+\] |> )$")
+
+
+def resumes_target(cmd: str) -> bool:
+    """True when ``cmd`` (in any supported debugger's syntax) lets the program run."""
+    words = (cmd or "").strip().lower().split()
+    if not words:
+        return False
+    return words[0] in _RESUME_WORDS or tuple(words[:2]) in _RESUME_PAIRS
+
+
+@dataclass
+class OutputLine:
+    # time.time() when the line's first byte arrived.
+    at: float
+    text: str
+
+    def render(self) -> str:
+        stamp = time.strftime("%H:%M:%S", time.localtime(self.at)) + f".{int(self.at * 1000) % 1000:03d}"
+        return f"[{stamp}] {self.text}"
+
+
+class OutputRing:
+    """Most recent complete lines, at most ``max_bytes`` (UTF-8) of text in total."""
+
+    def __init__(self, max_bytes: int = DEFAULT_OUTPUT_BYTES, *, clock: Callable[[], float] = time.time) -> None:
+        self.max_bytes = max(int(max_bytes), 1)
+        self._clock = clock
+        self._lines: Deque[OutputLine] = deque()
+        self._bytes = 0
+        self._partial = ""
+        self._partial_at = 0.0
+        self._lock = threading.Lock()
+        # Lines evicted to stay under max_bytes.
+        self.dropped = 0
+
+    def write(self, data: Any) -> int:
+        """File-like write: split into lines, stamping each with its arrival time."""
+        text = data.decode("utf-8", "replace") if isinstance(data, bytes) else str(data)
+        if not text:
+            return 0
+        now = self._clock()
+        *complete, tail = text.replace("\r\n", "\n").replace("\r", "\n").split("\n")
+        with self._lock:
+            for piece in complete:
+                self._feed(piece, now, complete=True)
+            if tail:
+                self._feed(tail, now, complete=False)
+        return len(text)
+
+    def _feed(self, piece: str, now: float, *, complete: bool) -> None:
+        if not self._partial:
+            self._partial_at = now
+        self._partial += piece
+        if complete or len(self._partial) >= MAX_LINE_CHARS:
+            self._append(OutputLine(self._partial_at, self._partial[:MAX_LINE_CHARS]))
+            self._partial = ""
+
+    def _append(self, line: OutputLine) -> None:
+        self._lines.append(line)
+        self._bytes += len(line.text.encode("utf-8", "replace"))
+        while self._bytes > self.max_bytes and len(self._lines) > 1:
+            old = self._lines.popleft()
+            self._bytes -= len(old.text.encode("utf-8", "replace"))
+            self.dropped += 1
+
+    def flush(self) -> None:
+        pass
+
+    def lines(self, n: Optional[int] = None) -> List[OutputLine]:
+        with self._lock:
+            lines = list(self._lines)
+            if self._partial:
+                lines.append(OutputLine(self._partial_at, self._partial))
+        return lines[-n:] if n else lines
+
+    def render(self, n: int = DEFAULT_OUTPUT_LINES) -> str:
+        """The last ``n`` lines with their arrival times, oldest first ("" when empty)."""
+        lines = self.lines(n)
+        return "\n".join(line.render() for line in lines)
+
+    @property
+    def total_bytes(self) -> int:
+        return self._bytes
+
+    def __len__(self) -> int:
+        return len(self._lines)
+
+
+class TargetOutputTee:
+    """pexpect ``logfile_read`` that copies program output into an ``OutputRing``."""
+
+    def __init__(self, ring: OutputRing, previous: Any = None) -> None:
+        self.ring = ring
+        self.previous = previous
+        self.active = False
+        self._command = ""
+        self._pending = ""
+
+    @contextmanager
+    def capturing(self, cmd: str = "") -> Iterator[None]:
+        """Record what arrives while ``cmd`` runs; the echoed command itself is skipped."""
+        self.active, self._command, self._pending = True, (cmd or "").strip(), ""
+        try:
+            yield
+        finally:
+            self.active = False
+            if self._pending:
+                self._keep(self._pending)
+            self._pending = ""
+
+    def write(self, data: Any) -> None:
+        if self.previous is not None:
+            self.previous.write(data)
+        if not self.active:
+            return
+        text = data.decode("utf-8", "replace") if isinstance(data, bytes) else str(data)
+        text = (self._pending + text).replace("\r\n", "\n")
+        prompt = _PROMPT_RE.search(text)
+        if prompt is not None:
+            text, self.active = text[: prompt.start()] + "\n", False
+        *complete, self._pending = text.split("\n")
+        for line in complete:
+            self._keep(line + "\n")
+
+    def _keep(self, line: str) -> None:
+        stripped = line.strip()
+        if not stripped or stripped == self._command or _CHATTER_RE.match(line.rstrip("\n")):
+            return
+        self.ring.write(line)
+
+    def flush(self) -> None:
+        if self.previous is not None:
+            self.previous.flush()
+
+
+def tee_target_output(backend: Any, ring: OutputRing) -> Optional[TargetOutputTee]:
+    """Hook ``ring`` onto the backend's pexpect child; None for backends without one (remote RPC)."""
+    child = getattr(backend, "child", None)
+    if child is None or not hasattr(child, "logfile_read"):
+        return None
+    current = child.logfile_read
+    if isinstance(current, TargetOutputTee):
+        return current
+    tee = TargetOutputTee(ring, current)
+    child.logfile_read = tee
+    return tee
+
+
+__all__ = [
+    "DEFAULT_OUTPUT_BYTES",
+    "DEFAULT_OUTPUT_LINES",
+    "OutputLine",
+    "OutputRing",
+    "TargetOutputTee",
+    "resumes_target",
+    "tee_target_output",
+]
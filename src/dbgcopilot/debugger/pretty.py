@@ -0,0 +1,274 @@
+"""Render debugger values as indented Go-like syntax for prompts and the REPL.
+
+``pretty_print`` lays out a structured ``Variable`` tree (from Delve's
+JSON-RPC API, see ``variable_from_rpc``): one field or element per line,
+truncation shown as ``...+N more``, channels and funcs as readable
+placeholders instead of blanks. Pointers already on the path being printed
+are shown as back-references, so cyclic lists and graphs terminate.
+``format_value_text`` re-indents the one-line values the Delve CLI prints
+when only text is available.
+"""
+from __future__ import annotations
+
+from typing import Any, Dict, List, Optional, Set, Tuple
+import json
+import re
+
+from .base import Variable
+
+# reflect.Kind values used by Delve's api.Variable.
+_KINDS = [
+    "invalid", "bool", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32",
+    "uint64", "uintptr", "float32", "float64", "complex64", "complex128", "array", "chan", "func",
+    "interface", "map", "ptr", "slice", "string", "struct", "unsafe-pointer",
+]
+# Delve's already-truncated loads are finite, but text from other sources is not; never nest deeper.
+MAX_PRINT_DEPTH = 32
+# Groups shorter than this stay on one line.
+INLINE_WIDTH = 60
+
+_OPEN = {"{": "}", "[": "]", "(": ")"}
+_LEN_CAP_RE = re.compile(r"(?:^|\s)(?:len|cap): \d+$")
+
+
+def variable_from_rpc(var: Dict[str, Any]) -> Variable:
+    """Convert a Delve v2 ``api.Variable`` JSON object (and its children) to a ``Variable``."""
+    kind = var.get("kind")
+    if isinstance(kind, int):
+        kind = _KINDS[kind] if 0 <= kind < len(_KINDS) else ""
+    return Variable(
+        name=str(var.get("name") or ""),
+        value=str(var.get("value") or ""),
+        type=str(var.get("type") or ""),
+        kind=str(kind or ""),
+        address=int(var.get("addr") or 0),
+        length=int(var.get("len", -1) if var.get("len") is not None else -1),
+        cap=int(var.get("cap", -1) if var.get("cap") is not None else -1),
+        children=[variable_from_rpc(c) for c in var.get("children") or []],
+        unreadable=str(var.get("unreadable") or ""),
+    )
+
+
+def pretty_print(var: Variable, *, indent: str = "  ") -> str:
+    """Indented Go-like rendering of ``var``; text-only values are re-indented."""
+    if not var.kind and not var.children:
+        return format_value_text(var.value, indent=indent)
+    return _Printer(indent).render(var, 0, set())
+
+
+def _more(loaded: int, total: int) -> str:
+    return f"...+{total - loaded} more" if total > loaded else ""
+
+
+class _Printer:
+    def __init__(self, indent: str) -> None:
+        self.indent = indent
+
+    def render(self, var: Variable, level: int, path: Set[Tuple[int, str]]) -> str:
+        if var.unreadable:
+            return f"<unreadable: {var.unreadable}>"
+        if level >= MAX_PRINT_DEPTH:
+            return f"{var.type} {{...}}".strip()
+        kind = var.kind
+        if kind == "string":
+            text = json.dumps(var.value, ensure_ascii=False)
+            more = _more(len(var.value), var.length)
+            return f"{text}{more}"
+        if kind == "ptr":
+            return self._pointer(var, level, path)
+        if kind == "interface":
+            return self._interface(var, level, path)
+        if kind == "chan":
+            return self._chan(var)
+        if kind == "func":
+            return f"func {var.value}" if var.value else f"{var.type or 'func'} nil"
+        if kind == "unsafe-pointer":
+            return f"unsafe.Pointer({var.value or hex(var.address)})"
+        if kind == "struct":
+            return self._struct(var, level, path)
+        if kind in {"slice", "array"}:
+            return self._list(var, level, path)
+        if kind == "map":
+            return self._map(var, level, path)
+        if var.children:
+            return self._struct(var, level, path)
+        return var.value or ("nil" if var.address == 0 else hex(var.address))
+
+    def _block(self, head: str, items: List[str], level: int, open_: str, close: str) -> str:
+        if not items:
+            return f"{head}{open_}{close}"
+        inline = f"{head}{open_}{', '.join(items)}{close}"
+        if len(inline) <= INLINE_WIDTH and "\n" not in inline:
+            return inline
+        pad = self.indent * (level + 1)
+        body = "".join(f"{pad}{item},\n" for item in items)
+        return f"{head}{open_}\n{body}{self.indent * level}{close}"
+
+    def _struct(self, var: Variable, level: int, path: Set[Tuple[int, str]]) -> str:
+        key = (var.address, var.type)
+        if var.address and key in path:
+            return f"{var.type}(0x{var.address:x}) <cycle>"
+        inner = path | {key} if var.address else path
+        items = [f"{c.name}: {self.render(c, level + 1, inner)}" for c in var.children]
+        head = f"{var.type} " if var.type else ""
+        return self._block(head, items, level, "{", "}")
+
+    def _list(self, var: Variable, level: int, path: Set[Tuple[int, str]]) -> str:
+        items = [self.render(c, level + 1, path) for c in var.children]
+        more = _more(len(var.children), var.length)
+        if more:
+            items.append(more)
+        sizes = f"len: {var.length}" if var.length >= 0 else ""
+        if var.kind == "slice" and var.cap >= 0:
+            sizes += f", cap: {var.cap}"
+        if var.kind == "slice" and var.address == 0 and var.length <= 0 and not var.children:
+            return f"{var.type} nil"
+        head = " ".join(part for part in (var.type, sizes) if part) + " " if var.type else ""
+        return self._block(head, items, level, "[", "]")
+
+    def _map(self, var: Variable, level: int, path: Set[Tuple[int, str]]) -> str:
+        if var.address == 0 and var.length <= 0 and not var.children:
+            return f"{var.type} nil"
+        pairs = list(zip(var.children[0::2], var.children[1::2]))
+        items = [f"{self.render(k, level + 1, path)}: {self.render(v, level + 1, path)}" for k, v in pairs]
+        more = _more(len(pairs), var.length)
+        if more:
+            items.append(more)
+        return self._block(f"{var.type} " if var.type else "", items, level, "[", "]")
+
+    def _pointer(self, var: Variable, level: int, path: Set[Tuple[int, str]]) -> str:
+        target = var.children[0] if var.children else None
+        if target is None or (target.address == 0 and not target.children and not target.value):
+            return f"{var.type} nil" if var.type else "nil"
+        key = (target.address, target.type)
+        if target.address and key in path:
+            return f"({var.type})(0x{target.address:x}) <cycle>"
+        if not target.children and target.kind in {"struct", "slice", "array", "map"}:
+            # Not loaded: MaxVariableRecurse was reached.
+            return f"({var.type})(0x{target.address:x})"
+        return "&" + self.render(target, level, path)
+
+    def _interface(self, var: Variable, level: int, path: Set[Tuple[int, str]]) -> str:
+        data = var.children[0] if var.children else None
+        if data is None or (not data.type and data.address == 0 and not data.value and not data.children):
+            return f"{var.type or 'interface {}'} nil"
+        return f"{var.type}({data.type}) {self.render(data, level, path)}"
+
+    def _chan(self, var: Variable) -> str:
+        fields = {c.name: c.value for c in var.children}
+        qcount = var.length if var.length >= 0 else _int(fields.get("qcount"))
+        size = var.cap if var.cap >= 0 else _int(fields.get("dataqsiz"))
+        if var.address == 0 and not var.children and qcount is None:
+            return f"{var.type or 'chan'} nil"
+        closed = " closed" if fields.get("closed") not in (None, "", "0") else ""
+        sizes = f" (len {qcount}, cap {size})" if qcount is not None and size is not None else ""
+        addr = f" 0x{var.address:x}" if var.address else ""
+        return f"{var.type or 'chan'}{sizes}{closed}{addr}"
+
+
+def _int(value: Optional[str]) -> Optional[int]:
+    try:
+        return int(value) if value not in (None, "") else None
+    except ValueError:
+        return None
+
+
+# ----------------------------------------------------------------------
+# Text values from the Delve CLI, e.g. 'main.T {A: 1, B: []int len: 3, cap: 3, [1,2,3]}'.
+
+
+def format_value_text(text: str, *, indent: str = "  ") -> str:
+    """Re-indent a one-line debugger value; short groups and already multi-line text are kept."""
+    value = (text or "").strip()
+    if "\n" in value or len(value) <= INLINE_WIDTH:
+        return value
+    return _render_text_item(value, 0, indent)
+
+
+def _scan_groups(text: str) -> List[Tuple[int, int]]:
+    """Top-level (start, end) spans of bracketed groups, skipping quoted strings."""
+    groups: List[Tuple[int, int]] = []
+    stack: List[str] = []
+    start = 0
+    i = 0
+    while i < len(text):
+        ch = text[i]
+        if ch in "\"`":
+            i = _skip_quoted(text, i)
+            continue
+        if ch in _OPEN:
+            if not stack:
+                start = i
+            stack.append(_OPEN[ch])
+        elif stack and ch == stack[-1]:
+            stack.pop()
+            if not stack:
+                groups.append((start, i + 1))
+        i += 1
+    return groups
+
+
+def _skip_quoted(text: str, i: int) -> int:
+    quote = text[i]
+    i += 1
+    while i < len(text) and text[i] != quote:
+        i += 2 if quote == '"' and text[i] == "\\" else 1
+    return i + 1
+
+
+def _split_items(body: str) -> List[str]:
+    items: List[str] = []
+    depth = 0
+    current: List[str] = []
+    i = 0
+    while i < len(body):
+        ch = body[i]
+        if ch in "\"`":
+            end = _skip_quoted(body, i)
+            current.append(body[i:end])
+            i = end
+            continue
+        if ch in _OPEN:
+            depth += 1
+        elif ch in _OPEN.values():
+            depth -= 1
+        if ch == "," and depth == 0:
+            items.append("".join(current).strip())
+            current = []
+        else:
+            current.append(ch)
+        i += 1
+    items.append("".join(current).strip())
+    merged: List[str] = []
+    for item in items:
+        if not item:
+            continue
+        # "len: 3, cap: 3, [1,2,3]" describes one slice value.
+        if merged and _LEN_CAP_RE.search(merged[-1]):
+            merged[-1] += ", " + item
+        else:
+            merged.append(item)
+    return merged
+
+
+def _render_text_item(item: str, level: int, indent: str) -> str:
+    if level >= MAX_PRINT_DEPTH:
+        return item
+    out: List[str] = []
+    cursor = 0
+    for start, end in _scan_groups(item):
+        out.append(item[cursor:start])
+        group = item[start:end]
+        if len(group) <= INLINE_WIDTH:
+            out.append(group)
+        else:
+            inner = _split_items(group[1:-1])
+            pad = indent * (level + 1)
+            body = "".join(f"{pad}{_render_text_item(x, level + 1, indent)},\n" for x in inner)
+            out.append(f"{group[0]}\n{body}{indent * level}{group[-1]}")
+        cursor = end
+    out.append(item[cursor:])
+    return "".join(out)
+
+
+__all__ = ["INLINE_WIDTH", "MAX_PRINT_DEPTH", "format_value_text", "pretty_print", "variable_from_rpc"]
@@ -0,0 +1,81 @@
+"""Pre-flight checks for attaching a debugger to a running process on Linux.
+
+Attaching needs ptrace rights over the target. Yama's ``ptrace_scope``
+usually restricts that to descendants of the tracer, so a service started
+elsewhere can only be attached as root (or with CAP_SYS_PTRACE) unless the
+setting is relaxed. These helpers turn the kernel's terse "operation not
+permitted" into something actionable.
+"""
+from __future__ import annotations
+
+from pathlib import Path
+from typing import Optional
+import os
+
+from .base import DebuggerError
+
+
+YAMA_PTRACE_SCOPE = "/proc/sys/kernel/yama/ptrace_scope"
+
+_SCOPE_MEANING = {
+    0: "classic ptrace permissions",
+    1: "restricted: only descendants of the debugger may be traced",
+    2: "admin-only: CAP_SYS_PTRACE is required",
+    3: "disabled: no process may be traced until reboot",
+}
+_PERMISSION_MARKERS = ("operation not permitted", "permission denied", "eperm", "access denied")
+
+
+class PtracePermissionError(DebuggerError):
+    """The kernel refused to let the debugger trace the target process."""
+
+
+def ptrace_scope(path: str = YAMA_PTRACE_SCOPE) -> Optional[int]:
+    """Current Yama ptrace_scope, or None when Yama is not enabled."""
+    try:
+        return int(Path(path).read_text().strip())
+    except (OSError, ValueError):
+        return None
+
+
+def is_permission_error(text: str) -> bool:
+    lower = (text or "").lower()
+    return any(marker in lower for marker in _PERMISSION_MARKERS)
+
+
+def permission_message(pid: int, detail: str = "", scope: Optional[int] = None) -> str:
+    lines = [f"Not allowed to attach to process {pid}" + (f": {detail.strip()}" if detail.strip() else ".")]
+    if scope is not None:
+        lines.append(f"{YAMA_PTRACE_SCOPE} is {scope} ({_SCOPE_MEANING.get(scope, 'unknown setting')}).")
+    if scope == 3:
+        lines.append("Attaching is impossible until the machine is rebooted with a lower ptrace_scope.")
+    else:
+        lines.append(
+            "Run the copilot as root (or grant CAP_SYS_PTRACE to dlv), or relax the restriction with "
+            f"`echo 0 | sudo tee {YAMA_PTRACE_SCOPE}`."
+        )
+    return "\n".join(lines)
+
+
+def check_attach(pid: int) -> None:
+    """Raise before spawning the debugger when attaching to ``pid`` cannot work."""
+    if pid <= 0:
+        raise DebuggerError(f"Invalid PID {pid}")
+    if not Path(f"/proc/{pid}").exists() and Path("/proc/self").exists():
+        raise DebuggerError(f"No process with PID {pid}")
+    if pid == os.getpid():
+        raise DebuggerError("Refusing to attach to the copilot's own process")
+    scope = ptrace_scope()
+    # Lower settings depend on the tracer's privileges (dlv may carry CAP_SYS_PTRACE), so let it try.
+    if scope == 3:
+        raise PtracePermissionError(permission_message(pid, scope=scope))
+
+
+__all__ = [
+    "PtracePermissionError",
+    "YAMA_PTRACE_SCOPE",
+    "check_attach",
+    "is_permission_error",
+    "permission_message",
+    "ptrace_scope",
+]
@@ -0,0 +1,782 @@
+"""Structured pdb driver for Python scripts.
+
+Wraps the pexpect-based ``PythonPdbBackend`` (``python -m pdb script``) and
+exposes the shared ``Debugger`` interface: Python threads are reported
+through ``goroutines()`` and pdb's ``where`` / thread stacks become
+``Frame`` lists. Thread stacks and values are read by a small probe
+installed once in the target's ``builtins`` (``__dbgcopilot__``), so the
+script's own namespaces are never modified.
+
+``continue_`` tells an uncaught exception (``STOP_EXCEPTION``, after which
+the session is post-mortem) apart from a clean exit (status 0) or a
+``sys.exit(n)``; callers use ``StopEvent.clean_exit`` to skip diagnosis
+when nothing went wrong. A ``continue`` that makes no progress within
+``continue_timeout`` is interrupted with SIGINT; when pdb cannot break in
+(the main thread is blocked in a lock or ``join``), the thread stacks come
+from a ``faulthandler`` dump instead so a deadlocked script can still be
+inspected.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Any, Dict, List, Mapping, Optional, Sequence, Union
+import json
+import linecache
+import os
+import re
+import shutil
+import signal
+import sys
+
+from dbgcopilot.analyze.goroutines import Frame, Goroutine, GoroutineDump
+from dbgcopilot.backends.python_pdb import PythonPdbBackend, pexpect
+
+from .base import (
+    STOP_BREAKPOINT,
+    STOP_EXCEPTION,
+    STOP_EXITED,
+    STOP_STOPPED,
+    STEP_INTO,
+    STEP_OUT,
+    STEP_OVER,
+    WATCH_WRITE,
+    Breakpoint,
+    BreakpointSpec,
+    DebuggerError,
+    DebuggerUnavailable,
+    LoadConfig,
+    PostMortemError,
+    ProcessExitedError,
+    StopEvent,
+    Variable,
+    Watchpoint,
+    as_spec,
+    finish_step,
+)
+
+
+# Frames from these files (in the interpreter's stdlib) belong to pdb itself, not the script.
+_DEBUGGER_FILES = {"bdb.py", "pdb.py", "cmd.py", "runpy.py"}
+# Stdlib and site-packages frames are attributed to libpython so ``Frame.is_runtime`` skips them.
+RUNTIME_MODULE = "libpython"
+DEFAULT_CONTINUE_TIMEOUT = 60.0
+# How long pdb gets to reach its prompt after SIGINT before the script is treated as hung.
+INTERRUPT_TIMEOUT = 3.0
+VENV_DIRS = (".venv", "venv", "env")
+
+_WHERE_RE = re.compile(r"^(?P<cur>[> ])\s*(?P<file>.+?)\((?P<line>\d+)\)(?P<func>[^()\s]+)\(\)\s*$")
+_CURRENT_RE = re.compile(r"^> (?P<file>.+?)\((?P<line>\d+)\)(?P<func>[^()\s]+)\(\)\s*$", re.MULTILINE)
+_UNCAUGHT = "Uncaught exception. Entering post mortem debugging"
+_EXCEPTION_LINE_RE = re.compile(r"^(?P<type>[A-Za-z_][\w.]*)(?::\s*(?P<msg>.*))?$")
+_SYS_EXIT_RE = re.compile(r"The program exited via sys\.exit\(\)\. Exit status: ?(?P<status>.*)$", re.MULTILINE)
+_FINISHED = "The program finished and will be restarted"
+_INTERRUPTED = "Program interrupted"
+_BREAK_SET_RE = re.compile(r"^Breakpoint (\d+) at (.+):(\d+)\s*$", re.MULTILINE)
+_BREAK_LIST_RE = re.compile(r"^(\d+)\s+breakpoint\s+\w+\s+(yes|no)\s+at\s+(.+):(\d+)\s*$")
+_BREAK_COND_RE = re.compile(r"^\s*stop only if (.*)$")
+_BREAK_HITS_RE = re.compile(r"breakpoint already hit (\d+) times?")
+_PDB_ERROR_RE = re.compile(r"^\*\*\* (.*)$", re.MULTILINE)
+_FAULT_THREAD_RE = re.compile(r"^(?:Current thread|Thread) (0x[0-9a-fA-F]+) \(most recent call first\):$")
+_FAULT_FRAME_RE = re.compile(r'^\s+File "(.+)", line (\d+) in (\S+)\s*$')
+# ``python -m pdb`` with SIGUSR1 wired to faulthandler, which dumps every thread even when none can run Python.
+_PDB_LAUNCH = (
+    ["-c", "import faulthandler, signal, pdb; faulthandler.register(signal.SIGUSR1, all_threads=True); pdb.main()"]
+    if hasattr(signal, "SIGUSR1")
+    else ["-m", "pdb"]
+)
+# Leading words of pdb commands that resume (and, after an uncaught exception, restart) the script.
+_RESUME_WORDS = {
+    "c", "cont", "continue", "s", "step", "n", "next", "r", "return", "unt", "until", "j", "jump", "run", "restart",
+}
+
+# Installed as builtins.__dbgcopilot__; kept free of top-level names so nothing leaks into the script.
+_PROBE_SOURCE = '''
+import builtins, json, linecache, os, reprlib, sys, sysconfig, threading, traceback
+
+def _runtime_dirs():
+    paths = sysconfig.get_paths()
+    return [os.path.realpath(p) for p in {paths.get("stdlib"), paths.get("purelib"), paths.get("platlib")} if p]
+
+def threads():
+    frames = sys._current_frames()
+    out = []
+    for t in threading.enumerate():
+        stack = []
+        top = frames.get(t.ident)
+        if top is not None:
+            for f, line in traceback.walk_stack(top):
+                code = f.f_code
+                stack.append([code.co_filename, line, code.co_name, linecache.getline(code.co_filename, line).strip()])
+        out.append({"id": t.native_id or t.ident, "ident": t.ident, "name": t.name, "daemon": t.daemon,
+                    "main": t is threading.main_thread(), "frames": stack})
+    return json.dumps({"threads": out, "runtime": _runtime_dirs()})
+
+def _value(v, depth, items, strlen):
+    r = reprlib.Repr()
+    r.maxlevel = depth + 1
+    r.maxlist = r.maxtuple = r.maxdict = r.maxset = r.maxfrozenset = r.maxdeque = r.maxarray = items
+    r.maxstring = r.maxlong = r.maxother = strlen
+    t = type(v)
+    name = t.__qualname__ if t.__module__ == "builtins" else t.__module__ + "." + t.__qualname__
+    try:
+        size = len(v)
+    except Exception:
+        size = -1
+    return {"type": name, "repr": r.repr(v), "len": size}
+
+def value(v, depth, items, strlen):
+    return json.dumps(_value(v, depth, items, strlen))
+
+def _frame(tid, index, skip):
+    if tid is None:
+        thread = threading.current_thread()
+    else:
+        thread = next((t for t in threading.enumerate() if (t.native_id or t.ident) == tid), None)
+    top = sys._current_frames().get(thread.ident) if thread is not None else None
+    if top is None:
+        return None, "no Python thread with id %s" % tid
+    runtime = _runtime_dirs()
+    frames = []
+    for f, _ in traceback.walk_stack(top):
+        path = f.f_code.co_filename
+        real = os.path.realpath(path)
+        if (path.startswith("<") and path.endswith(">")) or (
+                os.path.basename(path) in skip and any(real.startswith(d + os.sep) for d in runtime)):
+            continue
+        frames.append(f)
+    if not 0 <= index < len(frames):
+        return None, "frame %d is out of range (thread has %d frames)" % (index, len(frames))
+    return frames[index], ""
+
+def frame_eval(tid, index, expr, skip, depth, items, strlen):
+    f, error = _frame(tid, index, skip)
+    if f is None:
+        return json.dumps({"error": error})
+    try:
+        v = eval(expr, f.f_globals, f.f_locals)
+    except Exception as e:
+        return json.dumps({"error": "%s: %s" % (type(e).__name__, e)})
+    return json.dumps(_value(v, depth, items, strlen))
+
+def frame_locals(tid, index, skip, depth, items, strlen):
+    f, error = _frame(tid, index, skip)
+    if f is None:
+        return json.dumps({"error": error})
+    return json.dumps({"locals": [dict(_value(v, depth, items, strlen), name=k) for k, v in f.f_locals.items()]})
+
+class _Probe:
+    pass
+
+probe = _Probe()
+probe.threads = threads
+probe.value = value
+probe.frame_eval = frame_eval
+probe.frame_locals = frame_locals
+probe.runtime = lambda: json.dumps({"runtime": _runtime_dirs()})
+builtins.__dbgcopilot__ = probe
+'''
+
+
+# ----------------------------------------------------------------------
+# Interpreter selection.
+
+
+def _new_env() -> Dict[str, str]:
+    return {}
+
+
+@dataclass
+class Interpreter:
+    path: str
+    # How it was chosen: "explicit", "venv <dir>", "VIRTUAL_ENV", "shebang" or "current".
+    source: str
+    env: Dict[str, str] = field(default_factory=_new_env)
+
+
+def _venv_python(venv: Path) -> Optional[Path]:
+    for rel in ("bin/python", "bin/python3", "Scripts/python.exe"):
+        candidate = venv / rel
+        if candidate.is_file():
+            return candidate
+    return None
+
+
+def _venv_env(venv: Path, python: Path, environ: Mapping[str, str]) -> Dict[str, str]:
+    # What `activate` would set, so subprocesses the script spawns use the same environment.
+    return {"VIRTUAL_ENV": str(venv), "PATH": os.pathsep.join([str(python.parent), environ.get("PATH", "")])}
+
+
+def _shebang_python(program: Path) -> Optional[str]:
+    try:
+        with open(program, "rb") as fh:
+            first = fh.readline(256).decode("utf-8", "replace").strip()
+    except OSError:
+        return None
+    if not first.startswith("#!") or "python" not in first:
+        return None
+    words = first[2:].split()
+    if not words:
+        return None
+    target = words[1] if Path(words[0]).name == "env" and len(words) > 1 else words[0]
+    if Path(target).is_file():
+        return target
+    return shutil.which(target)
+
+
+def resolve_interpreter(
+    program: str,
+    python: Optional[str] = None,
+    *,
+    environ: Optional[Mapping[str, str]] = None,
+) -> Interpreter:
+    """Pick the interpreter for ``program``.
+
+    In order: ``python`` (an executable, a name on PATH or a virtualenv
+    directory), ``$VIRTUAL_ENV``, a ``.venv``/``venv``/``env`` next to the
+    script or in a parent directory, the script's ``#!`` line, and finally
+    the interpreter running dbgcopilot.
+    """
+    env = os.environ if environ is None else environ
+    if python:
+        candidate = Path(python).expanduser()
+        if candidate.is_dir():
+            found = _venv_python(candidate)
+            if found is None:
+                raise DebuggerUnavailable(f"'{python}' is a directory but not a virtualenv (no bin/python inside)")
+            return Interpreter(str(found), f"venv {candidate}", _venv_env(candidate, found, env))
+        resolved = str(candidate) if candidate.is_file() else shutil.which(python)
+        if not resolved:
+            raise DebuggerUnavailable(f"Python interpreter '{python}' not found")
+        return Interpreter(resolved, "explicit")
+    active = env.get("VIRTUAL_ENV")
+    if active:
+        found = _venv_python(Path(active))
+        if found is not None:
+            return Interpreter(str(found), "VIRTUAL_ENV")
+    script = Path(program).expanduser().resolve()
+    for directory in script.parents:
+        for name in VENV_DIRS:
+            venv = directory / name
+            found = _venv_python(venv) if (venv / "pyvenv.cfg").is_file() else None
+            if found is not None:
+                return Interpreter(str(found), f"venv {venv}", _venv_env(venv, found, env))
+    shebang = _shebang_python(script)
+    if shebang:
+        return Interpreter(shebang, "shebang")
+    return Interpreter(sys.executable, "current")
+
+
+# ----------------------------------------------------------------------
+# Output parsing.
+
+
+def _is_debugger_frame(path: str, runtime: Sequence[str]) -> bool:
+    if path.startswith("<") and path.endswith(">"):
+        return True
+    return Path(path).name in _DEBUGGER_FILES and _under(path, runtime)
+
+
+def _under(path: str, dirs: Sequence[str]) -> bool:
+    real = os.path.realpath(path)
+    return any(real == d or real.startswith(d.rstrip(os.sep) + os.sep) for d in dirs)
+
+
+def _frame(path: str, line: int, func: str, runtime: Sequence[str]) -> Frame:
+    module = RUNTIME_MODULE if runtime and _under(path, runtime) else Path(path).stem
+    return Frame(function=func, file=path, line=line, module=module)
+
+
+def parse_where(text: str, runtime: Sequence[str] = ()) -> List[Frame]:
+    """Frames from pdb's ``where``, innermost first, without pdb's own frames."""
+    frames: List[Frame] = []
+    for line in (text or "").replace("\r\n", "\n").split("\n"):
+        m = _WHERE_RE.match(line)
+        if not m or _is_debugger_frame(m.group("file"), runtime):
+            continue
+        frames.append(_frame(m.group("file"), int(m.group("line")), m.group("func"), runtime))
+    frames.reverse()
+    return frames
+
+
+def _exit_status(raw: str) -> int:
+    # sys.exit(None) is 0, an int is itself, and any other object prints and exits 1.
+    raw = raw.strip()
+    if raw in ("", "None"):
+        return 0
+    try:
+        return int(raw)
+    except ValueError:
+        return 1
+
+
+def _exception_summary(text: str) -> str:
+    head = text.split(_UNCAUGHT, 1)[0].rstrip().split("\n")
+    for line in reversed(head):
+        m = _EXCEPTION_LINE_RE.match(line.strip())
+        if m and not line.startswith(" "):
+            return line.strip()
+    return "uncaught exception"
+
+
+def parse_stop(output: str, breakpoints: Sequence[Breakpoint] = (), runtime: Sequence[str] = ()) -> StopEvent:
+    """Classify pdb's output after ``continue``: exception, exit, interrupt or a stop at a line."""
+    text = (output or "").replace("\r\n", "\n")
+    current = _CURRENT_RE.search(text.split(_UNCAUGHT, 1)[-1])
+    frame = None
+    if current:
+        frame = _frame(current.group("file"), int(current.group("line")), current.group("func"), runtime)
+    if _UNCAUGHT in text:
+        return StopEvent(reason=STOP_EXCEPTION, frame=frame, detail=_exception_summary(text), raw=text)
+    m = _SYS_EXIT_RE.search(text)
+    if m:
+        return StopEvent(reason=STOP_EXITED, exit_code=_exit_status(m.group("status")), raw=text)
+    if _FINISHED in text:
+        return StopEvent(reason=STOP_EXITED, exit_code=0, raw=text)
+    if _INTERRUPTED in text:
+        return StopEvent(reason=STOP_STOPPED, frame=frame, detail="interrupted", raw=text)
+    event = StopEvent(reason=STOP_STOPPED, frame=frame, raw=text)
+    if frame is not None:
+        for bp in breakpoints:
+            if bp.line == frame.line and os.path.realpath(bp.file) == os.path.realpath(frame.file):
+                event.reason = STOP_BREAKPOINT
+                event.breakpoint_id = bp.id
+                break
+    return event
+
+
+def _thread_state(frames: List[Frame], sources: List[str]) -> str:
+    """Best guess at what a thread waits on, from its innermost frames and their source lines."""
+    if not frames:
+        return "not started"
+    for frame, source in zip(frames, sources):
+        if frame.module == RUNTIME_MODULE:
+            if frame.function == "_wait_for_tstate_lock":
+                return "join"
+            if Path(frame.file).name == "queue.py" and frame.function in {"get", "put"}:
+                return "queue wait"
+            continue
+        if re.search(r"\bsleep\(", source):
+            return "sleep"
+        # Blocked on a `with` line means entering the context manager: almost always a lock.
+        if re.search(r"\.acquire\(|^with\b", source):
+            return "lock wait"
+        if re.search(r"\.join\(", source):
+            return "join"
+        return "running"
+    return "running"
+
+
+@dataclass
+class _Thread:
+    id: int
+    name: str
+    # (file, line, function, source) innermost first, pdb's own frames included.
+    frames: List[Sequence[Any]]
+    main: bool = False
+    daemon: bool = False
+    # Replaces the frames and guessed state, e.g. pdb's view of the thread it is stopped in.
+    parsed: Optional[List[Frame]] = None
+    state: str = ""
+
+
+def _build_dump(threads: List[_Thread], runtime: Sequence[str]) -> GoroutineDump:
+    dump = GoroutineDump()
+    lines: List[str] = []
+    for t in threads:
+        user = [f for f in t.frames if not _is_debugger_frame(str(f[0]), runtime)]
+        frames = t.parsed if t.parsed is not None else [_frame(str(f[0]), int(f[1]), str(f[2]), runtime) for f in user]
+        sources = [str(f[3]) if len(f) > 3 else linecache.getline(str(f[0]), int(f[1])).strip() for f in user]
+        state = t.state or _thread_state(frames, sources)
+        dump.goroutines.append(Goroutine(id=t.id, state=state, frames=frames, current=t.main))
+        lines.append(f"Thread {t.id}{f' {t.name!r}' if t.name else ''}{' (daemon)' if t.daemon else ''} [{state}]:")
+        lines.extend(f"  {f.function} at {f.location}" for f in frames)
+    dump.raw = "\n".join(lines)
+    return dump
+
+
+def threads_from_probe(
+    payload: Dict[str, Any], main_frames: Optional[List[Frame]] = None, main_state: str = "stopped"
+) -> GoroutineDump:
+    """``GoroutineDump`` from the probe's JSON; the main thread (where pdb runs) is marked current."""
+    threads = [
+        _Thread(
+            id=int(t.get("id") or 0),
+            name=str(t.get("name") or ""),
+            frames=list(t.get("frames") or []),
+            main=bool(t.get("main")),
+            daemon=bool(t.get("daemon")),
+        )
+        for t in payload.get("threads") or []
+    ]
+    for t in threads:
+        if t.main:
+            t.parsed, t.state = main_frames, main_state
+    return _build_dump(threads, list(payload.get("runtime") or []))
+
+
+def parse_faulthandler(text: str, runtime: Sequence[str] = ()) -> GoroutineDump:
+    """Thread stacks from a ``faulthandler`` dump; the thread running pdb's loop is marked current."""
+    threads: List[_Thread] = []
+    for line in (text or "").replace("\r\n", "\n").split("\n"):
+        m = _FAULT_THREAD_RE.match(line.strip())
+        if m:
+            threads.append(_Thread(id=int(m.group(1), 16), name="", frames=[]))
+            continue
+        m = _FAULT_FRAME_RE.match(line)
+        if m and threads:
+            threads[-1].frames.append((m.group(1), int(m.group(2)), m.group(3)))
+    for t in threads:
+        # pdb could not break in, so the main thread's state is guessed like the others'.
+        t.main = any(Path(str(f[0])).name == "pdb.py" for f in t.frames)
+    return _build_dump(threads, runtime)
+
+
+# ----------------------------------------------------------------------
+
+
+class PythonDebugger(PythonPdbBackend):
+    """pdb session exposing the structured ``Debugger`` interface."""
+
+    GOROUTINES_COMMAND = "threads"
+
+    def __init__(
+        self,
+        program: str,
+        *,
+        python: Optional[str] = None,
+        args: Sequence[str] = (),
+        env: Optional[Mapping[str, str]] = None,
+        cwd: Optional[str] = None,
+        timeout: float = 10.0,
+        continue_timeout: float = DEFAULT_CONTINUE_TIMEOUT,
+    ) -> None:
+        if not program:
+            raise ValueError("Python debugger requires a script path")
+        super().__init__(program=program, cwd=cwd, timeout=timeout)
+        self.python = python
+        self.args = list(args)
+        # The script's environment before the interpreter's own variables; None means ours.
+        self.env = dict(env) if env is not None else None
+        self.continue_timeout = continue_timeout
+        self.interpreter: Optional[Interpreter] = None
+        self._breakpoints: Dict[int, Breakpoint] = {}
+        self._runtime: List[str] = []
+        self._probe_ready = False
+        # Set once an uncaught exception stopped the script; pdb would restart it on continue.
+        self.exception: Optional[StopEvent] = None
+        self.exit_event: Optional[StopEvent] = None
+        # Thread stacks from faulthandler when the script hung where pdb cannot break in.
+        self.hung: Optional[GoroutineDump] = None
+
+    @property
+    def post_mortem(self) -> bool:
+        return self.exception is not None
+
+    def initialize_session(self) -> None:
+        if pexpect is None:
+            raise DebuggerUnavailable("pexpect is required to drive pdb")
+        script = Path(self.program or "").expanduser()
+        if not script.is_file():
+            raise DebuggerError(f"Python script '{self.program}' not found")
+        self.interpreter = resolve_interpreter(str(script), self.python)
+        self.python_path = self.interpreter.path
+        env = dict(os.environ if self.env is None else self.env)
+        env.setdefault("PYTHONUNBUFFERED", "1")
+        env.update(self.interpreter.env)
+        try:
+            self.child = pexpect.spawn(
+                self.python_path,
+                [*_PDB_LAUNCH, str(script), *self.args],
+                encoding="utf-8",
+                timeout=self.timeout,
+                env=env,  # type: ignore[arg-type]
+                cwd=self.cwd or str(script.resolve().parent),
+            )
+        except Exception as e:
+            raise DebuggerUnavailable(f"Failed to start {self.python_path} -m pdb: {e}") from e
+        startup = self._expect_initial_prompt()
+        if self.child is None or not self.child.isalive():
+            raise DebuggerError(f"pdb exited before the script started: {startup}")
+        self.startup_output = startup
+        self._runtime_dirs()
+
+    @property
+    def session_active(self) -> bool:
+        return self.child is not None and self.child.isalive()
+
+    def run_command(self, cmd: str, timeout: float | None = None) -> str:
+        command = (cmd or "").strip()
+        if command == self.GOROUTINES_COMMAND:
+            return self.goroutines().raw or "(no threads)"
+        words = command.split()
+        resumes = bool(words) and words[0].lower() in _RESUME_WORDS
+        if self.hung is not None or (resumes and (self.exception or self.exit_event)):
+            return f"[{self.name}] {self._finished_message(words[0])}"
+        return super().run_command(cmd, timeout=timeout)
+
+    def _finished_message(self, command: str) -> str:
+        if self.hung is not None:
+            return (
+                f"'{command}' is unavailable: the script is blocked where pdb cannot break in "
+                "(the main thread waits in native code). Use the thread stacks captured when it hung."
+            )
+        if self.exception is not None:
+            return (
+                f"'{command}' is unavailable: the script stopped on an uncaught exception "
+                f"({self.exception.detail}) and pdb would restart it. Inspect the post-mortem state instead "
+                "(where, up/down, printing variables, threads)."
+            )
+        code = self.exit_event.exit_code if self.exit_event is not None else None
+        return f"'{command}' is unavailable: the script already exited with status {code}."
+
+    def _checked(self, cmd: str, timeout: float | None = None) -> str:
+        if self.hung is not None:
+            raise DebuggerError(self._finished_message(cmd.split()[0] if cmd.split() else cmd))
+        if not self.session_active:
+            raise DebuggerError("pdb session is not running")
+        out = self._send_and_capture(cmd, timeout=timeout)
+        m = _PDB_ERROR_RE.search(out)
+        if m:
+            raise DebuggerError(m.group(1).strip())
+        return out
+
+    def _probe(self, call: str) -> Any:
+        if not self._probe_ready:
+            self._checked(f"!exec({_PROBE_SOURCE!r}, {{'__name__': '__dbgcopilot__'}})")
+            self._probe_ready = True
+        out = self._checked(f"!print(__dbgcopilot__.{call})")
+        line = next((ln for ln in reversed(out.splitlines()) if ln.startswith("{")), "")
+        try:
+            return json.loads(line)
+        except ValueError:
+            raise DebuggerError(f"Unexpected probe output: {out.strip()[:200]}") from None
+
+    def _runtime_dirs(self) -> List[str]:
+        if not self._runtime:
+            self._runtime = list(self._probe("runtime()").get("runtime") or [])
+        return self._runtime
+
+    # ------------------------------------------------------------------
+    def set_breakpoint(self, spec: Union[str, BreakpointSpec]) -> Breakpoint:
+        """Set a breakpoint at ``file:line`` or a function; conditions are Python expressions."""
+        spec = as_spec(spec)
+        cmd = f"break {spec.location}" + (f", {spec.condition}" if spec.condition else "")
+        out = self._checked(cmd)
+        m = _BREAK_SET_RE.search(out)
+        if not m:
+            raise DebuggerError(f"Unexpected pdb breakpoint output: {out.strip()}")
+        bp = Breakpoint(
+            id=int(m.group(1)),
+            location=spec.location,
+            file=m.group(2),
+            line=int(m.group(3)),
+            condition=spec.condition,
+            hit_count=spec.hit_count,
+        )
+        if spec.hit_count > 1:
+            self._checked(f"ignore {bp.id} {spec.hit_count - 1}")
+        self._breakpoints[bp.id] = bp
+        return bp
+
+    def breakpoints(self) -> List[Breakpoint]:
+        result: List[Breakpoint] = []
+        current: Optional[Breakpoint] = None
+        for line in self._checked("break").splitlines():
+            m = _BREAK_LIST_RE.match(line.strip())
+            if m:
+                known = self._breakpoints.get(int(m.group(1)))
+                current = Breakpoint(
+                    id=int(m.group(1)),
+                    location=known.location if known else f"{m.group(3)}:{m.group(4)}",
+                    file=m.group(3),
+                    line=int(m.group(4)),
+                    hit_count=known.hit_count if known else 0,
+                    enabled=m.group(2) == "yes",
+                )
+                result.append(current)
+                continue
+            if current is None:
+                continue
+            c = _BREAK_COND_RE.match(line)
+            if c:
+                current.condition = c.group(1).strip()
+            h = _BREAK_HITS_RE.search(line)
+            if h:
+                current.hits = int(h.group(1))
+        return result
+
+    def clear_breakpoint(self, breakpoint_id: int) -> None:
+        self._checked(f"clear {breakpoint_id}")
+        self._breakpoints.pop(breakpoint_id, None)
+
+    def set_watchpoint(self, expr: str, kind: str = WATCH_WRITE) -> Watchpoint:
+        raise DebuggerError("pdb has no watchpoints; use a conditional breakpoint such as 'file.py:42 if x != old'")
+
+    def watchpoints(self) -> List[Watchpoint]:
+        return []
+
+    def clear_watchpoint(self, watchpoint_id: int) -> None:
+        raise DebuggerError(f"No watchpoint {watchpoint_id}: pdb has no watchpoints")
+
+    def continue_(self) -> StopEvent:
+        return self._run("continue")
+
+    def step(self) -> StopEvent:
+        return self._run("step", STEP_INTO)
+
+    def next(self) -> StopEvent:
+        return self._run("next", STEP_OVER)
+
+    def step_out(self) -> StopEvent:
+        """pdb's ``return``: runs until the current function is about to return to its caller."""
+        return self._run("return", STEP_OUT)
+
+    def _run(self, command: str, step: str = "") -> StopEvent:
+        if self.exit_event is not None and step:
+            raise ProcessExitedError(step, self.exit_event.exit_code)
+        if self.exception is not None or self.exit_event is not None or self.hung is not None:
+            message = self._finished_message(command)
+            raise PostMortemError(message) if self.exception is not None else DebuggerError(message)
+        if not self.session_active:
+            raise DebuggerError("pdb session is not running")
+        out = self._resume(command)
+        if self.hung is not None:
+            detail = f"no stop after {self.continue_timeout:g}s and pdb could not break in"
+            return StopEvent(reason=STOP_STOPPED, detail=detail, raw=out + "\n" + self.hung.raw)
+        event = parse_stop(out, list(self._breakpoints.values()), self._runtime)
+        if event.reason == STOP_EXCEPTION:
+            self.exception = event
+        elif event.exited:
+            self.exit_event = event
+        elif step:
+            # pdb follows the thread it is stopped in; there is no other one to switch to.
+            event.stack = self.stacktrace()
+            finish_step(event, step, None, unit="thread")
+        return event
+
+    def _resume(self, command: str) -> str:
+        assert self.child is not None and pexpect is not None
+        self.child.sendline(command)
+        pattern = self._prompt_re or self._compile_prompt_pattern("(Pdb)")
+        partial = ""
+        for timeout in (self.continue_timeout, min(self.timeout, INTERRUPT_TIMEOUT)):
+            try:
+                self.child.expect(pattern, timeout=timeout)
+                return self._normalize_output(command, partial + (self.child.before or ""))
+            except pexpect.TIMEOUT:
+                partial += self.child.before or ""
+            except pexpect.EOF:
+                out = partial + (self.child.before or "")
+                self.child = None
+                raise DebuggerError(f"pdb exited unexpectedly: {out.strip()[-400:]}") from None
+            if timeout == self.continue_timeout:
+                # No stop within the timeout: interrupt so a hung script can be inspected.
+                self.child.sendintr()
+        # pdb only stops at the next Python line; a main thread blocked in a lock or join never gets there.
+        self.hung = self._dump_threads()
+        return self._normalize_output(command, partial)
+
+    def _dump_threads(self) -> GoroutineDump:
+        assert self.child is not None and pexpect is not None
+        if not hasattr(signal, "SIGUSR1"):
+            return GoroutineDump(raw="(thread stacks unavailable: no SIGUSR1 on this platform)")
+        os.kill(self.child.pid, signal.SIGUSR1)
+        try:
+            self.child.expect(pexpect.TIMEOUT, timeout=1.0)
+        except pexpect.EOF:
+            pass
+        return parse_faulthandler(self.child.before or "", self._runtime)
+
+    def stacktrace(self, goroutine_id: Optional[int] = None, depth: int = 50) -> List[Frame]:
+        """Frames of the thread pdb is stopped in (the default) or of another thread by id."""
+        if self.hung is not None:
+            thread = self.hung.get(goroutine_id) if goroutine_id is not None else None
+            thread = thread or next((g for g in self.hung.goroutines if g.current), None)
+            return thread.frames[:depth] if thread is not None else []
+        if goroutine_id is not None:
+            thread = self.goroutines().get(goroutine_id)
+            if thread is None:
+                raise DebuggerError(f"No Python thread with id {goroutine_id}")
+            if not thread.current:
+                return thread.frames[:depth]
+        return parse_where(self._checked("where"), self._runtime_dirs())[:depth]
+
+    def goroutines(self) -> GoroutineDump:
+        if self.hung is not None:
+            return self.hung
+        payload = self._probe("threads()")
+        self._runtime = list(payload.get("runtime") or [])
+        # The thread pdb runs in is best described by ``where``: after an exception it is the traceback.
+        where = parse_where(self._checked("where"), self._runtime)
+        return threads_from_probe(payload, where, "exception" if self.exception is not None else "stopped")
+
+    def read_variable(self, expr: str, cfg: Optional[LoadConfig] = None) -> Variable:
+        cfg = cfg or LoadConfig()
+        data = self._probe(f"value(({expr}), {cfg.max_depth}, {cfg.max_array_values}, {cfg.max_string_len})")
+        return Variable(
+            name=expr, value=str(data.get("repr", "")), type=str(data.get("type", "")), length=int(data.get("len", -1))
+        )
+
+    def eval_in_frame(
+        self, goroutine_id: Optional[int], frame: int, expr: str, cfg: Optional[LoadConfig] = None
+    ) -> Variable:
+        """Evaluate ``expr`` in frame ``frame`` of a thread (ids as in ``goroutines()``), pdb's own frames skipped."""
+        cfg = cfg or LoadConfig()
+        skip = sorted(_DEBUGGER_FILES)
+        data = self._probe(
+            f"frame_eval({goroutine_id!r}, {int(frame)}, {expr!r}, {skip!r}, "
+            f"{cfg.max_depth}, {cfg.max_array_values}, {cfg.max_string_len})"
+        )
+        if data.get("error"):
+            raise DebuggerError(f"Cannot evaluate '{expr}' in frame {frame}: {data['error']}")
+        return Variable(
+            name=expr, value=str(data.get("repr", "")), type=str(data.get("type", "")), length=int(data.get("len", -1))
+        )
+
+    def frame_locals(self, goroutine_id: Optional[int], frame: int, cfg: Optional[LoadConfig] = None) -> List[Variable]:
+        """Local variables of frame ``frame`` of a thread, numbered as in ``eval_in_frame``."""
+        cfg = cfg or LoadConfig()
+        skip = sorted(_DEBUGGER_FILES)
+        data = self._probe(
+            f"frame_locals({goroutine_id!r}, {int(frame)}, {skip!r}, "
+            f"{cfg.max_depth}, {cfg.max_array_values}, {cfg.max_string_len})"
+        )
+        if data.get("error"):
+            raise DebuggerError(f"Cannot list the locals of frame {frame}: {data['error']}")
+        return [
+            Variable(
+                name=str(v["name"]),
+                value=str(v.get("repr", "")),
+                type=str(v.get("type", "")),
+                length=int(v.get("len", -1)),
+            )
+            for v in data.get("locals") or []
+        ]
+
+    def detach(self) -> None:
+        # pdb cannot leave a script running on its own; ending the session stops it.
+        self.close()
+
+    def close(self) -> None:
+        if self.child is not None and self.child.isalive():
+            try:
+                self.child.sendline("quit")
+            except Exception:
+                pass
+        super().close()
+
+    def abort(self) -> None:
+        # The debugger takes its process down with it.
+        self.close()
+
+
+__all__ = [
+    "Interpreter",
+    "PythonDebugger",
+    "parse_faulthandler",
+    "parse_stop",
+    "parse_where",
+    "resolve_interpreter",
+    "threads_from_probe",
+]
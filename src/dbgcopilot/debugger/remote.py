@@ -0,0 +1,602 @@
+"""Drive an already running headless Delve server over JSON-RPC.
+
+Works with any ``dlv ... --headless --listen=host:port`` server (``dlv exec``,
+``dlv debug`` or ``dlv attach``). The connection negotiates the v2 API with
+``RPCServer.SetApiVersion``, so a server started with ``--api-version=1``
+still answers in the v2 format.
+
+Commands typed at the copilot are translated to RPC calls and the replies
+are rendered in the same text format the Delve CLI prints, so every parser
+and analyzer used for a local ``dlv exec`` session works unchanged. When the
+TCP link drops the client reconnects; if the server stays unreachable the
+session degrades to read-only analysis of the last known state instead of
+failing every command.
+
+Cancelling the session's ``context`` shuts the socket down, which ends a
+blocked call such as a long ``Continue``; the server and its target keep
+running.
+"""
+from __future__ import annotations
+
+from typing import Any, Callable, Dict, List, Optional, Tuple
+import json
+import socket
+import time
+
+from dbgcopilot.analyze.goroutines import Frame
+from dbgcopilot.utils.context import Context
+from dbgcopilot.utils.log import trace_debugger
+
+from .base import (
+    BreakpointHit,
+    DebuggerError,
+    DebuggerUnavailable,
+    LoadConfig,
+    OptimizedAwayError,
+    StopEvent,
+    Variable,
+    optimized_away,
+)
+from .delve import DelveDebugger
+from .pretty import variable_from_rpc
+
+
+DEFAULT_API_VERSION = 2
+RECONNECT_ATTEMPTS = 3
+# Goroutines whose full stacks are fetched for ``goroutines -t``; the rest list only their location.
+MAX_STACKED_GOROUTINES = 500
+
+_LOAD_CONFIG = {
+    "FollowPointers": True,
+    "MaxVariableRecurse": 1,
+    "MaxStringLen": 256,
+    "MaxArrayValues": 64,
+    "MaxStructFields": -1,
+}
+_EXEC_COMMANDS = {
+    "continue": "continue", "c": "continue",
+    "next": "next", "n": "next",
+    "step": "step", "s": "step",
+    "stepout": "stepOut", "so": "stepOut",
+    "step-instruction": "step", "si": "step",
+    "halt": "halt",
+}
+# Commands whose last reply is kept for read-only use while the server is unreachable.
+_READ_ONLY = {"stack", "bt", "goroutines", "grs", "goroutine", "gr", "print", "p", "locals", "args", "breakpoints", "bp", "whatis"}
+# runtime.waitReason strings (Go 1.21+ order), used to label blocked goroutines.
+_WAIT_REASONS = [
+    "", "GC assist marking", "IO wait", "chan receive (nil chan)", "chan send (nil chan)", "dumping heap",
+    "garbage collection", "garbage collection scan", "panicwait", "select", "select (no cases)",
+    "GC assist wait", "GC sweep wait", "GC scavenge wait", "chan receive", "chan send", "finalizer wait",
+    "force gc (idle)", "semacquire", "sleep", "sync.Cond.Wait", "sync.Mutex.Lock", "sync.RWMutex.RLock",
+    "sync.RWMutex.Lock", "trace reader (blocked)", "wait for GC cycle", "GC worker (idle)",
+    "GC worker (active)", "preempted", "debug call", "GC mark termination", "stopping the world",
+]
+_STATUS_NAMES = {0: "idle", 1: "runnable", 2: "running", 3: "syscall", 4: "waiting", 6: "dead"}
+_WATCH_TYPES = {"-r": 1, "-w": 2, "-rw": 3}
+
+
+class RemoteUnavailable(DebuggerUnavailable):
+    """The headless Delve server cannot be reached."""
+
+
+class RPCError(DebuggerError):
+    """The server answered the call with an error."""
+
+
+class DelveRemote(DelveDebugger):
+    """``Debugger`` backed by a remote headless Delve server."""
+
+    def __init__(
+        self,
+        addr: str,
+        *,
+        api_version: int = DEFAULT_API_VERSION,
+        timeout: float = 15.0,
+        reconnect_attempts: int = RECONNECT_ATTEMPTS,
+        connect: Optional[Callable[[Tuple[str, int], float], Any]] = None,
+        sleep: Callable[[float], None] = time.sleep,
+        context: Optional[Context] = None,
+    ) -> None:
+        host, _, port = addr.rpartition(":")
+        if not port.isdigit():
+            raise DebuggerError(f"Remote address must be host:port, got '{addr}'")
+        super().__init__(program=f"remote {addr}", timeout=timeout, context=context)
+        self.addr = addr
+        self._endpoint = (host or "127.0.0.1", int(port))
+        self.api_version = api_version
+        self.reconnect_attempts = reconnect_attempts
+        self._connect = connect or (lambda endpoint, t: socket.create_connection(endpoint, timeout=t))
+        self._sleep = sleep
+        self._sock: Any = None
+        self._buffer = ""
+        self._next_id = 0
+        self.server_version = ""
+        self.degraded = False
+        self._cache: Dict[str, str] = {}
+        self._last_stop = ""
+        # The DebuggerState of the last resume, whose Threads name every breakpoint reached.
+        self._last_state: Dict[str, Any] = {}
+        self._last_seen = 0.0
+
+    # ------------------------------------------------------------------
+    # Connection management
+    def initialize_session(self) -> None:
+        if self._unregister_cancel is None:
+            self._unregister_cancel = self.context.on_cancel(self._interrupt_for_cancel)
+        self._open()
+        try:
+            version = self._rpc("GetVersion", {})
+        except DebuggerError:
+            version = {}
+        self.server_version = str(version.get("DelveVersion") or "")
+        remote_api = version.get("APIVersion")
+        banner = f"Connected to Delve {self.server_version or '(unknown version)'} at {self.addr}"
+        if remote_api:
+            banner += f" (server API v{remote_api}, using v{self.api_version})"
+        self._startup_output = banner
+
+    def _open(self) -> None:
+        try:
+            self._sock = self._connect(self._endpoint, self.context.timeout(self.timeout))
+        except OSError as e:
+            self.context.check()
+            raise RemoteUnavailable(f"Cannot connect to Delve server at {self.addr}: {e}") from e
+        self._buffer = ""
+        try:
+            self._rpc("SetApiVersion", {"APIVersion": self.api_version}, retry=False)
+        except RPCError as e:
+            self._close_socket()
+            raise DebuggerError(
+                f"Delve server at {self.addr} rejected API v{self.api_version}: {e}. "
+                "Pass the version the server was started with (--api-version)."
+            ) from e
+        self.degraded = False
+
+    def _close_socket(self) -> None:
+        sock, self._sock = self._sock, None
+        if sock is not None:
+            try:
+                sock.close()
+            except OSError:
+                pass
+
+    def _reconnect(self) -> None:
+        self._close_socket()
+        last: Optional[Exception] = None
+        for attempt in range(self.reconnect_attempts):
+            if attempt:
+                self._sleep(0.5 * (2 ** (attempt - 1)))
+            try:
+                self._open()
+                return
+            except RemoteUnavailable as e:
+                last = e
+        self.degraded = True
+        raise RemoteUnavailable(
+            f"Lost connection to Delve server at {self.addr} and could not reconnect "
+            f"after {self.reconnect_attempts} attempt(s): {last}"
+        )
+
+    def _rpc(self, method: str, params: Dict[str, Any], *, retry: bool = True, timeout: Optional[float] = None) -> Dict[str, Any]:
+        """Call ``RPCServer.<method>``; transport failures trigger one reconnect and retry."""
+        try:
+            return self._roundtrip(method, params, timeout)
+        except (OSError, EOFError, ValueError) as e:
+            # The socket was shut down by a cancellation, not lost.
+            self.context.check()
+            if not retry:
+                raise RemoteUnavailable(f"Delve server at {self.addr} is unreachable: {e}") from e
+        self._reconnect()
+        try:
+            return self._roundtrip(method, params, timeout)
+        except (OSError, EOFError, ValueError) as e:
+            self.degraded = True
+            raise RemoteUnavailable(f"Delve server at {self.addr} is unreachable: {e}") from e
+
+    def _roundtrip(self, method: str, params: Dict[str, Any], timeout: Optional[float]) -> Dict[str, Any]:
+        if self._sock is None:
+            raise EOFError("not connected")
+        self._next_id += 1
+        call_id = self._next_id
+        payload = {"method": f"RPCServer.{method}", "params": [params], "id": call_id}
+        self._sock.settimeout(self.context.timeout(timeout if timeout is not None else self.timeout))
+        trace_debugger("delve-remote", "request", method=method, params=params)
+        start = time.monotonic()
+        self._sock.sendall((json.dumps(payload) + "\n").encode("utf-8"))
+        while True:
+            reply = self._read_reply()
+            if reply.get("id") != call_id:
+                continue
+            seconds = round(time.monotonic() - start, 3)
+            if reply.get("error"):
+                trace_debugger("delve-remote", "response", method=method, seconds=seconds, error=str(reply["error"]))
+                raise RPCError(str(reply["error"]))
+            self._last_seen = time.time()
+            result = reply.get("result") or {}
+            trace_debugger("delve-remote", "response", method=method, seconds=seconds, result=result)
+            return result
+
+    def _read_reply(self) -> Dict[str, Any]:
+        decoder = json.JSONDecoder()
+        while True:
+            text = self._buffer.lstrip()
+            if text:
+                try:
+                    obj, end = decoder.raw_decode(text)
+                except ValueError:
+                    obj, end = None, 0
+                if obj is not None:
+                    self._buffer = text[end:]
+                    return obj if isinstance(obj, dict) else {}
+            chunk = self._sock.recv(65536)
+            if not chunk:
+                raise EOFError("connection closed by Delve server")
+            self._buffer += chunk.decode("utf-8", "replace")
+
+    # ------------------------------------------------------------------
+    # Command translation
+    def run_command(self, cmd: str, timeout: float | None = None) -> str:
+        text = (cmd or "").strip()
+        if not text:
+            return ""
+        outputs = [self._run_one(part, timeout) for part in self._split_commands(text)]
+        return "\n".join(o for o in outputs if o)
+
+    def _run_one(self, cmd: str, timeout: Optional[float]) -> str:
+        words = cmd.split()
+        verb = words[0].lower()
+        try:
+            out = self._dispatch(verb, words[1:], cmd, timeout)
+        except RemoteUnavailable as e:
+            return self._degraded_reply(verb, cmd, str(e))
+        except RPCError as e:
+            return f"Command failed: {e}"
+        if verb in _READ_ONLY:
+            self._cache[cmd] = out
+        return out
+
+    def _degraded_reply(self, verb: str, cmd: str, reason: str) -> str:
+        seen = time.strftime("%H:%M:%S", time.localtime(self._last_seen)) if self._last_seen else "never"
+        if verb in _READ_ONLY and cmd in self._cache:
+            return f"[remote unavailable: last known state from {seen}] {reason}\n{self._cache[cmd]}"
+        last_stop = f"\nLast known stop:\n{self._last_stop}" if self._last_stop else ""
+        return (
+            f"Command failed: {reason}. The session is read-only until the server is back "
+            f"(earlier stacks, goroutines and variables can still be analyzed).{last_stop}"
+        )
+
+    def _dispatch(self, verb: str, args: List[str], raw: str, timeout: Optional[float]) -> str:
+        if verb in _EXEC_COMMANDS:
+            return self._exec(_EXEC_COMMANDS[verb])
+        if verb in {"stack", "bt"}:
+            return self._stack(-1, _depth(args))
+        if verb in {"goroutine", "gr"} and len(args) >= 2 and args[1] in {"stack", "bt"}:
+            return self._stack(_id_arg(args, "goroutine <id> stack [depth]"), _depth(args[2:]))
+        if verb in {"goroutine", "gr"} and not args:
+            return self._current_goroutine()
+        if verb in {"goroutines", "grs"}:
+            return self._goroutines(with_stacks="-t" in args)
+        if verb in {"print", "p"}:
+            return _render_value(self._eval(raw.split(maxsplit=1)[1] if args else ""))
+        if verb == "whatis":
+            return str(self._eval(raw.split(maxsplit=1)[1] if args else "").get("type") or "")
+        if verb in {"locals", "args"}:
+            method, key = ("ListLocalVars", "Variables") if verb == "locals" else ("ListFunctionArgs", "Args")
+            result = self._rpc(method, {"Scope": _scope(), "Cfg": _LOAD_CONFIG})
+            values = result.get(key) or []
+            return "\n".join(f"{v.get('name')} = {_render_value(v)}" for v in values) or f"(no {verb})"
+        if verb in {"break", "b"}:
+            return self._break(raw.split(maxsplit=1)[1] if args else "")
+        if verb in {"breakpoints", "bp"}:
+            return self._list_breakpoints()
+        if verb == "clear" and args:
+            bp = self._rpc("ClearBreakpoint", {"Id": _id_arg(args, "clear <breakpoint id>")}).get("Breakpoint") or {}
+            return f"{_bp_label(bp)} cleared at {_bp_where(bp)}"
+        if verb in {"condition", "cond"} and len(args) >= 2:
+            return self._condition(args)
+        if verb == "watch":
+            return self._watch(args)
+        if verb in {"exit", "quit", "q"}:
+            self.close()
+            return ""
+        raise RPCError(f"'{verb}' is not supported over the remote JSON-RPC connection")
+
+    def _exec(self, name: str) -> str:
+        try:
+            # Continue blocks until the target stops, so no read timeout applies.
+            state = self._rpc("Command", {"name": name}, timeout=None).get("State") or {}
+        except RPCError as e:
+            if "has exited with status" in str(e):
+                self._last_stop = str(e)
+                return str(e)
+            raise
+        self._last_state = state
+        out = _render_state(state)
+        if state.get("NextInProgress"):
+            out += f"\n\tbreakpoint hit during {name.lower()}"
+        self._last_stop = out
+        return out
+
+    def _other_hits(self, event: StopEvent) -> List[BreakpointHit]:
+        seen = {hit.goroutine_id for hit in event.hits}
+        return [hit for hit in _state_hits(self._last_state) if hit.goroutine_id not in seen]
+
+    def _current_goroutine(self) -> str:
+        state = self._rpc("State", {"NonBlocking": True}).get("State") or {}
+        if state.get("exited"):
+            raise RPCError(f"Process {state.get('Pid', 0)} has exited with status {state.get('exitStatus', 0)}")
+        goroutine = state.get("currentGoroutine") or {}
+        thread = state.get("currentThread") or {}
+        return f"Thread {thread.get('id', 0)} at {thread.get('file', '?')}:{thread.get('line', 0)}\n" + (
+            f"Goroutine {goroutine.get('id', 0)}:"
+        )
+
+    def _stack(self, goroutine_id: int, depth: int) -> str:
+        result = self._rpc("Stacktrace", {"Id": goroutine_id, "Depth": depth, "Full": False, "Cfg": None})
+        return _render_frames(result.get("Locations") or [], indent="")
+
+    def _goroutines(self, with_stacks: bool) -> str:
+        result = self._rpc("ListGoroutines", {"Start": 0, "Count": 0})
+        state = self._rpc("State", {"NonBlocking": True}).get("State") or {}
+        selected = (state.get("SelectedGoroutine") or {}).get("id")
+        lines: List[str] = []
+        goroutines = result.get("Goroutines") or []
+        for idx, g in enumerate(goroutines):
+            loc = g.get("userCurrentLoc") or g.get("currentLoc") or {}
+            fn = (loc.get("function") or {}).get("name", "?")
+            mark = "*" if g.get("id") == selected else " "
+            lines.append(
+                f"{mark} Goroutine {g.get('id')} - User: {loc.get('file', '?')}:{loc.get('line', 0)} "
+                f"{fn} (0x{int(loc.get('pc') or 0):x}) [{_wait_label(g)}]"
+            )
+            if with_stacks and idx < MAX_STACKED_GOROUTINES:
+                stack = self._rpc("Stacktrace", {"Id": g.get("id"), "Depth": 50, "Full": False, "Cfg": None})
+                lines.append(_render_frames(stack.get("Locations") or [], indent="\t"))
+        lines.append(f"[{len(goroutines)} goroutines]")
+        return "\n".join(lines)
+
+    def _eval(
+        self, expr: str, cfg: Optional[LoadConfig] = None, scope: Optional[Dict[str, Any]] = None
+    ) -> Dict[str, Any]:
+        if not expr:
+            raise RPCError("print needs an expression")
+        load = cfg.to_rpc() if cfg is not None else _LOAD_CONFIG
+        return self._rpc("Eval", {"Scope": scope or _scope(), "Expr": expr, "Cfg": load}).get("Variable") or {}
+
+    def read_variable(self, expr: str, cfg: Optional[LoadConfig] = None) -> Variable:
+        """Evaluate ``expr`` with Delve's full LoadConfig; the result keeps its structure for ``pretty_print``."""
+        return self._variable(expr, self._eval(expr, cfg))
+
+    def eval_in_frame(
+        self, goroutine_id: Optional[int], frame: int, expr: str, cfg: Optional[LoadConfig] = None
+    ) -> Variable:
+        """``Eval`` with an explicit ``EvalScope``; an optimized-away variable raises ``OptimizedAwayError``."""
+        if frame < 0:
+            raise DebuggerError(f"Frame index must not be negative, got {frame}")
+        scope = _scope(goroutine_id, frame)
+        try:
+            var = self._variable(expr, self._eval(expr, cfg, scope))
+        except RPCError as e:
+            if optimized_away(str(e)):
+                raise OptimizedAwayError(expr, str(e), goroutine_id=goroutine_id, frame=frame) from None
+            raise
+        if var.unreadable and optimized_away(var.unreadable):
+            raise OptimizedAwayError(expr, var.unreadable, goroutine_id=goroutine_id, frame=frame)
+        return var
+
+    def frame_locals(self, goroutine_id: Optional[int], frame: int, cfg: Optional[LoadConfig] = None) -> List[Variable]:
+        """``ListLocalVars`` in one frame; the load config applies to this call only."""
+        load = cfg.to_rpc() if cfg is not None else _LOAD_CONFIG
+        result = self._rpc("ListLocalVars", {"Scope": _scope(goroutine_id, frame), "Cfg": load})
+        return [variable_from_rpc(raw) for raw in result.get("Variables") or []]
+
+    def _variable(self, expr: str, raw: Dict[str, Any]) -> Variable:
+        var = variable_from_rpc(raw)
+        var.name = expr
+        # Composite values have no flat "value"; keep the CLI rendering for callers that only want text.
+        var.value = var.value if var.kind == "string" or not var.children else _render_value(raw)
+        return var
+
+    def _break(self, spec: str) -> str:
+        name = ""
+        parts = spec.split(maxsplit=1)
+        # "break name locspec" as in the CLI; a bare identifier without ':' or '.' is a name.
+        if len(parts) == 2 and ":" not in parts[0] and "." not in parts[0] and not parts[0].isdigit():
+            name, spec = parts
+        if len(spec) > 2 and spec.startswith("/") and spec.endswith("/"):
+            return self._break_all(spec)
+        bp = self._rpc("CreateBreakpoint", {"Breakpoint": {"name": name}, "LocExpr": spec}).get("Breakpoint") or {}
+        return f"{_bp_label(bp)} set at 0x{int(bp.get('addr') or 0):x} for {_bp_where(bp)}"
+
+    def _break_all(self, spec: str) -> str:
+        # As the CLI does for "break /regex/": one breakpoint per function the locspec matches.
+        found = self._rpc(
+            "FindLocation",
+            {"Scope": _scope(), "Loc": spec, "IncludeNonExecutableLines": False, "SubstitutePathRules": None},
+        )
+        lines: List[str] = []
+        for loc in found.get("Locations") or []:
+            bp = self._rpc("CreateBreakpoint", {"Breakpoint": {"addr": loc.get("pc")}}).get("Breakpoint") or {}
+            lines.append(f"{_bp_label(bp)} set at 0x{int(bp.get('addr') or 0):x} for {_bp_where(bp)}")
+        return "\n".join(lines)
+
+    def _list_breakpoints(self) -> str:
+        lines: List[str] = []
+        for bp in self._rpc("ListBreakpoints", {"All": False}).get("Breakpoints") or []:
+            state = "disabled" if bp.get("disabled") else "enabled"
+            lines.append(
+                f"{_bp_label(bp)} ({state}) at 0x{int(bp.get('addr') or 0):x} for {_bp_where(bp)} "
+                f"({int(bp.get('totalHitCount') or 0)})"
+            )
+            if bp.get("Cond"):
+                lines.append(f"\tcond {bp['Cond']}")
+            if bp.get("HitCond"):
+                lines.append(f"\tcond -hitcount {bp['HitCond']}")
+        return "\n".join(lines)
+
+    def _condition(self, args: List[str]) -> str:
+        hitcount = args[0] == "-hitcount"
+        if hitcount:
+            args = args[1:]
+        usage = "condition [-hitcount] <breakpoint id> <expression>"
+        bp = self._rpc("GetBreakpoint", {"Id": _id_arg(args, usage)}).get("Breakpoint") or {}
+        if hitcount:
+            bp["HitCond"] = " ".join(args[1:])
+        else:
+            bp["Cond"] = " ".join(args[1:])
+        self._rpc("AmendBreakpoint", {"Breakpoint": bp})
+        return ""
+
+    def _watch(self, args: List[str]) -> str:
+        flag = args[0] if args and args[0] in _WATCH_TYPES else "-w"
+        expr = " ".join(args[1:] if args and args[0] in _WATCH_TYPES else args)
+        bp = self._rpc("CreateWatchpoint", {"Scope": _scope(), "Expr": expr, "Type": _WATCH_TYPES[flag]})
+        bp = bp.get("Breakpoint") or {}
+        return f"Watchpoint {bp.get('id')} on [{expr}] set at 0x{int(bp.get('addr') or 0):x}"
+
+    def detach(self) -> None:
+        self.close()
+
+    def close(self) -> None:
+        # Leave the headless server (and its target) running for other clients.
+        self._release_context()
+        self._close_socket()
+
+    def abort(self) -> None:
+        self.close()
+
+    def _interrupt_for_cancel(self) -> None:
+        sock = self._sock
+        if sock is not None:
+            try:
+                sock.shutdown(socket.SHUT_RDWR)
+            except OSError:
+                pass
+
+
+def _id_arg(args: List[str], usage: str) -> int:
+    # Reported like any other failed command rather than a ValueError out of run_command.
+    if not args or not args[0].isdigit():
+        raise RPCError(f"usage: {usage}")
+    return int(args[0])
+
+
+def _depth(args: List[str]) -> int:
+    for arg in args:
+        if arg.isdigit():
+            return int(arg)
+    return 50
+
+
+def _scope(goroutine_id: Optional[int] = None, frame: int = 0) -> Dict[str, Any]:
+    return {"GoroutineID": -1 if goroutine_id is None else goroutine_id, "Frame": frame}
+
+
+def _wait_label(g: Dict[str, Any]) -> str:
+    reason = g.get("waitReason")
+    if isinstance(reason, int) and 0 < reason < len(_WAIT_REASONS):
+        return _WAIT_REASONS[reason]
+    if isinstance(reason, str) and reason:
+        return reason
+    return _STATUS_NAMES.get(int(g.get("status") or 0), "unknown")
+
+
+def _render_frames(locations: List[Dict[str, Any]], indent: str) -> str:
+    lines: List[str] = []
+    for idx, loc in enumerate(locations):
+        fn = (loc.get("function") or {}).get("name", "?")
+        lines.append(f"{indent}{idx}  0x{int(loc.get('pc') or 0):016x} in {fn}")
+        lines.append(f"{indent}    at {loc.get('file', '?')}:{loc.get('line', 0)}")
+    return "\n".join(lines)
+
+
+def _bp_label(bp: Dict[str, Any]) -> str:
+    kind = "Watchpoint" if bp.get("WatchExpr") else "Breakpoint"
+    return f"{kind} {bp.get('name') or bp.get('id')}"
+
+
+def _bp_where(bp: Dict[str, Any]) -> str:
+    return f"{bp.get('functionName') or '?'}() {bp.get('file', '?')}:{bp.get('line', 0)}"
+
+
+def _render_state(state: Dict[str, Any]) -> str:
+    if state.get("exited"):
+        return f"Process {state.get('Pid', 0)} has exited with status {state.get('exitStatus', 0)}"
+    lines: List[str] = []
+    for bp in state.get("WatchOutOfScope") or []:
+        lines.append(f"Watchpoint {bp.get('id')} on [{bp.get('WatchExpr', '')}] went out of scope and was cleared")
+    thread = state.get("currentThread") or state.get("CurrentThread") or {}
+    if not thread:
+        return "\n".join(lines) or "(target is running)"
+    selected = (state.get("currentGoroutine") or {}).get("id")
+    lines.append(_render_thread(thread, selected))
+    return "\n".join(lines)
+
+
+def _state_hits(state: Dict[str, Any]) -> List[BreakpointHit]:
+    """Breakpoints other threads than the current one reached in the same stop, from ``State.Threads``."""
+    current = (state.get("currentThread") or state.get("CurrentThread") or {}).get("id")
+    hits: List[BreakpointHit] = []
+    for thread in state.get("Threads") or []:
+        bp = thread.get("breakPoint") or {}
+        if thread.get("id") == current or not bp or bp.get("WatchExpr"):
+            continue
+        if not bp.get("name") and int(bp.get("id") or 0) <= 0:
+            continue
+        frame = Frame(
+            function=(thread.get("function") or {}).get("name", "?"),
+            file=thread.get("file", "?"),
+            line=int(thread.get("line") or 0),
+        )
+        hits.append(
+            BreakpointHit(
+                breakpoint_id=None if bp.get("name") else int(bp["id"]),
+                goroutine_id=thread.get("goroutineID") or None,
+                frame=frame,
+                name=bp.get("name") or "",
+            )
+        )
+    return hits
+
+
+def _render_thread(thread: Dict[str, Any], selected: Any) -> str:
+    fn = (thread.get("function") or {}).get("name", "?")
+    gid = thread.get("goroutineID", 0)
+    # As the CLI does, name the goroutine when the stopped thread runs another one than the selected.
+    switched = f"goroutine({gid}): " if selected and gid and gid != selected else ""
+    bp = thread.get("breakPoint") or {}
+    tag = ""
+    hits = ""
+    if bp:
+        if bp.get("WatchExpr"):
+            tag = f"[watchpoint {bp.get('id')} on [{bp['WatchExpr']}]] "
+        elif bp.get("name"):
+            tag = f"[{bp['name']}] "
+        elif int(bp.get("id") or 0) > 0:
+            tag = f"[Breakpoint {bp['id']}] "
+        count = (bp.get("hitCount") or {}).get(str(gid), 0)
+        hits = f" (hits goroutine({gid}):{count} total:{int(bp.get('totalHitCount') or 0)})"
+    return (
+        f"> {tag}{switched}{fn}() {thread.get('file', '?')}:{thread.get('line', 0)}{hits} "
+        f"(PC: 0x{int(thread.get('pc') or 0):x})"
+    )
+
+
+def _render_value(var: Dict[str, Any]) -> str:
+    if not var:
+        return ""
+    value = var.get("value")
+    children = var.get("children") or []
+    if value not in (None, "") or not children:
+        vtype = var.get("type") or ""
+        if vtype == "string":
+            return json.dumps(value or "")
+        return str(value if value is not None else "nil")
+    inner = ", ".join(
+        f"{c.get('name')}: {_render_value(c)}" if c.get("name") else _render_value(c) for c in children
+    )
+    return f"{var.get('type', '')} {{{inner}}}".strip()
+
+
+__all__ = ["DEFAULT_API_VERSION", "DelveRemote", "RPCError", "RemoteUnavailable"]
@@ -0,0 +1,272 @@
+# pyright: reportUnknownMemberType=false, reportUnknownArgumentType=false, reportUnknownVariableType=false, reportUnknownParameterType=false
+
+"""Anthropic Messages API provider.
+
+Configuration precedence:
+- Session config: anthropic_api_key, anthropic_model, anthropic_base_url
+- Environment: ANTHROPIC_API_KEY, ANTHROPIC_MODEL, ANTHROPIC_BASE_URL
+- Provider metadata: base_url, default_model
+"""
+from __future__ import annotations
+
+import os
+from typing import Any, Dict, Iterator, List, Optional, Tuple
+
+from . import params as param_utils
+from .base import (
+    LLMError,
+    http_error,
+    max_output_tokens,
+    request_error,
+    request_timeout,
+    retry_after_header,
+    stream_lines,
+)
+from .streaming import iter_json_events
+from .tools import Tool, ToolReply, parse_anthropic_content, to_anthropic_messages
+
+API_VERSION = "2023-06-01"
+DEFAULT_BASE_URL = "https://api.anthropic.com"
+DEFAULT_MODEL = "claude-sonnet-4-5"
+
+
+def _get_cfg(session_config: Optional[dict[str, Any]], meta: Dict[str, Any]) -> Dict[str, Any]:
+    sc = session_config or {}
+
+    def pick(sc_key: str, env_key: str) -> Optional[str]:
+        if sc.get(sc_key):
+            return str(sc[sc_key])
+        if os.environ.get(env_key):
+            return os.environ[env_key]
+        return None
+
+    return {
+        "api_key": pick("anthropic_api_key", "ANTHROPIC_API_KEY") or meta.get("api_key"),
+        "model": pick("anthropic_model", "ANTHROPIC_MODEL") or meta.get("default_model") or DEFAULT_MODEL,
+        "base_url": (pick("anthropic_base_url", "ANTHROPIC_BASE_URL") or meta.get("base_url") or DEFAULT_BASE_URL).rstrip("/"),
+    }
+
+
+def _extract_usage(data: Dict[str, Any], model: str) -> Dict[str, Any]:
+    usage: Dict[str, Any] = {"provider": "anthropic", "model": data.get("model") or model}
+    raw = data.get("usage")
+    if isinstance(raw, dict):
+        prompt_tokens = raw.get("input_tokens")
+        completion_tokens = raw.get("output_tokens")
+        if isinstance(prompt_tokens, int):
+            usage["prompt_tokens"] = prompt_tokens
+        if isinstance(completion_tokens, int):
+            usage["completion_tokens"] = completion_tokens
+        if isinstance(prompt_tokens, int) and isinstance(completion_tokens, int):
+            usage["total_tokens"] = prompt_tokens + completion_tokens
+    return usage
+
+
+def _build_request(
+    prompt: str,
+    session_config: Optional[dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> Tuple[str, Dict[str, str], Dict[str, Any], str]:
+    """Return (url, headers, body, model) for a Messages API call."""
+    meta = meta or {}
+    name = str(meta.get("name") or "anthropic")
+    cfg = _get_cfg(session_config, meta)
+    if not cfg["api_key"]:
+        raise LLMError(
+            "Anthropic API key not configured. Set ANTHROPIC_API_KEY or anthropic_api_key in session config."
+        )
+
+    body: Dict[str, Any] = {
+        "model": cfg["model"],
+        "max_tokens": max_output_tokens(meta),
+        "temperature": float(meta.get("default_temperature") or 0.0),
+        "messages": [{"role": "user", "content": prompt}],
+    }
+    default_params = meta.get("default_params")
+    if isinstance(default_params, dict):
+        body = param_utils.apply_params(body, default_params, meta, assume_canonical=False)
+    session_params = param_utils.get_session_params(session_config or {}, name)
+    body = param_utils.apply_params(body, session_params, meta, assume_canonical=True)
+    # The Messages API names this field stop_sequences rather than stop.
+    if "stop" in body:
+        body["stop_sequences"] = body.pop("stop")
+
+    url = f"{cfg['base_url']}/v1/messages"
+    headers = {
+        "x-api-key": cfg["api_key"],
+        "anthropic-version": API_VERSION,
+        "content-type": "application/json",
+        "accept": "application/json",
+    }
+    return url, headers, body, cfg["model"]
+
+
+def _raise_for_status(name: str, url: str, resp: Any) -> None:
+    if 200 <= resp.status_code < 300:
+        return
+    snippet = (resp.text or "")[:200].replace("\n", " ")
+    # 529 is Anthropic's "overloaded" status; treat it like a rate limit.
+    status = 429 if resp.status_code == 529 else resp.status_code
+    detail = f"{name} HTTP {resp.status_code} for {url}: {snippet}"
+    raise http_error(name, status, detail, retry_after=retry_after_header(resp))
+
+
+def _ask_anthropic(
+    prompt: str,
+    session_config: Optional[dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> Tuple[str, Dict[str, Any]]:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for the Anthropic provider") from e
+
+    meta = meta or {}
+    name = str(meta.get("name") or "anthropic")
+    url, headers, body, model = _build_request(prompt, session_config, meta)
+    try:
+        resp = requests.post(url, headers=headers, json=body, timeout=request_timeout(session_config, meta))
+    except Exception as e:
+        raise request_error(name, e) from e
+    _raise_for_status(name, url, resp)
+
+    try:
+        data = resp.json()
+    except Exception as e:
+        raw = (resp.text or "")[:400]
+        raise LLMError(f"{name} returned invalid JSON (status {resp.status_code}). Snippet: {raw}") from e
+
+    blocks = data.get("content") or []
+    content = "".join(b.get("text", "") for b in blocks if isinstance(b, dict) and b.get("type") == "text")
+    return content, _extract_usage(data, model)
+
+
+def _chat_anthropic(
+    messages: List[Dict[str, Any]],
+    tools: List[Tool],
+    session_config: Optional[dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> ToolReply:
+    """One tool-use turn; ``messages`` are in OpenAI chat shape and converted here."""
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for the Anthropic provider") from e
+
+    meta = meta or {}
+    name = str(meta.get("name") or "anthropic")
+    url, headers, body, model = _build_request("", session_config, meta)
+    system, body["messages"] = to_anthropic_messages(messages)
+    if system:
+        body["system"] = system
+    if tools:
+        body["tools"] = [t.anthropic_spec() for t in tools]
+    try:
+        resp = requests.post(url, headers=headers, json=body, timeout=request_timeout(session_config, meta))
+    except Exception as e:
+        raise request_error(name, e) from e
+    _raise_for_status(name, url, resp)
+    try:
+        data = resp.json()
+    except Exception as e:
+        raw = (resp.text or "")[:400]
+        raise LLMError(f"{name} returned invalid JSON (status {resp.status_code}). Snippet: {raw}") from e
+    reply = parse_anthropic_content(data.get("content") or [])
+    reply.usage = _extract_usage(data, model)
+    return reply
+
+
+def _stream_anthropic(
+    prompt: str,
+    session_config: Optional[dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> Iterator[str]:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for the Anthropic provider") from e
+
+    meta = meta or {}
+    name = str(meta.get("name") or "anthropic")
+    url, headers, body, _model = _build_request(prompt, session_config, meta)
+    body["stream"] = True
+    headers["accept"] = "text/event-stream"
+    try:
+        resp = requests.post(url, headers=headers, json=body, timeout=request_timeout(session_config, meta), stream=True)
+    except Exception as e:
+        raise request_error(name, e) from e
+    try:
+        _raise_for_status(name, url, resp)
+        for event in iter_json_events(stream_lines(resp)):
+            kind = event.get("type")
+            if kind == "content_block_delta":
+                delta = event.get("delta") or {}
+                if delta.get("type") == "text_delta" and delta.get("text"):
+                    yield delta["text"]
+            elif kind == "message_stop":
+                return
+            elif kind == "error":
+                err = event.get("error") or {}
+                status = 429 if err.get("type") in {"overloaded_error", "rate_limit_error"} else 500
+                raise http_error(name, status, f"{name} stream error: {err.get('message') or err}")
+    finally:
+        resp.close()
+
+
+def create_provider(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None):
+    """Return an ask(prompt) function bound to session_config."""
+    meta_payload = dict(meta or {})
+
+    def ask(prompt: str) -> str:
+        content, usage = _ask_anthropic(prompt, session_config=session_config, meta=meta_payload)
+        setattr(ask, "last_usage", usage)
+        return content
+
+    setattr(ask, "last_usage", {})
+    return ask
+
+
+def create_stream_provider(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None):
+    """Return a stream(prompt) generator function using the Messages streaming API."""
+    meta_payload = dict(meta or {})
+
+    def stream(prompt: str) -> Iterator[str]:
+        return _stream_anthropic(prompt, session_config=session_config, meta=meta_payload)
+
+    return stream
+
+
+def create_tool_provider(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None):
+    """Return a chat(messages, tools) function using Messages API tool use."""
+    meta_payload = dict(meta or {})
+
+    def chat(messages: List[Dict[str, Any]], tools: List[Tool]) -> ToolReply:
+        reply = _chat_anthropic(messages, tools, session_config=session_config, meta=meta_payload)
+        setattr(chat, "last_usage", reply.usage)
+        return reply
+
+    setattr(chat, "last_usage", {})
+    return chat
+
+
+def list_models(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None) -> list[str]:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required to list Anthropic models") from e
+
+    cfg = _get_cfg(session_config, dict(meta or {}))
+    if not cfg["api_key"]:
+        raise LLMError("Anthropic API key not configured; cannot list models")
+    headers = {"x-api-key": cfg["api_key"], "anthropic-version": API_VERSION}
+    try:
+        resp = requests.get(f"{cfg['base_url']}/v1/models", headers=headers, timeout=15)
+    except Exception as e:
+        raise LLMError(f"Anthropic models request failed: {e}") from e
+    if not (200 <= resp.status_code < 300):
+        return []
+    try:
+        data = resp.json()
+    except Exception:
+        return []
+    return [m["id"] for m in data.get("data") or [] if isinstance(m, dict) and isinstance(m.get("id"), str)]
@@ -0,0 +1,230 @@
+"""Provider-neutral completion interface, errors and context-window limits."""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from email.utils import parsedate_to_datetime
+from typing import Any, Dict, Iterator, List, Mapping, Optional, Protocol
+
+from dbgcopilot.utils.context import current
+
+DEFAULT_CONTEXT_WINDOW = 8192
+DEFAULT_MAX_OUTPUT_TOKENS = 512
+DEFAULT_TIMEOUT = 20.0
+# Rough prompt budget conversion; stack dumps and source are ~4 chars per token.
+CHARS_PER_TOKEN = 4
+
+
+class LLMError(RuntimeError):
+    """A provider call failed. ``retryable`` errors may succeed later or on another provider.
+
+    ``retry_after`` is the server's ``Retry-After`` hint in seconds, when it sent one.
+    """
+
+    retryable = False
+
+    def __init__(
+        self,
+        message: str,
+        *,
+        provider: str = "",
+        status: Optional[int] = None,
+        retry_after: Optional[float] = None,
+    ) -> None:
+        super().__init__(message)
+        self.provider = provider
+        self.status = status
+        self.retry_after = retry_after
+
+
+class RateLimited(LLMError):
+    retryable = True
+
+
+class ProviderTimeout(LLMError):
+    retryable = True
+
+
+class ServerError(LLMError):
+    """HTTP 5xx: the provider failed, not the request."""
+
+    retryable = True
+
+
+def _new_stop_list() -> List[str]:
+    return []
+
+
+@dataclass
+class CompletionOptions:
+    max_tokens: Optional[int] = None
+    temperature: Optional[float] = None
+    stop: List[str] = field(default_factory=_new_stop_list)
+    timeout: Optional[float] = None
+
+    def as_params(self) -> Dict[str, Any]:
+        """Canonical parameter overrides understood by ``llm.params.apply_params``."""
+        params: Dict[str, Any] = {}
+        if self.max_tokens is not None:
+            params["max_tokens"] = int(self.max_tokens)
+        if self.temperature is not None:
+            params["temperature"] = float(self.temperature)
+        if self.stop:
+            params["stop"] = list(self.stop)
+        return params
+
+
+class CompletionProvider(Protocol):
+    name: str
+
+    @property
+    def context_window(self) -> int: ...
+
+    @property
+    def max_output_tokens(self) -> int: ...
+
+    def complete(self, prompt: str, opts: Optional[CompletionOptions] = None) -> str: ...
+
+
+def _as_positive_int(value: Any) -> Optional[int]:
+    try:
+        number = int(value)
+    except (TypeError, ValueError):
+        return None
+    return number if number > 0 else None
+
+
+def context_window(meta: Mapping[str, Any] | None) -> int:
+    return _as_positive_int((meta or {}).get("context_window")) or DEFAULT_CONTEXT_WINDOW
+
+
+def max_output_tokens(meta: Mapping[str, Any] | None) -> int:
+    meta = meta or {}
+    return (
+        _as_positive_int(meta.get("max_output_tokens"))
+        or _as_positive_int(meta.get("default_max_tokens"))
+        or DEFAULT_MAX_OUTPUT_TOKENS
+    )
+
+
+def model_meta(meta: Mapping[str, Any] | None, model: Optional[str]) -> Mapping[str, Any]:
+    """``meta`` with the ``models`` entry for ``model`` (its own ``context_window``, ...) laid over it."""
+    meta = meta or {}
+    models = meta.get("models")
+    entry = models.get(model) if model and isinstance(models, Mapping) else None
+    return {**meta, **entry} if isinstance(entry, Mapping) else meta
+
+
+def prompt_char_budget(meta: Mapping[str, Any] | None) -> int:
+    """Characters of prompt that fit beside the reserved output tokens."""
+    tokens = max(context_window(meta) - max_output_tokens(meta), 256)
+    return tokens * CHARS_PER_TOKEN
+
+
+def request_timeout(session_config: Mapping[str, Any] | None, meta: Mapping[str, Any] | None = None) -> float:
+    """Seconds an HTTP request may take: ``llm_timeout``, the provider's ``timeout``, then the default.
+
+    Never more than the active context has left; raises its ``Cancelled``
+    instead of starting a request once it is done.
+    """
+    timeout = DEFAULT_TIMEOUT
+    for raw in ((session_config or {}).get("llm_timeout"), (meta or {}).get("timeout")):
+        try:
+            value = float(raw) if raw not in (None, "") else None
+        except (TypeError, ValueError):
+            value = None
+        if value and value > 0:
+            timeout = value
+            break
+    bounded = current().timeout(timeout)
+    return timeout if bounded is None else bounded
+
+
+def stream_lines(resp: Any) -> Iterator[str]:
+    """``resp.iter_lines()`` that the active context can abort from another thread.
+
+    Cancelling closes the response, which unblocks a read stuck waiting for
+    the next token; whatever that does to the iteration (an error, an early
+    end), the caller gets the context's ``Cancelled`` rather than a partial
+    answer that looks complete.
+    """
+    ctx = current()
+    unregister = ctx.on_cancel(resp.close)
+    try:
+        for line in resp.iter_lines(decode_unicode=True):
+            ctx.check()
+            yield line
+    except Exception:
+        ctx.check()
+        raise
+    finally:
+        unregister()
+    ctx.check()
+
+
+def parse_retry_after(value: Any, now: Optional[datetime] = None) -> Optional[float]:
+    """Seconds to wait from a ``Retry-After`` header (delta-seconds or an HTTP date)."""
+    if value in (None, ""):
+        return None
+    text = str(value).strip()
+    try:
+        return max(float(text), 0.0)
+    except ValueError:
+        pass
+    try:
+        when = parsedate_to_datetime(text)
+    except (TypeError, ValueError):
+        return None
+    if when.tzinfo is None:
+        when = when.replace(tzinfo=timezone.utc)
+    return max((when - (now or datetime.now(timezone.utc))).total_seconds(), 0.0)
+
+
+def retry_after_header(resp: Any) -> Optional[float]:
+    headers = getattr(resp, "headers", None) or {}
+    return parse_retry_after(headers.get("Retry-After") or headers.get("retry-after"))
+
+
+def http_error(name: str, status: int, message: str, *, retry_after: Optional[float] = None) -> LLMError:
+    """Map an HTTP failure to the error class used for retry and fallback decisions.
+
+    429, 408 and 5xx are retryable; other 4xx (bad credentials, malformed
+    requests) fail fast.
+    """
+    if status == 429:
+        return RateLimited(message, provider=name, status=status, retry_after=retry_after)
+    if status in {408, 504}:
+        return ProviderTimeout(message, provider=name, status=status, retry_after=retry_after)
+    if 500 <= status < 600:
+        return ServerError(message, provider=name, status=status, retry_after=retry_after)
+    return LLMError(message, provider=name, status=status)
+
+
+def request_error(name: str, exc: Exception) -> LLMError:
+    """Wrap a transport exception, keeping timeouts distinguishable."""
+    if "timeout" in type(exc).__name__.lower() or "timed out" in str(exc).lower():
+        return ProviderTimeout(f"{name} request timed out: {exc}", provider=name)
+    return LLMError(f"{name} request failed: {exc}", provider=name)
+
+
+__all__ = [
+    "CHARS_PER_TOKEN",
+    "CompletionOptions",
+    "CompletionProvider",
+    "DEFAULT_CONTEXT_WINDOW",
+    "DEFAULT_MAX_OUTPUT_TOKENS",
+    "LLMError",
+    "ProviderTimeout",
+    "RateLimited",
+    "ServerError",
+    "context_window",
+    "http_error",
+    "max_output_tokens",
+    "model_meta",
+    "parse_retry_after",
+    "prompt_char_budget",
+    "request_error",
+    "request_timeout",
+    "retry_after_header",
+    "stream_lines",
+]
@@ -0,0 +1,180 @@
+"""On-disk cache of LLM completions keyed by a hash of the request.
+
+The key covers the provider, the model, the exact prompt and the sampling
+options, so any change to the prompt (a new debugger output, a different
+temperature) is a miss. Entries are JSON files under the cache directory
+and expire after a TTL.
+
+Session config keys: ``llm_cache`` (``off`` disables), ``llm_cache_dir``
+(default ``$DBGCOPILOT_CACHE_DIR`` or ``~/.cache/dbgcopilot/llm``) and
+``llm_cache_ttl`` in seconds.
+"""
+from __future__ import annotations
+
+from pathlib import Path
+from typing import Any, Callable, Dict, Mapping, Optional
+import hashlib
+import json
+import os
+import time
+
+from . import params as _llm_params
+
+CACHE_DIR_ENV_VAR = "DBGCOPILOT_CACHE_DIR"
+DEFAULT_CACHE_DIR = Path.home() / ".cache" / "dbgcopilot" / "llm"
+DEFAULT_TTL = 24 * 3600.0
+
+_OFF = {"off", "0", "false", "no"}
+
+
+def cache_key(provider: str, model: str, prompt: str, options: Optional[Mapping[str, Any]] = None) -> str:
+    payload = json.dumps(
+        {"provider": provider, "model": model, "prompt": prompt, "options": dict(options or {})},
+        sort_keys=True,
+        ensure_ascii=False,
+        default=str,
+    )
+    return hashlib.sha256(payload.encode("utf-8")).hexdigest()
+
+
+def request_options(provider: str, session_config: Optional[Mapping[str, Any]]) -> Dict[str, Any]:
+    """Session settings that change what ``provider`` answers: parameter overrides and fallbacks."""
+    cfg = dict(session_config or {})
+    options: Dict[str, Any] = dict(_llm_params.get_session_params(cfg, provider))
+    if cfg.get("llm_fallback"):
+        options["fallback"] = cfg["llm_fallback"]
+    return options
+
+
+class Cache:
+    def __init__(
+        self,
+        directory: Optional[str] = None,
+        *,
+        ttl: float = DEFAULT_TTL,
+        enabled: bool = True,
+        clock: Callable[[], float] = time.time,
+    ) -> None:
+        self.directory = Path(directory or os.environ.get(CACHE_DIR_ENV_VAR) or DEFAULT_CACHE_DIR).expanduser()
+        self.ttl = ttl
+        self.enabled = enabled
+        self._clock = clock
+
+    @classmethod
+    def from_config(cls, config: Optional[Mapping[str, Any]]) -> "Cache":
+        cfg = config or {}
+        enabled = str(cfg.get("llm_cache") or "on").strip().lower() not in _OFF
+        raw_ttl = cfg.get("llm_cache_ttl")
+        try:
+            ttl = float(raw_ttl) if raw_ttl not in (None, "") else DEFAULT_TTL
+        except (TypeError, ValueError):
+            raise ValueError(f"llm_cache_ttl must be a number of seconds, got {raw_ttl!r}") from None
+        return cls(cfg.get("llm_cache_dir") or None, ttl=ttl, enabled=enabled)
+
+    def _path(self, key: str) -> Path:
+        return self.directory / key[:2] / f"{key}.json"
+
+    def get(self, key: str) -> Optional[str]:
+        """Cached response for ``key``, or None on a miss or an expired entry."""
+        if not self.enabled:
+            return None
+        path = self._path(key)
+        try:
+            entry = json.loads(path.read_text(encoding="utf-8"))
+        except (OSError, ValueError):
+            return None
+        if self.ttl > 0 and self._clock() - float(entry.get("created", 0)) > self.ttl:
+            try:
+                path.unlink()
+            except OSError:
+                pass
+            return None
+        response = entry.get("response")
+        return response if isinstance(response, str) else None
+
+    def put(self, key: str, response: str, *, provider: str = "", model: str = "") -> None:
+        if not self.enabled:
+            return
+        path = self._path(key)
+        entry = {"created": self._clock(), "provider": provider, "model": model, "response": response}
+        try:
+            path.parent.mkdir(parents=True, exist_ok=True)
+            tmp = path.with_suffix(f".{os.getpid()}.tmp")
+            tmp.write_text(json.dumps(entry, ensure_ascii=False), encoding="utf-8")
+            os.replace(tmp, path)
+        except OSError:
+            # A read-only or full cache directory must not break the session.
+            pass
+
+    def clear(self) -> int:
+        """Delete every entry; returns how many were removed."""
+        removed = 0
+        for path in self.directory.glob("*/*.json"):
+            try:
+                path.unlink()
+                removed += 1
+            except OSError:
+                pass
+        return removed
+
+
+class CachedClient:
+    """ask(prompt) callable that serves exact repeats from ``cache``.
+
+    ``last_cache_hit`` tells the caller (and the session recorder) whether the
+    most recent answer came from disk.
+    """
+
+    def __init__(
+        self,
+        ask: Callable[[str], str],
+        cache: Cache,
+        *,
+        provider: str,
+        model: str = "",
+        options: Optional[Mapping[str, Any]] = None,
+    ) -> None:
+        self.ask = ask
+        self.cache = cache
+        self.provider = provider
+        self.model = model
+        self.options = dict(options or {})
+        self.last_cache_hit = False
+        self.last_usage: Dict[str, Any] = {}
+        self.last_provider: Optional[str] = None
+
+    def key(self, prompt: str) -> str:
+        return cache_key(self.provider, self.model, prompt, self.options)
+
+    def lookup(self, prompt: str) -> Optional[str]:
+        answer = self.cache.get(self.key(prompt))
+        self.last_cache_hit = answer is not None
+        if answer is not None:
+            self.last_usage = {}
+            self.last_provider = self.provider
+        return answer
+
+    def store(self, prompt: str, answer: str) -> None:
+        if answer:
+            self.cache.put(self.key(prompt), answer, provider=self.last_provider or self.provider, model=self.model)
+
+    def __call__(self, prompt: str) -> str:
+        answer = self.lookup(prompt)
+        if answer is not None:
+            return answer
+        answer = self.ask(prompt)
+        self.last_usage = dict(getattr(self.ask, "last_usage", {}) or {})
+        self.last_provider = getattr(self.ask, "last_provider", None) or self.provider
+        self.store(prompt, answer)
+        return answer
+
+
+__all__ = [
+    "CACHE_DIR_ENV_VAR",
+    "Cache",
+    "CachedClient",
+    "DEFAULT_CACHE_DIR",
+    "DEFAULT_TTL",
+    "cache_key",
+    "request_options",
+]
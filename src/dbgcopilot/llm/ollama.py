@@ -0,0 +1,203 @@
+# pyright: reportUnknownMemberType=false, reportUnknownArgumentType=false, reportUnknownVariableType=false, reportUnknownParameterType=false
+
+"""Native Ollama /api/chat provider for fully local models.
+
+Unlike the OpenAI-compatible endpoint, the native API accepts ``num_ctx``, so
+the provider's configured ``context_window`` is actually applied by the server
+instead of Ollama silently truncating long stack dumps to its default.
+
+Configuration: ollama_base_url / OLLAMA_BASE_URL and ollama_model / OLLAMA_MODEL,
+falling back to the provider metadata (default http://localhost:11434, llama3.1).
+"""
+from __future__ import annotations
+
+import json
+import os
+from typing import Any, Dict, Iterator, Optional, Tuple
+
+from . import params as param_utils
+from .base import (
+    LLMError,
+    context_window,
+    http_error,
+    max_output_tokens,
+    request_error,
+    request_timeout,
+    retry_after_header,
+    stream_lines,
+)
+
+DEFAULT_BASE_URL = "http://localhost:11434"
+DEFAULT_MODEL = "llama3.1"
+
+# Ollama's native API nests sampling parameters under "options".
+_OPTION_KEYS = {"temperature", "top_p", "top_k", "stop", "repeat_penalty", "mirostat"}
+
+
+def _get_cfg(session_config: Optional[dict[str, Any]], meta: Dict[str, Any]) -> Dict[str, Any]:
+    sc = session_config or {}
+    base_url = sc.get("ollama_base_url") or os.environ.get("OLLAMA_BASE_URL") or meta.get("base_url") or DEFAULT_BASE_URL
+    model = sc.get("ollama_model") or os.environ.get("OLLAMA_MODEL") or meta.get("default_model") or DEFAULT_MODEL
+    return {"base_url": str(base_url).rstrip("/"), "model": str(model)}
+
+
+def _build_request(
+    prompt: str,
+    session_config: Optional[dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> Tuple[str, Dict[str, Any], str]:
+    """Return (url, body, model) for an /api/chat call."""
+    meta = meta or {}
+    name = str(meta.get("name") or "ollama-native")
+    cfg = _get_cfg(session_config, meta)
+
+    flat: Dict[str, Any] = {"temperature": float(meta.get("default_temperature") or 0.0)}
+    default_params = meta.get("default_params")
+    if isinstance(default_params, dict):
+        flat = param_utils.apply_params(flat, default_params, meta, assume_canonical=False)
+    session_params = param_utils.get_session_params(session_config or {}, name)
+    flat = param_utils.apply_params(flat, session_params, meta, assume_canonical=True)
+    extras = flat.pop("extras", {})
+    options: Dict[str, Any] = {k: v for k, v in flat.items() if k in _OPTION_KEYS}
+    if isinstance(extras, dict):
+        options.update({k: v for k, v in extras.items() if k in _OPTION_KEYS})
+    options["num_ctx"] = context_window(meta)
+    options["num_predict"] = int(flat.get("max_tokens") or max_output_tokens(meta))
+
+    body = {
+        "model": cfg["model"],
+        "messages": [{"role": "user", "content": prompt}],
+        "stream": False,
+        "options": options,
+    }
+    return f"{cfg['base_url']}/api/chat", body, cfg["model"]
+
+
+def _ask_ollama(
+    prompt: str,
+    session_config: Optional[dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> Tuple[str, Dict[str, Any]]:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for the Ollama provider") from e
+
+    meta = meta or {}
+    name = str(meta.get("name") or "ollama-native")
+    url, body, model = _build_request(prompt, session_config, meta)
+    try:
+        resp = requests.post(url, json=body, timeout=request_timeout(session_config, meta))
+    except Exception as e:
+        raise request_error(name, e) from e
+
+    if not (200 <= resp.status_code < 300):
+        snippet = (resp.text or "")[:200].replace("\n", " ")
+        detail = f"{name} HTTP {resp.status_code} for {url}: {snippet}"
+        raise http_error(name, resp.status_code, detail, retry_after=retry_after_header(resp))
+
+    try:
+        data = resp.json()
+    except Exception as e:
+        raw = (resp.text or "")[:400]
+        raise LLMError(f"{name} returned invalid JSON (status {resp.status_code}). Snippet: {raw}") from e
+
+    message = data.get("message") if isinstance(data.get("message"), dict) else {}
+    usage: Dict[str, Any] = {"provider": name, "model": data.get("model") or model}
+    if isinstance(data.get("prompt_eval_count"), int):
+        usage["prompt_tokens"] = data["prompt_eval_count"]
+    if isinstance(data.get("eval_count"), int):
+        usage["completion_tokens"] = data["eval_count"]
+    if "prompt_tokens" in usage and "completion_tokens" in usage:
+        usage["total_tokens"] = usage["prompt_tokens"] + usage["completion_tokens"]
+    return str(message.get("content") or ""), usage
+
+
+def _stream_ollama(
+    prompt: str,
+    session_config: Optional[dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> Iterator[str]:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for the Ollama provider") from e
+
+    meta = meta or {}
+    name = str(meta.get("name") or "ollama-native")
+    url, body, _model = _build_request(prompt, session_config, meta)
+    body["stream"] = True
+    try:
+        resp = requests.post(url, json=body, timeout=request_timeout(session_config, meta), stream=True)
+    except Exception as e:
+        raise request_error(name, e) from e
+    try:
+        if not (200 <= resp.status_code < 300):
+            snippet = (resp.text or "")[:200].replace("\n", " ")
+            detail = f"{name} HTTP {resp.status_code} for {url}: {snippet}"
+            raise http_error(name, resp.status_code, detail, retry_after=retry_after_header(resp))
+        # The native API streams one JSON object per line rather than SSE.
+        for line in stream_lines(resp):
+            if not line:
+                continue
+            try:
+                event = json.loads(line)
+            except ValueError:
+                continue
+            if event.get("error"):
+                raise LLMError(f"{name} stream error: {event['error']}")
+            message = event.get("message") if isinstance(event.get("message"), dict) else {}
+            if message.get("content"):
+                yield str(message["content"])
+            if event.get("done"):
+                return
+    finally:
+        resp.close()
+
+
+def create_provider(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None):
+    """Return an ask(prompt) function bound to session_config."""
+    meta_payload = dict(meta or {})
+
+    def ask(prompt: str) -> str:
+        content, usage = _ask_ollama(prompt, session_config=session_config, meta=meta_payload)
+        setattr(ask, "last_usage", usage)
+        return content
+
+    setattr(ask, "last_usage", {})
+    return ask
+
+
+def create_stream_provider(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None):
+    """Return a stream(prompt) generator function over /api/chat's line-delimited stream."""
+    meta_payload = dict(meta or {})
+
+    def stream(prompt: str) -> Iterator[str]:
+        return _stream_ollama(prompt, session_config=session_config, meta=meta_payload)
+
+    return stream
+
+
+def list_models(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None) -> list[str]:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required to list Ollama models") from e
+
+    cfg = _get_cfg(session_config, dict(meta or {}))
+    try:
+        resp = requests.get(f"{cfg['base_url']}/api/tags", timeout=15)
+    except Exception as e:
+        raise LLMError(f"Ollama models request failed: {e}") from e
+    if not (200 <= resp.status_code < 300):
+        return []
+    try:
+        data = resp.json() or {}
+    except Exception:
+        return []
+    models: list[str] = []
+    for m in data.get("models") or []:
+        mid = m.get("name") or m.get("model")
+        if isinstance(mid, str):
+            models.append(mid)
+    return models
@@ -18,16 +18,19 @@ Configuration precedence (per provider name, e.g., 'openai-http' or 'ollama'):
   - For name == 'ollama': base_url=http://localhost:11434, model='llama3.1', no API key
   - Otherwise: require base_url and API key
 
-Note: This is a lightweight POC client. For production, add retries, timeouts, and robust error handling.
+HTTP 429 and timeouts raise ``RateLimited``/``ProviderTimeout`` so callers can fall back to another provider.
 """
 from __future__ import annotations
 
 import os
 import json
 import re
-from typing import Optional, Dict, Any, Tuple
+from typing import Optional, Dict, Any, Iterator, List, Tuple
 
 from . import params as param_utils
+from .base import LLMError, http_error, request_error, request_timeout, retry_after_header, stream_lines
+from .streaming import iter_json_events, openai_delta
+from .tools import Tool, ToolReply, parse_openai_message
 
 
 def _slug_to_env_prefix(name: str) -> str:
@@ -161,18 +164,14 @@ def _extract_usage(data: Dict[str, Any], provider_name: str, model: str) -> Dict
     return usage
 
 
-def _ask_openai_compat(
+def _build_request(
     prompt: str,
     name: str,
     session_config: Optional[dict[str, Any]] = None,
     defaults: Optional[Dict[str, Any]] = None,
     meta: Optional[Dict[str, Any]] = None,
-) -> Tuple[str, Dict[str, Any]]:
-    try:
-        import requests
-    except Exception as e:
-        raise RuntimeError("requests library is required for OpenAI-compatible providers") from e
-
+) -> Tuple[str, Dict[str, str], Dict[str, Any], str]:
+    """Return (url, headers, body, model) for a chat completion request."""
     cfg = _get_cfg(name, session_config, defaults=defaults)
     base_url = (cfg.get("base_url") or "").rstrip("/")
     api_key = cfg.get("api_key")
@@ -185,7 +184,10 @@ def _ask_openai_compat(
         headers["Authorization"] = f"Bearer {api_key}"
 
     if not base_url:
-        raise RuntimeError(f"{name}: base_url not configured. Set {name.replace('-', '_')}_base_url in session config or { _slug_to_env_prefix(name) }_BASE_URL in env.")
+        raise LLMError(
+            f"{name}: base_url not configured. Set {name.replace('-', '_')}_base_url in session config "
+            f"or {_slug_to_env_prefix(name)}_BASE_URL in env."
+        )
 
     url = f"{base_url}{path if path.startswith('/') else '/' + path}"
     meta = meta or {}
@@ -219,20 +221,36 @@ def _ask_openai_compat(
 
     session_params = param_utils.get_session_params(session_config or {}, name)
     body = param_utils.apply_params(body, session_params, meta, assume_canonical=True)
+    return url, headers, body, model
+
 
+def _ask_openai_compat(
+    prompt: str,
+    name: str,
+    session_config: Optional[dict[str, Any]] = None,
+    defaults: Optional[Dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> Tuple[str, Dict[str, Any]]:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for OpenAI-compatible providers") from e
+
+    url, headers, body, model = _build_request(prompt, name, session_config, defaults, meta)
     try:
-        resp = requests.post(url, headers=headers, json=body, timeout=20)
+        resp = requests.post(url, headers=headers, json=body, timeout=request_timeout(session_config, meta))
     except Exception as e:
-        raise RuntimeError(f"{name} request failed: {e}") from e
+        raise request_error(name, e) from e
 
     if not (200 <= resp.status_code < 300):
         snippet = (resp.text or "")[:200].replace("\n", " ")
-        raise RuntimeError(f"{name} HTTP {resp.status_code} for {url}: {snippet}")
+        detail = f"{name} HTTP {resp.status_code} for {url}: {snippet}"
+        raise http_error(name, resp.status_code, detail, retry_after=retry_after_header(resp))
 
     content_type = resp.headers.get("Content-Type", "").lower()
     if "json" not in content_type:
         snippet = (resp.text or "")[:400].replace("\n", " ")
-        raise RuntimeError(
+        raise LLMError(
             f"{name} returned non-JSON payload (content-type={content_type or 'unknown'}). "
             f"Response snippet: {snippet}"
         )
@@ -241,7 +259,7 @@ def _ask_openai_compat(
         data = resp.json()
     except Exception as e:
         raw = (resp.text or "")[:400]
-        raise RuntimeError(f"{name} returned invalid JSON (status {resp.status_code}). Snippet: {raw}") from e
+        raise LLMError(f"{name} returned invalid JSON (status {resp.status_code}). Snippet: {raw}") from e
 
     # Try OpenAI-like shape first
     try:
@@ -254,6 +272,77 @@ def _ask_openai_compat(
     return content, usage
 
 
+def _chat_openai_compat(
+    messages: List[Dict[str, Any]],
+    tools: List[Tool],
+    name: str,
+    session_config: Optional[dict[str, Any]] = None,
+    defaults: Optional[Dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> ToolReply:
+    """One function-calling turn: ``messages`` in OpenAI chat shape, ``tools`` offered to the model."""
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for OpenAI-compatible providers") from e
+
+    url, headers, body, model = _build_request("", name, session_config, defaults, meta)
+    body["messages"] = messages
+    if tools:
+        body["tools"] = [t.openai_spec() for t in tools]
+    try:
+        resp = requests.post(url, headers=headers, json=body, timeout=request_timeout(session_config, meta))
+    except Exception as e:
+        raise request_error(name, e) from e
+    if not (200 <= resp.status_code < 300):
+        snippet = (resp.text or "")[:200].replace("\n", " ")
+        detail = f"{name} HTTP {resp.status_code} for {url}: {snippet}"
+        raise http_error(name, resp.status_code, detail, retry_after=retry_after_header(resp))
+    try:
+        data = resp.json()
+        message = data["choices"][0]["message"]
+    except Exception as e:
+        raw = (resp.text or "")[:400]
+        raise LLMError(
+            f"{name} returned an unexpected tool-call response (status {resp.status_code}). Snippet: {raw}"
+        ) from e
+    reply = parse_openai_message(message)
+    reply.usage = _extract_usage(data, name, model)
+    return reply
+
+
+def _stream_openai_compat(
+    prompt: str,
+    name: str,
+    session_config: Optional[dict[str, Any]] = None,
+    defaults: Optional[Dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+) -> Iterator[str]:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for OpenAI-compatible providers") from e
+
+    url, headers, body, _model = _build_request(prompt, name, session_config, defaults, meta)
+    body["stream"] = True
+    headers["Accept"] = "text/event-stream"
+    try:
+        resp = requests.post(url, headers=headers, json=body, timeout=request_timeout(session_config, meta), stream=True)
+    except Exception as e:
+        raise request_error(name, e) from e
+    try:
+        if not (200 <= resp.status_code < 300):
+            snippet = (resp.text or "")[:200].replace("\n", " ")
+            detail = f"{name} HTTP {resp.status_code} for {url}: {snippet}"
+            raise http_error(name, resp.status_code, detail, retry_after=retry_after_header(resp))
+        for event in iter_json_events(stream_lines(resp)):
+            delta = openai_delta(event)
+            if delta:
+                yield delta
+    finally:
+        resp.close()
+
+
 def create_provider(
     session_config: dict[str, Any] | None = None,
     name: str = "openai-http",
@@ -283,6 +372,52 @@ def create_provider(
     return ask
 
 
+def create_stream_provider(
+    session_config: dict[str, Any] | None = None,
+    name: str = "openai-http",
+    defaults: Optional[Dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+):
+    """Return a stream(prompt) generator function using server-sent events."""
+    meta_payload = dict(meta or {})
+
+    def stream(prompt: str) -> Iterator[str]:
+        return _stream_openai_compat(
+            prompt,
+            name=name,
+            session_config=session_config,
+            defaults=defaults,
+            meta=meta_payload,
+        )
+
+    return stream
+
+
+def create_tool_provider(
+    session_config: dict[str, Any] | None = None,
+    name: str = "openai-http",
+    defaults: Optional[Dict[str, Any]] = None,
+    meta: Optional[Dict[str, Any]] = None,
+):
+    """Return a chat(messages, tools) function that returns a ``ToolReply``."""
+    meta_payload = dict(meta or {})
+
+    def chat(messages: List[Dict[str, Any]], tools: List[Tool]) -> ToolReply:
+        reply = _chat_openai_compat(
+            messages,
+            tools,
+            name=name,
+            session_config=session_config,
+            defaults=defaults,
+            meta=meta_payload,
+        )
+        setattr(chat, "last_usage", reply.usage)
+        return reply
+
+    setattr(chat, "last_usage", {})
+    return chat
+
+
 def list_models(
     session_config: dict[str, Any] | None = None,
     name: str = "openai-http",
@@ -298,7 +433,7 @@ def list_models(
     try:
         import requests
     except Exception as e:
-        raise RuntimeError("requests library is required to list models for OpenAI-compatible providers") from e
+        raise LLMError("requests library is required to list models for OpenAI-compatible providers") from e
 
     cfg = _get_cfg(name, session_config, defaults=defaults)
     base_url = (cfg.get("base_url") or "").rstrip("/")
@@ -308,7 +443,7 @@ def list_models(
         headers["Authorization"] = f"Bearer {api_key}"
 
     if not base_url:
-        raise RuntimeError(f"{name}: base_url not configured; cannot list models")
+        raise LLMError(f"{name}: base_url not configured; cannot list models")
 
     # 1) Try OpenAI-compatible /v1/models (Gemini uses a different path)
     try:
@@ -13,9 +13,12 @@ from __future__ import annotations
 
 import os
 import json
-from typing import Optional, Tuple, Dict, Any
+from typing import Optional, Tuple, Dict, Any, Iterator, List
 
 from . import params as param_utils
+from .base import LLMError, http_error, request_error, request_timeout, retry_after_header, stream_lines
+from .streaming import iter_json_events, openai_delta
+from .tools import Tool, ToolReply, parse_openai_message
 
 
 def _get_api_key(meta: dict[str, Any] | None = None, session_config: dict[str, Any] | None = None) -> Optional[str]:
@@ -66,20 +69,15 @@ def _extract_usage(data: Dict[str, Any], model: str) -> Dict[str, Any]:
     return usage
 
 
-def _ask_openrouter(
+def _build_request(
     prompt: str,
     meta: dict[str, Any] | None = None,
     session_config: dict[str, Any] | None = None,
-) -> Tuple[str, Dict[str, Any]]:
-    # Lazy import to avoid adding hard runtime deps for tests
-    try:
-        import requests
-    except Exception as e:
-        raise RuntimeError("requests library is required for OpenRouter provider") from e
-
+) -> Tuple[str, Dict[str, str], Dict[str, Any], str]:
+    """Return (url, headers, body, model) for an OpenRouter chat completion."""
     key = _get_api_key(meta, session_config)
     if not key:
-        raise RuntimeError(
+        raise LLMError(
             "OpenRouter API key not configured. Set OPENROUTER_API_KEY, provide openrouter_api_key via session config, "
             "or select a different LLM provider."
         )
@@ -135,17 +133,32 @@ def _ask_openrouter(
     provider_name = str(meta.get("name") or "openrouter")
     session_params = param_utils.get_session_params(session_config or {}, provider_name)
     body = param_utils.apply_params(body, session_params, meta, assume_canonical=True)
+    return url, headers, body, model
 
+
+def _ask_openrouter(
+    prompt: str,
+    meta: dict[str, Any] | None = None,
+    session_config: dict[str, Any] | None = None,
+) -> Tuple[str, Dict[str, Any]]:
+    # Lazy import to avoid adding hard runtime deps for tests
     try:
-        resp = requests.post(url, headers=headers, json=body, timeout=20)
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for OpenRouter provider") from e
+
+    url, headers, body, model = _build_request(prompt, meta=meta, session_config=session_config)
+    try:
+        resp = requests.post(url, headers=headers, json=body, timeout=request_timeout(session_config, meta))
     except Exception as e:  # requests.RequestException in most cases
-        raise RuntimeError(f"OpenRouter request failed: {e}") from e
+        raise request_error("OpenRouter", e) from e
 
     # If non-2xx, surface body text to aid debugging
     if not (200 <= resp.status_code < 300):
         text = (resp.text or "").strip()
         snippet = text[:200].replace("\n", " ")
-        raise RuntimeError(f"OpenRouter HTTP {resp.status_code}: {snippet}")
+        detail = f"OpenRouter HTTP {resp.status_code}: {snippet}"
+        raise http_error("OpenRouter", resp.status_code, detail, retry_after=retry_after_header(resp))
 
     # Parse JSON response; if not JSON, show the raw response body for diagnosis
     try:
@@ -153,7 +166,7 @@ def _ask_openrouter(
     except Exception as e:
         raw = resp.text or ""
         # Prefer showing full provider response to help troubleshooting
-        raise RuntimeError(f"OpenRouter returned non-JSON response:\n{raw}") from e
+        raise LLMError(f"OpenRouter returned non-JSON response:\n{raw}") from e
     # Expecting standard OpenAI-like shape: choices[0].message.content
     content: str
     try:
@@ -164,6 +177,70 @@ def _ask_openrouter(
     return content, usage
 
 
+def _chat_openrouter(
+    messages: List[Dict[str, Any]],
+    tools: List[Tool],
+    meta: dict[str, Any] | None = None,
+    session_config: dict[str, Any] | None = None,
+) -> ToolReply:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for OpenRouter provider") from e
+
+    url, headers, body, model = _build_request("", meta=meta, session_config=session_config)
+    body["messages"] = messages
+    if tools:
+        body["tools"] = [t.openai_spec() for t in tools]
+    try:
+        resp = requests.post(url, headers=headers, json=body, timeout=request_timeout(session_config, meta))
+    except Exception as e:
+        raise request_error("OpenRouter", e) from e
+    if not (200 <= resp.status_code < 300):
+        snippet = (resp.text or "").strip()[:200].replace("\n", " ")
+        detail = f"OpenRouter HTTP {resp.status_code}: {snippet}"
+        raise http_error("OpenRouter", resp.status_code, detail, retry_after=retry_after_header(resp))
+    try:
+        data = resp.json()
+        message = data["choices"][0]["message"]
+    except Exception as e:
+        raise LLMError(f"OpenRouter returned an unexpected tool-call response:\n{resp.text or ''}") from e
+    reply = parse_openai_message(message)
+    reply.usage = _extract_usage(data, model)
+    return reply
+
+
+def _stream_openrouter(
+    prompt: str,
+    meta: dict[str, Any] | None = None,
+    session_config: dict[str, Any] | None = None,
+) -> Iterator[str]:
+    try:
+        import requests
+    except Exception as e:
+        raise LLMError("requests library is required for OpenRouter provider") from e
+
+    url, headers, body, _model = _build_request(prompt, meta=meta, session_config=session_config)
+    body["stream"] = True
+    headers["Accept"] = "text/event-stream"
+    try:
+        resp = requests.post(url, headers=headers, json=body, timeout=request_timeout(session_config, meta), stream=True)
+    except Exception as e:
+        raise request_error("OpenRouter", e) from e
+    try:
+        if not (200 <= resp.status_code < 300):
+            snippet = (resp.text or "").strip()[:200].replace("\n", " ")
+            detail = f"OpenRouter HTTP {resp.status_code}: {snippet}"
+            raise http_error("OpenRouter", resp.status_code, detail, retry_after=retry_after_header(resp))
+        # OpenRouter interleaves ": OPENROUTER PROCESSING" keep-alive comments; iter_sse_data skips them.
+        for event in iter_json_events(stream_lines(resp)):
+            delta = openai_delta(event)
+            if delta:
+                yield delta
+    finally:
+        resp.close()
+
+
 def create_provider(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None):
     # Returns a callable that accepts prompt and returns string
     meta = meta or {}
@@ -177,6 +254,27 @@ def create_provider(session_config: dict[str, Any] | None = None, meta: dict[str
     return ask
 
 
+def create_stream_provider(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None):
+    meta = meta or {}
+
+    def stream(prompt: str) -> Iterator[str]:
+        return _stream_openrouter(prompt, meta=meta, session_config=session_config)
+
+    return stream
+
+
+def create_tool_provider(session_config: dict[str, Any] | None = None, meta: dict[str, Any] | None = None):
+    meta = meta or {}
+
+    def chat(messages: List[Dict[str, Any]], tools: List[Tool]) -> ToolReply:
+        reply = _chat_openrouter(messages, tools, meta=meta, session_config=session_config)
+        setattr(chat, "last_usage", reply.usage)
+        return reply
+
+    setattr(chat, "last_usage", {})
+    return chat
+
+
 def list_models(session_config: dict[str, Any] | None = None) -> list[str]:
     """Return a list of available model IDs from OpenRouter.
 
@@ -185,7 +283,7 @@ def list_models(session_config: dict[str, Any] | None = None) -> list[str]:
     try:
         import requests
     except Exception as e:
-        raise RuntimeError("requests library is required to list OpenRouter models") from e
+        raise LLMError("requests library is required to list OpenRouter models") from e
 
     key = _get_api_key(None, session_config)
     url = "https://openrouter.ai/api/v1/models"
@@ -198,18 +296,18 @@ def list_models(session_config: dict[str, Any] | None = None) -> list[str]:
     try:
         resp = requests.get(url, headers=headers, timeout=15)
     except Exception as e:
-        raise RuntimeError(f"OpenRouter models request failed: {e}") from e
+        raise LLMError(f"OpenRouter models request failed: {e}") from e
 
     if not (200 <= resp.status_code < 300):
         text = (resp.text or "").strip()
         snippet = text[:200].replace("\n", " ")
-        raise RuntimeError(f"OpenRouter HTTP {resp.status_code}: {snippet}")
+        raise LLMError(f"OpenRouter HTTP {resp.status_code}: {snippet}")
 
     try:
         data = resp.json()
     except Exception as e:
         snippet = (resp.text or "")[:200].replace("\n", " ")
-        raise RuntimeError(f"OpenRouter returned non-JSON response: {snippet}") from e
+        raise LLMError(f"OpenRouter returned non-JSON response: {snippet}") from e
 
     models = []
     try:
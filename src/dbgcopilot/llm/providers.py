@@ -4,15 +4,35 @@
 from __future__ import annotations
 
 import json
-from typing import Any, Callable, Dict, Optional, cast
+from typing import Any, Callable, Dict, Iterator, Optional, cast
 import os
+import time
 from pathlib import Path
-from typing import Any, Callable, Dict, Optional, cast
 
-from . import openai_compat, openrouter
+from dbgcopilot.utils import tracing
+from dbgcopilot.utils.log import trace_llm
+
+from . import anthropic, ollama, openai_compat, openrouter
+from . import params as _llm_params
+from .base import (
+    CompletionOptions,
+    LLMError,
+    context_window as _context_window,
+    max_output_tokens as _max_output_tokens,
+    model_meta as _model_meta,
+    prompt_char_budget as _prompt_char_budget,
+)
+from .cache import Cache, CachedClient, request_options
+from .retry import with_retry
+from .streaming import TokenStream, blocking_chunks, resumed_chunks, started
+from .tools import Tool, ToolReply
 
 CONFIG_ENV_VAR = "DBGCOPILOT_LLM_PROVIDERS"
+PROVIDER_ENV_VAR = "DBGCOPILOT_LLM_PROVIDER"
+FALLBACK_ENV_VAR = "DBGCOPILOT_LLM_FALLBACK"
 CONFIG_FILENAME = "llm_providers.json"
+# chat(messages, tools) for providers with native function calling.
+ToolChat = Callable[[list[Dict[str, Any]], list[Tool]], ToolReply]
 DEFAULT_CONFIG: Dict[str, Any] = {
     "providers": {
         "mock-local": {
@@ -24,6 +44,7 @@ DEFAULT_CONFIG: Dict[str, Any] = {
             "kind": "openrouter",
             "description": "OpenRouter API provider (requires OPENROUTER_API_KEY)",
             "default_model": "openai/gpt-4o-mini",
+            "context_window": 128000,
             "supports_model_list": True,
             "capabilities": [
                 "temperature",
@@ -45,6 +66,7 @@ DEFAULT_CONFIG: Dict[str, Any] = {
             "base_url": "",
             "path": "/v1/chat/completions",
             "default_model": "gpt-4o-mini",
+            "context_window": 128000,
             "capabilities": [
                 "temperature",
                 "max_tokens",
@@ -60,6 +82,8 @@ DEFAULT_CONFIG: Dict[str, Any] = {
             "base_url": "http://localhost:11434",
             "path": "/v1/chat/completions",
             "default_model": "llama3.1",
+            # Ollama's OpenAI-compatible endpoint uses the server's default num_ctx.
+            "context_window": 4096,
             "capabilities": [
                 "temperature",
                 "max_tokens",
@@ -71,6 +95,43 @@ DEFAULT_CONFIG: Dict[str, Any] = {
                 "mirostat": "extras.mirostat",
             },
         },
+        "ollama-native": {
+            "kind": "ollama",
+            "description": "Local Ollama via native /api/chat (applies context_window as num_ctx)",
+            "base_url": "http://localhost:11434",
+            "default_model": "llama3.1",
+            "context_window": 8192,
+            "supports_model_list": True,
+            "capabilities": [
+                "temperature",
+                "max_tokens",
+                "top_p",
+                "top_k",
+                "stop_sequences",
+                "repeat_penalty",
+                "mirostat",
+            ],
+            "param_aliases": {
+                "repeat_penalty": "extras.repeat_penalty",
+                "mirostat": "extras.mirostat",
+            },
+        },
+        "anthropic": {
+            "kind": "anthropic",
+            "description": "Anthropic Messages API (requires ANTHROPIC_API_KEY)",
+            "base_url": "https://api.anthropic.com",
+            "default_model": "claude-sonnet-4-5",
+            "context_window": 200000,
+            "max_output_tokens": 1024,
+            "supports_model_list": True,
+            "capabilities": [
+                "temperature",
+                "max_tokens",
+                "top_p",
+                "top_k",
+                "stop_sequences",
+            ],
+        },
         "deepseek": {
             "kind": "openai-compatible",
             "description": "DeepSeek OpenAI-compatible API",
@@ -190,6 +251,69 @@ DEFAULT_CONFIG: Dict[str, Any] = {
 }
 
 
+class TracingClient:
+    """ask(prompt) or chat(messages, tools) wrapper that traces each request and answer at debug level.
+
+    ``--log-level debug`` (see ``dbgcopilot.utils.log``) shows them redacted;
+    at any other level the wrapper only forwards the call. Each request is
+    also an ``llm.request`` span when tracing is on (``dbgcopilot.utils.tracing``).
+    """
+
+    def __init__(self, client: Callable[..., Any], provider: str) -> None:
+        self.client = client
+        self.provider = provider
+
+    def __call__(self, request: Any, *args: Any, **kwargs: Any) -> Any:
+        trace_llm(self.provider, "prompt", prompt=_trace_text(request))
+        start = time.monotonic()
+        with tracing.span(tracing.SPAN_LLM_REQUEST, provider=self.provider) as span:
+            try:
+                answer = self.client(request, *args, **kwargs)
+            except Exception as e:
+                trace_llm(self.provider, "error", seconds=round(time.monotonic() - start, 3), error=str(e))
+                raise
+            usage = self.last_usage
+            span.set(**{k: usage.get(k) for k in ("model", "prompt_tokens", "completion_tokens")})
+        trace_llm(self.provider, "response", seconds=round(time.monotonic() - start, 3), response=_trace_text(answer))
+        return answer
+
+    @property
+    def last_usage(self) -> Dict[str, Any]:
+        return dict(getattr(self.client, "last_usage", {}) or {})
+
+    def __getattr__(self, name: str) -> Any:
+        return getattr(self.client, name)
+
+
+def _trace_text(value: Any) -> str:
+    if isinstance(value, str):
+        return value
+    if isinstance(value, ToolReply):
+        calls = ", ".join(call.describe() for call in value.calls)
+        return value.text + (f" [tool calls: {calls}]" if calls else "")
+    # Tool-chat requests: the message list.
+    return json.dumps(value, default=str, ensure_ascii=False)
+
+
+def _traced_chunks(chunks: Iterator[str], provider: str, prompt: str) -> Iterator[str]:
+    trace_llm(provider, "prompt", prompt=prompt, stream=True)
+    start = time.monotonic()
+    parts: list[str] = []
+    finished = False
+    try:
+        for chunk in chunks:
+            parts.append(chunk)
+            yield chunk
+        finished = True
+    finally:
+        # Closing this generator (TokenStream.cancel) must still release the provider's response.
+        close = getattr(chunks, "close", None)
+        if close is not None:
+            close()
+        kind = "response" if finished else "response cut short"
+        trace_llm(provider, kind, seconds=round(time.monotonic() - start, 3), response="".join(parts))
+
+
 class Provider:
     """Container for provider metadata and factory helpers."""
 
@@ -199,6 +323,10 @@ class Provider:
         kind: str,
         meta: Dict[str, Any],
         factory: Callable[[Optional[dict[str, Any]], Dict[str, Any]], Callable[[str], str]],
+        stream_factory: Optional[
+            Callable[[Optional[dict[str, Any]], Dict[str, Any]], Callable[[str], Iterator[str]]]
+        ] = None,
+        tool_factory: Optional[Callable[[Optional[dict[str, Any]], Dict[str, Any]], ToolChat]] = None,
     ) -> None:
         self.name = name
         self.kind = kind
@@ -210,11 +338,84 @@ class Provider:
         copied.setdefault("name", name)
         self.meta = copied
         self._factory = factory
+        self._stream_factory = stream_factory
+        self._tool_factory = tool_factory
         # Default ask function without per-session overrides (backwards compatible)
         self.ask = self.create_client(None)
 
     def create_client(self, session_config: Optional[dict[str, Any]] = None) -> Callable[[str], str]:
-        return self._factory(session_config, self.meta)
+        """ask(prompt) for this provider, retried and rate-limited per ``llm.retry``; each attempt is traced."""
+        return with_retry(TracingClient(self._factory(session_config, self.meta), self.name), self.name, session_config)
+
+    @property
+    def context_window(self) -> int:
+        """Total tokens (prompt plus completion) the provider's model accepts."""
+        return _context_window(self.meta)
+
+    @property
+    def max_output_tokens(self) -> int:
+        return _max_output_tokens(self.meta)
+
+    @property
+    def prompt_char_budget(self) -> int:
+        return _prompt_char_budget(self.meta)
+
+    @property
+    def supports_streaming(self) -> bool:
+        return self._stream_factory is not None
+
+    @property
+    def supports_tools(self) -> bool:
+        """Whether the provider accepts native function-calling requests."""
+        return self._tool_factory is not None
+
+    def create_tool_client(self, session_config: Optional[dict[str, Any]] = None) -> Optional[ToolChat]:
+        if self._tool_factory is None:
+            return None
+        chat = TracingClient(self._tool_factory(session_config, self.meta), self.name)
+        return with_retry(chat, self.name, session_config)
+
+    def _config_with(self, opts: Optional[CompletionOptions], session_config: Optional[dict[str, Any]]) -> dict[str, Any]:
+        config: dict[str, Any] = dict(session_config or {})
+        if opts is not None:
+            overrides = opts.as_params()
+            if overrides:
+                key = _llm_params.params_key(self.name)
+                merged = dict(_llm_params.get_session_params(config, self.name))
+                merged.update(overrides)
+                config[key] = merged
+            if opts.timeout:
+                config["llm_timeout"] = opts.timeout
+        return config
+
+    def complete(
+        self,
+        prompt: str,
+        opts: Optional[CompletionOptions] = None,
+        session_config: Optional[dict[str, Any]] = None,
+    ) -> str:
+        """Run one completion, applying ``opts`` on top of the session parameters."""
+        return self.create_client(self._config_with(opts, session_config))(prompt)
+
+    def complete_stream(
+        self,
+        prompt: str,
+        opts: Optional[CompletionOptions] = None,
+        session_config: Optional[dict[str, Any]] = None,
+    ) -> TokenStream:
+        """Like ``complete`` but yields chunks as they arrive.
+
+        Providers without a streaming API run the blocking call lazily and
+        produce the whole answer as one chunk. Either way the request is
+        rate-limited and retried per ``llm.retry`` until the first chunk.
+        """
+        config = self._config_with(opts, session_config)
+        if self._stream_factory is not None:
+            start = with_retry(started(self._stream_factory(config, self.meta)), self.name, config)
+            chunks = _traced_chunks(resumed_chunks(start, prompt), self.name, prompt)
+            return TokenStream(chunks, provider=self.name)
+        client = self.create_client(config)
+        return TokenStream(blocking_chunks(client, prompt), provider=self.name, client=client)
 
 
 _config_cache: Optional[Dict[str, Any]] = None
@@ -312,6 +513,11 @@ def _merge_default_providers(providers: Dict[str, Any]) -> bool:
     return merged
 
 
+def _tool_factory_if_enabled(meta: Dict[str, Any], factory: Any) -> Any:
+    # Many OpenAI-compatible servers ignore or reject "tools"; "tools": false in the entry opts out.
+    return None if meta.get("tools") is False else factory
+
+
 def _build_provider(name: str, entry: Dict[str, Any]) -> Optional[Provider]:
     kind = str(entry.get("kind", "openai-compatible")).lower()
     meta = dict(entry)
@@ -328,7 +534,36 @@ def _build_provider(name: str, entry: Dict[str, Any]) -> Optional[Provider]:
         def _factory_openrouter(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> Callable[[str], str]:
             return openrouter.create_provider(session_config=session_config, meta=meta_ref)
 
-        return Provider(name, kind, meta, _factory_openrouter)
+        def _stream_openrouter(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> Callable[[str], Iterator[str]]:
+            return openrouter.create_stream_provider(session_config=session_config, meta=meta_ref)
+
+        def _tools_openrouter(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> ToolChat:
+            return openrouter.create_tool_provider(session_config=session_config, meta=meta_ref)
+
+        tools = _tool_factory_if_enabled(meta, _tools_openrouter)
+        return Provider(name, kind, meta, _factory_openrouter, _stream_openrouter, tools)
+
+    if kind == "anthropic":
+        def _factory_anthropic(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> Callable[[str], str]:
+            return anthropic.create_provider(session_config=session_config, meta=meta_ref)
+
+        def _stream_anthropic(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> Callable[[str], Iterator[str]]:
+            return anthropic.create_stream_provider(session_config=session_config, meta=meta_ref)
+
+        def _tools_anthropic(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> ToolChat:
+            return anthropic.create_tool_provider(session_config=session_config, meta=meta_ref)
+
+        tools = _tool_factory_if_enabled(meta, _tools_anthropic)
+        return Provider(name, kind, meta, _factory_anthropic, _stream_anthropic, tools)
+
+    if kind == "ollama":
+        def _factory_ollama(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> Callable[[str], str]:
+            return ollama.create_provider(session_config=session_config, meta=meta_ref)
+
+        def _stream_ollama(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> Callable[[str], Iterator[str]]:
+            return ollama.create_stream_provider(session_config=session_config, meta=meta_ref)
+
+        return Provider(name, kind, meta, _factory_ollama, _stream_ollama)
 
     def _factory_openai(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> Callable[[str], str]:
         defaults = _provider_defaults(meta_ref)
@@ -339,7 +574,25 @@ def _build_provider(name: str, entry: Dict[str, Any]) -> Optional[Provider]:
             meta=meta_ref,
         )
 
-    return Provider(name, kind, meta, _factory_openai)
+    def _stream_openai(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> Callable[[str], Iterator[str]]:
+        return openai_compat.create_stream_provider(
+            session_config=session_config,
+            name=name,
+            defaults=_provider_defaults(meta_ref),
+            meta=meta_ref,
+        )
+
+    def _tools_openai(session_config: Optional[dict[str, Any]], meta_ref: Dict[str, Any]) -> ToolChat:
+        return openai_compat.create_tool_provider(
+            session_config=session_config,
+            name=name,
+            defaults=_provider_defaults(meta_ref),
+            meta=meta_ref,
+        )
+
+    stream_factory = None if meta.get("streaming") is False else _stream_openai
+    tool_factory = _tool_factory_if_enabled(meta, _tools_openai)
+    return Provider(name, kind, meta, _factory_openai, stream_factory, tool_factory)
 
 
 def _rebuild_registry() -> None:
@@ -391,9 +644,170 @@ def list_models(name: str, session_config: Optional[dict[str, Any]] = None) -> l
     if provider.kind == "openai-compatible":
         defaults = _provider_defaults(provider.meta)
         return openai_compat.list_models(session_config=session_config, name=name, defaults=defaults)
+    if provider.kind == "anthropic":
+        return anthropic.list_models(session_config=session_config, meta=provider.meta)
+    if provider.kind == "ollama":
+        return ollama.list_models(session_config=session_config, meta=provider.meta)
     return []
 
 
+def resolve_provider_name(
+    session_config: Optional[dict[str, Any]] = None, selected: Optional[str] = None
+) -> Optional[str]:
+    """Pick the provider: explicit selection, then session config, then environment."""
+    name = selected or (session_config or {}).get("llm_provider") or os.environ.get(PROVIDER_ENV_VAR) or ""
+    return str(name).strip() or None
+
+
+def fallback_chain(primary: str, session_config: Optional[dict[str, Any]] = None) -> list[str]:
+    """Return ``primary`` followed by configured fallbacks (``llm_fallback`` or env)."""
+    raw = (session_config or {}).get("llm_fallback") or os.environ.get(FALLBACK_ENV_VAR) or ""
+    if isinstance(raw, (list, tuple)):
+        candidates = [str(item) for item in raw]
+    else:
+        candidates = str(raw).split(",")
+    chain = [primary]
+    for candidate in candidates:
+        name = candidate.strip()
+        if name and name not in chain and get_provider(name) is not None:
+            chain.append(name)
+    return chain
+
+
+class FallbackClient:
+    """ask(prompt) callable that moves to the next provider on 429s, 5xx and timeouts.
+
+    Each provider's own retries (``llm.retry``) are used up before moving on.
+
+    Non-retryable errors (bad credentials, malformed requests) are raised from
+    the provider that produced them so configuration problems stay visible.
+    """
+
+    def __init__(self, names: list[str], session_config: Optional[dict[str, Any]] = None) -> None:
+        if not names:
+            raise ValueError("FallbackClient requires at least one provider")
+        self.names = list(names)
+        self.session_config = session_config
+        self.last_usage: Dict[str, Any] = {}
+        self.last_provider: Optional[str] = None
+
+    def __call__(self, prompt: str) -> str:
+        failures: list[str] = []
+        for name in self.names:
+            client = create_client(name, self.session_config)
+            try:
+                answer = client(prompt)
+            except LLMError as e:
+                if not e.retryable:
+                    raise
+                failures.append(f"{name}: {e}")
+                continue
+            self.last_provider = name
+            self.last_usage = dict(getattr(client, "last_usage", {}) or {})
+            return answer
+        raise LLMError("All LLM providers failed: " + "; ".join(failures))
+
+    def stream(self, prompt: str) -> TokenStream:
+        """Stream from the first provider that starts answering.
+
+        Fallback only happens before the first chunk; once text has been shown
+        to the user, a later failure is raised rather than silently restarting.
+        """
+        return TokenStream(self._stream_chunks(prompt), client=self)
+
+    def _stream_chunks(self, prompt: str) -> Iterator[str]:
+        failures: list[str] = []
+        self.last_usage = {}
+        for name in self.names:
+            provider = get_provider(name)
+            if provider is None:
+                continue
+            stream = provider.complete_stream(prompt, session_config=self.session_config)
+            try:
+                first = next(stream)
+            except StopIteration:
+                self.last_provider = name
+                return
+            except LLMError as e:
+                stream.cancel()
+                if not e.retryable:
+                    raise
+                failures.append(f"{name}: {e}")
+                continue
+            self.last_provider = name
+            try:
+                yield first
+                for chunk in stream:
+                    yield chunk
+            finally:
+                stream.cancel()
+                self.last_usage = stream.last_usage
+            return
+        raise LLMError("All LLM providers failed: " + "; ".join(failures))
+
+
+def create_fallback_client(name: str, session_config: Optional[dict[str, Any]] = None) -> Callable[[str], str]:
+    """Like ``create_client`` but wrapped in a ``FallbackClient`` when fallbacks are configured."""
+    chain = fallback_chain(name, session_config)
+    if len(chain) == 1:
+        return create_client(name, session_config)
+    return FallbackClient(chain, session_config)
+
+
+def create_cached_client(name: str, session_config: Optional[dict[str, Any]] = None) -> Callable[[str], str]:
+    """``create_fallback_client`` behind the on-disk response cache (``llm_cache``/``--no-cache``).
+
+    The deterministic mock provider costs nothing and is never cached.
+    """
+    client = create_fallback_client(name, session_config)
+    provider = get_provider(name)
+    cache = Cache.from_config(session_config)
+    if not cache.enabled or provider is None or provider.kind == "mock":
+        return client
+    model = (session_config or {}).get(f"{name.replace('-', '_')}_model") or provider.meta.get("default_model") or ""
+    return CachedClient(client, cache, provider=name, model=str(model), options=request_options(name, session_config))
+
+
+def create_tool_client(name: str, session_config: Optional[dict[str, Any]] = None) -> Optional[ToolChat]:
+    """Native function-calling client for ``name``, or None to fall back to text actions.
+
+    ``llm_tools off`` in the session config forces the text protocol.
+    """
+    if str((session_config or {}).get("llm_tools", "on")).strip().lower() in {"off", "false", "0", "no"}:
+        return None
+    provider = get_provider(name)
+    if provider is None:
+        return None
+    return provider.create_tool_client(session_config)
+
+
+def create_stream(name: str, prompt: str, session_config: Optional[dict[str, Any]] = None) -> TokenStream:
+    """Start a streamed completion against ``name`` and its configured fallbacks."""
+    chain = fallback_chain(name, session_config)
+    if len(chain) == 1:
+        provider = get_provider(name)
+        if provider is None:
+            raise ValueError(f"Unknown provider: {name}")
+        return provider.complete_stream(prompt, session_config=session_config)
+    return FallbackClient(chain, session_config).stream(prompt)
+
+
+def prompt_char_budget(name: Optional[str], model: Optional[str] = None) -> Optional[int]:
+    """Prompt size in characters that fits the context window of ``model`` on the provider, if known.
+
+    A model without its own entry under the provider's ``models`` gets the provider's window.
+    """
+    provider = get_provider(name) if name else None
+    return _prompt_char_budget(_model_meta(provider.meta, model)) if provider else None
+
+
+def session_model(name: Optional[str], session_config: Optional[Dict[str, Any]]) -> Optional[str]:
+    """The model the session asked ``name`` for (``<provider>_model``, set by ``--llm-model``), if any."""
+    if not name:
+        return None
+    return (session_config or {}).get(f"{name.replace('-', '_')}_model") or None
+
+
 def provider_config(name: str) -> Dict[str, Any]:
     data = _load_config(refresh=True)
     providers = data.get("providers", {})
@@ -466,15 +880,24 @@ def get_provider_field(name: str, field: Optional[str] = None) -> Any:
 
 
 __all__ = [
+    "FallbackClient",
     "Provider",
+    "TracingClient",
     "add_provider",
     "config_path",
+    "create_cached_client",
     "create_client",
+    "create_fallback_client",
+    "create_stream",
+    "fallback_chain",
     "get_provider",
     "get_provider_field",
     "list_models",
     "list_providers",
+    "prompt_char_budget",
     "provider_config",
     "reload",
+    "resolve_provider_name",
+    "session_model",
     "set_provider_field",
 ]
@@ -0,0 +1,218 @@
+"""Retry with backoff and a client-side rate limit for LLM calls.
+
+``RetryingClient`` wraps an ask (or tool-chat) callable. Retryable
+``LLMError``s (429, 408, 5xx and transport timeouts) are retried up to
+``RetryPolicy.max_attempts`` with exponential backoff and jitter; a
+``Retry-After`` hint from the server is waited out up to ``max_delay``, and
+the call is retried after the capped wait rather than given up.
+Other errors (401, 400, ...) are raised on the first attempt. Backoff waits
+never outlast the active ``Context``: once it is done its ``Cancelled`` is
+raised instead of the provider's timeout, and no further attempt is made.
+
+``TokenBucket`` spaces requests to one provider: every client created for
+that provider shares the bucket, so the fallback chain and the cache wrapper
+cannot multiply the request rate.
+
+Session keys (and environment fallbacks for CI):
+
+- ``llm_retries`` / ``DBGCOPILOT_LLM_RETRIES``: attempts per call, 1 disables retrying
+- ``llm_retry_delay``: first backoff in seconds, doubled on each retry
+- ``llm_retry_max_delay`` / ``DBGCOPILOT_LLM_RETRY_MAX_DELAY``: cap per wait
+- ``llm_rate_limit`` / ``DBGCOPILOT_LLM_RATE_LIMIT``: requests per minute, 0 or unset for no limit
+- ``llm_rate_burst``: requests allowed back to back before the limit applies
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, Mapping, Optional, Tuple
+import os
+import random
+import threading
+import time
+
+from dbgcopilot.utils.context import current
+
+from .base import LLMError
+
+DEFAULT_MAX_ATTEMPTS = 3
+DEFAULT_BASE_DELAY = 1.0
+DEFAULT_MAX_DELAY = 30.0
+# Fraction of each backoff that is randomised so parallel sessions do not retry in lockstep.
+DEFAULT_JITTER = 0.5
+
+RETRIES_ENV_VAR = "DBGCOPILOT_LLM_RETRIES"
+RETRY_MAX_DELAY_ENV_VAR = "DBGCOPILOT_LLM_RETRY_MAX_DELAY"
+RATE_LIMIT_ENV_VAR = "DBGCOPILOT_LLM_RATE_LIMIT"
+
+
+def _number(config: Mapping[str, Any], key: str, env: str = "") -> Optional[float]:
+    raw = config.get(key)
+    if raw in (None, "") and env:
+        raw = os.environ.get(env)
+    if raw in (None, ""):
+        return None
+    try:
+        value = float(raw)
+    except (TypeError, ValueError):
+        raise ValueError(f"{key} must be a number, got {raw!r}") from None
+    if value < 0:
+        raise ValueError(f"{key} must not be negative")
+    return value
+
+
+@dataclass
+class RetryPolicy:
+    max_attempts: int = DEFAULT_MAX_ATTEMPTS
+    base_delay: float = DEFAULT_BASE_DELAY
+    max_delay: float = DEFAULT_MAX_DELAY
+    jitter: float = DEFAULT_JITTER
+
+    @classmethod
+    def from_config(cls, session_config: Optional[Mapping[str, Any]] = None) -> "RetryPolicy":
+        cfg = session_config or {}
+        policy = cls()
+        attempts = _number(cfg, "llm_retries", RETRIES_ENV_VAR)
+        if attempts is not None:
+            policy.max_attempts = max(int(attempts), 1)
+        delay = _number(cfg, "llm_retry_delay")
+        if delay is not None:
+            policy.base_delay = delay
+        cap = _number(cfg, "llm_retry_max_delay", RETRY_MAX_DELAY_ENV_VAR)
+        if cap is not None:
+            policy.max_delay = cap
+        return policy
+
+    def delay(self, attempt: int, error: LLMError, rng: Callable[[], float] = random.random) -> Optional[float]:
+        """Seconds to wait before attempt ``attempt + 1``, or None to give up now."""
+        if not error.retryable or attempt >= self.max_attempts:
+            return None
+        backoff = min(self.max_delay, self.base_delay * (2 ** (attempt - 1)))
+        backoff *= 1 - self.jitter + self.jitter * rng()
+        hint = error.retry_after
+        if hint is None:
+            return backoff
+        return min(max(hint, backoff), self.max_delay)
+
+
+class TokenBucket:
+    """``rate`` requests per second on average, up to ``burst`` back to back."""
+
+    def __init__(
+        self,
+        rate: float,
+        burst: int = 1,
+        *,
+        clock: Callable[[], float] = time.monotonic,
+        sleep: Callable[[float], None] = time.sleep,
+    ) -> None:
+        if rate <= 0:
+            raise ValueError("rate must be positive")
+        self.rate = rate
+        self.burst = max(int(burst), 1)
+        self._tokens = float(self.burst)
+        self._clock = clock
+        self._sleep = sleep
+        self._stamp = clock()
+        self._lock = threading.Lock()
+
+    def acquire(self) -> float:
+        """Take one token, sleeping until one is available; returns the seconds waited."""
+        with self._lock:
+            now = self._clock()
+            self._tokens = min(self.burst, self._tokens + (now - self._stamp) * self.rate)
+            self._stamp = now
+            self._tokens -= 1
+            wait = -self._tokens / self.rate if self._tokens < 0 else 0.0
+        # Sleep outside the lock; the debt recorded above already reserves this caller's slot.
+        if wait > 0:
+            self._sleep(wait)
+        return wait
+
+
+_limiters: Dict[str, Tuple[Tuple[float, int], TokenBucket]] = {}
+_limiters_lock = threading.Lock()
+
+
+def limiter_for(provider: str, session_config: Optional[Mapping[str, Any]] = None) -> Optional[TokenBucket]:
+    """The shared bucket for ``provider``, or None when no rate limit is configured."""
+    cfg = session_config or {}
+    per_minute = _number(cfg, "llm_rate_limit", RATE_LIMIT_ENV_VAR)
+    if not per_minute:
+        return None
+    burst = int(_number(cfg, "llm_rate_burst") or 1)
+    key = (per_minute, burst)
+    with _limiters_lock:
+        current = _limiters.get(provider)
+        if current is None or current[0] != key:
+            current = (key, TokenBucket(per_minute / 60.0, burst))
+            _limiters[provider] = current
+        return current[1]
+
+
+class RetryingClient:
+    """Callable wrapper that rate-limits and retries the wrapped client."""
+
+    def __init__(
+        self,
+        client: Callable[..., Any],
+        policy: RetryPolicy,
+        *,
+        limiter: Optional[TokenBucket] = None,
+        sleep: Callable[[float], None] = time.sleep,
+        rng: Callable[[], float] = random.random,
+    ) -> None:
+        self.client = client
+        self.policy = policy
+        self.limiter = limiter
+        self.retries = 0
+        self._sleep = sleep
+        self._rng = rng
+
+    def __call__(self, *args: Any, **kwargs: Any) -> Any:
+        attempt = 1
+        while True:
+            if self.limiter is not None:
+                self.limiter.acquire()
+            try:
+                return self.client(*args, **kwargs)
+            except LLMError as e:
+                # A request cut short by the deadline reports the deadline, not a provider timeout.
+                current().check()
+                wait = self.policy.delay(attempt, e, self._rng)
+                if wait is None:
+                    if attempt > 1:
+                        e.args = (f"{e} (after {attempt} attempts)",)
+                    raise
+            self.retries += 1
+            attempt += 1
+            left = current().remaining()
+            self._sleep(wait if left is None else min(wait, left))
+
+    @property
+    def last_usage(self) -> Dict[str, Any]:
+        return dict(getattr(self.client, "last_usage", {}) or {})
+
+    def __getattr__(self, name: str) -> Any:
+        return getattr(self.client, name)
+
+
+def with_retry(client: Callable[..., Any], provider: str, session_config: Optional[Mapping[str, Any]] = None) -> Any:
+    """``client`` behind the configured retry policy and rate limit; unchanged when both are off."""
+    policy = RetryPolicy.from_config(session_config)
+    limiter = limiter_for(provider, session_config)
+    if policy.max_attempts <= 1 and limiter is None:
+        return client
+    return RetryingClient(client, policy, limiter=limiter)
+
+
+__all__ = [
+    "DEFAULT_MAX_ATTEMPTS",
+    "RATE_LIMIT_ENV_VAR",
+    "RETRIES_ENV_VAR",
+    "RETRY_MAX_DELAY_ENV_VAR",
+    "RetryPolicy",
+    "RetryingClient",
+    "TokenBucket",
+    "limiter_for",
+    "with_retry",
+]
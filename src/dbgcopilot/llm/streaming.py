@@ -0,0 +1,147 @@
+"""Incremental completion streams.
+
+Every provider returns a ``TokenStream`` from ``complete_stream`` whether or
+not its API streams: non-streaming providers produce a single chunk once the
+blocking call finishes, so callers never branch on provider capabilities.
+A stream is rate-limited and retried like a blocking call until its first
+chunk arrives (``started``); once text has been shown it is not restarted.
+"""
+from __future__ import annotations
+
+from typing import Any, Callable, Dict, Iterable, Iterator, List, Optional, Tuple
+import json
+
+
+class TokenStream:
+    """Iterator over completion chunks that also assembles the full response.
+
+    ``cancel()`` closes the underlying generator, which lets provider
+    generators release their HTTP response in a ``finally`` block. Chunks
+    received before cancellation remain available through ``text``.
+    """
+
+    def __init__(self, chunks: Iterator[str], *, provider: str = "", client: Any = None) -> None:
+        self._chunks = chunks
+        # The blocking client behind a one-chunk stream, whose token counts the answer reports.
+        self._client = client
+        self._parts: List[str] = []
+        self.provider = provider
+        self.cancelled = False
+        self.done = False
+
+    def __iter__(self) -> "TokenStream":
+        return self
+
+    def __next__(self) -> str:
+        if self.cancelled or self.done:
+            raise StopIteration
+        try:
+            chunk = next(self._chunks)
+        except StopIteration:
+            self.done = True
+            raise
+        self._parts.append(chunk)
+        return chunk
+
+    @property
+    def text(self) -> str:
+        return "".join(self._parts)
+
+    @property
+    def last_usage(self) -> Dict[str, Any]:
+        """Token counts the provider reported for this answer; streaming APIs report none here."""
+        return dict(getattr(self._client, "last_usage", {}) or {})
+
+    def cancel(self) -> None:
+        if self.cancelled or self.done:
+            return
+        self.cancelled = True
+        close = getattr(self._chunks, "close", None)
+        if close is not None:
+            try:
+                close()
+            except Exception:
+                pass
+
+    def collect(self) -> str:
+        for _ in self:
+            pass
+        return self.text
+
+
+def blocking_chunks(ask: Callable[[str], str], prompt: str) -> Iterator[str]:
+    """Adapt a blocking ask(prompt) into a one-chunk generator (called lazily)."""
+    answer = ask(prompt)
+    if answer:
+        yield answer
+
+
+def started(stream: Callable[[str], Iterator[str]]) -> Callable[[str], Tuple[List[str], Iterator[str]]]:
+    """Wrap stream(prompt) into a call that returns once the first chunk arrived, with the rest.
+
+    An error before the first chunk is raised by that call, so ``with_retry``
+    can wait and repeat it; ``resumed_chunks`` then yields the whole answer.
+    """
+
+    def start(prompt: str) -> Tuple[List[str], Iterator[str]]:
+        chunks = stream(prompt)
+        for chunk in chunks:
+            return [chunk], chunks
+        return [], chunks
+
+    return start
+
+
+def resumed_chunks(start: Callable[[str], Tuple[List[str], Iterator[str]]], prompt: str) -> Iterator[str]:
+    head, rest = start(prompt)
+    try:
+        yield from head
+        yield from rest
+    finally:
+        close = getattr(rest, "close", None)
+        if close is not None:
+            close()
+
+
+def iter_sse_data(lines: Iterable[object]) -> Iterator[str]:
+    """Yield ``data:`` payloads from a server-sent-events line iterator."""
+    for raw in lines:
+        if raw is None:
+            continue
+        line = raw.decode("utf-8", "replace") if isinstance(raw, bytes) else str(raw)
+        if not line.startswith("data:"):
+            continue
+        data = line[5:].strip()
+        if data == "[DONE]":
+            return
+        if data:
+            yield data
+
+
+def iter_json_events(lines: Iterable[object]) -> Iterator[dict]:
+    for data in iter_sse_data(lines):
+        try:
+            event = json.loads(data)
+        except ValueError:
+            continue
+        if isinstance(event, dict):
+            yield event
+
+
+def openai_delta(event: dict) -> Optional[str]:
+    """Text delta from an OpenAI-style ``chat.completion.chunk`` event."""
+    try:
+        return event["choices"][0]["delta"].get("content")
+    except (KeyError, IndexError, TypeError, AttributeError):
+        return None
+
+
+__all__ = [
+    "TokenStream",
+    "blocking_chunks",
+    "iter_json_events",
+    "iter_sse_data",
+    "openai_delta",
+    "resumed_chunks",
+    "started",
+]
@@ -0,0 +1,319 @@
+"""Tool descriptors and function-calling message helpers.
+
+A ``Tool`` pairs a name and a JSON schema for its arguments with a Python
+handler. ``ToolRegistry.invoke`` validates a model's ``ToolCall`` against the
+schema before running the handler and never raises: unknown tools, bad
+arguments and handler failures come back as a ``ToolResult`` whose content is
+a JSON error object (``{"error": "unknown_tool", "available": [...]}``) the
+model can read and recover from. A handler raises ``ToolUnsupported`` when
+the debugger has no way to do what the tool asks; that comes back as an
+``unsupported`` error rather than a failure.
+
+Conversations use the OpenAI chat shape (``role`` system/user/assistant/tool,
+assistant ``tool_calls``); ``to_anthropic_messages`` converts them for the
+Messages API. Only the JSON-schema subset tools need is checked: ``type``,
+``properties``, ``required``, ``enum`` and ``additionalProperties``.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, Iterable, List, Optional, Tuple
+import json
+
+# Tool calls allowed per user turn before the loop stops asking the model.
+DEFAULT_MAX_TOOL_CALLS = 6
+
+ERROR_UNKNOWN_TOOL = "unknown_tool"
+ERROR_INVALID_ARGUMENTS = "invalid_arguments"
+ERROR_TOOL_FAILED = "tool_failed"
+ERROR_TOOL_LIMIT = "tool_limit"
+ERROR_UNSUPPORTED = "unsupported"
+
+
+class ToolUnsupported(Exception):
+    """The tool cannot run against this debugger."""
+
+_JSON_TYPES: Dict[str, Tuple[type, ...]] = {
+    "string": (str,),
+    "integer": (int,),
+    "number": (int, float),
+    "boolean": (bool,),
+    "object": (dict,),
+    "array": (list,),
+    "null": (type(None),),
+}
+
+
+@dataclass
+class Tool:
+    name: str
+    description: str
+    parameters: Dict[str, Any]
+    handler: Callable[[Dict[str, Any]], str]
+
+    def openai_spec(self) -> Dict[str, Any]:
+        return {
+            "type": "function",
+            "function": {"name": self.name, "description": self.description, "parameters": self.parameters},
+        }
+
+    def anthropic_spec(self) -> Dict[str, Any]:
+        return {"name": self.name, "description": self.description, "input_schema": self.parameters}
+
+
+def _new_args() -> Dict[str, Any]:
+    return {}
+
+
+@dataclass
+class ToolCall:
+    id: str
+    name: str
+    arguments: Dict[str, Any] = field(default_factory=_new_args)
+    # Set when the model's arguments could not be decoded; ``arguments`` is then empty.
+    error: str = ""
+
+    def describe(self) -> str:
+        args = ", ".join(f"{k}={v!r}" for k, v in self.arguments.items())
+        return f"{self.name}({args})"
+
+
+@dataclass
+class ToolResult:
+    call_id: str
+    name: str
+    content: str
+    is_error: bool = False
+
+
+def _new_call_list() -> List[ToolCall]:
+    return []
+
+
+def _new_usage() -> Dict[str, Any]:
+    return {}
+
+
+@dataclass
+class ToolReply:
+    """One model response: prose, tool calls, or both."""
+
+    text: str = ""
+    calls: List[ToolCall] = field(default_factory=_new_call_list)
+    usage: Dict[str, Any] = field(default_factory=_new_usage)
+
+
+def validate_arguments(schema: Dict[str, Any], value: Any, path: str = "") -> List[str]:
+    """Human-readable problems with ``value`` against ``schema``; empty when it conforms."""
+    where = path or "arguments"
+    expected = schema.get("type")
+    if expected:
+        names = expected if isinstance(expected, list) else [expected]
+        types = tuple(t for n in names for t in _JSON_TYPES.get(n, ()))
+        # bool is an int subclass in Python but not a JSON number.
+        is_bool = isinstance(value, bool) and "boolean" not in names
+        if types and (not isinstance(value, types) or is_bool):
+            return [f"{where}: expected {' or '.join(names)}, got {_json_type(value)}"]
+    if "enum" in schema and value not in schema["enum"]:
+        return [f"{where}: must be one of {', '.join(json.dumps(v) for v in schema['enum'])}"]
+    problems: List[str] = []
+    if isinstance(value, dict):
+        props: Dict[str, Any] = schema.get("properties") or {}
+        for name in schema.get("required") or []:
+            if name not in value:
+                problems.append(f"{where}: missing required property '{name}'")
+        for name, item in value.items():
+            sub = f"{path}.{name}" if path else name
+            if name in props:
+                problems.extend(validate_arguments(props[name], item, sub))
+            elif schema.get("additionalProperties") is False:
+                problems.append(f"{where}: unexpected property '{name}'")
+    return problems
+
+
+def _json_type(value: Any) -> str:
+    if isinstance(value, bool):
+        return "boolean"
+    for name, types in _JSON_TYPES.items():
+        if isinstance(value, types):
+            return name
+    return type(value).__name__
+
+
+def _error(kind: str, message: str, **extra: Any) -> str:
+    return json.dumps({"error": kind, "message": message, **extra}, ensure_ascii=False)
+
+
+class ToolRegistry:
+    """Named tools offered to the model, in registration order."""
+
+    def __init__(self, tools: Iterable[Tool] = ()) -> None:
+        self._tools: Dict[str, Tool] = {}
+        for tool in tools:
+            self.register(tool)
+
+    def register(self, tool: Tool) -> None:
+        if tool.name in self._tools:
+            raise ValueError(f"tool '{tool.name}' is already registered")
+        self._tools[tool.name] = tool
+
+    def get(self, name: str) -> Optional[Tool]:
+        return self._tools.get(name)
+
+    def names(self) -> List[str]:
+        return list(self._tools)
+
+    def tools(self) -> List[Tool]:
+        return list(self._tools.values())
+
+    def __len__(self) -> int:
+        return len(self._tools)
+
+    def invoke(self, call: ToolCall) -> ToolResult:
+        """Validate and run ``call``; every failure is a structured error result."""
+        available = self.names()
+        tool = self._tools.get(call.name)
+        if tool is None:
+            message = f"unknown tool '{call.name}'. Available tools: {', '.join(available)}."
+            content = _error(ERROR_UNKNOWN_TOOL, message, available=available)
+            return ToolResult(call.id, call.name, content, is_error=True)
+        problems = [call.error] if call.error else validate_arguments(tool.parameters, call.arguments)
+        if problems:
+            message = f"invalid arguments for {call.name}: {'; '.join(problems)}"
+            content = _error(ERROR_INVALID_ARGUMENTS, message, details=problems, parameters=tool.parameters)
+            return ToolResult(call.id, call.name, content, is_error=True)
+        try:
+            return ToolResult(call.id, call.name, tool.handler(dict(call.arguments)))
+        except ToolUnsupported as e:
+            return ToolResult(call.id, call.name, _error(ERROR_UNSUPPORTED, str(e)), is_error=True)
+        except Exception as e:
+            return ToolResult(call.id, call.name, _error(ERROR_TOOL_FAILED, f"{call.name} failed: {e}"), is_error=True)
+
+
+# ----------------------------------------------------------------------
+# Provider message shapes.
+
+
+def decode_arguments(raw: Any) -> Tuple[Dict[str, Any], str]:
+    """Tool arguments as a dict plus a decode error ('' when fine); providers send JSON text or objects."""
+    if raw in (None, ""):
+        return {}, ""
+    if isinstance(raw, dict):
+        return dict(raw), ""
+    try:
+        value = json.loads(raw)
+    except (TypeError, ValueError) as e:
+        return {}, f"arguments are not valid JSON: {e}"
+    if not isinstance(value, dict):
+        return {}, f"arguments must be a JSON object, got {_json_type(value)}"
+    return value, ""
+
+
+def assistant_message(reply: ToolReply) -> Dict[str, Any]:
+    """The assistant turn that requested ``reply.calls``, in OpenAI chat shape."""
+    message: Dict[str, Any] = {"role": "assistant", "content": reply.text or None}
+    if reply.calls:
+        message["tool_calls"] = [
+            {
+                "id": c.id,
+                "type": "function",
+                "function": {"name": c.name, "arguments": json.dumps(c.arguments, ensure_ascii=False)},
+            }
+            for c in reply.calls
+        ]
+    return message
+
+
+def tool_message(result: ToolResult) -> Dict[str, Any]:
+    return {"role": "tool", "tool_call_id": result.call_id, "name": result.name, "content": result.content}
+
+
+def parse_openai_message(message: Dict[str, Any]) -> ToolReply:
+    """Text and tool calls from an OpenAI-style ``choices[0].message``."""
+    calls: List[ToolCall] = []
+    for i, raw in enumerate(message.get("tool_calls") or []):
+        fn = raw.get("function") or {}
+        args, error = decode_arguments(fn.get("arguments"))
+        calls.append(ToolCall(str(raw.get("id") or f"call_{i}"), str(fn.get("name") or ""), args, error))
+    return ToolReply(str(message.get("content") or ""), calls)
+
+
+def to_anthropic_messages(messages: List[Dict[str, Any]]) -> Tuple[str, List[Dict[str, Any]]]:
+    """(system, messages) for the Messages API; tool results become user ``tool_result`` blocks."""
+    system: List[str] = []
+    out: List[Dict[str, Any]] = []
+
+    def add(role: str, blocks: List[Dict[str, Any]]) -> None:
+        # The Messages API wants user and assistant turns to alternate.
+        if out and out[-1]["role"] == role:
+            out[-1]["content"].extend(blocks)
+        else:
+            out.append({"role": role, "content": blocks})
+
+    for m in messages:
+        role = m.get("role")
+        if role == "system":
+            system.append(str(m.get("content") or ""))
+        elif role == "tool":
+            content = str(m.get("content") or "")
+            add("user", [{"type": "tool_result", "tool_use_id": m.get("tool_call_id"), "content": content}])
+        elif role == "assistant":
+            blocks: List[Dict[str, Any]] = [{"type": "text", "text": m["content"]}] if m.get("content") else []
+            for c in m.get("tool_calls") or []:
+                fn = c.get("function") or {}
+                args, _ = decode_arguments(fn.get("arguments"))
+                blocks.append({"type": "tool_use", "id": c.get("id"), "name": fn.get("name"), "input": args})
+            add("assistant", blocks)
+        else:
+            add("user", [{"type": "text", "text": str(m.get("content") or "")}])
+    return "\n\n".join(s for s in system if s), out
+
+
+def parse_anthropic_content(blocks: List[Dict[str, Any]]) -> ToolReply:
+    text = "".join(b.get("text", "") for b in blocks if isinstance(b, dict) and b.get("type") == "text")
+    calls = [
+        ToolCall(str(b.get("id") or ""), str(b.get("name") or ""), *decode_arguments(b.get("input")))
+        for b in blocks
+        if isinstance(b, dict) and b.get("type") == "tool_use"
+    ]
+    return ToolReply(text, calls)
+
+
+def render_messages(messages: List[Dict[str, Any]]) -> str:
+    """Plain-text transcript of a tool conversation, for session recordings."""
+    lines: List[str] = []
+    for m in messages:
+        content = m.get("content") or ""
+        if m.get("role") == "tool":
+            lines.append(f"tool {m.get('name')} [{m.get('tool_call_id')}]: {content}")
+            continue
+        calls = ", ".join(
+            f"{(c.get('function') or {}).get('name')}({(c.get('function') or {}).get('arguments')})"
+            for c in m.get("tool_calls") or []
+        )
+        lines.append(f"{m.get('role')}: {content}" + (f" [calls {calls}]" if calls else ""))
+    return "\n".join(lines)
+
+
+__all__ = [
+    "DEFAULT_MAX_TOOL_CALLS",
+    "ERROR_INVALID_ARGUMENTS",
+    "ERROR_TOOL_FAILED",
+    "ERROR_TOOL_LIMIT",
+    "ERROR_UNKNOWN_TOOL",
+    "ERROR_UNSUPPORTED",
+    "Tool",
+    "ToolCall",
+    "ToolRegistry",
+    "ToolReply",
+    "ToolResult",
+    "ToolUnsupported",
+    "assistant_message",
+    "decode_arguments",
+    "parse_anthropic_content",
+    "parse_openai_message",
+    "render_messages",
+    "to_anthropic_messages",
+    "tool_message",
+    "validate_arguments",
+]
@@ -0,0 +1,155 @@
+"""Fit a prompt into the model's context window by trimming it in stages.
+
+A hang in a server with thousands of goroutines produces a dump (and a
+conversation full of earlier dumps) far larger than most context windows.
+Rather than let the provider reject the request, ``Budget.fit`` estimates
+the prompt's token count and, while it is over ``max_tokens``, applies
+progressively lossier steps:
+
+1. collapse goroutines with identical stacks into groups;
+2. list only the non-runtime frames of each group;
+3. drop the oldest conversation entries;
+4. as a last resort, cut the middle out of the debugger output.
+
+Every step that changed something is recorded in ``Budget.trimmed`` and
+passed to the compose callback as a note, so the model (and the user) know
+the analysis saw a reduced context.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Callable, List, Optional, Sequence, Tuple
+
+from dbgcopilot.analyze.goroutines import looks_like_goroutine_dump, parse_goroutine_dump
+from dbgcopilot.analyze.grouping import condense_goroutine_output, group_goroutines
+from dbgcopilot.llm import providers
+from dbgcopilot.llm.base import CHARS_PER_TOKEN
+from dbgcopilot.utils.io import head_tail_truncate
+
+# Debugger output is never cut below this many characters.
+MIN_OUTPUT_CHARS = 200
+
+# What head_tail_truncate puts in place of the cut.
+_CUT_MARKER = "\n... [truncated] ...\n"
+
+# compose(outputs, history, note) -> prompt
+Compose = Callable[[List[str], List[str], str], str]
+
+
+def estimate_tokens(text: str) -> int:
+    """Rough token count: ``CHARS_PER_TOKEN`` characters per token, rounded up."""
+    return -(-len(text or "") // CHARS_PER_TOKEN)
+
+
+def _new_note_list() -> List[str]:
+    return []
+
+
+@dataclass
+class Budget:
+    """Token budget for one prompt; ``states``/``contains`` are the session's goroutine filter."""
+
+    max_tokens: int
+    states: Optional[Sequence[str]] = None
+    contains: str = ""
+    trimmed: List[str] = field(default_factory=_new_note_list)
+
+    @classmethod
+    def for_provider(cls, name: Optional[str], model: Optional[str] = None, **kwargs) -> Optional["Budget"]:
+        """Budget for the model's context window on the provider less its reserved output, or None if unknown.
+
+        ``model`` is the one the session asked for; without it (or an entry
+        for it in the provider's ``models``) the provider's window applies.
+        """
+        chars = providers.prompt_char_budget(name, model)
+        if not chars:
+            return None
+        return cls(chars // CHARS_PER_TOKEN, **kwargs)
+
+    def fits(self, text: str) -> bool:
+        return estimate_tokens(text) <= self.max_tokens
+
+    def note(self) -> str:
+        if not self.trimmed:
+            return ""
+        return f"Context trimmed to fit the {self.max_tokens}-token window: " + "; ".join(self.trimmed) + "."
+
+    def fit(self, compose: Compose, outputs: Sequence[str], history: Sequence[str] = ()) -> str:
+        """Return ``compose(outputs, history, note)`` trimmed until it fits (or nothing is left to trim).
+
+        ``outputs`` are debugger outputs shown in full; ``history`` is the
+        conversation, oldest entry first.
+        """
+        self.trimmed = []
+        raw_outputs, raw_history = list(outputs), list(history)
+        outputs, history = list(raw_outputs), list(raw_history)
+        prompt = compose(outputs, history, "")
+        if self.fits(prompt):
+            return prompt
+
+        for user_only in (False, True):
+            texts = [self._collapse(t, user_only) for t in raw_outputs + raw_history]
+            if [t for t, _, _ in texts] == outputs + history:
+                continue
+            outputs = [t for t, _, _ in texts[: len(raw_outputs)]]
+            history = [t for t, _, _ in texts[len(raw_outputs) :]]
+            dumps = [(n, groups) for _, n, groups in texts if n]
+            if user_only:
+                self.trimmed.append("dropped runtime and standard-library frames from goroutine stacks")
+            else:
+                goroutines, groups = max(dumps)
+                self.trimmed.append(
+                    f"collapsed identical goroutine stacks (largest dump: {goroutines} goroutines in {groups} group(s))"
+                )
+            prompt = compose(outputs, history, self.note())
+            if self.fits(prompt):
+                return prompt
+
+        total = len(history)
+        if history and not self.fits(prompt):
+            self.trimmed.append("")
+        dropped = 0
+        while history and not self.fits(prompt):
+            history.pop(0)
+            dropped += 1
+            self.trimmed[-1] = f"dropped the {dropped} oldest of {total} conversation entries"
+            prompt = compose(outputs, history, self.note())
+        if self.fits(prompt):
+            return prompt
+
+        # Size the cut with its own note in the prompt, so the note does not push it back over.
+        self.trimmed.append("cut the middle out of the debugger output")
+        prompt = compose(outputs, history, self.note())
+        self.trimmed.pop()
+        overflow = (estimate_tokens(prompt) - self.max_tokens) * CHARS_PER_TOKEN
+        cut = False
+        for index in sorted(range(len(outputs)), key=lambda i: -len(outputs[i])):
+            if overflow <= 0:
+                break
+            text = outputs[index]
+            if len(text) <= MIN_OUTPUT_CHARS:
+                continue
+            keep = max(len(text) - overflow - len(_CUT_MARKER), MIN_OUTPUT_CHARS)
+            outputs[index] = head_tail_truncate(text, keep)
+            overflow -= len(text) - len(outputs[index])
+            cut = True
+        if cut:
+            self.trimmed.append("cut the middle out of the debugger output")
+            prompt = compose(outputs, history, self.note())
+        return prompt
+
+    def _collapse(self, text: str, user_only: bool) -> Tuple[str, int, int]:
+        """``text`` with its goroutine dump grouped, plus the goroutine and group counts (0, 0 if none)."""
+        if not looks_like_goroutine_dump(text):
+            return text, 0, 0
+        dump = parse_goroutine_dump(text)
+        if len(dump) < 2:
+            return text, 0, 0
+        groups = group_goroutines(dump, states=self.states, contains=self.contains)
+        condensed = condense_goroutine_output(
+            text, states=self.states, contains=self.contains, threshold=1, user_only=user_only
+        )
+        return condensed, len(dump), len(groups)
+
+
+__all__ = ["Budget", "MIN_OUTPUT_CHARS", "estimate_tokens"]
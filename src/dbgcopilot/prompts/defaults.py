@@ -29,4 +29,69 @@ DEFAULT_PROMPT_CONFIG = {
         "Never say 'I can't run executables directly' or similar disclaimers.",
     ],
     "language_hint_zh": "Please answer in Simplified Chinese (中文).\n",
+    "post_mortem_note": (
+        "Post-mortem mode: {debugger} is inspecting a core dump ({core}); there is no live process.\n"
+        "Do not suggest continue, step, next, run or restart. Use stack traces, goroutine/thread listings, "
+        "frame selection and variable printing to explain the crash.\n"
+    ),
+    "watchpoint_narration": (
+        "A watchpoint fired in {debugger}:\n{event}\n"
+        "Narrate what changed the watched value: which code wrote (or read) it, whether the old -> new "
+        "transition looks intended, and what to inspect next.\n"
+    ),
+    "interactive_preamble": (
+        "You are a debugging copilot in an interactive session on a live {debugger} process that stays "
+        "paused between questions.\n"
+        "To act on the debugger, reply with only one action block and nothing else, for example:\n"
+        '<action>{{"tool": "set_breakpoint", "location": "main.go:42", "condition": "i > 3"}}</action>\n'
+        "Tools: set_breakpoint(location, condition?, hitcount?), continue(), next() to step over a call, "
+        "step() into it, step_out() to its caller, stacktrace(goroutine?), "
+        "list_goroutines(), read_variable(expr), eval_in_frame(expr, frame, goroutine?) to evaluate in a "
+        "caller's scope, command(text) for any other single {debugger} command.\n"
+        "The result comes back as a Debugger message. When you can answer the user, reply in plain text "
+        "without an action. Quote exact values from Debugger messages; never invent output.\n"
+    ),
+    "interactive_tools_preamble": (
+        "You are a debugging copilot in an interactive session on a live {debugger} process that stays "
+        "paused between questions.\n"
+        "Use the provided tools to inspect or drive the debugger, one step at a time. If a tool returns an "
+        "error object, fix the call or pick another tool. When you can answer the user, reply in plain text. "
+        "Quote exact values from tool results; never invent output.\n"
+    ),
+    "dap_stop_analysis": (
+        "The program under {debugger} stopped in an editor debug session:\n{event}\n"
+        "Deterministic findings:\n{findings}\n"
+        "Explain the most likely root cause in a few sentences, name the frame or lock responsible, "
+        "and suggest what to inspect next in the editor. Quote exact values; never invent output.\n"
+    ),
+}
+
+# Analysis sections per issue type (see dbgcopilot.prompts.templates). A paragraph whose
+# placeholders are all empty is left out, so optional sections need no conditionals.
+DEFAULT_ANALYSIS_TEMPLATES = {
+    "deadlock": (
+        "The {debugger} output below shows a deadlock.\n\n"
+        "Stack dump from `{command}`:\n{stack}\n\n"
+        "Deterministic analysis:\n{findings}\n\n"
+        "Program output before the stop (oldest first):\n{program_output}\n\n"
+        "Source around the frames:\n{source_context}\n\n"
+        "Name every goroutine in the cycle or wait, the lock or channel it holds and the one it waits on, "
+        "and the line that acquired each. Propose the smallest change to the acquisition order (or the "
+        "missing send, receive or close) that breaks it."
+    ),
+    "panic": (
+        "The {debugger} output below shows a panic or fatal runtime error.\n\n"
+        "Stack dump from `{command}`:\n{stack}\n\n"
+        "Deterministic analysis:\n{findings}\n\n"
+        "Program output before the stop (oldest first):\n{program_output}\n\n"
+        "Source around the frames:\n{source_context}\n\n"
+        "Explain which value was wrong at the panicking line and where it came from, quoting the frame "
+        "that introduced it, then suggest a guard or fix at that origin rather than at the crash site."
+    ),
+    "generic": (
+        "Debugger output:\n{stack}\n\n"
+        "Deterministic analysis of this output:\n{findings}\n\n"
+        "Program output before the stop (oldest first):\n{program_output}\n\n"
+        "Source around the frames:\n{source_context}"
+    ),
 }
@@ -0,0 +1,57 @@
+"""Prompt section for the goroutines the user pinned (``dbgcopilot.analyze.pins``).
+
+Both prompt builders put the section next to, not inside, the debugger
+output, so ``Budget.fit`` can group, strip and cut everything else while
+the pinned goroutines keep every frame. Their goroutines come from the last
+output when it is a goroutine dump, otherwise from the debugger. On Delve
+the locals of each pinned frame are loaded with ``DEEP_LOAD`` on top.
+"""
+from __future__ import annotations
+
+from typing import Any, Dict, Mapping, Optional
+
+from dbgcopilot.analyze.goroutines import GoroutineDump, looks_like_goroutine_dump, parse_goroutine_dump
+from dbgcopilot.analyze.pins import format_pinned, pinned_goroutines, pins_from_config
+from dbgcopilot.debugger.base import DEEP_LOAD, DebuggerError
+from dbgcopilot.debugger.pretty import pretty_print
+
+
+def pinned_section(config: Optional[Mapping[str, str]], *, output: str = "", debugger: Any = None) -> str:
+    """The pinned goroutines in full, with deep-loaded locals where the debugger can; "" without pins."""
+    try:
+        pins = pins_from_config(config)
+    except ValueError:
+        return ""
+    if not pins:
+        return ""
+    dump = _latest_dump(output, debugger)
+    if dump is None:
+        return "Pinned goroutines: " + ", ".join(p.describe() for p in pins) + " (no goroutine dump yet)."
+    pinned = pinned_goroutines(dump, pins)
+    frame_locals = getattr(debugger, "frame_locals", None)
+    loaded: Dict[int, str] = {}
+    if callable(frame_locals):
+        for item in pinned:
+            try:
+                variables = frame_locals(item.goroutine.id, item.frame, DEEP_LOAD)
+            except DebuggerError as e:
+                loaded[item.goroutine.id] = f"(unavailable: {e})"
+                continue
+            loaded[item.goroutine.id] = "\n".join(f"{v.name} = {pretty_print(v)}" for v in variables)
+    return format_pinned(dump, pinned, pins, locals_text=loaded)
+
+
+def _latest_dump(output: str, debugger: Any) -> Optional[GoroutineDump]:
+    if looks_like_goroutine_dump(output):
+        return parse_goroutine_dump(output)
+    goroutines = getattr(debugger, "goroutines", None)
+    if not callable(goroutines):
+        return None
+    try:
+        dump = goroutines()
+    except DebuggerError:
+        return None
+    return dump if len(dump) else None
+
+
+__all__ = ["pinned_section"]
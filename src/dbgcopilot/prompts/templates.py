@@ -0,0 +1,205 @@
+"""Per-issue analysis templates used by the prompt builders.
+
+The part of a prompt that presents the stack dump, the source around its
+frames and the program's own output is rendered from a template picked by
+the kind of bug in the output: ``deadlock``, ``panic`` or ``generic``. A
+team can override any of them, to change the phrasing or add domain hints
+("this service uses an actor model"), by putting ``<issue>.txt`` files in a
+directory named by ``prompt_templates_dir`` in the session config,
+``$DBGCOPILOT_PROMPT_TEMPLATES`` or dbgagent's ``--prompt-templates``.
+Issues without a file keep the embedded default from
+``DEFAULT_ANALYSIS_TEMPLATES``.
+
+Templates are ``str.format`` text (``{{`` and ``}}`` are literal braces)
+that may use only the placeholders in ``TEMPLATE_FIELDS``. A paragraph
+(lines up to a blank line) whose placeholders all render empty is left out,
+so sections that do not apply need no conditionals. Every file is checked
+when it is loaded: an unknown placeholder, a stray brace or a file that is
+not named after an issue raises ``PromptTemplateError`` instead of producing
+a garbled prompt later.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from pathlib import Path
+from string import Formatter
+from typing import Dict, List, Mapping, Optional, Tuple
+import os
+import re
+
+from dbgcopilot.analyze import report_sections, runtime_deadlock_fired, stack_context_for_output
+from dbgcopilot.analyze.result import ISSUE_DEADLOCK, ISSUE_FATAL, ISSUE_PANIC, build_result
+from dbgcopilot.prompts.defaults import DEFAULT_ANALYSIS_TEMPLATES
+
+ISSUE_GENERIC = "generic"
+ISSUE_TEMPLATES = (ISSUE_DEADLOCK, ISSUE_PANIC, ISSUE_GENERIC)
+TEMPLATE_SUFFIX = ".txt"
+TEMPLATE_DIR_ENV = "DBGCOPILOT_PROMPT_TEMPLATES"
+TEMPLATE_DIR_KEY = "prompt_templates_dir"
+
+TEMPLATE_FIELDS = {
+    "debugger": "debugger name (dlv, gdb, lldb, ...)",
+    "command": "the debugger command that produced the output",
+    "stack": "its output: the stack or goroutine dump, condensed and redacted",
+    "findings": "panic, lock-cycle and channel reports from the deterministic analyzers",
+    "source_context": "the stack with source lines around each frame",
+    "program_output": "the target's last lines of stdout/stderr, with arrival times",
+}
+
+_CONTEXT_HEADING = "Stack with source context:\n"
+_PARAGRAPH_BREAK_RE = re.compile(r"\n[ \t]*\n")
+
+
+class PromptTemplateError(ValueError):
+    """A template override that cannot be rendered (bad placeholder, brace or file name)."""
+
+
+def _new_paragraphs() -> List[Tuple[str, List[str]]]:
+    return []
+
+
+def _split_paragraphs(text: str) -> List[Tuple[int, str]]:
+    """(first line number, text) of each paragraph."""
+    paragraphs: List[Tuple[int, str]] = []
+    start = 0
+    for match in list(_PARAGRAPH_BREAK_RE.finditer(text)) + [None]:
+        end = match.start() if match is not None else len(text)
+        chunk = text[start:end]
+        if chunk.strip():
+            paragraphs.append((text.count("\n", 0, start) + 1, chunk.strip("\n")))
+        if match is not None:
+            start = match.end()
+    return paragraphs
+
+
+def _placeholders(where: str, paragraph: str) -> List[str]:
+    try:
+        parsed = list(Formatter().parse(paragraph))
+    except ValueError as exc:
+        raise PromptTemplateError(f"{where}: {exc} (write {{{{ or }}}} for a literal brace)") from None
+    names: List[str] = []
+    for _, name, _, _ in parsed:
+        if name is None:
+            continue
+        if not name or name.isdigit():
+            raise PromptTemplateError(f"{where}: positional placeholder {{{name}}}; name one of TEMPLATE_FIELDS")
+        if name not in TEMPLATE_FIELDS:
+            known = ", ".join(TEMPLATE_FIELDS)
+            raise PromptTemplateError(f"{where}: unknown placeholder {{{name}}} (available: {known})")
+        names.append(name)
+    try:
+        paragraph.format(**{key: "" for key in TEMPLATE_FIELDS})
+    except (ValueError, TypeError) as exc:
+        raise PromptTemplateError(f"{where}: {exc}") from None
+    return names
+
+
+@dataclass
+class AnalysisTemplate:
+    issue: str
+    text: str
+    # "defaults" or the file the template was read from.
+    source: str = "defaults"
+    paragraphs: List[Tuple[str, List[str]]] = field(default_factory=_new_paragraphs)
+
+    @classmethod
+    def parse(cls, issue: str, text: str, source: str = "defaults") -> "AnalysisTemplate":
+        """Check every placeholder and brace now, so rendering cannot fail later."""
+        paragraphs = [
+            (chunk, _placeholders(f"{source} line {line}", chunk)) for line, chunk in _split_paragraphs(text)
+        ]
+        if not paragraphs:
+            raise PromptTemplateError(f"{source}: template for {issue} is empty")
+        return cls(issue=issue, text=text, source=source, paragraphs=paragraphs)
+
+    def render(self, **values: str) -> str:
+        full = {key: (values.get(key) or "").rstrip() for key in TEMPLATE_FIELDS}
+        kept = [
+            chunk.format(**full)
+            for chunk, names in self.paragraphs
+            if not names or any(full[name] for name in names)
+        ]
+        return "\n\n".join(kept)
+
+
+def _new_template_map() -> Dict[str, AnalysisTemplate]:
+    return {}
+
+
+@dataclass
+class AnalysisTemplates:
+    templates: Dict[str, AnalysisTemplate] = field(default_factory=_new_template_map)
+    # Override directory, "" when only the embedded defaults are in use.
+    directory: str = ""
+
+    @classmethod
+    def defaults(cls) -> "AnalysisTemplates":
+        return cls({issue: AnalysisTemplate.parse(issue, text) for issue, text in DEFAULT_ANALYSIS_TEMPLATES.items()})
+
+    @classmethod
+    def load(cls, directory: Optional[str]) -> "AnalysisTemplates":
+        """Embedded defaults, overridden by ``<issue>.txt`` files in ``directory`` when given."""
+        loaded = cls.defaults()
+        if not directory:
+            return loaded
+        root = Path(directory).expanduser()
+        if not root.is_dir():
+            raise PromptTemplateError(f"Prompt template directory {root} does not exist")
+        loaded.directory = str(root)
+        for path in sorted(root.glob(f"*{TEMPLATE_SUFFIX}")):
+            issue = path.stem
+            if issue not in ISSUE_TEMPLATES:
+                expected = ", ".join(f"{name}{TEMPLATE_SUFFIX}" for name in ISSUE_TEMPLATES)
+                raise PromptTemplateError(f"{path}: not a known issue type (expected one of {expected})")
+            loaded.templates[issue] = AnalysisTemplate.parse(issue, path.read_text(encoding="utf-8"), str(path))
+        return loaded
+
+    def render(self, issue: str, **values: str) -> str:
+        template = self.templates.get(issue) or self.templates[ISSUE_GENERIC]
+        return template.render(**values)
+
+    def sources(self) -> Dict[str, str]:
+        return {issue: self.templates[issue].source for issue in ISSUE_TEMPLATES}
+
+
+def resolve_template_dir(config: Mapping[str, str] | None) -> str:
+    """Override directory from the session config, then the environment ("" for none)."""
+    return ((config or {}).get(TEMPLATE_DIR_KEY) or os.environ.get(TEMPLATE_DIR_ENV) or "").strip()
+
+
+def load_analysis_templates(config: Mapping[str, str] | None = None) -> AnalysisTemplates:
+    return AnalysisTemplates.load(resolve_template_dir(config))
+
+
+def issue_for_output(text: str, *, hang: bool = False) -> str:
+    """Which template applies to debugger output: deadlock, panic or generic."""
+    issue = build_result([text], source_radius=0, hang=hang).issue_type
+    # The runtime's "all goroutines are asleep" is a deadlock even when no cycle or channel is named.
+    if issue == ISSUE_DEADLOCK or runtime_deadlock_fired(text):
+        return ISSUE_DEADLOCK
+    if issue in {ISSUE_PANIC, ISSUE_FATAL}:
+        return ISSUE_PANIC
+    return ISSUE_GENERIC
+
+
+def analysis_sections(text: str, source_radius: int) -> Tuple[str, str]:
+    """(findings, source_context) for the template; reports already in ``text`` are not repeated."""
+    findings = "\n\n".join(report for report in report_sections(text) if report not in text)
+    context = stack_context_for_output(text, source_radius)
+    return findings, context[len(_CONTEXT_HEADING):] if context.startswith(_CONTEXT_HEADING) else context
+
+
+__all__ = [
+    "ISSUE_GENERIC",
+    "ISSUE_TEMPLATES",
+    "TEMPLATE_DIR_ENV",
+    "TEMPLATE_DIR_KEY",
+    "TEMPLATE_FIELDS",
+    "AnalysisTemplate",
+    "AnalysisTemplates",
+    "PromptTemplateError",
+    "analysis_sections",
+    "issue_for_output",
+    "load_analysis_templates",
+    "resolve_template_dir",
+]
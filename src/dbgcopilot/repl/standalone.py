@@ -6,11 +6,13 @@ Phase 2a: supports selecting GDB via a subprocess backend. LLDB will be added ne
 from __future__ import annotations
 
 import atexit
+import json
+import re
 import shutil
 import sys
 import uuid
 from pathlib import Path
-from typing import Optional, Any, Dict
+from typing import Optional, Any, Dict, List
 
 try:
     import readline
@@ -18,9 +20,17 @@ except ImportError:  # pragma: no cover - optional feature
     readline = None
 
 from dbgcopilot.core.orchestrator import CopilotOrchestrator
-from dbgcopilot.core.state import SessionState, Attempt, resolve_auto_round_limit
+from dbgcopilot.core.state import (
+    SessionState,
+    Attempt,
+    resolve_auto_round_limit,
+    resolve_goroutine_filter,
+    resolve_source_context_lines,
+)
 from dbgcopilot.llm import params as _llm_params
-from dbgcopilot.utils.io import color_text
+from dbgcopilot.utils.io import color_text, strip_ansi
+from dbgcopilot.utils.log import DEFAULT_LEVEL, configure as configure_logging
+from dbgcopilot.utils.tracing import configure as configure_tracing
 from dbgcopilot.utils.tools import warn_missing_debugger_tools
 
 
@@ -97,8 +107,20 @@ def _install_output_sink(state: SessionState) -> None:
             return
         _echo(chunk)
 
+    def _token_sink(chunk: str) -> None:
+        # Render streamed LLM tokens in place; the orchestrator sends a final "\n".
+        if not chunk:
+            return
+        text = color_text(chunk, "green", enable=True) if state.colors_enabled and chunk != "\n" else chunk
+        try:
+            sys.stdout.write(text)
+            sys.stdout.flush()
+        except Exception:
+            pass
+
     state.debugger_output_sink = _dbg_sink
     state.chat_output_sink = _chat_sink
+    state.token_sink = _token_sink
 
 
 def _echo(line: str, colors: bool = True) -> None:
@@ -122,13 +144,40 @@ def _print_help() -> str:
             "  /use pdb                   Select pdb (Python debugger backend)",
             "  /use delve                 Select Delve for Go binaries",
             "  /use radare2               Select radare2 for binary analysis",
+            "  /use auto                  Pick Delve or LLDB from the binary's format",
+            "  /use core                  Post-mortem: open a binary + core file (dlv core / lldb)",
+            "  /use remote                Connect to a headless dlv server (host:port)",
+            "  /use attach                Snapshot a running Go process by PID, analyze, detach",
             "  /colors on|off             Toggle colored output in REPL and debugger (LLDB/GDB)",
             "  /new                       Start a new copilot session",
             "  /chatlog                   Show chat transcript",
             "  /config                    Show current config",
+            "  /context <lines>|off       Source lines shown around each stack frame (default 3)",
+            "  /pathmap <build>=<local>|clear  Map source paths of a binary built elsewhere",
+            "  /goroutines [state:<s>] [grep:<t>]|raw|clear  Group the last dump by identical stack",
+            "  /goroutines snapshot|diff  Save the last dump as a baseline; diff a later dump against it",
+            "  /goroutines sample|leak    Add the last dump to the series; find stacks that grew in every sample",
+            "  /pin [<id>|<stack text>]   Always show this goroutine in full, locals deep-loaded; list pins",
+            "  /unpin <id>|<text>|all     Remove a pin",
             "  /auto [on|off|toggle]      Control auto-approve command execution",
             "  /prompts show|reload       Show or reload prompt config",
             "  /exec <cmd>                Run a debugger command (after /use)",
+            "  /break <loc> [if <c>] [hitcount <n>]  Set a conditional/hit-count breakpoint",
+            "  /break -r <regex> ...      Break on every function the regex matches (Delve)",
+            "  /breakpoints               List breakpoints with conditions and hit counts",
+            "  /clear <id>                Delete a breakpoint by ID",
+            "  /watch <expr> [read|write|rw]  Hardware watchpoint; hits are narrated by the LLM",
+            "  /unwatch <id>              Delete a watchpoint",
+            "  /continue                  Resume a structured debugger and report the stop",
+            "  /continue aggregate [n] [expr ...]  Run through a loop's breakpoint hits; the LLM gets a summary",
+            "  /continue locks [max]      Run breaking on sync Lock/Unlock; the LLM gets who holds which lock",
+            "  /step | /next | /stepout   Step into, over or out of a call; shows the new location and stack",
+            "  /print <expr> [depth=N] [array=N] [string=N]  Pretty-print a value with load limits",
+            "  /hang [seconds]            Run until no output/events/CPU for N s (default 10), then diagnose",
+            "  /redact dry-run|on|off     Preview or toggle secret redaction in LLM prompts",
+            "  /redact name|pattern <re>  Add a variable-name or value regex to redact",
+            "  /record <file>|stop        Record commands and LLM replies as NDJSON",
+            "  /replay <file>             Replay a recorded session without the binary",
             "  /llm list                  List configured LLM providers",
             "  /llm use <name>            Select provider for this session",
             "  /llm models [provider]     List models (provider must support discovery)",
@@ -136,8 +185,9 @@ def _print_help() -> str:
             "  /llm provider ...          Manage provider definitions (add/set/show)",
             "  /llm params ...            Inspect or tune provider parameters",
             "  /llm key <provider> <key>  Set API key for this session",
+            "  /llm cache on|off|clear    Reuse answers to identical prompts (ttl <s>, dir <path>)",
             "  exit or quit               Leave copilot>",
-            "Any other input is sent to the LLM.",
+            "Any other input is sent to the LLM; answers stream as they arrive (Ctrl-C stops a response).",
         ]
     )
 
@@ -229,6 +279,596 @@ def _select_delve() -> str:
     return f"Using Delve (dlv exec {path})."
 
 
+def _select_auto() -> str:
+    global BACKEND, ORCH
+    s = _ensure_session()
+    try:
+        from dbgcopilot.debugger import DebuggerError, open_debugger, resolve_backend
+    except Exception as e:  # pragma: no cover - import guards runtime dependency
+        return f"Failed to load structured debuggers: {e}"
+
+    path = input("Enter path to binary or script (Go binaries use Delve, Python scripts pdb, others LLDB): ").strip()
+    if not path:
+        return "Auto selection requires a binary path; selection cancelled."
+
+    try:
+        choice = resolve_backend(path)
+        BACKEND = open_debugger(path, choice)
+    except DebuggerError as e:
+        BACKEND = None
+        return str(e)
+    except Exception as e:
+        BACKEND = None
+        return f"Failed to start debugger: {e}"
+
+    ORCH = CopilotOrchestrator(BACKEND, s)
+    _install_output_sink(s)
+    s.config["program"] = path
+    banner = getattr(BACKEND, "startup_output", "")
+    if banner:
+        _echo(banner)
+    return f"Using {choice} for {path} (auto-detected)."
+
+
+def _select_core() -> str:
+    global BACKEND, ORCH
+    s = _ensure_session()
+    from dbgcopilot.debugger import DebuggerError, open_core
+
+    program = input("Enter path to the binary that crashed: ").strip()
+    if not program:
+        return "Post-mortem analysis requires the binary; selection cancelled."
+    core = input("Enter path to the core file: ").strip()
+    if not core:
+        return "Post-mortem analysis requires a core file; selection cancelled."
+
+    try:
+        BACKEND = open_core(program, core)
+    except DebuggerError as e:
+        BACKEND = None
+        return str(e)
+    except Exception as e:
+        BACKEND = None
+        return f"Failed to open core: {e}"
+
+    ORCH = CopilotOrchestrator(BACKEND, s)
+    _install_output_sink(s)
+    s.config["program"] = program
+    s.config["core"] = core
+    banner = getattr(BACKEND, "startup_output", "")
+    if banner:
+        _echo(banner)
+    _echo(
+        f"Post-mortem mode: {BACKEND.name} loaded {core} for {program}. "
+        "Continue/step commands are disabled; collecting goroutines/threads for analysis..."
+    )
+    return ORCH.run_and_analyze(BACKEND.GOROUTINES_COMMAND)
+
+
+def _select_remote() -> str:
+    global BACKEND, ORCH
+    s = _ensure_session()
+    from dbgcopilot.debugger import DebuggerError, connect
+
+    addr = input("Enter host:port of the headless Delve server: ").strip()
+    if not addr:
+        return "Remote debugging requires the server address; selection cancelled."
+    raw_api = (s.config.get("delve_api_version") or "2").strip()
+    if not raw_api.isdigit():
+        return f"Invalid delve_api_version '{raw_api}'; expected 1 or 2."
+
+    try:
+        BACKEND = connect(addr, api_version=int(raw_api))
+    except DebuggerError as e:
+        BACKEND = None
+        return str(e)
+    except Exception as e:
+        BACKEND = None
+        return f"Failed to connect to Delve: {e}"
+
+    ORCH = CopilotOrchestrator(BACKEND, s)
+    _install_output_sink(s)
+    s.config["remote"] = addr
+    banner = getattr(BACKEND, "startup_output", "")
+    if banner:
+        _echo(banner)
+    return f"Using remote Delve at {addr}; /exec exit disconnects and leaves the server running."
+
+
+def _select_attach() -> str:
+    global BACKEND, ORCH
+    s = _ensure_session()
+    from dbgcopilot.debugger import DebuggerError, attach
+
+    raw_pid = input("Enter PID of the running Go process: ").strip()
+    if not raw_pid.isdigit():
+        return "Attaching requires a numeric PID; selection cancelled."
+    program = input("Path to its binary (optional, Enter to detect): ").strip()
+    pid = int(raw_pid)
+
+    try:
+        BACKEND = attach(pid, program or None)
+    except DebuggerError as e:
+        BACKEND = None
+        return str(e)
+    except Exception as e:
+        BACKEND = None
+        return f"Failed to attach: {e}"
+
+    ORCH = CopilotOrchestrator(BACKEND, s)
+    _install_output_sink(s)
+    s.config["pid"] = raw_pid
+    if program:
+        s.config["program"] = program
+    _echo(f"Attached to PID {pid}; collecting goroutines and thread stacks (the process is paused)...")
+    try:
+        snapshot = BACKEND.snapshot()
+    except Exception as e:
+        return f"Snapshot of PID {pid} failed: {e}"
+    finally:
+        # Resume the process before the (slow) LLM round trip, and even when collection failed.
+        BACKEND.detach()
+        _echo(f"Detached from PID {pid}; it is running again.")
+    return ORCH.analyze_output(f"{BACKEND.GOROUTINES_COMMAND}; threads (PID {pid})", snapshot)
+
+
+def _handle_breakpoints(verb: str, arg: str) -> str:
+    """Structured breakpoint management for debuggers selected with /use auto or /use core."""
+    from dbgcopilot.debugger import BreakpointSpec, DebuggerError, describe_placed, place_breakpoints
+
+    if BACKEND is None:
+        return "No debugger selected. Use /use auto first."
+    if not hasattr(BACKEND, "breakpoints"):
+        label = getattr(BACKEND, "name", "debugger") or "debugger"
+        return f"{verb} needs a structured debugger (/use auto); with {label} use /exec instead."
+    try:
+        if verb == "/break":
+            if not arg:
+                return "Usage: /break <location>|-r <regex> [if <condition>] [hitcount <n>]"
+            spec = BreakpointSpec.parse(arg)
+            return describe_placed(spec, place_breakpoints(BACKEND, spec))
+        if verb == "/clear":
+            if not arg.strip().isdigit():
+                return "Usage: /clear <breakpoint id>"
+            BACKEND.clear_breakpoint(int(arg))
+            return f"Cleared breakpoint {int(arg)}."
+        bps = BACKEND.breakpoints()
+    except DebuggerError as e:
+        return f"Error: {e}"
+    if not bps:
+        return "No breakpoints set."
+    return "\n".join(bp.describe() for bp in bps)
+
+
+def _handle_watch(verb: str, arg: str) -> str:
+    """Structured watchpoints and continue; fired watchpoints are narrated by the LLM."""
+    from dbgcopilot.debugger import DebuggerError
+
+    if BACKEND is None:
+        return "No debugger selected. Use /use auto first."
+    if verb == "/continue" and (arg.split() or [""])[0] == "aggregate":
+        return _handle_aggregate(arg.split()[1:])
+    if verb == "/continue" and (arg.split() or [""])[0] == "locks":
+        return _handle_locks(arg.split()[1:])
+    if not hasattr(BACKEND, "set_watchpoint"):
+        label = getattr(BACKEND, "name", "debugger") or "debugger"
+        return f"{verb} needs a structured debugger (/use auto); with {label} use /exec instead."
+    try:
+        if verb == "/watch":
+            if not arg.strip():
+                return "Usage: /watch <expr|0xaddr> [read|write|rw]"
+            expr, kind = arg, "write"
+            parts = arg.rsplit(maxsplit=1)
+            if len(parts) == 2 and parts[1].lower() in {"read", "write", "rw", "readwrite"}:
+                expr, kind = parts
+            wp = BACKEND.set_watchpoint(expr.strip(), kind)
+            value = f" (current value: {wp.value})" if wp.value else ""
+            return f"Watchpoint {wp.id} ({wp.kind}) set on {wp.expr}{value}."
+        if verb == "/unwatch":
+            if not arg.strip().isdigit():
+                return "Usage: /unwatch <watchpoint id>"
+            BACKEND.clear_watchpoint(int(arg))
+            return f"Cleared watchpoint {int(arg)}."
+        event = BACKEND.continue_()
+    except DebuggerError as e:
+        return f"Error: {e}"
+    s = _ensure_session()
+    s.last_output = event.raw
+    if event.watch is not None and ORCH is not None:
+        _echo(event.describe())
+        return ORCH.narrate_watch_hit(event.describe(), variable=event.watch.watchpoint.expr)
+    return event.describe()
+
+
+def _handle_aggregate(words: List[str]) -> str:
+    """Continue through repeated breakpoint hits; only the per-location summary goes to the LLM."""
+    from dbgcopilot.debugger import DEFAULT_AGGREGATE_THRESHOLD, DebuggerError, HitAggregator
+
+    if not hasattr(BACKEND, "continue_"):
+        label = getattr(BACKEND, "name", "debugger") or "debugger"
+        return f"/continue aggregate needs a structured debugger (/use auto); {label} is not supported."
+    threshold = DEFAULT_AGGREGATE_THRESHOLD
+    if words and words[0].isdigit():
+        threshold = int(words.pop(0))
+    try:
+        aggregator = HitAggregator(BACKEND, threshold=threshold, expressions=words)
+        _echo("Continuing through breakpoint hits...")
+        aggregator.run()
+    except (DebuggerError, ValueError) as e:
+        return f"Error: {e}"
+    summary = aggregator.describe()
+    s = _ensure_session()
+    s.last_output = summary
+    if ORCH is None:
+        return summary
+    _echo(summary)
+    return ORCH.analyze_output("continue aggregate", summary)
+
+
+def _handle_locks(words: List[str]) -> str:
+    """Continue under a LockMonitor; the locks it saw taken, applied to the final dump, go to the LLM."""
+    from dbgcopilot.debugger import DebuggerError
+    from dbgcopilot.debugger.locks import DEFAULT_MAX_STOPS, LockMonitor
+
+    if not hasattr(BACKEND, "continue_") or not hasattr(BACKEND, "goroutines"):
+        label = getattr(BACKEND, "name", "debugger") or "debugger"
+        return f"/continue locks needs a structured Delve session (/use auto); {label} is not supported."
+    if words and not words[0].isdigit():
+        return "Usage: /continue locks [max stops]"
+    max_stops = int(words[0]) if words else DEFAULT_MAX_STOPS
+    monitor = LockMonitor(BACKEND)
+    try:
+        monitor.install()
+        _echo("Continuing through lock operations...")
+        try:
+            event = monitor.run(max_stops=max_stops)
+        finally:
+            monitor.remove()
+        summary = f"{event.describe()}\n{monitor.describe(BACKEND.goroutines())}"
+    except DebuggerError as e:
+        return f"Error: {e}"
+    s = _ensure_session()
+    s.last_output = summary
+    if ORCH is None:
+        return summary
+    _echo(summary)
+    return ORCH.analyze_output("continue locks", summary)
+
+
+_STEP_VERBS = {"/step": "step", "/next": "next", "/stepout": "step_out"}
+
+
+def _handle_step(verb: str) -> str:
+    """Single-step a structured debugger; the stop and stack become LLM context."""
+    from dbgcopilot.analyze import format_stacktrace
+    from dbgcopilot.debugger import DebuggerError
+
+    if BACKEND is None:
+        return "No debugger selected. Use /use auto first."
+    method = _STEP_VERBS[verb]
+    if not hasattr(BACKEND, method):
+        label = getattr(BACKEND, "name", "debugger") or "debugger"
+        return f"{verb} needs a structured debugger (/use auto); with {label} use /exec instead."
+    try:
+        event = getattr(BACKEND, method)()
+    except DebuggerError as e:
+        return f"Error: {e}"
+    text = "\n".join(part for part in (event.describe(), format_stacktrace(event.stack)) if part)
+    if ORCH is not None:
+        ORCH.record_output(verb.lstrip("/"), text)
+    return text
+
+
+_PRINT_OPT_RE = re.compile(r"\s+(depth|array|string)=(\d+)\s*$")
+_PRINT_OPT_ATTRS = {"depth": "max_depth", "array": "max_array_values", "string": "max_string_len"}
+
+
+def _handle_print(arg: str) -> str:
+    """Read a variable with configurable load limits, pretty-print it and keep it as LLM context."""
+    from dbgcopilot.debugger import DebuggerError, LoadConfig, pretty_print
+
+    if BACKEND is None:
+        return "No debugger selected. Use /use auto first."
+    if not hasattr(BACKEND, "read_variable"):
+        label = getattr(BACKEND, "name", "debugger") or "debugger"
+        return f"/print needs a structured debugger (/use auto); with {label} use /exec print instead."
+    expr, overrides = (arg or "").strip(), {}
+    m = _PRINT_OPT_RE.search(expr)
+    while m:
+        overrides[_PRINT_OPT_ATTRS[m.group(1)]] = int(m.group(2))
+        expr = expr[: m.start()].rstrip()
+        m = _PRINT_OPT_RE.search(expr)
+    if not expr:
+        return "Usage: /print <expr> [depth=N] [array=N] [string=N]"
+    s = _ensure_session()
+    try:
+        var = BACKEND.read_variable(expr, LoadConfig.from_config(s.config, **overrides))
+    except DebuggerError as e:
+        return f"Error: {e}"
+    rendered = pretty_print(var)
+    text = f"{expr} = {rendered}" + (f"\n(type {var.type})" if var.type and not var.kind else "")
+    if ORCH is not None:
+        ORCH.record_output(f"print {expr}", text)
+    return text
+
+
+_GOROUTINE_OPT_RE = re.compile(r"(state|grep):(.*?)(?=\s+(?:state|grep):|$)")
+
+
+def _handle_hang(arg: str) -> str:
+    """Continue under hang detection; a stalled target is paused and diagnosed by the LLM."""
+    from dbgcopilot.debugger import DebuggerError
+    from dbgcopilot.debugger.hang import DEFAULT_HANG_TIMEOUT, watch_for_hang
+
+    if BACKEND is None or ORCH is None:
+        return "No debugger selected. Use /use auto first."
+    if not hasattr(BACKEND, "resume_async"):
+        label = getattr(BACKEND, "name", "debugger") or "debugger"
+        return f"/hang needs a local Delve session (/use auto with a Go binary); {label} is not supported."
+    s = _ensure_session()
+    raw = (arg or s.config.get("hang_timeout") or str(DEFAULT_HANG_TIMEOUT)).strip()
+    try:
+        timeout = float(raw)
+    except ValueError:
+        return "Usage: /hang [seconds]"
+    _echo(f"Running; pausing if there is no output, breakpoint hit or CPU use for {timeout:g}s...")
+    try:
+        result = watch_for_hang(BACKEND, timeout)
+    except DebuggerError as e:
+        return f"Error: {e}"
+    _echo(result.describe())
+    if result.hung and result.dump is not None:
+        return ORCH.analyze_output(f"{BACKEND.GOROUTINES_COMMAND} (auto-paused after {result.idle_seconds:.0f}s idle)", result.dump.raw)
+    if result.runtime_deadlock and result.dump is not None:
+        # The stop line carries the runtime's "all goroutines are asleep" message for the analyzers.
+        text = f"{result.stop.describe()}\n{result.dump.raw}" if result.stop is not None else result.dump.raw
+        return ORCH.analyze_output(f"{BACKEND.GOROUTINES_COMMAND} (runtime deadlock)", text)
+    if result.stop is not None and not result.stop.exited:
+        return ORCH.analyze_output("continue", result.stop.raw)
+    return ""
+
+
+def _handle_redact(arg: str) -> str:
+    """Secret redaction settings; ``dry-run`` reports what the next prompt would hide."""
+    from dbgcopilot.utils.redact import DEFAULT_NAME_PATTERN, Redactor
+
+    s = _ensure_session()
+    sub, _, rest = (arg or "").strip().partition(" ")
+    sub = sub.lower()
+    if sub in {"", "show"}:
+        state = "off" if (s.config.get("redact") or "on").lower() in {"off", "0", "false", "no"} else "on"
+        lines = [f"Redaction: {state}; names matching {DEFAULT_NAME_PATTERN} plus built-in secret patterns."]
+        for key in ("redact_names", "redact_patterns"):
+            if s.config.get(key):
+                lines.append(f"{key}: {s.config[key]}")
+        return "\n".join(lines)
+    if sub in {"on", "off"}:
+        s.config["redact"] = sub
+        return f"Redaction {sub}."
+    if sub in {"name", "pattern"}:
+        pattern = rest.strip()
+        if not pattern:
+            return f"Usage: /redact {sub} <regex>"
+        key = "redact_names" if sub == "name" else "redact_patterns"
+        current = s.config.get(key) or ""
+        patterns = json.loads(current) if current.startswith("[") else ([current] if current else [])
+        patterns.append(pattern)
+        candidate = json.dumps(patterns)
+        try:
+            Redactor.from_config({key: candidate})
+        except ValueError as e:
+            return str(e)
+        s.config[key] = candidate
+        return f"Added {sub} pattern {pattern!r}."
+    if sub in {"dry-run", "dryrun", "preview"}:
+        if ORCH is None:
+            return "No debugger selected. Use /use <debugger> first."
+        try:
+            return ORCH.redaction_dry_run()
+        except ValueError as e:
+            return str(e)
+    return "Usage: /redact [show] | on | off | dry-run | name <regex> | pattern <regex>"
+
+
+def _handle_pathmap(arg: str) -> str:
+    """Map source paths of a binary built elsewhere (``/build/src=/home/me/proj``) for context and breakpoints."""
+    from dbgcopilot.utils.pathmap import PathMap, PathMapping, active_path_map, config_mappings, set_path_map
+
+    s = _ensure_session()
+    choice = (arg or "").strip()
+    if choice in {"", "show"}:
+        return active_path_map().describe()
+    if choice == "clear":
+        s.config.pop("path_map", None)
+        set_path_map(None)
+        return "Source path mappings cleared."
+    try:
+        mapping = PathMapping.parse(choice)
+    except ValueError as e:
+        return f"{e}\nUsage: /pathmap <build prefix>=<local prefix> | show | clear"
+    mappings = [m for m in config_mappings(s.config.get("path_map")) if PathMapping.parse(m).build != mapping.build]
+    mappings.append(str(mapping))
+    s.config["path_map"] = json.dumps(mappings)
+    set_path_map(PathMap.from_config(s.config))
+    return f"Mapped {mapping.build} -> {mapping.local}."
+
+
+def _handle_pin(verb: str, arg: str) -> str:
+    """``/pin`` lists or adds a pinned goroutine (id or stack substring); ``/unpin`` removes one or all."""
+    from dbgcopilot.analyze.pins import PINS_KEY, Pin, pins_from_config, pins_to_config
+
+    s = _ensure_session()
+    pins = pins_from_config(s.config)
+    choice = (arg or "").strip()
+    if verb == "/unpin" and choice == "all":
+        s.config.pop(PINS_KEY, None)
+        return "All pins removed."
+    if not choice:
+        if verb == "/unpin":
+            return "Usage: /unpin <id>|<stack text>|all"
+        if not pins:
+            return "Nothing pinned. Use /pin <goroutine id> or /pin <function or file:line text>."
+        return "Pinned: " + "; ".join(pin.describe() for pin in pins)
+    try:
+        pin = Pin.parse(choice)
+    except ValueError as e:
+        return str(e)
+    if verb == "/unpin":
+        if pin not in pins:
+            return f"Not pinned: {pin.describe()}."
+        pins.remove(pin)
+        message = f"Unpinned {pin.describe()}."
+    else:
+        if pin in pins:
+            return f"Already pinned: {pin.describe()}."
+        pins.append(pin)
+        message = f"Pinned {pin.describe()}; prompts show it in full from now on."
+    if pins:
+        s.config[PINS_KEY] = pins_to_config(pins)
+    else:
+        s.config.pop(PINS_KEY, None)
+    return message
+
+
+def _handle_goroutines(arg: str) -> str:
+    """Grouped/filtered view of the last goroutine dump; filters also apply to LLM prompts."""
+    from dbgcopilot.analyze import condense_goroutine_output
+    from dbgcopilot.analyze.goroutines import looks_like_goroutine_dump
+
+    s = _ensure_session()
+    choice = (arg or "").strip()
+    if choice == "clear":
+        s.config.pop("goroutine_states", None)
+        s.config.pop("goroutine_grep", None)
+        return "Goroutine filter cleared; large dumps are still grouped for the LLM."
+    text = strip_ansi(s.last_output or "")
+    if choice == "raw":
+        return text or "No debugger output yet."
+    if choice in {"snapshot", "diff"}:
+        return _goroutine_diff(choice, text)
+    if choice in {"sample", "leak"}:
+        return _goroutine_leak(choice, text)
+    if choice:
+        opts = dict((k, v.strip()) for k, v in _GOROUTINE_OPT_RE.findall(choice))
+        if not opts:
+            return (
+                "Usage: /goroutines [state:<s>[,<s>...]] [grep:<text>] | raw | clear | snapshot | diff | sample | leak"
+            )
+        for key, cfg_key in (("state", "goroutine_states"), ("grep", "goroutine_grep")):
+            if key in opts:
+                if opts[key]:
+                    s.config[cfg_key] = opts[key]
+                else:
+                    s.config.pop(cfg_key, None)
+    if not looks_like_goroutine_dump(text):
+        return "The last debugger output is not a goroutine dump (run `goroutines -t` or `thread backtrace all`)."
+    states, contains = resolve_goroutine_filter(s.config)
+    return condense_goroutine_output(text, states=states, contains=contains, threshold=0)
+
+
+def _goroutine_diff(choice: str, text: str) -> str:
+    """``snapshot`` keeps the last dump as the baseline; ``diff`` compares the last dump with it."""
+    from dbgcopilot.analyze import diff_dumps, format_dump_diff, parse_goroutine_dump
+    from dbgcopilot.analyze.goroutines import looks_like_goroutine_dump
+
+    s = _ensure_session()
+    if not looks_like_goroutine_dump(text):
+        return "The last debugger output is not a goroutine dump (run `goroutines -t` or `thread backtrace all`)."
+    if choice == "snapshot":
+        s.goroutine_baseline = text
+        s.goroutine_samples = [text]
+        count = len(parse_goroutine_dump(text))
+        return f"Saved {count} goroutine(s) as the baseline; dump again later and run /goroutines diff."
+    if not s.goroutine_baseline:
+        return "No baseline yet. Use /goroutines snapshot on an earlier dump first."
+    after = parse_goroutine_dump(text)
+    report = format_dump_diff(diff_dumps(parse_goroutine_dump(s.goroutine_baseline), after), total=len(after))
+    if ORCH is None:
+        return report
+    _echo(report)
+    return ORCH.analyze_output("goroutine dump diff (baseline -> latest)", report)
+
+
+def _goroutine_leak(choice: str, text: str) -> str:
+    """``sample`` adds the last dump to the series started by ``snapshot``; ``leak`` looks for monotonic growth."""
+    from dbgcopilot.analyze import detect_leak, format_leak_report, parse_goroutine_dump
+    from dbgcopilot.analyze.goroutines import looks_like_goroutine_dump
+    from dbgcopilot.analyze.leak import MIN_SAMPLES
+
+    s = _ensure_session()
+    if choice == "sample":
+        if not looks_like_goroutine_dump(text):
+            return "The last debugger output is not a goroutine dump (run `goroutines -t` or `thread backtrace all`)."
+        if s.goroutine_samples and s.goroutine_samples[-1] == text:
+            return "The last dump is already in the series; continue the program and dump again."
+        s.goroutine_samples.append(text)
+        count = len(s.goroutine_samples)
+        more = f" {MIN_SAMPLES - count} more needed for /goroutines leak." if count < MIN_SAMPLES else ""
+        return f"Sample {count} saved ({len(parse_goroutine_dump(text))} goroutine(s)).{more}"
+    samples = [parse_goroutine_dump(sample) for sample in s.goroutine_samples]
+    report = format_leak_report(detect_leak(samples), samples=len(samples))
+    if ORCH is None or len(samples) < MIN_SAMPLES:
+        return report
+    _echo(report)
+    return ORCH.analyze_output(f"goroutine leak check over {len(samples)} samples", report)
+
+
+def _handle_record(arg: str) -> str:
+    from dbgcopilot.session import Recorder, detach
+
+    s = _ensure_session()
+    target = arg.strip()
+    if target in {"", "status"}:
+        if s.recorder is None:
+            return "Not recording. Use /record <file.ndjson> after /use."
+        return f"Recording to {s.recorder.path}."
+    if target == "stop":
+        if s.recorder is None:
+            return "Not recording."
+        path = s.recorder.path
+        s.recorder.close()
+        s.recorder = None
+        if BACKEND is not None:
+            detach(BACKEND)
+        return f"Recording saved to {path}."
+    if BACKEND is None:
+        return "No debugger selected. Use /use <debugger> before /record."
+    if s.recorder is not None:
+        return f"Already recording to {s.recorder.path}. Use /record stop first."
+    try:
+        recorder = Recorder(str(Path(target).expanduser()))
+        recorder.start(BACKEND, session_id=s.session_id, goal=s.goal or None)
+    except OSError as e:
+        return f"Cannot record to {target}: {e}"
+    s.recorder = recorder
+    return f"Recording session to {recorder.path}. Use /record stop to finish."
+
+
+def _finish_recording() -> None:
+    s = SESSION
+    if s is not None and s.recorder is not None:
+        _echo(_handle_record("stop"))
+
+
+def _select_replay(arg: str) -> str:
+    global BACKEND, ORCH
+    from dbgcopilot.session import ReplayError, replay
+
+    path = arg.strip()
+    if not path:
+        return "Usage: /replay <file.ndjson>"
+    try:
+        session = replay(str(Path(path).expanduser()))
+    except (OSError, ReplayError) as e:
+        return f"Cannot replay {path}: {e}"
+    s = _ensure_session()
+    BACKEND = session.backend
+    s.replay_llm = session.llm
+    ORCH = CopilotOrchestrator(BACKEND, s)
+    _install_output_sink(s)
+    return session.describe()
+
+
 def _select_radare2() -> str:
     global BACKEND, ORCH
     s = _ensure_session()
@@ -822,9 +1462,40 @@ def _handle_llm(cmd: str) -> str:
     if action == "params":
         return _handle_params(parts[1:])
 
+    if action == "cache":
+        return _handle_llm_cache(parts[1:])
+
     return _usage()
 
 
+def _handle_llm_cache(args: list[str]) -> str:
+    """Response cache settings: on/off, ttl, dir, or clear the stored entries."""
+    from dbgcopilot.llm.cache import Cache
+
+    s = _ensure_session()
+    sub = args[0].lower() if args else "show"
+    if sub == "show":
+        cache = Cache.from_config(s.config)
+        state = "on" if cache.enabled else "off"
+        return f"LLM cache: {state}; dir {cache.directory}; ttl {cache.ttl:g}s."
+    if sub in {"on", "off"}:
+        s.config["llm_cache"] = sub
+        return f"LLM cache {sub}."
+    if sub == "ttl" and len(args) == 2:
+        try:
+            float(args[1])
+        except ValueError:
+            return "Usage: /llm cache ttl <seconds>"
+        s.config["llm_cache_ttl"] = args[1]
+        return f"LLM cache TTL set to {args[1]}s."
+    if sub == "dir" and len(args) == 2:
+        s.config["llm_cache_dir"] = args[1]
+        return f"LLM cache directory set to {args[1]}."
+    if sub == "clear":
+        return f"Removed {Cache.from_config(s.config).clear()} cached response(s)."
+    return "Usage: /llm cache [show] | on | off | ttl <seconds> | dir <path> | clear"
+
+
 def _select_lldb() -> str:
     global BACKEND, ORCH
     s = _ensure_session()
@@ -856,6 +1527,12 @@ def _select_lldb() -> str:
 
 def main(argv: Optional[list[str]] = None) -> int:
     _configure_readline_history()
+    try:
+        configure_logging()
+    except ValueError as exc:
+        _echo(f"{exc}; logging at {DEFAULT_LEVEL}")
+        configure_logging(DEFAULT_LEVEL)
+    configure_tracing()
     warn_missing_debugger_tools("dbgcopilot")
     _ensure_session()
     _echo(
@@ -866,6 +1543,7 @@ def main(argv: Optional[list[str]] = None) -> int:
         try:
             line = input("copilot> ")
         except EOFError:
+            _finish_recording()
             _echo("Exiting copilot>")
             return 0
         except KeyboardInterrupt:
@@ -875,6 +1553,7 @@ def main(argv: Optional[list[str]] = None) -> int:
         if not cmd:
             continue
         if cmd in {"exit", "quit"}:
+            _finish_recording()
             _echo("Exiting copilot>")
             return 0
 
@@ -887,6 +1566,7 @@ def main(argv: Optional[list[str]] = None) -> int:
                 continue
             if verb == "/use":
                 choice = arg.strip().lower()
+                _ensure_session().replay_llm = None
                 if choice == "gdb":
                     _echo(_select_gdb())
                 elif choice == "rust-gdb":
@@ -903,12 +1583,23 @@ def main(argv: Optional[list[str]] = None) -> int:
                     _echo(_select_delve())
                 elif choice == "radare2":
                     _echo(_select_radare2())
+                elif choice == "auto":
+                    _echo(_select_auto())
+                elif choice == "core":
+                    _echo(_select_core())
+                elif choice == "remote":
+                    _echo(_select_remote())
+                elif choice == "attach":
+                    _echo(_select_attach())
                 else:
-                    _echo("Supported: /use gdb | /use rust-gdb | /use lldb | /use rust-lldb | /use jdb | /use pdb | /use delve | /use radare2")
+                    _echo("Supported: /use gdb | /use rust-gdb | /use lldb | /use rust-lldb | /use jdb | /use pdb | /use delve | /use radare2 | /use auto | /use core | /use remote | /use attach")
                 continue
             if verb == "/new":
                 sid = str(uuid.uuid4())[:8]
+                previous = _ensure_session()
                 globals()["SESSION"] = SessionState(session_id=sid)
+                globals()["SESSION"].recorder = previous.recorder
+                globals()["SESSION"].replay_llm = previous.replay_llm
                 if ORCH is not None and BACKEND is not None:
                     globals()["ORCH"] = CopilotOrchestrator(BACKEND, globals()["SESSION"])  # reload prompts per session
                     _install_output_sink(globals()["SESSION"])
@@ -923,6 +1614,35 @@ def main(argv: Optional[list[str]] = None) -> int:
                     for line in s.chatlog[-200:]:
                         _echo(line)
                 continue
+            if verb == "/goroutines":
+                _echo(_handle_goroutines(arg or ""))
+                continue
+            if verb in {"/pin", "/unpin"}:
+                _echo(_handle_pin(verb, arg or ""))
+                continue
+            if verb == "/hang":
+                _echo(_handle_hang(arg or ""))
+                continue
+            if verb == "/pathmap":
+                _echo(_handle_pathmap(arg or ""))
+                continue
+            if verb == "/redact":
+                _echo(_handle_redact(arg or ""))
+                continue
+            if verb == "/context":
+                s = _ensure_session()
+                choice = (arg or "").strip().lower()
+                if not choice:
+                    _echo(f"Source context: {resolve_source_context_lines(s.config)} line(s) around each frame.")
+                elif choice in {"off", "0"}:
+                    s.config["source_context_lines"] = "0"
+                    _echo("Source context disabled; stack frames are sent without code.")
+                elif choice.isdigit():
+                    s.config["source_context_lines"] = choice
+                    _echo(f"Source context set to {choice} line(s) before/after each frame.")
+                else:
+                    _echo("Usage: /context <lines>|off")
+                continue
             if verb == "/config":
                 s = _ensure_session()
                 _echo(f"Config: {s.config}")
@@ -1004,6 +1724,18 @@ def main(argv: Optional[list[str]] = None) -> int:
                 else:
                     _echo("Usage: /prompts show | /prompts reload")
                 continue
+            if verb in {"/break", "/breakpoints", "/clear"}:
+                _echo(_handle_breakpoints(verb, arg or ""))
+                continue
+            if verb in {"/watch", "/unwatch", "/continue"}:
+                _echo(_handle_watch(verb, arg or ""))
+                continue
+            if verb in _STEP_VERBS:
+                _echo(_handle_step(verb))
+                continue
+            if verb == "/print":
+                _echo(_handle_print(arg or ""))
+                continue
             if verb == "/exec":
                 if BACKEND is None:
                     _echo("No debugger selected. Use /use gdb first.")
@@ -1052,6 +1784,12 @@ def main(argv: Optional[list[str]] = None) -> int:
             if verb == "/llm":
                 _echo(_handle_llm(arg))
                 continue
+            if verb == "/record":
+                _echo(_handle_record(arg))
+                continue
+            if verb == "/replay":
+                _echo(_select_replay(arg))
+                continue
             _echo("Unknown slash command. Try /help")
             continue
 
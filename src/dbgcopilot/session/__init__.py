@@ -0,0 +1,31 @@
+"""Session recording, deterministic replay and the interactive chat loop."""
+from __future__ import annotations
+
+from .interactive import Interactive, Reply, parse_action
+from .recorder import Recorder, detach
+from .replay import (
+    Replay,
+    ReplayBackend,
+    ReplayError,
+    ReplayLLM,
+    ReplayTruncated,
+    Transcript,
+    load_transcript,
+    replay,
+)
+
+__all__ = [
+    "Interactive",
+    "Recorder",
+    "Replay",
+    "ReplayBackend",
+    "ReplayError",
+    "ReplayLLM",
+    "ReplayTruncated",
+    "Reply",
+    "Transcript",
+    "detach",
+    "load_transcript",
+    "parse_action",
+    "replay",
+]
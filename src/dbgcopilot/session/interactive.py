@@ -0,0 +1,432 @@
+"""Multi-turn chat over a live debugger session.
+
+``Interactive`` keeps the conversation and the debugger alive between
+questions. Each question goes to the LLM with the recent history, and the
+model drives the debugger through the tools in ``session.tools``. Providers
+with native function calling (``chat``) get the tools as JSON schemas and
+request them as tool calls; others are asked to reply with an
+``<action>{"tool": ..., ...}</action>`` block (a bare ``<cmd>...</cmd>`` is
+the ``command`` tool). Either way arguments are validated against the tool's
+schema, results go back to the model, and at most ``max_actions`` tool calls
+run per question. Unknown tools and bad arguments come back as structured
+errors the model can correct. History is trimmed oldest-first to
+``max_history_chars``, and the prompt says how many messages were dropped so
+the model knows context is missing. ``pin <id>``, ``pin "<text>"`` (or
+``/pin <id|text>``) and ``unpin <pin|all>`` lines change the pinned
+goroutines, which every prompt shows in full; any other line is a question.
+
+A failed question is reported and the loop goes on only when the next one
+can succeed: a retryable LLM error after its retries, or a debugger command
+error. Cancellation, a non-retryable LLM error (bad key, malformed reply)
+and a debugger that is gone end the loop with the exception.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, List, Mapping, Optional
+import json
+import re
+
+from dbgcopilot.analyze.pins import PINS_KEY, Pin, pins_from_config, pins_to_config
+from dbgcopilot.debugger.base import DebuggerError, DebuggerUnavailable
+from dbgcopilot.llm.base import LLMError
+from dbgcopilot.llm.tools import (
+    ERROR_INVALID_ARGUMENTS,
+    ERROR_TOOL_LIMIT,
+    ToolCall,
+    ToolRegistry,
+    ToolReply,
+    ToolResult,
+    assistant_message,
+    render_messages,
+    tool_message,
+)
+from dbgcopilot.prompts.defaults import DEFAULT_PROMPT_CONFIG
+from dbgcopilot.prompts.pinned import pinned_section
+from dbgcopilot.utils.context import Cancelled
+from dbgcopilot.utils.io import head_tail_truncate
+from dbgcopilot.utils.redact import Redactor, command_variable
+
+from .tools import debugger_tools
+
+
+# Hard cap on tool calls per question, so a model that never answers cannot loop forever.
+DEFAULT_MAX_ACTIONS = 6
+DEFAULT_HISTORY_CHARS = 24000
+# Longest debugger output kept per action in the history.
+ACTION_RESULT_CHARS = 4000
+
+ROLE_USER = "User"
+ROLE_ASSISTANT = "Assistant"
+ROLE_DEBUGGER = "Debugger"
+
+_ACTION_RE = re.compile(r"<action>\s*([\s\S]*?)\s*</action>", re.IGNORECASE)
+_CMD_RE = re.compile(r"<cmd>\s*([\s\S]*?)\s*</cmd>", re.IGNORECASE)
+_EXIT_WORDS = {"exit", "quit", "/exit", "/quit"}
+# "/pin <id|text>" takes anything; a bare "pin" only an id or quoted text, so "pin down why ..." stays a question.
+_PIN_RE = re.compile(
+    r'^(?:/(?P<slash>pin|unpin)(?:\s+(?P<any>.*))?'
+    r'|(?P<verb>pin|unpin)(?:\s+(?P<arg>\d+|"[^"]*"))?'
+    r"|(?P<all>unpin)\s+all)$",
+    re.IGNORECASE,
+)
+_ROLE_MESSAGE = {ROLE_USER: "user", ROLE_ASSISTANT: "assistant", ROLE_DEBUGGER: "user"}
+
+# chat(messages, tools) -> ToolReply, as returned by ``providers.create_tool_client``.
+ToolChat = Callable[[List[Dict[str, Any]], List[Any]], ToolReply]
+
+
+@dataclass
+class Turn:
+    role: str
+    text: str
+    # Expression the text prints, so name-based redaction applies to a bare value.
+    variable: str = ""
+
+    def render(self) -> str:
+        return f"{self.role}: {self.text}"
+
+
+def _new_turn_list() -> List[Turn]:
+    return []
+
+
+@dataclass
+class History:
+    """Conversation turns, trimmed oldest-first to stay within ``max_chars``."""
+
+    max_chars: int = DEFAULT_HISTORY_CHARS
+    turns: List[Turn] = field(default_factory=_new_turn_list)
+    dropped: int = 0
+
+    def add(self, role: str, text: str, *, variable: str = "") -> None:
+        self.turns.append(Turn(role, text, variable))
+        self._trim()
+
+    def size(self) -> int:
+        return sum(len(t.render()) + 1 for t in self.turns)
+
+    def _trim(self) -> None:
+        while len(self.turns) > 1 and self.size() > self.max_chars:
+            self.turns.pop(0)
+            self.dropped += 1
+        if self.turns and self.size() > self.max_chars:
+            last = self.turns[-1]
+            last.text = head_tail_truncate(last.text, max(self.max_chars - len(last.role) - 3, 200))
+
+    def notice(self) -> str:
+        if not self.dropped:
+            return ""
+        return f"[{self.dropped} earlier message(s) were dropped to fit the context window]"
+
+
+@dataclass
+class Action:
+    tool: str
+    args: Dict[str, Any]
+
+    def describe(self) -> str:
+        args = ", ".join(f"{k}={v!r}" for k, v in self.args.items())
+        return f"{self.tool}({args})"
+
+
+@dataclass
+class ActionResult:
+    action: Action
+    output: str
+    error: bool = False
+
+
+def _new_result_list() -> List[ActionResult]:
+    return []
+
+
+@dataclass
+class Reply:
+    answer: str
+    actions: List[ActionResult] = field(default_factory=_new_result_list)
+    # The model still wanted to act when the per-question action limit was reached.
+    limited: bool = False
+
+
+def parse_action(text: str) -> Optional[Action]:
+    """The debugger action requested in an LLM reply, or None for a plain answer."""
+    m = _ACTION_RE.search(text or "")
+    if m:
+        try:
+            data = json.loads(m.group(1))
+        except ValueError as e:
+            return Action("invalid", {"error": f"action is not valid JSON: {e}"})
+        if not isinstance(data, dict) or not data.get("tool"):
+            return Action("invalid", {"error": 'action must be a JSON object with a "tool" field'})
+        tool = str(data.pop("tool"))
+        return Action(tool, data)
+    m = _CMD_RE.search(text or "")
+    if m and m.group(1).strip():
+        return Action("command", {"text": m.group(1).strip()})
+    return None
+
+
+class Interactive:
+    """Question/answer loop that lets the LLM drive ``debugger`` between answers."""
+
+    def __init__(
+        self,
+        debugger: Any,
+        ask: Callable[[str], str],
+        *,
+        config: Optional[Mapping[str, str]] = None,
+        max_actions: int = DEFAULT_MAX_ACTIONS,
+        max_history_chars: int = DEFAULT_HISTORY_CHARS,
+        preamble: Optional[str] = None,
+        recorder: Any = None,
+        provider: str = "",
+        tools: Optional[ToolRegistry] = None,
+        chat: Optional[ToolChat] = None,
+    ) -> None:
+        self.debugger = debugger
+        self.ask_llm = ask
+        self.chat = chat
+        self.tools = tools if tools is not None else debugger_tools(debugger)
+        self.config = dict(config or {})
+        self.max_actions = max_actions
+        key = "interactive_tools_preamble" if chat is not None else "interactive_preamble"
+        self.preamble = preamble or DEFAULT_PROMPT_CONFIG[key]
+        # ``max_history_chars`` bounds the whole prompt; the preamble is sent every time.
+        self.history = History(max_chars=max(max_history_chars - len(self.preamble), 1000))
+        self.recorder = recorder
+        self.provider = provider
+        self._secrets: Dict[str, str] = {}
+
+    @property
+    def debugger_name(self) -> str:
+        return getattr(self.debugger, "name", "") or "debugger"
+
+    # ------------------------------------------------------------------
+    def ask(self, question: str) -> Reply:
+        """Answer ``question``, running the debugger actions the LLM requests on the way."""
+        self.history.add(ROLE_USER, question.strip())
+        if self.chat is not None:
+            return self._ask_with_tools()
+        results: List[ActionResult] = []
+        while True:
+            answer = self._complete().strip()
+            action = parse_action(answer)
+            if action is None:
+                self.history.add(ROLE_ASSISTANT, answer)
+                return Reply(answer, results)
+            if len(results) >= self.max_actions:
+                text = _strip_action(answer)
+                return self._limited(text, action.describe(), results)
+            self.history.add(ROLE_ASSISTANT, answer)
+            results.append(self.execute(action))
+
+    def _ask_with_tools(self) -> Reply:
+        messages = self.build_messages()
+        results: List[ActionResult] = []
+        while True:
+            reply = self._chat(messages)
+            if not reply.calls:
+                answer = reply.text.strip()
+                self.history.add(ROLE_ASSISTANT, answer)
+                return Reply(answer, results)
+            if len(results) >= self.max_actions:
+                return self._limited(reply.text.strip(), reply.calls[0].describe(), results)
+            messages.append(assistant_message(reply))
+            redactor = self._redactor()
+            for call in reply.calls:
+                if len(results) >= self.max_actions:
+                    # Every call needs an answer before the next request; refuse the ones over the cap.
+                    message = f"limit of {self.max_actions} tool calls for this question reached; answer now"
+                    refused = json.dumps({"error": ERROR_TOOL_LIMIT, "message": message})
+                    messages.append(tool_message(ToolResult(call.id, call.name, refused, is_error=True)))
+                    continue
+                result = self.execute(Action(call.name, call.arguments), call)
+                results.append(result)
+                content = redactor.redact(result.output, variable=_action_variable(result.action))
+                messages.append(tool_message(ToolResult(call.id, call.name, content, is_error=result.error)))
+
+    def _limited(self, text: str, wanted: str, results: List[ActionResult]) -> Reply:
+        note = (
+            f"(Stopped after {self.max_actions} debugger action(s) for this question; "
+            f"the model still wanted to run {wanted}. Ask again to continue.)"
+        )
+        self.history.add(ROLE_ASSISTANT, text or note)
+        return Reply("\n\n".join(p for p in (text, note) if p), results, limited=True)
+
+    def _redactor(self) -> Redactor:
+        return Redactor.from_config(self.config, known=self._secrets)
+
+    def _redacted_turns(self) -> List[Turn]:
+        redactor = self._redactor()
+        turns: List[Turn] = []
+        for turn in self.history.turns:
+            if turn.variable:
+                # First line names the action; only the output below it is the variable's value.
+                head, _, body = turn.text.partition("\n")
+                text = redactor.redact(head) + "\n" + redactor.redact(body, variable=turn.variable)
+            else:
+                text = redactor.redact(turn.text)
+            turns.append(Turn(turn.role, text))
+        return turns
+
+    def _pinned(self) -> str:
+        return self._redactor().redact(pinned_section(self.config, debugger=self.debugger))
+
+    def build_prompt(self) -> str:
+        lines = [self.preamble.format(debugger=self.debugger_name)]
+        if self.history.notice():
+            lines.append(self.history.notice())
+        lines.extend(turn.render() for turn in self._redacted_turns())
+        pinned = self._pinned()
+        if pinned:
+            lines.append(pinned)
+        lines.append(f"{ROLE_ASSISTANT}:")
+        return "\n".join(lines)
+
+    def build_messages(self) -> List[Dict[str, Any]]:
+        """The history as chat messages for function-calling providers."""
+        system = self.preamble.format(debugger=self.debugger_name)
+        if self.history.notice():
+            system += "\n" + self.history.notice()
+        pinned = self._pinned()
+        if pinned:
+            system += "\n" + pinned
+        messages: List[Dict[str, Any]] = [{"role": "system", "content": system}]
+        for turn in self._redacted_turns():
+            text = f"{ROLE_DEBUGGER} output: {turn.text}" if turn.role == ROLE_DEBUGGER else turn.text
+            messages.append({"role": _ROLE_MESSAGE[turn.role], "content": text})
+        return messages
+
+    def _complete(self) -> str:
+        prompt = self.build_prompt()
+        answer = self.ask_llm(prompt)
+        if self.recorder is not None and not self.recorder.closed:
+            self.recorder.record_llm(
+                prompt, answer, provider=self.provider, cache_hit=bool(getattr(self.ask_llm, "last_cache_hit", False))
+            )
+        return answer
+
+    def _chat(self, messages: List[Dict[str, Any]]) -> ToolReply:
+        assert self.chat is not None
+        reply = self.chat(messages, self.tools.tools())
+        if self.recorder is not None and not self.recorder.closed:
+            response = reply.text + "".join(f"\n[tool call] {c.describe()}" for c in reply.calls)
+            self.recorder.record_llm(render_messages(messages), response, provider=self.provider)
+        return reply
+
+    # ------------------------------------------------------------------
+    def execute(self, action: Action, call: Optional[ToolCall] = None) -> ActionResult:
+        """Run ``action`` through the tool registry and add its result to the history."""
+        if action.tool == "invalid":
+            message = f"{action.args.get('error')}. Available tools: {', '.join(self.tools.names())}."
+            output = json.dumps({"error": ERROR_INVALID_ARGUMENTS, "message": message, "available": self.tools.names()})
+            result = ActionResult(action, output, error=True)
+        else:
+            tool_result = self.tools.invoke(call or ToolCall("", action.tool, action.args))
+            result = ActionResult(action, tool_result.content, error=tool_result.is_error)
+        self.history.add(
+            ROLE_DEBUGGER,
+            f"{action.describe()}\n" + head_tail_truncate(result.output, ACTION_RESULT_CHARS),
+            variable=_action_variable(action),
+        )
+        return result
+
+    def pin_command(self, line: str) -> Optional[str]:
+        """Apply a ``pin``/``unpin`` line and return what to print, or None when ``line`` is a question."""
+        match = _PIN_RE.match(line.strip())
+        if match is None:
+            return None
+        verb = (match.group("slash") or match.group("verb") or match.group("all")).lower()
+        arg = (match.group("any") or match.group("arg") or ("all" if match.group("all") else "")).strip()
+        if len(arg) > 1 and arg[0] == arg[-1] == '"':
+            arg = arg[1:-1]
+        try:
+            pins = pins_from_config(self.config)
+            if verb == "pin" and arg:
+                pin = Pin.parse(arg)
+                if pin not in pins:
+                    pins.append(pin)
+            elif verb == "unpin":
+                if not arg:
+                    return 'Usage: unpin <id|"text">|all'
+                if arg.lower() == "all":
+                    pins = []
+                else:
+                    pin = Pin.parse(arg)
+                    if pin not in pins:
+                        return f"Not pinned: {pin.describe()}"
+                    pins.remove(pin)
+        except ValueError as e:
+            return str(e)
+        self.config[PINS_KEY] = pins_to_config(pins)
+        return "Pinned: " + ", ".join(p.describe() for p in pins) if pins else "No pinned goroutines."
+
+    # ------------------------------------------------------------------
+    def run(
+        self,
+        read: Callable[[str], str] = input,
+        write: Callable[[str], None] = print,
+        *,
+        prompt: str = "copilot> ",
+    ) -> None:
+        """Read questions until ``exit``/``quit`` or end of input, printing actions and answers."""
+        write(f"Interactive session on {self.debugger_name}. Ask a question, 'pin <id|\"text\">', or 'exit' to leave.")
+        while True:
+            try:
+                question = read(prompt)
+            except (EOFError, KeyboardInterrupt):
+                write("")
+                return
+            if not question.strip():
+                continue
+            if question.strip().lower() in _EXIT_WORDS:
+                return
+            pinned = self.pin_command(question)
+            if pinned is not None:
+                write(pinned)
+                continue
+            dropped = self.history.dropped
+            try:
+                reply = self.ask(question)
+            except Cancelled:
+                # Ctrl-C or --timeout: the caller ends the session.
+                raise
+            except LLMError as e:
+                # Retries are spent by now, but a rate limit or an overloaded server may pass before
+                # the next question; a bad key or a malformed request will not.
+                if not e.retryable:
+                    raise
+                write(f"LLM error: {e}")
+                continue
+            except DebuggerUnavailable:
+                raise
+            except DebuggerError as e:
+                write(f"Debugger error: {e}")
+                continue
+            for result in reply.actions:
+                write(f"[{self.debugger_name}] {result.action.describe()}" + (" failed" if result.error else ""))
+            write(reply.answer)
+            if self.history.dropped > dropped:
+                write(f"(history trimmed: {self.history.dropped - dropped} older message(s) no longer sent to the LLM)")
+
+
+def _action_variable(action: Action) -> str:
+    return str(action.args.get("expr") or "") or command_variable(str(action.args.get("text") or ""))
+
+
+def _strip_action(text: str) -> str:
+    return _CMD_RE.sub("", _ACTION_RE.sub("", text or "")).strip()
+
+
+__all__ = [
+    "ACTION_RESULT_CHARS",
+    "Action",
+    "ActionResult",
+    "DEFAULT_HISTORY_CHARS",
+    "DEFAULT_MAX_ACTIONS",
+    "History",
+    "Interactive",
+    "Reply",
+    "parse_action",
+]
@@ -0,0 +1,147 @@
+"""Newline-delimited JSON transcript of a copilot session.
+
+Each line is one event. The first line is a ``session`` header describing the
+debugger and, for a program the debugger launched, its ``invocation``
+(arguments and environment, see ``dbgcopilot.debugger.launch``), followed
+by ``command`` events (debugger input and raw output) and ``llm`` events
+(the prompt sent, the response received and whether it was served from the
+response cache). A clean shutdown writes a final ``end`` event; its absence
+tells replay the recording was cut short.
+"""
+from __future__ import annotations
+
+from typing import Any, Callable, Dict, IO, Optional
+import json
+import time
+
+FORMAT_VERSION = 1
+
+EVENT_SESSION = "session"
+EVENT_COMMAND = "command"
+EVENT_LLM = "llm"
+EVENT_END = "end"
+
+
+class Recorder:
+    """Append session events to an NDJSON file, flushing after every line."""
+
+    def __init__(self, path: str, *, clock: Callable[[], float] = time.time) -> None:
+        self.path = path
+        self._clock = clock
+        self._fh: Optional[IO[str]] = open(path, "w", encoding="utf-8")
+        self._seq = 0
+        self._steps = 0
+
+    @property
+    def closed(self) -> bool:
+        return self._fh is None
+
+    def _write(self, event: Dict[str, Any]) -> None:
+        if self._fh is None:
+            raise RuntimeError(f"Recorder for {self.path} is closed")
+        record = {"seq": self._seq, "ts": round(self._clock(), 3)}
+        record.update(event)
+        self._fh.write(json.dumps(record, ensure_ascii=False) + "\n")
+        self._fh.flush()
+        self._seq += 1
+
+    def start(self, backend: Any, **meta: Any) -> None:
+        """Write the session header and start capturing ``backend.run_command``."""
+        header: Dict[str, Any] = {
+            "type": EVENT_SESSION,
+            "version": FORMAT_VERSION,
+            "debugger": getattr(backend, "name", "") or "",
+            "prompt": getattr(backend, "prompt", "") or "",
+        }
+        program = getattr(backend, "program", None)
+        if program:
+            header["program"] = program
+        header.update({k: v for k, v in meta.items() if v is not None})
+        self._write(header)
+        self.attach(backend)
+
+    def attach(self, backend: Any) -> None:
+        """Wrap the instance's ``run_command`` so every debugger round trip is recorded.
+
+        The wrapper is installed on the instance, so structured drivers that
+        call ``self.run_command`` internally are captured as well.
+        """
+        original = backend.run_command
+
+        def _recording_run_command(cmd: str, *args: Any, **kwargs: Any) -> str:
+            started = self._clock()
+            try:
+                out = original(cmd, *args, **kwargs)
+            except Exception as e:
+                if not self.closed:
+                    self.record_command(cmd, error=str(e), elapsed=self._clock() - started)
+                raise
+            if not self.closed:
+                self.record_command(cmd, output=out, elapsed=self._clock() - started)
+            return out
+
+        _recording_run_command.__wrapped__ = original  # type: ignore[attr-defined]
+        backend.run_command = _recording_run_command
+
+    def record_command(
+        self, cmd: str, output: str = "", *, error: Optional[str] = None, elapsed: float = 0.0
+    ) -> None:
+        event: Dict[str, Any] = {
+            "type": EVENT_COMMAND,
+            "step": self._steps,
+            "cmd": cmd,
+            "output": output or "",
+            "elapsed_ms": int(elapsed * 1000),
+        }
+        if error is not None:
+            event["error"] = error
+        self._write(event)
+        self._steps += 1
+
+    def record_llm(
+        self,
+        prompt: str,
+        response: str,
+        *,
+        provider: Optional[str] = None,
+        cancelled: bool = False,
+        cache_hit: bool = False,
+    ) -> None:
+        event: Dict[str, Any] = {
+            "type": EVENT_LLM,
+            "step": self._steps,
+            "provider": provider or "",
+            "prompt": prompt,
+            "response": response,
+            "cache_hit": cache_hit,
+        }
+        if cancelled:
+            event["cancelled"] = True
+        self._write(event)
+        self._steps += 1
+
+    def close(self) -> None:
+        if self._fh is None:
+            return
+        self._write({"type": EVENT_END, "steps": self._steps})
+        self._fh.close()
+        self._fh = None
+
+
+def detach(backend: Any) -> None:
+    """Restore the ``run_command`` that ``Recorder.attach`` replaced, if any."""
+    current = getattr(backend, "run_command", None)
+    original = getattr(current, "__wrapped__", None)
+    if original is not None:
+        backend.run_command = original
+
+
+__all__ = [
+    "EVENT_COMMAND",
+    "EVENT_END",
+    "EVENT_LLM",
+    "EVENT_SESSION",
+    "FORMAT_VERSION",
+    "Recorder",
+    "detach",
+]
@@ -0,0 +1,204 @@
+"""Deterministic playback of a recorded session transcript.
+
+``replay(path)`` loads a transcript written by ``Recorder`` and exposes a mock
+debugger backend plus an LLM callable that return the recorded responses in
+order, so a session can be re-driven without the original binary or a live
+provider.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, List, Optional
+import json
+
+from dbgcopilot.debugger.launch import Invocation
+
+from .recorder import EVENT_COMMAND, EVENT_END, EVENT_LLM, EVENT_SESSION, FORMAT_VERSION
+
+
+class ReplayError(RuntimeError):
+    """A replayed step does not match the transcript."""
+
+
+class ReplayTruncated(ReplayError):
+    """The transcript ends before the step being replayed."""
+
+
+def _new_event_list() -> List[Dict[str, Any]]:
+    return []
+
+
+def _new_meta_dict() -> Dict[str, Any]:
+    return {}
+
+
+@dataclass
+class Transcript:
+    path: str
+    meta: Dict[str, Any] = field(default_factory=_new_meta_dict)
+    events: List[Dict[str, Any]] = field(default_factory=_new_event_list)
+    # True when the recording has no end marker or its last line is incomplete.
+    truncated: bool = False
+    bad_line: Optional[int] = None
+
+    def of_type(self, kind: str) -> List[Dict[str, Any]]:
+        return [e for e in self.events if e.get("type") == kind]
+
+    @property
+    def invocation(self) -> Invocation:
+        """The recorded program's arguments and environment; the default when the header has none."""
+        return Invocation.from_dict(self.meta.get("invocation"))
+
+
+def load_transcript(path: str) -> Transcript:
+    with open(path, "r", encoding="utf-8") as fh:
+        lines = fh.read().split("\n")
+    transcript = Transcript(path=path, truncated=True)
+    for lineno, line in enumerate(lines, 1):
+        if not line.strip():
+            continue
+        try:
+            event = json.loads(line)
+        except ValueError:
+            # A partial last line means the writer died mid-event; anything
+            # after it cannot be trusted, so stop here.
+            transcript.bad_line = lineno
+            transcript.truncated = True
+            break
+        kind = event.get("type")
+        if kind == EVENT_SESSION:
+            version = event.get("version", FORMAT_VERSION)
+            if version > FORMAT_VERSION:
+                raise ReplayError(
+                    f"{path}: transcript format v{version} is newer than supported v{FORMAT_VERSION}"
+                )
+            transcript.meta = event
+        elif kind == EVENT_END:
+            transcript.truncated = False
+        elif kind in {EVENT_COMMAND, EVENT_LLM}:
+            transcript.events.append(event)
+    return transcript
+
+
+def _exhausted(transcript: Transcript, what: str, position: int) -> ReplayError:
+    if transcript.truncated:
+        where = f" (line {transcript.bad_line} is incomplete)" if transcript.bad_line else ""
+        return ReplayTruncated(
+            f"Cannot reproduce {what}: transcript {transcript.path} is truncated after "
+            f"{position} recorded {'entry' if position == 1 else 'entries'}{where}"
+        )
+    return ReplayError(f"Cannot reproduce {what}: the recorded session has no more entries of that kind")
+
+
+class ReplayBackend:
+    """Mock debugger that answers commands from a transcript, in recorded order."""
+
+    def __init__(self, transcript: Transcript) -> None:
+        self.transcript = transcript
+        self.name = transcript.meta.get("debugger") or "replay"
+        self.prompt = transcript.meta.get("prompt") or f"({self.name}) "
+        self.program = transcript.meta.get("program")
+        self.invocation = transcript.invocation
+        self._commands = transcript.of_type(EVENT_COMMAND)
+        self._cursor = 0
+
+    @property
+    def remaining(self) -> int:
+        return len(self._commands) - self._cursor
+
+    def initialize_session(self) -> None:
+        return None
+
+    def run_command(self, cmd: str, timeout: Optional[float] = None) -> str:
+        if self._cursor >= len(self._commands):
+            raise _exhausted(self.transcript, f"'{cmd}'", len(self._commands))
+        event = self._commands[self._cursor]
+        recorded = event.get("cmd", "")
+        if recorded.strip() != cmd.strip():
+            raise ReplayError(
+                f"Replay diverged at step {event.get('step', self._cursor)}: "
+                f"expected '{recorded}', got '{cmd}'"
+            )
+        self._cursor += 1
+        if "error" in event:
+            raise RuntimeError(event["error"])
+        return event.get("output", "")
+
+    def close(self) -> None:
+        return None
+
+
+class ReplayLLM:
+    """LLM stand-in returning recorded responses in order.
+
+    When ``live`` is given it is used once the recorded responses run out;
+    by default replay never reaches a real provider.
+    """
+
+    def __init__(self, transcript: Transcript, live: Optional[Callable[[str], str]] = None) -> None:
+        self.transcript = transcript
+        self.live = live
+        self._responses = transcript.of_type(EVENT_LLM)
+        self._cursor = 0
+
+    @property
+    def remaining(self) -> int:
+        return len(self._responses) - self._cursor
+
+    def __call__(self, prompt: str) -> str:
+        if self._cursor >= len(self._responses):
+            if self.live is not None:
+                return self.live(prompt)
+            raise _exhausted(self.transcript, "LLM response", len(self._responses))
+        event = self._responses[self._cursor]
+        self._cursor += 1
+        return event.get("response", "")
+
+
+class Replay:
+    """A loaded transcript plus the mock backend and LLM that replay it."""
+
+    def __init__(self, transcript: Transcript, live_llm: Optional[Callable[[str], str]] = None) -> None:
+        self.transcript = transcript
+        self.backend = ReplayBackend(transcript)
+        self.llm = ReplayLLM(transcript, live=live_llm)
+
+    @property
+    def truncated(self) -> bool:
+        return self.transcript.truncated
+
+    def steps(self) -> List[Dict[str, Any]]:
+        return list(self.transcript.events)
+
+    def describe(self) -> str:
+        meta = self.transcript.meta
+        commands = len(self.transcript.of_type(EVENT_COMMAND))
+        answers = len(self.transcript.of_type(EVENT_LLM))
+        target = f" on {meta['program']}" if meta.get("program") else ""
+        text = (
+            f"Replaying {self.backend.name} session{target}: "
+            f"{commands} debugger commands, {answers} LLM responses."
+        )
+        invocation = self.transcript.invocation
+        if meta.get("program") and not invocation.default:
+            text += f" The program was started as: {invocation.describe(meta['program'])}"
+        if self.truncated:
+            text += " Warning: transcript is truncated; later steps cannot be reproduced."
+        return text
+
+
+def replay(path: str, *, live_llm: Optional[Callable[[str], str]] = None) -> Replay:
+    """Load ``path`` and return a ``Replay`` ready to drive a session."""
+    return Replay(load_transcript(path), live_llm=live_llm)
+
+
+__all__ = [
+    "Replay",
+    "ReplayBackend",
+    "ReplayError",
+    "ReplayLLM",
+    "ReplayTruncated",
+    "Transcript",
+    "load_transcript",
+    "replay",
+]
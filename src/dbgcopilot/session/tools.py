@@ -0,0 +1,195 @@
+"""Debugger operations offered to the LLM as function-calling tools.
+
+``debugger_tools`` builds the registry the interactive loop hands to
+providers: set_breakpoint, continue, step, next, step_out, stacktrace,
+read_variable, eval_in_frame and list_goroutines use the structured debugger
+API when the backend has one and fall back to CLI commands otherwise
+(eval_in_frame only to Delve's frame-scoped print; elsewhere it is an
+``unsupported`` tool error); ``command`` runs any other single debugger
+command.
+"""
+from __future__ import annotations
+
+from typing import Any, Dict
+
+from dbgcopilot.analyze import condense_goroutine_output, format_stacktrace
+from dbgcopilot.llm.tools import Tool, ToolRegistry, ToolUnsupported
+
+
+def _object(properties: Dict[str, Any], *required: str) -> Dict[str, Any]:
+    return {"type": "object", "properties": properties, "required": list(required), "additionalProperties": False}
+
+
+SET_BREAKPOINT_SCHEMA = _object(
+    {
+        "location": {
+            "type": "string",
+            "description": "file:line, function name (main.workerOne) or address; a pattern when regex is true",
+        },
+        "regex": {"type": "boolean", "description": "break on every function matching location as a regex"},
+        "condition": {"type": "string", "description": "stop only when this expression is true"},
+        "hitcount": {"type": "integer", "description": "stop only after this many hits"},
+    },
+    "location",
+)
+CONTINUE_SCHEMA = _object(
+    {
+        "aggregate": {
+            "type": "boolean",
+            "description": "continue through repeated breakpoint hits (a loop) and return a per-location summary",
+        },
+        "threshold": {"type": "integer", "description": "with aggregate, summarize bursts of more hits than this"},
+        "expressions": {
+            "type": "array",
+            "items": {"type": "string"},
+            "description": "with aggregate, expressions to track at each hit instead of all locals",
+        },
+    }
+)
+STEP_SCHEMA = _object({})
+STACKTRACE_SCHEMA = _object({"goroutine": {"type": "integer", "description": "goroutine id, current if omitted"}})
+READ_VARIABLE_SCHEMA = _object({"expr": {"type": "string", "description": "expression to evaluate"}}, "expr")
+EVAL_IN_FRAME_SCHEMA = _object(
+    {
+        "expr": {"type": "string", "description": "expression such as len(m), s.field.ptr or n*2 > cap(q)"},
+        "frame": {"type": "integer", "description": "stack frame index, 0 is the innermost"},
+        "goroutine": {"type": "integer", "description": "goroutine or thread id, current if omitted"},
+    },
+    "expr",
+    "frame",
+)
+LIST_GOROUTINES_SCHEMA = _object({})
+COMMAND_SCHEMA = _object({"text": {"type": "string", "description": "one raw debugger command"}}, "text")
+
+
+def _structured(debugger: Any, method: str) -> bool:
+    return callable(getattr(debugger, method, None))
+
+
+def debugger_tools(debugger: Any) -> ToolRegistry:
+    """The tool registry for ``debugger``; handlers return the text the model sees."""
+    name = getattr(debugger, "name", "") or "debugger"
+
+    def set_breakpoint(args: Dict[str, Any]) -> str:
+        location = str(args["location"])
+        condition = str(args.get("condition") or "")
+        regex = bool(args.get("regex"))
+        if _structured(debugger, "set_breakpoint"):
+            from dbgcopilot.debugger.base import BreakpointSpec, describe_placed, place_breakpoints
+
+            hit_count = int(args.get("hitcount") or 0)
+            spec = BreakpointSpec(location=location, condition=condition, hit_count=hit_count, regex=regex)
+            return describe_placed(spec, place_breakpoints(debugger, spec))
+        if regex:
+            if name in ("gdb", "rust-gdb"):
+                if condition:
+                    raise ToolUnsupported(f"{name}'s rbreak takes no condition; set it per breakpoint with command")
+                return debugger.run_command(f"rbreak {location}")
+            if name in ("lldb", "rust-lldb"):
+                return debugger.run_command(
+                    f"breakpoint set -r {location}" + (f' -c "{condition}"' if condition else "")
+                )
+            if name != "delve":
+                raise ToolUnsupported(f"{name} has no regex breakpoints; set one per function with command")
+            location = f"/{location}/"
+        return debugger.run_command(f"break {location}" + (f" if {condition}" if condition else ""))
+
+    def continue_(args: Dict[str, Any]) -> str:
+        if args.get("aggregate") and _structured(debugger, "continue_"):
+            from dbgcopilot.debugger.loops import DEFAULT_AGGREGATE_THRESHOLD, HitAggregator
+
+            threshold = int(args.get("threshold") or DEFAULT_AGGREGATE_THRESHOLD)
+            expressions = [str(expr) for expr in args.get("expressions") or []]
+            aggregator = HitAggregator(debugger, threshold=threshold, expressions=expressions)
+            aggregator.run()
+            return aggregator.describe()
+        if _structured(debugger, "continue_"):
+            event = debugger.continue_()
+            return f"{event.describe()}\n{event.raw}".strip()
+        return debugger.run_command("continue")
+
+    def stepper(method: str, fallback: str):
+        def run(args: Dict[str, Any]) -> str:
+            if _structured(debugger, method):
+                event = getattr(debugger, method)()
+                stack = format_stacktrace(event.stack)
+                return "\n".join(part for part in (event.describe(), stack) if part)
+            return debugger.run_command(fallback)
+
+        return run
+
+    def stacktrace(args: Dict[str, Any]) -> str:
+        goroutine = args.get("goroutine")
+        if _structured(debugger, "stacktrace"):
+            return format_stacktrace(debugger.stacktrace(goroutine)) or "(no frames)"
+        return debugger.run_command(f"goroutine {goroutine} bt" if goroutine is not None else "bt")
+
+    def read_variable(args: Dict[str, Any]) -> str:
+        expr = str(args["expr"])
+        if _structured(debugger, "read_variable"):
+            from dbgcopilot.debugger.pretty import pretty_print
+
+            return f"{expr} = {pretty_print(debugger.read_variable(expr))}"
+        return debugger.run_command(f"print {expr}")
+
+    def eval_in_frame(args: Dict[str, Any]) -> str:
+        expr, frame, goroutine = str(args["expr"]), int(args["frame"]), args.get("goroutine")
+        if _structured(debugger, "eval_in_frame"):
+            from dbgcopilot.debugger.pretty import pretty_print
+
+            return f"{expr} = {pretty_print(debugger.eval_in_frame(goroutine, frame, expr))}"
+        if name != "delve":
+            raise ToolUnsupported(f"{name} has no frame-scoped evaluation; select the frame with command, then print")
+        # Delve's own syntax for a frame-scoped print.
+        scope = (f"goroutine {goroutine} " if goroutine is not None else "") + f"frame {frame} "
+        return debugger.run_command(f"{scope}print {expr}")
+
+    def list_goroutines(args: Dict[str, Any]) -> str:
+        command = getattr(debugger, "GOROUTINES_COMMAND", "") or "thread backtrace all"
+        return condense_goroutine_output(debugger.run_command(command))
+
+    def command(args: Dict[str, Any]) -> str:
+        return debugger.run_command(str(args["text"]))
+
+    return ToolRegistry(
+        [
+            Tool(
+                "set_breakpoint",
+                "Set a breakpoint, optionally conditional or on every function a regex matches.",
+                SET_BREAKPOINT_SCHEMA,
+                set_breakpoint,
+            ),
+            Tool(
+                "continue",
+                "Resume the process until the next stop, or with aggregate summarize a loop's repeated hits.",
+                CONTINUE_SCHEMA,
+                continue_,
+            ),
+            Tool("step", "Execute one source line, entering function calls.", STEP_SCHEMA, stepper("step", "step")),
+            Tool(
+                "next",
+                "Execute one source line, stepping over function calls.",
+                STEP_SCHEMA,
+                stepper("next", "next"),
+            ),
+            Tool(
+                "step_out",
+                "Run until the current function returns to its caller.",
+                STEP_SCHEMA,
+                stepper("step_out", "stepout" if name == "delve" else "finish"),
+            ),
+            Tool("stacktrace", "Stack frames of one goroutine or thread.", STACKTRACE_SCHEMA, stacktrace),
+            Tool("read_variable", "Evaluate and pretty-print an expression.", READ_VARIABLE_SCHEMA, read_variable),
+            Tool(
+                "eval_in_frame",
+                "Evaluate an expression in the scope of one stack frame, to check a hypothesis.",
+                EVAL_IN_FRAME_SCHEMA,
+                eval_in_frame,
+            ),
+            Tool("list_goroutines", "All goroutines, grouped by stack.", LIST_GOROUTINES_SCHEMA, list_goroutines),
+            Tool("command", f"Run any other single {name} command.", COMMAND_SCHEMA, command),
+        ]
+    )
+
+
+__all__ = ["debugger_tools"]
@@ -0,0 +1,206 @@
+"""Cancellation and deadlines shared by the debugger, LLM and analysis layers.
+
+A ``Context`` plays the part of Go's ``context.Context``: it carries an
+optional deadline and a cancelled flag from the top-level entry point down
+to every blocking call. ``Context.with_timeout(30)`` starts one (``dbgagent
+--timeout``); ``child()`` derives one that is cancelled with its parent but
+can also be cancelled on its own (one streamed LLM answer in the REPL).
+
+Blocking code asks the context how long it may wait with ``timeout(cap)``
+and calls ``check()`` afterwards, which raises ``Cancelled`` (or
+``DeadlineExceeded``, also a ``TimeoutError``) once the context is done.
+Work that cannot poll, such as an HTTP stream or a ``continue`` in Delve,
+registers an ``on_cancel`` callback that unblocks it from another thread:
+closing the response, interrupting the debugger. Callbacks run once, on the
+thread that cancelled, or on a timer thread when the deadline passes, so
+they must only interrupt; the thread that owns the resource does the
+cleanup (``Debugger.abort``) when it sees the error.
+
+Debuggers take their context when they are created. LLM providers use the
+context that is active on the calling thread (``activate``/``current``), so
+the ``ask(prompt)`` callables keep their signature; without one they run
+under ``background()``, which is never cancelled.
+"""
+from __future__ import annotations
+
+from contextlib import contextmanager
+from contextvars import ContextVar
+from typing import Callable, Iterator, List, Optional
+import threading
+import time
+
+
+class Cancelled(Exception):
+    """The context was cancelled (Ctrl-C, a caller giving up) or its deadline passed."""
+
+
+class DeadlineExceeded(Cancelled, TimeoutError):
+    """The context's deadline passed."""
+
+
+class Context:
+    def __init__(
+        self,
+        *,
+        parent: Optional["Context"] = None,
+        timeout: Optional[float] = None,
+        clock: Callable[[], float] = time.monotonic,
+    ) -> None:
+        self._clock = clock
+        self._lock = threading.Lock()
+        self._callbacks: List[Callable[[], None]] = []
+        self._error: Optional[Cancelled] = None
+        self._timer: Optional[threading.Timer] = None
+        self.done = threading.Event()
+        self.timeout_seconds = timeout
+        # Our own deadline, only when it is earlier than the one inherited from the parent.
+        own = clock() + timeout if timeout is not None else None
+        if own is not None and parent is not None and parent.deadline is not None and parent.deadline <= own:
+            own = None
+        self._own_deadline = own
+        # clock() value after which the context is done; None for no deadline.
+        self.deadline = own if own is not None else (parent.deadline if parent is not None else None)
+        self._parent = parent
+        self._detach_parent: Optional[Callable[[], None]] = None
+        if parent is not None:
+            self._detach_parent = parent.on_cancel(lambda: self.cancel(parent.error))
+        if own is not None and not self.done.is_set():
+            self._timer = threading.Timer(max(own - clock(), 0.0), self._expire)
+            self._timer.daemon = True
+            self._timer.start()
+
+    @classmethod
+    def background(cls) -> "Context":
+        """A context that is never cancelled and has no deadline."""
+        return _BACKGROUND
+
+    @classmethod
+    def with_timeout(cls, seconds: Optional[float]) -> "Context":
+        """A new root context, done ``seconds`` from now (None or 0 for no deadline)."""
+        return cls(timeout=seconds or None)
+
+    def child(self, timeout: Optional[float] = None) -> "Context":
+        """A context cancelled with this one, by its own ``cancel`` or when its (earlier) timeout passes."""
+        return Context(parent=self, timeout=timeout, clock=self._clock)
+
+    # ------------------------------------------------------------------
+    @property
+    def cancelled(self) -> bool:
+        return self.done.is_set()
+
+    @property
+    def error(self) -> Optional[Cancelled]:
+        """Why the context is done: ``DeadlineExceeded``, ``Cancelled`` or None while it is live."""
+        if self._error is None:
+            if self._parent is not None and self._parent.error is not None:
+                self.cancel(self._parent.error)
+            elif self._own_deadline is not None and self._clock() >= self._own_deadline:
+                self._expire()
+        return self._error
+
+    def cancel(self, reason: "str | Cancelled | None" = None) -> None:
+        """Mark the context done and run its ``on_cancel`` callbacks (only the first call does anything)."""
+        if isinstance(reason, Cancelled):
+            error = reason
+        else:
+            error = Cancelled(reason or "cancelled")
+        with self._lock:
+            if self._error is not None:
+                return
+            self._error = error
+            callbacks, self._callbacks = self._callbacks, []
+            self.done.set()
+        if self._timer is not None:
+            self._timer.cancel()
+        if self._detach_parent is not None:
+            self._detach_parent()
+        for callback in reversed(callbacks):
+            try:
+                callback()
+            except Exception:
+                # Interrupting is best-effort; the owner still sees the error and cleans up.
+                pass
+
+    def _expire(self) -> None:
+        self.cancel(DeadlineExceeded(f"timed out after {self.timeout_seconds or 0:g}s"))
+
+    def on_cancel(self, callback: Callable[[], None]) -> Callable[[], None]:
+        """Run ``callback`` when the context is cancelled (now, if it already is); returns an unregister function."""
+        with self._lock:
+            if self._error is None:
+                self._callbacks.append(callback)
+                registered = True
+            else:
+                registered = False
+        if not registered:
+            callback()
+            return _noop
+
+        def unregister() -> None:
+            with self._lock:
+                if callback in self._callbacks:
+                    self._callbacks.remove(callback)
+
+        return unregister
+
+    # ------------------------------------------------------------------
+    def remaining(self) -> Optional[float]:
+        """Seconds until the deadline (0 once it passed), or None without one."""
+        if self.deadline is None:
+            return None
+        return max(self.deadline - self._clock(), 0.0)
+
+    def timeout(self, cap: Optional[float] = None) -> Optional[float]:
+        """How long a blocking call may wait: ``cap`` bounded by the deadline (None is unbounded).
+
+        Raises the context's error when it is already done, so a call is
+        never started with a zero timeout.
+        """
+        self.check()
+        left = self.remaining()
+        if left is None:
+            return cap
+        return left if cap is None else min(cap, left)
+
+    def check(self) -> None:
+        error = self.error
+        if error is not None:
+            raise error
+
+    def sleep(self, seconds: float) -> None:
+        """Wait ``seconds``, waking early (and raising) when the context is done."""
+        self.check()
+        self.done.wait(max(seconds, 0.0))
+        self.check()
+
+    @contextmanager
+    def activate(self) -> Iterator["Context"]:
+        """Make this the ``current()`` context of the calling thread for a block."""
+        token = _current.set(self)
+        try:
+            yield self
+        finally:
+            _current.reset(token)
+
+    def close(self) -> None:
+        """Release the deadline timer and the link to the parent; the context stays as it is."""
+        if self._timer is not None:
+            self._timer.cancel()
+        if self._detach_parent is not None:
+            self._detach_parent()
+
+
+def _noop() -> None:
+    pass
+
+
+_BACKGROUND = Context()
+_current: ContextVar[Context] = ContextVar("dbgcopilot_context", default=_BACKGROUND)
+
+
+def current() -> Context:
+    """The context activated on this thread, or ``background()``."""
+    return _current.get()
+
+
+__all__ = ["Cancelled", "Context", "DeadlineExceeded", "current"]
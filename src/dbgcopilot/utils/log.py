@@ -0,0 +1,184 @@
+"""Leveled, structured logging for the debugger and LLM layers.
+
+Every module logs through ``get_logger(name)`` (a child of the
+``dbgcopilot`` logger) and attaches key/value attributes with ``log``;
+``configure`` sends them to stderr as one ``key=value`` line per record,
+in the style of Go's ``slog.TextHandler``, so stdout stays a single JSON or
+Markdown document::
+
+    time=2026-10-14T09:12:03.104Z level=DEBUG logger=debugger msg="debugger request" backend=delve cmd=goroutines
+
+At ``debug`` level each debugger request and response (``trace_debugger``)
+and each LLM prompt and answer (``trace_llm``) is logged in full, after the
+same secret redaction as prompts; the runner hands its ``Redactor`` to
+``set_redactor`` so literals it already hid stay hidden in the trace too.
+The redactor is context-local (a ``ContextVar``): batch workers each
+analyzing their own target redact with their own session's secrets.
+
+Tests swap the output with ``capture()``, which collects records in a list
+instead of writing them anywhere.
+"""
+from __future__ import annotations
+
+from contextlib import contextmanager
+from contextvars import ContextVar
+from datetime import datetime, timezone
+from typing import Any, Iterator, List, Optional, TextIO
+import json
+import logging
+import os
+import sys
+
+from .redact import Redactor
+
+LOGGER_NAME = "dbgcopilot"
+LEVEL_ENV_VAR = "DBGCOPILOT_LOG_LEVEL"
+LEVELS = ("debug", "info", "warning", "error")
+DEFAULT_LEVEL = "warning"
+
+_ATTRS = "dbgcopilot_attrs"
+_ESCAPES = str.maketrans({"\\": "\\\\", '"': '\\"', "\n": "\\n", "\r": "\\r", "\t": "\\t"})
+
+
+def get_logger(name: str) -> logging.Logger:
+    """The ``dbgcopilot.<name>`` logger, e.g. ``get_logger("debugger")``."""
+    return logging.getLogger(f"{LOGGER_NAME}.{name}")
+
+
+def log(logger: logging.Logger, level: int, msg: str, **attrs: Any) -> None:
+    """Log ``msg`` with ``attrs`` rendered as ``key=value`` after it."""
+    if logger.isEnabledFor(level):
+        logger.log(level, msg, extra={_ATTRS: attrs})
+
+
+def trace_debugger(backend: str, kind: str, **attrs: Any) -> None:
+    """Debug-level record of one debugger ``request`` or ``response``; text values are redacted."""
+    logger = get_logger("debugger")
+    if logger.isEnabledFor(logging.DEBUG):
+        log(logger, logging.DEBUG, f"debugger {kind}", backend=backend, **_redacted(attrs))
+
+
+def trace_llm(provider: str, kind: str, **attrs: Any) -> None:
+    """Debug-level record of one LLM ``prompt`` or ``response``; text values are redacted."""
+    logger = get_logger("llm")
+    if logger.isEnabledFor(logging.DEBUG):
+        log(logger, logging.DEBUG, f"llm {kind}", provider=provider, **_redacted(attrs))
+
+
+def _redacted(attrs: dict[str, Any]) -> dict[str, Any]:
+    """Text values redacted; dicts and lists (JSON-RPC params and results) encoded as JSON first."""
+    out: dict[str, Any] = {}
+    for key, value in attrs.items():
+        if isinstance(value, (dict, list)):
+            value = json.dumps(value, default=str, ensure_ascii=False)
+        out[key] = _redactor.get().redact(value) if isinstance(value, str) else value
+    return out
+
+
+class KeyValueFormatter(logging.Formatter):
+    """``time=... level=... logger=... msg=... key=value`` lines; values with spaces or quotes are quoted."""
+
+    def format(self, record: logging.LogRecord) -> str:
+        stamp = datetime.fromtimestamp(record.created, timezone.utc).isoformat(timespec="milliseconds")
+        name = record.name[len(LOGGER_NAME) + 1 :] if record.name.startswith(LOGGER_NAME + ".") else record.name
+        pairs: List[tuple[str, Any]] = [
+            ("time", stamp.replace("+00:00", "Z")),
+            ("level", record.levelname),
+            ("logger", name),
+            ("msg", record.getMessage()),
+        ]
+        pairs += list(getattr(record, _ATTRS, {}).items())
+        if record.exc_info:
+            pairs.append(("error", self.formatException(record.exc_info)))
+        return " ".join(f"{key}={_quote(value)}" for key, value in pairs)
+
+
+def _quote(value: Any) -> str:
+    text = value if isinstance(value, str) else str(value)
+    if text and not any(ch in text for ch in ' "=\\') and text.isprintable():
+        return text
+    return '"' + text.translate(_ESCAPES) + '"'
+
+
+def parse_level(name: Optional[str]) -> int:
+    """``logging`` level for one of ``LEVELS`` (case-insensitive); ValueError otherwise."""
+    text = (name or DEFAULT_LEVEL).strip().lower()
+    if text not in LEVELS:
+        raise ValueError(f"Unknown log level {name!r}; expected one of {', '.join(LEVELS)}")
+    return getattr(logging, text.upper())
+
+
+_handler: Optional[logging.Handler] = None
+_redactor: ContextVar[Redactor] = ContextVar("dbgcopilot_log_redactor", default=Redactor())
+
+
+def configure(level: Optional[str] = None, *, stream: Optional[TextIO] = None) -> logging.Handler:
+    """Send ``dbgcopilot`` records at ``level`` and above to ``stream`` (stderr); replaces an earlier call.
+
+    ``level`` defaults to ``$DBGCOPILOT_LOG_LEVEL``, then ``warning``.
+    """
+    global _handler
+    root = logging.getLogger(LOGGER_NAME)
+    if _handler is not None:
+        root.removeHandler(_handler)
+    _handler = logging.StreamHandler(stream or sys.stderr)
+    _handler.setFormatter(KeyValueFormatter())
+    root.addHandler(_handler)
+    root.setLevel(parse_level(level or os.environ.get(LEVEL_ENV_VAR)))
+    # Records stop here rather than reaching whatever the host application configured on the root logger.
+    root.propagate = False
+    return _handler
+
+
+def set_redactor(redactor: Redactor) -> None:
+    """Redact this thread's (or context's) trace records with ``redactor``, the session's own."""
+    _redactor.set(redactor)
+
+
+class _ListHandler(logging.Handler):
+    def __init__(self, records: List[logging.LogRecord]) -> None:
+        super().__init__(logging.NOTSET)
+        self.records = records
+
+    def emit(self, record: logging.LogRecord) -> None:
+        self.records.append(record)
+
+
+@contextmanager
+def capture(level: str = "debug") -> Iterator[List[logging.LogRecord]]:
+    """Collect ``dbgcopilot`` records at ``level`` and above, and nothing else, for the duration of a block."""
+    root = logging.getLogger(LOGGER_NAME)
+    records: List[logging.LogRecord] = []
+    handler = _ListHandler(records)
+    saved = (root.handlers[:], root.level, root.propagate)
+    root.handlers = [handler]
+    root.setLevel(parse_level(level))
+    root.propagate = False
+    try:
+        yield records
+    finally:
+        root.handlers, root.propagate = saved[0], saved[2]
+        root.setLevel(saved[1])
+
+
+def attrs(record: logging.LogRecord) -> dict[str, Any]:
+    """The key/value attributes ``log`` attached to ``record``."""
+    return dict(getattr(record, _ATTRS, {}))
+
+
+__all__ = [
+    "DEFAULT_LEVEL",
+    "LEVELS",
+    "LEVEL_ENV_VAR",
+    "LOGGER_NAME",
+    "KeyValueFormatter",
+    "attrs",
+    "capture",
+    "configure",
+    "get_logger",
+    "log",
+    "parse_level",
+    "set_redactor",
+    "trace_debugger",
+    "trace_llm",
+]
@@ -0,0 +1,218 @@
+"""Map source paths recorded in a binary to where the sources live locally.
+
+A binary built in CI records paths like ``/build/src/pkg/x.go`` that do not
+exist on the machine being debugged. A ``PathMap`` holds ``build=local``
+prefix pairs and rewrites paths in both directions: ``to_local`` when
+reading source for frame context and condition checks, ``to_target`` when a
+locally written breakpoint location is sent to the debugger. The longest
+matching prefix wins, and prefixes only match whole path components.
+
+Go binaries built with ``-trimpath`` record ``module@version/file.go`` for
+dependencies. A mapping whose local side is ``$GOMODCACHE`` resolves those
+paths inside the module cache (``$GOMODCACHE``, else ``$GOPATH/pkg/mod``,
+else ``~/go/pkg/mod``), applying the cache's ``!``-escaping of capital
+letters; its build side is a module path prefix, or ``*`` for any module.
+
+Session config key ``path_map`` (one ``build=local`` mapping, or a JSON
+list of them); ``dbgagent --path-map`` and ``/pathmap`` set it.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Iterable, List, Mapping, Optional, Tuple
+import json
+import os
+import re
+
+MODCACHE = "$GOMODCACHE"
+ANY_MODULE = "*"
+
+# "module/path@v1.2.3/rest" -> ("module/path@v1.2.3", "rest")
+_VERSIONED_RE = re.compile(r"^(?P<module>[^@\s]+@[^/\s]+)(?:/(?P<rest>.*))?$")
+_LOCATION_RE = re.compile(r"^(?P<path>.+?):(?P<line>\d+)$")
+
+
+def module_cache_dir() -> str:
+    cache = os.environ.get("GOMODCACHE")
+    if cache:
+        return cache
+    gopath = os.environ.get("GOPATH", "").split(os.pathsep)[0]
+    return os.path.join(gopath or os.path.expanduser("~/go"), "pkg", "mod")
+
+
+def _escape_module(module: str) -> str:
+    return re.sub(r"[A-Z]", lambda m: "!" + m.group(0).lower(), module)
+
+
+def _unescape_module(module: str) -> str:
+    return re.sub(r"!([a-z])", lambda m: m.group(1).upper(), module)
+
+
+def _strip(prefix: str) -> str:
+    return prefix.rstrip("/") if prefix.strip("/") else prefix
+
+
+def _under(path: str, prefix: str) -> Optional[str]:
+    """The part of ``path`` after ``prefix`` ("" for the prefix itself), or None if it is not under it."""
+    if path == prefix:
+        return ""
+    if prefix.endswith("/"):
+        return path[len(prefix) :] if path.startswith(prefix) else None
+    if path.startswith(prefix + "/"):
+        return path[len(prefix) + 1 :]
+    return None
+
+
+def _join(prefix: str, rest: str) -> str:
+    if not rest:
+        return prefix
+    return prefix + rest if prefix.endswith("/") else f"{prefix}/{rest}"
+
+
+@dataclass(frozen=True)
+class PathMapping:
+    build: str
+    local: str
+
+    @property
+    def module_cache(self) -> bool:
+        return self.local == MODCACHE
+
+    @classmethod
+    def parse(cls, text: str) -> "PathMapping":
+        build, sep, local = (text or "").partition("=")
+        build, local = build.strip(), local.strip()
+        if not sep or not build or not local:
+            raise ValueError(f"Path mapping must look like <build prefix>=<local prefix>, got {text!r}")
+        return cls(_strip(build), local if local == MODCACHE else _strip(os.path.expanduser(local)))
+
+    def __str__(self) -> str:
+        return f"{self.build}={self.local}"
+
+
+class PathMap:
+    """Ordered ``build=local`` prefix mappings; an empty map leaves every path unchanged."""
+
+    def __init__(self, mappings: Iterable[PathMapping] = ()) -> None:
+        self.mappings: List[PathMapping] = list(mappings)
+
+    @classmethod
+    def parse(cls, specs: Iterable[str]) -> "PathMap":
+        return cls(PathMapping.parse(spec) for spec in specs if spec and spec.strip())
+
+    @classmethod
+    def from_config(cls, config: Optional[Mapping[str, str]]) -> "PathMap":
+        return cls.parse(config_mappings((config or {}).get("path_map")))
+
+    def __bool__(self) -> bool:
+        return bool(self.mappings)
+
+    def _by_length(self, side: str) -> List[PathMapping]:
+        return sorted(self.mappings, key=lambda m: len(getattr(m, side)), reverse=True)
+
+    def to_local(self, path: str) -> str:
+        """Where the file the binary calls ``path`` lives on this machine."""
+        if not path or not self.mappings:
+            return path
+        for mapping in self._by_length("build"):
+            if mapping.module_cache:
+                local = self._from_module_cache(mapping, path)
+                if local is not None:
+                    return local
+                continue
+            rest = _under(path, mapping.build)
+            if rest is not None:
+                return _join(mapping.local, rest)
+        return path
+
+    def to_target(self, path: str) -> str:
+        """The path the binary records for the local file ``path``."""
+        if not path or not self.mappings:
+            return path
+        cache = module_cache_dir()
+        best: Optional[Tuple[int, str]] = None
+        for mapping in self.mappings:
+            if mapping.module_cache:
+                rest = _under(path, cache)
+                target = _unescape_module(rest) if rest else None
+                if target and (mapping.build == ANY_MODULE or _under(target, mapping.build) is not None):
+                    candidate = (len(cache), target)
+                else:
+                    continue
+            else:
+                rest = _under(path, mapping.local)
+                if rest is None:
+                    continue
+                candidate = (len(mapping.local), _join(mapping.build, rest))
+            if best is None or candidate[0] > best[0]:
+                best = candidate
+        return best[1] if best else path
+
+    def location_to_target(self, location: str) -> str:
+        """Rewrite the file part of a ``file:line`` breakpoint location; other locations are unchanged."""
+        m = _LOCATION_RE.match((location or "").strip())
+        if not m or not self.mappings:
+            return location
+        return f"{self.to_target(m.group('path'))}:{m.group('line')}"
+
+    def _from_module_cache(self, mapping: PathMapping, path: str) -> Optional[str]:
+        m = _VERSIONED_RE.match(path)
+        if not m:
+            return None
+        module = m.group("module")
+        if mapping.build != ANY_MODULE and _under(module.split("@", 1)[0], mapping.build) is None:
+            return None
+        local = os.path.join(module_cache_dir(), _escape_module(module))
+        return _join(local, m.group("rest") or "")
+
+    def describe(self) -> str:
+        if not self.mappings:
+            return "No source path mappings."
+        lines = ["Source path mappings (longest build prefix wins):"]
+        lines.extend(f"  {m.build} -> {m.local}" for m in self._by_length("build"))
+        return "\n".join(lines)
+
+
+def config_mappings(raw: Optional[str]) -> List[str]:
+    """Mappings stored under the ``path_map`` session key: one mapping, or a JSON list."""
+    text = (raw or "").strip()
+    if not text:
+        return []
+    if text.startswith("["):
+        try:
+            items = json.loads(text)
+        except ValueError as e:
+            raise ValueError(f"path_map must be a mapping or a JSON list: {e}") from e
+        return [str(item) for item in items]
+    return [text]
+
+
+_active = PathMap()
+
+
+def active_path_map() -> PathMap:
+    """The process-wide map used by ``SourceCache`` and the debugger backends."""
+    return _active
+
+
+def set_path_map(path_map: Optional[PathMap]) -> PathMap:
+    global _active
+    _active = path_map or PathMap()
+    return _active
+
+
+def local_path(path: str) -> str:
+    return _active.to_local(path)
+
+
+__all__ = [
+    "ANY_MODULE",
+    "MODCACHE",
+    "PathMap",
+    "PathMapping",
+    "active_path_map",
+    "config_mappings",
+    "local_path",
+    "module_cache_dir",
+    "set_path_map",
+]
@@ -0,0 +1,280 @@
+"""Redact secrets from debugger output before it is put into an LLM prompt.
+
+Two kinds of rules apply. Name heuristics replace the value of anything
+whose name looks sensitive (``password = "..."``, ``APIKey: "..."``,
+``token=0x1c "..."`` in a gdb frame); the output of ``print <name>``-style
+commands counts as that name's value. Value patterns replace well-known
+secret shapes (cloud keys, bearer tokens, URL credentials, PEM private keys)
+wherever they occur. Every redacted literal is remembered, so the same
+value is also hidden where it reappears without a name (chat history, the
+last output). Values become ``<redacted:len=N>``.
+
+Session config keys: ``redact`` (``off`` disables), ``redact_names`` and
+``redact_patterns`` (one regex, or a JSON list of regexes) extend the
+built-in rules.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Dict, List, Mapping, Optional, Pattern, Sequence, Tuple
+import json
+import re
+
+
+DEFAULT_NAME_PATTERN = r"(?i)(pass|secret|token|key|auth)"
+# Value shapes that are secrets whatever they are called; a "secret" group limits the replaced part.
+DEFAULT_VALUE_PATTERNS = (
+    r"-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----",
+    r"\bAKIA[0-9A-Z]{16}\b",
+    r"\bgh[pousr]_[A-Za-z0-9]{36,}\b",
+    r"\bsk-[A-Za-z0-9_-]{20,}",
+    r"\bxox[abprs]-[A-Za-z0-9-]{10,}",
+    r"(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/=-]{8,})",
+    r"://[^/\s:@]+:(?P<secret>[^@\s/]+)@",
+)
+MIN_KNOWN_LENGTH = 4
+
+_TOKEN_RE = re.compile(r"<redacted:len=\d+>")
+# name = value, name: value, name := value, also with a quoted name ('name': value, "name": value)
+# (but not ==, ::, :// or file.go:12).
+_ASSIGN_RE = re.compile(
+    r"(?<![\w.$])(?P<name>[A-Za-z_$][\w$]*)(?P<sep>['\"]?\s*(?::=|=(?!=)|:(?![:/=\d]))\s*)"
+)
+_QUOTED_RE = re.compile(r'(?:0x[0-9a-fA-F]+\s+)?(?:"(?:\\.|[^"\\])*"|`[^`]*`|\'(?:\\.|[^\'\\])*\')')
+# HTTP-style "Bearer <token>" values keep their scheme word together with the token. A bare value
+# stops at "[", so a Delve type ([]string, map[string]string) is never taken for one.
+_BARE_RE = re.compile(r'(?i:(?:bearer|basic|digest)\s+)?[^\s,;)}\[\]("\'`]+')
+_NOTHING = {"nil", "<nil>", "null", "NULL", '""', "''", "0x0", "(null)"}
+_VALUE_LINE_RE = re.compile(r"^(\s*(?:old |new )?value:\s*)(.+?)\s*$", re.IGNORECASE)
+_VARIABLE_COMMAND_RE = re.compile(
+    r"^\s*(?:print|p|whatis|display|call|po|output|expr(?:ession)?(?:\s+--)?|frame\s+variable|fr\s+v|v|var|watch(?:\s+-\w+)*)"
+    r"(?:/\w+)?\s+(?P<expr>.+?)\s*$",
+    re.IGNORECASE,
+)
+_EXECUTED_RE = re.compile(r"^(Assistant: \((?:executed|collected)\) )(.*)$")
+
+
+@dataclass
+class Redaction:
+    rule: str
+    name: str
+    length: int
+    line: int
+
+    def describe(self) -> str:
+        return f"line {self.line}: {self.name or '(value)'} by {self.rule}, len={self.length}"
+
+
+def _compile(patterns: Sequence[str]) -> List[Pattern[str]]:
+    compiled: List[Pattern[str]] = []
+    for pattern in patterns:
+        try:
+            compiled.append(re.compile(pattern))
+        except re.error as e:
+            raise ValueError(f"Invalid redaction pattern {pattern!r}: {e}") from e
+    return compiled
+
+
+def _config_patterns(raw: Optional[str]) -> List[str]:
+    text = (raw or "").strip()
+    if not text:
+        return []
+    if text.startswith("["):
+        try:
+            items = json.loads(text)
+        except ValueError as e:
+            raise ValueError(f"Redaction patterns must be a regex or a JSON list: {e}") from e
+        return [str(item) for item in items]
+    return [text]
+
+
+def command_variable(command: str) -> str:
+    """Expression printed by a ``print``/``frame variable``/``watch``-style command, else ""."""
+    m = _VARIABLE_COMMAND_RE.match(command or "")
+    return m.group("expr") if m else ""
+
+
+def _root_name(expr: str) -> str:
+    # "cfg.APIKey" -> "APIKey", "*creds.token" -> "token", "keys[0]" -> "keys"
+    cleaned = re.sub(r"\[[^\]]*\]|\([^)]*\)", "", expr or "")
+    parts = [p for p in re.split(r"[.\s*&>-]+", cleaned) if p]
+    return parts[-1] if parts else ""
+
+
+def _literal(value: str) -> str:
+    """The secret itself: quotes and a leading gdb/LLDB pointer are not part of it."""
+    m = re.match(r'^(?:0x[0-9a-fA-F]+\s+)?(["\'`])(.*)\1$', value, re.DOTALL)
+    return m.group(2) if m else value
+
+
+class Redactor:
+    """Applies name heuristics and value patterns; remembers redacted literals across calls."""
+
+    def __init__(
+        self,
+        name_patterns: Optional[Sequence[str]] = None,
+        value_patterns: Optional[Sequence[str]] = None,
+        *,
+        enabled: bool = True,
+        known: Optional[Dict[str, str]] = None,
+    ) -> None:
+        self.enabled = enabled
+        self.name_patterns = _compile([DEFAULT_NAME_PATTERN, *(name_patterns or [])])
+        self.value_patterns = _compile([*DEFAULT_VALUE_PATTERNS, *(value_patterns or [])])
+        # Literal secret -> rule that found it.
+        self.known: Dict[str, str] = known if known is not None else {}
+
+    @classmethod
+    def from_config(cls, config: Optional[Mapping[str, str]], known: Optional[Dict[str, str]] = None) -> "Redactor":
+        cfg = config or {}
+        enabled = (cfg.get("redact") or "on").strip().lower() not in {"off", "0", "false", "no"}
+        return cls(
+            _config_patterns(cfg.get("redact_names")),
+            _config_patterns(cfg.get("redact_patterns")),
+            enabled=enabled,
+            known=known,
+        )
+
+    def sensitive(self, name: str) -> bool:
+        root = _root_name(name)
+        return bool(root) and any(p.search(root) for p in self.name_patterns)
+
+    # ------------------------------------------------------------------
+    def scan(self, text: str, *, variable: str = "") -> Tuple[str, List[Redaction]]:
+        """Return the redacted text and what was replaced; ``variable`` names what ``text`` prints."""
+        if not self.enabled or not text:
+            return text, []
+        spans: List[Tuple[int, int, str, str]] = []
+        if variable and self.sensitive(variable):
+            spans.extend(self._variable_spans(text, variable))
+        for idx, pattern in enumerate(self.value_patterns):
+            rule = "pattern" if idx < len(DEFAULT_VALUE_PATTERNS) else f"custom pattern {pattern.pattern!r}"
+            for m in pattern.finditer(text):
+                group = "secret" if "secret" in pattern.groupindex and m.group("secret") else 0
+                spans.append((m.start(group), m.end(group), rule, ""))
+        for m in _ASSIGN_RE.finditer(text):
+            if not self.sensitive(m.group("name")):
+                continue
+            span = self._value_span(text, m.end())
+            if span is not None:
+                spans.append((span[0], span[1], "name heuristic", m.group("name")))
+        return self._apply(text, spans)
+
+    def redact(self, text: str, *, variable: str = "") -> str:
+        return self.scan(text, variable=variable)[0]
+
+    def redact_entry(self, entry: str) -> str:
+        """Redact a transcript entry, using the command of "(executed) <cmd>" entries as context."""
+        head, _, body = (entry or "").partition("\n")
+        m = _EXECUTED_RE.match(head)
+        if not m or not body:
+            return self.redact(entry)
+        return m.group(1) + self.redact(m.group(2)) + "\n" + self.redact(body, variable=command_variable(m.group(2)))
+
+    def preview(self, sections: Sequence[Tuple[str, str]]) -> List[Redaction]:
+        """What redacting ``(text, variable)`` sections in order would replace, without remembering values.
+
+        Transcript entries ("Assistant: (executed) <cmd>" + output) get their
+        command as context like ``redact_entry``; line numbers run across sections.
+        """
+        saved = dict(self.known)
+        found: List[Redaction] = []
+        offset = 0
+        try:
+            for text, variable in sections:
+                head, _, body = (text or "").partition("\n")
+                m = _EXECUTED_RE.match(head)
+                if m and body and not variable:
+                    text, variable, skip = body, command_variable(m.group(2)), 1
+                else:
+                    skip = 0
+                for r in self.scan(text, variable=variable)[1]:
+                    r.line += offset + skip
+                    found.append(r)
+                offset += skip + text.count("\n") + 1
+        finally:
+            self.known.clear()
+            self.known.update(saved)
+        return found
+
+    # ------------------------------------------------------------------
+    def _variable_spans(self, text: str, variable: str) -> List[Tuple[int, int, str, str]]:
+        spans: List[Tuple[int, int, str, str]] = []
+        offset = 0
+        for line in text.splitlines(keepends=True):
+            m = _VALUE_LINE_RE.match(line)
+            if m:
+                spans.append((offset + m.start(2), offset + m.end(2), "name heuristic", variable))
+            offset += len(line)
+        if spans:
+            return spans
+        body = text.strip()
+        if body and body not in _NOTHING:
+            start = text.index(body)
+            spans.append((start, start + len(body), "name heuristic", variable))
+        return spans
+
+    def _value_span(self, text: str, pos: int) -> Optional[Tuple[int, int]]:
+        if text.startswith("<redacted:", pos):
+            return None
+        m = _QUOTED_RE.match(text, pos)
+        if m is None:
+            m = _BARE_RE.match(text, pos)
+            # A call, composite or Go type (os.Getenv("X"), Config{...}, map[string]int) is code, not a value.
+            if m is None or text[m.end() : m.end() + 1] in {"(", "{", "["}:
+                return None
+        if m.group(0) in _NOTHING:
+            return None
+        return m.start(), m.end()
+
+    def _apply(self, text: str, spans: List[Tuple[int, int, str, str]]) -> Tuple[str, List[Redaction]]:
+        found: List[Redaction] = []
+        parts: List[str] = []
+        cursor = 0
+        for start, end, rule, name in sorted(spans, key=lambda s: (s[0], -s[1])):
+            if start < cursor or start == end:
+                continue
+            literal = _literal(text[start:end])
+            found.append(Redaction(rule, name, len(literal), text.count("\n", 0, start) + 1))
+            if len(literal) >= MIN_KNOWN_LENGTH:
+                self.known.setdefault(literal, rule)
+            parts.append(text[cursor:start])
+            parts.append(f"<redacted:len={len(literal)}>")
+            cursor = end
+        parts.append(text[cursor:])
+        result = "".join(parts)
+        return self._replace_known(result, found), found
+
+    def _replace_known(self, text: str, found: List[Redaction]) -> str:
+        if not self.known:
+            return text
+        literals = sorted((k for k in self.known if len(k) >= MIN_KNOWN_LENGTH), key=len, reverse=True)
+        if not literals:
+            return text
+        pattern = re.compile("|".join(re.escape(k) for k in literals))
+        pieces: List[str] = []
+        cursor = 0
+        # Never rewrite inside an existing <redacted:len=N> token.
+        for m in _TOKEN_RE.finditer(text):
+            pieces.append(self._sub_known(pattern, text[cursor : m.start()], text, cursor, found))
+            pieces.append(m.group(0))
+            cursor = m.end()
+        pieces.append(self._sub_known(pattern, text[cursor:], text, cursor, found))
+        return "".join(pieces)
+
+    def _sub_known(self, pattern: Pattern[str], chunk: str, full: str, base: int, found: List[Redaction]) -> str:
+        def repl(m: "re.Match[str]") -> str:
+            found.append(Redaction("known value", "", len(m.group(0)), full.count("\n", 0, base + m.start()) + 1))
+            return f"<redacted:len={len(m.group(0))}>"
+
+        return pattern.sub(repl, chunk)
+
+
+def format_preview(found: Sequence[Redaction], *, enabled: bool = True) -> str:
+    if not enabled:
+        return "Redaction is off (set redact=on to enable)."
+    if not found:
+        return "Nothing would be redacted."
+    lines = [f"Would redact {len(found)} value(s):"]
+    lines.extend(f"  {r.describe()}" for r in found)
+    return "\n".join(lines)
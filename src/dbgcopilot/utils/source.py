@@ -0,0 +1,96 @@
+"""Source file helpers shared by the analyzers.
+
+Reads target source files lazily and keeps them cached so a single analysis
+pass does not hit the disk repeatedly for the same file. Paths recorded by
+the binary are rewritten through the active ``PathMap`` before reading.
+"""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Any, Dict, List, Optional
+
+from .pathmap import PathMap, active_path_map
+
+DEFAULT_CONTEXT_RADIUS = 3
+
+
+def _new_line_list() -> List[str]:
+    return []
+
+
+@dataclass
+class SourceContext:
+    """Lines surrounding a frame's active line; ``lines`` is empty when the file is missing."""
+
+    path: str
+    line: int
+    start: int = 0
+    lines: List[str] = field(default_factory=_new_line_list)
+    active_index: int = -1
+
+    @property
+    def available(self) -> bool:
+        return bool(self.lines)
+
+    def numbered(self) -> List[tuple[int, str]]:
+        return [(self.start + i, text) for i, text in enumerate(self.lines)]
+
+    def render(self, indent: str = "  ") -> List[str]:
+        """Render as ``> 12 | code`` lines with the active line marked."""
+        if not self.lines:
+            return []
+        width = len(str(self.start + len(self.lines) - 1))
+        out: List[str] = []
+        for i, (lineno, text) in enumerate(self.numbered()):
+            marker = ">" if i == self.active_index else " "
+            out.append(f"{indent}{marker} {lineno:>{width}} | {text}")
+        return out
+
+
+class SourceCache:
+    """Cache of source files keyed by path; missing files are remembered too."""
+
+    def __init__(self, path_map: Optional[PathMap] = None) -> None:
+        self._files: Dict[str, Optional[List[str]]] = {}
+        self.path_map = path_map if path_map is not None else active_path_map()
+
+    def lines(self, path: str) -> Optional[List[str]]:
+        """Return the lines of ``path`` (without newlines) or None if unreadable."""
+        if not path:
+            return None
+        if path in self._files:
+            return self._files[path]
+        content: Optional[List[str]]
+        try:
+            content = Path(self.path_map.to_local(path)).read_text(encoding="utf-8", errors="replace").splitlines()
+        except Exception:
+            content = None
+        self._files[path] = content
+        return content
+
+    def line(self, path: str, lineno: int) -> Optional[str]:
+        """Return a single 1-based line from ``path`` or None."""
+        content = self.lines(path)
+        if content is None or lineno < 1 or lineno > len(content):
+            return None
+        return content[lineno - 1]
+
+    def context(self, path: str, lineno: int, radius: int = DEFAULT_CONTEXT_RADIUS) -> SourceContext:
+        """Return ``radius`` lines before and after ``lineno`` in ``path``."""
+        ctx = SourceContext(path=path, line=lineno)
+        content = self.lines(path)
+        if content is None or lineno < 1 or lineno > len(content):
+            return ctx
+        radius = max(0, radius)
+        ctx.start = max(1, lineno - radius)
+        end = min(len(content), lineno + radius)
+        ctx.lines = content[ctx.start - 1 : end]
+        ctx.active_index = lineno - ctx.start
+        return ctx
+
+
+def context(frame: Any, radius: int = DEFAULT_CONTEXT_RADIUS, cache: Optional[SourceCache] = None) -> SourceContext:
+    """Source context for any frame-like object with ``file`` and ``line`` attributes."""
+    return (cache or SourceCache()).context(getattr(frame, "file", "") or "", int(getattr(frame, "line", 0) or 0), radius)
+
@@ -0,0 +1,238 @@
+"""Optional OpenTelemetry spans for the analysis pipeline.
+
+``configure`` turns tracing on when an OTLP endpoint is set
+(``$OTEL_EXPORTER_OTLP_TRACES_ENDPOINT`` or ``$OTEL_EXPORTER_OTLP_ENDPOINT``,
+``$OTEL_SDK_DISABLED`` unset) and the ``opentelemetry-sdk`` and
+``opentelemetry-exporter-otlp`` packages are installed. The exporter takes
+the rest of its settings (protocol, headers, ``$OTEL_SERVICE_NAME``) from
+the standard ``OTEL_*`` variables. Spans are batched and flushed at exit.
+
+Each phase opens ``span(name, **attrs)``: one ``dbgagent.analyze`` span per
+target, with ``debugger.launch``, ``debugger.wait`` (running to a
+breakpoint, crash, exit or hang), ``debugger.goroutines``,
+``debugger.command``, ``prompt.build`` and ``llm.call`` children. Keyword
+attributes become ``dbgcopilot.<key>``, except the LLM ones, which use the
+``gen_ai.*`` names of the OpenTelemetry conventions. The active span
+follows the calling thread through ``contextvars``; work handed to another
+thread runs under ``propagate(fn)`` to stay in the same trace.
+
+Disabled, ``span`` returns a shared no-op after one ``None`` check, and
+``propagate`` returns ``fn`` itself. Tests record spans with ``capture()``.
+"""
+from __future__ import annotations
+
+from contextlib import contextmanager
+from contextvars import ContextVar, copy_context
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, Iterator, List, Mapping, Optional, TypeVar
+import functools
+import logging
+import os
+
+from .log import get_logger, log
+
+ENDPOINT_ENV_VARS = ("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT")
+SERVICE_NAME = "dbgcopilot"
+
+SPAN_BATCH = "dbgagent.batch"
+SPAN_ANALYZE = "dbgagent.analyze"
+SPAN_LAUNCH = "debugger.launch"
+SPAN_WAIT = "debugger.wait"
+SPAN_GOROUTINES = "debugger.goroutines"
+SPAN_COMMAND = "debugger.command"
+SPAN_PROMPT = "prompt.build"
+SPAN_LLM = "llm.call"
+SPAN_LLM_REQUEST = "llm.request"
+
+# Keyword attributes with an OpenTelemetry semantic-convention name.
+_SEMCONV = {
+    "provider": "gen_ai.system",
+    "model": "gen_ai.request.model",
+    "prompt_tokens": "gen_ai.usage.input_tokens",
+    "completion_tokens": "gen_ai.usage.output_tokens",
+}
+
+T = TypeVar("T")
+
+# The SDK tracer, or None while tracing is off.
+_tracer: Any = None
+_logger = get_logger("tracing")
+
+
+def _attribute(value: Any) -> Any:
+    if isinstance(value, (bool, int, float, str)):
+        return value
+    return str(value)
+
+
+def _attributes(attrs: Dict[str, Any]) -> Dict[str, Any]:
+    return {
+        _SEMCONV.get(key, f"{SERVICE_NAME}.{key}"): _attribute(value)
+        for key, value in attrs.items()
+        if value is not None and value != ""
+    }
+
+
+class Span:
+    """An open span; ``set`` adds attributes while the phase runs."""
+
+    def __init__(self, raw: Any) -> None:
+        self.raw = raw
+
+    def set(self, **attrs: Any) -> None:
+        for key, value in _attributes(attrs).items():
+            self.raw.set_attribute(key, value)
+
+
+class _NoopSpan:
+    def __enter__(self) -> "_NoopSpan":
+        return self
+
+    def __exit__(self, *exc: Any) -> bool:
+        return False
+
+    def set(self, **attrs: Any) -> None:
+        pass
+
+
+_NOOP = _NoopSpan()
+
+
+@contextmanager
+def _started(name: str, attrs: Dict[str, Any]) -> Iterator[Span]:
+    # The SDK records an exception leaving the block on the span and marks it as an error.
+    with _tracer.start_as_current_span(name, attributes=_attributes(attrs)) as raw:
+        yield Span(raw)
+
+
+def span(name: str, **attrs: Any) -> Any:
+    """Context manager for one pipeline phase, a child of the span active on this thread."""
+    if _tracer is None:
+        return _NOOP
+    return _started(name, attrs)
+
+
+def enabled() -> bool:
+    return _tracer is not None
+
+
+def propagate(fn: Callable[..., T]) -> Callable[..., T]:
+    """``fn`` bound to the caller's active span, for running on a worker thread."""
+    if _tracer is None:
+        return fn
+    ctx = copy_context()
+    return functools.partial(ctx.run, fn)
+
+
+def endpoint(env: Optional[Mapping[str, str]] = None) -> str:
+    """The configured OTLP endpoint, or "" when tracing should stay off."""
+    env = os.environ if env is None else env
+    if env.get("OTEL_SDK_DISABLED", "").strip().lower() == "true":
+        return ""
+    for name in ENDPOINT_ENV_VARS:
+        if env.get(name, "").strip():
+            return env[name].strip()
+    return ""
+
+
+def configure(env: Optional[Mapping[str, str]] = None) -> bool:
+    """Start exporting spans over OTLP when an endpoint is configured; returns whether tracing is on."""
+    global _tracer
+    env = os.environ if env is None else env
+    target = endpoint(env)
+    if not target or _tracer is not None:
+        return _tracer is not None
+    try:
+        from opentelemetry import trace
+        from opentelemetry.sdk.resources import Resource
+        from opentelemetry.sdk.trace import TracerProvider
+        from opentelemetry.sdk.trace.export import BatchSpanProcessor
+
+        if env.get("OTEL_EXPORTER_OTLP_PROTOCOL", "").startswith("grpc"):
+            from opentelemetry.exporter.otlp.proto.grpc.trace_exporter import OTLPSpanExporter
+        else:
+            from opentelemetry.exporter.otlp.proto.http.trace_exporter import OTLPSpanExporter
+    except ImportError as e:
+        log(_logger, logging.WARNING, "tracing off: OpenTelemetry is not installed", endpoint=target, error=str(e))
+        return False
+    service = env.get("OTEL_SERVICE_NAME") or SERVICE_NAME
+    provider = TracerProvider(resource=Resource.create({"service.name": service}))
+    provider.add_span_processor(BatchSpanProcessor(OTLPSpanExporter()))
+    trace.set_tracer_provider(provider)
+    _tracer = trace.get_tracer(SERVICE_NAME)
+    log(_logger, logging.INFO, "tracing enabled", endpoint=target, service=service)
+    return True
+
+
+def _new_attrs() -> Dict[str, Any]:
+    return {}
+
+
+@dataclass
+class RecordedSpan:
+    """A span collected by ``capture()``."""
+
+    name: str
+    attributes: Dict[str, Any] = field(default_factory=_new_attrs)
+    parent: Optional["RecordedSpan"] = None
+    error: str = ""
+
+    def set_attribute(self, key: str, value: Any) -> None:
+        self.attributes[key] = value
+
+
+_recording: ContextVar[Optional[RecordedSpan]] = ContextVar("dbgcopilot_recorded_span", default=None)
+
+
+class _RecordingTracer:
+    def __init__(self, spans: List[RecordedSpan]) -> None:
+        self.spans = spans
+
+    @contextmanager
+    def start_as_current_span(self, name: str, attributes: Optional[Dict[str, Any]] = None) -> Iterator[RecordedSpan]:
+        recorded = RecordedSpan(name, dict(attributes or {}), parent=_recording.get())
+        self.spans.append(recorded)
+        token = _recording.set(recorded)
+        try:
+            yield recorded
+        except BaseException as e:
+            recorded.error = f"{type(e).__name__}: {e}"
+            raise
+        finally:
+            _recording.reset(token)
+
+
+@contextmanager
+def capture() -> Iterator[List[RecordedSpan]]:
+    """Record spans, in the order they start, instead of exporting them for the duration of a block."""
+    global _tracer
+    spans: List[RecordedSpan] = []
+    saved = _tracer
+    _tracer = _RecordingTracer(spans)
+    try:
+        yield spans
+    finally:
+        _tracer = saved
+
+
+__all__ = [
+    "ENDPOINT_ENV_VARS",
+    "SERVICE_NAME",
+    "SPAN_ANALYZE",
+    "SPAN_BATCH",
+    "SPAN_COMMAND",
+    "SPAN_GOROUTINES",
+    "SPAN_LAUNCH",
+    "SPAN_LLM",
+    "SPAN_LLM_REQUEST",
+    "SPAN_PROMPT",
+    "SPAN_WAIT",
+    "RecordedSpan",
+    "Span",
+    "capture",
+    "configure",
+    "enabled",
+    "endpoint",
+    "propagate",
+    "span",
+]
@@ -0,0 +1,134 @@
+"""dbgagent's command line: option combinations rejected before anything starts, and how a session ends."""
+import io
+import json
+import sys
+import types
+
+import pytest
+
+from dbgagent import cli
+from dbgagent.cli import main
+from dbgagent.runner import AgentRequest, DebugAgentRunner
+from dbgcopilot.llm import providers
+from dbgcopilot.llm.streaming import TokenStream
+from dbgcopilot.utils.context import Context, DeadlineExceeded
+
+
+def test_options_that_need_a_local_live_process_are_rejected(capsys):
+    cases = [
+        (["--program", "./app", "--core", "core.1", "--hang-timeout", "5"], "not a core dump"),
+        (["--remote", "127.0.0.1:4040", "--hang-timeout", "5"], "not a --remote server"),
+        (["--pid", "4242", "--triage"], "--triage runs a program dbgagent launches, not with --pid"),
+    ]
+    for argv, message in cases:
+        with pytest.raises(SystemExit):
+            main(argv)
+        assert message in capsys.readouterr().err
+
+
+class _Debugger:
+    name = "delve"
+
+    def __init__(self):
+        self.aborted = False
+
+    def run_command(self, cmd, timeout=None):
+        return ""
+
+    def abort(self):
+        self.aborted = True
+
+
+def test_timeout_during_interactive_ends_the_session(tmp_path, monkeypatch, capsys):
+    debugger = _Debugger()
+
+    class Finished(DebugAgentRunner):
+        def run(self, context=None):
+            self.context = Context.with_timeout(self.request.timeout)
+            self.backend = debugger
+            return "Final report: worker blocks on lockA."
+
+        def _get_provider_fn(self, provider):
+            def expired(prompt):
+                raise DeadlineExceeded("deadline exceeded")
+
+            return expired
+
+    monkeypatch.setattr(cli, "DebugAgentRunner", Finished)
+    monkeypatch.setattr(providers, "create_tool_client", lambda name, config=None: None)
+    monkeypatch.setattr(sys, "stdin", io.StringIO("why does it hang?\n"))
+    program = tmp_path / "app"
+    program.write_text("")
+    argv = ["--debugger", "delve", "--program", str(program), "--interactive", "--timeout", "5"]
+    code = cli.main(argv + ["--report-file", str(tmp_path / "report.md"), "--llm-provider", "ollama"])
+    assert code == cli.EXIT_TIMEOUT and debugger.aborted
+    assert "Timed out after 5s; the debugger session was ended" in capsys.readouterr().err
+
+
+def test_a_plain_delve_launch_runs_under_hang_detection_only_with_triage(tmp_path):
+    def runner(**options):
+        fields = dict.fromkeys(["model", "api_key", "classpath", "sourcepath", "main_class", "corefile"])
+        request = AgentRequest(
+            debugger="delve", provider="ollama", program="./app", goal_type="crash", goal_text="",
+            resume_context=None, max_steps=5, language="en", log_enabled=False, log_path=None,
+            report_path=tmp_path / "report.md", **fields, **options,
+        )
+        return DebugAgentRunner(request)
+
+    assert runner()._watch_timeout() is None
+    assert runner(hang_timeout=3.0)._watch_timeout() == 3.0
+    assert runner(triage=True)._watch_timeout() == cli.DEFAULT_HANG_TIMEOUT
+    assert runner(triage=True, force_analyze=True)._watch_timeout() is None
+
+
+def test_stdout_holds_only_the_result_and_streamed_answers_are_progress(tmp_path, monkeypatch, capsys):
+    runners = []
+
+    class Answering(DebugAgentRunner):
+        def run(self, context=None):
+            runners.append(self)
+            self.context = Context()
+            self.backend = _Debugger()
+            answer = self._call_llm("Why does ./app crash?")
+            self.request.report_path.write_text(answer, encoding="utf-8")
+            return answer
+
+        def _get_provider_fn(self, provider):
+            def ask(prompt):
+                return "Final report: nil map write in main.go:12."
+
+            # Left over from an earlier call: a streamed answer must not report it again.
+            ask.last_usage = {"prompt_tokens": 999}
+            return ask
+
+    def streamed(name, prompt, session_config=None):
+        usage = types.SimpleNamespace(last_usage={"prompt_tokens": 40, "completion_tokens": 9})
+        return TokenStream(iter(["Final report: ", "nil map write in main.go:12."]), client=usage)
+
+    monkeypatch.setattr(cli, "DebugAgentRunner", Answering)
+    monkeypatch.setattr(providers, "create_stream", streamed)
+    program = tmp_path / "app"
+    program.write_text("")
+    argv = ["--debugger", "delve", "--program", str(program), "--llm-provider", "ollama"]
+    argv += ["--report-file", str(tmp_path / "report.md")]
+
+    results = {}
+    for fmt in ("text", "json", "md"):
+        assert cli.main(argv + ["--format", fmt]) == cli.EXIT_OK
+        out, err = capsys.readouterr()
+        assert "[dbgagent]" not in out and "[dbgagent] Session complete" in err
+        results[fmt] = out
+    assert json.loads(results["json"])["severity"] and results["md"].startswith("#")
+
+    # Streamed tokens reach stderr; the result on stdout does not change.
+    assert cli.main(argv + ["--stream"]) == cli.EXIT_OK
+    out, err = capsys.readouterr()
+    assert out == results["text"] and "Final report: nil map write in main.go:12.\n" in err
+    assert runners[-1].usage_entries[-1]["prompt_tokens"] == 40
+
+    assert cli.main(argv + ["--format", "json", "--quiet"]) == cli.EXIT_OK
+    assert capsys.readouterr() == (results["json"], "")
+
+    target = tmp_path / "out" / "result.md"
+    assert cli.main(argv + ["--format", "md", "--quiet", "--output", str(target)]) == cli.EXIT_OK
+    assert capsys.readouterr() == ("", "") and target.read_text() == results["md"]
@@ -0,0 +1,99 @@
+"""Batch analysis: per-target isolation, report files, the aggregate exit code and the JSON document."""
+import functools
+import json
+
+from dbgagent import cli
+from dbgagent.batch import (
+    STATUS_ERROR,
+    STATUS_INFRA,
+    STATUS_OK,
+    render_batch_json,
+    run_batch,
+    target_paths,
+)
+from dbgagent.runner import AgentRequest
+from dbgcopilot.analyze.result import AnalysisResult
+from dbgcopilot.debugger.base import DebuggerUnavailable
+
+
+class _Runner:
+    """Stands in for DebugAgentRunner: ./crash is critical, ./broken has a bug, ./nodlv has no debugger."""
+
+    def __init__(self, request):
+        self.request = request
+        self.result = None
+
+    def run(self, context=None):
+        name = self.request.program
+        if name == "./broken":
+            raise KeyError("outputs")
+        if name == "./nodlv":
+            raise DebuggerUnavailable("dlv not found on PATH")
+        severity = "critical" if name == "./crash" else "info"
+        self.result = AnalysisResult(issue_type="panic" if severity == "critical" else "unknown", severity=severity)
+        report = f"Final report for {name}"
+        self.request.report_path.write_text(report, encoding="utf-8")
+        return report
+
+
+def _request(tmp_path, program):
+    fields = dict.fromkeys(["model", "api_key", "classpath", "sourcepath", "main_class", "corefile", "log_path"])
+    return AgentRequest(
+        debugger="delve", provider="mock-local", program=program, goal_type="crash", goal_text="",
+        resume_context=None, max_steps=5, language="en", log_enabled=False,
+        report_path=tmp_path / f"report-{program[2:]}.md", **fields,
+    )
+
+
+def test_one_failing_target_does_not_stop_the_batch(tmp_path):
+    programs = ["./crash", "./broken", "./nodlv", "./server"]
+    finished = []
+    report = run_batch(
+        [_request(tmp_path, p) for p in programs],
+        jobs=2,
+        runner_factory=_Runner,
+        on_done=lambda entry, done, total: finished.append((done, total)),
+    )
+    assert [(e.target, e.status) for e in report.entries] == [
+        ("./crash", STATUS_OK),
+        ("./broken", STATUS_ERROR),
+        ("./nodlv", STATUS_INFRA),
+        ("./server", STATUS_OK),
+    ]
+    assert report.entries[1].error == "KeyError: 'outputs'"
+    assert report.entries[2].error == "dlv not found on PATH"
+    assert [e.target for e in report.failed()] == ["./broken", "./nodlv"]
+    assert report.worst_severity == "critical" and sorted(finished) == [(i, 4) for i in range(1, 5)]
+    # Each target that finished wrote its own report.
+    assert report.entries[0].report_path == str(tmp_path / "report-crash.md")
+    assert (tmp_path / "report-crash.md").read_text() == "Final report for ./crash"
+    assert report.entries[1].report_path == "" and not (tmp_path / "report-broken.md").exists()
+
+    doc = json.loads(render_batch_json(report))
+    assert doc["worst_severity"] == "critical"
+    assert [(t["target"], t["status"], t["severity"]) for t in doc["targets"]] == [
+        ("./crash", "ok", "critical"),
+        ("./broken", "error", "none"),
+        ("./nodlv", "infra-error", "none"),
+        ("./server", "ok", "info"),
+    ]
+    assert doc["targets"][0]["result"]["issue_type"] == "panic" and doc["targets"][2]["result"] is None
+
+
+def test_cli_batch_exit_code_and_report_files(tmp_path, monkeypatch, capsys):
+    monkeypatch.setattr(cli, "run_batch", functools.partial(run_batch, runner_factory=_Runner))
+    summary = tmp_path / "report.md"
+    argv = ["--debugger", "delve", "--llm-provider", "mock-local", "--report-file", str(summary), "--quiet"]
+
+    # The worst finding decides the exit code once it reaches --fail-on.
+    assert cli.main(argv + ["--fail-on", "warning", "./server", "./crash"]) == cli.EXIT_SEVERITY["critical"]
+    assert (tmp_path / "report-01-server.md").read_text() == "Final report for ./server"
+    assert (tmp_path / "report-02-crash.md").read_text() == "Final report for ./crash"
+    assert target_paths(summary, 2, "./crash") == tmp_path / "report-02-crash.md"
+    assert summary.read_text().startswith("# Batch analysis: 2 target(s)")
+    assert capsys.readouterr().out.startswith("Analyzed 2 target(s); worst severity: critical")
+
+    # Below the threshold, failed targets decide it: only infrastructure failures give 3, anything else 1.
+    assert cli.main(argv + ["--fail-on", "critical", "./server", "./nodlv"]) == cli.EXIT_INFRA
+    assert cli.main(argv + ["--fail-on", "critical", "./server", "./nodlv", "./broken"]) == cli.EXIT_ERROR
+    assert cli.main(argv + ["./server", "./crash"]) == cli.EXIT_OK
@@ -0,0 +1,126 @@
+"""Breakpoint hits on several goroutines: every hit reported, delivered in order on the events queue."""
+from pathlib import Path
+
+import pytest
+
+from dbgcopilot.debugger import POLICY_RESUME, POLICY_STOP, BreakpointEvents, BreakpointSpec, DebuggerError, delve
+from dbgcopilot.debugger import remote
+
+HANG = Path(__file__).resolve().parents[1] / "examples" / "hang" / "go" / "hang.go"
+
+# Delve prints only the selected thread's stop; goroutine 7 reached breakpoint 2 in it too.
+BOTH = (
+    f"> [Breakpoint 1] main.workerOne() {HANG}:17 (hits goroutine(6):1 total:1) (PC: 0x49a3c5)\n"
+    "    16:\t\tfmt.Println(\"workerOne locking A\")\n=>  17:\t\tlockA.Lock()\n"
+)
+ONE = f"> [Breakpoint 1] main.workerOne() {HANG}:17 (hits goroutine(6):1 total:1) (PC: 0x49a3c5)\n"
+TWO = f"> [Breakpoint 2] main.workerTwo() {HANG}:29 (hits goroutine(7):1 total:1) (PC: 0x49a4e5)\n"
+EXITED = "Process 4242 has exited with status 0\n"
+
+
+def _goroutines(*on_threads):
+    lines = [f"  Goroutine 1 - User: {HANG}:40 main.main (0x49a6a0) [semacquire]"]
+    for gid in (6, 7):
+        fn, line, pc = ("workerOne", 17, "0x49a3c5") if gid == 6 else ("workerTwo", 29, "0x49a4e5")
+        where = f" (thread 424{gid})" if gid in on_threads else " [runnable]"
+        lines.append(f"{'*' if gid == 6 else ' '} Goroutine {gid} - User: {HANG}:{line} main.{fn} ({pc}){where}")
+    return "\n".join(lines) + "\n[3 goroutines]\n"
+
+
+def _delve(*continues):
+    stops = list(continues)
+    sent = []
+    last = []
+
+    def run_command(cmd, timeout=None):
+        sent.append(cmd)
+        if cmd.startswith("break "):
+            n = sum(c.startswith("break ") for c in sent)
+            line = cmd.rsplit(":", 1)[1]
+            fn, pc = ("workerOne", "0x49a3c5") if line == "17" else ("workerTwo", "0x49a4e5")
+            return f"Breakpoint {n} set at {pc} for main.{fn}() {HANG}:{line}\n"
+        if cmd == "continue":
+            last[:] = [stops.pop(0)]
+            return last[0]
+        if cmd == "breakpoints":
+            return (
+                f"Breakpoint 1 (enabled) at 0x49a3c5 for main.workerOne() {HANG}:17 (1)\n"
+                f"Breakpoint 2 (enabled) at 0x49a4e5 for main.workerTwo() {HANG}:29 (1)\n"
+            )
+        if cmd == "goroutines":
+            # Both workers run on threads at their breakpoints in the BOTH stop; otherwise only the stopped one.
+            return _goroutines(6, 7) if last == [BOTH] else _goroutines(6 if last == [ONE] else 7)
+        if cmd.startswith("goroutine "):
+            gid = int(cmd.split()[1])
+            fn = "workerOne" if gid == 6 else "workerTwo"
+            return f"0  0x000000000049a3c5 in main.{fn}\n   at {HANG}:{17 if gid == 6 else 29}\n"
+        return ""
+
+    dbg = delve.DelveDebugger(program=str(HANG))
+    dbg.run_command = run_command
+    for line in (17, 29):
+        dbg.set_breakpoint(BreakpointSpec(location=f"{HANG}:{line}"))
+    return dbg, sent
+
+
+def test_one_stop_reports_every_goroutine_on_a_breakpoint():
+    dbg, sent = _delve(BOTH)
+    event = dbg.continue_()
+    assert (event.reason, event.breakpoint_id, event.goroutine_id) == ("breakpoint", 1, 6)
+    assert event.frame.function == "main.workerOne"
+    assert [(h.breakpoint_id, h.goroutine_id, h.selected) for h in event.hits] == [(1, 6, True), (2, 7, False)]
+    assert "; also goroutine 7 hit breakpoint 2 at main.workerTwo" in event.describe()
+    # A goroutine at a breakpoint's line but not on a thread did not reach it in this stop.
+    dbg, _ = _delve(ONE)
+    assert [h.goroutine_id for h in dbg.continue_().hits] == [6]
+
+    # The RPC client reads the other threads' breakpoints from State.Threads.
+    thread_one = {"id": 11, "goroutineID": 6, "file": str(HANG), "line": 17, "function": {"name": "main.workerOne"},
+                  "breakPoint": {"id": 1, "hitCount": {"6": 1}, "totalHitCount": 1}}
+    thread_two = {"id": 12, "goroutineID": 7, "file": str(HANG), "line": 29, "function": {"name": "main.workerTwo"},
+                  "breakPoint": {"id": 2, "hitCount": {"7": 1}, "totalHitCount": 1}}
+    state = {"currentThread": thread_one, "currentGoroutine": {"id": 6}, "Threads": [thread_one, thread_two]}
+    rendered = remote._render_state(state)
+    assert "workerTwo" not in rendered
+    client = remote.DelveRemote("127.0.0.1:1")
+    client._last_state = state
+    event = delve.parse_stop(rendered)
+    event.hits += client._other_hits(event)
+    assert [(h.breakpoint_id, h.goroutine_id, h.selected) for h in event.hits] == [(1, 6, True), (2, 7, False)]
+
+
+def test_both_hang_workers_are_observed_in_order():
+    # Hit together in one stop, then one per stop: nothing is dropped either way.
+    for continues, stops in (([BOTH, EXITED], [1, 1]), ([ONE, TWO, EXITED], [1, 2])):
+        dbg, sent = _delve(*continues)
+        events = BreakpointEvents(dbg, policy=POLICY_RESUME).start()
+        hits = list(events)
+        assert [(h.breakpoint_id, h.goroutine_id) for h in hits] == [(1, 6), (2, 7)]
+        assert [h.seq for h in hits] == [1, 2] and [h.stop_seq for h in hits] == stops
+        assert [h.stack[0].function for h in hits] == ["main.workerOne", "main.workerTwo"]
+        assert events.final.exited and events.error is None and events.get() is None
+        assert sent.count("continue") == len(continues)
+
+
+def test_stop_policy_holds_the_world_until_resume():
+    dbg, sent = _delve(ONE, TWO, EXITED)
+    events = BreakpointEvents(dbg, policy=POLICY_STOP).start()
+    first = events.get(timeout=5)
+    assert first.goroutine_id == 6 and events.wait_paused(5)
+    # The loop is parked, so the debugger is free for the consumer.
+    assert sent.count("continue") == 1 and dbg.stacktrace(6)[0].function == "main.workerOne"
+    events.resume()
+    assert events.get(timeout=5).goroutine_id == 7
+    events.resume()
+    assert events.get(timeout=5) is None and events.final.exited
+    with pytest.raises(DebuggerError, match="not paused"):
+        events.resume()
+
+    # A callable policy decides per stop: stop only when workerTwo is among the hits.
+    dbg, sent = _delve(ONE, TWO, EXITED)
+    events = BreakpointEvents(dbg, policy=lambda hits: POLICY_STOP if any(h.goroutine_id == 7 for h in hits)
+                              else POLICY_RESUME).start()
+    assert [events.get(timeout=5).goroutine_id, events.get(timeout=5).goroutine_id] == [6, 7]
+    assert events.wait_paused(5) and sent.count("continue") == 2
+    events.close(timeout=5)
+    assert events.done and events.final is None and events.get() is None
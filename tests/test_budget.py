@@ -0,0 +1,123 @@
+"""Context-window budget: staged trimming of goroutine dumps and conversation history."""
+from dbgcopilot.core.orchestrator import CopilotOrchestrator
+from dbgcopilot.core.state import SessionState
+from dbgcopilot.llm import providers
+from dbgcopilot.prompts.budget import Budget, estimate_tokens
+
+WAITER = """\
+goroutine {id} [sync.Mutex.Lock, 12 minutes]:
+sync.runtime_SemacquireMutex(0xc000010{id:04d}, 0x0, 0x1)
+\t/usr/local/go/src/runtime/sema.go:95 +0x25
+sync.(*Mutex).lockSlow(0xc000010000)
+\t/usr/local/go/src/sync/mutex.go:173 +0x15d
+sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/sync/mutex.go:92
+main.(*store).get(0xc000010000, {{0x4b0a15, 0x3}})
+\t/src/kv/store.go:41 +0x85
+net/http.HandlerFunc.ServeHTTP(0xc000112000, {{0x5d2a40, 0xc00013e000}}, 0xc000150000)
+\t/usr/local/go/src/net/http/server.go:2136 +0x29
+net/http.(*conn).serve(0xc000118{id:04d}, {{0x5d3190, 0xc000100180}})
+\t/usr/local/go/src/net/http/server.go:2009 +0x612
+created by net/http.(*Server).Serve in goroutine 1
+\t/usr/local/go/src/net/http/server.go:3086 +0x4db
+"""
+
+
+def _hang(count=4000):
+    return "\n".join(WAITER.format(id=i) for i in range(1, count + 1))
+
+
+def _compose(outputs, history, note):
+    return "\n".join(["Preamble", *outputs, *history, note, "Assistant:"])
+
+
+def test_trimming_stops_at_the_first_stage_that_fits():
+    dump = _hang()
+    history = ["User: why is it stuck?", "Assistant: (executed) goroutines\n" + dump]
+
+    roomy = Budget(max_tokens=estimate_tokens(_compose([dump], history, "")))
+    assert roomy.fit(_compose, [dump], history).count("goroutine ") > 4000 and not roomy.trimmed
+
+    grouped = Budget(max_tokens=1000)
+    prompt = grouped.fit(_compose, [dump], history)
+    assert grouped.trimmed == ["collapsed identical goroutine stacks (largest dump: 4000 goroutines in 1 group(s))"]
+    assert "4000 goroutine(s) [sync.Mutex.Lock]" in prompt and "server.go:2009" in prompt
+    assert "Context trimmed to fit the 1000-token window: collapsed" in prompt
+    assert estimate_tokens(prompt) <= 1000
+
+    user_only = Budget(max_tokens=250)
+    prompt = user_only.fit(_compose, [dump], history)
+    assert user_only.trimmed[1] == "dropped runtime and standard-library frames from goroutine stacks"
+    assert "main.(*store).get" in prompt and "sema.go" not in prompt
+    assert "(5 runtime/stdlib frame(s) omitted)" in prompt
+
+
+def test_oldest_history_goes_before_the_last_output_is_cut():
+    history = [f"User: question {i}\nAssistant: " + "long answer " * 40 for i in range(10)]
+    budget = Budget(max_tokens=400)
+    prompt = budget.fit(_compose, ["x = 1"], history)
+    assert budget.trimmed[0].startswith("dropped the ")
+    assert budget.trimmed[0].endswith(" oldest of 10 conversation entries")
+    assert "question 9" in prompt and "question 0" not in prompt and "x = 1" in prompt
+
+    squeezed = Budget(max_tokens=100)
+    prompt = squeezed.fit(_compose, ["line\n" * 2000], history)
+    assert squeezed.trimmed[-1] == "cut the middle out of the debugger output"
+    assert "[truncated]" in prompt and "question" not in prompt
+    assert estimate_tokens(prompt) <= 150
+
+
+def test_copilot_prompt_fits_a_small_model_and_says_what_was_trimmed(monkeypatch):
+    monkeypatch.setattr(providers, "prompt_char_budget", lambda name, model=None: 4000 * 4)
+    prompts = []
+    state = SessionState(session_id="t", colors_enabled=False, selected_provider="ollama")
+    state.replay_llm = lambda prompt: prompts.append(prompt) or "Every request waits on store.mu."
+    orch = CopilotOrchestrator(backend=None, state=state)
+    orch.record_output("goroutines -t", _hang())
+    answer = orch.ask("why is the server hung?")
+    assert estimate_tokens(prompts[0]) <= 4000
+    assert "largest dump: 4000 goroutines in 1 group(s)" in prompts[0]
+    assert answer.startswith("[Context trimmed to fit the 4000-token window: collapsed")
+    assert answer.endswith("Every request waits on store.mu.")
+
+
+def test_copilot_prompt_keeps_long_output_until_the_budget_cuts_it(monkeypatch):
+    prompts = []
+    state = SessionState(session_id="t", colors_enabled=False, selected_provider="ollama")
+    state.replay_llm = lambda prompt: prompts.append(prompt) or "ok"
+    orch = CopilotOrchestrator(backend=None, state=state)
+    output = "\n".join(f"{i:04d} 0x{i:08x}" for i in range(600))
+    orch.record_output("x/600x $sp", output)
+
+    monkeypatch.setattr(providers, "prompt_char_budget", lambda name, model=None: 64000 * 4)
+    orch.ask("what is on the stack?")
+    assert "0000 0x00000000" in prompts[-1] and "0300 0x0000012c" in prompts[-1]
+    assert "[truncated]" not in prompts[-1]
+
+    monkeypatch.setattr(providers, "prompt_char_budget", lambda name, model=None: 1000 * 4)
+    orch.ask("what is on the stack?")
+    assert estimate_tokens(prompts[-1]) <= 1000
+    assert "[truncated]" in prompts[-1] and "cut the middle out of the debugger output" in prompts[-1]
+
+
+def test_the_budget_follows_the_model_the_session_asked_for(monkeypatch):
+    meta = {"context_window": 8192, "max_output_tokens": 192, "models": {"llama3.1:70b": {"context_window": 131072}}}
+    local = providers.Provider("local", "mock", meta, lambda session_config, meta: lambda prompt: "ok")
+    monkeypatch.setattr(providers, "get_provider", lambda name: local if name == "local" else None)
+    assert Budget.for_provider("local").max_tokens == 8000
+    assert Budget.for_provider("local", "llama3.1:70b").max_tokens == 130880
+    assert Budget.for_provider("local", "mistral").max_tokens == 8000
+    assert Budget.for_provider("elsewhere", "llama3.1:70b") is None
+
+    # The copilot reads the model from the session's "<provider>_model" key, as --llm-model sets it.
+    prompts = []
+    state = SessionState(session_id="t", colors_enabled=False, selected_provider="local")
+    state.config["local_model"] = "llama3.1:70b"
+    state.replay_llm = lambda prompt: prompts.append(prompt) or "ok"
+    orch = CopilotOrchestrator(backend=None, state=state)
+    orch.record_output("goroutines -t", _hang(200))
+    orch.ask("why is the server hung?")
+    assert "Context trimmed" not in prompts[0]
+    del state.config["local_model"]
+    orch.ask("why is the server hung?")
+    assert "Context trimmed to fit the 8000-token window" in prompts[1]
@@ -0,0 +1,157 @@
+"""Cancellation and deadlines: aborted LLM streams, interrupted dlv commands and no orphaned processes."""
+import json
+import os
+import signal
+import sys
+import threading
+import time
+import types
+
+import pytest
+
+from dbgcopilot.backends.delve_subprocess import DelveSubprocessBackend
+from dbgcopilot.core.orchestrator import CopilotOrchestrator
+from dbgcopilot.core.state import SessionState
+from dbgcopilot.llm import providers
+from dbgcopilot.llm.base import request_timeout
+from dbgcopilot.utils.context import Cancelled, Context, DeadlineExceeded
+
+# Stands in for dlv: launches a target that ignores SIGINT (it shares dlv's terminal), blocks in
+# "continue" until Ctrl-C, and exits on "exit" without killing the target, as a dlv that dies would.
+FAKE_DLV = """\
+import subprocess, sys, time
+target = subprocess.Popen([sys.executable, "-c",
+    "import signal, time; signal.signal(signal.SIGINT, signal.SIG_IGN); time.sleep(120)"])
+open(sys.argv[2], "w").write(str(target.pid))
+print("Type 'help' for list of commands.")
+while True:
+    sys.stdout.write("(dlv) ")
+    sys.stdout.flush()
+    cmd = sys.stdin.readline().strip()
+    if cmd == "continue":
+        try:
+            time.sleep(120)
+        except KeyboardInterrupt:
+            print("received SIGINT, stopping process (will not forward signal)")
+    elif cmd == "exit":
+        sys.exit(0)
+"""
+
+
+def _alive(pid):
+    try:
+        with open(f"/proc/{pid}/stat") as fh:
+            return fh.read().rsplit(")", 1)[1].split()[0] != "Z"
+    except OSError:
+        return False
+
+
+def _gone(*pids, wait=5.0):
+    end = time.monotonic() + wait
+    while any(_alive(pid) for pid in pids):
+        if time.monotonic() > end:
+            return False
+        time.sleep(0.05)
+    return True
+
+
+def _fake_dlv(tmp_path, context):
+    script = tmp_path / "dlv"
+    script.write_text(f"#!{sys.executable}\n" + FAKE_DLV)
+    script.chmod(0o755)
+    pidfile = tmp_path / "target.pid"
+    backend = DelveSubprocessBackend(program=str(pidfile), delve_path=str(script), context=context)
+    backend.initialize_session()
+    return backend, backend.child.pid, int(pidfile.read_text())
+
+
+class _SlowStream:
+    """An SSE response that sends one token, then stalls until it is closed."""
+
+    status_code = 200
+    text = ""
+
+    def __init__(self):
+        self.closed = False
+
+    def iter_lines(self, decode_unicode=False):
+        yield "data: " + json.dumps({"choices": [{"delta": {"content": "The lock "}}]})
+        while not self.closed:
+            time.sleep(0.01)
+
+    def close(self):
+        self.closed = True
+
+
+def test_context_deadlines_and_cancellation():
+    fired = []
+    ctx = Context.with_timeout(0.2)
+    ctx.on_cancel(lambda: fired.append("timer"))
+    child = ctx.child()
+    assert ctx.timeout(30) <= 0.2 and ctx.timeout() <= 0.2 and Context.background().timeout(30) == 30
+    assert ctx.done.wait(5) and fired == ["timer"]
+    assert isinstance(child.error, DeadlineExceeded) and isinstance(child.error, TimeoutError)
+    with pytest.raises(DeadlineExceeded, match="timed out after 0.2s"):
+        child.timeout(1)
+
+    # A child cancels alone; its parent, and every later request under it, are unaffected.
+    parent = Context.with_timeout(60)
+    stream_ctx = parent.child()
+    stream_ctx.cancel("answer cancelled")
+    assert not parent.cancelled
+    with pytest.raises(Cancelled, match="answer cancelled"), stream_ctx.activate():
+        request_timeout({"llm_timeout": "30"})
+    with parent.activate():
+        assert 59 < request_timeout({"llm_timeout": "90"}) <= 60
+    parent.close()
+
+
+def test_deadline_aborts_a_stalled_stream(monkeypatch, tmp_path):
+    resp = _SlowStream()
+    monkeypatch.setitem(sys.modules, "requests", types.SimpleNamespace(post=lambda *a, **k: resp))
+    start = time.monotonic()
+    stream = providers.get_provider("ollama").complete_stream("why?")
+    with pytest.raises(DeadlineExceeded), Context.with_timeout(0.3).activate():
+        stream.collect()
+    assert resp.closed and stream.text == "The lock " and time.monotonic() - start < 5
+
+    # cancel_stream from another thread closes the response the REPL is blocked reading.
+    resp = _SlowStream()
+    ollama = providers.get_provider("ollama")
+    monkeypatch.setattr(providers, "create_stream", lambda name, prompt, cfg=None: ollama.complete_stream(prompt))
+    state = SessionState(session_id="t", colors_enabled=False, selected_provider="ollama")
+    orch = CopilotOrchestrator(None, state)
+    shown = []
+    threading.Timer(0.2, orch.cancel_stream).start()
+    assert orch._stream_answer("ollama", "why?", shown.append) == ("The lock ", True)
+    assert resp.closed and shown == ["The lock ", "\n"]
+
+
+def test_ctrl_c_during_an_llm_stream_leaves_no_dlv_behind(monkeypatch, tmp_path):
+    ctx = Context.with_timeout(None)
+    backend, dlv_pid, target = _fake_dlv(tmp_path, ctx)
+    assert backend.target_pid() == target and _alive(dlv_pid) and _alive(target)
+
+    resp = _SlowStream()
+    monkeypatch.setitem(sys.modules, "requests", types.SimpleNamespace(post=lambda *a, **k: resp))
+    threading.Timer(0.2, os.kill, (os.getpid(), signal.SIGINT)).start()
+    with pytest.raises(KeyboardInterrupt), ctx.activate():
+        providers.get_provider("ollama").complete_stream("why is it stuck?").collect()
+    # What dbgagent does on Ctrl-C: cancel, then end the session from the main thread.
+    ctx.cancel("interrupted")
+    backend.abort()
+    assert resp.closed and backend.child is None
+    assert _gone(dlv_pid, target)
+
+
+def test_deadline_interrupts_a_running_continue(tmp_path):
+    ctx = Context.with_timeout(60)
+    backend, dlv_pid, target = _fake_dlv(tmp_path, ctx.child(timeout=0.5))
+    start = time.monotonic()
+    with pytest.raises(DeadlineExceeded):
+        backend.run_command("continue")
+    assert time.monotonic() - start < 10 and _alive(dlv_pid)
+    # dlv is back at its prompt, so abort can kill the target and quit cleanly.
+    backend.abort()
+    backend.abort()
+    assert _gone(dlv_pid, target) and not ctx.cancelled
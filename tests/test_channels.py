@@ -0,0 +1,196 @@
+"""Channel starvation analysis and the runtime's "all goroutines are asleep" detection."""
+from dbgcopilot.analyze import (
+    detect_channel_deadlock,
+    detect_deadlock,
+    findings_for_output,
+    format_channel_report,
+    parse_goroutine_dump,
+)
+from dbgcopilot.analyze.offline import offline_report
+from dbgcopilot.analyze.result import build_result
+from dbgcopilot.debugger import delve
+from dbgcopilot.debugger.base import STOP_FATAL, StopEvent
+from dbgcopilot.debugger.hang import watch_for_hang
+
+PIPELINE_SRC = """\
+package main
+
+import (
+\t"fmt"
+\t"sync"
+)
+
+type pipeline struct {
+\tjobs    chan int
+\tresults chan int
+\tdone    chan struct{}
+}
+
+func producer(p *pipeline, wg *sync.WaitGroup) {
+\tdefer wg.Done()
+\tfor i := 0; i < 3; i++ {
+\t\tp.jobs <- i
+\t}
+}
+
+func collector(p *pipeline) {
+\tfor {
+\t\tselect {
+\t\tcase r := <-p.results:
+\t\t\tfmt.Println("result", r)
+\t\tcase <-p.done: // closed by main
+\t\t\treturn
+\t\t}
+\t}
+}
+
+func main() {
+\tp := &pipeline{jobs: make(chan int), results: make(chan int), done: make(chan struct{})}
+\tvar wg sync.WaitGroup
+\twg.Add(1)
+\tgo producer(p, &wg)
+\tgo collector(p)
+\twg.Wait()
+\tclose(p.done)
+}
+"""
+
+# Go 1.27 output for the program above.
+PIPELINE_DUMP = """\
+fatal error: all goroutines are asleep - deadlock!
+
+goroutine 1 [sync.WaitGroup.Wait]:
+sync.runtime_SemacquireWaitGroup(0x29c2cf966070?, 0xe0?)
+\t/usr/local/go/src/runtime/sema.go:114 +0x2e
+sync.(*WaitGroup).Wait(0x29c2cf964120)
+\t/usr/local/go/src/sync/waitgroup.go:206 +0x85
+main.main()
+\t{src}:38 +0x185
+
+goroutine 7 [chan send]:
+main.producer(0x29c2cf962048, 0x0?)
+\t{src}:17 +0x5e
+created by main.main in goroutine 1
+\t{src}:36 +0x12e
+
+goroutine 8 [select]:
+main.collector(0x29c2cf962048)
+\t{src}:23 +0xd5
+created by main.main in goroutine 1
+\t{src}:37 +0x176
+exit status 2
+"""
+
+
+def _pipeline(tmp_path):
+    src = tmp_path / "main.go"
+    src.write_text(PIPELINE_SRC)
+    return PIPELINE_DUMP.replace("{src}", str(src))
+
+
+def test_starved_channels_of_a_runtime_deadlock(tmp_path):
+    text = _pipeline(tmp_path)
+    dump = parse_goroutine_dump(text)
+    report = detect_channel_deadlock(dump)
+    assert report.runtime_deadlock and detect_deadlock(dump) == []
+    assert [w.goroutine_id for w in report.waits] == [7, 8]
+    assert [(op.channel.name, op.direction) for op in report.wait(8).ops] == [
+        ("p.results", "receive"),
+        ("p.done", "receive"),
+    ]
+    starved = {e.channel.name: (e.senders, e.receivers) for e in report.starved}
+    assert starved == {"p.jobs": ([7], []), "p.results": ([], [8]), "p.done": ([], [8])}
+    assert [g.id for g in report.other_blocked] == [1]
+
+    text_report = format_channel_report(report)
+    assert "deadlock detector fired" in text_report
+    assert "Channel starvation (not a mutex cycle): 3 channel(s)" in text_report
+    assert "p.jobs: blocked sending: goroutine 7 (main.producer at" in text_report
+    assert "in a select also waiting on p.done" in text_report
+    assert "goroutine 1 [sync.WaitGroup.Wait] in main.main" in text_report
+    assert "Channel starvation" in findings_for_output(text)
+
+    result = build_result([text])
+    assert (result.issue_type, result.issue_detail) == ("deadlock", "channel-starvation")
+    assert "p.jobs" in result.summary and "all goroutines asleep" in result.summary
+    assert [p.id for p in result.participants] == [7, 8]
+
+
+def test_a_runtime_deadlock_without_source_names_the_blocked_goroutines():
+    text = (
+        "fatal error: all goroutines are asleep - deadlock!\n\n"
+        "goroutine 1 [chan receive]:\nmain.main()\n\t/gone/main.go:12 +0x25\nexit status 2\n"
+    )
+    report = detect_channel_deadlock(parse_goroutine_dump(text))
+    assert report.found and report.starved == [] and report.forever == []
+    assert "- goroutine 1 [chan receive] in main.main at /gone/main.go:12" in format_channel_report(report)
+
+    result = build_result([text])
+    assert (result.issue_type, result.issue_detail) == ("deadlock", "channel-starvation")
+    assert [p.id for p in result.participants] == [1]
+    assert result.summary.startswith("1 goroutine(s) blocked on channel operations")
+    assert "goroutines 1 block forever" in offline_report([text])
+
+
+def test_nil_channels_and_empty_selects_block_forever():
+    # The runtime frames supply the channel address when they are in the dump.
+    forever = parse_goroutine_dump(
+        "goroutine 3 [chan receive (nil chan)]:\nmain.wait()\n\t/x/main.go:9 +0x1\n\n"
+        "goroutine 4 [select (no cases)]:\nmain.park()\n\t/x/main.go:12 +0x1\n\n"
+        "goroutine 5 [chan send]:\nruntime.chansend1(0xc000020120?, 0xc0000a0f38?)\n"
+        "\t/usr/local/go/src/runtime/chan.go:161 +0x1d\nmain.send()\n\t/x/main.go:15 +0x1\n"
+    )
+    report = detect_channel_deadlock(forever)
+    assert not report.runtime_deadlock
+    assert [w.goroutine_id for w in report.forever] == [3, 4]
+    assert [e.channel.address for e in report.starved] == ["0xc000020120"]
+    text_report = format_channel_report(report)
+    assert "goroutine 3 receives from a nil channel in main.wait" in text_report
+    assert "goroutine 4 is in select {} (no cases)" in text_report
+    assert "(A snapshot:" in text_report
+    # A blocked channel alone, with no hang and no runtime detection, is not a deadlock.
+    assert build_result(["goroutine 5 [chan send]:\nmain.send()\n\t/x/main.go:15 +0x1\n"]).issue_type == "unknown"
+
+
+class _FatalTarget:
+    def __init__(self, dump_text):
+        self.dump_text = dump_text
+
+    def resume_async(self):
+        pass
+
+    def read_progress(self, wait):
+        return "", StopEvent(reason=STOP_FATAL, goroutine_id=1, detail="runtime-fatal-throw")
+
+    def interrupt(self):
+        raise AssertionError("a fatal stop is not a hang")
+
+    def target_pid(self):
+        return None
+
+    def fatal_message(self, goroutine_id):
+        return "all goroutines are asleep - deadlock!"
+
+    def goroutines(self):
+        return parse_goroutine_dump(self.dump_text)
+
+
+def test_hang_watch_analyzes_a_runtime_deadlock_stop(tmp_path):
+    result = watch_for_hang(_FatalTarget(_pipeline(tmp_path)), 5, cpu_reader=lambda pid: None)
+    assert not result.hung and result.runtime_deadlock
+    assert result.stop.detail == "all goroutines are asleep - deadlock!"
+    text = result.describe()
+    assert text.startswith("Stopped (fatal: all goroutines are asleep - deadlock!)")
+    assert "3 goroutine(s) captured." in text and "p.jobs: blocked sending" in text
+
+    # Delve reads the message from the ``s`` argument of runtime.fatal.
+    replies = {
+        "goroutine 1 stack 10": "0  0x000000000043a1f6 in runtime.fatal\n"
+        "   at /usr/local/go/src/runtime/panic.go:1101\n"
+        "1  0x0000000000446b9e in runtime.checkdead\n   at /usr/local/go/src/runtime/proc.go:5940\n",
+        "goroutine 1 frame 0 print s": '"all goroutines are asleep - deadlock!"',
+        "goroutine 1 frame 0 whatis s": "string",
+    }
+    dbg = delve.DelveDebugger(program="main")
+    dbg.run_command = lambda cmd, timeout=None: replies.get(cmd, "")
+    assert dbg.fatal_message(1) == "all goroutines are asleep - deadlock!"
@@ -0,0 +1,216 @@
+"""DAP server: handle management across requests and a scripted editor session."""
+import io
+
+import pytest
+
+from dbgcopilot.analyze.goroutines import Frame, Goroutine, GoroutineDump
+from dbgcopilot.dap import ReferenceManager, StaleHandleError, encode_message, read_message
+from dbgcopilot.dap.references import FrameRef, VariableRef
+from dbgcopilot.dap.server import DapServer, parse_locals
+from dbgcopilot.debugger.base import Breakpoint, StopEvent, Variable
+
+WORKER = Frame(function="main.worker", file="/src/main.go", line=42)
+MAIN = Frame(function="main.main", file="/src/main.go", line=12)
+PANIC = """panic: runtime error: index out of range [5] with length 3
+
+goroutine 19 [running]:
+main.worker(...)
+\t/src/main.go:42 +0x1d
+"""
+
+
+def test_handles_are_stable_within_a_stop_and_rejected_after_resume():
+    refs = ReferenceManager()
+    first = refs.frame(19, 0, WORKER)
+    assert refs.frame(19, 0, Frame(function="main.worker", file="/src/main.go", line=42)) == first
+    assert refs.frame(19, 1, MAIN) != first
+    assert refs.get(first, FrameRef).thread_id == 19
+
+    leaf = Variable(name="n", value="3")
+    queue = Variable(name="queue", value="[]int len: 2", children=[Variable(name="[0]", value="1"), leaf])
+    assert refs.variable(first, leaf, first) == 0
+    handle = refs.variable(first, queue, first)
+    assert handle > 0 and refs.variable(first, queue, first) == handle
+    assert refs.get(handle, VariableRef).variable.children[1] is leaf
+    with pytest.raises(StaleHandleError, match="is a variable, not a frame"):
+        refs.get(handle, FrameRef)
+
+    refs.invalidate()
+    with pytest.raises(StaleHandleError, match="from an earlier stop"):
+        refs.get(first, FrameRef)
+    assert refs.frame(19, 0, WORKER) > handle
+    with pytest.raises(StaleHandleError, match="unknown frame reference 999"):
+        refs.get(999, FrameRef)
+
+
+class _FakeDelve:
+    name = "delve"
+    post_mortem = False
+
+    def __init__(self):
+        self.stops = [
+            StopEvent(reason="breakpoint", frame=WORKER, goroutine_id=19, breakpoint_id=1),
+            StopEvent(reason="panic", frame=WORKER, goroutine_id=19, detail="index out of range", raw=PANIC),
+        ]
+        self.commands = []
+        self.closed = False
+
+    def set_breakpoint(self, spec):
+        if spec.location.endswith(":99"):
+            from dbgcopilot.debugger.base import DebuggerError
+
+            raise DebuggerError("could not find /src/main.go:99")
+        return Breakpoint(id=1, location=spec.location, file="/src/main.go", line=42, condition=spec.condition)
+
+    def clear_breakpoint(self, breakpoint_id):
+        self.commands.append(f"clear {breakpoint_id}")
+
+    def continue_(self):
+        return self.stops.pop(0)
+
+    def goroutines(self):
+        return GoroutineDump(goroutines=[Goroutine(id=19, state="running", frames=[WORKER, MAIN], current=True)])
+
+    def stacktrace(self, goroutine_id=None, depth=50):
+        return [WORKER, MAIN][:depth]
+
+    def read_variable(self, expr, cfg=None):
+        return Variable(name=expr, value="[]int len: 2", type="[]int", children=[Variable(name="[0]", value="7")])
+
+    def run_command(self, cmd, timeout=None):
+        self.commands.append(cmd)
+        return "i = 5\nqueue = []int len: 2, cap: 2, [\n\t7,\n\t8,\n]\n"
+
+    def close(self):
+        self.closed = True
+
+
+class _FakeRemoteDelve(_FakeDelve):
+    """A headless dlv over JSON-RPC: structured locals, no CLI ``goroutine`` prefix."""
+
+    def stacktrace(self, goroutine_id=None, depth=50):
+        runtime = Frame(function="runtime.goexit", file="/usr/lib/go/src/runtime/asm_amd64.s", line=1700)
+        return [WORKER, MAIN, runtime][:depth]
+
+    def frame_locals(self, goroutine_id, frame, cfg=None):
+        self.commands.append(f"locals {goroutine_id} {frame}")
+        items = [Variable(name="[0]", value="7"), Variable(name="[1]", value="8")]
+        queue = Variable(name="queue", value="", type="[]int", children=items)
+        return [Variable(name="i", value="5", type="int"), queue]
+
+    def run_command(self, cmd, timeout=None):
+        return "Command failed: 'goroutine' is not supported over the remote JSON-RPC connection"
+
+
+def _session(requests, llm=None, debugger=_FakeDelve):
+    stream = b"".join(encode_message({"seq": i + 1, "type": "request", **r}) for i, r in enumerate(requests))
+    out = io.BytesIO()
+    dbg = debugger()
+    server = DapServer(io.BytesIO(stream), out, llm=llm, opener=lambda program, backend=None, **kw: dbg)
+    server.serve()
+    out.seek(0)
+    messages = []
+    while True:
+        message = read_message(out)
+        if message is None:
+            return dbg, messages
+        messages.append(message)
+
+
+def _responses(messages, command):
+    return [m for m in messages if m["type"] == "response" and m["command"] == command]
+
+
+def test_editor_session_maps_requests_and_reports_crash_analysis():
+    prompts = []
+    dbg, messages = _session(
+        [
+            {"command": "initialize", "arguments": {"adapterID": "dbgcopilot"}},
+            {"command": "launch", "arguments": {"program": "/src/app"}},
+            {
+                "command": "setBreakpoints",
+                "arguments": {"source": {"path": "/src/main.go"}, "breakpoints": [{"line": 42, "condition": "i > 3"}]},
+            },
+            {
+                "command": "setBreakpoints",
+                "arguments": {"source": {"path": "/src/main.go"}, "breakpoints": [{"line": 42}, {"line": 99}]},
+            },
+            {"command": "configurationDone"},
+            {"command": "stackTrace", "arguments": {"threadId": 19}},
+            {"command": "stackTrace", "arguments": {"threadId": 19}},
+            {"command": "scopes", "arguments": {"frameId": 2}},
+            {"command": "variables", "arguments": {"variablesReference": 3}},
+            {"command": "evaluate", "arguments": {"expression": "queue", "frameId": 1}},
+            {"command": "variables", "arguments": {"variablesReference": 4}},
+            {"command": "continue", "arguments": {"threadId": 19}},
+            {"command": "variables", "arguments": {"variablesReference": 4}},
+            {"command": "disconnect"},
+        ],
+        llm=lambda prompt: prompts.append(prompt) or "The slice has 3 elements but index 5 is read.",
+    )
+    failed = [m["command"] for m in messages if m["type"] == "response" and not m["success"]]
+    assert failed == ["variables"]
+    events = [m["event"] for m in messages if m["type"] == "event"]
+    assert events[:2] == ["process", "initialized"]
+
+    second = _responses(messages, "setBreakpoints")[1]["body"]["breakpoints"]
+    assert second[0]["verified"] and not second[1]["verified"] and "could not find" in second[1]["message"]
+    assert "clear 1" in dbg.commands
+
+    stopped = [m["body"] for m in messages if m.get("event") == "stopped"]
+    assert stopped[0]["reason"] == "breakpoint" and stopped[0]["hitBreakpointIds"] == [1]
+    assert stopped[1]["reason"] == "exception" and stopped[1]["threadId"] == 19
+
+    first, again = (r["body"]["stackFrames"] for r in _responses(messages, "stackTrace"))
+    assert [f["id"] for f in first] == [f["id"] for f in again] == [1, 2]
+    assert first[0]["source"]["path"] == "/src/main.go"
+
+    assert _responses(messages, "scopes")[0]["body"]["scopes"][0]["variablesReference"] == 3
+    assert "goroutine 19 frame 1 locals" in dbg.commands
+    local_vars = _responses(messages, "variables")[0]["body"]["variables"]
+    assert [v["name"] for v in local_vars] == ["i", "queue"] and local_vars[1]["value"].endswith("]")
+
+    evaluated = _responses(messages, "evaluate")[0]["body"]
+    assert evaluated["type"] == "[]int" and evaluated["variablesReference"] == 4
+    children = _responses(messages, "variables")[1]["body"]["variables"]
+    assert children == [{"name": "[0]", "value": "7", "variablesReference": 0}]
+    stale = _responses(messages, "variables")[2]
+    assert not stale["success"] and "earlier stop" in stale["message"]
+
+    analysis = [m["body"] for m in messages if m.get("event") == "dbgcopilot/analysis"][0]
+    assert analysis["reason"] == "panic" and "index 5 is read" in analysis["analysis"]
+    assert "index out of range" in prompts[0]
+    assert dbg.closed
+
+
+def test_remote_locals_are_structured_and_the_stack_reports_its_real_depth():
+    dbg, messages = _session(
+        [
+            {"command": "initialize", "arguments": {"adapterID": "dbgcopilot"}},
+            {"command": "launch", "arguments": {"program": "/src/app"}},
+            {"command": "configurationDone"},
+            {"command": "stackTrace", "arguments": {"threadId": 19, "startFrame": 0, "levels": 1}},
+            {"command": "stackTrace", "arguments": {"threadId": 19, "startFrame": 1, "levels": 1}},
+            {"command": "scopes", "arguments": {"frameId": 2}},
+            {"command": "variables", "arguments": {"variablesReference": 3}},
+            {"command": "variables", "arguments": {"variablesReference": 4}},
+            {"command": "disconnect"},
+        ],
+        debugger=_FakeRemoteDelve,
+    )
+    assert not [m["command"] for m in messages if m["type"] == "response" and not m["success"]]
+    first, second = (r["body"] for r in _responses(messages, "stackTrace"))
+    assert [f["name"] for f in first["stackFrames"]] == ["main.worker"] and first["totalFrames"] == 3
+    assert [(f["id"], f["name"]) for f in second["stackFrames"]] == [(2, "main.main")] and second["totalFrames"] == 3
+
+    assert _responses(messages, "scopes")[0]["body"]["scopes"][0]["variablesReference"] == 3
+    assert "locals 19 1" in dbg.commands
+    local_vars, children = (r["body"]["variables"] for r in _responses(messages, "variables"))
+    assert [(v["name"], v["variablesReference"]) for v in local_vars] == [("i", 0), ("queue", 4)]
+    assert [(v["name"], v["value"]) for v in children] == [("[0]", "7"), ("[1]", "8")]
+
+
+def test_locals_listing_joins_wrapped_values():
+    values = parse_locals("(int) i = 5\n(Point) p = {\n  x = 1\n}\n")
+    assert [(v.name, v.type) for v in values] == [("i", "int"), ("p", "Point")]
+    assert values[1].value == "{\n  x = 1\n}"
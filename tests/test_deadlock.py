@@ -0,0 +1,271 @@
+"""Deadlock analyzer tests driven by a real dump of examples/hang/go."""
+from pathlib import Path
+
+from dbgcopilot.analyze import (
+    detect_deadlock,
+    findings_for_output,
+    format_lock_orders,
+    lock_order_fix,
+    parse_goroutine_dump,
+)
+from dbgcopilot.analyze.goroutines import Frame
+from dbgcopilot.analyze.result import build_result, render_text
+from dbgcopilot.debugger import Breakpoint, DebuggerError, StopEvent, Variable
+from dbgcopilot.debugger.locks import LockMonitor
+
+HANG_SRC = Path(__file__).resolve().parents[1] / "examples" / "hang" / "go" / "hang.go"
+
+HANG_DUMP = """\
+fatal error: all goroutines are asleep - deadlock!
+
+goroutine 1 [sync.WaitGroup.Wait]:
+sync.runtime_SemacquireWaitGroup(0x1b28c442e080?, 0xe0?)
+\t/usr/local/go/src/runtime/sema.go:114 +0x2e
+sync.(*WaitGroup).Wait(0x1b28c442c130)
+\t/usr/local/go/src/sync/waitgroup.go:206 +0x85
+main.main()
+\t{hang}:45 +0x10f
+
+goroutine 6 [sync.Mutex.Lock]:
+internal/sync.runtime_SemacquireMutex(0x1b28c441c038?, 0xa0?, 0x14?)
+\t/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x594220)
+\t/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/sync/mutex.go:46
+main.workerOne(0x0?)
+\t{hang}:20 +0x106
+created by main.main in goroutine 1
+\t{hang}:43 +0xba
+
+goroutine 7 [sync.Mutex.Lock]:
+internal/sync.runtime_SemacquireMutex(0x1b28c441c038?, 0xa0?, 0x14?)
+\t/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x594218)
+\t/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/sync/mutex.go:46
+main.workerTwo(0x0?)
+\t{hang}:32 +0x106
+created by main.main in goroutine 1
+\t{hang}:44 +0x105
+"""
+
+
+def _dump():
+    return parse_goroutine_dump(HANG_DUMP.replace("{hang}", str(HANG_SRC)))
+
+
+def test_parse_runtime_dump():
+    dump = _dump()
+    assert dump.header.startswith("fatal error: all goroutines are asleep")
+    assert [g.id for g in dump.goroutines] == [1, 6, 7]
+    worker = dump.get(6)
+    assert worker.state == "sync.Mutex.Lock"
+    assert worker.wait_kind == "mutex"
+    assert worker.top_user_frame().function == "main.workerOne"
+    assert worker.top_user_frame().line == 20
+    assert worker.created_by.function == "main.main"
+
+
+def test_trailing_output_after_the_last_frame_is_not_a_frame():
+    dump = parse_goroutine_dump(
+        "goroutine 1 [chan receive]:\nmain.main()\n\t/x/main.go:12 +0x25\nexit status 2\n"
+        "...additional frames elided...\n"
+    )
+    assert [(f.function, f.line) for f in dump.get(1).frames] == [("main.main", 12)]
+
+
+def test_detect_two_mutex_cycle():
+    cycles = detect_deadlock(_dump())
+    assert len(cycles) == 1
+    cycle = cycles[0]
+    assert sorted(cycle.goroutine_ids) == [6, 7]
+    assert sorted(cycle.lock_addresses) == ["0x594218", "0x594220"]
+    by_id = {w.goroutine_id: w for w in cycle.waits}
+    assert by_id[6].lock.name == "lockB"
+    assert by_id[6].held[0].lock.name == "lockA"
+    assert by_id[6].held[0].acquired_at.endswith("hang.go:17")
+    assert by_id[7].lock.name == "lockA"
+    assert by_id[7].held[0].acquired_at.endswith("hang.go:29")
+    text = cycle.describe()
+    assert "goroutine 6 holds lockA" in text
+    assert "goroutine 7 holds lockB" in text
+
+
+def test_lock_order_inversion_side_by_side_with_fix():
+    cycle = detect_deadlock(_dump())[0]
+    orders = cycle.lock_orders()
+    assert [(o.goroutine_id, o.function) for o in orders] == [(6, "main.workerOne"), (7, "main.workerTwo")]
+    assert [(s.lock.name, s.short_location, s.waiting) for s in orders[0].steps] == [
+        ("lockA", "hang.go:17", False),
+        ("lockB", "hang.go:20", True),
+    ]
+    assert format_lock_orders(cycle).splitlines() == [
+        "Lock order inversion (goroutine 6 vs goroutine 7):",
+        "  goroutine 6 main.workerOne         |   goroutine 7 main.workerTwo",
+        "  1. lockA  hang.go:17               |   1. lockB  hang.go:29",
+        "  2. lockB  hang.go:20  <- blocked   |   2. lockA  hang.go:32  <- blocked",
+    ]
+    summary, details = lock_order_fix(cycle)
+    assert summary == "Acquire lockA before lockB everywhere"
+    assert details == (
+        "Change main.workerTwo to take them in that order (it takes lockB at hang.go:29, lockA at hang.go:32); "
+        "main.workerOne already does (lockA at hang.go:17, lockB at hang.go:20)."
+    )
+
+    # The structured result carries the table as a finding and the order as the first fix, ahead of the LLM's.
+    text = HANG_DUMP.replace("{hang}", str(HANG_SRC))
+    result = build_result([text], report="## Suggested Fixes\n- Use a single mutex for both resources")
+    assert [f.summary for f in result.suggested_fixes] == [summary, "Use a single mutex for both resources"]
+    assert "  - Lock order inversion (goroutine 6 vs goroutine 7):" in render_text(result)
+    assert "Suggested fix: Acquire lockA before lockB everywhere." in findings_for_output(text)
+
+
+def test_runtime_deadlock_from_a_lock_cycle_is_not_channel_starvation():
+    text = HANG_DUMP.replace("{hang}", str(HANG_SRC))
+    findings = findings_for_output(text)
+    assert "Detected 1 lock cycle(s)" in findings
+    assert "Cause: the mutex lock cycle(s) above, not channel starvation." in findings
+    assert "Channel starvation" not in findings
+    result = build_result([text])
+    assert (result.issue_type, result.issue_detail) == ("deadlock", "lock-cycle")
+
+
+def test_delve_goroutines_output():
+    text = "\n".join(
+        [
+            "* Goroutine 6 - User: {hang}:20 main.workerOne (0x49a3c5) [sync.Mutex.Lock 1234]",
+            "\t0  0x000000000043a1f6 in runtime.gopark",
+            "\t    at /usr/local/go/src/runtime/proc.go:398",
+            "\t1  0x000000000049a3c5 in sync.(*Mutex).Lock",
+            "\t    at /usr/local/go/src/sync/mutex.go:46",
+            "\t2  0x000000000049a3c5 in main.workerOne",
+            "\t    at {hang}:20",
+            "  Goroutine 7 - User: {hang}:32 main.workerTwo (0x49a4c5) [sync.Mutex.Lock 1234]",
+            "\t0  0x000000000043a1f6 in runtime.gopark",
+            "\t    at /usr/local/go/src/runtime/proc.go:398",
+            "\t1  0x000000000049a4c5 in main.workerTwo",
+            "\t    at {hang}:32",
+        ]
+    ).replace("{hang}", str(HANG_SRC))
+    dump = parse_goroutine_dump(text)
+    assert dump.get(6).current
+    assert len(dump.get(6).frames) == 3
+    assert "goroutine 6 holds lockA" in findings_for_output(text)
+
+
+def test_no_cycle_without_lock_waits():
+    assert detect_deadlock(parse_goroutine_dump("goroutine 1 [running]:\nmain.main()\n\t/x/main.go:3 +0x1\n")) == []
+
+
+def test_held_locks_of_any_goroutine_from_source():
+    # Goroutine 6 sleeps between its two Lock calls: not waiting on a lock, still holding lockA.
+    text = "goroutine 6 [sleep]:\ntime.Sleep(0x1dcd6500)\n\t/usr/local/go/src/runtime/time.go:315 +0x11\n"
+    text += f"main.workerOne(0x0?)\n\t{HANG_SRC}:18 +0x9a\n"
+    goroutine = parse_goroutine_dump(text).get(6)
+    assert [(h.lock.name, h.acquired_at.endswith("hang.go:17")) for h in goroutine.held_locks()] == [("lockA", True)]
+    assert "- goroutine 6 [sleep]: lockA since hang.go:17 in main.workerOne" in findings_for_output(text)
+
+
+def test_lock_monitor_observations_key_the_wait_graph_without_source():
+    # Built elsewhere: no source to reconstruct held locks from, so only the monitor knows them.
+    dump_text = HANG_DUMP.replace("{hang}", "/build/hang.go")
+    assert detect_deadlock(parse_goroutine_dump(dump_text)) == []
+
+    stops = [
+        (1, 6, "0x594218", 17),  # workerOne: lockA.Lock()
+        (1, 7, "0x594220", 29),  # workerTwo: lockB.Lock()
+        (1, 6, "0x594220", 20),  # workerOne: lockB.Lock(), never granted
+        (1, 7, "0x594218", 32),  # workerTwo: lockA.Lock(), never granted
+    ]
+
+    class FakeDelve:
+        name = "delve"
+
+        def __init__(self):
+            self.queue = list(stops)
+            self.current = None
+
+        def set_breakpoint(self, spec):
+            if spec.location != "sync.(*Mutex).Lock":
+                raise DebuggerError(f"location {spec.location} not found")
+            return Breakpoint(id=1, location=spec.location)
+
+        def continue_(self):
+            if not self.queue:
+                return StopEvent(reason="fatal", detail="all goroutines are asleep - deadlock!")
+            self.current = self.queue.pop(0)
+            bp, gid, _, _ = self.current
+            return StopEvent(reason="breakpoint", breakpoint_id=bp, goroutine_id=gid)
+
+        def eval_in_frame(self, goroutine_id, frame, expr):
+            assert (goroutine_id, frame, expr) == (self.current[1], 0, "m")
+            return Variable(name="m", value=f"(*sync.Mutex)({self.current[2]})")
+
+        def stacktrace(self, goroutine_id=None, depth=50):
+            fn = "main.workerOne" if goroutine_id == 6 else "main.workerTwo"
+            line = self.current[3]
+            return [Frame("sync.(*Mutex).Lock", "/go/src/sync/mutex.go", 46), Frame(fn, "/build/hang.go", line)]
+
+    monitor = LockMonitor(FakeDelve())
+    assert [bp.location for bp in monitor.install()] == ["sync.(*Mutex).Lock"]
+    assert monitor.run().reason == "fatal" and monitor.stops == 5
+
+    dump = monitor.apply(parse_goroutine_dump(dump_text))
+    assert [(h.lock.address, h.acquired_at) for h in dump.get(6).held_locks()] == [("0x594218", "/build/hang.go:17")]
+    cycles = detect_deadlock(dump)
+    assert len(cycles) == 1 and sorted(cycles[0].goroutine_ids) == [6, 7]
+    assert "goroutine 7 holds 0x594220 (acquired at /build/hang.go:29)" in cycles[0].describe()
+
+
+def test_rwmutex_write_lock_is_recorded_once_not_again_for_its_writer_mutex():
+    rw_lock = Frame("sync.(*RWMutex).Lock", "/go/src/sync/rwmutex.go", 150)
+    save = Frame("main.(*Store).save", "/build/store.go", 10)
+    stops = [
+        (2, [rw_lock, save]),
+        # RWMutex.Lock takes rw.w, the Mutex at the RWMutex's own address.
+        (1, [Frame("sync.(*Mutex).Lock", "/go/src/sync/mutex.go", 46), rw_lock, save]),
+    ]
+
+    class FakeDelve:
+        name = "delve"
+
+        def __init__(self):
+            self.queue = list(stops)
+            self.evals = []
+
+        def set_breakpoint(self, spec):
+            ids = {"sync.(*Mutex).Lock": 1, "sync.(*RWMutex).Lock": 2}
+            if spec.location not in ids:
+                raise DebuggerError(f"location {spec.location} not found")
+            return Breakpoint(id=ids[spec.location], location=spec.location)
+
+        def continue_(self):
+            if not self.queue:
+                return StopEvent(reason="manual", detail="interrupted")
+            bp, self.stack = self.queue.pop(0)
+            return StopEvent(reason="breakpoint", breakpoint_id=bp, goroutine_id=6)
+
+        def stacktrace(self, goroutine_id=None, depth=50):
+            return self.stack
+
+        def eval_in_frame(self, goroutine_id, frame, expr):
+            self.evals.append(expr)
+            return Variable(name=expr, value=f"(*sync.{'RWMutex' if expr == 'rw' else 'Mutex'})(0x5000)")
+
+    debugger = FakeDelve()
+    monitor = LockMonitor(debugger)
+    monitor.install()
+    assert monitor.run().reason == "manual" and debugger.evals == ["rw"]
+
+    dump_text = "goroutine 6 [sleep]:\ntime.Sleep(0x1)\n\t/usr/local/go/src/runtime/time.go:315 +0x11\n"
+    dump_text += "main.(*Store).save(0xc000010000)\n\t/build/store.go:12 +0x9a\n"
+    summary = monitor.describe(parse_goroutine_dump(dump_text))
+    assert summary.startswith("Lock monitor: 3 stop(s) observed.\nHeld locks:\n")
+    held = [line for line in summary.splitlines() if line.startswith("- goroutine 6")]
+    assert held == ["- goroutine 6 [sleep]: 0x5000 since store.go:10 in main.(*Store).save"]
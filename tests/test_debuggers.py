@@ -0,0 +1,495 @@
+"""Output parsing and backend selection for the structured debuggers."""
+from pathlib import Path
+import json
+import os
+
+import pytest
+
+from dbgcopilot.debugger import (
+    BreakpointSpec,
+    DebuggerError,
+    DebuggerUnavailable,
+    OptimizedAwayError,
+    PostMortemError,
+    ProcessExitedError,
+    PtracePermissionError,
+    Watchpoint,
+    WatchpointLimitError,
+    describe_placed,
+    detect_backend,
+    open_core,
+    place_breakpoints,
+    resolve_backend,
+)
+from dbgcopilot.debugger import conditions, delve, factory, lldb, ptrace, remote
+
+
+def test_delve_breakpoint_stop():
+    event = delve.parse_stop(
+        "> [Breakpoint 1] main.workerOne() ./hang.go:20 (hits goroutine(6):1 total:1) (PC: 0x49a3c5)\n"
+        "    15:\tdefer wg.Done()\n"
+    )
+    assert event.reason == "breakpoint"
+    assert event.breakpoint_id == 1
+    assert event.goroutine_id == 6
+    assert event.frame.location == "./hang.go:20"
+
+
+def test_delve_exit_and_panic():
+    assert delve.parse_stop("Process 4242 has exited with status 2").exit_code == 2
+    panic = delve.parse_stop("> [unrecovered-panic] runtime.fatalpanic() /usr/local/go/src/runtime/panic.go:1217 (hits goroutine(1):1 total:1)")
+    assert panic.reason == "panic"
+
+
+def test_lldb_backtrace_matches_frame_shape():
+    text = "\n".join(
+        [
+            "* thread #1, name = 'crash', stop reason = signal SIGSEGV: invalid address (fault address: 0x0)",
+            "  * frame #0: 0x0000555555555149 crash`boom at crash.c:6:10",
+            "    frame #1: 0x0000555555555170 crash`main at crash.c:12:5",
+            "    frame #2: 0x00007ffff7c29d90 libc.so.6`__libc_start_call_main + 128",
+            "  thread #2, name = 'worker'",
+            "    frame #0: 0x00007ffff7c91117 libc.so.6`__futex_abstimed_wait_common + 167",
+        ]
+    )
+    dump = lldb.parse_backtrace(text)
+    assert [t.id for t in dump.goroutines] == [1, 2]
+    main_thread = dump.goroutines[0]
+    assert main_thread.current
+    assert main_thread.frames[0].function == "boom"
+    assert main_thread.frames[0].location == "crash.c:6"
+    assert main_thread.top_user_frame().function == "boom"
+    assert main_thread.frames[2].is_runtime()
+    assert lldb.parse_stop(text).reason == "signal"
+
+
+def test_detect_backend(tmp_path):
+    go_bin = tmp_path / "hang"
+    go_bin.write_bytes(b"\x7fELF" + b"\0" * 64 + b"\xff Go buildinf:" + b"\0" * 16)
+    c_bin = tmp_path / "crash"
+    c_bin.write_bytes(b"\x7fELF" + b"\0" * 64)
+    script = tmp_path / "notes.txt"
+    script.write_text("hello")
+    assert detect_backend(str(go_bin)) == "delve"
+    assert detect_backend(str(c_bin)) == "lldb"
+    assert resolve_backend(str(go_bin), "lldb") == "lldb"
+    with pytest.raises(DebuggerError):
+        detect_backend(str(script))
+    with pytest.raises(DebuggerError):
+        resolve_backend(str(c_bin), "windbg")
+
+
+def test_post_mortem_refuses_resume(tmp_path):
+    dbg = delve.DelveDebugger(program="./hang", core="core.1234")
+    assert dbg.post_mortem
+    assert dbg._launch_args() == ["core", "./hang", "core.1234"]
+    # Refused before anything reaches the (unstarted) dlv process.
+    out = dbg.run_command("goroutines; continue")
+    assert out.startswith("[post-mortem]") and "core.1234" in out
+    with pytest.raises(PostMortemError, match="post-mortem"):
+        dbg.continue_()
+    assert delve.DelveDebugger(program="./hang")._launch_args() == ["exec", "./hang"]
+
+    assert lldb._is_resume_command("thread step-over")
+    assert lldb._is_resume_command("c")
+    assert not lldb._is_resume_command("thread backtrace all")
+
+    go_bin = tmp_path / "hang"
+    go_bin.write_bytes(b"\x7fELF" + b"\0" * 64 + b"\xff Go buildinf:")
+    with pytest.raises(DebuggerError, match="Core file"):
+        open_core(str(go_bin), str(tmp_path / "core.missing"))
+
+
+HANG_SRC = Path(__file__).resolve().parents[1] / "examples" / "hang" / "go" / "hang.go"
+
+
+def test_breakpoint_spec_and_condition_scope():
+    spec = BreakpointSpec.parse("hang.go:20 if i > 1000 && name == \"if\" hitcount 3")
+    assert spec == BreakpointSpec(location="hang.go:20", condition='i > 1000 && name == "if"', hit_count=3)
+    assert BreakpointSpec.parse("main.workerOne").condition == ""
+    assert conditions.condition_names('s.count > 3 && len(buf) == n && msg != "x y"') == ["s", "buf", "n", "msg"]
+    src = str(HANG_SRC)
+    assert conditions.unknown_names("wg != nil && lockB.state == 0", src, 20) == []
+    assert conditions.unknown_names("i > 1000", src, 20) == ["i"]
+    assert conditions.unknown_names("wg.noCopy", src, 39) == ["wg"]  # declared on that line
+    assert conditions.unknown_names("time.Now().IsZero()", src, 45) == []
+    assert conditions.unknown_names("anything", "/missing/file.go", 3) == []
+
+
+def _scripted_delve(replies):
+    dbg = delve.DelveDebugger(program=str(HANG_SRC))
+    sent = []
+
+    def run_command(cmd, timeout=None):
+        sent.append(cmd)
+        return replies.get(cmd.split()[0], "")
+
+    dbg.run_command = run_command
+    return dbg, sent
+
+
+def test_delve_conditional_breakpoint_commands():
+    set_out = f"Breakpoint 1 set at 0x49a3c5 for main.workerOne() {HANG_SRC}:20\n"
+    dbg, sent = _scripted_delve({"break": set_out})
+    bp = dbg.set_breakpoint(BreakpointSpec(location="hang.go:20", condition="wg != nil", hit_count=3))
+    assert sent == ["break hang.go:20", "condition 1 wg != nil", "condition -hitcount 1 >= 3"]
+    assert (bp.condition, bp.hit_count, bp.line) == ("wg != nil", 3, 20)
+
+    dbg, sent = _scripted_delve({"break": set_out})
+    with pytest.raises(DebuggerError, match="references i, not in scope"):
+        dbg.set_breakpoint(BreakpointSpec(location="hang.go:20", condition="i > 1000"))
+    assert sent == ["break hang.go:20", "clear 1"]
+
+
+def test_regex_breakpoints_cover_every_match_and_warn_on_none():
+    for text in ("-r 'worker.*' if wg != nil", "/worker.*/ if wg != nil"):
+        assert BreakpointSpec.parse(text) == BreakpointSpec(location="worker.*", condition="wg != nil", regex=True)
+    with pytest.raises(DebuggerError, match="Invalid breakpoint regex"):
+        BreakpointSpec.parse("-r 'worker(('")
+
+    set_out = (
+        f"Breakpoint 1 set at 0x49a3c5 for main.workerOne() {HANG_SRC}:20\n"
+        f"Breakpoint 2 set at 0x49a4c5 for main.workerTwo() {HANG_SRC}:32\n"
+    )
+    dbg, sent = _scripted_delve({"break": set_out})
+    spec = BreakpointSpec.parse("-r 'main.worker.*' if wg != nil")
+    placed = place_breakpoints(dbg, spec)
+    assert sent == ["break /main.worker.*/", "condition 1 wg != nil", "condition 2 wg != nil"]
+    assert [(b.id, b.location, b.condition) for b in placed] == [
+        (1, "main.workerOne", "wg != nil"),
+        (2, "main.workerTwo", "wg != nil"),
+    ]
+    assert describe_placed(spec, placed).splitlines()[0] == "Regex 'main.worker.*' matched 2 function(s); breakpoints:"
+
+    dbg, _ = _scripted_delve({"break": 'Command failed: location "/nothing.*/" not found'})
+    spec = BreakpointSpec.parse("-r nothing.*")
+    assert describe_placed(spec, place_breakpoints(dbg, spec)) == (
+        "Warning: regex 'nothing.*' matched no function; no breakpoint was set."
+    )
+    with pytest.raises(DebuggerError, match="Regex breakpoints need Delve; lldb"):
+        place_breakpoints(lldb.LldbDebugger(program="crash"), spec)
+
+
+def test_delve_breakpoint_listing():
+    listing = "\n".join(
+        [
+            "Breakpoint runtime-fatal-throw (enabled) at 0x43a1c0 for runtime.throw() /usr/local/go/src/runtime/panic.go:1010 (0)",
+            "Breakpoint 1 (enabled) at 0x49a3c5 for main.workerOne() ./hang.go:20 (4)",
+            "\tcond wg != nil",
+            "\tcond -hitcount >= 3",
+            "Breakpoint 2 (disabled) at 0x49a4c5 for main.workerTwo() ./hang.go:32 (0)",
+        ]
+    )
+    dbg, _ = _scripted_delve({"breakpoints": listing})
+    bps = dbg.breakpoints()
+    assert [b.id for b in bps] == [1, 2]
+    assert (bps[0].condition, bps[0].hit_count, bps[0].hits) == ("wg != nil", 3, 4)
+    assert not bps[1].enabled
+    assert bps[0].describe() == "1: main.workerOne at ./hang.go:20 if wg != nil from hit 3 (hits: 4)"
+
+
+def test_lldb_breakpoint_listing():
+    dbg = lldb.LldbDebugger(program="crash")
+    dbg.run_command = lambda cmd, timeout=None: "\n".join(
+        [
+            "Current breakpoints:",
+            "1: file = 'crash.c', line = 6, exact_match = 0, locations = 1, resolved = 1, hit count = 2",
+            "    Options: ignore: 4 enabled ",
+            "    Condition: i > 1000",
+            "",
+            "  1.1: where = crash`boom + 8 at crash.c:6:10, address = 0x0000555555555149, resolved, hit count = 2 ",
+            "2: name = 'main', locations = 1, resolved = 1, hit count = 1",
+        ]
+    )
+    bps = dbg.breakpoints()
+    assert [(b.id, b.location) for b in bps] == [(1, "crash.c:6"), (2, "main")]
+    assert (bps[0].condition, bps[0].hit_count, bps[0].hits) == ("i > 1000", 5, 2)
+
+
+def test_lldb_eval_in_frame_restores_the_selected_thread_and_frame():
+    replies = {
+        "thread info": "thread #2: tid = 4243, 0x0000555555555149 crash`worker at crash.c:6, name = 'crash'",
+        "frame info": "frame #1: 0x0000555555555170 crash`main at crash.c:12:5",
+        "expression -- total": "(int) $0 = 7",
+    }
+    sent = []
+    dbg = lldb.LldbDebugger(program="crash")
+    dbg.run_command = lambda cmd, timeout=None: sent.append(cmd) or replies.get(cmd, "")
+    assert dbg.eval_in_frame(4, 3, "total").value == "7"
+    assert sent[-2:] == ["thread select 2", "frame select 1"]
+
+    sent.clear()
+    replies["expression -- gone"] = "error: use of undeclared identifier 'gone'"
+    with pytest.raises(DebuggerError, match="undeclared identifier"):
+        dbg.eval_in_frame(None, 5, "gone")
+    assert sent[-2:] == ["thread select 2", "frame select 1"]
+
+
+def test_delve_watchpoint_hit_and_scope():
+    dbg, sent = _scripted_delve(
+        {
+            "watch": "Watchpoint 1 on [counter] set at 0xc000012f48\n",
+            "print": "41\n",
+            "whatis": "int\n",
+            "continue": "> [watchpoint 1 on [counter]] main.bump() ./main.go:9 (hits goroutine(7):1 total:1) (PC: 0x49a3c5)\n",
+        }
+    )
+    wp = dbg.set_watchpoint("counter", "rw")
+    assert sent[0] == "watch -rw counter"
+    assert (wp.id, wp.value) == (1, "41")
+    event = dbg.continue_()
+    assert event.reason == "watchpoint"
+    assert (event.watch.old_value, event.watch.new_value) == ("41", "41")
+    assert "by main.bump at ./main.go:9 in goroutine 7" in event.describe()
+
+    dbg.run_command = lambda cmd, timeout=None: "Watchpoint 1 on [counter] went out of scope and was cleared\n"
+    event = dbg.continue_()
+    assert event.reason == "watch-out-of-scope"
+    assert dbg.watchpoints() == []
+
+
+def test_watchpoint_limit_is_reported():
+    dbg, _ = _scripted_delve({"watch": "Watchpoint 1 on [a] set at 0xc000012f48\n"})
+    for i in range(4):
+        dbg._watchpoints[i + 1] = Watchpoint(id=i + 1, expr=f"v{i}")
+    with pytest.raises(WatchpointLimitError, match="4 of 4 debug registers in use \\(v0, v1, v2, v3\\)"):
+        dbg.set_watchpoint("counter")
+    dbg._watchpoints.clear()
+    dbg.run_command = lambda cmd, timeout=None: "Command failed: could not set watchpoint: hardware breakpoint limit exceeded\n"
+    with pytest.raises(WatchpointLimitError, match="Hardware watchpoint limit"):
+        dbg.set_watchpoint("counter")
+    with pytest.raises(DebuggerError, match="Unknown watchpoint kind"):
+        dbg.set_watchpoint("counter", "exec")
+
+
+def test_lldb_watchpoint_old_and_new_values():
+    out = "\n".join(
+        [
+            "Watchpoint 1 hit:",
+            "old value: 0",
+            "new value: 7",
+            "Process 4242 stopped",
+            "* thread #1, name = 'crash', stop reason = watchpoint 1",
+            "    frame #0: 0x0000555555555170 crash`main at crash.c:12:5",
+        ]
+    )
+    event = lldb.parse_stop(out)
+    assert (event.reason, event.breakpoint_id) == ("watchpoint", 1)
+    dbg = lldb.LldbDebugger(program="crash")
+    dbg._launched = True
+    dbg._watchpoints[1] = Watchpoint(id=1, expr="total")
+    dbg.run_command = lambda cmd, timeout=None: out
+    event = dbg.continue_()
+    assert (event.watch.old_value, event.watch.new_value) == ("0", "7")
+    assert event.watch.frame.function == "main"
+
+
+class _FakeDelveServer:
+    """In-memory stand-in for a headless dlv JSON-RPC socket."""
+
+    def __init__(self, handlers):
+        self.handlers = handlers
+        self.calls = []
+        self.pending = b""
+        self.alive = True
+
+    def settimeout(self, value):
+        pass
+
+    def sendall(self, data):
+        if not self.alive:
+            raise ConnectionResetError("connection reset by peer")
+        req = json.loads(data)
+        method = req["method"].split(".", 1)[1]
+        self.calls.append((method, req["params"][0]))
+        result = self.handlers.get(method, {})
+        reply = {"id": req["id"], "result": None, "error": result} if isinstance(result, str) else {"id": req["id"], "result": result, "error": None}
+        self.pending += json.dumps(reply).encode()
+
+    def recv(self, size):
+        chunk, self.pending = self.pending[:size], self.pending[size:]
+        return chunk
+
+    def close(self):
+        pass
+
+
+def test_remote_delve_renders_rpc_replies_and_degrades():
+    frames = [
+        {"pc": 4198400, "file": "/src/app/main.go", "line": 12, "function": {"name": "main.worker"}},
+        {"pc": 4198500, "file": "/src/app/main.go", "line": 30, "function": {"name": "main.main"}},
+    ]
+    server = _FakeDelveServer(
+        {
+            "GetVersion": {"DelveVersion": "1.22.1", "APIVersion": 2},
+            "Stacktrace": {"Locations": frames},
+            "FindLocation": {"Locations": frames[:1]},
+            "CreateBreakpoint": {
+                "Breakpoint": {"id": 3, "addr": 4198400, "file": "/src/app/main.go", "line": 12, "functionName": "main.worker"}
+            },
+        }
+    )
+    sockets = [server]
+
+    def connect(endpoint, timeout):
+        if not sockets:
+            raise ConnectionRefusedError("connection refused")
+        return sockets.pop(0)
+
+    dbg = remote.DelveRemote("127.0.0.1:4040", connect=connect, sleep=lambda s: None)
+    dbg.initialize_session()
+    assert server.calls[0] == ("SetApiVersion", {"APIVersion": 2})
+    assert "Delve 1.22.1" in dbg.startup_output
+    stack = dbg.stacktrace(depth=10)
+    assert [(f.function, f.line) for f in stack] == [("main.worker", 12), ("main.main", 30)]
+    bp = dbg.set_breakpoint("main.go:12")
+    assert (bp.id, bp.function) == (3, "main.worker")
+    assert [b.function for b in place_breakpoints(dbg, BreakpointSpec.parse("/main.work/"))] == ["main.worker"]
+    assert server.calls[-1] == ("CreateBreakpoint", {"Breakpoint": {"addr": 4198400}})
+    assert dbg.run_command("clear x") == "Command failed: usage: clear <breakpoint id>"
+    assert dbg.run_command("goroutine abc stack") == "Command failed: usage: goroutine <id> stack [depth]"
+    assert dbg.run_command("condition x n > 3").startswith("Command failed: usage: condition")
+
+    server.alive = False
+    out = dbg.run_command("stack 10")
+    assert out.startswith("[remote unavailable: last known state")
+    assert "main.worker" in out and dbg.degraded
+    with pytest.raises(DebuggerError, match="could not reconnect"):
+        dbg.continue_()
+
+
+def test_delve_that_cannot_start_is_unavailable(tmp_path):
+    # dbgagent reports DebuggerUnavailable as an infrastructure failure (exit 3).
+    dbg = delve.DelveDebugger(program=str(tmp_path / "app"), delve_path=str(tmp_path / "no-dlv"))
+    with pytest.raises(DebuggerUnavailable, match="Delve failed to start"):
+        dbg.initialize_session()
+
+
+def test_attach_detaches_and_explains_ptrace(tmp_path, monkeypatch):
+    scope_file = tmp_path / "ptrace_scope"
+    scope_file.write_text("1\n")
+    assert ptrace.ptrace_scope(str(scope_file)) == 1
+    assert ptrace.is_permission_error("could not attach to pid 4242: operation not permitted")
+    message = ptrace.permission_message(4242, "operation not permitted", scope=1)
+    assert "ptrace_scope is 1" in message and "only descendants" in message
+    monkeypatch.setattr(ptrace, "ptrace_scope", lambda path=ptrace.YAMA_PTRACE_SCOPE: 3)
+    with pytest.raises(PtracePermissionError, match="rebooted"):
+        ptrace.check_attach(os.getppid())
+
+    dbg = delve.DelveDebugger(pid=4242)
+    assert dbg._launch_args() == ["attach", "4242"]
+    sent = []
+
+    class _Child:
+        def sendline(self, line):
+            sent.append(line)
+
+        def expect(self, *args, **kwargs):
+            pass
+
+        def close(self, force=False):
+            pass
+
+    dbg.child = _Child()
+    dbg._checked = lambda cmd, timeout=None: (_ for _ in ()).throw(DebuggerError("lost"))
+    monkeypatch.setattr(factory, "attach", lambda pid, program=None: dbg)
+    with pytest.raises(DebuggerError):
+        with factory.attached(4242) as attached:
+            attached.snapshot()
+    assert sent == ["quit -c"]
+    assert dbg.run_command("goroutines").startswith("[detached] Process 4242 was resumed")
+
+    # An exit inside a compound command detaches too, after running what came before it.
+    dbg = delve.DelveDebugger(pid=4243)
+    dbg.child = _Child()
+    dbg._send_and_capture = lambda cmd, timeout=None: sent.append(cmd) or f"ran {cmd}"
+    sent.clear()
+    assert dbg.run_command("bt; exit; goroutines") == "ran bt\n[detached] Process 4243 resumed."
+    assert sent == ["bt", "quit -c"]
+
+
+def test_eval_in_frame_scopes_the_expression_and_flags_optimized_away_values():
+    replies = {
+        "goroutine 7 frame 2 print len(q.items) == cap(q.items)": "true\n",
+        "goroutine 7 frame 2 whatis len(q.items) == cap(q.items)": "bool\n",
+        "frame 0 print n": "(unreadable could not find loclist entry at 0x4a3c5f for address 0x4a3c7e)\n",
+        "frame 1 print total": "Command failed: could not find symbol value for total\n",
+    }
+    dbg = delve.DelveDebugger(program="/tmp/app")
+    dbg.run_command = lambda cmd, timeout=None: replies.get(cmd, "")
+    var = dbg.eval_in_frame(7, 2, "len(q.items) == cap(q.items)")
+    assert (var.value, var.type) == ("true", "bool")
+    with pytest.raises(OptimizedAwayError, match="'n' in frame 0: .* optimized away") as info:
+        dbg.eval_in_frame(None, 0, "n")
+    assert info.value.reason.startswith("could not find loclist entry")
+    with pytest.raises(DebuggerError, match="could not find symbol value for total") as info:
+        dbg.eval_in_frame(None, 1, "total")
+    assert not isinstance(info.value, OptimizedAwayError)
+
+    server = _FakeDelveServer({"Eval": {"Variable": {"name": "x", "type": "int", "kind": 2, "value": "42"}}})
+    rdbg = remote.DelveRemote("127.0.0.1:4040", connect=lambda endpoint, timeout: server, sleep=lambda s: None)
+    rdbg.initialize_session()
+    assert rdbg.eval_in_frame(5, 3, "x * 2").value == "42"
+    assert server.calls[-1][1]["Scope"] == {"GoroutineID": 5, "Frame": 3}
+    server.handlers["Eval"] = {"Variable": {"name": "x", "unreadable": "optimized out", "type": "int"}}
+    with pytest.raises(OptimizedAwayError, match="of goroutine 5"):
+        rdbg.eval_in_frame(5, 3, "x")
+
+
+def test_delve_steps_report_goroutine_switches_and_refuse_an_exited_process():
+    replies = {
+        "goroutine": "Thread 101 at ./main.go:10\nGoroutine 1:\n\tRuntime: ./main.go:10 main.main (0x49a3c5)\n",
+        "next": "> main.main() ./main.go:11 (PC: 0x49a3d0)\n     6:\tfunc main() {\n=>  11:\t\tgo worker()\n",
+        "stack 50": "0  0x000000000049a3d0 in main.main\n   at ./main.go:11\n",
+    }
+    dbg = delve.DelveDebugger(program="/tmp/app")
+    sent = []
+    dbg.run_command = lambda cmd, timeout=None: sent.append(cmd) or replies.get(cmd, "")
+    event = dbg.next()
+    assert (event.reason, event.detail, event.goroutine_id) == ("step", "next", 1)
+    assert [f.line for f in event.stack] == [11] and event.frame.line == 11
+    assert sent == ["goroutine", "next", "goroutine", "stack 50"]
+
+    # A breakpoint in another goroutine ends the step there; Delve keeps the step pending.
+    replies["step"] = (
+        "> [Breakpoint 2] main.worker() ./main.go:30 (hits goroutine(7):1 total:1) (PC: 0x49a4c0)\n"
+        "\tbreakpoint hit during step\n"
+    )
+    event = dbg.step()
+    assert event.reason == "breakpoint" and event.goroutine_id == 7
+    assert event.detail.startswith(
+        "step interrupted: goroutine 7 hit breakpoint 2 while goroutine 1 was running 'step'"
+    )
+    assert "still pending" in event.describe()
+    # The stopped thread runs another goroutine than the selected one.
+    replies["stepout"] = "> goroutine(9): main.consume() ./main.go:44 (PC: 0x49a5c0)\n"
+    event = dbg.step_out()
+    assert event.detail == "stepout (started in goroutine 1, now in goroutine 9)"
+
+    replies["goroutine"] = "Command failed: Process 4242 has exited with status 0\n"
+    with pytest.raises(ProcessExitedError, match="Cannot next: the process has exited with status 0"):
+        dbg.next()
+
+    server = _FakeDelveServer(
+        {
+            "State": {"State": {"currentGoroutine": {"id": 1}, "currentThread": {"id": 3, "goroutineID": 1}}},
+            "Command": {
+                "State": {
+                    "NextInProgress": True,
+                    "currentGoroutine": {"id": 1},
+                    "currentThread": {
+                        "goroutineID": 7, "file": "/src/main.go", "line": 30, "pc": 1,
+                        "function": {"name": "main.worker"},
+                        "breakPoint": {"id": 2, "hitCount": {"7": 1}, "totalHitCount": 1},
+                    },
+                }
+            },
+        }
+    )
+    rdbg = remote.DelveRemote("127.0.0.1:4040", connect=lambda endpoint, timeout: server, sleep=lambda s: None)
+    rdbg.initialize_session()
+    event = rdbg.next()
+    assert event.reason == "breakpoint" and event.goroutine_id == 7 and "still pending" in event.detail
+    server.handlers["State"] = {"State": {"exited": True, "Pid": 4242, "exitStatus": 2}}
+    with pytest.raises(ProcessExitedError, match="status 2"):
+        rdbg.step()
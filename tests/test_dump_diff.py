@@ -0,0 +1,69 @@
+"""Diffing two goroutine dumps: stuck, appeared, transitioned and gone goroutines."""
+from dbgcopilot.analyze import diff_dumps, format_dump_diff, parse_goroutine_dump
+
+STUCK = """\
+goroutine {id} [sync.Mutex.Lock, {mins} minutes]:
+sync.runtime_SemacquireMutex(0xc000010008, 0x0, 0x1)
+\t/usr/local/go/src/runtime/sema.go:95 +0x25
+sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/sync/mutex.go:92
+main.(*pool).acquire(0xc000010000)
+\t/src/pool/pool.go:31 +0x85
+created by main.(*server).handle in goroutine 1
+\t/src/pool/server.go:18 +0x4f
+"""
+
+WORKER = """\
+goroutine {id} [{state}]:
+main.worker(0xc000020000)
+\t/src/pool/worker.go:{line} +0x2d
+created by main.main in goroutine 1
+\t/src/pool/main.go:22 +0x4f
+"""
+
+MAIN = "goroutine 1 [select]:\nmain.main()\n\t/src/pool/main.go:40 +0x1a\n"
+
+
+def _dump(*parts):
+    return parse_goroutine_dump("\n".join([MAIN, *parts]))
+
+
+def test_classifies_goroutines_across_snapshots():
+    before = _dump(
+        STUCK.format(id=7, mins=2),
+        STUCK.format(id=8, mins=2),
+        WORKER.format(id=9, state="chan receive", line=12),
+        WORKER.format(id=10, state="running", line=15),
+        WORKER.format(id=11, state="chan receive", line=12),
+    )
+    after = _dump(
+        STUCK.format(id=7, mins=9),
+        # Same place but a shorter wait: it woke up and blocked again, so it is not stuck.
+        STUCK.format(id=8, mins=1),
+        WORKER.format(id=9, state="chan send", line=14),
+        WORKER.format(id=11, state="chan receive", line=12),
+        STUCK.format(id=30, mins=0),
+        STUCK.format(id=31, mins=0),
+    )
+    diff = diff_dumps(before, after)
+    assert [new.id for _, new in diff.stuck] == [1, 7, 11]
+    assert sorted(new.id for _, new in diff.transitioned) == [8, 9]
+    assert [g.id for g in diff.appeared] == [30, 31] and [g.id for g in diff.gone] == [10]
+    assert diff.matched_by_creation == 0 and diff.changed
+
+    report = format_dump_diff(diff, total=len(after))
+    assert report.startswith("Goroutine dump diff: 3 stuck, 2 appeared, 2 changed state or stack, 1 gone")
+    stuck_at = report.index("STUCK in the same wait")
+    assert stuck_at < report.index("Appeared since") < report.index("Changed state")
+    assert "main.(*pool).acquire at /src/pool/pool.go:31" in report[stuck_at:report.index("Appeared since")]
+    assert "goroutine 9: [chan receive] main.worker -> [chan send] main.worker" in report
+
+
+def test_dumps_from_two_runs_pair_by_creation_site():
+    before = _dump(STUCK.format(id=7, mins=3), WORKER.format(id=9, state="chan receive", line=12))
+    # Restarted process: same goroutines under new ids, and id 7 now belongs to a different goroutine.
+    after = _dump(WORKER.format(id=7, state="chan receive", line=12), STUCK.format(id=42, mins=5))
+    diff = diff_dumps(before, after)
+    assert sorted((old.id, new.id) for old, new in diff.stuck) == [(1, 1), (7, 42), (9, 7)]
+    assert not diff.changed and diff.matched_by_creation == 2
+    assert "2 goroutine(s) matched by creation site" in format_dump_diff(diff)
@@ -0,0 +1,47 @@
+"""Source context attached to stack frames."""
+from pathlib import Path
+
+from dbgcopilot.analyze import extract_stack, findings_for_output, format_stacktrace
+from dbgcopilot.analyze.goroutines import Frame
+from dbgcopilot.utils.source import SourceCache, context
+
+CRASH_SRC = Path(__file__).resolve().parents[1] / "examples" / "crash" / "go" / "crash.go"
+
+
+def test_context_window_marks_active_line():
+    ctx = context(Frame(function="main.boom", file=str(CRASH_SRC), line=8), radius=3)
+    assert ctx.start == 5
+    assert ctx.lines[ctx.active_index] == "\tfmt.Println(*ptr)"
+    assert len(ctx.lines) == 7
+    edge = context(Frame(function="main.main", file=str(CRASH_SRC), line=13), radius=3)
+    assert edge.numbered()[-1][0] == 14  # clipped at end of file
+    assert not context(Frame(function="x", file="/nowhere/x.go", line=3)).available
+
+
+def test_stacktrace_rendering_reads_each_file_once():
+    reads = []
+
+    class CountingCache(SourceCache):
+        def lines(self, path):
+            if path not in self._files:
+                reads.append(path)
+            return super().lines(path)
+
+    frames = [
+        Frame(function="main.boom", file=str(CRASH_SRC), line=8),
+        Frame(function="main.main", file=str(CRASH_SRC), line=13),
+        Frame(function="runtime.main", file="/usr/local/go/src/runtime/proc.go.missing", line=283),
+    ]
+    text = format_stacktrace(frames, radius=1, sources=CountingCache())
+    assert reads == [str(CRASH_SRC), "/usr/local/go/src/runtime/proc.go.missing"]
+    assert "  > 8 | \tfmt.Println(*ptr)" in text
+    assert "#2 runtime.main" in text and "(source not available locally)" in text
+
+
+def test_backtrace_output_gets_source_in_findings():
+    gdb = f"#0  main.boom () at {CRASH_SRC}:8\n#1  0x000000000049b0ef in main.main () at {CRASH_SRC}:13\n"
+    assert [f.line for f in extract_stack(gdb)] == [8, 13]
+    findings = findings_for_output(gdb, source_radius=2)
+    assert findings.startswith("Stack with source context:")
+    assert "  > 13 | \tboom()" in findings
+    assert findings_for_output(gdb, source_radius=0) == ""
@@ -0,0 +1,58 @@
+"""Grouping of large goroutine dumps by identical stack."""
+from dbgcopilot.analyze import condense_goroutine_output, group_goroutines, parse_goroutine_dump
+
+WORKER = """\
+goroutine {id} [chan receive, {mins} minutes]:
+main.worker(0xc0000{id:03d}0, {id})
+\t/src/pool/main.go:14 +0x2d
+created by main.main in goroutine 1
+\t/src/pool/main.go:30 +0x4f
+"""
+
+LOCKED = """\
+goroutine {id} [sync.Mutex.Lock]:
+sync.runtime_SemacquireMutex(0xc000010{id:03d}, 0x0, 0x1)
+\t/usr/local/go/src/runtime/sema.go:95 +0x25
+sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/sync/mutex.go:46
+main.(*cache).get(0xc000010000, {{0x4b0a15, 0x3}})
+\t/src/pool/cache.go:22 +0x85
+"""
+
+
+def _big_dump():
+    parts = ["goroutine 1 [select]:\nmain.main()\n\t/src/pool/main.go:35 +0x1a\n"]
+    parts += [WORKER.format(id=i, mins=i % 7) for i in range(2, 502)]
+    parts += [LOCKED.format(id=i) for i in range(502, 512)]
+    return "\n".join(parts)
+
+
+def test_identical_stacks_collapse_with_count():
+    dump = parse_goroutine_dump(_big_dump())
+    groups = group_goroutines(dump)
+    assert [g.count for g in groups] == [500, 10, 1]
+    assert groups[0].representative.id == 2
+    assert groups[0].state == "chan receive"
+    assert "500 goroutine(s) [chan receive]: 2, 3, 4" in groups[0].describe()
+    assert "(waiting up to 6 minutes)" in groups[0].describe()
+
+
+def test_filters_by_state_and_stack_substring():
+    dump = parse_goroutine_dump(_big_dump())
+    assert [g.count for g in group_goroutines(dump, states=["sync.Mutex"])] == [10]
+    assert [g.count for g in group_goroutines(dump, states=["select", "chan receive"])] == [500, 1]
+    assert [g.count for g in group_goroutines(dump, contains="cache.go")] == [10]
+    assert group_goroutines(dump, states=["IO wait"]) == []
+
+
+def test_condensed_output_replaces_large_dumps_only():
+    text = _big_dump()
+    condensed = condense_goroutine_output(text)
+    assert condensed.startswith("511 of 511 goroutine(s) in 3 group(s)")
+    assert len(condensed) < len(text) // 20
+    assert "raw dump is available on request" in condensed
+    small = "goroutine 1 [running]:\nmain.main()\n\t/x/main.go:3 +0x1\n"
+    assert condense_goroutine_output(small) == small
+    assert condense_goroutine_output("Process 1 exited") == "Process 1 exited"
+    filtered = condense_goroutine_output(small, states=["running"])
+    assert "Filter: state in running" in filtered
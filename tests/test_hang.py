@@ -0,0 +1,67 @@
+"""Automatic hang detection around continue."""
+from dbgcopilot.analyze import parse_goroutine_dump
+from dbgcopilot.debugger.base import STOP_BREAKPOINT, STOP_EXITED, StopEvent
+from dbgcopilot.debugger.hang import watch_for_hang
+
+DUMP = """\
+goroutine 1 [chan receive]:
+main.main()
+\t/src/app/main.go:20 +0x1a
+"""
+
+
+class _FakeTarget:
+    """Replays one (output, stop) pair per poll; the fake clock advances one second per poll."""
+
+    def __init__(self, script):
+        self.script = list(script)
+        self.now = 0.0
+        self.resumes = 0
+        self.interrupted = False
+
+    def clock(self):
+        return self.now
+
+    def resume_async(self):
+        self.resumes += 1
+
+    def read_progress(self, wait):
+        self.now += 1.0
+        return self.script.pop(0) if self.script else ("", None)
+
+    def interrupt(self):
+        self.interrupted = True
+        return ""
+
+    def target_pid(self):
+        return 4242
+
+    def goroutines(self):
+        return parse_goroutine_dump(DUMP)
+
+
+def test_silent_idle_target_is_paused_and_dumped():
+    target = _FakeTarget([("starting\n", None)])
+    result = watch_for_hang(target, 5, clock=target.clock, cpu_reader=lambda pid: 0.01)
+    assert result.hung and target.interrupted
+    assert result.output_bytes == len("starting\n")
+    assert len(result.dump) == 1
+    assert "No progress for 5.0s" in result.describe()
+
+
+def test_output_and_breakpoint_hits_count_as_progress():
+    hit = StopEvent(reason=STOP_BREAKPOINT, breakpoint_id=1)
+    script = [("tick\n", None), ("", None), ("", hit), ("", None), ("tick\n", None)] * 3
+    script.append(("", StopEvent(reason=STOP_EXITED, exit_code=0)))
+    target = _FakeTarget(script)
+    result = watch_for_hang(target, 3, clock=target.clock, cpu_reader=lambda pid: None)
+    assert not result.hung and not target.interrupted
+    assert result.events == 3 and target.resumes == 4
+    assert result.stop.exited
+
+
+def test_cpu_bound_target_without_output_is_not_flagged():
+    target = _FakeTarget([("", None)] * 20 + [("", StopEvent(reason=STOP_EXITED, exit_code=0))])
+    # A full core's worth of CPU time per second of wall time.
+    result = watch_for_hang(target, 3, clock=target.clock, cpu_reader=lambda pid: target.now)
+    assert not result.hung and result.stop.exited
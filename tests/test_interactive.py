@@ -0,0 +1,110 @@
+"""Interactive chat loop: actions run against the debugger, history is kept and bounded."""
+import pytest
+
+from dbgcopilot.debugger.base import Breakpoint, StopEvent, Variable
+from dbgcopilot.llm.base import LLMError, RateLimited
+from dbgcopilot.session.interactive import History, Interactive, parse_action
+from dbgcopilot.utils.context import DeadlineExceeded
+
+
+class _FakeDelve:
+    name = "delve"
+
+    def __init__(self):
+        self.calls = []
+
+    def set_breakpoint(self, spec):
+        self.calls.append(("break", spec.location, spec.condition))
+        return Breakpoint(id=1, location=spec.location, condition=spec.condition)
+
+    def continue_(self):
+        self.calls.append(("continue",))
+        return StopEvent(reason="breakpoint", raw="> main.worker() ./main.go:42 (hits goroutine(19):1 total:1)")
+
+    def read_variable(self, expr, cfg=None):
+        self.calls.append(("print", expr))
+        return Variable(name=expr, value="3")
+
+    def run_command(self, cmd, timeout=None):
+        self.calls.append(("raw", cmd))
+        return f"ran {cmd}"
+
+
+class _ScriptedLLM:
+    def __init__(self, answers):
+        self.answers = list(answers)
+        self.prompts = []
+
+    def __call__(self, prompt):
+        self.prompts.append(prompt)
+        answer = self.answers.pop(0)
+        if isinstance(answer, BaseException):
+            raise answer
+        return answer
+
+
+def test_actions_run_and_history_carries_over():
+    dbg = _FakeDelve()
+    llm = _ScriptedLLM(
+        [
+            '<action>{"tool": "set_breakpoint", "location": "main.go:42", "condition": "i > 3"}</action>',
+            '<action>{"tool": "continue"}</action>',
+            "Goroutine 19 stopped at main.go:42.",
+            '<action>{"tool": "read_variable", "expr": "len(queue)"}</action>',
+            "<cmd>goroutine 19 frame 2 print len(queue)</cmd>",
+            "The queue holds 3 items.",
+        ]
+    )
+    chat = Interactive(dbg, llm)
+    first = chat.ask("stop at main.go:42 when i > 3")
+    assert first.answer == "Goroutine 19 stopped at main.go:42."
+    assert [r.action.tool for r in first.actions] == ["set_breakpoint", "continue"]
+    assert dbg.calls[:2] == [("break", "main.go:42", "i > 3"), ("continue",)]
+    assert "hits goroutine(19)" in llm.prompts[2]
+
+    second = chat.ask("evaluate len(queue) in frame 2")
+    assert second.answer == "The queue holds 3 items."
+    assert ("raw", "goroutine 19 frame 2 print len(queue)") in dbg.calls
+    # The earlier question and its results are still in the prompt.
+    assert "stop at main.go:42 when i > 3" in llm.prompts[-1]
+    assert "len(queue) = 3" in llm.prompts[-1]
+
+
+def test_unknown_tool_is_reported_and_actions_are_capped():
+    dbg = _FakeDelve()
+    llm = _ScriptedLLM(['<action>{"tool": "reboot"}</action>'] + ['<action>{"tool": "continue"}</action>'] * 3)
+    chat = Interactive(dbg, llm, max_actions=2)
+    reply = chat.ask("go")
+    assert reply.limited
+    assert reply.actions[0].error and "unknown tool 'reboot'" in reply.actions[0].output
+    assert "Available tools:" in llm.prompts[1]
+    assert "Stopped after 2 debugger action(s)" in reply.answer
+    assert dbg.calls == [("continue",)]
+    assert parse_action('<action>{"no": "tool"}</action>').tool == "invalid"
+    assert parse_action("plain answer") is None
+
+
+def test_history_is_bounded_and_says_so():
+    history = History(max_chars=200)
+    for i in range(20):
+        history.add("User", f"question number {i} " + "x" * 20)
+    assert history.size() <= 200
+    assert history.dropped > 0
+    assert history.turns[-1].text.startswith("question number 19")
+    assert "earlier message(s) were dropped" in history.notice()
+
+
+def test_loop_reports_only_errors_the_next_question_can_get_past():
+    written = []
+    chat = Interactive(_FakeDelve(), _ScriptedLLM([RateLimited("HTTP 429"), "Goroutine 19 is blocked."]))
+    questions = ["why?", "and now?", "exit"]
+    chat.run(lambda prompt: questions.pop(0), written.append)
+    assert "LLM error: HTTP 429" in written and written[-1] == "Goroutine 19 is blocked."
+
+    # The deadline ends the conversation instead of failing every later question.
+    for error in (DeadlineExceeded("deadline exceeded"), LLMError("HTTP 401", status=401)):
+        chat = Interactive(_FakeDelve(), _ScriptedLLM([error, "unused"]))
+        questions = ["why?", "and now?"]
+        with pytest.raises(type(error)):
+            chat.run(lambda prompt: questions.pop(0), lambda text: None)
+        assert questions == ["and now?"]
@@ -0,0 +1,56 @@
+"""Goroutine leak detection: monotonic growth that keeps its goroutines, but not pool churn."""
+from dbgcopilot.analyze import detect_leak, format_leak_report, parse_goroutine_dump
+
+LEAKED = """\
+goroutine {id} [chan receive, {mins} minutes]:
+main.(*Server).waitResult(0xc000010000)
+\t/src/api/server.go:88 +0x45
+created by main.(*Server).handle in goroutine 1
+\t/src/api/server.go:61 +0x8f
+"""
+
+WORKER = """\
+goroutine {id} [select]:
+main.(*pool).run(0xc000020000)
+\t/src/api/pool.go:24 +0x2d
+created by main.(*pool).grow in goroutine 1
+\t/src/api/pool.go:17 +0x4f
+"""
+
+MAIN = "goroutine 1 [IO wait]:\nmain.main()\n\t/src/api/main.go:40 +0x1a\n"
+
+
+def _dump(leaked, workers):
+    parts = [MAIN] + [LEAKED.format(id=i, mins=1) for i in leaked] + [WORKER.format(id=i) for i in workers]
+    return parse_goroutine_dump("\n".join(parts))
+
+
+def test_growing_stack_that_keeps_its_goroutines_is_a_leak():
+    samples = [
+        # The pool grows under load too, but its workers are replaced between samples.
+        _dump(leaked=[10, 11], workers=[20, 21]),
+        _dump(leaked=[10, 11, 12, 13], workers=[30, 31, 32]),
+        _dump(leaked=[10, 11, 12, 13, 14, 15, 16], workers=[40, 41, 42, 43]),
+    ]
+    suspects = detect_leak(samples)
+    assert len(suspects) == 1
+    leak = suspects[0]
+    assert leak.spawner == "main.(*Server).handle" and leak.counts == [2, 4, 7]
+    assert leak.rate == 2.5 and leak.retained == 1.0 and leak.group.ids[-1] == 16
+    assert "close it when the producer is done" in leak.likely_missing()
+
+    report = format_leak_report(suspects, samples=len(samples))
+    assert report.splitlines()[0] == "Goroutine leak suspected: 1 stack(s) grew in every one of 3 samples."
+    assert (
+        "1. Goroutines started by main.(*Server).handle pile up [chan receive] in main.(*Server).waitResult "
+        "(/src/api/server.go:88): 2 -> 4 -> 7 (+2.5 per sample, 100% still alive from sample to sample)"
+    ) in report
+    assert report.endswith("explain which cancellation, channel close or WaitGroup.Done is missing.")
+
+
+def test_flat_or_short_series_is_not_a_leak():
+    flat = [_dump(leaked=[10, 11], workers=[20]), _dump(leaked=[10, 11, 12], workers=[20]), _dump([10, 12, 13], [20])]
+    assert detect_leak(flat) == []
+    assert detect_leak(flat[:2]) == []
+    assert format_leak_report([], samples=2) == "Goroutine leak check needs at least 3 samples; have 2."
+    assert format_leak_report([], samples=3).startswith("No goroutine leak:")
@@ -0,0 +1,65 @@
+"""On-disk LLM response cache: keys, TTL expiry and cache-hit recording."""
+import json
+
+from dbgcopilot.llm import providers
+from dbgcopilot.llm.cache import Cache, CachedClient, cache_key
+from dbgcopilot.session.recorder import Recorder
+
+
+class _Clock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+def test_cached_client_serves_exact_repeats(tmp_path):
+    calls = []
+
+    def ask(prompt):
+        calls.append(prompt)
+        return f"answer {len(calls)}"
+
+    client = CachedClient(ask, Cache(str(tmp_path)), provider="openrouter", model="m", options={"temperature": 0.2})
+    assert client("why deadlock?") == "answer 1"
+    assert client.last_cache_hit is False
+    assert client("why deadlock?") == "answer 1"
+    assert client.last_cache_hit is True
+    assert client("why deadlock? (again)") == "answer 2"
+    assert calls == ["why deadlock?", "why deadlock? (again)"]
+    warm, cold = {"temperature": 0.2}, {"temperature": 0.7}
+    assert cache_key("openrouter", "m", "p", warm) != cache_key("openrouter", "m", "p", cold)
+    assert cache_key("openrouter", "m", "p") != cache_key("openrouter", "other", "p")
+
+
+def test_entries_expire_and_cache_can_be_disabled(tmp_path):
+    clock = _Clock()
+    cache = Cache(str(tmp_path), ttl=60, clock=clock)
+    key = cache_key("anthropic", "m", "prompt")
+    cache.put(key, "cached")
+    clock.now += 30
+    assert cache.get(key) == "cached"
+    clock.now += 31
+    assert cache.get(key) is None
+    assert not list(tmp_path.glob("*/*.json"))
+
+    off = Cache.from_config({"llm_cache": "off", "llm_cache_dir": str(tmp_path)})
+    off.put(key, "ignored")
+    assert off.get(key) is None
+    assert Cache.from_config({"llm_cache_ttl": "5", "llm_cache_dir": str(tmp_path)}).ttl == 5.0
+
+
+def test_cached_provider_client_and_recorded_hits(tmp_path, monkeypatch):
+    monkeypatch.setattr(providers, "create_client", lambda name, session_config=None: lambda prompt: "live")
+    config = {"llm_cache_dir": str(tmp_path), "anthropic_model": "claude-test"}
+    assert isinstance(providers.create_cached_client("anthropic", config), CachedClient)
+    assert not isinstance(providers.create_cached_client("anthropic", {**config, "llm_cache": "off"}), CachedClient)
+    assert not isinstance(providers.create_cached_client("mock-local", config), CachedClient)
+
+    path = tmp_path / "session.ndjson"
+    recorder = Recorder(str(path))
+    recorder.record_llm("p", "r", provider="anthropic", cache_hit=True)
+    recorder.close()
+    event = json.loads(path.read_text().splitlines()[0])
+    assert event["type"] == "llm" and event["cache_hit"] is True
@@ -0,0 +1,117 @@
+"""Provider fallback, completion options and context-window limits."""
+import sys
+import types
+
+import pytest
+
+from dbgcopilot.llm import anthropic, providers
+from dbgcopilot.llm.base import CompletionOptions, LLMError, ProviderTimeout, RateLimited
+
+
+def _fake_clients(monkeypatch, behaviour):
+    calls = []
+
+    def fake_create_client(name, session_config=None):
+        def ask(prompt):
+            calls.append(name)
+            outcome = behaviour[name]
+            if isinstance(outcome, Exception):
+                raise outcome
+            return outcome
+
+        return ask
+
+    monkeypatch.setattr(providers, "create_client", fake_create_client)
+    return calls
+
+
+def test_fallback_on_rate_limit_and_timeout(monkeypatch):
+    calls = _fake_clients(
+        monkeypatch,
+        {
+            "openrouter": RateLimited("HTTP 429"),
+            "anthropic": ProviderTimeout("timed out"),
+            "ollama-native": "local answer",
+        },
+    )
+    client = providers.create_fallback_client("openrouter", {"llm_fallback": "anthropic, ollama-native"})
+    assert client("why?") == "local answer"
+    assert calls == ["openrouter", "anthropic", "ollama-native"]
+    assert client.last_provider == "ollama-native"
+
+
+def test_fallback_stops_on_non_retryable_error(monkeypatch):
+    calls = _fake_clients(monkeypatch, {"openrouter": LLMError("HTTP 401", status=401), "anthropic": "unused"})
+    client = providers.create_fallback_client("openrouter", {"llm_fallback": "anthropic"})
+    with pytest.raises(LLMError, match="401"):
+        client("why?")
+    assert calls == ["openrouter"]
+
+
+def test_provider_from_env(monkeypatch):
+    monkeypatch.setenv(providers.PROVIDER_ENV_VAR, "ollama-native")
+    assert providers.resolve_provider_name({}) == "ollama-native"
+    assert providers.resolve_provider_name({"llm_provider": "anthropic"}) == "anthropic"
+    assert providers.resolve_provider_name({}, selected="mock-local") == "mock-local"
+
+
+def test_context_limits_are_exposed():
+    prov = providers.get_provider("anthropic")
+    assert prov.context_window == 200000
+    assert prov.max_output_tokens == 1024
+    assert providers.prompt_char_budget("ollama") < providers.prompt_char_budget("anthropic")
+
+
+def test_anthropic_request_shape(monkeypatch):
+    sent = {}
+
+    class _Resp:
+        status_code = 200
+        text = ""
+
+        def json(self):
+            return {"content": [{"type": "text", "text": "hi"}], "usage": {"input_tokens": 3, "output_tokens": 1}}
+
+    def post(url, headers=None, json=None, timeout=None):
+        sent.update(url=url, headers=headers, body=json, timeout=timeout)
+        return _Resp()
+
+    monkeypatch.setitem(sys.modules, "requests", types.SimpleNamespace(post=post))
+    monkeypatch.setenv("ANTHROPIC_API_KEY", "test-key")
+    prov = providers.get_provider("anthropic")
+    answer = prov.complete("stack dump", CompletionOptions(max_tokens=64, stop=["</cmd>"], timeout=5))
+    assert answer == "hi"
+    assert sent["url"].endswith("/v1/messages")
+    assert sent["headers"]["x-api-key"] == "test-key"
+    assert sent["body"]["max_tokens"] == 64
+    assert sent["body"]["stop_sequences"] == ["</cmd>"]
+    assert sent["timeout"] == 5
+
+
+def test_anthropic_overload_is_retryable(monkeypatch):
+    class _Resp:
+        status_code = 529
+        text = "overloaded"
+
+    monkeypatch.setitem(sys.modules, "requests", types.SimpleNamespace(post=lambda *a, **k: _Resp()))
+    monkeypatch.setenv("ANTHROPIC_API_KEY", "test-key")
+    with pytest.raises(RateLimited):
+        anthropic.create_provider(meta={"name": "anthropic"})("hello")
+
+
+def test_configuration_and_response_errors_are_llm_errors(monkeypatch):
+    class _Resp:
+        status_code = 200
+        text = "<html>"
+
+        def json(self):
+            raise ValueError("not JSON")
+
+    monkeypatch.setitem(sys.modules, "requests", types.SimpleNamespace(post=lambda *a, **k: _Resp()))
+    # dbgagent exits 3 (infrastructure) for an LLMError, not 1 as for a bug.
+    monkeypatch.delenv("ANTHROPIC_API_KEY", raising=False)
+    with pytest.raises(LLMError, match="API key not configured"):
+        anthropic.create_provider(meta={"name": "anthropic"})("hello")
+    monkeypatch.setenv("ANTHROPIC_API_KEY", "test-key")
+    with pytest.raises(LLMError, match="invalid JSON"):
+        anthropic.create_provider(meta={"name": "anthropic"})("hello")
@@ -0,0 +1,91 @@
+"""LLM retry policy, Retry-After handling and the client-side rate limiter."""
+from datetime import datetime, timezone
+
+import pytest
+
+from dbgcopilot.llm.base import LLMError, ServerError, http_error, parse_retry_after
+from dbgcopilot.llm.retry import RetryingClient, RetryPolicy, TokenBucket, limiter_for, with_retry
+
+
+class _Flaky:
+    def __init__(self, errors, answer="ok"):
+        self.errors = list(errors)
+        self.answer = answer
+        self.calls = 0
+        self.last_usage = {"total_tokens": 7}
+
+    def __call__(self, prompt):
+        self.calls += 1
+        if self.errors:
+            raise self.errors.pop(0)
+        return self.answer
+
+
+def test_retryable_errors_back_off_and_honour_retry_after():
+    waits = []
+    flaky = _Flaky([http_error("p", 503, "unavailable"), http_error("p", 429, "slow down", retry_after=4.0)])
+    client = RetryingClient(flaky, RetryPolicy(max_attempts=3, base_delay=1.0), sleep=waits.append, rng=lambda: 1.0)
+    assert client("q") == "ok"
+    assert flaky.calls == 3 and client.retries == 2
+    # Full jitter draw of 1.0 keeps the backoff at 1s, 2s; the server's 4s hint wins over 2s.
+    assert waits == [1.0, 4.0]
+    assert client.last_usage == {"total_tokens": 7}
+
+
+def test_non_retryable_errors_fail_fast_and_budget_is_bounded():
+    waits = []
+    bad_key = _Flaky([http_error("p", 401, "invalid api key")])
+    with pytest.raises(LLMError, match="invalid api key") as info:
+        RetryingClient(bad_key, RetryPolicy(max_attempts=5), sleep=waits.append)("q")
+    assert bad_key.calls == 1 and waits == [] and not info.value.retryable
+
+    down = _Flaky([ServerError("boom", status=500)] * 3)
+    with pytest.raises(ServerError, match=r"after 2 attempts"):
+        RetryingClient(down, RetryPolicy(max_attempts=2), sleep=waits.append, rng=lambda: 0.0)("q")
+    assert down.calls == 2 and waits == [0.5]
+
+    # A Retry-After beyond the patience budget is cut to it, and the call is still retried.
+    assert RetryPolicy(max_delay=10).delay(1, http_error("p", 429, "later", retry_after=120)) == 10
+    patient = _Flaky([http_error("p", 429, "later", retry_after=60)])
+    waits.clear()
+    assert RetryingClient(patient, RetryPolicy(), sleep=waits.append, rng=lambda: 0.0)("q") == "ok"
+    assert waits == [30.0]
+
+
+def test_policy_and_limits_come_from_config_or_environment(monkeypatch):
+    monkeypatch.setenv("DBGCOPILOT_LLM_RETRIES", "8")
+    policy = RetryPolicy.from_config({"llm_retry_max_delay": "120"})
+    assert (policy.max_attempts, policy.max_delay) == (8, 120.0)
+    assert RetryPolicy.from_config({"llm_retries": "1"}).max_attempts == 1
+    with pytest.raises(ValueError, match="llm_retries must be a number"):
+        RetryPolicy.from_config({"llm_retries": "many"})
+
+    monkeypatch.delenv("DBGCOPILOT_LLM_RETRIES")
+    plain = _Flaky([])
+    assert with_retry(plain, "p", {"llm_retries": "1"}) is plain
+    bucket = limiter_for("p", {"llm_rate_limit": "30", "llm_rate_burst": "2"})
+    assert bucket is limiter_for("p", {"llm_rate_limit": "30", "llm_rate_burst": "2"})
+    assert bucket.rate == 0.5 and bucket.burst == 2
+
+
+def test_token_bucket_spaces_requests():
+    now = [0.0]
+    waits = []
+
+    def sleep(seconds):
+        waits.append(seconds)
+        now[0] += seconds
+
+    bucket = TokenBucket(rate=2.0, burst=2, clock=lambda: now[0], sleep=sleep)
+    assert [bucket.acquire() for _ in range(4)] == [0.0, 0.0, 0.5, 0.5]
+    now[0] += 10
+    assert bucket.acquire() == 0.0
+
+
+def test_retry_after_header_formats():
+    assert parse_retry_after("7") == 7.0
+    now = datetime(2026, 1, 1, 12, 0, 0, tzinfo=timezone.utc)
+    assert parse_retry_after("Thu, 01 Jan 2026 12:00:30 GMT", now=now) == 30.0
+    assert parse_retry_after("soon") is None
+    assert http_error("p", 502, "bad gateway").retryable
+    assert not http_error("p", 400, "bad request").retryable
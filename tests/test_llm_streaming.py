@@ -0,0 +1,146 @@
+"""Streamed completions, cancellation and transcript assembly."""
+import json
+import sys
+import types
+
+from dbgcopilot.core.orchestrator import CopilotOrchestrator
+from dbgcopilot.core.state import SessionState
+from dbgcopilot.llm import providers
+from dbgcopilot.llm.streaming import TokenStream
+from dbgcopilot.session import Recorder, load_transcript
+
+
+class _StreamResp:
+    status_code = 200
+    text = ""
+
+    def __init__(self, lines):
+        self._lines = lines
+        self.closed = False
+
+    def iter_lines(self, decode_unicode=False):
+        return iter(self._lines)
+
+    def close(self):
+        self.closed = True
+
+
+def _sse(*deltas):
+    lines = [": keep-alive"]
+    for d in deltas:
+        lines.append("data: " + json.dumps({"choices": [{"delta": {"content": d}}]}))
+    lines.append("data: [DONE]")
+    return lines
+
+
+def test_openai_compatible_stream(monkeypatch):
+    resp = _StreamResp(_sse("The ", "lock ", "cycle"))
+    sent = {}
+
+    def post(url, headers=None, json=None, timeout=None, stream=False):
+        sent.update(body=json, stream=stream)
+        return resp
+
+    monkeypatch.setitem(sys.modules, "requests", types.SimpleNamespace(post=post))
+    stream = providers.get_provider("ollama").complete_stream("why?")
+    assert list(stream) == ["The ", "lock ", "cycle"]
+    assert stream.text == "The lock cycle"
+    assert sent["stream"] and sent["body"]["stream"] is True
+    assert resp.closed
+
+
+def test_stream_is_rate_limited_and_retried_until_its_first_chunk(monkeypatch):
+    from dbgcopilot.llm import retry
+
+    busy = _StreamResp([])
+    busy.status_code, busy.text = 503, "overloaded"
+    replies = [busy, _StreamResp(_sse("The ", "answer"))]
+    acquired = []
+
+    class Bucket:
+        # The bucket --llm-rate-limit shares with the blocking calls.
+        def acquire(self):
+            acquired.append("ollama")
+            return 0.0
+
+    monkeypatch.setitem(sys.modules, "requests", types.SimpleNamespace(post=lambda *a, **kw: replies.pop(0)))
+    monkeypatch.setattr(retry, "limiter_for", lambda name, config=None: Bucket())
+    config = {"llm_retries": "2", "llm_retry_delay": "0"}
+    stream = providers.get_provider("ollama").complete_stream("why?", session_config=config)
+    assert stream.collect() == "The answer" and acquired == ["ollama", "ollama"]
+    assert busy.closed and replies == []
+    assert stream.last_usage == {}
+
+
+def test_non_streaming_provider_keeps_stream_api():
+    stream = providers.get_provider("mock-local").complete_stream("hello")
+    assert isinstance(stream, TokenStream)
+    assert stream.collect().startswith("(mock)")
+
+
+def test_cancel_closes_generator():
+    closed = []
+
+    def gen():
+        try:
+            yield "a"
+            yield "b"
+        finally:
+            closed.append(True)
+
+    stream = TokenStream(gen())
+    assert next(stream) == "a"
+    stream.cancel()
+    assert list(stream) == []
+    assert stream.cancelled and closed == [True]
+    assert stream.text == "a"
+
+
+class _Backend:
+    name = "delve"
+    prompt = "(dlv) "
+
+    def run_command(self, cmd, timeout=None):
+        return ""
+
+
+def _orchestrator(monkeypatch, tmp_path, chunks):
+    def fake_stream(name, prompt, session_config=None):
+        def gen():
+            for c in chunks:
+                if c is KeyboardInterrupt:
+                    raise KeyboardInterrupt
+                yield c
+
+        return TokenStream(gen())
+
+    monkeypatch.setattr(providers, "create_stream", fake_stream)
+    shown = []
+    state = SessionState(session_id="t", colors_enabled=False, selected_provider="mock-local")
+    state.token_sink = shown.append
+    state.recorder = Recorder(str(tmp_path / "s.ndjson"))
+    return CopilotOrchestrator(_Backend(), state), state, shown
+
+
+def test_streamed_answer_is_recorded_whole(monkeypatch, tmp_path):
+    orch, state, shown = _orchestrator(monkeypatch, tmp_path, ["Check ", "the ", "<cmd>goroutines</cmd>"])
+    reply = orch.ask("what now?")
+    assert shown == ["Check ", "the ", "<cmd>goroutines</cmd>", "\n"]
+    # The explanation was already streamed; only the confirmation remains.
+    assert reply.startswith("Proposed debugger command:")
+    assert state.pending_command == "goroutines"
+    state.recorder.close()
+    (event,) = load_transcript(state.recorder.path).events
+    assert event["response"] == "Check the <cmd>goroutines</cmd>"
+
+
+def test_ctrl_c_stops_stream(monkeypatch, tmp_path):
+    orch, state, shown = _orchestrator(monkeypatch, tmp_path, ["Partial <cmd>cont", KeyboardInterrupt, "inue</cmd>"])
+    reply = orch.ask("what now?")
+    assert reply == "Response cancelled."
+    assert shown == ["Partial <cmd>cont", "\n"]
+    assert state.pending_command is None
+    state.recorder.close()
+    (event,) = load_transcript(state.recorder.path).events
+    assert event["response"] == "Partial <cmd>cont"
+    assert event["cancelled"] is True
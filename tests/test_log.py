@@ -0,0 +1,132 @@
+"""Structured logging: key=value lines on stderr and redacted debug traces of debugger and LLM traffic."""
+import io
+import logging
+import sys
+import threading
+
+from dbgcopilot.backends.delve_subprocess import DelveSubprocessBackend
+from dbgcopilot.llm import providers
+from dbgcopilot.utils import log
+
+FAKE_DLV = """\
+import sys
+import threading
+print("Type 'help' for list of commands.")
+while True:
+    sys.stdout.write("(dlv) ")
+    sys.stdout.flush()
+    cmd = sys.stdin.readline().strip()
+    if cmd == "exit":
+        sys.exit(0)
+    print("apiToken = \\"s3cr3t-value\\"" if cmd == "locals" else "unknown command")
+"""
+
+
+def test_key_value_lines_go_to_the_configured_stream_at_their_level():
+    out = io.StringIO()
+    root = logging.getLogger(log.LOGGER_NAME)
+    saved = (root.handlers[:], root.level, root.propagate)
+    try:
+        log.configure("info", stream=out)
+        logger = log.get_logger("debugger")
+        log.log(logger, logging.INFO, "session started", backend="delve", cmd="break main.go:12")
+        log.log(logger, logging.DEBUG, "not shown")
+        log.trace_llm("mock-local", "prompt", prompt="why?")
+        line = out.getvalue().strip()
+        assert "\n" not in line and line.startswith("time=")
+        assert line.endswith('level=INFO logger=debugger msg="session started" backend=delve cmd="break main.go:12"')
+    finally:
+        root.handlers, root.propagate = saved[0], saved[2]
+        root.setLevel(saved[1])
+    assert log.parse_level("DEBUG") == logging.DEBUG
+    try:
+        log.parse_level("verbose")
+    except ValueError as exc:
+        assert "debug, info, warning, error" in str(exc)
+    else:
+        raise AssertionError("verbose is not a level")
+
+
+def test_debug_traces_every_command_and_llm_exchange_redacted(tmp_path):
+    script = tmp_path / "dlv"
+    script.write_text(f"#!{sys.executable}\n" + FAKE_DLV)
+    script.chmod(0o755)
+    backend = DelveSubprocessBackend(program="./server", delve_path=str(script))
+    backend.initialize_session()
+    try:
+        with log.capture("debug") as records:
+            assert "s3cr3t-value" in backend.run_command("locals")
+            providers.get_provider("mock-local").complete("Is Authorization: Bearer abcdef123456 leaked?")
+        with log.capture("info") as quiet:
+            backend.run_command("locals")
+    finally:
+        backend.run_command("exit")
+
+    assert [(r.name, r.getMessage()) for r in records] == [
+        ("dbgcopilot.debugger", "debugger request"),
+        ("dbgcopilot.debugger", "debugger response"),
+        ("dbgcopilot.llm", "llm prompt"),
+        ("dbgcopilot.llm", "llm response"),
+    ]
+    request, response, prompt, answer = (log.attrs(r) for r in records)
+    assert request == {"backend": "delve", "cmd": "locals"}
+    assert response["output"] == "apiToken = <redacted:len=12>" and response["seconds"] >= 0
+    assert prompt["provider"] == "mock-local" and "abcdef123456" not in prompt["prompt"]
+    assert isinstance(answer["response"], str)
+    assert quiet == []
+
+
+class _FakeGdb:
+    """Answers every command at the next prompt, echoing it first as gdb's terminal does."""
+
+    timeout = 30
+
+    def __init__(self, replies):
+        self.replies, self.before = replies, ""
+
+    def sendline(self, cmd):
+        self.before = f"{cmd}\n{self.replies[cmd]}\n"
+
+    def expect(self, pattern, timeout=None):
+        return 0
+
+
+def test_each_thread_traces_with_its_own_redactor_and_gdb_is_traced_too():
+    from dbgcopilot.backends.gdb_subprocess import GdbSubprocessBackend
+    from dbgcopilot.utils.redact import Redactor
+
+    replies = {"print key": "$1 = alpha-key beta-key"}
+
+    def analyze(secret):
+        # A batch worker: its session's redactor knows its own target's secret only.
+        log.set_redactor(Redactor(known={secret: "name heuristic"}))
+        backend = GdbSubprocessBackend()
+        backend.child = _FakeGdb(replies)
+        backend.run_command("print key")
+
+    with log.capture("debug") as records:
+        workers = [threading.Thread(target=analyze, args=(s,), name=s) for s in ("alpha-key", "beta-key")]
+        for worker in workers:
+            worker.start()
+        for worker in workers:
+            worker.join()
+
+    for secret, other in (("alpha-key", "beta-key"), ("beta-key", "alpha-key")):
+        request, response = (log.attrs(r) for r in records if r.threadName == secret)
+        assert request == {"backend": "gdb", "cmd": "print key"}
+        assert secret not in response["output"] and other in response["output"]
+
+
+def test_radare2_commands_are_traced_with_their_cleaned_output():
+    from dbgcopilot.backends.radare2_subprocess import Radare2SubprocessBackend
+
+    class _Pipe:
+        def cmd(self, command):
+            return "\r\n0x00401000  push rbp\r\n\n"
+
+    backend = Radare2SubprocessBackend("/bin/true")
+    backend._r2 = _Pipe()
+    with log.capture("debug") as records:
+        assert backend._execute("pd 1") == "0x00401000  push rbp"
+    request, response = (log.attrs(r) for r in records)
+    assert request == {"backend": "radare2", "cmd": "pd 1"} and response["output"] == "0x00401000  push rbp"
@@ -0,0 +1,75 @@
+"""Repeated breakpoint hits in a loop are summarized per location, and only the summary reaches the LLM."""
+from dbgcopilot.debugger.loops import HitAggregator
+from dbgcopilot.debugger.mock import MockDebugger
+from dbgcopilot.llm.tools import ToolCall
+from dbgcopilot.session.tools import debugger_tools
+
+
+def _hit(line, function, goroutine, **variables):
+    return {
+        "stop": {
+            "reason": "breakpoint",
+            "breakpoint_id": 1 if line == 12 else 2,
+            "goroutine_id": goroutine,
+            "frame": {"function": function, "file": "/src/sum.go", "line": line},
+        },
+        "scopes": [{"goroutine": goroutine, "frame": 0, "variables": variables}],
+    }
+
+
+def _loop_script():
+    # 500 iterations of main.sum's loop, a cleanup hit twice, then another pass over the loop after a pause.
+    steps = [_hit(12, "main.sum", 1, i=str(i), total=str(i * (i - 1) // 2), state='"busy"') for i in range(500)]
+    steps += [_hit(20, "main.flush", 1, n=str(n), buf='"abc"') for n in (3, 0)]
+    steps += [_hit(12, "main.sum", 1, i=str(i), state='"done"' if i == 4 else '"busy"') for i in range(5)]
+    steps.append({"stop": {"reason": "exited", "exit_code": 0}})
+    return {"debugger": "delve", "steps": steps}
+
+
+def _clock():
+    # A hit every millisecond; the second pass over the loop comes a minute later.
+    times = [i / 1000 for i in range(502)] + [60 + i / 1000 for i in range(5)]
+    return iter(times).__next__
+
+
+def test_loop_hits_are_summarized_per_burst_and_short_bursts_listed():
+    aggregator = HitAggregator(MockDebugger(_loop_script()), threshold=3, clock=_clock())
+    final = aggregator.run()
+    assert final.exited and aggregator.hits == 507
+    assert [(b.location, b.count, b.summarized) for b in aggregator.bursts] == [
+        ("/src/sum.go:12", 500, True),
+        ("/src/sum.go:20", 2, False),
+        ("/src/sum.go:12", 5, True),
+    ]
+
+    summary = aggregator.describe()
+    assert summary.splitlines()[0] == (
+        "Breakpoint hits: 507 at 2 location(s) in 3 burst(s); bursts of more than 3 hits are summarized."
+    )
+    assert "- main.sum /src/sum.go:12 (breakpoint 1): 500 hits by goroutine(s) 1 over 0.5s" in summary
+    assert '    first: i=0, total=0, state="busy"' in summary
+    assert "    i in [0, 499], first 0, last 499" in summary
+    assert "    total in [0, 124251], first 0, last 124251" in summary
+    assert '    state = "busy" (unchanged)' in summary
+    # Within the threshold each hit reads as continue would have reported it.
+    assert '- goroutine 1 hit breakpoint 2 at main.flush /src/sum.go:20; n=3, buf="abc"' in summary
+    assert '    state took "busy", "done"; last "done"' in summary
+    assert summary.endswith("Then: Process exited with status 0")
+    # Nothing of the individual loop iterations beyond the summary.
+    assert "i=250" not in summary and len(summary.splitlines()) < 20
+
+
+def test_continue_tool_aggregates_until_the_hit_limit_with_tracked_expressions():
+    steps = [_hit(12, "main.sum", 1, i=str(i)) for i in range(50)] + [{"stop": {"reason": "exited", "exit_code": 0}}]
+    script = {"debugger": "delve", "steps": steps}
+    dbg = MockDebugger(script)
+    registry = debugger_tools(dbg)
+
+    reply = registry.invoke(ToolCall("c1", "continue", {"aggregate": True, "threshold": 10, "expressions": ["i"]}))
+    assert "50 hits by goroutine(s) 1" in reply.content and "i in [0, 49], first 0, last 49" in reply.content
+    assert ("eval_in_frame", 1, 0, "i") in dbg.calls and not any(c[0] == "frame_locals" for c in dbg.calls)
+    assert reply.content.endswith("Then: Process exited with status 0")
+
+    capped = HitAggregator(MockDebugger(script), threshold=10)
+    assert capped.run(max_hits=20).reason == "breakpoint" and capped.hits == 20
+    assert capped.describe().endswith("Stopped after 20 hits (the limit); the program is still at the last one.")
@@ -0,0 +1,50 @@
+"""The scripted mock debugger drives the analysis layer through the hang example without dlv."""
+from pathlib import Path
+
+import pytest
+
+from dbgcopilot.analyze import detect_deadlock, lock_order_fix, report_sections
+from dbgcopilot.debugger import BreakpointSpec, DebuggerError, ProcessExitedError, place_breakpoints
+from dbgcopilot.debugger.mock import MockDebugger
+from dbgcopilot.debugger.pretty import pretty_print
+
+HANG_SCRIPT = Path(__file__).resolve().parent / "testdata" / "hang_deadlock.json"
+
+
+def test_scripted_stops_variables_and_breakpoints():
+    dbg = MockDebugger.load(HANG_SCRIPT)
+    assert dbg.name == "delve" and dbg.stop().reason == "stopped"
+    placed = place_breakpoints(dbg, BreakpointSpec.parse("-r main.worker.*"))
+    assert [(bp.function, bp.line) for bp in placed] == [("main.workerOne", 18), ("main.workerTwo", 30)]
+
+    stop = dbg.continue_()
+    assert (stop.reason, stop.goroutine_id, stop.frame.function) == ("breakpoint", 6, "main.workerOne")
+    assert stop.frame.file.endswith("examples/hang/go/hang.go")
+    assert pretty_print(dbg.read_variable("lockA")) == "sync.Mutex {state: 1, sema: 0}"
+    assert dbg.eval_in_frame(7, 1, "wg").type == "*sync.WaitGroup"
+    with pytest.raises(DebuggerError, match="could not find symbol value for i"):
+        dbg.eval_in_frame(7, 1, "i")
+    # Source-reconstructed ownership while both workers sleep between their Lock calls.
+    dump = dbg.goroutines()
+    assert [h.lock.name for h in dump.get(6).held_locks()] == ["lockA"]
+    assert [h.lock.name for h in dump.get(7).held_locks()] == ["lockB"]
+    assert detect_deadlock(dump) == []
+    assert ("read_variable", "lockA") in dbg.calls
+
+
+def test_hang_dump_reports_the_lock_order_inversion():
+    dbg = MockDebugger.load(HANG_SCRIPT)
+    dbg.continue_()
+    stop = dbg.continue_()
+    assert (stop.reason, stop.detail) == ("fatal", "all goroutines are asleep - deadlock!")
+
+    cycles = detect_deadlock(dbg.goroutines())
+    assert len(cycles) == 1 and sorted(cycles[0].goroutine_ids) == [6, 7]
+    assert [lock.name for lock in cycles[0].global_order()] == ["lockA", "lockB"]
+    assert lock_order_fix(cycles[0])[0] == "Acquire lockA before lockB everywhere"
+    # The raw dump the analyzers see through run_command reads back the same way.
+    sections = report_sections(dbg.run_command(dbg.GOROUTINES_COMMAND))
+    assert sections[0].startswith("Detected 1 lock cycle(s):")
+
+    with pytest.raises(ProcessExitedError):
+        dbg.continue_()
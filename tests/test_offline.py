@@ -0,0 +1,94 @@
+"""Offline reports (--no-llm): templated diagnoses from the analyzers, parsed like an LLM's report."""
+from pathlib import Path
+import json
+
+from dbgcopilot.analyze.offline import OFFLINE_NOTE, offline_report
+from dbgcopilot.analyze.result import build_result, render_json, render_text
+
+HANG_SRC = Path(__file__).resolve().parents[1] / "examples" / "hang" / "go" / "hang.go"
+
+NIL_TRACE = """\
+panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4915b6]
+
+goroutine 1 [running]:
+main.boom()
+\t/src/crash/crash.go:8 +0x16
+main.main()
+\t/src/crash/crash.go:13 +0x4f
+exit status 2
+"""
+
+LOCK_CYCLE = """\
+fatal error: all goroutines are asleep - deadlock!
+
+goroutine 1 [sync.WaitGroup.Wait]:
+sync.runtime_SemacquireWaitGroup(0x1b28c442e080?, 0xe0?)
+\t/usr/local/go/src/runtime/sema.go:114 +0x2e
+sync.(*WaitGroup).Wait(0x1b28c442c130)
+\t/usr/local/go/src/sync/waitgroup.go:206 +0x85
+main.main()
+\t{hang}:45 +0x10f
+
+goroutine 6 [sync.Mutex.Lock]:
+internal/sync.runtime_SemacquireMutex(0x1b28c441c038?, 0xa0?, 0x14?)
+\t/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x594220)
+\t/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/sync/mutex.go:46
+main.workerOne(0x0?)
+\t{hang}:20 +0x106
+created by main.main in goroutine 1
+\t{hang}:43 +0xba
+
+goroutine 7 [sync.Mutex.Lock]:
+internal/sync.runtime_SemacquireMutex(0x1b28c441c038?, 0xa0?, 0x14?)
+\t/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x594218)
+\t/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/sync/mutex.go:46
+main.workerTwo(0x0?)
+\t{hang}:32 +0x106
+created by main.main in goroutine 1
+\t{hang}:44 +0x105
+""".replace("{hang}", str(HANG_SRC))
+
+
+def test_panic_report_names_the_failing_frame_and_the_fix():
+    outputs = ["continue", NIL_TRACE]
+    report = offline_report(outputs)
+    assert report.splitlines()[:3] == [
+        "Analysis Summary:",
+        "- nil pointer dereference: runtime error: invalid memory address or nil pointer dereference",
+        f"- {OFFLINE_NOTE}",
+    ]
+
+    result = build_result(outputs, report=report, debugger="delve", program="crash")
+    assert result.issue_type == "panic" and result.severity == "critical"
+    assert result.diagnosis.startswith("The program panics on a nil pointer dereference in main.boom (")
+    assert result.suggested_fixes[0].summary == "Check for nil before use"
+    assert "reaches main.boom" in result.suggested_fixes[0].details
+    assert result.next_steps[-1] == "Rerun without --no-llm where an LLM is reachable for a fuller explanation."
+    assert result.confidence_level == "high"
+    # Same document shape as an LLM-backed run.
+    llm_keys = json.loads(render_json(build_result(outputs, report="## Analysis Summary\n- x")))
+    assert json.loads(render_json(result)).keys() == llm_keys.keys()
+
+
+def test_lock_cycle_keeps_the_analyzer_fix_and_nothing_found_says_so():
+    result = build_result([LOCK_CYCLE], report=offline_report([LOCK_CYCLE]))
+    assert result.issue_type == "deadlock"
+    assert result.diagnosis.startswith("Deadlock from a lock-order inversion: goroutines 6, 7 each hold a lock")
+    # The lock order lock_order_fix derived, not the generic template.
+    assert [f.summary for f in result.suggested_fixes] == ["Acquire lockA before lockB everywhere"]
+    assert "Acquire the locks in one global order" not in render_text(result)
+
+    quiet = offline_report(["(dlv) exited with status 0"])
+    assert "found no panic, deadlock, crash or goroutine leak" in quiet
+    assert "Suggested Fixes:" not in quiet
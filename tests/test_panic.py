@@ -0,0 +1,90 @@
+"""Panic classifier tests driven by runtime traces of the example programs."""
+from pathlib import Path
+
+from dbgcopilot.analyze import classify_panic, findings_for_output
+from dbgcopilot.analyze import panic
+
+CRASH_SRC = Path(__file__).resolve().parents[1] / "examples" / "crash" / "go" / "crash.go"
+
+NIL_TRACE = """\
+Go crash demo starting...
+About to dereference a nil pointer...
+panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4915b6]
+
+goroutine 1 [running]:
+main.boom()
+\t{crash}:8 +0x16
+main.main()
+\t{crash}:13 +0x4f
+exit status 2
+""".replace("{crash}", str(CRASH_SRC))
+
+MAP_TRACE = """\
+fatal error: concurrent map writes
+
+goroutine 7 [running]:
+internal/runtime/maps.fatal({0x4b0c7a?, 0x0?})
+\t/usr/local/go/src/runtime/panic.go:1058 +0x18
+runtime.mapassign_faststr(0x4a2e20, 0xc000090030, {0x4b0a15, 0x3})
+\t/usr/local/go/src/internal/runtime/maps/runtime_faststr_swiss.go:263 +0x2ad
+main.writer(0xc000090030, 0x0?)
+\t/src/race/main.go:12 +0x45
+created by main.main in goroutine 1
+\t/src/race/main.go:20 +0x5c
+
+goroutine 1 [sleep]:
+time.Sleep(0x3b9aca00)
+\t/usr/local/go/src/runtime/time.go:338 +0x165
+main.main()
+\t/src/race/main.go:23 +0x85
+
+goroutine 8 [runnable]:
+runtime.mapassign_faststr(0x4a2e20, 0xc000090030, {0x4b0a15, 0x3})
+\t/usr/local/go/src/internal/runtime/maps/runtime_faststr_swiss.go:216 +0x9c
+main.writer(0xc000090030, 0x1?)
+\t/src/race/main.go:12 +0x45
+created by main.main in goroutine 1
+\t/src/race/main.go:20 +0x5c
+"""
+
+
+def test_nil_deref_points_at_user_frame():
+    report = classify_panic(NIL_TRACE)
+    assert report.kind == panic.PANIC_NIL_DEREF
+    assert report.goroutine_id == 1
+    assert report.signal.startswith("SIGSEGV")
+    assert report.culprit.function == "main.boom"
+    assert report.culprit.line == 8
+    assert (8, "\tfmt.Println(*ptr)") in report.source.numbered()
+    assert not report.multi_goroutine
+    text = findings_for_output(NIL_TRACE)
+    assert "likely culprit: main.boom" in text
+    assert ">  8 | \tfmt.Println(*ptr)" in text
+
+
+def test_concurrent_map_writes_lists_participants():
+    report = classify_panic(MAP_TRACE)
+    assert report.kind == panic.PANIC_CONCURRENT_MAP
+    assert report.multi_goroutine
+    assert report.goroutine_id == 7
+    assert report.culprit.function == "main.writer"
+    assert [g.id for g in report.participants] == [7, 8]
+    assert not report.source.available  # sources not on disk
+
+
+def test_other_panic_classes():
+    index = classify_panic(
+        "panic: runtime error: index out of range [5] with length 3\n\n"
+        "goroutine 1 [running]:\nmain.main()\n\t/x/main.go:6 +0x1d\n"
+    )
+    assert index.kind == panic.PANIC_INDEX
+    worker = classify_panic(
+        "panic: worker failed\n\ngoroutine 6 [running]:\nmain.work()\n\t/x/main.go:9 +0x25\n"
+        "created by main.main in goroutine 1\n\t/x/main.go:14 +0x1a\n"
+    )
+    assert worker.kind == panic.PANIC_UNRECOVERED
+    assert "started by main.main" in worker.describe()
+    overflow = classify_panic("runtime: goroutine stack exceeds 1000000000-byte limit\nfatal error: stack overflow\n")
+    assert overflow.kind == panic.PANIC_FATAL
+    assert overflow.goroutine is None
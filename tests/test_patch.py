@@ -0,0 +1,173 @@
+"""Suggested patches: the prompt shows the frames' source, and only diffs that apply to it are accepted."""
+from pathlib import Path
+import shutil
+import types
+
+from dbgagent.runner import AgentRequest, DebugAgentRunner
+from dbgcopilot.analyze.patch import PatchError, apply_patch, check_patch, patch_prompt, patch_sources
+from dbgcopilot.analyze.result import build_result, render_markdown, render_text
+from dbgcopilot.llm.base import ServerError
+
+HANG_SRC = Path(__file__).resolve().parents[1] / "examples" / "hang" / "go" / "hang.go"
+
+LOCK_CYCLE = """\
+goroutine 6 [sync.Mutex.Lock]:
+internal/sync.(*Mutex).lockSlow(0x594220)
+\t/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/sync/mutex.go:46
+main.workerOne(0x0?)
+\t{hang}:20 +0x106
+created by main.main in goroutine 1
+\t{hang}:43 +0xba
+
+goroutine 7 [sync.Mutex.Lock]:
+internal/sync.(*Mutex).lockSlow(0x594218)
+\t/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+sync.(*Mutex).Lock(...)
+\t/usr/local/go/src/sync/mutex.go:46
+main.workerTwo(0x0?)
+\t{hang}:32 +0x106
+created by main.main in goroutine 1
+\t{hang}:44 +0x105
+"""
+
+# Takes the locks in workerOne's order; the header is two lines off and miscounts the hunk.
+REORDER = """\
+Both workers should take lockA first:
+
+```diff
+--- a/{hang}
++++ b/{hang}
+@@ -30,5 +30,5 @@ func workerTwo(wg *sync.WaitGroup) {
+ \tfmt.Println("workerTwo locking B")
+-\tlockB.Lock()
++\tlockA.Lock()
+ \ttime.Sleep(500 * time.Millisecond)
+ \tfmt.Println("workerTwo locking A")
+-\tlockA.Lock()
++\tlockB.Lock()
+```
+"""
+
+
+def _lock_cycle(tmp_path):
+    hang = tmp_path / "hang.go"
+    shutil.copy(HANG_SRC, hang)
+    return hang, build_result([LOCK_CYCLE.replace("{hang}", str(hang))])
+
+
+def test_prompt_shows_the_frames_source_and_a_drifted_diff_is_normalized_and_applied(tmp_path):
+    hang, result = _lock_cycle(tmp_path)
+    sources = patch_sources(result)
+    # Both workers are in one file, shown once with one merged range.
+    assert [(s.local, s.ranges) for s in sources] == [(str(hang), [(5, 46)])]
+    prompt = patch_prompt(result, sources)
+    assert f"File: {hang}" in prompt
+    assert '29 | \tlockB.Lock()' in prompt
+    assert "- Acquire lockA before lockB everywhere" in prompt
+
+    patch = check_patch(REORDER.replace("{hang}", str(hang)), sources)
+    assert patch.describe() == f"1 file(s) changed ({hang}): +2 -2"
+    # Regenerated with the real line numbers, so it applies as printed.
+    assert "@@ -26,10 +26,10 @@" in patch.diff and "-\tlockB.Lock()\n+\tlockA.Lock()\n" in patch.diff
+    result.patch = patch.diff
+    assert "Suggested patch:\n  --- " in render_text(result)
+    assert "```diff\n--- " in render_markdown(result)
+
+    assert hang.read_text() == HANG_SRC.read_text()
+    assert apply_patch(patch) == [str(hang)]
+    lines = hang.read_text().splitlines()
+    assert (lines[28], lines[31]) == ("\tlockA.Lock()", "\tlockB.Lock()")
+    assert hang.read_text().endswith("}\n")
+
+
+def test_diffs_that_do_not_apply_are_rejected_with_the_reason(tmp_path):
+    hang, result = _lock_cycle(tmp_path)
+    sources = patch_sources(result)
+
+    def rejected(answer):
+        try:
+            check_patch(answer, sources)
+        except PatchError as exc:
+            return str(exc)
+        raise AssertionError("the diff was accepted")
+
+    misquoted = REORDER.replace("{hang}", str(hang)).replace("-\tlockB.Lock()", "-\tlockB.Lock() // B")
+    error = rejected(misquoted)
+    assert error == (
+        f"{hang}: hunk 1 (@@ -30 @@) does not apply: "
+        "line 29 is '\\tlockB.Lock()', the hunk expects '\\tlockB.Lock() // B'"
+    )
+    assert rejected(REORDER.replace("{hang}", "other/main.go")).startswith("b/other/main.go is not one of the files")
+    assert rejected("Acquire lockA first in workerTwo.").startswith("the answer has no unified diff")
+
+    # The retry shows the model its diff and what was wrong with it.
+    retry = patch_prompt(result, sources, previous=misquoted, error=error)
+    assert "Your previous diff was rejected:\n" + error in retry
+    assert retry.endswith("Reply with a corrected diff against the source above.")
+
+
+class _Runner(DebugAgentRunner):
+    """Analyzes the lock cycle without a debugger, then asks ``answers`` (in order) for the patch."""
+
+    def __init__(self, request, answers):
+        super().__init__(request)
+        self.answers = list(answers)
+        self.prompts = []
+
+    def _create_backend(self):
+        return types.SimpleNamespace(name="delve", run_command=lambda cmd, timeout=None: "")
+
+    def _prepare_debugger(self):
+        pass
+
+    def _auto_loop(self):
+        self.state.outputs.append(self.output)
+        return "Final report: workerOne and workerTwo take lockA and lockB in opposite orders."
+
+    def _get_provider_fn(self, provider):
+        def ask(prompt):
+            self.prompts.append(prompt)
+            answer = self.answers.pop(0)
+            if isinstance(answer, Exception):
+                raise answer
+            return answer
+
+        return ask
+
+
+def _patch_runner(tmp_path, hang, answers):
+    fields = dict.fromkeys(["model", "api_key", "classpath", "sourcepath", "main_class", "corefile", "log_path"])
+    request = AgentRequest(
+        debugger="delve", provider="ollama", program="./hang", goal_type="hang", goal_text="",
+        resume_context=None, max_steps=5, language="en", log_enabled=False,
+        report_path=tmp_path / "report.md", suggest_patch=True, patch_attempts=2, **fields,
+    )
+    runner = _Runner(request, answers)
+    runner.output = LOCK_CYCLE.replace("{hang}", str(hang))
+    return runner
+
+
+def test_runner_retries_a_rejected_patch_then_accepts_one_that_applies(tmp_path):
+    hang, _ = _lock_cycle(tmp_path)
+    runner = _patch_runner(tmp_path, hang, ["Swap the two Lock calls.", REORDER.replace("{hang}", str(hang))])
+    runner.run()
+    assert len(runner.prompts) == 2 and "Swap the two Lock calls." in runner.prompts[1]
+    assert runner.patch is not None and runner.patch_error == "" and runner.result.patch == runner.patch.diff
+    report = (tmp_path / "report.md").read_text()
+    assert "## Suggested Patch\nNot applied.\n\n```diff\n--- " in report
+    assert (tmp_path / "report.patch").read_text() == runner.patch.diff
+    # Suggested only: the source is untouched.
+    assert hang.read_text() == HANG_SRC.read_text()
+
+
+def test_a_failed_patch_request_keeps_the_analysis_and_its_report(tmp_path):
+    hang, _ = _lock_cycle(tmp_path)
+    runner = _patch_runner(tmp_path, hang, [ServerError("ollama HTTP 503: overloaded", provider="ollama", status=503)])
+    assert runner.run().startswith("Final report: workerOne")
+    assert runner.patch is None
+    assert runner.patch_error == "No patch: the LLM request for attempt 1 failed: ollama HTTP 503: overloaded"
+    report = (tmp_path / "report.md").read_text()
+    assert "## Final Report\nFinal report: workerOne" in report
+    assert f"## Suggested Patch\n{runner.patch_error}" in report
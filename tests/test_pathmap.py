@@ -0,0 +1,57 @@
+"""Source path remapping for binaries built on another machine."""
+from pathlib import Path
+
+import pytest
+
+from dbgcopilot.analyze import findings_for_output
+from dbgcopilot.debugger import BreakpointSpec, DebuggerError, delve
+from dbgcopilot.utils.pathmap import PathMap, set_path_map
+
+EXAMPLES = Path(__file__).resolve().parents[1] / "examples"
+
+
+def test_longest_prefix_wins_on_whole_components():
+    pm = PathMap.parse(["/build=/srv/all", "/build/src=/home/me/proj", "/build/src/vendor=/home/me/vendor"])
+    assert pm.to_local("/build/src/pkg/x.go") == "/home/me/proj/pkg/x.go"
+    assert pm.to_local("/build/src/vendor/lib/y.go") == "/home/me/vendor/lib/y.go"
+    assert pm.to_local("/build/srcgen/z.go") == "/srv/all/srcgen/z.go"
+    assert pm.to_local("/usr/local/go/src/runtime/proc.go") == "/usr/local/go/src/runtime/proc.go"
+    assert pm.to_target("/home/me/proj/pkg/x.go") == "/build/src/pkg/x.go"
+    assert pm.location_to_target("/home/me/proj/pkg/x.go:42") == "/build/src/pkg/x.go:42"
+    assert pm.location_to_target("main.worker") == "main.worker"
+    with pytest.raises(ValueError, match="<build prefix>=<local prefix>"):
+        PathMap.parse(["/build/src"])
+
+
+def test_trimpath_module_paths_resolve_in_the_module_cache(monkeypatch):
+    monkeypatch.setenv("GOMODCACHE", "/home/me/go/pkg/mod")
+    pm = PathMap.parse(["example.com/app=/home/me/app", "*=$GOMODCACHE"])
+    assert pm.to_local("example.com/app/cmd/main.go") == "/home/me/app/cmd/main.go"
+    local = pm.to_local("github.com/BurntSushi/toml@v1.3.2/decode.go")
+    assert local == "/home/me/go/pkg/mod/github.com/!burnt!sushi/toml@v1.3.2/decode.go"
+    assert pm.to_target(local) == "github.com/BurntSushi/toml@v1.3.2/decode.go"
+    only_x = PathMap.parse(["golang.org/x=$GOMODCACHE"])
+    errgroup = "golang.org/x/sync@v0.6.0/errgroup/errgroup.go"
+    assert only_x.to_local(errgroup) == f"/home/me/go/pkg/mod/{errgroup}"
+    assert only_x.to_local("github.com/a/b@v1.0.0/b.go") == "github.com/a/b@v1.0.0/b.go"
+
+
+def test_breakpoints_and_source_context_use_the_mapping():
+    hang = EXAMPLES / "hang" / "go"
+    set_path_map(PathMap.parse([f"/build/src={hang}"]))
+    try:
+        replies = {"break": "Breakpoint 1 set at 0x49a3c5 for main.workerOne() /build/src/hang.go:20\n"}
+        dbg = delve.DelveDebugger(program=str(hang / "hang.go"))
+        sent = []
+        dbg.run_command = lambda cmd, timeout=None: sent.append(cmd) or replies.get(cmd.split()[0], "")
+        bp = dbg.set_breakpoint(BreakpointSpec(location=f"{hang}/hang.go:20", condition="wg != nil"))
+        assert sent[:2] == ["break /build/src/hang.go:20", "condition 1 wg != nil"]
+        assert bp.file == f"{hang}/hang.go"
+        # The scope check reads the source through the mapping, so unknown names are still caught.
+        with pytest.raises(DebuggerError, match="references i, not in scope"):
+            dbg.set_breakpoint(BreakpointSpec(location=f"{hang}/hang.go:20", condition="i > 1000"))
+
+        trace = "goroutine 1 [running]:\nmain.workerOne()\n\t/build/src/hang.go:20 +0x1d\n"
+        assert "> 20 |" in findings_for_output(trace)
+    finally:
+        set_path_map(None)
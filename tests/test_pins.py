@@ -0,0 +1,101 @@
+"""Pinned goroutines: selected by id or stack text and shown in full with deep-loaded locals."""
+from dbgcopilot.analyze import parse_goroutine_dump
+from dbgcopilot.analyze.pins import PINS_KEY, Pin, format_pinned, pinned_goroutines, pins_from_config
+from dbgcopilot.debugger.base import DEEP_LOAD, Variable
+from dbgcopilot.prompts.pinned import pinned_section
+from dbgcopilot.session.interactive import Interactive
+
+DUMP = """\
+goroutine 1 [IO wait]:
+main.main()
+\t/src/api/main.go:40 +0x1a
+
+goroutine 19 [chan receive, 3 minutes]:
+runtime.gopark(0x0)
+\t/usr/local/go/src/runtime/proc.go:398 +0xce
+main.(*Server).waitResult(0xc000010000)
+\t/src/api/server.go:88 +0x45
+created by main.(*Server).handle in goroutine 1
+\t/src/api/server.go:61 +0x8f
+
+goroutine 23 [select]:
+main.(*pool).run(0xc000020000)
+\t/src/api/pool.go:24 +0x2d
+created by main.(*pool).grow in goroutine 1
+\t/src/api/pool.go:17 +0x4f
+"""
+
+
+class FakeDebugger:
+    name = "delve"
+
+    def __init__(self):
+        self.loads = []
+
+    def goroutines(self):
+        return parse_goroutine_dump(DUMP)
+
+    def frame_locals(self, goroutine_id, frame, cfg):
+        self.loads.append((goroutine_id, frame, cfg))
+        return [Variable(name="req", value='main.Request {ID: 7, Path: "/orders"}')]
+
+
+def test_pins_select_goroutines_by_id_or_stack_text():
+    assert Pin.parse(" 19 ") == Pin(goroutine_id=19)
+    assert Pin.parse("pool.go:24") == Pin(text="pool.go:24")
+    for bad in ("", "a,b"):
+        try:
+            Pin.parse(bad)
+        except ValueError:
+            pass
+        else:
+            raise AssertionError(f"{bad!r} is not a pin")
+
+    dump = parse_goroutine_dump(DUMP)
+    pins = pins_from_config({PINS_KEY: "19,pool.go:24,missing"})
+    pinned = pinned_goroutines(dump, pins)
+    # The id pin skips runtime.gopark for the top user frame.
+    assert [(p.goroutine.id, p.frame) for p in pinned] == [(19, 1), (23, 0)]
+
+    text = format_pinned(dump, pinned, pins, locals_text={19: "req = 7"})
+    assert text.splitlines()[:2] == [
+        "Pinned goroutines (full detail; never trimmed):",
+        "goroutine 19 [chan receive, 3 minutes]:  (pinned: goroutine 19)",
+    ]
+    assert "  runtime.gopark(0x0)\n      /usr/local/go/src/runtime/proc.go:398" in text
+    assert "  Locals of frame 1 (main.(*Server).waitResult), loaded in depth:\n    req = 7" in text
+    assert text.endswith("Pin stack contains 'missing' matched no goroutine in the latest dump.")
+
+
+def test_interactive_pin_command_puts_deep_loaded_goroutine_in_every_prompt():
+    debugger = FakeDebugger()
+    chat = Interactive(debugger, lambda prompt: "done")
+    assert "Pinned goroutines" not in chat.build_prompt()
+
+    assert chat.pin_command("pin 19") == "Pinned: goroutine 19"
+    assert chat.pin_command("why is it stuck?") is None
+    prompt = chat.build_prompt()
+    assert "goroutine 19 [chan receive, 3 minutes]:  (pinned: goroutine 19)" in prompt
+    assert 'req = main.Request {ID: 7, Path: "/orders"}' in prompt
+    assert debugger.loads[-1] == (19, 1, DEEP_LOAD)
+    assert "(pinned: goroutine 19)" in chat.build_messages()[0]["content"]
+
+    assert chat.pin_command("unpin 5") == "Not pinned: goroutine 5"
+    assert chat.pin_command("unpin all") == "No pinned goroutines."
+    assert pinned_section(chat.config, debugger=debugger) == ""
+
+
+def test_only_a_pin_command_is_taken_for_one_and_other_lines_starting_with_pin_are_asked():
+    asked = []
+    chat = Interactive(FakeDebugger(), lambda prompt: asked.append(prompt) or "It waits on a channel.")
+    assert chat.pin_command('pin "waitResult"') == "Pinned: stack contains 'waitResult'"
+    both = "Pinned: stack contains 'waitResult', stack contains 'main.(*pool).run'"
+    assert chat.pin_command("/pin main.(*pool).run") == both
+    assert chat.pin_command('unpin "waitResult"') == "Pinned: stack contains 'main.(*pool).run'"
+    assert chat.pin_command("pin down why goroutine 7 blocks") is None
+
+    lines = iter(["pin down why goroutine 7 blocks", "exit"])
+    out = []
+    chat.run(read=lambda prompt: next(lines), write=out.append)
+    assert len(asked) == 1 and "pin down why goroutine 7 blocks" in asked[0]
+    assert "It waits on a channel." in out and not any(line.startswith("Pinned:") for line in out)
@@ -0,0 +1,71 @@
+"""Pretty-printing of debugger values, including cycles, channels and truncated loads."""
+import pytest
+
+from dbgcopilot.debugger import DebuggerError, LoadConfig
+from dbgcopilot.debugger.pretty import format_value_text, pretty_print, variable_from_rpc
+
+# reflect.Kind numbers as sent by Delve.
+INT, CHAN, FUNC, PTR, SLICE, STRING, STRUCT = 2, 18, 19, 22, 23, 24, 25
+
+
+def _node(addr, val, next_ptr):
+    return {
+        "name": "",
+        "type": "main.Node",
+        "kind": STRUCT,
+        "addr": addr,
+        "children": [
+            {"name": "Val", "type": "int", "kind": INT, "value": str(val)},
+            next_ptr,
+        ],
+    }
+
+
+def _ptr(target):
+    return {"name": "Next", "type": "*main.Node", "kind": PTR, "children": [target]}
+
+
+def test_cyclic_list_terminates_with_back_reference():
+    # a -> b -> a: Delve loads the repeated node again as long as MaxVariableRecurse allows.
+    again = _node(0xC000010000, 1, _ptr({"type": "main.Node", "kind": STRUCT, "addr": 0xC000010010}))
+    b = _node(0xC000010010, 2, _ptr(again))
+    a = _node(0xC000010000, 1, _ptr(b))
+    root = {"name": "head", "type": "*main.Node", "kind": PTR, "children": [a]}
+    text = pretty_print(variable_from_rpc(root))
+    assert text.startswith("&main.Node {")
+    assert "Val: 2" in text
+    assert "(*main.Node)(0xc000010000) <cycle>" in text
+    assert text.count("main.Node {") == 2
+
+
+def test_channels_funcs_and_truncation():
+    ch = variable_from_rpc({"type": "chan int", "kind": CHAN, "addr": 0xC00001E0C0, "len": 2, "cap": 8})
+    assert pretty_print(ch) == "chan int (len 2, cap 8) 0xc00001e0c0"
+    assert pretty_print(variable_from_rpc({"type": "chan error", "kind": CHAN})) == "chan error nil"
+    assert pretty_print(variable_from_rpc({"type": "func()", "kind": FUNC, "value": "main.handler"})) == "func main.handler"
+    assert pretty_print(variable_from_rpc({"type": "func()", "kind": FUNC})) == "func() nil"
+
+    items = [{"type": "int", "kind": INT, "value": str(i)} for i in range(3)]
+    sl = variable_from_rpc({"type": "[]int", "kind": SLICE, "addr": 1, "len": 10, "cap": 16, "children": items})
+    assert pretty_print(sl) == "[]int len: 10, cap: 16 [0, 1, 2, ...+7 more]"
+    s = variable_from_rpc({"type": "string", "kind": STRING, "value": "abc", "len": 40})
+    assert pretty_print(s) == '"abc"...+37 more'
+
+
+def test_cli_text_is_reindented_and_load_config_validated():
+    text = (
+        'main.Config {Name: "service-a, primary", Ports: []int len: 3, cap: 3, [80,443,8080], '
+        'Labels: map[string]string ["env": "prod", "team": "payments", ], Next: *main.Config nil}'
+    )
+    out = format_value_text(text)
+    assert out.splitlines()[0] == "main.Config {"
+    assert '  Name: "service-a, primary",' in out
+    assert "  Ports: []int len: 3, cap: 3, [80,443,8080]," in out
+    assert out.rstrip().endswith("}")
+    assert format_value_text("42") == "42"
+
+    cfg = LoadConfig.from_config({"load_depth": "3"}, max_array_values=500)
+    assert cfg.to_rpc()["MaxVariableRecurse"] == 3
+    assert cfg.to_rpc()["MaxArrayValues"] == 500
+    with pytest.raises(DebuggerError, match="load_string_len"):
+        LoadConfig.from_config({"load_string_len": "lots"})
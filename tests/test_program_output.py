@@ -0,0 +1,69 @@
+"""Program output ring buffer: byte cap, arrival timestamps and the pexpect tee."""
+import time
+
+from dbgcopilot.debugger.output import OutputRing, resumes_target, tee_target_output
+
+
+class _Clock:
+    def __init__(self, start):
+        self.now = start
+
+    def __call__(self):
+        return self.now
+
+
+class _Child:
+    """Just enough of a pexpect child: reads are logged to ``logfile_read``."""
+
+    def __init__(self):
+        self.logfile_read = None
+
+    def receive(self, text):
+        if self.logfile_read is not None:
+            self.logfile_read.write(text)
+
+
+class _Backend:
+    def __init__(self):
+        self.child = _Child()
+
+
+def test_ring_caps_total_bytes_and_stamps_lines_on_arrival():
+    start = time.mktime((2026, 10, 14, 9, 30, 15, 0, 0, -1)) + 0.25
+    clock = _Clock(start)
+    ring = OutputRing(max_bytes=100, clock=clock)
+    ring.write("workerOne locking A\nworkerTwo ")
+    clock.now += 0.5
+    ring.write(b"locking B\n")
+    assert ring.render() == "[09:30:15.250] workerOne locking A\n[09:30:15.250] workerTwo locking B"
+
+    for i in range(1000):
+        ring.write(f"tick {i:04d} " + "x" * 40 + "\n")
+    assert ring.total_bytes <= 100 and ring.dropped == 1000
+    assert [line.text[:9] for line in ring.lines()] == ["tick 0998", "tick 0999"]
+    assert len(ring.render(1).splitlines()) == 1
+    # A line that never ends is cut rather than buffered forever.
+    ring.write("#" * 10_000)
+    assert len(ring.lines()[-1].text) < 10_000
+
+
+def test_tee_keeps_program_lines_while_resuming_and_stops_at_the_prompt():
+    backend = _Backend()
+    ring = OutputRing()
+    tee = tee_target_output(backend, ring)
+    child = backend.child
+    assert tee is not None and tee_target_output(backend, ring) is tee
+
+    child.receive("goroutines\r\n  Goroutine 1 - User: ./hang.go:40\r\n(dlv) ")
+    assert ring.render() == ""
+
+    assert resumes_target("continue") and resumes_target("process continue") and not resumes_target("goroutines")
+    with tee.capturing("continue"):
+        child.receive("continue\r\nworkerOne locking A\r\nworkerTwo lock")
+        child.receive("ing B\r\nworkerOne locking B\r\n")
+        child.receive("received SIGINT, stopping process (will not forward signal)\r\n")
+        child.receive("> main.main() ./hang.go:40 (PC: 0x49a3c5)\r\n    40:\twg.Wait()\r\n(dlv) ")
+        # Anything after the prompt (here, a goroutine dump) is debugger output.
+        child.receive("  Goroutine 6 - User: ./hang.go:21 main.workerOne\r\n")
+    texts = [line.text for line in ring.lines()]
+    assert texts == ["workerOne locking A", "workerTwo locking B", "workerOne locking B"]
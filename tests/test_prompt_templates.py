@@ -0,0 +1,82 @@
+"""Per-issue analysis templates: embedded defaults, directory overrides and load-time validation."""
+import pytest
+
+from dbgcopilot.core.orchestrator import CopilotOrchestrator
+from dbgcopilot.core.state import SessionState
+from dbgcopilot.prompts.templates import (
+    AnalysisTemplate,
+    AnalysisTemplates,
+    PromptTemplateError,
+    issue_for_output,
+    load_analysis_templates,
+)
+
+PANIC = (
+    "panic: runtime error: index out of range [5] with length 3\n\n"
+    "goroutine 1 [running]:\nmain.main()\n\t/x/main.go:9 +0x1d\nexit status 2\n"
+)
+ASLEEP = (
+    "fatal error: all goroutines are asleep - deadlock!\n\n"
+    "goroutine 1 [chan receive]:\nmain.main()\n\t/x/main.go:12 +0x1d\nexit status 2\n"
+)
+
+
+def test_issue_picks_the_template_and_empty_paragraphs_are_dropped():
+    assert issue_for_output(PANIC) == "panic"
+    assert issue_for_output(ASLEEP) == "deadlock"
+    assert issue_for_output("$1 = 42") == "generic"
+
+    templates = AnalysisTemplates.defaults()
+    text = templates.render("panic", debugger="dlv", command="bt", stack=PANIC, findings="Panic analysis: ...")
+    assert text.startswith("The dlv output below shows a panic")
+    assert "Stack dump from `bt`:\npanic: runtime error" in text
+    # No program output or source was supplied, so their headings are left out too.
+    assert "Program output" not in text and "Source around" not in text
+    assert templates.render("generic", stack="$1 = 42") == "Debugger output:\n$1 = 42"
+
+
+def test_directory_overrides_one_issue_and_keeps_the_rest(tmp_path):
+    (tmp_path / "deadlock.txt").write_text(
+        "Domain hint: this service uses an actor model; each mailbox goroutine owns its state.\n\n"
+        "Goroutines ({debugger}):\n{stack}\n\n"
+        "Output:\n{program_output}\n\n"
+        "Literal braces survive: {{mailbox}}\n"
+    )
+    templates = load_analysis_templates({"prompt_templates_dir": str(tmp_path)})
+    sources = templates.sources()
+    assert sources == {"deadlock": str(tmp_path / "deadlock.txt"), "panic": "defaults", "generic": "defaults"}
+    text = templates.render("deadlock", debugger="dlv", stack="goroutine 7 [chan send]:", program_output="")
+    assert text.splitlines()[0].startswith("Domain hint: this service uses an actor model")
+    assert "Goroutines (dlv):\ngoroutine 7 [chan send]:" in text
+    assert "Output:" not in text and "Literal braces survive: {mailbox}" in text
+
+    state = SessionState(session_id="t", config={"prompt_templates_dir": str(tmp_path)})
+    orch = CopilotOrchestrator(backend=None, state=state)
+    assert "actor model" in orch._build_followup_prompt("goroutines -t", ASLEEP)
+    assert "actor model" not in orch._build_followup_prompt("bt", PANIC)
+
+
+def test_broken_templates_fail_when_loaded(tmp_path):
+    with pytest.raises(PromptTemplateError, match=r"line 3: unknown placeholder \{stak\} \(available: debugger,"):
+        AnalysisTemplate.parse("panic", "Header\n\nStack:\n{stak}\n", "panic.txt")
+    with pytest.raises(PromptTemplateError, match="literal brace"):
+        AnalysisTemplate.parse("panic", "map{string]int {stack}", "panic.txt")
+    with pytest.raises(PromptTemplateError, match="positional placeholder"):
+        AnalysisTemplate.parse("panic", "{} {stack}", "panic.txt")
+    with pytest.raises(PromptTemplateError, match="Unknown format code"):
+        AnalysisTemplate.parse("panic", "{stack:d}", "panic.txt")
+    with pytest.raises(PromptTemplateError, match="does not exist"):
+        AnalysisTemplates.load(str(tmp_path / "missing"))
+
+    (tmp_path / "deadlok.txt").write_text("{stack}\n")
+    with pytest.raises(PromptTemplateError, match=r"deadlok.txt: not a known issue type \(expected one of deadlock"):
+        AnalysisTemplates.load(str(tmp_path))
+
+    # A broken override on reload leaves the working templates in place.
+    (tmp_path / "deadlok.txt").unlink()
+    state = SessionState(session_id="t", config={"prompt_templates_dir": str(tmp_path)})
+    orch = CopilotOrchestrator(backend=None, state=state)
+    (tmp_path / "generic.txt").write_text("Output {stack\n")
+    with pytest.raises(PromptTemplateError, match="generic.txt line 1"):
+        orch.reload_prompts()
+    assert orch.analysis_templates.sources()["generic"] == "defaults"
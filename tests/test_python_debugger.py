@@ -0,0 +1,119 @@
+"""pdb backend: stop classification, thread stacks and interpreter selection."""
+import json
+
+from dbgcopilot.debugger import detect_backend
+from dbgcopilot.debugger.base import Breakpoint
+from dbgcopilot.debugger.python import (
+    parse_faulthandler,
+    parse_stop,
+    parse_where,
+    resolve_interpreter,
+    threads_from_probe,
+)
+
+RUNTIME = ["/usr/lib/python3.11"]
+
+UNCAUGHT = """\
+Traceback (most recent call last):
+  File "/usr/lib/python3.11/pdb.py", line 1793, in main
+    pdb._run(target)
+  File "/srv/app/job.py", line 11, in <module>
+    f(0)
+  File "/srv/app/job.py", line 10, in f
+    return 10 / n
+ZeroDivisionError: division by zero
+Uncaught exception. Entering post mortem debugging
+Running 'cont' or 'step' will restart the program
+> /srv/app/job.py(10)f()
+-> return 10 / n
+"""
+
+WHERE = """\
+  /usr/lib/python3.11/bdb.py(600)run()
+-> exec(cmd, globals, locals)
+  <string>(1)<module>()
+  /srv/app/job.py(11)<module>()
+-> f(0)
+> /srv/app/job.py(10)f()
+-> return 10 / n
+"""
+
+
+def test_exception_exit_and_breakpoint_stops_are_told_apart():
+    event = parse_stop(UNCAUGHT, runtime=RUNTIME)
+    assert event.reason == "exception" and not event.clean_exit
+    assert event.detail == "ZeroDivisionError: division by zero"
+    assert (event.frame.function, event.frame.line) == ("f", 10)
+
+    assert parse_stop("The program finished and will be restarted\n").clean_exit
+    failed = parse_stop("The program exited via sys.exit(). Exit status: 3\n")
+    assert failed.exited and failed.exit_code == 3 and not failed.clean_exit
+
+    bp = Breakpoint(id=2, location="/srv/app/job.py:10", file="/srv/app/job.py", line=10)
+    hit = parse_stop("> /srv/app/job.py(10)f()\n-> return 10 / n\n", [bp])
+    assert hit.reason == "breakpoint" and hit.breakpoint_id == 2
+
+
+def test_where_drops_pdb_frames_and_threads_map_to_goroutines():
+    frames = parse_where(WHERE, RUNTIME)
+    assert [(f.function, f.line) for f in frames] == [("f", 10), ("<module>", 11)]
+
+    payload = {
+        "runtime": RUNTIME,
+        "threads": [
+            {"id": 7, "name": "MainThread", "main": True, "frames": [["/usr/lib/python3.11/bdb.py", 600, "run", ""]]},
+            {
+                "id": 8,
+                "name": "worker-1",
+                "daemon": True,
+                "frames": [
+                    ["/srv/app/job.py", 5, "worker", "with lock:"],
+                    ["/usr/lib/python3.11/threading.py", 982, "run", "self._target()"],
+                ],
+            },
+        ],
+    }
+    dump = threads_from_probe(json.loads(json.dumps(payload)), main_frames=frames, main_state="exception")
+    main, worker = dump.goroutines
+    assert main.current and main.state == "exception" and main.frames[0].function == "f"
+    assert worker.state == "lock wait" and worker.frames[1].module == "libpython"
+    assert "Thread 8 'worker-1' (daemon) [lock wait]:" in dump.raw
+
+
+def test_faulthandler_dump_marks_the_pdb_thread_current():
+    text = """\
+Thread 0x00007f0000000002 (most recent call first):
+  File "/usr/lib/python3.11/threading.py", line 1139 in _wait_for_tstate_lock
+  File "/usr/lib/python3.11/threading.py", line 1119 in join
+  File "/srv/app/dl.py", line 17 in <module>
+  File "/usr/lib/python3.11/bdb.py", line 600 in run
+  File "/usr/lib/python3.11/pdb.py", line 1793 in main
+
+Current thread 0x00007f0000000001 (most recent call first):
+  File "/srv/app/dl.py", line 6 in one
+"""
+    main, other = parse_faulthandler(text, RUNTIME).goroutines
+    assert main.current and main.state == "join"
+    assert [f.function for f in main.frames] == ["_wait_for_tstate_lock", "join", "<module>"]
+    assert not other.current and other.id == 0x7F0000000001
+
+
+def test_interpreter_selection_prefers_explicit_then_venv_then_shebang(tmp_path):
+    project = tmp_path / "proj"
+    (project / "pkg").mkdir(parents=True)
+    script = project / "pkg" / "main.py"
+    script.write_text("#!/usr/bin/env python3.11\nprint('hi')\n")
+    assert resolve_interpreter(str(script), environ={}).source == "shebang"
+
+    venv = project / ".venv"
+    (venv / "bin").mkdir(parents=True)
+    (venv / "pyvenv.cfg").write_text("home = /usr/bin\n")
+    (venv / "bin" / "python").write_text("")
+    found = resolve_interpreter(str(script), environ={"PATH": "/usr/bin"})
+    assert found.path == str(venv / "bin" / "python") and found.source.startswith("venv")
+    assert found.env["VIRTUAL_ENV"] == str(venv) and found.env["PATH"].startswith(str(venv / "bin"))
+
+    explicit = tmp_path / "python3"
+    explicit.write_text("")
+    assert resolve_interpreter(str(script), str(explicit), environ={}).source == "explicit"
+    assert detect_backend(str(script)) == "pdb"
@@ -0,0 +1,63 @@
+"""Secret redaction of debugger output bound for the LLM."""
+from dbgcopilot.core.orchestrator import CopilotOrchestrator
+from dbgcopilot.core.state import Attempt, SessionState
+from dbgcopilot.utils.redact import Redactor, command_variable, format_preview
+
+
+def test_name_heuristics_cover_variables_and_frame_strings():
+    redactor = Redactor()
+    text = "\n".join(
+        [
+            '#1 0x5555 in login (user=0x5556 "bob", password=0x5557 "hunter22") at main.c:12',
+            'cfg = main.Config {User: "bob", APIKey: "abcd1234", Timeout: 30}',
+            '\tauthToken := os.Getenv("TOKEN")',
+            "if key == 3 { /src/auth/main.go:12 }",
+            'dsn = "postgres://admin:s3cr3tpw@db:5432/app"',
+        ]
+    )
+    out = redactor.redact(text)
+    assert 'password=<redacted:len=8>' in out
+    assert 'User: "bob", APIKey: <redacted:len=8>, Timeout: 30' in out
+    assert 'os.Getenv("TOKEN")' in out and "key == 3" in out and "main.go:12" in out
+    assert "admin:<redacted:len=8>@db" in out
+    # The literal is remembered and hidden where it reappears without a name.
+    assert redactor.redact("retrying with hunter22") == "retrying with <redacted:len=8>"
+
+
+def test_print_output_custom_patterns_and_dry_run():
+    assert command_variable("print cfg.APIKey") == "cfg.APIKey"
+    assert command_variable("frame variable secret") == "secret"
+    redactor = Redactor.from_config({"redact_patterns": '["ACME-[0-9]{6}"]', "redact_names": "(?i)pin"})
+    assert redactor.redact('"s3cret"', variable="cfg.APIKey") == "<redacted:len=6>"
+    assert redactor.redact("order ACME-123456 placed") == "order <redacted:len=11> placed"
+    assert redactor.redact("cardPin = 4321") == "cardPin = <redacted:len=4>"
+    found = Redactor().preview([("Assistant: (executed) p dbPassword\n\"letmein\"", ""), ("password: xyz9", "")])
+    report = format_preview(found)
+    assert "Would redact 2 value(s):" in report
+    assert "line 2: dbPassword by name heuristic, len=7" in report
+    assert Redactor.from_config({"redact": "off"}).redact("password = abc") == "password = abc"
+
+
+def test_followup_prompt_is_redacted():
+    state = SessionState(session_id="t")
+    orch = CopilotOrchestrator(backend=None, state=state)
+    prompt = orch._build_followup_prompt("print apiToken", '"tok_live_1234"')
+    assert "tok_live_1234" not in prompt and "<redacted:len=13>" in prompt
+    state.attempts.append(Attempt(cmd="print apiToken", output_snippet='"tok_live_1234"'))
+    state.last_output = '"tok_live_1234"'
+    assert "Would redact" in orch.redaction_dry_run()
+
+
+def test_quoted_keys_of_a_python_dict_and_a_json_object_are_redacted():
+    redactor = Redactor()
+    # pdb's p of a dict, and a JSON-RPC reply in a debug trace.
+    assert redactor.redact("{'password': 'hunter2', 'user': 'bob'}") == "{'password': <redacted:len=7>, 'user': 'bob'}"
+    out = redactor.redact('{"db_password": "s3cr3tpw", "port": 5432}')
+    assert out == '{"db_password": <redacted:len=8>, "port": 5432}'
+
+
+def test_a_go_type_after_a_sensitive_name_is_not_taken_for_its_value():
+    redactor = Redactor()
+    assert redactor.redact("Keys: []string len: 2, cap: 2, [...]") == "Keys: []string len: 2, cap: 2, [...]"
+    assert redactor.redact("apiKeys: map[string]string []") == "apiKeys: map[string]string []"
+    assert redactor.redact("Keys: abcd1234") == "Keys: <redacted:len=8>"
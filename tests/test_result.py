@@ -0,0 +1,160 @@
+"""AnalysisResult construction and the text/JSON/Markdown renderers that share it."""
+from datetime import datetime, timezone
+from pathlib import Path
+import json
+
+from dbgcopilot.analyze.result import (
+    SCHEMA_VERSION,
+    Hypothesis,
+    build_result,
+    parse_report_sections,
+    render_json,
+    render_markdown,
+    render_text,
+    severity_rank,
+)
+
+CRASH_SRC = Path(__file__).resolve().parents[1] / "examples" / "crash" / "go" / "crash.go"
+
+NIL_TRACE = """\
+panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4915b6]
+
+goroutine 1 [running]:
+main.boom()
+\t{crash}:8 +0x16
+main.main()
+\t{crash}:13 +0x4f
+exit status 2
+""".replace("{crash}", str(CRASH_SRC))
+
+REPORT = """\
+## Analysis Summary
+main.boom dereferences a nil *int.
+
+## Findings
+- ptr is never assigned before use.
+
+## Suggested Fixes
+- Initialize ptr: allocate with new(int) before dereferencing.
+- Add a nil check
+  before the print.
+
+## Next Steps
+1. Re-run with the fix applied.
+"""
+
+
+def test_build_result_from_panic_trace_and_report():
+    result = build_result(["break main.boom", NIL_TRACE], report=REPORT, debugger="delve", program="crash")
+    assert result.issue_type == "panic"
+    assert result.issue_detail == "nil-deref"
+    assert result.severity == "critical"
+    assert result.participants[0].id == 1
+    assert result.participants[0].role == "panicking"
+    top = result.frames[0]
+    assert top.function == "main.boom" and top.line == 8
+    active = [s for s in top.source if s.active]
+    assert active and "fmt.Println(*ptr)" in active[0].text
+    assert "nil *int" in result.explanation
+    assert result.suggested_fixes[0].summary == "Initialize ptr"
+    assert result.suggested_fixes[1].summary == "Add a nil check before the print."
+    assert result.next_steps == ["Re-run with the fix applied."]
+
+
+def test_json_and_text_render_the_same_result():
+    result = build_result([NIL_TRACE], report=REPORT)
+    doc = json.loads(render_json(result))
+    assert doc["schema_version"] == SCHEMA_VERSION
+    assert doc["issue_type"] == "panic"
+    assert doc["frames"][0]["function"] == "main.boom"
+    assert doc["suggested_fixes"][0]["details"].startswith("allocate")
+    text = render_text(result)
+    assert text.startswith("Issue: panic (nil-deref)")
+    assert "goroutine 1 [running] panicking at main.boom" in text
+    assert "- Initialize ptr: allocate" in text
+
+
+def test_markdown_report_renders_the_same_result():
+    result = build_result([NIL_TRACE], report=REPORT, debugger="delve", program="/srv/bin/crash")
+    md = render_markdown(result, generated=datetime(2026, 10, 14, 9, 30, 15, tzinfo=timezone.utc))
+    lines = md.splitlines()
+    assert lines[0] == "# Analysis report: crash"
+    assert "| Binary | `/srv/bin/crash` |" in lines and "| Generated | 2026-10-14 09:30:15 UTC |" in lines
+    assert "| Issue | panic (nil-deref) |" in lines
+    assert "## Goroutines involved" in lines
+    assert f"| 1 | running | panicking | `main.boom` at `{CRASH_SRC}:8` |" in lines
+    # Each snippet is a fenced block with the active line marked by a comment in the file's language.
+    start = lines.index("```go")
+    block = lines[start + 1:lines.index("```", start)]
+    assert "\tfmt.Println(*ptr)  // <-- active line" in block
+    assert sum(line.endswith("<-- active line") for line in block) == 1
+    assert "- **Initialize ptr**: allocate with new(int) before dereferencing." in lines
+    assert "1. Re-run with the fix applied." in lines
+    # Pipes in cells are escaped so the table keeps its columns.
+    result.participants[0].role = "a|b"
+    assert "| a\\|b |" in render_markdown(result)
+
+
+def test_hang_and_unstructured_report():
+    assert parse_report_sections("just prose") == {}
+    result = build_result(["Process 42 stopped"], report="just prose", hang=True)
+    assert result.issue_type == "hang"
+    assert result.severity == "warning"
+    assert result.explanation == "just prose"
+    assert result.suggested_fixes == []
+
+
+def test_severity_levels():
+    assert build_result(["Process 42 exited with status = 0"]).severity == "none"
+    # The report's sections do not raise the severity; what the analyzers found does.
+    assert build_result([], report="The program exited normally.").severity == "none"
+    assert build_result([], report="## Suggested Fixes\n- Guard the map with a mutex").severity == "none"
+    stopped = "> main.worker() ./main.go:42 (hits goroutine(1):1 total:1)\n"
+    stopped += "0  0x4a3c5f in main.worker\n   at ./main.go:42"
+    assert build_result([stopped], report="## Suggested Fixes\n- Nothing to fix").severity == "info"
+    assert severity_rank("warning") > severity_rank("info")
+
+
+DIAGNOSED = REPORT.replace("## Findings", """## Diagnosis
+main.boom dereferences ptr, a nil *int, at crash.go:8.
+
+## Alternative Hypotheses
+- Memory corruption: unlikely, the fault address is 0x0.
+- A data race on ptr — only one goroutine exists.
+- none
+
+## Findings""")
+
+
+def test_diagnosis_alternatives_and_confidence():
+    result = build_result([NIL_TRACE], report=DIAGNOSED)
+    assert result.diagnosis == "main.boom dereferences ptr, a nil *int, at crash.go:8."
+    assert result.alternatives == [
+        Hypothesis("Memory corruption", "unlikely, the fault address is 0x0."),
+        Hypothesis("A data race on ptr", "only one goroutine exists."),
+    ]
+    # The panic analyzer classified a nil dereference in main.boom too; two open alternatives cost a little.
+    assert (result.confidence, result.confidence_level) == (0.7, "medium")
+    assert "it names what the analyzers pinpointed: main.boom" in result.confidence_reasons
+    doc = json.loads(render_json(result))
+    assert doc["confidence_level"] == "medium" and doc["alternatives"][0]["summary"] == "Memory corruption"
+    text = render_text(result)
+    assert "Confidence: medium (0.70): " in text and "  - Memory corruption: unlikely" in text
+    assert "| Confidence | medium (0.70) |" in render_markdown(result)
+
+    # Without a Diagnosis section the summary's first point stands in, and without alternatives it scores higher.
+    plain = build_result([NIL_TRACE], report=REPORT)
+    assert plain.diagnosis == "main.boom dereferences a nil *int." and plain.confidence_level == "high"
+
+
+def test_low_confidence_is_flagged_first():
+    # The LLM blames a lock while the analyzers saw a nil dereference.
+    result = build_result([NIL_TRACE], report="## Analysis Summary\nThe workers wait on each other's mutex forever.")
+    assert result.low_confidence and result.confidence == 0.15
+    assert render_text(result).startswith("WARNING: low confidence (0.15): the analyzers found a panic")
+    assert "> **WARNING: low confidence (0.15): " in render_markdown(result)
+
+    # Nothing deterministic to compare against is low as well; no LLM text at all is not scored.
+    assert build_result(["Process 42 stopped"], report="Maybe a slow disk.").confidence_level == "low"
+    assert build_result([NIL_TRACE]).confidence_level == ""
@@ -0,0 +1,134 @@
+"""Session recording and deterministic replay."""
+import pytest
+
+from dbgcopilot.debugger.launch import Invocation, parse_env
+from dbgcopilot.session import Recorder, ReplayError, ReplayTruncated, replay
+
+
+class _FakeDelve:
+    name = "delve"
+    prompt = "(dlv) "
+    program = "./hang"
+
+    def run_command(self, cmd, timeout=None):
+        if cmd == "bogus":
+            raise RuntimeError("Command failed: command not available")
+        return f"output of {cmd}"
+
+
+def _record(path, **meta):
+    backend = _FakeDelve()
+    rec = Recorder(str(path), clock=lambda: 0.0)
+    rec.start(backend, **meta)
+    backend.run_command("break hang.go:20")
+    with pytest.raises(RuntimeError):
+        backend.run_command("bogus")
+    rec.record_llm("prompt", "Try <cmd>goroutines</cmd>", provider="mock-local")
+    backend.run_command("goroutines")
+    return rec
+
+
+def test_round_trip(tmp_path):
+    path = tmp_path / "session.ndjson"
+    _record(path).close()
+
+    session = replay(str(path))
+    assert not session.truncated
+    assert session.backend.name == "delve"
+    assert session.backend.run_command("break hang.go:20") == "output of break hang.go:20"
+    with pytest.raises(RuntimeError, match="command not available"):
+        session.backend.run_command("bogus")
+    assert session.llm("anything") == "Try <cmd>goroutines</cmd>"
+    assert session.backend.run_command("goroutines") == "output of goroutines"
+    with pytest.raises(ReplayError) as exc:
+        session.backend.run_command("continue")
+    assert not isinstance(exc.value, ReplayTruncated)
+
+
+def test_divergence_is_reported(tmp_path):
+    path = tmp_path / "session.ndjson"
+    _record(path).close()
+    with pytest.raises(ReplayError, match="expected 'break hang.go:20'"):
+        replay(str(path)).backend.run_command("continue")
+
+
+def test_truncated_transcript_is_flagged(tmp_path):
+    path = tmp_path / "session.ndjson"
+    _record(path)  # never closed: no end marker
+    with open(path, "a", encoding="utf-8") as fh:
+        fh.write('{"seq": 9, "type": "command", "cmd": "sta')
+
+    session = replay(str(path))
+    assert session.truncated
+    assert "truncated" in session.describe()
+    session.backend.run_command("break hang.go:20")
+    with pytest.raises(RuntimeError):
+        session.backend.run_command("bogus")
+    session.backend.run_command("goroutines")
+    with pytest.raises(ReplayTruncated, match="line 6 is incomplete"):
+        session.backend.run_command("stack")
+    session.llm("p")
+    with pytest.raises(ReplayTruncated):
+        session.llm("p")
+
+
+def test_the_program_invocation_is_recorded_and_replayed(tmp_path):
+    invocation = Invocation(
+        args=["--config", "prod cfg.yaml"], env=parse_env(["GOMAXPROCS=2", "MODE=a=b"]), inherit_env=False
+    )
+    path = tmp_path / "session.ndjson"
+    _record(path, invocation=invocation.to_dict()).close()
+
+    session = replay(str(path))
+    assert session.transcript.invocation == invocation
+    assert session.backend.invocation.environment({"HOME": "/root"}) == {"GOMAXPROCS": "2", "MODE": "a=b"}
+    assert session.describe().endswith(
+        "The program was started as: env -i GOMAXPROCS=2 MODE=a=b ./hang --config 'prod cfg.yaml'"
+    )
+    # gdb and lldb are told the same before the program runs.
+    assert invocation.gdb_commands() == [
+        "unset environment",
+        "set environment GOMAXPROCS=2",
+        "set environment MODE=a=b",
+        "set args --config 'prod cfg.yaml'",
+    ]
+    assert invocation.lldb_commands()[-1] == 'settings set target.run-args "--config" "prod cfg.yaml"'
+    with pytest.raises(ValueError, match="expected KEY=VAL"):
+        parse_env(["GOMAXPROCS"])
+
+    # An older transcript without an invocation keeps the default.
+    _record(path).close()
+    assert replay(str(path)).transcript.invocation.default
+
+
+def test_a_recorded_invocation_is_redacted_and_dlv_keeps_what_it_needs(tmp_path, monkeypatch):
+    from dbgagent.runner import AgentRequest, DebugAgentRunner
+    from dbgcopilot.backends.delve_subprocess import DelveSubprocessBackend
+    from dbgcopilot.session import load_transcript
+
+    invocation = Invocation(
+        args=["--token=abcdef123", "--mode", "prod"], env={"DB_PASSWORD": "hunter2", "MODE": "prod"}, inherit_env=False
+    )
+    fields = dict.fromkeys(["model", "api_key", "classpath", "sourcepath", "main_class", "corefile", "log_path"])
+    request = AgentRequest(
+        debugger="delve", provider="mock-local", program="./hang", goal_type="hang", goal_text="",
+        resume_context=None, max_steps=5, language="en", log_enabled=False,
+        report_path=tmp_path / "report.md", invocation=invocation, **fields,
+    )
+    runner = DebugAgentRunner(request)
+    runner.backend = _FakeDelve()
+    path = tmp_path / "session.ndjson"
+    runner._start_recording(path)
+    runner._end_backend()
+    recorded = load_transcript(str(path)).invocation
+    assert recorded.args == ["--token=<redacted:len=9>", "--mode", "prod"]
+    assert recorded.env == {"DB_PASSWORD": "<redacted:len=7>", "MODE": "prod"} and not recorded.inherit_env
+    assert "hunter2" not in path.read_text()
+
+    # dlv starts with the program's environment plus the variables it needs itself, never an empty one.
+    monkeypatch.setenv("HOME", "/home/dev")
+    monkeypatch.setenv("PATH", "/usr/bin")
+    backend = DelveSubprocessBackend("./hang", env=invocation.environment())
+    assert backend._delve_env()["HOME"] == "/home/dev" and backend._delve_env()["MODE"] == "prod"
+    assert DelveSubprocessBackend("./hang", env={"PATH": "/opt/bin"})._delve_env()["PATH"] == "/opt/bin"
+    assert DelveSubprocessBackend("./hang")._delve_env() is None
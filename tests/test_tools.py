@@ -0,0 +1,124 @@
+"""Tool registry: schema validation, structured errors and the native function-calling loop."""
+import json
+
+from dbgcopilot.analyze import Frame
+from dbgcopilot.debugger.base import StopEvent, Variable
+from dbgcopilot.llm.tools import ToolCall, ToolReply, to_anthropic_messages, validate_arguments
+from dbgcopilot.session.interactive import Interactive
+from dbgcopilot.session.tools import SET_BREAKPOINT_SCHEMA, debugger_tools
+
+
+class _FakeDelve:
+    name = "delve"
+
+    def __init__(self):
+        self.calls = []
+
+    def continue_(self):
+        self.calls.append("continue")
+        return StopEvent(reason="breakpoint", raw="> main.worker() ./main.go:42")
+
+    def next(self):
+        self.calls.append("next")
+        frame = Frame(function="main.worker", file="./main.go", line=43)
+        return StopEvent(reason="step", frame=frame, detail="next", stack=[frame])
+
+    def read_variable(self, expr, cfg=None):
+        self.calls.append(f"print {expr}")
+        return Variable(name=expr, value="3")
+
+    def run_command(self, cmd, timeout=None):
+        return f"ran {cmd}"
+
+
+class _ScriptedChat:
+    def __init__(self, replies):
+        self.replies = list(replies)
+        self.requests = []
+
+    def __call__(self, messages, tools):
+        self.requests.append((list(messages), [t.name for t in tools]))
+        return self.replies.pop(0)
+
+
+def test_arguments_are_validated_and_errors_are_structured():
+    registry = debugger_tools(_FakeDelve())
+    assert validate_arguments(SET_BREAKPOINT_SCHEMA, {"location": "main.go:42", "hitcount": 2}) == []
+    problems = validate_arguments(SET_BREAKPOINT_SCHEMA, {"hitcount": "2", "color": "red"})
+    assert "arguments: missing required property 'location'" in problems
+    assert "hitcount: expected integer, got string" in problems
+    assert "arguments: unexpected property 'color'" in problems
+
+    unknown = registry.invoke(ToolCall("c1", "reboot"))
+    error = json.loads(unknown.content)
+    assert unknown.is_error and error["error"] == "unknown_tool"
+    assert "read_variable" in error["available"]
+
+    bad = json.loads(registry.invoke(ToolCall("c2", "read_variable", {})).content)
+    assert bad["error"] == "invalid_arguments" and bad["parameters"]["required"] == ["expr"]
+    assert registry.invoke(ToolCall("c3", "read_variable", {"expr": "n"})).content == "n = 3"
+    # Without a structured eval_in_frame the tool falls back to Delve's frame-scoped print.
+    scoped = registry.invoke(ToolCall("c4", "eval_in_frame", {"expr": "len(q)", "frame": 1, "goroutine": 7}))
+    assert scoped.content == "ran goroutine 7 frame 1 print len(q)"
+    # Other CLI backends have no such syntax, so the tool says so instead of sending Delve's.
+    gdb = _FakeDelve()
+    gdb.name = "gdb"
+    unsupported = debugger_tools(gdb).invoke(ToolCall("c4", "eval_in_frame", {"expr": "n", "frame": 1}))
+    assert unsupported.is_error and json.loads(unsupported.content)["error"] == "unsupported"
+    assert "gdb has no frame-scoped evaluation" in json.loads(unsupported.content)["message"]
+    # A regex breakpoint is Delve's /pattern/, gdb's rbreak and LLDB's breakpoint set -r.
+    pattern = {"location": "^main\\.handle", "regex": True}
+    assert registry.invoke(ToolCall("c5", "set_breakpoint", pattern)).content == "ran break /^main\\.handle/"
+    assert debugger_tools(gdb).invoke(ToolCall("c5", "set_breakpoint", pattern)).content == "ran rbreak ^main\\.handle"
+    conditional = debugger_tools(gdb).invoke(ToolCall("c5", "set_breakpoint", dict(pattern, condition="n > 3")))
+    assert conditional.is_error and json.loads(conditional.content)["error"] == "unsupported"
+    lldb = _FakeDelve()
+    lldb.name = "lldb"
+    placed = debugger_tools(lldb).invoke(ToolCall("c5", "set_breakpoint", dict(pattern, condition="n > 3")))
+    assert placed.content == 'ran breakpoint set -r ^main\\.handle -c "n > 3"'
+    jdb = _FakeDelve()
+    jdb.name = "jdb"
+    assert debugger_tools(jdb).invoke(ToolCall("c5", "set_breakpoint", pattern)).is_error
+    stepped = registry.invoke(ToolCall("c5", "next")).content
+    assert stepped.startswith("Stopped (step: next) at main.worker ./main.go:43\n")
+    assert "main.worker" in stepped.splitlines()[1]
+    # step_out falls back to the debugger's own command when there is no structured method.
+    assert registry.invoke(ToolCall("c6", "step_out")).content == "ran stepout"
+
+
+def test_native_tool_calls_run_and_results_return_as_tool_messages():
+    dbg = _FakeDelve()
+    chat = _ScriptedChat(
+        [
+            ToolReply(calls=[ToolCall("a", "continue"), ToolCall("b", "read_variable", {"expr": "len(queue)"})]),
+            ToolReply(calls=[ToolCall("c", "reboot")]),
+            ToolReply(text="The queue holds 3 items."),
+        ]
+    )
+    reply = Interactive(dbg, lambda prompt: "unused", chat=chat).ask("what is in the queue?")
+    assert reply.answer == "The queue holds 3 items."
+    assert dbg.calls == ["continue", "print len(queue)"]
+    messages, offered = chat.requests[1]
+    assert offered == [
+        "set_breakpoint", "continue", "step", "next", "step_out", "stacktrace", "read_variable", "eval_in_frame",
+        "list_goroutines", "command",
+    ]
+    assert messages[-3]["tool_calls"][1]["function"]["name"] == "read_variable"
+    assert messages[-1] == {"role": "tool", "tool_call_id": "b", "name": "read_variable", "content": "len(queue) = 3"}
+    assert json.loads(chat.requests[2][0][-1]["content"])["error"] == "unknown_tool"
+
+    system, converted = to_anthropic_messages(chat.requests[2][0])
+    assert system.startswith("You are a debugging copilot")
+    assert [m["role"] for m in converted] == ["user", "assistant", "user", "assistant", "user"]
+    assert converted[2]["content"][1] == {"type": "tool_result", "tool_use_id": "b", "content": "len(queue) = 3"}
+
+
+def test_tool_calls_are_hard_capped_per_question():
+    dbg = _FakeDelve()
+    burst = ToolReply(calls=[ToolCall(str(i), "continue") for i in range(3)])
+    chat = _ScriptedChat([burst, ToolReply(calls=[ToolCall("x", "continue")])])
+    reply = Interactive(dbg, lambda prompt: "unused", chat=chat, max_actions=2).ask("keep going")
+    assert dbg.calls == ["continue", "continue"]
+    assert json.loads(chat.requests[1][0][-1]["content"])["error"] == "tool_limit"
+    assert reply.limited and "Stopped after 2 debugger action(s)" in reply.answer
+    assert not chat.replies
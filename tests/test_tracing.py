@@ -0,0 +1,90 @@
+"""OpenTelemetry spans: off without an endpoint, nested per thread, and carried to worker threads."""
+from concurrent.futures import ThreadPoolExecutor
+
+from dbgcopilot.llm import providers
+from dbgcopilot.utils import tracing
+
+
+def test_tracing_stays_off_without_an_endpoint():
+    assert tracing.endpoint({}) == ""
+    assert tracing.endpoint({"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318"}) == "http://collector:4318"
+    off = {"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_SDK_DISABLED": "true"}
+    assert tracing.endpoint(off) == ""
+    assert tracing.configure({}) is False and not tracing.enabled()
+
+    def work():
+        return 42
+
+    # Disabled, every span is the same no-op and propagate hands back the function itself.
+    assert tracing.span(tracing.SPAN_LLM, model="m") is tracing.span(tracing.SPAN_PROMPT)
+    assert tracing.propagate(work) is work
+    with tracing.span(tracing.SPAN_COMMAND, command="goroutines") as span:
+        span.set(lines=3)
+
+
+def test_spans_nest_and_follow_work_to_worker_threads():
+    with tracing.capture() as spans:
+        with tracing.span(tracing.SPAN_BATCH, targets=1) as batch:
+            with ThreadPoolExecutor(max_workers=1) as pool:
+
+                def analyze():
+                    with tracing.span(tracing.SPAN_ANALYZE, debugger="delve", program="./server"):
+                        providers.create_client("mock-local")("why is goroutine 19 stuck?")
+
+                def unrelated():
+                    with tracing.span(tracing.SPAN_COMMAND):
+                        pass
+
+                pool.submit(tracing.propagate(analyze)).result()
+                # Without propagate the worker starts a trace of its own.
+                pool.submit(unrelated).result()
+            batch.set(severity="warning", failed=0)
+        try:
+            with tracing.span(tracing.SPAN_LLM, provider="mock-local"):
+                raise TimeoutError("deadline")
+        except TimeoutError:
+            pass
+
+    names = [(s.name, s.parent.name if s.parent else None) for s in spans]
+    assert names == [
+        (tracing.SPAN_BATCH, None),
+        (tracing.SPAN_ANALYZE, tracing.SPAN_BATCH),
+        (tracing.SPAN_LLM_REQUEST, tracing.SPAN_ANALYZE),
+        (tracing.SPAN_COMMAND, None),
+        (tracing.SPAN_LLM, None),
+    ]
+    assert spans[0].attributes == {"dbgcopilot.targets": 1, "dbgcopilot.severity": "warning", "dbgcopilot.failed": 0}
+    assert spans[1].attributes == {"dbgcopilot.debugger": "delve", "dbgcopilot.program": "./server"}
+    assert spans[2].attributes["gen_ai.system"] == "mock-local"
+    assert spans[4].error == "TimeoutError: deadline"
+    assert not tracing.enabled()
+
+
+def test_batch_span_records_the_worst_severity(tmp_path):
+    from dbgagent.batch import run_batch
+    from dbgagent.runner import AgentRequest
+    from dbgcopilot.analyze.result import AnalysisResult
+
+    class Runner:
+        def __init__(self, request):
+            self.request = request
+            self.result = AnalysisResult(severity="critical" if request.program == "./crash" else "info")
+
+        def run(self, context=None):
+            return "Final report"
+
+    fields = dict.fromkeys(["model", "api_key", "classpath", "sourcepath", "main_class", "corefile", "log_path"])
+    requests = [
+        AgentRequest(
+            debugger="delve", provider="mock-local", program=program, goal_type="crash", goal_text="",
+            resume_context=None, max_steps=5, language="en", log_enabled=False,
+            report_path=tmp_path / f"{program[2:]}.md", **fields,
+        )
+        for program in ("./server", "./crash")
+    ]
+    with tracing.capture() as spans:
+        report = run_batch(requests, jobs=2, runner_factory=Runner)
+    assert report.worst_severity == "critical" and not report.failed()
+    assert spans[0].name == tracing.SPAN_BATCH
+    assert spans[0].attributes == {"dbgcopilot.targets": 2, "dbgcopilot.jobs": 2, "dbgcopilot.failed": 0,
+                                   "dbgcopilot.severity": "critical"}
@@ -0,0 +1,48 @@
+"""Local triage: clean runs skip the LLM, anything unusual still reaches it."""
+from dbgcopilot.analyze.triage import CHECK_EXIT, CHECK_HANG, CHECK_PANIC, triage_run
+
+CLEAN = "Process 4242 has exited with status 0\n"
+PANIC = """panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x48f1a2]
+
+goroutine 1 [running]:
+main.main()
+\t/src/app/main.go:12 +0x22
+exit status 2
+"""
+
+
+def test_clean_run_passes_every_check_with_evidence():
+    triage = triage_run([CLEAN], exited=True, exit_code=0, program_output="hello\n", hang_timeout=10)
+    assert not triage.anomaly
+    assert [(c.name, c.passed) for c in triage.checks] == [(CHECK_EXIT, True), (CHECK_PANIC, True), (CHECK_HANG, True)]
+    assert triage.describe() == (
+        "No issues detected: exited with status 0; no panic, fatal error or crash signal in 44 byte(s) of output; "
+        "no stall longer than 10s"
+    )
+    report = triage.report()
+    assert report.startswith("Analysis Summary:\n- No issues detected")
+    assert "- exit status: exited with status 0" in report
+
+
+def test_anomalies_are_sent_on_to_the_llm():
+    failed = triage_run(["Process 4242 has exited with status 3\n"], exited=True, exit_code=3)
+    assert failed.anomaly and failed.checks[0].evidence == "exited with status 3"
+
+    # A panic the program printed counts even when the debugger output looks clean.
+    panicked = triage_run([CLEAN], exited=True, exit_code=0, program_output=PANIC)
+    assert panicked.anomaly and not panicked.checks[1].passed
+    assert panicked.checks[1].evidence.startswith("found 'panic: runtime error: invalid memory address")
+
+    hung = triage_run([], exited=False, stop_reason="paused", hung=True, idle_seconds=12.3)
+    assert [c.passed for c in hung.checks] == [False, True, False]
+    assert hung.describe() == (
+        "Anomaly detected: did not exit (stopped: paused); "
+        "no panic, fatal error or crash signal in 0 byte(s) of output; no progress for 12.3s"
+    )
+
+    asleep = triage_run(["fatal error: all goroutines are asleep - deadlock!\n"], exited=False, stop_reason="fatal")
+    assert asleep.anomaly and asleep.checks[2].evidence == "the runtime reported all goroutines asleep"
+    assert triage_run(["Program received signal SIGSEGV"], exited=True, exit_code=0).checks[1].evidence == (
+        "found 'SIGSEGV'"
+    )